@@ -9,11 +9,13 @@
 //
 // Run: go run main.go
 // Test: nc localhost 8080 (then type messages)
+// Or:  go run main.go -addr :9001   (several on different ports, as backends for 08-load-balancer)
 package main
 
 import (
 	"bufio"
 	"context"
+	"flag"
 	"fmt"
 	"log"
 	"net"
@@ -23,9 +25,10 @@ import (
 	"syscall"
 )
 
-const addr = ":8080"
-
 func main() {
+	addr := flag.String("addr", ":8080", "Address to listen on")
+	flag.Parse()
+
 	// Create a context for graceful shutdown
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
@@ -35,14 +38,14 @@ func main() {
 	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
 
 	// Start TCP listener
-	listener, err := net.Listen("tcp", addr)
+	listener, err := net.Listen("tcp", *addr)
 	if err != nil {
-		log.Fatalf("Failed to listen on %s: %v", addr, err)
+		log.Fatalf("Failed to listen on %s: %v", *addr, err)
 	}
 	defer listener.Close()
 
-	log.Printf("🚀 TCP Echo Server listening on %s", addr)
-	log.Println("   Connect with: nc localhost 8080")
+	log.Printf("🚀 TCP Echo Server listening on %s", *addr)
+	log.Printf("   Connect with: nc localhost %d", listener.Addr().(*net.TCPAddr).Port)
 	log.Println("   Press Ctrl+C to shutdown")
 
 	// Track active connections for graceful shutdown
@@ -87,7 +90,7 @@ func handleConnection(ctx context.Context, conn net.Conn) {
 	log.Printf("📥 Client connected: %s", clientAddr)
 
 	// Send welcome message
-	fmt.Fprintf(conn, "Welcome to TCP Echo Server!\n")
+	fmt.Fprintf(conn, "Welcome to TCP Echo Server on %s!\n", conn.LocalAddr())
 	fmt.Fprintf(conn, "Type messages and I'll echo them back.\n")
 	fmt.Fprintf(conn, "Type 'quit' to disconnect.\n\n")
 
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/channyeintun/network-exercises/pkg/scanner"
+)
+
+// checkpointInterval is how often progress is flushed to the state file
+const checkpointInterval = 5 * time.Second
+
+// Checkpoint records which host/port pairs have been scanned so an
+// interrupted run can pick up where it stopped
+type Checkpoint struct {
+	Targets   string              `json:"targets"`
+	StartPort int                 `json:"start_port"`
+	EndPort   int                 `json:"end_port"`
+	Ports     []int               `json:"ports,omitempty"`   // -top-ports list; empty means the whole range
+	Exclude   []string            `json:"exclude,omitempty"` // -exclude entries, re-applied on resume
+	Started   time.Time           `json:"started"`
+	Done      map[string][][2]int `json:"done"` // host -> completed port ranges
+	Results   []scanner.Result    `json:"results"`
+	All       bool                `json:"all,omitempty"`    // -all: closed and filtered ports are kept too
+	Others    []scanner.Result    `json:"others,omitempty"` // those closed and filtered ports
+	mu        sync.Mutex          `json:"-"`
+	done      map[string][]bool   `json:"-"`
+	completed int                 `json:"-"`
+}
+
+func newCheckpoint(targets string, startPort, endPort int) *Checkpoint {
+	return &Checkpoint{
+		Targets:   targets,
+		StartPort: startPort,
+		EndPort:   endPort,
+		Started:   time.Now(),
+		done:      make(map[string][]bool),
+	}
+}
+
+func loadCheckpoint(path string) (*Checkpoint, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	cp := &Checkpoint{}
+	if err := json.Unmarshal(data, cp); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+
+	// Rebuild the in-memory port bitmap from the compact ranges
+	cp.done = make(map[string][]bool)
+	for host, ranges := range cp.Done {
+		bits := cp.portBits(host)
+		for _, r := range ranges {
+			for port := r[0]; port <= r[1]; port++ {
+				bits[port-cp.StartPort] = true
+				cp.completed++
+			}
+		}
+	}
+
+	return cp, nil
+}
+
+// portBits returns the done-bitmap for host, creating it on first use.
+// Callers must hold c.mu or own c exclusively.
+func (c *Checkpoint) portBits(host string) []bool {
+	bits, ok := c.done[host]
+	if !ok {
+		bits = make([]bool, c.EndPort-c.StartPort+1)
+		c.done[host] = bits
+	}
+	return bits
+}
+
+// IsDone reports whether host:port was already scanned
+func (c *Checkpoint) IsDone(host string, port int) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.portBits(host)[port-c.StartPort]
+}
+
+// MarkDone records a finished probe, keeping the result if the port is
+// open, or with All whatever its state
+func (c *Checkpoint) MarkDone(r scanner.Result) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	bits := c.portBits(r.Host)
+	if !bits[r.Port-c.StartPort] {
+		bits[r.Port-c.StartPort] = true
+		c.completed++
+	}
+	switch {
+	case r.State == scanner.StateOpen:
+		c.Results = append(c.Results, r)
+	case c.All:
+		c.Others = append(c.Others, r)
+	}
+}
+
+// Completed returns the number of host/port pairs already scanned
+func (c *Checkpoint) Completed() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.completed
+}
+
+// OpenCount returns the number of open ports found so far
+func (c *Checkpoint) OpenCount() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.Results)
+}
+
+// OpenResults returns a copy of the open ports found so far
+func (c *Checkpoint) OpenResults() []scanner.Result {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]scanner.Result(nil), c.Results...)
+}
+
+// OtherResults returns a copy of the closed and filtered ports found so
+// far, if All is set
+func (c *Checkpoint) OtherResults() []scanner.Result {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]scanner.Result(nil), c.Others...)
+}
+
+// Save atomically writes the checkpoint to path
+func (c *Checkpoint) Save(path string) error {
+	c.mu.Lock()
+	c.Done = make(map[string][][2]int, len(c.done))
+	for host, bits := range c.done {
+		c.Done[host] = compressRanges(bits, c.StartPort)
+	}
+	data, err := json.MarshalIndent(c, "", "  ")
+	c.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	// Write to a temp file and rename so a crash never leaves a torn file
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// compressRanges turns a bitmap into [start, end] port ranges
+func compressRanges(bits []bool, offset int) [][2]int {
+	var ranges [][2]int
+	start := -1
+	for i, b := range bits {
+		switch {
+		case b && start < 0:
+			start = i
+		case !b && start >= 0:
+			ranges = append(ranges, [2]int{start + offset, i - 1 + offset})
+			start = -1
+		}
+	}
+	if start >= 0 {
+		ranges = append(ranges, [2]int{start + offset, len(bits) - 1 + offset})
+	}
+	return ranges
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// daemonOptions configures continuous monitoring mode
+type daemonOptions struct {
+	Schedule     time.Duration // time between the start of consecutive scans
+	AlertWebhook string        // optional URL that receives a JSON POST on changes
+	OutputFormat string
+	OutputFile   string // rewritten after every scan if set
+}
+
+// runDaemon re-runs the scan on a schedule until ctx is cancelled,
+// storing every run and reporting what changed since the previous one
+func runDaemon(ctx context.Context, store *Store, opts scanOptions, d daemonOptions) {
+	log.Printf("👁  Daemon mode: scanning %s every %v", opts.Targets, d.Schedule)
+
+	ticker := time.NewTicker(d.Schedule)
+	defer ticker.Stop()
+
+	for {
+		daemonPass(ctx, store, opts, d)
+		if ctx.Err() != nil {
+			return
+		}
+
+		log.Printf("⏰ Next scan at %s", time.Now().Add(d.Schedule).Format(time.TimeOnly))
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// daemonPass runs one scheduled scan; failures are logged, not fatal,
+// so a transient network problem doesn't stop the monitor
+func daemonPass(ctx context.Context, store *Store, opts scanOptions, d daemonOptions) {
+	cp := newCheckpoint(opts.Targets, opts.StartPort, opts.EndPort)
+	cp.Ports = opts.Ports
+	cp.Exclude = opts.Exclude.Entries()
+	cp.All = opts.All
+	run, err := runScan(ctx, opts, cp)
+	if ctx.Err() != nil {
+		return
+	}
+	if err != nil {
+		failuresTotal.WithLabelValues("scan").Inc()
+		log.Printf("❌ Scan failed: %v", err)
+		return
+	}
+	log.Printf("✅ Scan done in %v: %d open ports", run.Summary.Elapsed.Round(time.Millisecond), len(run.Results))
+
+	if d.OutputFile != "" {
+		if err := writeOutputFile(d.OutputFile, d.OutputFormat, run); err != nil {
+			failuresTotal.WithLabelValues("output").Inc()
+			log.Printf("❌ Failed to write %s: %v", d.OutputFile, err)
+		}
+	}
+
+	scanID, err := store.SaveScan(run.Summary, run.Results)
+	if err != nil {
+		failuresTotal.WithLabelValues("store").Inc()
+		log.Printf("❌ Failed to save scan: %v", err)
+		return
+	}
+
+	diff, err := store.Diff(scanID)
+	if err != nil {
+		failuresTotal.WithLabelValues("store").Inc()
+		log.Printf("❌ Failed to diff scan: %v", err)
+		return
+	}
+	printDiff(diff)
+	observeDiff(diff)
+
+	if diff != nil && (len(diff.Opened) > 0 || len(diff.Closed) > 0) && d.AlertWebhook != "" {
+		if err := sendAlert(ctx, d.AlertWebhook, opts.Targets, diff); err != nil {
+			failuresTotal.WithLabelValues("alert").Inc()
+			log.Printf("❌ Alert failed: %v", err)
+		}
+	}
+}
+
+// alertPayload is POSTed to the webhook. The "text" field makes it
+// render directly in Slack-compatible incoming webhooks.
+type alertPayload struct {
+	Text       string         `json:"text"`
+	Targets    string         `json:"targets"`
+	ScanID     int64          `json:"scan_id"`
+	PreviousID int64          `json:"previous_scan_id"`
+	Opened     []resultRecord `json:"opened"`
+	Closed     []resultRecord `json:"closed"`
+}
+
+func sendAlert(ctx context.Context, url, targets string, d *ScanDiff) error {
+	payload := alertPayload{
+		Targets:    targets,
+		ScanID:     d.CurrentID,
+		PreviousID: d.PreviousID,
+		Opened:     []resultRecord{},
+		Closed:     []resultRecord{},
+	}
+
+	var lines []string
+	for _, r := range d.Opened {
+		payload.Opened = append(payload.Opened, toRecord(r))
+		lines = append(lines, fmt.Sprintf("+ %s:%d opened (%s)", r.Host, r.Port, r.Service))
+	}
+	for _, r := range d.Closed {
+		payload.Closed = append(payload.Closed, toRecord(r))
+		lines = append(lines, fmt.Sprintf("- %s:%d closed (%s)", r.Host, r.Port, r.Service))
+	}
+	payload.Text = fmt.Sprintf("Port changes on %s:\n%s", targets, strings.Join(lines, "\n"))
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/channyeintun/network-exercises/pkg/ping"
+	"github.com/channyeintun/network-exercises/pkg/scanner"
+)
+
+// discoveryPorts are probed by TCP discovery. Any answer, even a RST,
+// proves the host is up.
+var discoveryPorts = []int{80, 443, 22, 445, 3389}
+
+// discoverHosts returns the subset of hosts that answer the chosen probe
+func discoverHosts(ctx context.Context, dialer scanner.Dialer, method, network string, hosts []string, timeout time.Duration, workers int) ([]string, error) {
+	switch method {
+	case "none":
+		return hosts, nil
+	case "tcp":
+		return discoverTCP(ctx, dialer, network, hosts, timeout, workers), nil
+	case "icmp":
+		return discoverICMP(ctx, network, hosts, timeout, workers)
+	case "arp":
+		return discoverARP(ctx, hosts, timeout)
+	default:
+		return nil, fmt.Errorf("unknown discovery method %q (want none, icmp, tcp or arp)", method)
+	}
+}
+
+// discoverTCP marks a host up if any discovery port connects or refuses
+func discoverTCP(ctx context.Context, dialer scanner.Dialer, network string, hosts []string, timeout time.Duration, workers int) []string {
+	alive := make([]bool, len(hosts))
+	sem := make(chan struct{}, workers)
+	var wg sync.WaitGroup
+
+	for i, host := range hosts {
+		if ctx.Err() != nil {
+			break
+		}
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, host string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			for _, port := range discoveryPorts {
+				r := scanner.Probe(ctx, dialer, network, host, port, timeout)
+				if r.State == scanner.StateOpen || r.State == scanner.StateClosed {
+					alive[i] = true
+					return
+				}
+			}
+		}(i, host)
+	}
+	wg.Wait()
+
+	return pickAlive(hosts, alive)
+}
+
+// discoverICMP pings each host once and marks those that answer up. The
+// pings share one ICMPv4 and one ICMPv6 socket from pkg/ping: raw as
+// root, or ICMP datagram sockets where net.ipv4.ping_group_range allows.
+func discoverICMP(ctx context.Context, network string, hosts []string, timeout time.Duration, workers int) ([]string, error) {
+	// Resolve over the scan's family: "ip", or "ip4"/"ip6" with -4/-6
+	lookup := "ip" + strings.TrimPrefix(network, "tcp")
+	dsts := make([]*net.IPAddr, len(hosts))
+	conns := make(map[string]*ping.Conn)
+	for i, host := range hosts {
+		ips, err := net.DefaultResolver.LookupIP(ctx, lookup, host)
+		if err != nil || len(ips) == 0 {
+			continue
+		}
+		dsts[i] = &net.IPAddr{IP: ips[0]}
+
+		family := ipFamily(ips[0])
+		if conns[family] != nil {
+			continue
+		}
+		conn, err := ping.Listen(family, "")
+		if err != nil {
+			return nil, fmt.Errorf("ICMP discovery over %s needs root or ping_group_range: %w", family, err)
+		}
+		defer conn.Close()
+		conns[family] = conn
+	}
+
+	alive := make([]bool, len(hosts))
+	sem := make(chan struct{}, workers)
+	var wg sync.WaitGroup
+	for i, dst := range dsts {
+		if dst == nil {
+			continue
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, dst *net.IPAddr) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			conn := conns[ipFamily(dst.IP)]
+			_, _, err := conn.Ping(ctx, dst, i&0xffff, ping.DefaultPayload, timeout)
+			alive[i] = err == nil
+		}(i, dst)
+	}
+	wg.Wait()
+
+	return pickAlive(hosts, alive), nil
+}
+
+// ipFamily returns pkg/ping's network for ip, "ip4" or "ip6"
+func ipFamily(ip net.IP) string {
+	if ip.To4() != nil {
+		return "ip4"
+	}
+	return "ip6"
+}
+
+// discoverARP finds hosts on the local segment. Sending any packet to an
+// on-link address makes the kernel ARP for it; complete entries in the
+// neighbour table are hosts that answered. Linux only, no root needed.
+func discoverARP(ctx context.Context, hosts []string, timeout time.Duration) ([]string, error) {
+	if runtime.GOOS != "linux" {
+		return nil, fmt.Errorf("ARP discovery reads /proc/net/arp and only works on Linux")
+	}
+
+	for _, host := range hosts {
+		if ctx.Err() != nil {
+			break
+		}
+		ip := net.ParseIP(host)
+		if ip == nil || ip.To4() == nil {
+			continue
+		}
+		// UDP port 9 is "discard"; we only care about the ARP it triggers
+		if conn, err := net.Dial("udp4", net.JoinHostPort(host, "9")); err == nil {
+			conn.Write([]byte{0})
+			conn.Close()
+		}
+	}
+
+	select {
+	case <-time.After(timeout):
+	case <-ctx.Done():
+	}
+
+	table, err := readARPTable()
+	if err != nil {
+		return nil, err
+	}
+
+	alive := make([]bool, len(hosts))
+	for i, host := range hosts {
+		alive[i] = table[host]
+	}
+	return pickAlive(hosts, alive), nil
+}
+
+// readARPTable returns IPs with a complete entry in /proc/net/arp
+func readARPTable() (map[string]bool, error) {
+	f, err := os.Open("/proc/net/arp")
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	// IP address  HW type  Flags  HW address  Mask  Device
+	entries := make(map[string]bool)
+	scanner := bufio.NewScanner(f)
+	scanner.Scan() // skip header
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 4 {
+			continue
+		}
+		flags, err := strconv.ParseInt(fields[2], 0, 64)
+		if err != nil {
+			continue
+		}
+		const atfCom = 0x2 // ATF_COM: entry is complete
+		if flags&atfCom != 0 {
+			entries[fields[0]] = true
+		}
+	}
+	return entries, scanner.Err()
+}
+
+func pickAlive(hosts []string, alive []bool) []string {
+	var up []string
+	for i, host := range hosts {
+		if alive[i] {
+			up = append(up, host)
+		}
+	}
+	return up
+}
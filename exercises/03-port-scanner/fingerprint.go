@@ -0,0 +1,236 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/channyeintun/network-exercises/pkg/scanner"
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+)
+
+// osFingerprint is what we could observe of a host's TCP/IP stack, plus
+// a best guess at the OS family. Like nmap's, the guess leans on the
+// fact that stacks ship different defaults: the initial TTL, the SYN-ACK
+// window and the TCP options they offer.
+type osFingerprint struct {
+	TTL        int // TTL of an ICMP echo reply, 0 if unknown
+	Window     int // window advertised in the SYN-ACK, 0 if unknown
+	WScale     int // window scale option, -1 if not offered
+	MSS        int
+	SACK       bool
+	Timestamps bool
+
+	Family   string // e.g. "Linux", "" if we can't tell
+	Accuracy int    // rough confidence in percent
+}
+
+// String summarises the fingerprint for the results table
+func (f osFingerprint) String() string {
+	var parts []string
+	if f.TTL > 0 {
+		parts = append(parts, fmt.Sprintf("ttl %d", f.TTL))
+	}
+	if f.Window > 0 {
+		parts = append(parts, fmt.Sprintf("win %d", f.Window))
+	}
+	if f.WScale >= 0 {
+		parts = append(parts, fmt.Sprintf("wscale %d", f.WScale))
+	}
+	if f.MSS > 0 {
+		parts = append(parts, fmt.Sprintf("mss %d", f.MSS))
+	}
+
+	family := f.Family
+	if family == "" {
+		family = "unknown"
+	}
+	return fmt.Sprintf("%s (%d%%; %s)", family, f.Accuracy, strings.Join(parts, ", "))
+}
+
+// fingerprintHosts fingerprints every host with at least one open port,
+// connecting to the first open port found on each
+func fingerprintHosts(ctx context.Context, network string, results []scanner.Result, timeout time.Duration) map[string]osFingerprint {
+	firstOpen := make(map[string]int)
+	for _, r := range results {
+		if _, ok := firstOpen[r.Host]; !ok {
+			firstOpen[r.Host] = r.Port
+		}
+	}
+
+	fps := make(map[string]osFingerprint)
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, 16)
+
+	for host, port := range firstOpen {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			fp := fingerprintHost(ctx, network, host, port, timeout)
+			mu.Lock()
+			fps[host] = fp
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	return fps
+}
+
+func fingerprintHost(ctx context.Context, network, host string, port int, timeout time.Duration) osFingerprint {
+	fp := osFingerprint{WScale: -1}
+
+	// Both probes are best effort; whatever we learn feeds the guess
+	if sig, err := synAckSignature(ctx, network, host, port, timeout); err == nil {
+		fp = sig
+	}
+	fp.TTL = echoTTL(host, timeout)
+
+	fp.Family, fp.Accuracy = guessOS(fp)
+	return fp
+}
+
+// initialTTL rounds an observed TTL up to the default it most likely
+// started from. Hosts are rarely more than 30 hops away.
+func initialTTL(ttl int) int {
+	switch {
+	case ttl <= 0:
+		return 0
+	case ttl <= 32:
+		return 32
+	case ttl <= 64:
+		return 64
+	case ttl <= 128:
+		return 128
+	default:
+		return 255
+	}
+}
+
+// guessOS maps a fingerprint to an OS family and a rough confidence.
+// These are classroom heuristics, not nmap's database: firewalls,
+// load balancers and tuned sysctls all blur the picture.
+func guessOS(fp osFingerprint) (string, int) {
+	switch initialTTL(fp.TTL) {
+	case 128:
+		if fp.WScale == 8 {
+			return "Windows", 85
+		}
+		return "Windows", 70
+	case 255:
+		return "Network device or Solaris", 60
+	case 32:
+		return "Old Windows or embedded", 40
+	case 64:
+		switch {
+		case fp.WScale == 6 && fp.Window == 65535:
+			return "macOS or FreeBSD", 75
+		case fp.WScale >= 7:
+			// Linux scales to fit tcp_rmem, 7 with default sysctls
+			return "Linux", 85
+		default:
+			return "Linux or other Unix", 60
+		}
+	}
+
+	// No TTL, so go on TCP options alone
+	switch {
+	case fp.WScale == 8:
+		return "Windows", 40
+	case fp.WScale >= 7:
+		return "Linux", 50
+	case fp.WScale == 6:
+		return "macOS or FreeBSD", 40
+	}
+	return "", 0
+}
+
+// protocolICMP is the IANA protocol number used to parse ICMPv4 replies
+const protocolICMP = 1
+
+// echoTTL pings host once and returns the TTL of the reply, or 0 if it
+// didn't answer or we can't open an ICMP socket. Like discovery it uses
+// a raw socket as root and an ICMP datagram socket otherwise.
+func echoTTL(host string, timeout time.Duration) int {
+	ip := net.ParseIP(host)
+	if ip == nil {
+		addrs, err := net.LookupIP(host)
+		if err != nil || len(addrs) == 0 {
+			return 0
+		}
+		ip = addrs[0]
+	}
+	if ip.To4() == nil {
+		return 0 // only ICMPv4 here; v6 reports a hop limit instead
+	}
+
+	privileged := true
+	conn, err := icmp.ListenPacket("ip4:icmp", "0.0.0.0")
+	if err != nil {
+		privileged = false
+		conn, err = icmp.ListenPacket("udp4", "0.0.0.0")
+		if err != nil {
+			return 0
+		}
+	}
+	defer conn.Close()
+
+	pc := conn.IPv4PacketConn()
+	if err := pc.SetControlMessage(ipv4.FlagTTL, true); err != nil {
+		return 0
+	}
+
+	id := os.Getpid() & 0xffff
+	msg := icmp.Message{
+		Type: ipv4.ICMPTypeEcho,
+		Body: &icmp.Echo{ID: id, Seq: 1, Data: []byte("fingerprint")},
+	}
+	b, err := msg.Marshal(nil)
+	if err != nil {
+		return 0
+	}
+
+	var dst net.Addr = &net.IPAddr{IP: ip}
+	if !privileged {
+		dst = &net.UDPAddr{IP: ip}
+	}
+	if _, err := pc.WriteTo(b, nil, dst); err != nil {
+		return 0
+	}
+
+	pc.SetReadDeadline(time.Now().Add(timeout))
+	buf := make([]byte, 1500)
+	for {
+		n, cm, peer, err := pc.ReadFrom(buf)
+		if err != nil {
+			return 0
+		}
+		if peerIP(peer) != ip.String() || cm == nil {
+			continue
+		}
+		reply, err := icmp.ParseMessage(protocolICMP, buf[:n])
+		if err != nil || reply.Type != ipv4.ICMPTypeEchoReply {
+			continue
+		}
+		return cm.TTL
+	}
+}
+
+func peerIP(addr net.Addr) string {
+	switch a := addr.(type) {
+	case *net.IPAddr:
+		return a.IP.String()
+	case *net.UDPAddr:
+		return a.IP.String()
+	}
+	return ""
+}
@@ -0,0 +1,65 @@
+//go:build linux
+
+package main
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// synAckSignature connects to host:port and reads what the kernel
+// recorded about the peer's SYN-ACK from TCP_INFO, so no raw socket is
+// needed. The window is only reported by Linux 6.2 and later.
+func synAckSignature(ctx context.Context, network, host string, port int, timeout time.Duration) (osFingerprint, error) {
+	fp := osFingerprint{WScale: -1}
+
+	d := net.Dialer{Timeout: timeout}
+	conn, err := d.DialContext(ctx, network, net.JoinHostPort(host, strconv.Itoa(port)))
+	if err != nil {
+		return fp, err
+	}
+	defer conn.Close()
+
+	raw, err := conn.(*net.TCPConn).SyscallConn()
+	if err != nil {
+		return fp, err
+	}
+
+	var info *unix.TCPInfo
+	var sockErr error
+	err = raw.Control(func(fd uintptr) {
+		info, sockErr = unix.GetsockoptTCPInfo(int(fd), unix.IPPROTO_TCP, unix.TCP_INFO)
+	})
+	if err != nil {
+		return fp, err
+	}
+	if sockErr != nil {
+		return fp, sockErr
+	}
+
+	// tcpi_options flags from linux/tcp.h
+	const (
+		optTimestamps = 1
+		optSACK       = 2
+		optWScale     = 4
+	)
+
+	// Nothing has been sent yet, so snd_wnd is still the SYN-ACK window
+	fp.Window = int(info.Snd_wnd)
+	fp.MSS = int(info.Snd_mss)
+	fp.SACK = info.Options&optSACK != 0
+	fp.Timestamps = info.Options&optTimestamps != 0
+	if info.Options&optWScale != 0 {
+		// unix.TCPInfo has no field for the bitfield byte holding
+		// tcpi_snd_wscale:4 and tcpi_rcv_wscale:4, but the kernel fills
+		// it in; it is the padding byte right after Options
+		wscales := (*[8]byte)(unsafe.Pointer(info))[6]
+		fp.WScale = int(wscales & 0x0f)
+	}
+	return fp, nil
+}
@@ -0,0 +1,14 @@
+//go:build !linux
+
+package main
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// synAckSignature needs Linux's TCP_INFO; elsewhere only the TTL is used
+func synAckSignature(ctx context.Context, network, host string, port int, timeout time.Duration) (osFingerprint, error) {
+	return osFingerprint{WScale: -1}, errors.New("TCP fingerprinting is only supported on Linux")
+}
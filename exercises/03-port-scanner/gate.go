@@ -0,0 +1,101 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/channyeintun/network-exercises/pkg/scanner"
+)
+
+// Exit codes, so scripts and CI jobs can tell outcomes apart. 2 is what
+// the flag package uses for bad arguments.
+const (
+	exitOK             = 0
+	exitError          = 1 // scan failed or was interrupted
+	exitUnexpectedOpen = 3 // -fail-on-open found ports outside -allow-open
+	exitChanged        = 4 // -fail-on-diff saw ports open or close since the last run
+)
+
+// allowList holds ports that are expected to be open, either on any
+// host ("443") or on one host ("10.0.0.5:22")
+type allowList struct {
+	ports     map[int]bool
+	hostPorts map[portKey]bool
+}
+
+func parseAllowList(spec string) (*allowList, error) {
+	al := &allowList{ports: make(map[int]bool), hostPorts: make(map[portKey]bool)}
+
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		if port, err := strconv.Atoi(entry); err == nil {
+			if port < 1 || port > 65535 {
+				return nil, fmt.Errorf("port %d out of range", port)
+			}
+			al.ports[port] = true
+			continue
+		}
+
+		host, portStr, err := net.SplitHostPort(entry)
+		if err != nil {
+			return nil, fmt.Errorf("%q is neither a port nor host:port", entry)
+		}
+		port, err := strconv.Atoi(portStr)
+		if err != nil || port < 1 || port > 65535 {
+			return nil, fmt.Errorf("invalid port in %q", entry)
+		}
+		al.hostPorts[portKey{host: host, port: port}] = true
+	}
+
+	return al, nil
+}
+
+func (al *allowList) allows(r scanner.Result) bool {
+	return al.ports[r.Port] || al.hostPorts[portKey{host: r.Host, port: r.Port}]
+}
+
+// unexpectedOpen returns the open ports that al does not allow
+func unexpectedOpen(results []scanner.Result, al *allowList) []scanner.Result {
+	var bad []scanner.Result
+	for _, r := range results {
+		if r.State == scanner.StateOpen && !al.allows(r) {
+			bad = append(bad, r)
+		}
+	}
+	return bad
+}
+
+// logHostSummary prints one line per scanned host with its open ports.
+// Ports al doesn't allow are marked with "!"; al may be nil.
+func logHostSummary(results []scanner.Result, summary ScanSummary, al *allowList) {
+	open := make(map[string][]string)
+	for _, r := range results {
+		port := strconv.Itoa(r.Port)
+		if al != nil && !al.allows(r) {
+			port += "!"
+		}
+		open[r.Host] = append(open[r.Host], port)
+	}
+
+	width := 0
+	for _, host := range summary.Hosts {
+		width = max(width, len(host))
+	}
+
+	log.Println("📋 Host summary:")
+	for _, host := range summary.Hosts {
+		ports := open[host]
+		if len(ports) == 0 {
+			log.Printf("   %-*s  no open ports", width, host)
+			continue
+		}
+		log.Printf("   %-*s  %d open: %s", width, host, len(ports), strings.Join(ports, ","))
+	}
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/reflection"
+
+	"github.com/channyeintun/network-exercises/pkg/scanner"
+	"github.com/channyeintun/network-exercises/pkg/scanrpc"
+	"github.com/channyeintun/network-exercises/pkg/scanrpc/scannerpb"
+)
+
+// serveGRPC runs the remote-control API on addr until ctx is cancelled.
+// Reflection is enabled so grpcurl works without the .proto file.
+func serveGRPC(ctx context.Context, addr string, dialer scanner.Dialer) error {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+
+	server := grpc.NewServer()
+	scannerpb.RegisterScannerServer(server, scanrpc.NewServer(dialer))
+	reflection.Register(server)
+
+	go func() {
+		<-ctx.Done()
+		// Open result streams would hold GracefulStop forever
+		timer := time.AfterFunc(5*time.Second, server.Stop)
+		defer timer.Stop()
+		server.GracefulStop()
+	}()
+
+	log.Printf("🛰  gRPC API on %s", ln.Addr())
+	return server.Serve(ln)
+}
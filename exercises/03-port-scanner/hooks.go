@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/channyeintun/network-exercises/pkg/scanner"
+)
+
+// execHook runs an external command for every open port. The command
+// is split into arguments up front and placeholders are substituted per
+// argument, so no shell is involved and a hostile hostname can't inject
+// extra commands. Use sh -c '...' explicitly if you need pipes.
+type execHook struct {
+	argv    []string
+	timeout time.Duration
+	sem     chan struct{}
+	wg      sync.WaitGroup
+}
+
+func newExecHook(command string, workers int, timeout time.Duration) (*execHook, error) {
+	argv, err := splitCommand(command)
+	if err != nil {
+		return nil, err
+	}
+	if len(argv) == 0 {
+		return nil, fmt.Errorf("empty -exec command")
+	}
+
+	return &execHook{
+		argv:    argv,
+		timeout: timeout,
+		sem:     make(chan struct{}, workers),
+	}, nil
+}
+
+// Run starts the command for r in the background
+func (h *execHook) Run(r scanner.Result) {
+	replacer := strings.NewReplacer(
+		"{host}", r.Host,
+		"{port}", strconv.Itoa(r.Port),
+		"{service}", strings.ToLower(r.Service),
+	)
+	args := make([]string, len(h.argv))
+	for i, a := range h.argv {
+		args[i] = replacer.Replace(a)
+	}
+
+	h.wg.Add(1)
+	go func() {
+		defer h.wg.Done()
+		h.sem <- struct{}{}
+		defer func() { <-h.sem }()
+
+		ctx, cancel := context.WithTimeout(context.Background(), h.timeout)
+		defer cancel()
+
+		cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+		// Keep stdout clean for -output json/csv; hook output goes to stderr
+		cmd.Stdout = os.Stderr
+		cmd.Stderr = os.Stderr
+		cmd.Env = append(os.Environ(),
+			"SCAN_HOST="+r.Host,
+			"SCAN_PORT="+strconv.Itoa(r.Port),
+			"SCAN_SERVICE="+r.Service,
+		)
+
+		if err := cmd.Run(); err != nil {
+			log.Printf("⚠️  -exec for %s:%d failed: %v", r.Host, r.Port, err)
+		}
+	}()
+}
+
+// Wait blocks until all started commands have finished
+func (h *execHook) Wait() {
+	h.wg.Wait()
+}
+
+// splitCommand splits a command line on spaces, honouring single and
+// double quotes so arguments like 'curl -H "Host: x" {host}' survive
+func splitCommand(s string) ([]string, error) {
+	var args []string
+	var cur strings.Builder
+	var quote rune
+	inArg := false
+
+	for _, c := range s {
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			} else {
+				cur.WriteRune(c)
+			}
+		case c == '\'' || c == '"':
+			quote = c
+			inArg = true
+		case c == ' ' || c == '\t':
+			if inArg {
+				args = append(args, cur.String())
+				cur.Reset()
+				inArg = false
+			}
+		default:
+			cur.WriteRune(c)
+			inArg = true
+		}
+	}
+
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated %c quote in -exec command", quote)
+	}
+	if inArg {
+		args = append(args, cur.String())
+	}
+	return args, nil
+}
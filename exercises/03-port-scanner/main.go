@@ -7,152 +7,394 @@
 // - Aggregate results across goroutines
 //
 // Run: go run main.go -host scanme.nmap.org -start 1 -end 100
+// Or:  go run main.go -host localhost -output json -o results.json
+// Or:  go run main.go -host localhost -output nmap-xml -o scan.xml
+// Or:  go run main.go -host localhost -db scans.db -diff
+// Or:  go run main.go -host example.com -fail-on-open -allow-open 80,443   (exit 3 if more is open)
+// Or:  go run main.go -host 192.168.1.0/24 -state scan.json   (Ctrl+C, then -resume scan.json)
+// Or:  go run main.go -host localhost -output csv -quiet       (no progress line)
+// Or:  go run main.go -host localhost -start 20 -end 25 -all   (closed and filtered ports too)
+// Or:  go run main.go -host 192.168.1.0/24 -rdns
+// Or:  go run main.go -host 10.0.0.0/24 -top-ports 100 -services-file /usr/share/nmap/nmap-services
+// Or:  go run main.go -host 10.0.0.0/24 -exclude 10.0.0.1,10.0.0.128/28
+// Or:  sudo go run main.go -host 192.168.1.0/24 -os            (OS guess; root improves TTL)
+// Or:  go run main.go -host ::1 -6                             (IPv6)
+// Or:  sudo go run main.go -host 10.0.0.0/24 -discover icmp
+// Or:  go run main.go -host 10.0.0.5 -proxy socks5://bastion:1080
+// Or:  go run main.go -host localhost -exec 'curl -sI http://{host}:{port}/'
+// Or:  go run main.go -host 10.0.0.0/24 -db scans.db -daemon -schedule 1h
+// Or:  go run main.go -db scans.db -ui :8080                  (browse stored scans)
+// Or:  go run main.go -host 10.0.0.0/24 -db scans.db -daemon -metrics :9100
+// Or:  go run main.go -grpc :50051                            (remote control, see pkg/scanrpc)
 package main
 
 import (
+	"context"
 	"flag"
-	"fmt"
 	"log"
-	"net"
-	"sort"
-	"sync"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"syscall"
 	"time"
-)
 
-// ScanResult holds the result of scanning a port
-type ScanResult struct {
-	Port   int
-	Open   bool
-	Banner string
-}
+	"github.com/channyeintun/network-exercises/pkg/scanner"
+)
 
 func main() {
 	// Parse command line flags
-	host := flag.String("host", "localhost", "Target host to scan")
+	host := flag.String("host", "localhost", "Target hosts to scan (comma-separated hosts or CIDRs)")
+	exclude := flag.String("exclude", "", "Hosts or CIDRs never to scan, e.g. 10.0.0.5,10.0.1.0/28")
+	excludeFile := flag.String("exclude-file", "", "File of hosts or CIDRs never to scan, one per line")
 	startPort := flag.Int("start", 1, "Start port")
 	endPort := flag.Int("end", 1024, "End port")
+	topPorts := flag.Int("top-ports", 0, "Scan the N most frequently open ports instead of -start/-end")
+	servicesFile := flag.String("services-file", "", "nmap-services style file for service names and -top-ports ranking")
 	timeout := flag.Duration("timeout", 500*time.Millisecond, "Connection timeout")
+	bannerTimeout := flag.Duration("banner-timeout", scanner.DefaultBannerTimeout, "How long an open port gets to send a banner (0 to skip)")
 	workers := flag.Int("workers", 100, "Number of concurrent workers")
+	outputFormat := flag.String("output", "table", "Output format: table, json, csv, nmap-xml")
+	outputFile := flag.String("o", "", "Write results to file instead of stdout")
+	showAll := flag.Bool("all", false, "Report closed and filtered ports too, with their state, not just open ones")
+	dbPath := flag.String("db", "", "SQLite database to persist scan runs (optional)")
+	diff := flag.Bool("diff", false, "Report ports opened/closed since the previous run (requires -db)")
+	failOnOpen := flag.Bool("fail-on-open", false, "Exit 3 if any open port is not listed in -allow-open")
+	allowOpen := flag.String("allow-open", "", "Ports expected to be open for -fail-on-open, e.g. 443 or 10.0.0.5:22")
+	failOnDiff := flag.Bool("fail-on-diff", false, "Exit 4 if ports opened or closed since the previous run (requires -db)")
+	statePath := flag.String("state", "", "Periodically checkpoint progress to this file (optional)")
+	resumePath := flag.String("resume", "", "Resume an interrupted scan from a checkpoint file")
+	quiet := flag.Bool("quiet", false, "Suppress the live progress line")
+	rdns := flag.Bool("rdns", false, "Resolve PTR records for hosts with open ports")
+	rdnsWorkers := flag.Int("rdns-workers", 16, "Maximum concurrent reverse DNS lookups")
+	osDetect := flag.Bool("os", false, "Guess each host's OS from its TTL and SYN-ACK (window, options)")
+	ipv4Only := flag.Bool("4", false, "Use IPv4 only (resolve A records)")
+	ipv6Only := flag.Bool("6", false, "Use IPv6 only (resolve AAAA records)")
+	discover := flag.String("discover", "none", "Find live hosts first, when scanning several, and only port-scan those: none, icmp, tcp, arp")
+	skipDiscovery := flag.Bool("skip-discovery", false, "Port-scan every target without checking it is up")
+	proxyURL := flag.String("proxy", "", "Tunnel connect scans through a proxy, e.g. socks5://bastion:1080")
+	execCmd := flag.String("exec", "", "Command to run per open port; {host}, {port} and {service} are substituted")
+	execWorkers := flag.Int("exec-workers", 4, "Maximum concurrent -exec commands")
+	execTimeout := flag.Duration("exec-timeout", 30*time.Second, "Kill an -exec command after this long")
+	daemon := flag.Bool("daemon", false, "Keep running and re-scan on a schedule (requires -db)")
+	schedule := flag.Duration("schedule", time.Hour, "Time between scans in -daemon mode")
+	uiAddr := flag.String("ui", "", "Serve a web UI over the -db store on this address, e.g. :8080")
+	grpcAddr := flag.String("grpc", "", "Serve the gRPC remote-control API on this address, e.g. :50051")
+	metricsAddr := flag.String("metrics", "", "Expose Prometheus metrics on this address, e.g. :9100")
+	alertWebhook := flag.String("alert-webhook", "", "POST a JSON alert here when -daemon sees ports change")
 	flag.Parse()
 
-	log.Printf("🔍 Scanning %s ports %d-%d", *host, *startPort, *endPort)
-	log.Printf("   Timeout: %v, Workers: %d", *timeout, *workers)
+	if !validFormat(*outputFormat) {
+		log.Fatalf("Unknown output format %q (want table, json, csv or nmap-xml)", *outputFormat)
+	}
+	if (*diff || *failOnDiff) && *dbPath == "" {
+		log.Fatal("-diff and -fail-on-diff require -db")
+	}
+	if *allowOpen != "" && !*failOnOpen {
+		log.Fatal("-allow-open only applies with -fail-on-open")
+	}
+	if *daemon && (*failOnOpen || *failOnDiff) {
+		log.Fatal("-fail-on-open and -fail-on-diff set the exit code of a single scan; use -alert-webhook with -daemon")
+	}
+	if *daemon && *dbPath == "" {
+		log.Fatal("-daemon requires -db to compare runs")
+	}
+	if *uiAddr != "" && *dbPath == "" {
+		log.Fatal("-ui requires -db")
+	}
+	if *daemon && *resumePath != "" {
+		log.Fatal("-daemon and -resume are mutually exclusive")
+	}
+	if *schedule <= 0 {
+		log.Fatal("-schedule must be positive")
+	}
+	if *topPorts < 0 {
+		log.Fatal("-top-ports must be positive")
+	}
+	if *topPorts > 0 && *resumePath != "" {
+		log.Fatal("-top-ports and -resume are mutually exclusive; the checkpoint has the ports")
+	}
+	if *ipv4Only && *ipv6Only {
+		log.Fatal("-4 and -6 are mutually exclusive")
+	}
+	if *proxyURL != "" && *osDetect {
+		log.Fatal("-os would fingerprint the proxy, not the target; drop -proxy or -os")
+	}
+	if *proxyURL != "" && *discover != "tcp" && *discover != "none" && !*skipDiscovery {
+		log.Fatalf("-discover %s would probe from this machine, not the proxy; use tcp or none", *discover)
+	}
 
-	startTime := time.Now()
+	dialer, err := scanner.NewDialer(*proxyURL)
+	if err != nil {
+		log.Fatalf("Proxy setup failed: %v", err)
+	}
 
-	// Scan ports
-	results := scanPorts(*host, *startPort, *endPort, *timeout, *workers)
+	// Pick the dial network; plain "tcp" lets the resolver choose
+	network := "tcp"
+	if *ipv4Only {
+		network = "tcp4"
+	} else if *ipv6Only {
+		network = "tcp6"
+	}
 
-	elapsed := time.Since(startTime)
+	var services *scanner.ServiceDB
+	if *servicesFile != "" {
+		services, err = scanner.LoadServices(*servicesFile)
+		if err != nil {
+			log.Fatalf("Failed to load services: %v", err)
+		}
+	}
 
-	// Display results
-	fmt.Println("\n📊 Results:")
-	fmt.Println("─────────────────────────────────")
+	// The checkpoint bitmap spans the lowest to highest port in the list
+	var ports []int
+	if *topPorts > 0 {
+		ports = services.TopPorts(*topPorts, "tcp")
+		if len(ports) == 0 {
+			log.Fatal("-top-ports: the services database has no ranked TCP ports")
+		}
+		if len(ports) < *topPorts {
+			log.Printf("⚠️  Only %d TCP ports known, scanning those", len(ports))
+		}
+		*startPort, *endPort = slices.Min(ports), slices.Max(ports)
+	}
 
-	if len(results) == 0 {
-		fmt.Println("No open ports found")
-	} else {
-		// Sort by port number
-		sort.Slice(results, func(i, j int) bool {
-			return results[i].Port < results[j].Port
-		})
+	var allowed *allowList
+	if *failOnOpen {
+		allowed, err = parseAllowList(*allowOpen)
+		if err != nil {
+			log.Fatalf("Invalid -allow-open: %v", err)
+		}
+	}
 
-		for _, r := range results {
-			service := getServiceName(r.Port)
-			fmt.Printf("  Port %5d: OPEN  (%s)\n", r.Port, service)
+	excludes := strings.Split(*exclude, ",")
+	if *excludeFile != "" {
+		fromFile, err := scanner.ReadExcludeFile(*excludeFile)
+		if err != nil {
+			log.Fatalf("Failed to read exclusions: %v", err)
 		}
+		excludes = append(excludes, fromFile...)
 	}
 
-	fmt.Println("─────────────────────────────────")
-	fmt.Printf("Scan completed in %v\n", elapsed)
-	fmt.Printf("Open ports: %d/%d\n", len(results), *endPort-*startPort+1)
-}
+	// A resumed scan takes its targets, ports and -all from the checkpoint,
+	// and keeps honouring the exclusions it was started with
+	var cp *Checkpoint
+	if *resumePath != "" {
+		loaded, err := loadCheckpoint(*resumePath)
+		if err != nil {
+			log.Fatalf("Failed to load checkpoint: %v", err)
+		}
+		cp = loaded
+		*host, *startPort, *endPort = cp.Targets, cp.StartPort, cp.EndPort
+		ports = cp.Ports
+		*statePath = *resumePath
+		excludes = append(cp.Exclude, excludes...)
+		*showAll = cp.All
+	} else {
+		cp = newCheckpoint(*host, *startPort, *endPort)
+		cp.Ports = ports
+		cp.All = *showAll
+	}
 
-func scanPorts(host string, startPort, endPort int, timeout time.Duration, workers int) []ScanResult {
-	// Channel for ports to scan
-	ports := make(chan int, 100)
+	excludeList, err := scanner.NewExcludeList(excludes)
+	if err != nil {
+		log.Fatalf("Invalid exclusions: %v", err)
+	}
+	cp.Exclude = excludeList.Entries()
 
-	// Channel for results
-	results := make(chan ScanResult, 100)
+	// Cancel the scan cleanly on Ctrl+C so progress can be saved
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
 
-	// WaitGroup for workers
-	var wg sync.WaitGroup
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		<-sigChan
+		log.Println("🛑 Interrupted, stopping workers...")
+		cancel()
+	}()
 
-	// Start worker pool
-	for i := 0; i < workers; i++ {
-		wg.Add(1)
+	if *metricsAddr != "" {
 		go func() {
-			defer wg.Done()
-			for port := range ports {
-				result := scanPort(host, port, timeout)
-				if result.Open {
-					results <- result
-				}
+			if err := serveMetrics(ctx, *metricsAddr); err != nil {
+				log.Fatalf("Metrics server failed: %v", err)
 			}
 		}()
 	}
 
-	// Send ports to workers
-	go func() {
-		for port := startPort; port <= endPort; port++ {
-			ports <- port
+	// scanner.Config takes 0 as "the default"; here it means no banners
+	if *bannerTimeout == 0 {
+		*bannerTimeout = -1
+	}
+
+	opts := scanOptions{
+		Targets:       *host,
+		Exclude:       excludeList,
+		StartPort:     *startPort,
+		EndPort:       *endPort,
+		Ports:         ports,
+		Network:       network,
+		Timeout:       *timeout,
+		Banner:        *bannerTimeout,
+		Workers:       *workers,
+		Dialer:        dialer,
+		ProxyURL:      *proxyURL,
+		Services:      services,
+		All:           *showAll,
+		Discover:      *discover,
+		SkipDiscovery: *skipDiscovery,
+		Quiet:         *quiet,
+		StatePath:     *statePath,
+		RDNS:          *rdns,
+		RDNSWorkers:   *rdnsWorkers,
+		Fingerprint:   *osDetect,
+		ExecCmd:       *execCmd,
+		ExecWorkers:   *execWorkers,
+		ExecTimeout:   *execTimeout,
+	}
+
+	// Long-running modes keep going until Ctrl+C instead of scanning once
+	if *daemon || *uiAddr != "" || *grpcAddr != "" {
+		if *grpcAddr != "" {
+			go func() {
+				if err := serveGRPC(ctx, *grpcAddr, dialer); err != nil {
+					log.Fatalf("gRPC server failed: %v", err)
+				}
+			}()
 		}
-		close(ports)
-	}()
 
-	// Wait for workers and close results
-	go func() {
-		wg.Wait()
-		close(results)
-	}()
+		var store *Store
+		if *daemon || *uiAddr != "" {
+			store, err = openStore(*dbPath)
+			if err != nil {
+				log.Fatalf("Failed to open %s: %v", *dbPath, err)
+			}
+			defer store.Close()
+		}
+
+		if *uiAddr != "" {
+			go func() {
+				if err := serveUI(ctx, *uiAddr, store); err != nil {
+					log.Fatalf("Web UI failed: %v", err)
+				}
+			}()
+		}
+
+		if !*daemon {
+			<-ctx.Done()
+			return
+		}
+
+		// Nobody is watching a progress line in a long-running monitor
+		opts.Quiet = true
+		runDaemon(ctx, store, opts, daemonOptions{
+			Schedule:     *schedule,
+			AlertWebhook: *alertWebhook,
+			OutputFormat: *outputFormat,
+			OutputFile:   *outputFile,
+		})
+		log.Println("👋 Daemon stopped")
+		return
+	}
+
+	run, err := runScan(ctx, opts, cp)
+	if ctx.Err() != nil {
+		if *statePath == "" {
+			log.Println("   No -state file given, progress discarded")
+			os.Exit(exitError)
+		}
+		if err := cp.Save(*statePath); err != nil {
+			log.Fatalf("Failed to save checkpoint: %v", err)
+		}
+		log.Printf("💾 Progress saved (%d probes done). Resume with: -resume %s", cp.Completed(), *statePath)
+		os.Exit(exitError)
+	}
+	if err != nil {
+		log.Fatalf("Scan failed: %v", err)
+	}
+
+	// The scan finished, so the checkpoint is no longer needed
+	if *statePath != "" {
+		os.Remove(*statePath)
+	}
 
-	// Collect results
-	var openPorts []ScanResult
-	for result := range results {
-		openPorts = append(openPorts, result)
+	if *outputFile != "" {
+		if err := writeOutputFile(*outputFile, *outputFormat, run); err != nil {
+			log.Fatalf("Failed to write results: %v", err)
+		}
+		log.Printf("💾 Results written to %s", *outputFile)
+	} else if err := writeResults(os.Stdout, *outputFormat, run.reported(), run.Summary); err != nil {
+		log.Fatalf("Failed to write results: %v", err)
+	}
+
+	logHostSummary(run.Results, run.Summary, allowed)
+
+	var changes *ScanDiff
+	if *dbPath != "" {
+		changes, err = persistScan(*dbPath, run.Summary, run.Results, *diff || *failOnDiff)
+		if err != nil {
+			log.Fatalf("Failed to persist scan: %v", err)
+		}
 	}
 
-	return openPorts
+	// Unexpected open ports take precedence over mere changes
+	if *failOnOpen {
+		if bad := unexpectedOpen(run.Results, allowed); len(bad) > 0 {
+			log.Printf("❌ %d open port(s) not in -allow-open", len(bad))
+			os.Exit(exitUnexpectedOpen)
+		}
+	}
+	if *failOnDiff && changes != nil && (len(changes.Opened) > 0 || len(changes.Closed) > 0) {
+		log.Printf("❌ Ports changed since scan #%d", changes.PreviousID)
+		os.Exit(exitChanged)
+	}
 }
 
-func scanPort(host string, port int, timeout time.Duration) ScanResult {
-	address := fmt.Sprintf("%s:%d", host, port)
+// persistScan stores the run and, if diff is set, reports and returns
+// the changes since the last one (nil if there was no earlier run)
+func persistScan(path string, summary ScanSummary, results []scanner.Result, diff bool) (*ScanDiff, error) {
+	store, err := openStore(path)
+	if err != nil {
+		return nil, err
+	}
+	defer store.Close()
 
-	conn, err := net.DialTimeout("tcp", address, timeout)
+	scanID, err := store.SaveScan(summary, results)
 	if err != nil {
-		return ScanResult{Port: port, Open: false}
+		return nil, err
 	}
-	defer conn.Close()
+	log.Printf("💾 Scan #%d saved to %s", scanID, path)
 
-	return ScanResult{Port: port, Open: true}
+	if !diff {
+		return nil, nil
+	}
+
+	d, err := store.Diff(scanID)
+	if err != nil {
+		return nil, err
+	}
+	printDiff(d)
+	return d, nil
 }
 
-// getServiceName returns common service names for well-known ports
-func getServiceName(port int) string {
-	services := map[int]string{
-		21:    "FTP",
-		22:    "SSH",
-		23:    "Telnet",
-		25:    "SMTP",
-		53:    "DNS",
-		80:    "HTTP",
-		110:   "POP3",
-		143:   "IMAP",
-		443:   "HTTPS",
-		445:   "SMB",
-		993:   "IMAPS",
-		995:   "POP3S",
-		3306:  "MySQL",
-		3389:  "RDP",
-		5432:  "PostgreSQL",
-		6379:  "Redis",
-		8080:  "HTTP-Alt",
-		8443:  "HTTPS-Alt",
-		27017: "MongoDB",
-	}
-
-	if name, ok := services[port]; ok {
-		return name
-	}
-	return "unknown"
+func printDiff(d *ScanDiff) {
+	if d == nil {
+		log.Println("🔁 No previous scan of these targets to diff against")
+		return
+	}
+
+	log.Printf("🔁 Changes since scan #%d:", d.PreviousID)
+	if len(d.Opened) == 0 && len(d.Closed) == 0 {
+		log.Println("   No changes")
+		return
+	}
+
+	sortResults(d.Opened)
+	sortResults(d.Closed)
+
+	for _, r := range d.Opened {
+		log.Printf("   + %s:%d newly OPEN (%s)", r.Host, r.Port, r.Service)
+	}
+	for _, r := range d.Closed {
+		log.Printf("   - %s:%d no longer open (%s)", r.Host, r.Port, r.Service)
+	}
 }
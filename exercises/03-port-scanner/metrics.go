@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Prometheus metrics. They are always updated (it's cheap) but only
+// exposed when -metrics is given.
+var (
+	scansTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "portscan_scans_total",
+		Help: "Scan passes that ran to completion.",
+	})
+
+	scanDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "portscan_scan_duration_seconds",
+		Help:    "Wall-clock time of completed scan passes.",
+		Buckets: prometheus.ExponentialBuckets(0.1, 4, 10), // 100ms .. ~7h
+	})
+
+	probesTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "portscan_probes_total",
+		Help: "Port probes by resulting state (open, closed, filtered, error).",
+	}, []string{"state"})
+
+	probeRate = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "portscan_last_scan_probes_per_second",
+		Help: "Average probe rate of the most recent completed scan.",
+	})
+
+	openPorts = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "portscan_open_ports",
+		Help: "Open ports found by the most recent scan of each target spec.",
+	}, []string{"targets"})
+
+	lastScanTime = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "portscan_last_scan_timestamp_seconds",
+		Help: "Unix time the most recent scan of each target spec finished.",
+	}, []string{"targets"})
+
+	portChanges = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "portscan_port_changes_total",
+		Help: "Ports that opened or closed between consecutive runs.",
+	}, []string{"direction"})
+
+	failuresTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "portscan_failures_total",
+		Help: "Failed operations by stage (scan, store, alert, output).",
+	}, []string{"stage"})
+)
+
+// observeScan records a completed scan pass
+func observeScan(run *scanRun) {
+	elapsed := run.Summary.Elapsed.Seconds()
+
+	scansTotal.Inc()
+	scanDuration.Observe(elapsed)
+	if elapsed > 0 {
+		probeRate.Set(float64(run.Summary.Scanned) / elapsed)
+	}
+	openPorts.WithLabelValues(run.Summary.Targets).Set(float64(len(run.Results)))
+	lastScanTime.WithLabelValues(run.Summary.Targets).Set(float64(time.Now().Unix()))
+}
+
+// observeDiff counts ports that changed state since the previous run
+func observeDiff(d *ScanDiff) {
+	if d == nil {
+		return
+	}
+	portChanges.WithLabelValues("opened").Add(float64(len(d.Opened)))
+	portChanges.WithLabelValues("closed").Add(float64(len(d.Closed)))
+}
+
+// serveMetrics exposes /metrics on addr until ctx is cancelled
+func serveMetrics(ctx context.Context, addr string) error {
+	mux := http.NewServeMux()
+	mux.Handle("GET /metrics", promhttp.Handler())
+
+	server := &http.Server{
+		Handler:           mux,
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(shutdownCtx)
+	}()
+
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("📈 Metrics on http://%s/metrics", ln.Addr())
+	if err := server.Serve(ln); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
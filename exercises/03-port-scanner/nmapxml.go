@@ -0,0 +1,248 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/channyeintun/network-exercises/pkg/scanner"
+)
+
+// The types below mirror the subset of nmap's XML output schema
+// (nmap.dtd, xmloutputversion 1.05) that tools like ndiff and
+// Metasploit's db_import rely on.
+
+type nmapRun struct {
+	XMLName          xml.Name     `xml:"nmaprun"`
+	Scanner          string       `xml:"scanner,attr"`
+	Args             string       `xml:"args,attr"`
+	Start            int64        `xml:"start,attr"`
+	StartStr         string       `xml:"startstr,attr"`
+	Version          string       `xml:"version,attr"`
+	XMLOutputVersion string       `xml:"xmloutputversion,attr"`
+	ScanInfo         nmapScanInfo `xml:"scaninfo"`
+	Hosts            []nmapHost   `xml:"host"`
+	RunStats         nmapRunStats `xml:"runstats"`
+}
+
+type nmapScanInfo struct {
+	Type        string `xml:"type,attr"`
+	Protocol    string `xml:"protocol,attr"`
+	NumServices int    `xml:"numservices,attr"`
+	Services    string `xml:"services,attr"`
+}
+
+type nmapHost struct {
+	StartTime int64         `xml:"starttime,attr"`
+	EndTime   int64         `xml:"endtime,attr"`
+	Status    nmapStatus    `xml:"status"`
+	Address   nmapAddress   `xml:"address"`
+	Hostnames nmapHostnames `xml:"hostnames"`
+	Ports     nmapPorts     `xml:"ports"`
+	OS        *nmapOS       `xml:"os,omitempty"`
+}
+
+type nmapOS struct {
+	Matches []nmapOSMatch `xml:"osmatch"`
+}
+
+type nmapOSMatch struct {
+	Name     string `xml:"name,attr"`
+	Accuracy int    `xml:"accuracy,attr"`
+}
+
+type nmapStatus struct {
+	State  string `xml:"state,attr"`
+	Reason string `xml:"reason,attr"`
+}
+
+type nmapAddress struct {
+	Addr     string `xml:"addr,attr"`
+	AddrType string `xml:"addrtype,attr"`
+}
+
+type nmapHostnames struct {
+	Hostnames []nmapHostname `xml:"hostname"`
+}
+
+type nmapHostname struct {
+	Name string `xml:"name,attr"`
+	Type string `xml:"type,attr"`
+}
+
+type nmapPorts struct {
+	ExtraPorts *nmapExtraPorts `xml:"extraports,omitempty"`
+	Ports      []nmapPort      `xml:"port"`
+}
+
+type nmapExtraPorts struct {
+	State string `xml:"state,attr"`
+	Count int    `xml:"count,attr"`
+}
+
+type nmapPort struct {
+	Protocol string      `xml:"protocol,attr"`
+	PortID   int         `xml:"portid,attr"`
+	State    nmapState   `xml:"state"`
+	Service  nmapService `xml:"service"`
+}
+
+type nmapState struct {
+	State  string `xml:"state,attr"`
+	Reason string `xml:"reason,attr"`
+}
+
+type nmapService struct {
+	Name      string `xml:"name,attr"`
+	Product   string `xml:"product,attr,omitempty"`
+	Method    string `xml:"method,attr"`
+	Conf      int    `xml:"conf,attr"`
+	ExtraInfo string `xml:"extrainfo,attr,omitempty"`
+}
+
+type nmapRunStats struct {
+	Finished nmapFinished  `xml:"finished"`
+	Hosts    nmapHostStats `xml:"hosts"`
+}
+
+type nmapFinished struct {
+	Time    int64  `xml:"time,attr"`
+	TimeStr string `xml:"timestr,attr"`
+	Elapsed string `xml:"elapsed,attr"`
+	Summary string `xml:"summary,attr"`
+	Exit    string `xml:"exit,attr"`
+}
+
+type nmapHostStats struct {
+	Up    int `xml:"up,attr"`
+	Down  int `xml:"down,attr"`
+	Total int `xml:"total,attr"`
+}
+
+func writeNmapXML(w io.Writer, results []scanner.Result, summary ScanSummary) error {
+	end := summary.Start.Add(summary.Elapsed)
+
+	byHost := make(map[string][]scanner.Result)
+	for _, r := range results {
+		byHost[r.Host] = append(byHost[r.Host], r)
+	}
+
+	portsPerHost := summary.portsPerHost()
+	hosts := make([]nmapHost, 0, len(summary.Hosts))
+	for _, name := range summary.Hosts {
+		host := nmapHost{
+			StartTime: summary.Start.Unix(),
+			EndTime:   end.Unix(),
+			Status:    nmapStatus{State: "up", Reason: "user-set"},
+			Address:   nmapAddressFor(name),
+		}
+		if net.ParseIP(name) == nil {
+			host.Hostnames.Hostnames = []nmapHostname{{Name: name, Type: "user"}}
+		}
+		if rs := byHost[name]; len(rs) > 0 && rs[0].Hostname != "" {
+			host.Hostnames.Hostnames = append(host.Hostnames.Hostnames,
+				nmapHostname{Name: rs[0].Hostname, Type: "PTR"})
+		}
+
+		for _, r := range byHost[name] {
+			host.Ports.Ports = append(host.Ports.Ports, nmapPort{
+				Protocol: "tcp",
+				PortID:   r.Port,
+				State:    nmapState{State: r.State, Reason: nmapReason(r.State)},
+				Service: nmapService{
+					Name:      strings.ToLower(r.Service),
+					Method:    "table",
+					Conf:      3,
+					ExtraInfo: r.Banner,
+				},
+			})
+		}
+
+		if fp, ok := summary.Fingerprints[name]; ok && fp.Family != "" {
+			host.OS = &nmapOS{Matches: []nmapOSMatch{{Name: fp.Family, Accuracy: fp.Accuracy}}}
+		}
+
+		// Ports we did not list are summarised the same way nmap does
+		if hidden := portsPerHost - len(byHost[name]); hidden > 0 {
+			host.Ports.ExtraPorts = &nmapExtraPorts{State: scanner.StateClosed, Count: hidden}
+		}
+		hosts = append(hosts, host)
+	}
+
+	services := fmt.Sprintf("%d-%d", summary.StartPort, summary.EndPort)
+	if len(summary.Ports) > 0 {
+		services = formatPorts(summary.Ports)
+	}
+
+	elapsed := strconv.FormatFloat(summary.Elapsed.Seconds(), 'f', 2, 64)
+	run := nmapRun{
+		Scanner:          "nmap",
+		Args:             summary.Args,
+		Start:            summary.Start.Unix(),
+		StartStr:         summary.Start.Format(time.ANSIC),
+		Version:          "7.94",
+		XMLOutputVersion: "1.05",
+		ScanInfo: nmapScanInfo{
+			Type:        "connect",
+			Protocol:    "tcp",
+			NumServices: portsPerHost,
+			Services:    services,
+		},
+		Hosts: hosts,
+		RunStats: nmapRunStats{
+			Finished: nmapFinished{
+				Time:    end.Unix(),
+				TimeStr: end.Format(time.ANSIC),
+				Elapsed: elapsed,
+				Summary: fmt.Sprintf("Nmap done at %s; %d IP addresses (%d hosts up) scanned in %s seconds",
+					end.Format(time.ANSIC), len(hosts), len(hosts), elapsed),
+				Exit: "success",
+			},
+			Hosts: nmapHostStats{Up: len(hosts), Down: 0, Total: len(hosts)},
+		},
+	}
+
+	io.WriteString(w, xml.Header)
+	io.WriteString(w, "<!DOCTYPE nmaprun>\n")
+
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(run); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// nmapAddressFor resolves host to the address element nmap expects
+func nmapAddressFor(host string) nmapAddress {
+	ip := net.ParseIP(host)
+	if ip == nil {
+		if ips, err := net.LookupIP(host); err == nil && len(ips) > 0 {
+			ip = ips[0]
+		}
+	}
+	if ip == nil {
+		return nmapAddress{Addr: host, AddrType: "ipv4"}
+	}
+	if ip.To4() != nil {
+		return nmapAddress{Addr: ip.String(), AddrType: "ipv4"}
+	}
+	return nmapAddress{Addr: ip.String(), AddrType: "ipv6"}
+}
+
+// nmapReason returns the reason nmap would give for a connect-scan state
+func nmapReason(state string) string {
+	switch state {
+	case scanner.StateOpen:
+		return "syn-ack"
+	case scanner.StateClosed:
+		return "conn-refused"
+	default:
+		return "no-response"
+	}
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/channyeintun/network-exercises/pkg/scanner"
+)
+
+// ScanSummary holds run-level information printed alongside results
+type ScanSummary struct {
+	Targets   string   // target spec as given on the command line
+	Hosts     []string // individual hosts the spec expanded to
+	Excluded  []string // hosts dropped by -exclude
+	StartPort int
+	EndPort   int
+	Ports     []int // explicit port list (-top-ports); nil for the whole range
+	Args      string
+	Start     time.Time
+	Elapsed   time.Duration
+	Scanned   int
+
+	// Fingerprints holds OS guesses by host when -os is given
+	Fingerprints map[string]osFingerprint
+}
+
+// resultRecord is the flat, script-friendly shape of a scanner.Result
+type resultRecord struct {
+	Host      string  `json:"host"`
+	Hostname  string  `json:"hostname,omitempty"`
+	OS        string  `json:"os,omitempty"`
+	Port      int     `json:"port"`
+	State     string  `json:"state"`
+	Service   string  `json:"service"`
+	Banner    string  `json:"banner"`
+	LatencyMS float64 `json:"latency_ms"`
+	Timestamp string  `json:"timestamp"`
+}
+
+// portsPerHost is how many ports were probed on each host
+func (s ScanSummary) portsPerHost() int {
+	if len(s.Ports) > 0 {
+		return len(s.Ports)
+	}
+	return s.EndPort - s.StartPort + 1
+}
+
+// formatPorts renders ports as a compact nmap-style list like
+// "21-23,80,443"
+func formatPorts(ports []int) string {
+	sorted := append([]int(nil), ports...)
+	sort.Ints(sorted)
+
+	var parts []string
+	for i := 0; i < len(sorted); {
+		j := i
+		for j+1 < len(sorted) && sorted[j+1] == sorted[j]+1 {
+			j++
+		}
+		if i == j {
+			parts = append(parts, strconv.Itoa(sorted[i]))
+		} else {
+			parts = append(parts, fmt.Sprintf("%d-%d", sorted[i], sorted[j]))
+		}
+		i = j + 1
+	}
+	return strings.Join(parts, ",")
+}
+
+func validFormat(format string) bool {
+	switch format {
+	case "table", "json", "csv", "nmap-xml":
+		return true
+	}
+	return false
+}
+
+func toRecord(r scanner.Result) resultRecord {
+	return resultRecord{
+		Host:      r.Host,
+		Hostname:  r.Hostname,
+		Port:      r.Port,
+		State:     r.State,
+		Service:   r.Service,
+		Banner:    r.Banner,
+		LatencyMS: float64(r.Latency.Microseconds()) / 1000,
+		Timestamp: r.Timestamp.Format(time.RFC3339),
+	}
+}
+
+// writeResults renders results in the requested format
+func writeResults(w io.Writer, format string, results []scanner.Result, summary ScanSummary) error {
+	switch format {
+	case "json":
+		return writeJSON(w, results, summary)
+	case "csv":
+		return writeCSV(w, results, summary)
+	case "nmap-xml":
+		return writeNmapXML(w, results, summary)
+	default:
+		return writeTable(w, results, summary)
+	}
+}
+
+func writeTable(w io.Writer, results []scanner.Result, summary ScanSummary) error {
+	fmt.Fprintln(w, "\n📊 Results:")
+	fmt.Fprintln(w, "─────────────────────────────────")
+
+	open := 0
+	if len(results) == 0 {
+		fmt.Fprintln(w, "No open ports found")
+	} else {
+		lastHost, lastOS := "", ""
+		for _, r := range results {
+			// Results are sorted by host, so print a header per host
+			if (len(summary.Hosts) > 1 || r.Hostname != "") && r.Host != lastHost {
+				if r.Hostname != "" {
+					fmt.Fprintf(w, "%s (%s)\n", r.Host, r.Hostname)
+				} else {
+					fmt.Fprintf(w, "%s\n", r.Host)
+				}
+				lastHost = r.Host
+			}
+			if fp, ok := summary.Fingerprints[r.Host]; ok && r.Host != lastOS {
+				fmt.Fprintf(w, "  OS guess: %s\n", fp)
+				lastOS = r.Host
+			}
+			if r.State == scanner.StateOpen {
+				open++
+			}
+			fmt.Fprintf(w, "  Port %5d: %-8s (%s)\n", r.Port, strings.ToUpper(r.State), r.Service)
+		}
+	}
+
+	fmt.Fprintln(w, "─────────────────────────────────")
+	fmt.Fprintf(w, "Scan completed in %v\n", summary.Elapsed)
+	if len(summary.Excluded) > 0 {
+		fmt.Fprintf(w, "Excluded: %d host(s) skipped by -exclude\n", len(summary.Excluded))
+	}
+	_, err := fmt.Fprintf(w, "Open ports: %d/%d\n", open, summary.Scanned)
+	return err
+}
+
+func writeJSON(w io.Writer, results []scanner.Result, summary ScanSummary) error {
+	records := make([]resultRecord, 0, len(results))
+	for _, r := range results {
+		rec := toRecord(r)
+		rec.OS = summary.Fingerprints[r.Host].Family
+		records = append(records, rec)
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(records)
+}
+
+func writeCSV(w io.Writer, results []scanner.Result, summary ScanSummary) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"host", "hostname", "port", "state", "service", "banner", "latency_ms", "timestamp", "os"})
+
+	for _, r := range results {
+		rec := toRecord(r)
+		rec.OS = summary.Fingerprints[r.Host].Family
+		cw.Write([]string{
+			rec.Host,
+			rec.Hostname,
+			strconv.Itoa(rec.Port),
+			rec.State,
+			rec.Service,
+			rec.Banner,
+			strconv.FormatFloat(rec.LatencyMS, 'f', 2, 64),
+			rec.Timestamp,
+			rec.OS,
+		})
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+// writeOutputFile writes a completed run to path in the given format
+func writeOutputFile(path, format string, run *scanRun) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := writeResults(f, format, run.reported(), run.Summary); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
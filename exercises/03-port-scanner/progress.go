@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// progressInterval is how often the progress line is redrawn
+const progressInterval = time.Second
+
+// progressBarWidth is the number of cells in the progress bar
+const progressBarWidth = 30
+
+// progressLoop redraws a single status line on w until ctx is done.
+// It rewrites the line in place with \r, so w should be a terminal
+// (stderr) rather than the results stream.
+func progressLoop(ctx context.Context, w io.Writer, cp *Checkpoint, total int) {
+	ticker := time.NewTicker(progressInterval)
+	defer ticker.Stop()
+
+	start := time.Now()
+	initial := cp.Completed() // probes restored from a checkpoint
+
+	for {
+		select {
+		case <-ctx.Done():
+			// Clear the line so later log output starts clean
+			fmt.Fprintf(w, "\r%s\r", strings.Repeat(" ", 100))
+			return
+		case <-ticker.C:
+			done := cp.Completed()
+			fmt.Fprintf(w, "\r%s", formatProgress(done, initial, total, cp.OpenCount(), time.Since(start)))
+		}
+	}
+}
+
+// formatProgress builds a line like:
+// [██████░░░░] 61.2% 7342/12000 open:3 1520/s ETA 3s
+func formatProgress(done, initial, total, open int, elapsed time.Duration) string {
+	pct := 0.0
+	if total > 0 {
+		pct = float64(done) / float64(total)
+	}
+	filled := int(pct * progressBarWidth)
+	bar := strings.Repeat("█", filled) + strings.Repeat("░", progressBarWidth-filled)
+
+	// Rate only counts probes made in this run, not ones restored on resume
+	rate := float64(done-initial) / elapsed.Seconds()
+	eta := "--"
+	if rate > 0 {
+		remaining := time.Duration(float64(total-done)/rate) * time.Second
+		eta = remaining.Round(time.Second).String()
+	}
+
+	return fmt.Sprintf("[%s] %5.1f%% %d/%d open:%d %.0f/s ETA %s   ",
+		bar, pct*100, done, total, open, rate, eta)
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ptrResolver looks up PTR records in the background with bounded
+// concurrency, so slow DNS never holds up the scan workers
+type ptrResolver struct {
+	timeout time.Duration
+	sem     chan struct{}
+	wg      sync.WaitGroup
+
+	mu    sync.Mutex
+	names map[string]string
+	seen  map[string]bool
+}
+
+func newPTRResolver(workers int, timeout time.Duration) *ptrResolver {
+	return &ptrResolver{
+		timeout: timeout,
+		sem:     make(chan struct{}, workers),
+		names:   make(map[string]string),
+		seen:    make(map[string]bool),
+	}
+}
+
+// Lookup starts resolving host if it is an IP we haven't queued yet.
+// It never blocks the caller.
+func (p *ptrResolver) Lookup(host string) {
+	if net.ParseIP(host) == nil {
+		return // already a name, nothing to reverse
+	}
+
+	p.mu.Lock()
+	if p.seen[host] {
+		p.mu.Unlock()
+		return
+	}
+	p.seen[host] = true
+	p.mu.Unlock()
+
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		p.sem <- struct{}{}
+		defer func() { <-p.sem }()
+
+		ctx, cancel := context.WithTimeout(context.Background(), p.timeout)
+		defer cancel()
+
+		names, err := net.DefaultResolver.LookupAddr(ctx, host)
+		if err != nil || len(names) == 0 {
+			return
+		}
+
+		p.mu.Lock()
+		p.names[host] = strings.TrimSuffix(names[0], ".")
+		p.mu.Unlock()
+	}()
+}
+
+// Wait blocks until all queued lookups have finished
+func (p *ptrResolver) Wait() {
+	p.wg.Wait()
+}
+
+// Name returns the resolved hostname for host, or "" if none was found
+func (p *ptrResolver) Name(host string) string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.names[host]
+}
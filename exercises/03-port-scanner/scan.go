@@ -0,0 +1,305 @@
+package main
+
+import (
+	"context"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/channyeintun/network-exercises/pkg/scanner"
+)
+
+// scanOptions holds everything needed to run one scan pass
+type scanOptions struct {
+	Targets   string
+	Exclude   *scanner.ExcludeList
+	StartPort int
+	EndPort   int
+	Ports     []int // scanned instead of StartPort-EndPort if set
+	Network   string
+	Timeout   time.Duration
+	Banner    time.Duration // how long to wait for a banner, negative to skip
+	Workers   int
+	Dialer    scanner.Dialer
+	ProxyURL  string
+	Services  *scanner.ServiceDB
+
+	All           bool // report closed and filtered ports too, not just open ones
+	Discover      string
+	SkipDiscovery bool
+	Quiet         bool
+	StatePath     string // checkpoint file, "" to disable
+
+	RDNS        bool
+	RDNSWorkers int
+
+	Fingerprint bool // guess each host's OS after the scan
+
+	ExecCmd     string
+	ExecWorkers int
+	ExecTimeout time.Duration
+}
+
+// portCount is the number of ports probed per host
+func (o scanOptions) portCount() int {
+	if len(o.Ports) > 0 {
+		return len(o.Ports)
+	}
+	return o.EndPort - o.StartPort + 1
+}
+
+// scanRun is the outcome of one completed scan pass
+type scanRun struct {
+	Results []scanner.Result // open ports, sorted by host then port
+	Others  []scanner.Result // closed and filtered ports, with -all; sorted the same
+	Summary ScanSummary
+}
+
+// reported is what the output formats list: the open ports and, with
+// -all, the closed and filtered ones among them
+func (r *scanRun) reported() []scanner.Result {
+	if len(r.Others) == 0 {
+		return r.Results
+	}
+	all := append(append([]scanner.Result(nil), r.Results...), r.Others...)
+	sortResults(all)
+	return all
+}
+
+// runScan expands targets, discovers live hosts, scans them and returns
+// the open ports. If ctx is cancelled part way it returns ctx.Err() and
+// cp holds the progress made so far.
+func runScan(ctx context.Context, opts scanOptions, cp *Checkpoint) (*scanRun, error) {
+	hosts, excluded, err := scanner.ExpandTargetsExcluding(opts.Targets, opts.Exclude, opts.Network)
+	if err != nil {
+		return nil, err
+	}
+	if len(excluded) > 0 {
+		log.Printf("🚫 Excluding %d host(s) matching %d exclusion(s)", len(excluded), opts.Exclude.Len())
+	}
+	if err := scanner.CheckFamily(hosts, opts.Network); err != nil {
+		return nil, err
+	}
+
+	// Only port-scan hosts that answer, which saves a lot of time on
+	// sparse subnets where most addresses are unused
+	if !opts.SkipDiscovery && opts.Discover != "none" && len(hosts) > 1 {
+		log.Printf("🛰  Discovering live hosts among %d targets (%s)...", len(hosts), opts.Discover)
+		up, err := discoverHosts(ctx, opts.Dialer, opts.Discover, opts.Network, hosts, opts.Timeout, opts.Workers)
+		if err != nil {
+			return nil, err
+		}
+		log.Printf("   %d/%d hosts up", len(up), len(hosts))
+		hosts = up
+	}
+
+	total := len(hosts) * opts.portCount()
+	if len(opts.Ports) > 0 {
+		log.Printf("🔍 Scanning %d host(s), top %d ports", len(hosts), len(opts.Ports))
+	} else {
+		log.Printf("🔍 Scanning %d host(s) ports %d-%d", len(hosts), opts.StartPort, opts.EndPort)
+	}
+	log.Printf("   Timeout: %v, Workers: %d", opts.Timeout, opts.Workers)
+	if opts.ProxyURL != "" {
+		log.Printf("   Via proxy %s", opts.ProxyURL)
+		log.Printf("   ⚠️  %s", scanner.ProxyAccuracyNote(opts.ProxyURL))
+	}
+	if done := cp.Completed(); done > 0 {
+		log.Printf("   Resuming: %d/%d probes already done", done, total)
+	}
+
+	if len(hosts) == 0 {
+		return &scanRun{Summary: ScanSummary{
+			Targets:   opts.Targets,
+			Excluded:  excluded,
+			StartPort: opts.StartPort,
+			EndPort:   opts.EndPort,
+			Ports:     opts.Ports,
+			Args:      strings.Join(os.Args, " "),
+			Start:     cp.Started,
+		}}, nil
+	}
+
+	startTime := time.Now()
+
+	// Background loops that live only as long as this scan pass
+	bgCtx, stopBG := context.WithCancel(ctx)
+	var bg sync.WaitGroup
+
+	// Flush progress to the state file while the scan runs
+	if opts.StatePath != "" {
+		bg.Add(1)
+		go func() {
+			defer bg.Done()
+			checkpointLoop(bgCtx, cp, opts.StatePath)
+		}()
+	}
+
+	// Show live progress until the scan finishes
+	if !opts.Quiet {
+		bg.Add(1)
+		go func() {
+			defer bg.Done()
+			progressLoop(bgCtx, os.Stderr, cp, total)
+		}()
+	}
+
+	// Things to do in the background as open ports turn up
+	var onOpen []func(scanner.Result)
+
+	var resolver *ptrResolver
+	if opts.RDNS {
+		resolver = newPTRResolver(opts.RDNSWorkers, 2*time.Second)
+		onOpen = append(onOpen, func(r scanner.Result) { resolver.Lookup(r.Host) })
+	}
+
+	var hook *execHook
+	if opts.ExecCmd != "" {
+		hook, err = newExecHook(opts.ExecCmd, opts.ExecWorkers, opts.ExecTimeout)
+		if err != nil {
+			stopBG()
+			bg.Wait()
+			return nil, err
+		}
+		onOpen = append(onOpen, hook.Run)
+	}
+
+	// Scan ports
+	err = scanPorts(ctx, scanner.Config{
+		Hosts:     hosts,
+		StartPort: opts.StartPort,
+		EndPort:   opts.EndPort,
+		Ports:     opts.Ports,
+		Network:   opts.Network,
+		Timeout:   opts.Timeout,
+		Workers:   opts.Workers,
+		Dialer:    opts.Dialer,
+		Services:  opts.Services,
+		Logf:      log.Printf,
+
+		BannerTimeout: opts.Banner,
+	}, cp, onOpen)
+
+	elapsed := time.Since(startTime)
+	stopBG()
+	bg.Wait()
+
+	if hook != nil {
+		hook.Wait()
+	}
+	if err != nil {
+		return nil, err
+	}
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+
+	results := cp.OpenResults()
+	others := cp.OtherResults()
+
+	if resolver != nil {
+		// Results restored from a checkpoint still need their lookups
+		for _, r := range results {
+			resolver.Lookup(r.Host)
+		}
+		resolver.Wait()
+		for i := range results {
+			results[i].Hostname = resolver.Name(results[i].Host)
+		}
+		for i := range others {
+			others[i].Hostname = resolver.Name(others[i].Host)
+		}
+	}
+
+	sortResults(results)
+	sortResults(others)
+
+	var fingerprints map[string]osFingerprint
+	if opts.Fingerprint && len(results) > 0 {
+		log.Printf("🖥  Fingerprinting hosts with open ports...")
+		fingerprints = fingerprintHosts(ctx, opts.Network, results, opts.Timeout)
+	}
+
+	run := &scanRun{
+		Results: results,
+		Others:  others,
+		Summary: ScanSummary{
+			Targets:   opts.Targets,
+			Hosts:     hosts,
+			Excluded:  excluded,
+			StartPort: opts.StartPort,
+			EndPort:   opts.EndPort,
+			Ports:     opts.Ports,
+			Args:      strings.Join(os.Args, " "),
+			Start:     cp.Started,
+			Elapsed:   elapsed,
+			Scanned:   total,
+
+			Fingerprints: fingerprints,
+		},
+	}
+	observeScan(run)
+	return run, nil
+}
+
+// scanPorts runs the scan engine, skipping pairs already in the
+// checkpoint, recording each result in cp and calling onOpen for
+// open ports. It returns early if ctx is cancelled.
+func scanPorts(ctx context.Context, cfg scanner.Config, cp *Checkpoint, onOpen []func(scanner.Result)) error {
+	cfg.Skip = cp.IsDone
+
+	results, err := scanner.Scan(ctx, cfg)
+	if err != nil {
+		return err
+	}
+
+	failed := 0
+	for result := range results {
+		probesTotal.WithLabelValues(result.State).Inc()
+		if result.State == scanner.StateError {
+			// Leave it undone so a resumed scan tries again
+			failed++
+			continue
+		}
+		cp.MarkDone(result)
+		if result.State == scanner.StateOpen {
+			for _, fn := range onOpen {
+				fn(result)
+			}
+		}
+	}
+
+	if failed > 0 {
+		log.Printf("⚠️  %d probes failed with local resource errors and were not classified", failed)
+	}
+	return nil
+}
+
+func checkpointLoop(ctx context.Context, cp *Checkpoint, path string) {
+	ticker := time.NewTicker(checkpointInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := cp.Save(path); err != nil {
+				log.Printf("Checkpoint error: %v", err)
+			}
+		}
+	}
+}
+
+// sortResults orders results by host, then port number
+func sortResults(results []scanner.Result) {
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Host != results[j].Host {
+			return results[i].Host < results[j].Host
+		}
+		return results[i].Port < results[j].Port
+	})
+}
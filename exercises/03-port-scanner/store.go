@@ -0,0 +1,295 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/channyeintun/network-exercises/pkg/scanner"
+	_ "modernc.org/sqlite" // pure-Go SQLite driver, no cgo required
+)
+
+const schema = `
+CREATE TABLE IF NOT EXISTS scans (
+	id          INTEGER PRIMARY KEY AUTOINCREMENT,
+	host        TEXT    NOT NULL, -- target spec, e.g. "10.0.0.0/24"
+	start_port  INTEGER NOT NULL,
+	end_port    INTEGER NOT NULL,
+	started_at  TEXT    NOT NULL,
+	elapsed_ms  INTEGER NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS results (
+	scan_id     INTEGER NOT NULL REFERENCES scans(id),
+	host        TEXT    NOT NULL,
+	port        INTEGER NOT NULL,
+	state       TEXT    NOT NULL,
+	service     TEXT    NOT NULL,
+	banner      TEXT    NOT NULL,
+	latency_us  INTEGER NOT NULL,
+	scanned_at  TEXT    NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_results_scan ON results(scan_id);
+`
+
+// Store persists scan runs in a SQLite database
+type Store struct {
+	db *sql.DB
+}
+
+// ScanRecord describes one stored scan run
+type ScanRecord struct {
+	ID        int64
+	Targets   string
+	StartPort int
+	EndPort   int
+	Started   time.Time
+	Elapsed   time.Duration
+	OpenPorts int
+}
+
+// ScanDiff lists ports whose state changed between two runs
+type ScanDiff struct {
+	PreviousID int64
+	CurrentID  int64
+	Opened     []scanner.Result
+	Closed     []scanner.Result
+}
+
+func openStore(path string) (*Store, error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, err
+	}
+
+	// The daemon writes while the web UI reads; one connection keeps
+	// SQLite from returning "database is locked" between them
+	db.SetMaxOpenConns(1)
+
+	if _, err := db.Exec(schema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("create schema: %w", err)
+	}
+
+	return &Store{db: db}, nil
+}
+
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// SaveScan records one scan run and its open ports, returning the run ID
+func (s *Store) SaveScan(summary ScanSummary, results []scanner.Result) (int64, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(
+		`INSERT INTO scans (host, start_port, end_port, started_at, elapsed_ms) VALUES (?, ?, ?, ?, ?)`,
+		summary.Targets, summary.StartPort, summary.EndPort,
+		summary.Start.Format(time.RFC3339Nano), summary.Elapsed.Milliseconds(),
+	)
+	if err != nil {
+		return 0, fmt.Errorf("insert scan: %w", err)
+	}
+
+	scanID, err := res.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	for _, r := range results {
+		_, err := tx.Exec(
+			`INSERT INTO results (scan_id, host, port, state, service, banner, latency_us, scanned_at)
+			 VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+			scanID, r.Host, r.Port, r.State, r.Service, r.Banner,
+			r.Latency.Microseconds(), r.Timestamp.Format(time.RFC3339Nano),
+		)
+		if err != nil {
+			return 0, fmt.Errorf("insert result: %w", err)
+		}
+	}
+
+	return scanID, tx.Commit()
+}
+
+// Diff compares scan scanID with the run before it for the same targets.
+// It returns nil if there is no earlier run to compare against.
+func (s *Store) Diff(scanID int64) (*ScanDiff, error) {
+	var host string
+	if err := s.db.QueryRow(`SELECT host FROM scans WHERE id = ?`, scanID).Scan(&host); err != nil {
+		return nil, fmt.Errorf("load scan %d: %w", scanID, err)
+	}
+
+	var prevID int64
+	err := s.db.QueryRow(
+		`SELECT id FROM scans WHERE host = ? AND id < ? ORDER BY id DESC LIMIT 1`,
+		host, scanID,
+	).Scan(&prevID)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("find previous scan: %w", err)
+	}
+
+	current, err := s.openPorts(scanID)
+	if err != nil {
+		return nil, err
+	}
+	previous, err := s.openPorts(prevID)
+	if err != nil {
+		return nil, err
+	}
+
+	diff := &ScanDiff{PreviousID: prevID, CurrentID: scanID}
+	for key, r := range current {
+		if _, ok := previous[key]; !ok {
+			diff.Opened = append(diff.Opened, r)
+		}
+	}
+	for key, r := range previous {
+		if _, ok := current[key]; !ok {
+			diff.Closed = append(diff.Closed, r)
+		}
+	}
+
+	return diff, nil
+}
+
+// ListScans returns the most recent scan runs, newest first
+func (s *Store) ListScans(limit int) ([]ScanRecord, error) {
+	rows, err := s.db.Query(
+		`SELECT s.id, s.host, s.start_port, s.end_port, s.started_at, s.elapsed_ms,
+		        (SELECT COUNT(*) FROM results r WHERE r.scan_id = s.id AND r.state = ?)
+		 FROM scans s ORDER BY s.id DESC LIMIT ?`,
+		scanner.StateOpen, limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("list scans: %w", err)
+	}
+	defer rows.Close()
+
+	var scans []ScanRecord
+	for rows.Next() {
+		rec, err := scanScanRecord(rows)
+		if err != nil {
+			return nil, err
+		}
+		scans = append(scans, rec)
+	}
+	return scans, rows.Err()
+}
+
+// LoadScan returns a single scan run by ID
+func (s *Store) LoadScan(scanID int64) (ScanRecord, error) {
+	row := s.db.QueryRow(
+		`SELECT s.id, s.host, s.start_port, s.end_port, s.started_at, s.elapsed_ms,
+		        (SELECT COUNT(*) FROM results r WHERE r.scan_id = s.id AND r.state = ?)
+		 FROM scans s WHERE s.id = ?`,
+		scanner.StateOpen, scanID,
+	)
+	rec, err := scanScanRecord(row)
+	if err != nil {
+		return ScanRecord{}, fmt.Errorf("load scan %d: %w", scanID, err)
+	}
+	return rec, nil
+}
+
+// rowScanner is satisfied by both *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanScanRecord(row rowScanner) (ScanRecord, error) {
+	var rec ScanRecord
+	var startedAt string
+	var elapsedMS int64
+	err := row.Scan(&rec.ID, &rec.Targets, &rec.StartPort, &rec.EndPort, &startedAt, &elapsedMS, &rec.OpenPorts)
+	if err != nil {
+		return ScanRecord{}, err
+	}
+	rec.Started, _ = time.Parse(time.RFC3339Nano, startedAt)
+	rec.Elapsed = time.Duration(elapsedMS) * time.Millisecond
+	return rec, nil
+}
+
+// Results returns the stored results of a scan ordered by host and port.
+// Non-empty host and service filters match case-insensitive substrings.
+func (s *Store) Results(scanID int64, host, service string) ([]scanner.Result, error) {
+	rows, err := s.db.Query(
+		`SELECT host, port, state, service, banner, latency_us, scanned_at
+		 FROM results
+		 WHERE scan_id = ? AND host LIKE ? ESCAPE '\' AND service LIKE ? ESCAPE '\'
+		 ORDER BY host, port`,
+		scanID, containsPattern(host), containsPattern(service),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("load results: %w", err)
+	}
+	defer rows.Close()
+
+	var results []scanner.Result
+	for rows.Next() {
+		r, err := scanResult(rows)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, r)
+	}
+	return results, rows.Err()
+}
+
+// containsPattern builds a LIKE pattern matching s anywhere, escaping
+// the wildcards so user input is matched literally
+func containsPattern(s string) string {
+	s = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+	return "%" + s + "%"
+}
+
+// scanResult reads one row of the results table
+func scanResult(row rowScanner) (scanner.Result, error) {
+	var r scanner.Result
+	var latencyUS int64
+	var scannedAt string
+	if err := row.Scan(&r.Host, &r.Port, &r.State, &r.Service, &r.Banner, &latencyUS, &scannedAt); err != nil {
+		return scanner.Result{}, err
+	}
+	r.Latency = time.Duration(latencyUS) * time.Microsecond
+	r.Timestamp, _ = time.Parse(time.RFC3339Nano, scannedAt)
+	return r, nil
+}
+
+// portKey identifies a port on a particular host
+type portKey struct {
+	host string
+	port int
+}
+
+// openPorts loads the open ports of a scan keyed by host and port
+func (s *Store) openPorts(scanID int64) (map[portKey]scanner.Result, error) {
+	rows, err := s.db.Query(
+		`SELECT host, port, state, service, banner, latency_us, scanned_at
+		 FROM results WHERE scan_id = ? AND state = ?`,
+		scanID, scanner.StateOpen,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("load results: %w", err)
+	}
+	defer rows.Close()
+
+	ports := make(map[portKey]scanner.Result)
+	for rows.Next() {
+		r, err := scanResult(rows)
+		if err != nil {
+			return nil, err
+		}
+		ports[portKey{host: r.Host, port: r.Port}] = r
+	}
+
+	return ports, rows.Err()
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"context"
+	"embed"
+	"errors"
+	"html/template"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+//go:embed templates/*.html
+var templateFS embed.FS
+
+var uiTemplates = template.Must(template.New("").Funcs(template.FuncMap{
+	"when": func(t time.Time) string { return t.Local().Format("2006-01-02 15:04:05") },
+	"dur":  func(d time.Duration) string { return d.Round(time.Millisecond).String() },
+	"ms":   func(d time.Duration) string { return strconv.FormatFloat(float64(d.Microseconds())/1000, 'f', 2, 64) },
+}).ParseFS(templateFS, "templates/*.html"))
+
+// historyLimit caps how many runs the index page lists
+const historyLimit = 200
+
+// uiServer serves read-only pages over the scan store
+type uiServer struct {
+	store *Store
+}
+
+// targetSummary is one row of the "Targets" table: a target spec and
+// its most recent run
+type targetSummary struct {
+	Targets string
+	Runs    int
+	Latest  ScanRecord
+}
+
+// serveUI runs the web UI on addr until ctx is cancelled
+func serveUI(ctx context.Context, addr string, store *Store) error {
+	ui := &uiServer{store: store}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /{$}", ui.handleIndex)
+	mux.HandleFunc("GET /scans/{id}", ui.handleScan)
+
+	server := &http.Server{
+		Addr:              addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(shutdownCtx)
+	}()
+
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("🌐 Web UI on http://%s", ln.Addr())
+	if err := server.Serve(ln); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+func (ui *uiServer) handleIndex(w http.ResponseWriter, r *http.Request) {
+	scans, err := ui.store.ListScans(historyLimit)
+	if err != nil {
+		ui.fail(w, err)
+		return
+	}
+
+	// Scans are newest first, so the first one seen per spec is the latest
+	var targets []*targetSummary
+	byTargets := make(map[string]*targetSummary)
+	for _, s := range scans {
+		t, ok := byTargets[s.Targets]
+		if !ok {
+			t = &targetSummary{Targets: s.Targets, Latest: s}
+			byTargets[s.Targets] = t
+			targets = append(targets, t)
+		}
+		t.Runs++
+	}
+
+	ui.render(w, "index.html", map[string]any{
+		"Targets": targets,
+		"Scans":   scans,
+	})
+}
+
+func (ui *uiServer) handleScan(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	scan, err := ui.store.LoadScan(id)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	host := r.URL.Query().Get("host")
+	service := r.URL.Query().Get("service")
+
+	results, err := ui.store.Results(id, host, service)
+	if err != nil {
+		ui.fail(w, err)
+		return
+	}
+
+	diff, err := ui.store.Diff(id)
+	if err != nil {
+		ui.fail(w, err)
+		return
+	}
+	if diff != nil {
+		sortResults(diff.Opened)
+		sortResults(diff.Closed)
+	}
+
+	ui.render(w, "scan.html", map[string]any{
+		"Scan":    scan,
+		"Results": results,
+		"Diff":    diff,
+		"Host":    host,
+		"Service": service,
+	})
+}
+
+func (ui *uiServer) render(w http.ResponseWriter, name string, data any) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := uiTemplates.ExecuteTemplate(w, name, data); err != nil {
+		log.Printf("UI template %s: %v", name, err)
+	}
+}
+
+func (ui *uiServer) fail(w http.ResponseWriter, err error) {
+	log.Printf("UI error: %v", err)
+	http.Error(w, "internal error", http.StatusInternalServerError)
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/channyeintun/network-exercises/pkg/ping"
+)
+
+// Webhook delivery: up to alertAttempts tries, waiting alertBackoff,
+// then twice as long, and so on between them
+const (
+	alertAttempts = 5
+	alertBackoff  = time.Second
+)
+
+// Host states. A target starts out unknown, so the first replies don't
+// announce it's "up again".
+const (
+	stateUnknown = "unknown"
+	stateUp      = "up"
+	stateDown    = "down"
+)
+
+// upDown turns a stream of ping outcomes into up/down state, ignoring
+// blips: downAfter losses in a row mark a host down, upAfter replies in
+// a row mark it up.
+type upDown struct {
+	downAfter, upAfter int
+
+	state  string
+	ok     bool // outcome of the current streak
+	streak int
+}
+
+// observe records one ping and returns the new state if it changed
+func (u *upDown) observe(ok bool) (string, bool) {
+	if u.state == "" {
+		u.state = stateUnknown
+	}
+	if ok != u.ok {
+		u.ok, u.streak = ok, 0
+	}
+	u.streak++
+
+	next := u.state
+	switch {
+	case ok && u.streak >= u.upAfter:
+		next = stateUp
+	case !ok && u.streak >= u.downAfter:
+		next = stateDown
+	}
+	if next == u.state {
+		return u.state, false
+	}
+	u.state = next
+	return next, true
+}
+
+// alertEvent describes a transition, and is POSTed to the webhook as is.
+// The "text" field makes it render directly in Slack-compatible incoming
+// webhooks.
+type alertEvent struct {
+	Text          string  `json:"text"`
+	Target        string  `json:"target"`
+	Address       string  `json:"address"`
+	State         string  `json:"state"` // "up" or "down"
+	PreviousState string  `json:"previous_state"`
+	Time          string  `json:"time"`
+	Consecutive   int     `json:"consecutive"` // replies or losses in a row that triggered it
+	LossPct       float64 `json:"loss_pct"`    // over the whole session
+	LastError     string  `json:"last_error,omitempty"`
+}
+
+// alerter delivers events one at a time, in order, so a slow or failing
+// webhook holds up alerts rather than pings
+type alerter struct {
+	url    string
+	events chan alertEvent
+	done   chan struct{}
+}
+
+func newAlerter(ctx context.Context, url string) *alerter {
+	a := &alerter{
+		url:    url,
+		events: make(chan alertEvent, 64),
+		done:   make(chan struct{}),
+	}
+	go func() {
+		defer close(a.done)
+		for ev := range a.events {
+			if err := a.deliver(ctx, ev); err != nil && ctx.Err() == nil {
+				log.Printf("❌ Alert for %s failed: %v", ev.Target, err)
+			}
+		}
+	}()
+	return a
+}
+
+// watchState makes t report its up/down transitions to each of notify
+func watchState(t *target, downAfter, upAfter int, notify ...func(alertEvent)) {
+	u := &upDown{downAfter: downAfter, upAfter: upAfter}
+	t.onRecv = func(pkt ping.Packet) {
+		previous := u.state
+		state, changed := u.observe(pkt.Err == nil)
+		if !changed || (previous == stateUnknown && state == stateUp) {
+			return
+		}
+
+		ev := alertEvent{
+			Target:        t.host,
+			Address:       t.addr.String(),
+			State:         state,
+			PreviousState: previous,
+			Time:          time.Now().Format(time.RFC3339),
+			Consecutive:   u.streak,
+			LossPct:       t.stats().Loss(),
+		}
+		if state == stateDown {
+			ev.LastError = pkt.Err.Error()
+			ev.Text = fmt.Sprintf("🔴 %s (%s) is down: %d pings lost in a row (%s)", t.host, t.addr, u.streak, ev.LastError)
+		} else {
+			ev.Text = fmt.Sprintf("🟢 %s (%s) is up again: %d replies in a row", t.host, t.addr, u.streak)
+		}
+		log.Println(ev.Text)
+		for _, n := range notify {
+			n(ev)
+		}
+	}
+}
+
+// send queues ev for delivery
+func (a *alerter) send(ev alertEvent) {
+	select {
+	case a.events <- ev:
+	default:
+		log.Printf("⚠️  Alert queue full; dropping alert for %s", ev.Target)
+	}
+}
+
+// close waits for queued alerts to be delivered, or given up on
+func (a *alerter) close() {
+	close(a.events)
+	<-a.done
+}
+
+// deliver POSTs ev, retrying with exponential backoff on network errors
+// and on 5xx or 429 responses. Other statuses won't improve on retry.
+func (a *alerter) deliver(ctx context.Context, ev alertEvent) error {
+	body, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+
+	backoff := alertBackoff
+	for attempt := 1; ; attempt++ {
+		retry, err := a.post(ctx, body)
+		if err == nil || !retry || attempt == alertAttempts {
+			return err
+		}
+
+		log.Printf("⚠️  Alert for %s failed (%v); retrying in %v", ev.Target, err, backoff)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+// post makes one delivery attempt and says whether a failure is worth
+// retrying
+func (a *alerter) post(ctx context.Context, body []byte) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, "POST", a.url, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		retry := resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests
+		return retry, fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return false, nil
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"slices"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/channyeintun/network-exercises/pkg/ping"
+)
+
+// daemonOptions configures metrics export mode
+type daemonOptions struct {
+	Listen   string // address serving /metrics
+	Interval time.Duration
+	Timeout  time.Duration
+
+	History     time.Duration // how far back /api/targets/{name}/history goes
+	HistoryFile string        // optional; keeps the history across restarts
+
+	// Reload, if set, re-reads the targets on SIGHUP, and NewTarget
+	// readies the ones it adds
+	Reload    func() ([]hostEntry, error)
+	NewTarget func(hostEntry) (*target, error)
+}
+
+// pinging is a target runDaemon has started, and how to stop it
+type pinging struct {
+	t      *target
+	cancel context.CancelFunc
+}
+
+// runDaemon pings every target until ctx is done, smokeping style,
+// keeping the Prometheus metrics and each target's recent history up to
+// date for scraping on listen. The terminal only gets the final table.
+func runDaemon(ctx context.Context, targets []*target, opts daemonOptions) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	hist := newHistoryStore(opts.History, opts.Interval)
+	if opts.HistoryFile != "" {
+		// A daemon that restarts with no history beats one that won't
+		if err := hist.load(opts.HistoryFile); err != nil {
+			log.Printf("⚠️  Starting with no history: %v", err)
+		}
+	}
+
+	// Pinging with nothing to scrape the results would be pointless,
+	// so a taken port is fatal
+	go func() {
+		if err := serveMetrics(ctx, opts.Listen, hist); err != nil {
+			log.Fatalf("Metrics server failed: %v", err)
+		}
+	}()
+
+	log.Printf("🛰️  Pinging %d targets every %v; Ctrl+C to stop", len(targets), opts.Interval)
+
+	var wg sync.WaitGroup
+	running := make(map[hostEntry]pinging)
+	start := func(t *target) {
+		labels := []string{t.label(), t.host, t.addr.String()}
+		sent := packetsSent.WithLabelValues(labels...)
+		received := packetsReceived.WithLabelValues(labels...)
+		loss := lossRatio.WithLabelValues(labels...)
+		rtt := rttSeconds.WithLabelValues(labels...)
+		var window lossWindow // report calls don't overlap, so no lock
+		h := hist.track(t)
+
+		tctx, tcancel := context.WithCancel(ctx)
+		running[t.entry()] = pinging{t: t, cancel: tcancel}
+
+		t.onSend = sent.Inc
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			t.run(tctx, 0, opts.Interval, opts.Timeout, func(pkt ping.Packet) {
+				h.add(newHistorySample(pkt))
+				loss.Set(window.add(pkt.Err != nil))
+				if pkt.Err == nil {
+					received.Inc()
+					rtt.Observe(pkt.RTT.Seconds())
+				}
+			})
+		}()
+	}
+	for _, t := range targets {
+		start(t)
+	}
+
+	hup := make(chan os.Signal, 1)
+	if opts.Reload != nil {
+		signal.Notify(hup, syscall.SIGHUP)
+		defer signal.Stop(hup)
+	}
+
+	// A nil channel never fires, so without -history-file nothing is saved
+	var saveTick <-chan time.Time
+	if opts.HistoryFile != "" {
+		ticker := time.NewTicker(historySaveInterval)
+		defer ticker.Stop()
+		saveTick = ticker.C
+	}
+	save := func() {
+		if err := hist.save(opts.HistoryFile); err != nil {
+			log.Printf("❌ Failed to save history: %v", err)
+		}
+	}
+
+	for ctx.Err() == nil {
+		select {
+		case <-ctx.Done():
+		case <-hup:
+			targets = reloadTargets(running, targets, opts, start, hist)
+		case <-saveTick:
+			save()
+		}
+	}
+	wg.Wait()
+	if opts.HistoryFile != "" {
+		save()
+	}
+
+	fmt.Println()
+	redrawTable(os.Stdout, targets, 0)
+}
+
+// reloadTargets brings the running targets in line with a fresh read of
+// the targets file: it stops the ones no longer listed, dropping their
+// metrics and history, and starts new ones as far as the rate cap
+// allows. Targets that stay keep their statistics. A file that can't be
+// read changes nothing. It returns the targets now running, in file
+// order.
+func reloadTargets(running map[hostEntry]pinging, targets []*target, opts daemonOptions, start func(*target), hist *historyStore) []*target {
+	entries, err := opts.Reload()
+	if err != nil {
+		log.Printf("⚠️  Reload failed, keeping the current targets: %v", err)
+		return targets
+	}
+
+	listed := make(map[hostEntry]bool)
+	for _, e := range entries {
+		listed[e] = true
+	}
+	for e, p := range running {
+		if listed[e] {
+			continue
+		}
+		p.cancel()
+		delete(running, e)
+		labels := []string{p.t.label(), p.t.host, p.t.addr.String()}
+		packetsSent.DeleteLabelValues(labels...)
+		packetsReceived.DeleteLabelValues(labels...)
+		lossRatio.DeleteLabelValues(labels...)
+		rttSeconds.DeleteLabelValues(labels...)
+		hist.forget(p.t.label())
+		log.Printf("➖ Stopped pinging %s", p.t.label())
+	}
+
+	maxTargets := int(opts.Interval * maxPingsPerSecond / time.Second)
+	var next []*target
+	for _, e := range entries {
+		if p, ok := running[e]; ok {
+			if !slices.Contains(next, p.t) {
+				next = append(next, p.t)
+			}
+			continue
+		}
+		if len(running) >= maxTargets {
+			log.Printf("⚠️  Not starting %s: %d targets every %v is the most under %d pings/s", e.host, maxTargets, opts.Interval, maxPingsPerSecond)
+			continue
+		}
+
+		t, err := opts.NewTarget(e)
+		if err != nil {
+			log.Printf("⚠️  Skipping %s: %v", e.host, err)
+			continue
+		}
+		start(t)
+		next = append(next, t)
+		log.Printf("➕ Now pinging %s (%s)", t.label(), t.addr)
+	}
+
+	log.Printf("🔄 Reloaded: pinging %d targets", len(next))
+	return next
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// How often the live table is redrawn
+const tableRefresh = 500 * time.Millisecond
+
+// splitHosts parses a comma-separated host list
+func splitHosts(list string) []string {
+	var hosts []string
+	for _, h := range strings.Split(list, ",") {
+		if h = strings.TrimSpace(h); h != "" {
+			hosts = append(hosts, h)
+		}
+	}
+	return hosts
+}
+
+// readHostsFile reads targets, one or more per line separated by
+// commas. Blank lines and # comments are ignored.
+func readHostsFile(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var hosts []string
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		line, _, _ := strings.Cut(sc.Text(), "#")
+		hosts = append(hosts, splitHosts(line)...)
+	}
+	return hosts, sc.Err()
+}
+
+// hostEntry is a host to ping and, if the user gave one, a friendly
+// name to show instead
+type hostEntry struct {
+	name string
+	host string
+}
+
+// readTargetsFile reads "name,host" pairs, one per line; a line with just
+// a host leaves it unnamed. Blank lines and # comments are ignored.
+func readTargetsFile(path string) ([]hostEntry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var entries []hostEntry
+	sc := bufio.NewScanner(f)
+	for lineNo := 1; sc.Scan(); lineNo++ {
+		line, _, _ := strings.Cut(sc.Text(), "#")
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+
+		fields := strings.Split(line, ",")
+		for i := range fields {
+			fields[i] = strings.TrimSpace(fields[i])
+		}
+		switch {
+		case len(fields) == 1:
+			entries = append(entries, hostEntry{host: fields[0]})
+		case len(fields) == 2 && fields[1] != "":
+			entries = append(entries, hostEntry{name: fields[0], host: fields[1]})
+		default:
+			return nil, fmt.Errorf("%s:%d: want name,host", path, lineNo)
+		}
+	}
+	return entries, sc.Err()
+}
+
+// pingFleet pings every target at once, fping style. On a terminal a
+// table of loss and RTT per host is redrawn in place as replies arrive;
+// otherwise only the final table is printed.
+func pingFleet(ctx context.Context, targets []*target, count int, interval, timeout time.Duration) {
+	fmt.Printf("PING %d hosts\n", len(targets))
+	fmt.Println("─────────────────────────────────")
+
+	var wg sync.WaitGroup
+	for _, t := range targets {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			t.run(ctx, count, interval, timeout, nil)
+		}()
+	}
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	lines := 0
+	if isTerminal(os.Stdout) {
+		ticker := time.NewTicker(tableRefresh)
+		defer ticker.Stop()
+	loop:
+		for {
+			select {
+			case <-done:
+				break loop
+			case <-ticker.C:
+				lines = redrawTable(os.Stdout, targets, lines)
+			}
+		}
+	} else {
+		<-done
+	}
+
+	redrawTable(os.Stdout, targets, lines)
+	fmt.Println("─────────────────────────────────")
+}
+
+// redrawTable moves the cursor up over the previous table, if any, and
+// prints the current one. Columns have fixed widths so each row fully
+// overwrites the last. It returns the number of lines written.
+func redrawTable(w io.Writer, targets []*target, prevLines int) int {
+	if prevLines > 0 {
+		fmt.Fprintf(w, "\033[%dA", prevLines)
+	}
+
+	hostWidth, addrWidth := len("HOST"), len("ADDRESS")
+	for _, t := range targets {
+		hostWidth = max(hostWidth, len(t.label()))
+		addrWidth = max(addrWidth, len(t.addr.String()))
+	}
+
+	fmt.Fprintf(w, "%-*s  %-*s  %6s %6s %6s  %8s %8s %8s %8s\n",
+		hostWidth, "HOST", addrWidth, "ADDRESS", "SENT", "RECV", "LOSS", "LAST", "MIN", "AVG", "MAX")
+	for _, t := range targets {
+		r := t.stats()
+		fmt.Fprintf(w, "%-*s  %-*s  %6d %6d %5.1f%%  %8s %8s %8s %8s\n",
+			hostWidth, t.label(), addrWidth, t.addr, r.PacketsSent, r.PacketsRecv, r.Loss(),
+			formatRTT(r.LastRTT, r.PacketsRecv), formatRTT(r.MinRTT, r.PacketsRecv),
+			formatRTT(r.AvgRTT, r.PacketsRecv), formatRTT(r.MaxRTT, r.PacketsRecv))
+	}
+	return len(targets) + 1
+}
+
+// formatRTT prints an RTT in milliseconds, or "-" before the first reply
+func formatRTT(rtt time.Duration, received int) string {
+	if received == 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%.2fms", float64(rtt.Microseconds())/1000)
+}
+
+func isTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,27 @@
+package main
+
+// Exit codes, so health-check scripts can tell outcomes apart. 2 is what
+// the flag package uses for bad arguments.
+const (
+	exitOK      = 0
+	exitError   = 1 // couldn't ping at all, e.g. no ICMP socket
+	exitLossy   = 3 // -fail-loss: some host lost more than the threshold
+	exitNoReply = 4 // -fail-loss: some host never answered
+)
+
+// lossExitCode checks every target's loss against threshold, a
+// percentage. A host that never answered is worse than a lossy one, so
+// it wins when both occur.
+func lossExitCode(targets []*target, threshold float64) int {
+	code := exitOK
+	for _, t := range targets {
+		r := t.stats()
+		switch {
+		case r.PacketsSent > 0 && r.PacketsRecv == 0:
+			return exitNoReply
+		case r.Loss() > threshold:
+			code = exitLossy
+		}
+	}
+	return code
+}
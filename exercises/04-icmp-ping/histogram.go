@@ -0,0 +1,122 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// rttBuckets are the histogram's upper bounds. They grow roughly
+// logarithmically so loopback, LAN and intercontinental RTTs all spread
+// over a few buckets; a last, unbounded bucket catches the rest.
+var rttBuckets = [...]time.Duration{
+	100 * time.Microsecond,
+	250 * time.Microsecond,
+	500 * time.Microsecond,
+	1 * time.Millisecond,
+	2500 * time.Microsecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	25 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	250 * time.Millisecond,
+	500 * time.Millisecond,
+	1 * time.Second,
+}
+
+// sparkWidth is how many recent RTTs the sparkline shows
+const sparkWidth = 60
+
+// Widest histogram bar, in characters
+const histogramBarWidth = 40
+
+// rttHistogram counts RTTs per bucket and keeps the most recent ones for
+// the sparkline. It is made of arrays so copying a PingResult copies it.
+type rttHistogram struct {
+	counts [len(rttBuckets) + 1]int
+	recent [sparkWidth]time.Duration
+	total  int
+}
+
+func (h *rttHistogram) add(rtt time.Duration) {
+	i := 0
+	for i < len(rttBuckets) && rtt > rttBuckets[i] {
+		i++
+	}
+	h.counts[i]++
+
+	h.recent[h.total%sparkWidth] = rtt
+	h.total++
+}
+
+// recentRTTs returns the RTTs the sparkline covers, oldest first
+func (h *rttHistogram) recentRTTs() []time.Duration {
+	if h.total <= sparkWidth {
+		return h.recent[:h.total]
+	}
+	start := h.total % sparkWidth
+	return append(h.recent[start:], h.recent[:start]...)
+}
+
+// sparkline draws RTTs as a row of block characters, scaled from the
+// lowest to the highest value shown
+func sparkline(rtts []time.Duration) string {
+	const blocks = "▁▂▃▄▅▆▇█"
+	levels := []rune(blocks)
+	if len(rtts) == 0 {
+		return ""
+	}
+
+	lo, hi := rtts[0], rtts[0]
+	for _, rtt := range rtts {
+		lo, hi = min(lo, rtt), max(hi, rtt)
+	}
+
+	var b strings.Builder
+	for _, rtt := range rtts {
+		level := 0
+		if hi > lo {
+			level = int(float64(rtt-lo) / float64(hi-lo) * float64(len(levels)-1))
+		}
+		b.WriteRune(levels[level])
+	}
+	return b.String()
+}
+
+// write prints the buckets from the first to the last non-empty one as
+// a bar chart, e.g.
+//
+//	≤ 0.25ms  ██████████████████████                    12
+func (h *rttHistogram) write(w io.Writer) {
+	first, last, most := -1, -1, 0
+	for i, n := range h.counts {
+		if n == 0 {
+			continue
+		}
+		if first < 0 {
+			first = i
+		}
+		last = i
+		most = max(most, n)
+	}
+	if first < 0 {
+		return
+	}
+
+	for i := first; i <= last; i++ {
+		label := "> " + formatBound(rttBuckets[len(rttBuckets)-1])
+		if i < len(rttBuckets) {
+			label = "≤ " + formatBound(rttBuckets[i])
+		}
+		bar := strings.Repeat("█", h.counts[i]*histogramBarWidth/most)
+		fmt.Fprintf(w, "  %9s  %-*s %d\n", label, histogramBarWidth, bar, h.counts[i])
+	}
+}
+
+// formatBound prints a bucket bound in milliseconds, without trailing
+// zeros
+func formatBound(d time.Duration) string {
+	return fmt.Sprintf("%gms", float64(d.Microseconds())/1000)
+}
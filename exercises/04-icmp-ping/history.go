@@ -0,0 +1,244 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/channyeintun/network-exercises/pkg/ping"
+)
+
+// maxHistorySamples bounds each target's ring, about 5 MB, whatever
+// -history and -interval ask for
+const maxHistorySamples = 100_000
+
+// historySaveInterval is how often -history-file is rewritten, so a
+// crash loses at most this much
+const historySaveInterval = time.Minute
+
+// historySample is one probe in a target's history. RTT is null for a
+// lost ping, which graphing libraries draw as a gap.
+type historySample struct {
+	Time  time.Time `json:"time"`
+	RTTMs *float64  `json:"rtt_ms"`
+	Error string    `json:"error,omitempty"`
+}
+
+func newHistorySample(pkt ping.Packet) historySample {
+	s := historySample{Time: pkt.SentAt}
+	if pkt.Err != nil {
+		s.Error = pkt.Err.Error()
+	} else {
+		ms := float64(pkt.RTT.Microseconds()) / 1000
+		s.RTTMs = &ms
+	}
+	return s
+}
+
+// history is a ring of one target's most recent samples
+type history struct {
+	mu      sync.Mutex
+	host    string
+	address string
+	samples []historySample
+	next    int // where the next sample goes; the oldest once full
+	full    bool
+}
+
+func newHistory(size int) *history {
+	return &history{samples: make([]historySample, size)}
+}
+
+// add records a sample, overwriting the oldest once the ring is full
+func (h *history) add(s historySample) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.samples[h.next] = s
+	h.next = (h.next + 1) % len(h.samples)
+	if h.next == 0 {
+		h.full = true
+	}
+}
+
+// since returns the samples sent at or after t, oldest first
+func (h *history) since(t time.Time) []historySample {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	recent := []historySample{} // [] rather than null in JSON
+	for _, s := range h.samples[:h.count()] {
+		if !s.Time.Before(t) {
+			recent = append(recent, s)
+		}
+	}
+	// A lost ping is recorded when it times out, after later pings'
+	// replies, so the ring is only roughly in order
+	slices.SortStableFunc(recent, func(a, b historySample) int {
+		return a.Time.Compare(b.Time)
+	})
+	return recent
+}
+
+// count is how many slots of the ring hold samples
+func (h *history) count() int {
+	if h.full {
+		return len(h.samples)
+	}
+	return h.next
+}
+
+// historyStore holds the history of every target the daemon pings, by
+// name, and serves it as JSON for graphing
+type historyStore struct {
+	keep time.Duration
+	size int
+
+	mu      sync.Mutex
+	targets map[string]*history
+	saved   map[string][]historySample // read from disk, until the target starts again
+}
+
+// newHistoryStore keeps keep worth of pings sent every interval
+func newHistoryStore(keep, interval time.Duration) *historyStore {
+	size := int(keep/interval) + 1
+	if size > maxHistorySamples {
+		log.Printf("⚠️  -history %v at -interval %v is more than %d pings; keeping the last %d per target", keep, interval, maxHistorySamples, maxHistorySamples)
+		size = maxHistorySamples
+	}
+	return &historyStore{
+		keep:    keep,
+		size:    size,
+		targets: make(map[string]*history),
+		saved:   make(map[string][]historySample),
+	}
+}
+
+// track returns the history to record t's pings in, picking up where a
+// saved history of the same name left off
+func (s *historyStore) track(t *target) *history {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	name := t.label()
+	if h, ok := s.targets[name]; ok {
+		return h
+	}
+	h := newHistory(s.size)
+	h.host, h.address = t.host, t.addr.String()
+	cutoff := time.Now().Add(-s.keep)
+	for _, sample := range s.saved[name] {
+		if !sample.Time.Before(cutoff) {
+			h.add(sample)
+		}
+	}
+	delete(s.saved, name)
+	s.targets[name] = h
+	return h
+}
+
+// forget drops the history of a target that is no longer pinged
+func (s *historyStore) forget(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.targets, name)
+}
+
+// load reads histories saved by save. A missing file is a first run,
+// not an error.
+func (s *historyStore) load(path string) error {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return json.Unmarshal(data, &s.saved)
+}
+
+// save atomically writes every tracked history to path
+func (s *historyStore) save(path string) error {
+	cutoff := time.Now().Add(-s.keep)
+	s.mu.Lock()
+	all := make(map[string][]historySample, len(s.targets))
+	for name, h := range s.targets {
+		all[name] = h.since(cutoff)
+	}
+	s.mu.Unlock()
+
+	data, err := json.Marshal(all)
+	if err != nil {
+		return err
+	}
+
+	// Write to a temp file and rename so a crash never leaves a torn file
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// register adds the query API to mux:
+//
+//	GET /api/targets                        names of the targets
+//	GET /api/targets/{name}/history?since=  samples, by default all kept
+func (s *historyStore) register(mux *http.ServeMux) {
+	mux.HandleFunc("GET /api/targets", s.handleTargets)
+	mux.HandleFunc("GET /api/targets/{name}/history", s.handleHistory)
+}
+
+func (s *historyStore) handleTargets(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	names := make([]string, 0, len(s.targets))
+	for name := range s.targets {
+		names = append(names, name)
+	}
+	s.mu.Unlock()
+
+	slices.Sort(names)
+	writeJSON(w, map[string]any{"targets": names})
+}
+
+func (s *historyStore) handleHistory(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	s.mu.Lock()
+	h, ok := s.targets[name]
+	s.mu.Unlock()
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	window := s.keep
+	if since := r.URL.Query().Get("since"); since != "" {
+		d, err := time.ParseDuration(since)
+		if err != nil || d <= 0 {
+			http.Error(w, "since wants a duration like 15m", http.StatusBadRequest)
+			return
+		}
+		window = min(d, s.keep)
+	}
+
+	writeJSON(w, map[string]any{
+		"name":    name,
+		"target":  h.host,
+		"address": h.address,
+		"samples": h.since(time.Now().Add(-window)),
+	})
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("API response: %v", err)
+	}
+}
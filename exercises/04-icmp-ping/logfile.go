@@ -0,0 +1,146 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/channyeintun/network-exercises/pkg/ping"
+)
+
+// probeLog appends every probe to a CSV file (-log-file), the artifact a
+// soak test leaves behind whatever stdout shows. The file is rotated at
+// local midnight and whenever it would grow past maxSize, so each file
+// covers at most a day: pings.csv becomes pings-2026-01-02.csv, then
+// pings-2026-01-02.1.csv, and so on. Every file starts with the header,
+// so each can be loaded on its own.
+type probeLog struct {
+	mu      sync.Mutex
+	path    string
+	maxSize int64 // bytes; 0 for no limit
+	f       *os.File
+	size    int64
+	day     string // local date of the probes in f, for its rotated name
+	empty   bool   // f holds just the header
+	failed  bool   // a write failed; logged once, not per probe
+}
+
+// openProbeLog opens path for appending, picking up an existing file's
+// size and day so a restart carries on where the last run left off
+func openProbeLog(path string, maxSize int64) (*probeLog, error) {
+	l := &probeLog{path: path, maxSize: maxSize}
+	if err := l.open(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+func (l *probeLog) open() error {
+	f, err := os.OpenFile(l.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	l.f, l.size = f, fi.Size()
+	l.day = fi.ModTime().Format(time.DateOnly)
+	l.empty = l.size == 0
+	if l.empty {
+		l.day = time.Now().Format(time.DateOnly)
+		return l.write(csvLine(probeCSVHeader))
+	}
+	return nil
+}
+
+// probe logs one outcome
+func (l *probeLog) probe(t *target, o ping.Packet) {
+	line := csvLine(newProbeRecord(t, o).csvRow())
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	err := l.rotate(time.Now(), len(line))
+	if err == nil {
+		err = l.write(line)
+		l.empty = false
+	}
+	if err != nil && !l.failed {
+		log.Printf("❌ -log-file: %v; further probes may be missing", err)
+	}
+	l.failed = err != nil
+}
+
+// rotate starts a new file if the day has changed or the next n bytes
+// would overflow this one
+func (l *probeLog) rotate(now time.Time, n int) error {
+	if l.f == nil {
+		return l.open() // a previous rotation failed halfway
+	}
+	today := now.Format(time.DateOnly)
+	full := l.maxSize > 0 && l.size+int64(n) > l.maxSize
+	if today == l.day && !full {
+		return nil
+	}
+	// A file of just the header gains nothing from rotating
+	if l.empty {
+		l.day = today
+		return nil
+	}
+
+	if err := l.f.Close(); err != nil {
+		return err
+	}
+	l.f = nil
+	if err := os.Rename(l.path, l.rotatedName()); err != nil {
+		return err
+	}
+	return l.open()
+}
+
+// rotatedName returns the first free name for the current file, dated
+// by the probes in it
+func (l *probeLog) rotatedName() string {
+	ext := filepath.Ext(l.path)
+	base := strings.TrimSuffix(l.path, ext)
+	name := fmt.Sprintf("%s-%s%s", base, l.day, ext)
+	for i := 1; ; i++ {
+		if _, err := os.Stat(name); errors.Is(err, os.ErrNotExist) {
+			return name
+		}
+		name = fmt.Sprintf("%s-%s.%d%s", base, l.day, i, ext)
+	}
+}
+
+func (l *probeLog) write(line []byte) error {
+	n, err := l.f.Write(line)
+	l.size += int64(n)
+	return err
+}
+
+func (l *probeLog) close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.f == nil {
+		return nil
+	}
+	return l.f.Close()
+}
+
+// csvLine encodes one CSV record, quoting as needed
+func csvLine(fields []string) []byte {
+	var b bytes.Buffer
+	w := csv.NewWriter(&b)
+	w.Write(fields)
+	w.Flush()
+	return b.Bytes()
+}
@@ -5,165 +5,891 @@
 // - Work with ICMP protocol
 // - Parse network addresses
 // - Measure round-trip time
-// - Handle privileged operations (requires root/sudo)
+// - Handle privileged operations (raw vs. datagram ICMP sockets)
 //
-// Run: sudo go run main.go -host 8.8.8.8 -count 4
+// Run: go run main.go -host 8.8.8.8 -count 4
+// Or:  sudo go run main.go -host 8.8.8.8    (raw socket)
+// Or:  go run main.go -host google.com -6    (ICMPv6)
+// Or:  go run main.go -host 1.1.1.1 -t       (until Ctrl+C, like system ping)
+// Or:  go run main.go -hosts 8.8.8.8,1.1.1.1,9.9.9.9 -count 10
+// Or:  go run main.go -hosts-file fleet.txt -t   (live table per host)
+// Or:  sudo go run main.go -host 8.8.8.8 -traceroute -max-hops 20
+// Or:  go run main.go -host 8.8.8.8 -ttl 3     (expires in transit: "time exceeded")
+// Or:  go run main.go -host 8.8.8.8 -size 1400 -pattern ff00
+// Or:  go run main.go -host 8.8.8.8 -sweep 0:8000:500   (RTT vs. size; >MTU fragments)
+// Or:  go run main.go -sweep 192.168.1.0/24   (which hosts of the LAN are up)
+// Or:  go run main.go -host 192.168.1.1 -flood -count 1000   (dots left = lost)
+// Or:  go run main.go -host 8.8.8.8 -adaptive -count 50   (next ping once the last is answered)
+// Or:  go run main.go -hosts 8.8.8.8,1.1.1.1 -t -output json | jq .
+// Or:  go run main.go -host 8.8.8.8 -t -D -timestamp-format rfc3339
+// Or:  go run main.go -host 10.0.0.1 -count 20 -fail-loss 10 || alert   (exit 3/4)
+// Or:  go run main.go -host 8.8.8.8 -deadline 30s   (ping for 30s, like ping -w)
+// Or:  go run main.go -host 10.0.0.1 -dscp 46   (EF, to test a QoS path; or -tos 0xb8)
+// Or:  go run main.go -host example.com -mode tcp:443   (no ICMP: time TCP connects)
+// Or:  go run main.go -host example.com -mode https     (time to first response byte)
+// Or:  go run main.go -hosts 8.8.8.8,1.1.1.1 -daemon -listen :9374   (Prometheus /metrics)
+// Or:  go run main.go -targets-file targets.txt -daemon   (name,host lines; kill -HUP to reload)
+// Or:  go run main.go -hosts 8.8.8.8 -daemon -history 24h -history-file history.json   (GET /api/targets/8.8.8.8/history)
+// Or:  go run main.go -hosts-file fleet.txt -daemon -alert-webhook https://hooks.example.com/ping
+// Or:  go run main.go -host 192.168.1.1 -t -notify bell   (or -notify 'command:notify-send "$PING_TEXT"')
+// Or:  go run main.go -hosts 8.8.8.8,1.1.1.1 -t -tui   (live RTT graph per host)
+// Or:  go run main.go -hosts-file fleet.txt -t -log-file pings.csv   (soak test; rotated daily and at 100 MB)
+// Or:  go run main.go -host 8.8.8.8 -n   (numeric: no reverse DNS of replying addresses)
+// Or:  go run main.go -host 8.8.8.8 -I eth1   (or -source 10.0.0.5, on multi-homed hosts)
+//
+// Without root this uses an ICMP datagram socket. macOS allows those for
+// everyone; Linux only for groups in net.ipv4.ping_group_range:
+//
+//	sudo sysctl -w net.ipv4.ping_group_range="0 2147483647"
 package main
 
 import (
+	"context"
+	"encoding/hex"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"log"
 	"net"
 	"os"
+	"os/signal"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
 	"time"
 
-	"golang.org/x/net/icmp"
-	"golang.org/x/net/ipv4"
-)
-
-const (
-	protocolICMP = 1
+	"github.com/channyeintun/network-exercises/pkg/netif"
+	"github.com/channyeintun/network-exercises/pkg/ping"
+	"github.com/channyeintun/network-exercises/pkg/scanner"
 )
 
-// PingResult holds statistics for a ping session
+// PingResult holds statistics for a ping session, plus the RTT
+// histogram the summaries draw
 type PingResult struct {
-	Host         string
-	PacketsSent  int
-	PacketsRecv  int
-	MinRTT       time.Duration
-	MaxRTT       time.Duration
-	AvgRTT       time.Duration
-	TotalRTT     time.Duration
+	ping.Statistics
+	Host string
+	hist rttHistogram
+}
+
+// target is one host being pinged and its running statistics
+type target struct {
+	name     string // from -targets-file, "" if none
+	host     string
+	addr     *net.IPAddr
+	mode     probeMode
+	p        ping.Prober
+	payloads [][]byte          // used in turn, one per ping
+	adaptive bool              // wait for each reply before the next ping, like ping -A
+	onSend   func()            // called as each request goes out, e.g. for -flood
+	onRecv   func(ping.Packet) // called for each outcome, e.g. for alerts
+	probeLog *probeLog         // -log-file, if set
+
+	mu     sync.Mutex
+	pinger *ping.Pinger // set once run starts
+	hist   rttHistogram
+}
+
+// label is how tables and metrics name the target
+func (t *target) label() string {
+	if t.name != "" {
+		return t.name
+	}
+	return t.host
+}
+
+// entry is the target as listed in -targets-file
+func (t *target) entry() hostEntry {
+	return hostEntry{name: t.name, host: t.host}
+}
+
+// stats returns a copy of the statistics so far
+func (t *target) stats() PingResult {
+	t.mu.Lock()
+	pinger, hist := t.pinger, t.hist
+	t.mu.Unlock()
+
+	result := PingResult{Host: t.host, hist: hist}
+	if pinger != nil {
+		result.Statistics = pinger.Statistics()
+	}
+	return result
 }
 
 func main() {
 	// Parse flags
 	host := flag.String("host", "8.8.8.8", "Host to ping")
-	count := flag.Int("count", 4, "Number of pings to send")
+	hostList := flag.String("hosts", "", "Ping several hosts at once (comma-separated)")
+	hostsFile := flag.String("hosts-file", "", "File of hosts to ping at once, one per line")
+	targetsFile := flag.String("targets-file", "", "File of name,host lines to ping at once, shown by name; -daemon re-reads it on SIGHUP")
+	count := flag.Int("count", 4, "Number of pings to send (0 = until interrupted)")
+	forever := flag.Bool("t", false, "Ping until interrupted, same as -count 0")
+	deadline := flag.Duration("deadline", 0, "Stop the whole session after this long, whatever -count says")
 	timeout := flag.Duration("timeout", 2*time.Second, "Timeout per ping")
-	interval := flag.Duration("interval", 1*time.Second, "Interval between pings")
+	interval := flag.Duration("interval", 1*time.Second, "Interval between pings, e.g. 10ms (capped at 100 pings/s)")
+	stamp := flag.Bool("D", false, "Prefix each reply line with a timestamp, like ping -D")
+	stampTimeFormat := flag.String("timestamp-format", "unix", "Timestamp format for -D: unix or rfc3339")
+	outputFormat := flag.String("output", "text", "Output format: text, json (one object per line), csv")
+	failLoss := flag.Float64("fail-loss", -1, "Exit 3 if any host's loss exceeds this percentage, 4 if one never answered")
+	modeSpec := flag.String("mode", "icmp", "Probe with icmp, tcp:PORT (connect time) or http[s][:PORT] (time to first byte)")
+	daemon := flag.Bool("daemon", false, "Ping until stopped and expose loss and RTT metrics for Prometheus")
+	listenAddr := flag.String("listen", ":9374", "Address -daemon serves /metrics and /api/targets on")
+	historyKeep := flag.Duration("history", time.Hour, "How much per-target history -daemon serves at /api/targets/{name}/history")
+	historyFile := flag.String("history-file", "", "File -daemon keeps its history in across restarts")
+	alertWebhook := flag.String("alert-webhook", "", "POST a JSON event here when a host goes down or comes back up (needs -t or -daemon)")
+	notifySpec := flag.String("notify", "", `Ring the terminal "bell", or run "command:CMD", when a host goes down or comes back up`)
+	downAfter := flag.Int("down-after", 3, "Consecutive losses that mark a host down for -alert-webhook and -notify")
+	upAfter := flag.Int("up-after", 2, "Consecutive replies that mark a host up again for -alert-webhook and -notify")
+	adaptive := flag.Bool("adaptive", false, "Send each ping once the last is answered, but no faster than -interval (default 200ms), like ping -A")
+	logFile := flag.String("log-file", "", "Append every probe to this CSV file, rotated daily and by -log-max-size")
+	logMaxSize := flag.Int64("log-max-size", 100, "Rotate -log-file once it reaches this many MB (0 = only daily)")
+	tui := flag.Bool("tui", false, "Show a live, in-place RTT graph and loss markers per host instead of a line per reply")
+	flood := flag.Bool("flood", false, "Ping at the rate cap, printing . per request and erasing it per reply")
+	numeric := flag.Bool("n", false, "Numeric output only: don't look up names of replying addresses")
+	ipv4Only := flag.Bool("4", false, "Use IPv4 only (resolve A records)")
+	ipv6Only := flag.Bool("6", false, "Use IPv6 only (resolve AAAA records)")
+	iface := flag.String("I", "", "Send pings from this interface's address, e.g. eth0")
+	source := flag.String("source", "", "Send pings from this local address, e.g. 10.0.0.5")
+	ttl := flag.Int("ttl", 0, "TTL (IPv6 hop limit) of outgoing pings; 0 uses the system default")
+	tos := flag.Int("tos", 0, "TOS byte (IPv6 traffic class) of outgoing pings, e.g. 0xb8")
+	dscp := flag.Int("dscp", 0, "DSCP of outgoing pings, e.g. 46 for EF; sets the top six bits of -tos")
+	trace := flag.Bool("traceroute", false, "Trace the route to -host instead of pinging it")
+	size := flag.Int("size", 56, "Payload bytes per ping")
+	pattern := flag.String("pattern", "", "Fill the payload with these hex bytes, e.g. ff00, after the 16-byte nonce and timestamp every ICMP ping starts with (default: a text message)")
+	sweep := flag.String("sweep", "", "Ping once per payload size min:max:step instead of -size, e.g. 0:1500:100; or every address of a subnet, e.g. 192.168.1.0/24")
+	maxHops := flag.Int("max-hops", 30, "Give up -traceroute after this many hops")
+	hopTimeout := flag.Duration("hop-timeout", time.Second, "How long -traceroute waits for each probe")
 	flag.Parse()
 
-	// Check for root privileges
-	if os.Geteuid() != 0 {
-		log.Println("⚠️  Warning: ICMP requires root privileges")
-		log.Println("   Run with: sudo go run main.go")
-		os.Exit(1)
+	if *ipv4Only && *ipv6Only {
+		log.Fatal("-4 and -6 are mutually exclusive")
+	}
+	// -sweep takes payload sizes or, recognisably different, a subnet
+	subnet := ""
+	if strings.Contains(*sweep, "/") {
+		subnet, *sweep = *sweep, ""
 	}
 
-	// Resolve host
-	dst, err := net.ResolveIPAddr("ip4", *host)
+	mode, err := parseMode(*modeSpec)
 	if err != nil {
-		log.Fatalf("Failed to resolve %s: %v", *host, err)
+		log.Fatalf("Invalid -mode: %v", err)
+	}
+	if !mode.icmp() {
+		for _, name := range []string{"traceroute", "ttl", "tos", "dscp", "size", "pattern"} {
+			if flagSet(name) {
+				log.Fatalf("-%s only applies to ICMP; drop it or -mode", name)
+			}
+		}
+		if *sweep != "" {
+			log.Fatal("-sweep of payload sizes only applies to ICMP; drop it or -mode")
+		}
+	}
+	if *iface != "" && *source != "" {
+		log.Fatal("-I and -source are mutually exclusive")
+	}
+	var sourceIP net.IP
+	if *source != "" {
+		if sourceIP = net.ParseIP(*source); sourceIP == nil {
+			log.Fatalf("Invalid -source %q: want an IP address", *source)
+		}
+	}
+	if *count < 0 {
+		log.Fatal("-count must not be negative")
+	}
+	if *deadline < 0 {
+		log.Fatal("-deadline must not be negative")
+	}
+	// Like ping -w, a deadline alone means ping until it passes
+	if *forever || *daemon || (*deadline > 0 && !flagSet("count")) {
+		*count = 0
+	} else if subnet != "" && !flagSet("count") {
+		*count = 1 // one ping each maps a subnet
+	}
+	if *stampTimeFormat != "unix" && *stampTimeFormat != "rfc3339" {
+		log.Fatalf("Unknown -timestamp-format %q (want unix or rfc3339)", *stampTimeFormat)
+	}
+	if *stamp && (*flood || *trace || *hostList != "" || *hostsFile != "" || *targetsFile != "" || *outputFormat != "text") {
+		log.Fatal("-D timestamps the reply lines of a plain single-host ping")
+	}
+	if !validFormat(*outputFormat) {
+		log.Fatalf("Unknown output format %q (want text, json or csv)", *outputFormat)
+	}
+	if *outputFormat != "text" && (*flood || *trace) {
+		log.Fatal("-output applies to plain pings, not -flood or -traceroute")
+	}
+	if *flood && (*trace || *hostList != "" || *hostsFile != "" || *targetsFile != "") {
+		log.Fatal("-flood pings a single -host")
+	}
+	if subnet != "" && (flagSet("host") || *hostList != "" || *hostsFile != "" || *targetsFile != "" || *trace || *flood || *daemon || *stamp) {
+		log.Fatal("-sweep of a subnet pings its addresses instead of -host, -hosts, -hosts-file or -targets-file, and not with -traceroute, -flood, -daemon or -D")
+	}
+	if *daemon && (*flood || *trace || *stamp || *outputFormat != "text" || *failLoss >= 0 || *sweep != "") {
+		log.Fatal("-daemon only pings and serves metrics; drop -flood, -traceroute, -D, -output, -fail-loss and -sweep")
+	}
+	if (flagSet("history") || *historyFile != "") && !*daemon {
+		log.Fatal("-history and -history-file apply to -daemon")
+	}
+	if *historyKeep <= 0 {
+		log.Fatal("-history must be positive")
+	}
+	if *daemon && flagSet("count") {
+		log.Fatal("-daemon pings until stopped; drop -count")
+	}
+	if *tui && (*flood || *trace || *daemon || *stamp || *outputFormat != "text" || subnet != "") {
+		log.Fatal("-tui draws plain pings; drop -flood, -traceroute, -daemon, -D, -output and subnet -sweep")
+	}
+	if *tui && !isTerminal(os.Stdout) {
+		log.Fatal("-tui needs a terminal")
+	}
+	if *logFile != "" && *trace {
+		log.Fatal("-log-file logs pings, not -traceroute hops")
+	}
+	if *logMaxSize < 0 {
+		log.Fatal("-log-max-size must not be negative")
+	}
+	if *downAfter < 1 || *upAfter < 1 {
+		log.Fatal("-down-after and -up-after must be at least 1")
+	}
+	if *failLoss > 100 {
+		log.Fatal("-fail-loss is a percentage, at most 100")
+	}
+	if *failLoss >= 0 && *trace {
+		log.Fatal("-fail-loss doesn't apply to -traceroute")
+	}
+	if *adaptive && *trace {
+		log.Fatal("-traceroute paces its own probes; drop -adaptive")
+	}
+	if *trace && (*hostList != "" || *hostsFile != "" || *targetsFile != "") {
+		log.Fatal("-traceroute traces a single -host")
+	}
+	if *ttl < 0 || *ttl > 255 {
+		log.Fatal("-ttl must be between 0 and 255")
+	}
+	if *trace && *ttl != 0 {
+		log.Fatal("-traceroute sets the TTL of its probes itself; drop -ttl")
+	}
+	if flagSet("tos") && flagSet("dscp") {
+		log.Fatal("-tos and -dscp are mutually exclusive")
+	}
+	if *tos < 0 || *tos > 255 {
+		log.Fatal("-tos must be between 0 and 255")
+	}
+	if *dscp < 0 || *dscp > 63 {
+		log.Fatal("-dscp must be between 0 and 63")
+	}
+	if *dscp > 0 {
+		*tos = *dscp << 2
+	}
+	if *size < 0 || *size > maxPayload {
+		log.Fatalf("-size must be between 0 and %d", maxPayload)
 	}
 
-	fmt.Printf("PING %s (%s)\n", *host, dst.IP)
-	fmt.Println("─────────────────────────────────")
+	fill := ping.DefaultPayload
+	if *pattern != "" {
+		decoded, err := hex.DecodeString(*pattern)
+		if err != nil || len(decoded) == 0 {
+			log.Fatalf("Invalid -pattern %q: want hex bytes like ff00", *pattern)
+		}
+		fill = decoded
+	}
+
+	// A sweep pings once per size, so it sets the count too
+	sizes := []int{*size}
+	if *sweep != "" {
+		var err error
+		if sizes, err = parseSweep(*sweep); err != nil {
+			log.Fatalf("Invalid -sweep: %v", err)
+		}
+		*count = len(sizes)
+	}
+	var payloads [][]byte
+	for _, n := range sizes {
+		payloads = append(payloads, ping.MakePayload(n, fill))
+	}
+	if !mode.icmp() {
+		payloads = [][]byte{nil} // nothing to carry
+	}
+
+	if *maxHops < 1 || *maxHops > 255 {
+		log.Fatal("-max-hops must be between 1 and 255")
+	}
+
+	hosts := []string{*host}
+	var entries []hostEntry
+	if subnet != "" {
+		if hosts, err = scanner.ExpandTargets(subnet); err != nil {
+			log.Fatalf("Invalid -sweep: %v", err)
+		}
+	} else if *hostList != "" || *hostsFile != "" || *targetsFile != "" {
+		hosts = splitHosts(*hostList)
+		if *hostsFile != "" {
+			fromFile, err := readHostsFile(*hostsFile)
+			if err != nil {
+				log.Fatalf("Failed to read hosts: %v", err)
+			}
+			hosts = append(hosts, fromFile...)
+		}
+		if *targetsFile != "" {
+			if entries, err = readTargetsFile(*targetsFile); err != nil {
+				log.Fatalf("Failed to read targets: %v", err)
+			}
+		}
+		if len(hosts)+len(entries) == 0 {
+			log.Fatal("No hosts given")
+		}
+	}
+	var given []hostEntry // on the command line, so a reload keeps them
+	for _, h := range hosts {
+		given = append(given, hostEntry{host: h})
+	}
+	entries = append(entries, given...)
+
+	// Resolve hosts. Plain "ip" lets resolveHost pick the family for
+	// dual-stack names.
+	network := "ip"
+	if *ipv4Only {
+		network = "ip4"
+	} else if *ipv6Only {
+		network = "ip6"
+	}
+	// A source address can only reach targets of its own IP version
+	if sourceIP != nil && network == "ip" {
+		network = "ip6"
+		if sourceIP.To4() != nil {
+			network = "ip4"
+		}
+	}
 
-	result := &PingResult{
-		Host:   *host,
-		MinRTT: time.Hour, // Start with large value
+	var probes *probeLog
+	if *logFile != "" {
+		if probes, err = openProbeLog(*logFile, *logMaxSize<<20); err != nil {
+			log.Fatalf("Failed to open -log-file: %v", err)
+		}
 	}
 
-	// Send pings
-	for i := 0; i < *count; i++ {
-		rtt, err := ping(dst, i+1, *timeout)
-		result.PacketsSent++
+	// Targets share one socket per ICMP version
+	conns := make(map[string]*ping.Conn)
+	defer func() {
+		for _, conn := range conns {
+			conn.Close()
+		}
+	}()
 
+	// newTarget resolves a host and readies its prober. Only resolving
+	// can fail; anything else is a setup problem that no host would get
+	// past, so it exits.
+	newTarget := func(e hostEntry) (*target, error) {
+		h := e.host
+		dst, err := resolveHost(h, network)
+		if err != nil {
+			return nil, err
+		}
+
+		family := "ip4"
+		if dst.IP.To4() == nil {
+			family = "ip6"
+		}
+		local, err := localAddr(family, *iface, sourceIP)
 		if err != nil {
-			fmt.Printf("Request timeout for seq %d\n", i+1)
+			log.Fatalf("Cannot ping %s: %v", h, err)
+		}
+
+		// TCP and HTTP probes need no special socket, just a dialer
+		var pr ping.Prober
+		if !mode.icmp() {
+			if pr, err = mode.prober(h, dst, local); err != nil {
+				log.Fatalf("Cannot probe %s: %v", h, err)
+			}
+		} else if conn, ok := conns[family]; ok {
+			pr = conn
 		} else {
-			result.PacketsRecv++
-			result.TotalRTT += rtt
+			conn, err := ping.Listen(family, local)
+			if err != nil {
+				log.Printf("⚠️  Cannot open an ICMP socket: %v", err)
+				log.Println(`   Allow unprivileged ping: sudo sysctl -w net.ipv4.ping_group_range="0 2147483647"`)
+				log.Println("   Or run with: sudo go run main.go")
+				log.Println("   Or measure TCP latency instead: -mode tcp:443")
+				os.Exit(exitError)
+			}
 
-			if rtt < result.MinRTT {
-				result.MinRTT = rtt
+			if !conn.Privileged() {
+				// Linux queues ICMP errors for ping sockets on the
+				// socket's error queue, where ReadFrom never sees them
+				if *trace && runtime.GOOS == "linux" {
+					log.Fatal("-traceroute needs a raw socket on Linux; run with sudo")
+				}
+				log.Printf("🔓 Using an unprivileged ICMP datagram socket (%s)", conn.Network())
+			}
+			if local != "" {
+				log.Printf("📍 Sending from %s", local)
+			}
+			if *ttl > 0 {
+				if err := conn.SetTTL(*ttl); err != nil {
+					log.Fatalf("Failed to set TTL: %v", err)
+				}
 			}
-			if rtt > result.MaxRTT {
-				result.MaxRTT = rtt
+			if *tos > 0 {
+				if err := conn.SetTOS(*tos); err != nil {
+					log.Fatalf("Failed to set TOS: %v", err)
+				}
 			}
+			conns[family] = conn
+			pr = conn
+		}
+
+		return &target{
+			name:     e.name,
+			host:     h,
+			addr:     dst,
+			mode:     mode,
+			p:        pr,
+			payloads: payloads,
+			adaptive: *adaptive,
+			probeLog: probes,
+		}, nil
+	}
 
-			fmt.Printf("Reply from %s: seq=%d time=%.2fms\n",
-				dst.IP, i+1, float64(rtt.Microseconds())/1000)
+	var targets []*target
+	for _, e := range entries {
+		t, err := newTarget(e)
+		if err != nil {
+			if len(entries) == 1 {
+				log.Fatalf("Failed to resolve %s: %v", e.host, err)
+			}
+			log.Printf("⚠️  Skipping %s: %v", e.host, err)
+			continue
+		}
+		targets = append(targets, t)
+	}
+	if len(targets) == 0 {
+		log.Fatal("None of the hosts resolved")
+	}
+	if *alertWebhook != "" && (*count != 0 || *trace) {
+		log.Fatal("-alert-webhook watches hosts continuously; add -t or -daemon")
+	}
+	var notify *notifier
+	if *notifySpec != "" {
+		if *trace {
+			log.Fatal("-notify watches pinged hosts, not -traceroute")
 		}
+		if notify, err = parseNotify(*notifySpec); err != nil {
+			log.Fatalf("Invalid -notify: %v", err)
+		}
+	}
 
-		// Wait between pings (except for last one)
-		if i < *count-1 {
-			time.Sleep(*interval)
+	// Keep the total rate, across all targets, under the cap. -flood
+	// runs at the cap unless -interval asks for something slower.
+	minInterval := time.Second * time.Duration(len(targets)) / maxPingsPerSecond
+	if *flood && !flagSet("interval") {
+		*interval = minInterval
+	} else if *adaptive && !flagSet("interval") {
+		*interval = adaptiveInterval
+	}
+	if *interval < minInterval {
+		log.Printf("⚠️  -interval %v would exceed %d pings/s; using %v", *interval, maxPingsPerSecond, minInterval)
+		*interval = minInterval
+	}
+
+	// Ctrl+C or the deadline ends the loop, abandons outstanding pings
+	// and falls through to the statistics
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if *deadline > 0 {
+		ctx, cancel = context.WithTimeout(ctx, *deadline)
+		defer cancel()
+	}
+
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		<-sigChan
+		cancel()
+	}()
+
+	// Webhook alerts and -notify both follow each target's up/down state
+	var alerts *alerter
+	var onChange []func(alertEvent)
+	if *alertWebhook != "" {
+		alerts = newAlerter(ctx, *alertWebhook)
+		onChange = append(onChange, alerts.send)
+	}
+	if notify != nil {
+		onChange = append(onChange, notify.notify)
+	}
+	watch := func(t *target) {
+		if len(onChange) > 0 {
+			watchState(t, *downAfter, *upAfter, onChange...)
 		}
 	}
+	for _, t := range targets {
+		watch(t)
+	}
+
+	// Names are looked up in the background, starting with the targets'
+	var ptr *ptrCache
+	if !*numeric {
+		ptr = newPTRCache()
+		for _, t := range targets {
+			ptr.name(t.addr.IP)
+		}
+	}
+
+	if *trace {
+		t := targets[0]
+		traceroute(ctx, t.p.(*ping.Conn), t.host, t.addr, *maxHops, *hopTimeout, ptr)
+		return
+	}
+
+	switch {
+	case *daemon:
+		opts := daemonOptions{
+			Listen:      *listenAddr,
+			Interval:    *interval,
+			Timeout:     *timeout,
+			History:     *historyKeep,
+			HistoryFile: *historyFile,
+		}
+		if *targetsFile != "" {
+			opts.Reload = func() ([]hostEntry, error) {
+				fromFile, err := readTargetsFile(*targetsFile)
+				return append(fromFile, given...), err
+			}
+			opts.NewTarget = func(e hostEntry) (*target, error) {
+				t, err := newTarget(e)
+				if err == nil {
+					watch(t)
+				}
+				return t, err
+			}
+		}
+		runDaemon(ctx, targets, opts)
+	case *tui:
+		runTUI(ctx, targets, *count, *interval, *timeout)
+	case *outputFormat != "text":
+		pingRecords(ctx, targets, *outputFormat, *count, *interval, *timeout)
+	case subnet != "":
+		pingSubnet(ctx, subnet, targets, *count, *interval, *timeout)
+	case len(targets) > 1:
+		pingFleet(ctx, targets, *count, *interval, *timeout)
+	case *flood:
+		pingFlood(ctx, targets[0], *count, *interval, *timeout)
+	default:
+		stampFormat := ""
+		if *stamp {
+			stampFormat = *stampTimeFormat
+		}
+		pingOne(ctx, targets[0], *count, *interval, *timeout, stampFormat, ptr)
+	}
+
+	if alerts != nil {
+		alerts.close()
+	}
+	if notify != nil {
+		notify.close()
+	}
+	if probes != nil {
+		probes.close()
+	}
+	if *failLoss >= 0 {
+		os.Exit(lossExitCode(targets, *failLoss))
+	}
+}
 
-	// Print statistics
+// pingOne pings a single target, printing a line per reply like system
+// ping. stampFormat, if set, prefixes each line with a timestamp (-D),
+// and ptr names the addresses replies come from once it knows them.
+func pingOne(ctx context.Context, t *target, count int, interval, timeout time.Duration, stampFormat string, ptr *ptrCache) {
+	printHeader(t)
 	fmt.Println("─────────────────────────────────")
-	fmt.Printf("\n--- %s ping statistics ---\n", *host)
 
-	lossPercent := float64(result.PacketsSent-result.PacketsRecv) / float64(result.PacketsSent) * 100
-	fmt.Printf("%d packets transmitted, %d received, %.1f%% packet loss\n",
-		result.PacketsSent, result.PacketsRecv, lossPercent)
+	// A continuous ping never reaches the summary on its own, so show
+	// how latency is trending every so often
+	if count == 0 {
+		go func() {
+			ticker := time.NewTicker(sparklineInterval)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case <-ticker.C:
+					result := t.stats()
+					recent := result.hist.recentRTTs()
+					fmt.Printf("📈 %s  (last %d)\n", sparkline(recent), len(recent))
+				}
+			}
+		}()
+	}
+
+	t.run(ctx, count, interval, timeout, func(o ping.Packet) {
+		prefix := ""
+		if stampFormat != "" {
+			prefix = timestamp(time.Now(), stampFormat) + " "
+		}
+
+		if errors.Is(o.Err, ping.ErrTimeout) {
+			fmt.Printf("%sRequest timeout for seq %d\n", prefix, o.Seq)
+			return
+		}
+		if o.Err != nil {
+			fmt.Printf("%sseq %d: %s\n", prefix, o.Seq, ptr.describeError(o.Err))
+			return
+		}
+
+		from := withName(t.addr.IP, ptr.name(t.addr.IP))
+		line := fmt.Sprintf("Reply from %s: bytes=%d seq=%d", from, o.Size, o.Seq)
+		if !t.mode.icmp() {
+			line = fmt.Sprintf("Reply from %s via %s: seq=%d", from, t.mode, o.Seq)
+		}
+		if o.TTL > 0 {
+			line += fmt.Sprintf(" ttl=%d", o.TTL)
+		}
+		fmt.Printf("%s%s time=%.2fms\n", prefix, line, float64(o.RTT.Microseconds())/1000)
+	})
+
+	printStats(t.stats())
+}
+
+// pingFlood pings a single target with the ping -f display
+func pingFlood(ctx context.Context, t *target, count int, interval, timeout time.Duration) {
+	printHeader(t)
+	fmt.Println("─────────────────────────────────")
+
+	d := &floodDisplay{w: os.Stdout}
+	t.onSend = d.sent
+	t.run(ctx, count, interval, timeout, d.report)
+	fmt.Println()
+	printStats(t.stats())
+}
+
+// pingRecords pings every target at once and writes a record per probe
+// to stdout as it happens, then a summary per target
+func pingRecords(ctx context.Context, targets []*target, format string, count int, interval, timeout time.Duration) {
+	pw := newProbeWriter(os.Stdout, format)
+
+	var wg sync.WaitGroup
+	for _, t := range targets {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			t.run(ctx, count, interval, timeout, func(o ping.Packet) {
+				pw.probe(t, o)
+			})
+		}()
+	}
+	wg.Wait()
+
+	for _, t := range targets {
+		pw.summary(t, t.stats())
+	}
+}
+
+// printHeader starts a single-target ping the way system ping does,
+// naming the mode when it isn't ICMP
+func printHeader(t *target) {
+	if t.mode.icmp() {
+		fmt.Printf("PING %s (%s)\n", t.host, t.addr)
+	} else {
+		fmt.Printf("PING %s (%s) via %s\n", t.host, t.addr, t.mode)
+	}
+}
+
+// timestamp formats t for -D: "[1700000000.123456]" as iputils prints
+// it, or RFC 3339 in local time with microseconds
+func timestamp(t time.Time, format string) string {
+	if format == "rfc3339" {
+		return "[" + t.Format("2006-01-02T15:04:05.000000Z07:00") + "]"
+	}
+	return fmt.Sprintf("[%d.%06d]", t.Unix(), t.Nanosecond()/1000)
+}
+
+// printStats prints the summary system ping ends with
+func printStats(result PingResult) {
+	fmt.Println("─────────────────────────────────")
+	fmt.Printf("\n--- %s ping statistics ---\n", result.Host)
+
+	// Like iputils' "+N errors", corrupted replies only show up if any
+	corrupted := ""
+	if result.PacketsCorrupted > 0 {
+		corrupted = fmt.Sprintf(", +%d corrupted", result.PacketsCorrupted)
+	}
+	fmt.Printf("%d packets transmitted, %d received%s, %.1f%% packet loss\n",
+		result.PacketsSent, result.PacketsRecv, corrupted, result.Loss())
 
 	if result.PacketsRecv > 0 {
-		result.AvgRTT = result.TotalRTT / time.Duration(result.PacketsRecv)
-		fmt.Printf("rtt min/avg/max = %.2f/%.2f/%.2f ms\n",
+		fmt.Printf("rtt min/avg/max/mdev = %.3f/%.3f/%.3f/%.3f ms, jitter %.3f ms\n",
 			float64(result.MinRTT.Microseconds())/1000,
 			float64(result.AvgRTT.Microseconds())/1000,
-			float64(result.MaxRTT.Microseconds())/1000)
+			float64(result.MaxRTT.Microseconds())/1000,
+			float64(result.MDev.Microseconds())/1000,
+			float64(result.Jitter.Microseconds())/1000)
+	}
+
+	if result.PacketsRecv > 1 {
+		fmt.Println("\nRTT distribution:")
+		result.hist.write(os.Stdout)
+
+		recent := result.hist.recentRTTs()
+		fmt.Printf("\nLast %d RTTs: %s\n", len(recent), sparkline(recent))
 	}
 }
 
-func ping(dst *net.IPAddr, seq int, timeout time.Duration) (time.Duration, error) {
-	// Create ICMP connection
-	conn, err := icmp.ListenPacket("ip4:icmp", "0.0.0.0")
-	if err != nil {
-		return 0, fmt.Errorf("listen error: %w", err)
-	}
-	defer conn.Close()
-
-	// Build ICMP echo request
-	msg := icmp.Message{
-		Type: ipv4.ICMPTypeEcho,
-		Code: 0,
-		Body: &icmp.Echo{
-			ID:   os.Getpid() & 0xffff,
-			Seq:  seq,
-			Data: []byte("PING from Go exercise!"),
+// run pings the target count times (0 = until ctx is done), one every
+// interval, recording the RTTs in the histogram as well. report, if set,
+// is called for each reply or failed ping.
+func (t *target) run(ctx context.Context, count int, interval, timeout time.Duration, report func(ping.Packet)) {
+	pinger := ping.New(t.addr, ping.Config{
+		Count:    count,
+		Interval: interval,
+		Adaptive: t.adaptive,
+		Timeout:  timeout,
+		Payloads: t.payloads,
+		Prober:   t.p,
+		OnSend: func(int) {
+			if t.onSend != nil {
+				t.onSend()
+			}
 		},
+		OnRecv: func(pkt ping.Packet) {
+			if pkt.Err == nil {
+				t.mu.Lock()
+				t.hist.add(pkt.RTT)
+				t.mu.Unlock()
+			}
+			if t.probeLog != nil {
+				t.probeLog.probe(t, pkt)
+			}
+			if t.onRecv != nil {
+				t.onRecv(pkt)
+			}
+			if report != nil {
+				report(pkt)
+			}
+		},
+	})
+
+	t.mu.Lock()
+	t.pinger = pinger
+	t.mu.Unlock()
+
+	// With a prober of our own, Run has no socket to fail to open
+	pinger.Run(ctx)
+}
+
+// How often a continuous ping prints its sparkline
+const sparklineInterval = 10 * time.Second
+
+// adaptiveInterval is the least time between -adaptive pings unless
+// -interval says otherwise; ping -A uses the same for ordinary users
+const adaptiveInterval = 200 * time.Millisecond
+
+// maxPingsPerSecond caps the total send rate, so a typo in -interval
+// can't flood someone else's network
+const maxPingsPerSecond = 100
+
+// flagSet reports whether a flag was given on the command line
+func flagSet(name string) bool {
+	set := false
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return set
+}
+
+// localAddr picks the address pings of a family ("ip4" or "ip6") leave
+// from: the -source address, the -I interface's address of that family,
+// or "" for whichever the routing table chooses
+func localAddr(family, iface string, source net.IP) (string, error) {
+	if source != nil {
+		if (source.To4() == nil) != (family == "ip6") {
+			return "", fmt.Errorf("-source %s is a different IP version", source)
+		}
+		if !isLocal(source) {
+			return "", fmt.Errorf("-source %s is not an address of this machine", source)
+		}
+		return source.String(), nil
+	}
+	if iface != "" {
+		addr, err := netif.Addr(iface, family == "ip6")
+		if err != nil {
+			return "", err
+		}
+		return addr.String(), nil
 	}
+	return "", nil
+}
 
-	msgBytes, err := msg.Marshal(nil)
+// isLocal reports whether ip is assigned to one of this machine's
+// interfaces, since binding to any other address fails
+func isLocal(ip net.IP) bool {
+	addrs, err := net.InterfaceAddrs()
 	if err != nil {
-		return 0, fmt.Errorf("marshal error: %w", err)
+		return false
 	}
-
-	// Set deadline
-	if err := conn.SetDeadline(time.Now().Add(timeout)); err != nil {
-		return 0, fmt.Errorf("set deadline: %w", err)
+	for _, addr := range addrs {
+		if ipnet, ok := addr.(*net.IPNet); ok && ipnet.IP.Equal(ip) {
+			return true
+		}
 	}
+	return false
+}
 
-	// Send
-	start := time.Now()
-	if _, err := conn.WriteTo(msgBytes, dst); err != nil {
-		return 0, fmt.Errorf("write error: %w", err)
-	}
+// floodDisplay draws ping -f style progress: a dot for each request
+// sent and a backspace for each reply, so the dots left are the losses
+type floodDisplay struct {
+	mu sync.Mutex
+	w  io.Writer
+}
 
-	// Receive reply
-	reply := make([]byte, 1500)
-	n, _, err := conn.ReadFrom(reply)
-	if err != nil {
-		return 0, fmt.Errorf("read error: %w", err)
+func (d *floodDisplay) sent() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	fmt.Fprint(d.w, ".")
+}
+
+func (d *floodDisplay) report(o ping.Packet) {
+	if o.Err != nil {
+		return
 	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	fmt.Fprint(d.w, "\b \b")
+}
 
-	rtt := time.Since(start)
+// maxPayload is the largest echo payload that fits an IPv4 datagram:
+// 65535 minus the 20 byte IP header and 8 byte ICMP header
+const maxPayload = 65507
 
-	// Parse reply
-	rm, err := icmp.ParseMessage(protocolICMP, reply[:n])
-	if err != nil {
-		return 0, fmt.Errorf("parse error: %w", err)
+// parseSweep parses "min:max:step" into the payload sizes to try
+func parseSweep(spec string) ([]int, error) {
+	parts := strings.Split(spec, ":")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("want min:max:step, got %q", spec)
 	}
 
-	if rm.Type != ipv4.ICMPTypeEchoReply {
-		return 0, fmt.Errorf("unexpected ICMP type: %v", rm.Type)
+	var nums [3]int
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not a number", part)
+		}
+		nums[i] = n
+	}
+	minSize, maxSize, step := nums[0], nums[1], nums[2]
+	if minSize < 0 || maxSize > maxPayload || minSize > maxSize {
+		return nil, fmt.Errorf("sizes must satisfy 0 <= min <= max <= %d", maxPayload)
+	}
+	if step < 1 {
+		return nil, fmt.Errorf("step must be positive")
 	}
 
-	return rtt, nil
+	var sizes []int
+	for n := minSize; n <= maxSize; n += step {
+		sizes = append(sizes, n)
+	}
+	return sizes, nil
 }
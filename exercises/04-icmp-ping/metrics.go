@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Prometheus metrics, labelled by name (from -targets-file, else the
+// host), target (the host as given) and the address it resolved to.
+// Only -daemon updates and exposes them.
+var (
+	metricLabels = []string{"name", "target", "address"}
+
+	packetsSent = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "ping_packets_sent_total",
+		Help: "Echo requests (or TCP/HTTP probes) sent.",
+	}, metricLabels)
+
+	packetsReceived = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "ping_packets_received_total",
+		Help: "Replies received in time.",
+	}, metricLabels)
+
+	lossRatio = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "ping_loss_ratio",
+		Help: "Share of the most recent pings (up to 100) that went unanswered, 0 to 1.",
+	}, metricLabels)
+
+	rttSeconds = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "ping_rtt_seconds",
+		Help:    "Round-trip time of answered pings.",
+		Buckets: rttBucketSeconds(),
+	}, metricLabels)
+)
+
+// rttBucketSeconds gives the metrics the same buckets as the summary
+// histogram, so the two can be compared at a glance
+func rttBucketSeconds() []float64 {
+	buckets := make([]float64, len(rttBuckets))
+	for i, b := range rttBuckets {
+		buckets[i] = b.Seconds()
+	}
+	return buckets
+}
+
+// Pings the loss gauge looks back over
+const lossWindowSize = 100
+
+// lossWindow remembers which of the last pings were lost, so the loss
+// gauge follows current conditions rather than the whole session, which
+// for a daemon may be months
+type lossWindow struct {
+	lost  [lossWindowSize]bool
+	count int // pings recorded, saturating at lossWindowSize
+	next  int
+	nLost int
+}
+
+// add records one ping and returns the loss ratio over the window
+func (w *lossWindow) add(lost bool) float64 {
+	if w.count == lossWindowSize && w.lost[w.next] {
+		w.nLost--
+	}
+	w.lost[w.next] = lost
+	if lost {
+		w.nLost++
+	}
+	w.next = (w.next + 1) % lossWindowSize
+	w.count = min(w.count+1, lossWindowSize)
+	return float64(w.nLost) / float64(w.count)
+}
+
+// serveMetrics exposes /metrics, and the history API, on addr until ctx
+// is cancelled
+func serveMetrics(ctx context.Context, addr string, hist *historyStore) error {
+	mux := http.NewServeMux()
+	mux.Handle("GET /metrics", promhttp.Handler())
+	hist.register(mux)
+
+	server := &http.Server{
+		Handler:           mux,
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(shutdownCtx)
+	}()
+
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("📈 Metrics on http://%s/metrics", ln.Addr())
+	if err := server.Serve(ln); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
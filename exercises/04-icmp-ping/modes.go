@@ -0,0 +1,175 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/channyeintun/network-exercises/pkg/ping"
+)
+
+// probeMode is what -mode asks for: "icmp", "tcp:443", "http" or
+// "https", the last two optionally with a port. Where ICMP is filtered
+// or raw sockets are off limits, a TCP connect or an HTTP request still
+// measures latency.
+type probeMode struct {
+	proto string
+	port  int // 0 for icmp, or for http(s) on the default port
+}
+
+func (m probeMode) String() string {
+	if m.port == 0 {
+		return m.proto
+	}
+	return m.proto + ":" + strconv.Itoa(m.port)
+}
+
+func (m probeMode) icmp() bool {
+	return m.proto == "icmp"
+}
+
+// parseMode parses -mode
+func parseMode(spec string) (probeMode, error) {
+	proto, portSpec, hasPort := strings.Cut(spec, ":")
+	switch proto {
+	case "icmp":
+		if hasPort {
+			return probeMode{}, fmt.Errorf("icmp has no port")
+		}
+		return probeMode{proto: proto}, nil
+	case "tcp", "http", "https":
+	default:
+		return probeMode{}, fmt.Errorf("unknown mode %q (want icmp, tcp:PORT, http or https)", proto)
+	}
+
+	if !hasPort {
+		if proto == "tcp" {
+			return probeMode{}, fmt.Errorf("tcp needs a port, e.g. tcp:443")
+		}
+		return probeMode{proto: proto}, nil
+	}
+	port, err := strconv.Atoi(portSpec)
+	if err != nil || port < 1 || port > 65535 {
+		return probeMode{}, fmt.Errorf("invalid port %q", portSpec)
+	}
+	return probeMode{proto: proto, port: port}, nil
+}
+
+// prober builds the TCP or HTTP prober for one target. host is the name
+// the user gave, which HTTP needs for the Host header and TLS; dst is
+// what it resolved to, and local the address to send from, if any.
+func (m probeMode) prober(host string, dst *net.IPAddr, local string) (ping.Prober, error) {
+	dialer := &net.Dialer{}
+	if local != "" {
+		addr, err := net.ResolveTCPAddr("tcp", net.JoinHostPort(local, "0"))
+		if err != nil {
+			return nil, err
+		}
+		dialer.LocalAddr = addr
+	}
+
+	if m.proto == "tcp" {
+		return &tcpProber{dialer: dialer, port: m.port}, nil
+	}
+
+	port := m.port
+	if port == 0 {
+		port = 80
+		if m.proto == "https" {
+			port = 443
+		}
+	}
+
+	// Connect to the address already resolved, so -4, -6 and -source
+	// hold, while the URL keeps the name for virtual hosting and SNI
+	remote := net.JoinHostPort(dst.String(), strconv.Itoa(port))
+	transport := &http.Transport{
+		DialContext: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			return dialer.DialContext(ctx, network, remote)
+		},
+		TLSClientConfig:   &tls.Config{ServerName: host},
+		DisableKeepAlives: true,
+	}
+	return &httpProber{
+		client: &http.Client{
+			Transport: transport,
+			// Time the first response, not wherever it redirects
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		url: m.proto + "://" + net.JoinHostPort(host, strconv.Itoa(port)) + "/",
+	}, nil
+}
+
+// tcpProber times TCP handshakes: the RTT is how long connect takes,
+// one SYN and its SYN-ACK. The connection is closed straight away.
+type tcpProber struct {
+	dialer *net.Dialer
+	port   int
+}
+
+func (tp *tcpProber) Ping(ctx context.Context, dst *net.IPAddr, seq int, payload []byte, timeout time.Duration) (time.Duration, int, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	start := time.Now()
+	conn, err := tp.dialer.DialContext(ctx, "tcp", net.JoinHostPort(dst.String(), strconv.Itoa(tp.port)))
+	if err != nil {
+		return 0, 0, probeError(err)
+	}
+	rtt := time.Since(start)
+	conn.Close()
+	return rtt, 0, nil
+}
+
+// httpProber times HTTP requests to the first response byte (TTFB). Each
+// request opens a new connection, so the RTT includes the TCP and any
+// TLS handshake as well as the server's think time.
+type httpProber struct {
+	client *http.Client
+	url    string
+}
+
+func (hp *httpProber) Ping(ctx context.Context, dst *net.IPAddr, seq int, payload []byte, timeout time.Duration) (time.Duration, int, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var firstByte time.Time
+	ctx = httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		GotFirstResponseByte: func() { firstByte = time.Now() },
+	})
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, hp.url, nil)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	start := time.Now()
+	resp, err := hp.client.Do(req)
+	if err != nil {
+		return 0, 0, probeError(err)
+	}
+	resp.Body.Close()
+
+	// Any response is a reply, but a server error is worth knowing about
+	if resp.StatusCode >= 500 {
+		return 0, 0, fmt.Errorf("HTTP %s", resp.Status)
+	}
+	return firstByte.Sub(start), 0, nil
+}
+
+// probeError turns a probe that ran out of time into ping.ErrTimeout,
+// so it is reported like a lost ping
+func probeError(err error) error {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return ping.ErrTimeout
+	}
+	return err
+}
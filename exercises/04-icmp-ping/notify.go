@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// notifyTimeout bounds a -notify command, so a hung one doesn't pile up
+// behind every later transition
+const notifyTimeout = 30 * time.Second
+
+// notifier alerts someone nearby, not a pager, to transitions
+// (-notify): "bell" rings the terminal bell, and "command:CMD" runs CMD
+// through the shell with the event in its environment
+type notifier struct {
+	bell bool
+	cmd  string
+	wg   sync.WaitGroup // running commands
+}
+
+func parseNotify(spec string) (*notifier, error) {
+	kind, cmd, _ := strings.Cut(spec, ":")
+	switch kind {
+	case "bell":
+		if cmd != "" {
+			return nil, fmt.Errorf("bell takes no argument")
+		}
+		return &notifier{bell: true}, nil
+	case "command":
+		if strings.TrimSpace(cmd) == "" {
+			return nil, fmt.Errorf(`command needs something to run, e.g. command:notify-send ping "$PING_TEXT"`)
+		}
+		return &notifier{cmd: cmd}, nil
+	default:
+		return nil, fmt.Errorf("unknown kind %q (want bell or command:CMD)", kind)
+	}
+}
+
+// notify reports one transition without holding up the pings
+func (n *notifier) notify(ev alertEvent) {
+	if n.bell {
+		// stderr, so the bell doesn't land in piped or redrawn output
+		fmt.Fprint(os.Stderr, "\a")
+		return
+	}
+	n.wg.Add(1)
+	go func() {
+		defer n.wg.Done()
+		runNotifyCommand(n.cmd, ev)
+	}()
+}
+
+// close waits for commands still running. Ctrl+C reaches them too, as
+// they share the terminal's process group.
+func (n *notifier) close() {
+	n.wg.Wait()
+}
+
+// runNotifyCommand runs cmd for one transition. The event is passed as
+// PING_* environment variables rather than spliced into the command
+// line, so a hostile hostname can't inject shell syntax.
+func runNotifyCommand(cmd string, ev alertEvent) {
+	ctx, cancel := context.WithTimeout(context.Background(), notifyTimeout)
+	defer cancel()
+
+	var c *exec.Cmd
+	if runtime.GOOS == "windows" {
+		c = exec.CommandContext(ctx, "cmd", "/C", cmd)
+	} else {
+		c = exec.CommandContext(ctx, "sh", "-c", cmd)
+	}
+	c.Env = append(os.Environ(),
+		"PING_TARGET="+ev.Target,
+		"PING_ADDRESS="+ev.Address,
+		"PING_STATE="+ev.State,
+		"PING_PREVIOUS_STATE="+ev.PreviousState,
+		"PING_CONSECUTIVE="+strconv.Itoa(ev.Consecutive),
+		"PING_ERROR="+ev.LastError,
+		"PING_TEXT="+ev.Text,
+	)
+	c.Stdout, c.Stderr = os.Stderr, os.Stderr
+
+	if err := c.Run(); err != nil {
+		log.Printf("❌ -notify command for %s failed: %v", ev.Target, err)
+	}
+}
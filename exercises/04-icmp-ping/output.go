@@ -0,0 +1,168 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/channyeintun/network-exercises/pkg/ping"
+)
+
+// probeRecord is the script-friendly shape of one ping
+type probeRecord struct {
+	Type    string   `json:"type"` // always "probe"
+	Seq     int      `json:"seq"`
+	Target  string   `json:"target"`
+	Address string   `json:"address"`
+	Mode    string   `json:"mode"` // icmp, tcp:PORT, http or https
+	SentAt  string   `json:"sent_at"`
+	Bytes   int      `json:"bytes"`
+	RTTMS   *float64 `json:"rtt_ms"` // null if there was no reply
+	TTL     int      `json:"ttl,omitempty"`
+	Error   string   `json:"error,omitempty"`
+}
+
+// probeCSVHeader names the columns of probeRecord.csvRow
+var probeCSVHeader = []string{"seq", "target", "address", "sent_at", "bytes", "rtt_ms", "ttl", "error", "mode"}
+
+func newProbeRecord(t *target, o ping.Packet) probeRecord {
+	rec := probeRecord{
+		Type:    "probe",
+		Seq:     o.Seq,
+		Target:  t.host,
+		Address: t.addr.String(),
+		Mode:    t.mode.String(),
+		SentAt:  o.SentAt.Format(time.RFC3339Nano),
+		Bytes:   o.Size,
+		TTL:     o.TTL,
+	}
+	if o.Err != nil {
+		rec.Error = o.Err.Error()
+	} else {
+		ms := milliseconds(o.RTT)
+		rec.RTTMS = &ms
+	}
+	return rec
+}
+
+// csvRow is rec as CSV fields, under probeCSVHeader
+func (rec probeRecord) csvRow() []string {
+	rtt, ttl := "", ""
+	if rec.RTTMS != nil {
+		rtt = strconv.FormatFloat(*rec.RTTMS, 'f', 3, 64)
+	}
+	if rec.TTL > 0 {
+		ttl = strconv.Itoa(rec.TTL)
+	}
+	return []string{
+		strconv.Itoa(rec.Seq),
+		rec.Target,
+		rec.Address,
+		rec.SentAt,
+		strconv.Itoa(rec.Bytes),
+		rtt,
+		ttl,
+		rec.Error,
+		rec.Mode,
+	}
+}
+
+// summaryRecord closes each target's stream of probe records
+type summaryRecord struct {
+	Type      string  `json:"type"` // always "summary"
+	Target    string  `json:"target"`
+	Address   string  `json:"address"`
+	Mode      string  `json:"mode"`
+	Sent      int     `json:"sent"`
+	Received  int     `json:"received"`
+	Corrupted int     `json:"corrupted"` // replies that didn't echo the request intact
+	LossPct   float64 `json:"loss_pct"`
+	MinMS     float64 `json:"min_ms"`
+	AvgMS     float64 `json:"avg_ms"`
+	MaxMS     float64 `json:"max_ms"`
+	MDevMS    float64 `json:"mdev_ms"`
+	JitterMS  float64 `json:"jitter_ms"`
+}
+
+// probeWriter streams results as they come in, so a continuous ping can
+// feed a dashboard. JSON output is one object per line; CSV has a row
+// per probe and logs the summaries to stderr, since they don't fit the
+// columns.
+type probeWriter struct {
+	mu     sync.Mutex
+	format string
+	enc    *json.Encoder
+	cw     *csv.Writer
+}
+
+func validFormat(format string) bool {
+	switch format {
+	case "text", "json", "csv":
+		return true
+	}
+	return false
+}
+
+func newProbeWriter(w io.Writer, format string) *probeWriter {
+	pw := &probeWriter{format: format}
+	if format == "csv" {
+		pw.cw = csv.NewWriter(w)
+		pw.cw.Write(probeCSVHeader)
+		pw.cw.Flush()
+	} else {
+		pw.enc = json.NewEncoder(w)
+	}
+	return pw
+}
+
+func (pw *probeWriter) probe(t *target, o ping.Packet) {
+	rec := newProbeRecord(t, o)
+
+	pw.mu.Lock()
+	defer pw.mu.Unlock()
+
+	if pw.cw == nil {
+		pw.enc.Encode(rec)
+		return
+	}
+	pw.cw.Write(rec.csvRow())
+	pw.cw.Flush()
+}
+
+func (pw *probeWriter) summary(t *target, r PingResult) {
+	rec := summaryRecord{
+		Type:      "summary",
+		Target:    t.host,
+		Address:   t.addr.String(),
+		Mode:      t.mode.String(),
+		Sent:      r.PacketsSent,
+		Received:  r.PacketsRecv,
+		Corrupted: r.PacketsCorrupted,
+		LossPct:   r.Loss(),
+	}
+	if r.PacketsRecv > 0 {
+		rec.MinMS = milliseconds(r.MinRTT)
+		rec.AvgMS = milliseconds(r.AvgRTT)
+		rec.MaxMS = milliseconds(r.MaxRTT)
+		rec.MDevMS = milliseconds(r.MDev)
+		rec.JitterMS = milliseconds(r.Jitter)
+	}
+
+	pw.mu.Lock()
+	defer pw.mu.Unlock()
+
+	if pw.cw == nil {
+		pw.enc.Encode(rec)
+		return
+	}
+	log.Printf("📋 %s: %d sent, %d received, %.1f%% loss, rtt min/avg/max/mdev = %.3f/%.3f/%.3f/%.3f ms",
+		rec.Target, rec.Sent, rec.Received, rec.LossPct, rec.MinMS, rec.AvgMS, rec.MaxMS, rec.MDevMS)
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
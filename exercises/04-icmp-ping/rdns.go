@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/channyeintun/network-exercises/pkg/ping"
+)
+
+// How long PTR answers are trusted. Go's resolver doesn't expose the
+// records' own TTLs, so these stand in for them; a failed lookup is
+// retried sooner than a name is refreshed.
+const (
+	ptrTTL         = 10 * time.Minute
+	ptrNegativeTTL = time.Minute
+	ptrTimeout     = 2 * time.Second
+)
+
+// ptrEntry is a cached reverse lookup
+type ptrEntry struct {
+	name     string // "" if the address has no PTR record
+	expires  time.Time
+	resolved chan struct{} // closed once the first lookup is done
+	updating bool
+}
+
+// ptrCache resolves the PTR names of targets and of the routers that
+// answer for them, in the background, so a slow DNS server never delays
+// a ping. A nil *ptrCache (-n) resolves nothing.
+type ptrCache struct {
+	mu      sync.Mutex
+	entries map[string]*ptrEntry
+}
+
+func newPTRCache() *ptrCache {
+	return &ptrCache{entries: make(map[string]*ptrEntry)}
+}
+
+// name returns ip's cached PTR name, or "" if there is none or it isn't
+// known yet. A missing or expired entry is looked up in the background;
+// until then an expired name is still returned, as stale beats nothing.
+func (c *ptrCache) name(ip net.IP) string {
+	if c == nil || ip == nil {
+		return ""
+	}
+	name, _ := c.entry(ip)
+	return name
+}
+
+// resolve is name but waits for the first lookup, for output that is
+// printed once rather than refreshed
+func (c *ptrCache) resolve(ctx context.Context, ip net.IP) string {
+	if c == nil || ip == nil {
+		return ""
+	}
+	_, resolved := c.entry(ip)
+	select {
+	case <-resolved:
+	case <-ctx.Done():
+	}
+	name, _ := c.entry(ip)
+	return name
+}
+
+// entry returns ip's cached name and a channel closed once it has been
+// looked up, starting a lookup if ip is new or its entry expired
+func (c *ptrCache) entry(ip net.IP) (string, <-chan struct{}) {
+	key := ip.String()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[key]
+	if !ok {
+		e = &ptrEntry{resolved: make(chan struct{})}
+		c.entries[key] = e
+	}
+	if !e.updating && (!ok || time.Now().After(e.expires)) {
+		e.updating = true
+		go c.lookup(key, e)
+	}
+	return e.name, e.resolved
+}
+
+func (c *ptrCache) lookup(addr string, e *ptrEntry) {
+	ctx, cancel := context.WithTimeout(context.Background(), ptrTimeout)
+	defer cancel()
+
+	names, err := net.DefaultResolver.LookupAddr(ctx, addr)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err == nil && len(names) > 0 {
+		e.name = strings.TrimSuffix(names[0], ".")
+		e.expires = time.Now().Add(ptrTTL)
+	} else {
+		// Keep a stale name through a DNS hiccup; it's retried soon
+		e.expires = time.Now().Add(ptrNegativeTTL)
+	}
+	e.updating = false
+	select {
+	case <-e.resolved:
+	default:
+		close(e.resolved)
+	}
+}
+
+// withName formats ip as "name (ip)" when it has a name, as ping and
+// traceroute do, or else just as ip
+func withName(ip net.IP, name string) string {
+	if name == "" {
+		return ip.String()
+	}
+	return name + " (" + ip.String() + ")"
+}
+
+// describeError is err as text, naming the router behind an ICMP error
+func (c *ptrCache) describeError(err error) string {
+	var icmpErr *ping.ICMPError
+	if errors.As(err, &icmpErr) {
+		if name := c.name(icmpErr.From); name != "" {
+			return fmt.Sprintf("%v from %s", icmpErr.Type, withName(icmpErr.From, name))
+		}
+	}
+	return err.Error()
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net"
+	"time"
+)
+
+// resolveTimeout bounds looking up one host
+const resolveTimeout = 5 * time.Second
+
+// resolveHost resolves host for network "ip4", "ip6", or "ip" for
+// either. Forced to a family (-4, -6, -source) it takes that family's
+// first address. Otherwise it takes the resolver's first address in a
+// family this machine has a route for, so a dual-stack name still works
+// from an IPv4-only network, and says which it chose.
+func resolveHost(host, network string) (*net.IPAddr, error) {
+	if network != "ip" {
+		return net.ResolveIPAddr(network, host)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), resolveTimeout)
+	defer cancel()
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+
+	// The first address of each family
+	var v4, v6 *net.IPAddr
+	for i := range addrs {
+		switch {
+		case addrs[i].IP.To4() != nil && v4 == nil:
+			v4 = &addrs[i]
+		case addrs[i].IP.To4() == nil && v6 == nil:
+			v6 = &addrs[i]
+		}
+	}
+	if v4 == nil || v6 == nil {
+		return &addrs[0], nil
+	}
+
+	// Both families: the resolver orders addresses by preference (RFC
+	// 6724), but that doesn't mean this host can reach them
+	first, second := v4, v6
+	if addrs[0].IP.To4() == nil {
+		first, second = v6, v4
+	}
+	chosen, why := first, "preferred"
+	if !routable(first) && routable(second) {
+		chosen, why = second, "no route for the other"
+	}
+	other := "-4"
+	if chosen == v4 {
+		other = "-6"
+	}
+	log.Printf("🌐 %s has IPv4 %s and IPv6 %s; using %s (%s; %s to switch)", host, v4.IP, v6.IP, chosen.IP, why, other)
+	return chosen, nil
+}
+
+// routable reports whether the kernel has a route to addr. Connecting a
+// UDP socket only picks the route and source address; nothing is sent.
+func routable(addr *net.IPAddr) bool {
+	conn, err := net.DialUDP("udp", nil, &net.UDPAddr{IP: addr.IP, Zone: addr.Zone, Port: 9})
+	if err != nil {
+		return false
+	}
+	conn.Close()
+	return true
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+)
+
+// pingSubnet pings every address of a subnet, a fast liveness map of a
+// LAN, and lists the hosts that answered. All targets ping at once, but
+// their starts are spread over one interval so the first round keeps
+// to the rate cap too instead of going out in a single burst.
+func pingSubnet(ctx context.Context, cidr string, targets []*target, count int, interval, timeout time.Duration) {
+	fmt.Printf("SWEEP %s (%d addresses)\n", cidr, len(targets))
+	fmt.Println("─────────────────────────────────")
+
+	var wg sync.WaitGroup
+	for i, t := range targets {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(interval * time.Duration(i) / time.Duration(len(targets))):
+			}
+			t.run(ctx, count, interval, timeout, nil)
+		}()
+	}
+	wg.Wait()
+
+	// Addresses sort numerically, so hosts read in subnet order
+	slices.SortFunc(targets, func(a, b *target) int {
+		return bytes.Compare(a.addr.IP.To16(), b.addr.IP.To16())
+	})
+
+	addrWidth := len("ADDRESS")
+	for _, t := range targets {
+		addrWidth = max(addrWidth, len(t.addr.String()))
+	}
+
+	up := 0
+	for _, t := range targets {
+		r := t.stats()
+		if r.PacketsRecv == 0 {
+			continue
+		}
+		if up == 0 {
+			fmt.Printf("%-*s  %8s  %6s\n", addrWidth, "ADDRESS", "AVG", "LOSS")
+		}
+		up++
+		fmt.Printf("%-*s  %8s  %5.1f%%\n", addrWidth, t.addr, formatRTT(r.AvgRTT, r.PacketsRecv), r.Loss())
+	}
+
+	fmt.Println("─────────────────────────────────")
+	fmt.Printf("%d of %d addresses answered\n", up, len(targets))
+}
@@ -0,0 +1,10 @@
+//go:build !unix
+
+package main
+
+import "os"
+
+// terminalSize assumes the classic 80x24 where there's no TIOCGWINSZ
+func terminalSize(f *os.File) (int, int) {
+	return 80, 24
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/channyeintun/network-exercises/pkg/ping"
+)
+
+// Probes sent per hop, as traceroute does by default
+const probesPerHop = 3
+
+// traceroute finds the path to dst the way traceroute -I does: echo
+// requests with TTL 1, 2, 3... Each router that decrements the TTL to
+// zero drops the packet and answers with Time Exceeded, revealing
+// itself. The trace ends when dst answers or reports it is unreachable.
+// Hops are named by ptr unless it is nil (-n).
+func traceroute(ctx context.Context, c *ping.Conn, host string, dst *net.IPAddr, maxHops int, timeout time.Duration, ptr *ptrCache) {
+	fmt.Printf("traceroute to %s (%s), %d hops max\n", host, dst, maxHops)
+
+	seq := 0
+	for ttl := 1; ttl <= maxHops; ttl++ {
+		var line strings.Builder
+		fmt.Fprintf(&line, "%2d ", ttl)
+
+		var last net.IP
+		done := false
+		for i := 0; i < probesPerHop; i++ {
+			seq = (seq + 1) & 0xffff
+			r, rtt, err := c.Probe(ctx, dst, seq, ttl, timeout)
+			if ctx.Err() != nil {
+				fmt.Println(line.String())
+				return
+			}
+			if err != nil {
+				line.WriteString(" *")
+				continue
+			}
+
+			// Probes of one hop may be answered by different routers
+			// when traffic is load balanced
+			if !r.From.Equal(last) {
+				fmt.Fprintf(&line, " %s", withName(r.From, ptr.resolve(ctx, r.From)))
+				last = r.From
+			}
+			fmt.Fprintf(&line, "  %.3f ms", float64(rtt.Microseconds())/1000)
+
+			switch {
+			case r.EchoReply():
+				done = true
+			case r.Unreachable():
+				line.WriteString(" !")
+				done = true
+			}
+		}
+
+		fmt.Println(line.String())
+		if done {
+			return
+		}
+	}
+}
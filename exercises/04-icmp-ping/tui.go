@@ -0,0 +1,204 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/channyeintun/network-exercises/pkg/ping"
+)
+
+// How often the TUI redraws, and how many outcomes per target it keeps,
+// which is the widest graph it can draw
+const (
+	tuiRefresh = 250 * time.Millisecond
+	tuiHistory = 512
+)
+
+// Tallest graph, in rows; with many targets they shrink to fit
+const tuiGraphRows = 5
+
+// Width of the graph's y axis labels, e.g. " 12.34ms ┤"
+const tuiAxisWidth = 10
+
+// ANSI escape sequences the TUI draws with
+const (
+	ansiAltScreen  = "\033[?1049h\033[?25l" // switch to the alternate screen, hide the cursor
+	ansiMainScreen = "\033[?25h\033[?1049l"
+	ansiHome       = "\033[H"
+	ansiClearLine  = "\033[K" // to the end of the line
+	ansiClearBelow = "\033[J"
+	ansiBold       = "\033[1m"
+	ansiDim        = "\033[2m"
+	ansiRed        = "\033[31m"
+	ansiGreen      = "\033[32m"
+	ansiYellow     = "\033[33m"
+	ansiCyan       = "\033[36m"
+	ansiReset      = "\033[0m"
+)
+
+// tuiSeries is a ring of a target's latest outcomes, oldest overwritten
+type tuiSeries struct {
+	mu   sync.Mutex
+	rtts [tuiHistory]time.Duration // -1 for a lost ping
+	n    int                       // outcomes recorded, ever
+}
+
+func (s *tuiSeries) add(pkt ping.Packet) {
+	rtt := pkt.RTT
+	if pkt.Err != nil {
+		rtt = -1
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rtts[s.n%tuiHistory] = rtt
+	s.n++
+}
+
+// last returns up to n of the latest outcomes, oldest first
+func (s *tuiSeries) last(n int) []time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n = min(n, s.n, tuiHistory)
+	out := make([]time.Duration, n)
+	for i := range out {
+		out[i] = s.rtts[(s.n-n+i)%tuiHistory]
+	}
+	return out
+}
+
+// runTUI pings every target at once on the terminal's alternate screen,
+// redrawing a scrolling RTT graph per target in place. When it's done
+// the screen is restored and the final table printed as a fleet ping
+// would.
+func runTUI(ctx context.Context, targets []*target, count int, interval, timeout time.Duration) {
+	series := make([]*tuiSeries, len(targets))
+	var wg sync.WaitGroup
+	for i, t := range targets {
+		series[i] = &tuiSeries{}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			t.run(ctx, count, interval, timeout, series[i].add)
+		}()
+	}
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	fmt.Print(ansiAltScreen)
+	ticker := time.NewTicker(tuiRefresh)
+	defer ticker.Stop()
+loop:
+	for {
+		width, height := terminalSize(os.Stdout)
+		drawTUI(os.Stdout, targets, series, interval, width, height)
+		select {
+		case <-done:
+			break loop
+		case <-ticker.C:
+		}
+	}
+	fmt.Print(ansiMainScreen)
+
+	redrawTable(os.Stdout, targets, 0)
+}
+
+// drawTUI draws one frame: a title line, then per target a status line
+// and its graph. The frame is built in memory and written at once, with
+// each line overwriting the last frame's, so it doesn't flicker.
+func drawTUI(w io.Writer, targets []*target, series []*tuiSeries, interval time.Duration, width, height int) {
+	// Give every target the same graph height, as tall as fits
+	rows := (height-1)/len(targets) - 2
+	rows = max(1, min(rows, tuiGraphRows))
+	cols := max(1, width-tuiAxisWidth)
+
+	var b bytes.Buffer
+	b.WriteString(ansiHome)
+	fmt.Fprintf(&b, "%sPING %d hosts every %v%s  %sCtrl+C to stop%s%s\n",
+		ansiBold, len(targets), interval, ansiReset, ansiDim, ansiReset, ansiClearLine)
+
+	for i, t := range targets {
+		recent := series[i].last(cols)
+		r := t.stats()
+
+		dot := ansiDim + "●"
+		if n := len(recent); n > 0 && recent[n-1] >= 0 {
+			dot = ansiGreen + "●"
+		} else if n > 0 {
+			dot = ansiRed + "●"
+		}
+		lossColor := ""
+		if r.Loss() > 0 {
+			lossColor = ansiYellow
+		}
+		fmt.Fprintf(&b, "%s%s %s%s%s (%s)  sent %d  %sloss %.1f%%%s  last %s  avg %s  max %s%s\n",
+			dot, ansiReset, ansiBold, t.label(), ansiReset, t.addr, r.PacketsSent,
+			lossColor, r.Loss(), ansiReset,
+			formatRTT(r.LastRTT, r.PacketsRecv), formatRTT(r.AvgRTT, r.PacketsRecv),
+			formatRTT(r.MaxRTT, r.PacketsRecv), ansiClearLine)
+
+		for _, line := range graphLines(recent, rows) {
+			b.WriteString(line)
+			b.WriteString(ansiClearLine + "\n")
+		}
+		b.WriteString(ansiClearLine + "\n")
+	}
+	b.WriteString(ansiClearBelow)
+	w.Write(b.Bytes())
+}
+
+// graphLines draws RTTs as a bar graph rows tall, newest on the right,
+// scaled from zero to the highest RTT shown, which labels the top row.
+// Each row is eight levels of block character, and a lost ping is a red
+// × on the baseline.
+func graphLines(rtts []time.Duration, rows int) []string {
+	levels := []rune(" ▁▂▃▄▅▆▇█")
+
+	var top time.Duration
+	for _, rtt := range rtts {
+		top = max(top, rtt)
+	}
+
+	lines := make([]string, rows)
+	for row := range rows {
+		var b strings.Builder
+		// Label the top row with the scale and the bottom with zero
+		switch {
+		case row == 0 && top > 0:
+			fmt.Fprintf(&b, "%*s ┤", tuiAxisWidth-2, formatRTT(top, 1))
+		case row == rows-1:
+			fmt.Fprintf(&b, "%*s ┤", tuiAxisWidth-2, "0")
+		default:
+			fmt.Fprintf(&b, "%*s │", tuiAxisWidth-2, "")
+		}
+
+		b.WriteString(ansiCyan)
+		fromBottom := rows - 1 - row
+		for _, rtt := range rtts {
+			if rtt < 0 {
+				if fromBottom == 0 {
+					b.WriteString(ansiRed + "×" + ansiCyan)
+				} else {
+					b.WriteRune(' ')
+				}
+				continue
+			}
+
+			// Height in eighths of a row, at least a sliver for any reply
+			height := max(1, int(int64(rtt)*int64(rows*8)/int64(max(top, 1))))
+			fill := min(max(height-fromBottom*8, 0), 8)
+			b.WriteRune(levels[fill])
+		}
+		b.WriteString(ansiReset)
+		lines[row] = b.String()
+	}
+	return lines
+}
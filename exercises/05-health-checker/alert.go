@@ -0,0 +1,227 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+)
+
+// Webhook delivery: up to alertAttempts tries, waiting alertBackoff,
+// then twice as long, and so on between them
+const (
+	alertAttempts = 5
+	alertBackoff  = time.Second
+)
+
+// alertEvent is an endpoint going down, still being down (a resend) or
+// recovering. It is POSTed as is to -alert-webhook; its "text" field
+// alone makes it render in Slack-compatible webhooks too.
+type alertEvent struct {
+	Text      string `json:"text"`
+	Endpoint  string `json:"endpoint"`
+	Target    string `json:"target"`
+	State     string `json:"state"` // "down" or "up"
+	Repeat    bool   `json:"repeat,omitempty"`
+	Time      string `json:"time"`
+	DownSince string `json:"down_since,omitempty"`
+	Downtime  string `json:"downtime,omitempty"` // on recovery
+	Error     string `json:"error,omitempty"`
+}
+
+// alertReceiver is a webhook and the payload it wants
+type alertReceiver struct {
+	url   string
+	slack bool // a Slack incoming webhook, sent a colored attachment
+}
+
+// endpointAlerts is what has been said about one endpoint, so each
+// outage is announced once (plus resends) however many checks fail
+type endpointAlerts struct {
+	down      bool
+	downSince time.Time
+	lastSent  time.Time
+}
+
+// alerter turns endpoint state changes into notifications and delivers
+// them one at a time, in order, so a slow webhook holds up alerts rather
+// than checks. A nil *alerter sends nothing.
+type alerter struct {
+	receivers []alertReceiver
+	resend    time.Duration // 0 to announce an outage only once
+	endpoints map[string]*endpointAlerts
+	events    chan alertEvent
+	done      chan struct{}
+}
+
+func newAlerter(ctx context.Context, receivers []alertReceiver, resend time.Duration) *alerter {
+	a := &alerter{
+		receivers: receivers,
+		resend:    resend,
+		endpoints: make(map[string]*endpointAlerts),
+		events:    make(chan alertEvent, 64),
+		done:      make(chan struct{}),
+	}
+	go func() {
+		defer close(a.done)
+		for ev := range a.events {
+			for _, r := range a.receivers {
+				if err := a.deliver(ctx, r, ev); err != nil && ctx.Err() == nil {
+					log.Printf("❌ Alert for %s to %s failed: %v", ev.Endpoint, r.url, err)
+				}
+			}
+		}
+	}()
+	return a
+}
+
+// observe looks at an endpoint's new status and alerts if it went down,
+// is still down and due a resend, or came back up. A first check that
+// finds the endpoint up says nothing. Called with the checker's lock
+// held, so calls for one endpoint are never concurrent.
+func (a *alerter) observe(status *HealthStatus) {
+	if a == nil {
+		return
+	}
+	ep := status.Endpoint
+	st, ok := a.endpoints[ep.Name]
+	if !ok {
+		st = &endpointAlerts{}
+		a.endpoints[ep.Name] = st
+	}
+	now := status.LastCheck
+
+	ev := alertEvent{
+		Endpoint: ep.Name,
+		Target:   ep.target(),
+		Time:     now.Format(time.RFC3339),
+		Error:    status.Error,
+	}
+	switch {
+	case !status.Healthy && !st.down:
+		st.down, st.downSince = true, now
+		ev.State = "down"
+		ev.Text = fmt.Sprintf("🔴 %s is down: %s", ep.Name, status.Error)
+	case !status.Healthy && a.resend > 0 && now.Sub(st.lastSent) >= a.resend:
+		ev.State, ev.Repeat = "down", true
+		ev.Text = fmt.Sprintf("🔴 %s is still down after %v: %s", ep.Name, now.Sub(st.downSince).Round(time.Second), status.Error)
+	case status.Healthy && st.down:
+		st.down = false
+		ev.State = "up"
+		ev.Downtime = now.Sub(st.downSince).Round(time.Second).String()
+		ev.Error = ""
+		ev.Text = fmt.Sprintf("🟢 %s is up again after %s down", ep.Name, ev.Downtime)
+	default:
+		return
+	}
+	if ev.State == "down" {
+		ev.DownSince = st.downSince.Format(time.RFC3339)
+	}
+	st.lastSent = now
+
+	log.Println(ev.Text)
+	select {
+	case a.events <- ev:
+	default:
+		log.Printf("⚠️  Alert queue full; dropping alert for %s", ev.Endpoint)
+	}
+}
+
+// forget drops a removed endpoint's alert state. Called with the
+// checker's lock held.
+func (a *alerter) forget(name string) {
+	if a == nil {
+		return
+	}
+	delete(a.endpoints, name)
+}
+
+// close waits for queued alerts to be delivered, or given up on
+func (a *alerter) close() {
+	if a == nil {
+		return
+	}
+	close(a.events)
+	<-a.done
+}
+
+// payload is ev as r wants it
+func (r alertReceiver) payload(ev alertEvent) ([]byte, error) {
+	if !r.slack {
+		return json.Marshal(ev)
+	}
+
+	color := "#cf222e"
+	if ev.State == "up" {
+		color = "#1a7f37"
+	}
+	fields := []map[string]any{{"title": "Target", "value": ev.Target, "short": true}}
+	if ev.Downtime != "" {
+		fields = append(fields, map[string]any{"title": "Downtime", "value": ev.Downtime, "short": true})
+	}
+	if ev.Error != "" {
+		fields = append(fields, map[string]any{"title": "Error", "value": ev.Error})
+	}
+	return json.Marshal(map[string]any{
+		"text": ev.Text,
+		"attachments": []map[string]any{{
+			"color":    color,
+			"fallback": ev.Text,
+			"fields":   fields,
+		}},
+	})
+}
+
+// deliver POSTs ev to r, retrying with exponential backoff on network
+// errors and on 5xx or 429 responses. Other statuses won't improve on
+// retry.
+func (a *alerter) deliver(ctx context.Context, r alertReceiver, ev alertEvent) error {
+	body, err := r.payload(ev)
+	if err != nil {
+		return err
+	}
+
+	backoff := alertBackoff
+	for attempt := 1; ; attempt++ {
+		retry, err := post(ctx, r.url, body)
+		if err == nil || !retry || attempt == alertAttempts {
+			return err
+		}
+
+		log.Printf("⚠️  Alert for %s failed (%v); retrying in %v", ev.Endpoint, err, backoff)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+// post makes one delivery attempt and says whether a failure is worth
+// retrying
+func post(ctx context.Context, url string, body []byte) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		retry := resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests
+		return retry, fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return false, nil
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// Auth is the credentials an HTTP check sends: "basic" with a username
+// and password, "bearer" with a token, or "header" with a header name
+// and value (an API key, say). Secret fields can name where the secret
+// lives rather than hold it, so endpoints.json can be committed:
+// "env:NAME" reads an environment variable and "file:PATH" a file's
+// contents, as mounted Docker and Kubernetes secrets are.
+type Auth struct {
+	Type     string `json:"type"`
+	Username string `json:"username"`
+	Password string `json:"password"`
+	Token    string `json:"token"`
+	Header   string `json:"header"`
+	Value    string `json:"value"`
+}
+
+// resolve checks the credentials are complete for their type and
+// replaces secret references with the secrets
+func (a *Auth) resolve() error {
+	var secrets []*string
+	switch a.Type {
+	case "basic":
+		if a.Username == "" {
+			return errors.New("basic auth needs a username")
+		}
+		secrets = []*string{&a.Username, &a.Password}
+	case "bearer":
+		if a.Token == "" {
+			return errors.New("bearer auth needs a token")
+		}
+		secrets = []*string{&a.Token}
+	case "header":
+		if a.Header == "" || a.Value == "" {
+			return errors.New("header auth needs a header and a value")
+		}
+		secrets = []*string{&a.Value}
+	default:
+		return fmt.Errorf("unknown auth type %q (want basic, bearer or header)", a.Type)
+	}
+
+	for _, s := range secrets {
+		value, err := resolveSecret(*s)
+		if err != nil {
+			return err
+		}
+		*s = value
+	}
+	return nil
+}
+
+// resolveSecret returns the secret ref names, or ref itself if it isn't
+// an env: or file: reference
+func resolveSecret(ref string) (string, error) {
+	if name, ok := strings.CutPrefix(ref, "env:"); ok {
+		value, set := os.LookupEnv(name)
+		if !set {
+			return "", fmt.Errorf("environment variable %s is not set", name)
+		}
+		return value, nil
+	}
+	if path, ok := strings.CutPrefix(ref, "file:"); ok {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", err
+		}
+		// Secret files usually end in a newline no one meant to send
+		return strings.TrimRight(string(data), "\r\n"), nil
+	}
+	return ref, nil
+}
+
+// apply adds the credentials to req
+func (a *Auth) apply(req *http.Request) {
+	switch a.Type {
+	case "basic":
+		req.SetBasicAuth(a.Username, a.Password)
+	case "bearer":
+		req.Header.Set("Authorization", "Bearer "+a.Token)
+	case "header":
+		req.Header.Set(a.Header, a.Value)
+	}
+}
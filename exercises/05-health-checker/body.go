@@ -0,0 +1,187 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"strconv"
+	"strings"
+)
+
+// defaultMaxBody caps how much of a response is read for body assertions
+const defaultMaxBody = 64 << 10
+
+// hasBodyAssertions reports whether the endpoint checks the response
+// body, and so has to read it
+func (ep *Endpoint) hasBodyAssertions() bool {
+	return ep.hasContentAssertions() || ep.MinBytes > 0 || ep.MaxBytes > 0
+}
+
+// hasContentAssertions reports whether the endpoint checks what the
+// response body says, not just its size
+func (ep *Endpoint) hasContentAssertions() bool {
+	return ep.BodyContains != "" || ep.bodyRegexp != nil || ep.jsonPath != nil
+}
+
+// bodyLimit is how much of a response body is read: max_body_bytes for
+// the content assertions, enough to reach min_bytes, and one byte past
+// max_bytes to see it exceeded
+func (ep *Endpoint) bodyLimit() int64 {
+	var limit int64
+	if ep.hasContentAssertions() {
+		limit = ep.MaxBodyBytes
+	}
+	limit = max(limit, ep.MinBytes)
+	if ep.MaxBytes > 0 {
+		limit = max(limit, ep.MaxBytes+1)
+	}
+	return limit
+}
+
+// checkBody reads as much of body as the endpoint's assertions need and
+// applies them to it, so a 200 serving an error page, or only half a
+// page, still fails
+func checkBody(ep *Endpoint, body io.Reader) error {
+	data, err := io.ReadAll(io.LimitReader(body, ep.bodyLimit()))
+	if err != nil {
+		return fmt.Errorf("reading body: %v", err)
+	}
+
+	switch size := int64(len(data)); {
+	case ep.MaxBytes > 0 && size > ep.MaxBytes:
+		return fmt.Errorf("body is over max_bytes (%d)", ep.MaxBytes)
+	case size < ep.MinBytes:
+		return fmt.Errorf("body is %d bytes (min_bytes %d)", size, ep.MinBytes)
+	}
+	if int64(len(data)) > ep.MaxBodyBytes {
+		data = data[:ep.MaxBodyBytes]
+	}
+
+	if ep.BodyContains != "" && !bytes.Contains(data, []byte(ep.BodyContains)) {
+		return fmt.Errorf("body lacks %q", ep.BodyContains)
+	}
+	if ep.bodyRegexp != nil && !ep.bodyRegexp.Match(data) {
+		return fmt.Errorf("body doesn't match /%s/", ep.BodyRegexp)
+	}
+	if ep.jsonPath != nil {
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.UseNumber()
+		var doc any
+		if err := dec.Decode(&doc); err != nil {
+			if int64(len(data)) == ep.MaxBodyBytes {
+				return fmt.Errorf("body isn't JSON in its first %d bytes (max_body_bytes): %v", len(data), err)
+			}
+			return fmt.Errorf("body isn't JSON: %v", err)
+		}
+		value, ok := ep.jsonPath.lookup(doc)
+		if !ok {
+			return fmt.Errorf("%s not found in body", ep.JSONPath)
+		}
+		if ep.JSONValue != "" && jsonText(value) != ep.JSONValue {
+			return fmt.Errorf("%s is %s (expected %s)", ep.JSONPath, jsonText(value), ep.JSONValue)
+		}
+	}
+	return nil
+}
+
+// checkContentType checks a response's Content-Type header is want's
+// media type, ignoring parameters such as charset. A want of "type/*"
+// takes any subtype.
+func checkContentType(want, header string) error {
+	if header == "" {
+		return fmt.Errorf("no Content-Type (expected %s)", want)
+	}
+	got, _, err := mime.ParseMediaType(header)
+	if err != nil {
+		return fmt.Errorf("bad Content-Type %q: %v", header, err)
+	}
+	prefix, wildcard := strings.CutSuffix(want, "*")
+	if got == want || wildcard && strings.HasPrefix(got, prefix) {
+		return nil
+	}
+	return fmt.Errorf("Content-Type %s (expected %s)", got, want)
+}
+
+// jsonPath is a parsed JSONPath expression of the simple kind health
+// checks need: $ followed by .key, ["key"] and [index] steps. Filters,
+// wildcards and recursive descent aren't supported.
+type jsonPath []any // each step a string key or an int index
+
+func parseJSONPath(expr string) (jsonPath, error) {
+	rest, ok := strings.CutPrefix(expr, "$")
+	if !ok {
+		return nil, errors.New("must start with $")
+	}
+
+	var path jsonPath
+	for rest != "" {
+		switch rest[0] {
+		case '.':
+			rest = rest[1:]
+			end := strings.IndexAny(rest, ".[")
+			if end < 0 {
+				end = len(rest)
+			}
+			if end == 0 {
+				return nil, fmt.Errorf("empty key before %q", rest)
+			}
+			path = append(path, rest[:end])
+			rest = rest[end:]
+		case '[':
+			end := strings.IndexByte(rest, ']')
+			if end < 0 {
+				return nil, errors.New("unclosed [")
+			}
+			inner := rest[1:end]
+			rest = rest[end+1:]
+			if len(inner) >= 2 && (inner[0] == '"' || inner[0] == '\'') && inner[len(inner)-1] == inner[0] {
+				path = append(path, inner[1:len(inner)-1])
+				continue
+			}
+			index, err := strconv.Atoi(inner)
+			if err != nil || index < 0 {
+				return nil, fmt.Errorf("bad index [%s]", inner)
+			}
+			path = append(path, index)
+		default:
+			return nil, fmt.Errorf("unexpected %q", rest)
+		}
+	}
+	return path, nil
+}
+
+// lookup follows the path through a decoded JSON document
+func (p jsonPath) lookup(doc any) (any, bool) {
+	for _, step := range p {
+		switch step := step.(type) {
+		case string:
+			obj, ok := doc.(map[string]any)
+			if !ok {
+				return nil, false
+			}
+			if doc, ok = obj[step]; !ok {
+				return nil, false
+			}
+		case int:
+			arr, ok := doc.([]any)
+			if !ok || step >= len(arr) {
+				return nil, false
+			}
+			doc = arr[step]
+		}
+	}
+	return doc, true
+}
+
+// jsonText is a JSON value as json_value is compared with it: strings
+// bare, everything else as JSON
+func jsonText(v any) string {
+	if s, ok := v.(string); ok {
+		return s
+	}
+	b, _ := json.Marshal(v)
+	return string(b)
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Checker checks one type of endpoint. Every type is its own Checker,
+// registered under the type's name, so a new kind of check is a Checker
+// and a RegisterChecker call in its file's init: scheduling, retries,
+// thresholds, alerts and every report work the same for it.
+type Checker interface {
+	// Check checks ep once, within the timeout ctx carries
+	Check(ctx context.Context, ep *Endpoint) Result
+}
+
+// Pender is a Checker that may have nothing to go on yet, like a
+// composite whose members haven't been checked. A pending endpoint is
+// neither up nor down until a check at a later interval isn't pending.
+type Pender interface {
+	Pending(ep *Endpoint) bool
+}
+
+// Result is what one check found. A Checker sets Err, nil for a pass,
+// and whatever else its type has to say.
+type Result struct {
+	Err      error
+	Latency  time.Duration  // if the check times something itself, like a round trip; by default how long Check took
+	Phases   *httpPhases    // for http: where the time went
+	Targets  []srvTarget    // for srv: how each target's check went
+	Sources  []sourceResult // for endpoints with interfaces: how the check went from each
+	Partial  string         // for srv and interfaces: the targets or sources down, if only some are
+	Attempts int            // set by the scheduler: tries it took, retries included
+}
+
+// checkerType is a registered type of endpoint
+type checkerType struct {
+	newChecker func(*HealthChecker) Checker
+	validate   func(*Endpoint) error
+}
+
+var checkerTypes = map[string]checkerType{}
+
+// RegisterChecker adds a type of endpoint. newChecker makes its Checker
+// for a HealthChecker, whose dialer, clients and statuses it can use.
+// validate, if not nil, checks an endpoint's settings for the type when
+// its config is loaded, and fills in their defaults. Call it from init;
+// it panics if the type is taken.
+func RegisterChecker(typ string, newChecker func(*HealthChecker) Checker, validate func(*Endpoint) error) {
+	if _, dup := checkerTypes[typ]; dup {
+		panic("RegisterChecker: type " + typ + " registered twice")
+	}
+	checkerTypes[typ] = checkerType{newChecker: newChecker, validate: validate}
+}
+
+// newCheckers makes every registered type's Checker for hc
+func newCheckers(hc *HealthChecker) map[string]Checker {
+	checkers := make(map[string]Checker, len(checkerTypes))
+	for typ, t := range checkerTypes {
+		checkers[typ] = t.newChecker(hc)
+	}
+	return checkers
+}
+
+// checkerNames lists the registered types, for errors: "a, b or c"
+func checkerNames() string {
+	names := slices.Sorted(maps.Keys(checkerTypes))
+	if len(names) < 2 {
+		return strings.Join(names, "")
+	}
+	return strings.Join(names[:len(names)-1], ", ") + " or " + names[len(names)-1]
+}
@@ -0,0 +1,441 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/channyeintun/network-exercises/pkg/rendezvous"
+)
+
+// errLapsed is a cluster registration that expired before it was renewed
+var errLapsed = errors.New("registration lapsed")
+
+// clusterBackend is where the instances of a cluster register. Each
+// registration lapses unless it's renewed within the TTL, so a member
+// that dies drops out on its own.
+type clusterBackend interface {
+	// join registers id for ttl
+	join(ctx context.Context, id string, ttl time.Duration) error
+	// renew restarts the registration's TTL; errLapsed if it expired
+	renew(ctx context.Context) error
+	// members lists the IDs registered now
+	members(ctx context.Context) ([]string, error)
+	// leave ends the registration at once, so the others needn't wait
+	// out the TTL to take over
+	leave(ctx context.Context) error
+}
+
+// cluster splits the endpoints among instances that share a config.
+// Every instance registers in etcd or Consul and reads who else has;
+// each endpoint goes to one member by rendezvous hashing of its name, so
+// every instance works out the same split with no leader to hand it
+// out, and a member joining or leaving only moves its own share. When a
+// member dies its registration lapses after the TTL and the others pick
+// up its endpoints. An instance cut off from the backend carries on with
+// the split it last saw, so an outage of the backend itself doesn't stop
+// any checks, though the others may take its share over meanwhile and
+// check some endpoints twice until it's back.
+type cluster struct {
+	hc      *HealthChecker
+	id      string
+	ttl     time.Duration
+	via     string // "etcd" or "consul", for logs
+	backend clusterBackend
+
+	mu    sync.Mutex
+	alive []string // sorted; nil until first read
+}
+
+func newCluster(hc *HealthChecker, id string, ttl time.Duration, via string, backend clusterBackend) *cluster {
+	return &cluster{hc: hc, id: id, ttl: ttl, via: via, backend: backend}
+}
+
+// run keeps this instance registered and the split up to date until ctx
+// is cancelled, then leaves
+func (c *cluster) run(ctx context.Context) {
+	joined, failing := false, false
+	for {
+		err := c.beat(ctx, &joined)
+		if ctx.Err() != nil {
+			break
+		}
+		switch {
+		case err != nil && !failing:
+			c.hc.event(slog.LevelError, fmt.Sprintf("❌ Cluster: %v", err), "cluster failed", "via", c.via, "error", err)
+			failing = true
+		case err == nil && failing:
+			c.hc.event(slog.LevelInfo, "👥 Cluster: reaching "+c.via+" again", "cluster recovered", "via", c.via)
+			failing = false
+		}
+		if !sleepCtx(ctx, c.ttl/3) {
+			break
+		}
+	}
+
+	if joined {
+		leaveCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := c.backend.leave(leaveCtx); err != nil {
+			c.hc.event(slog.LevelWarn, fmt.Sprintf("⚠️  Cluster: leaving: %v; the others take over after %v", err, c.ttl),
+				"cluster leave failed", "via", c.via, "error", err)
+		}
+	}
+}
+
+// beat renews the registration, or makes it, and rereads the members,
+// rebalancing if they changed
+func (c *cluster) beat(ctx context.Context, joined *bool) error {
+	// Endpoints started by a rebalance run on ctx; the calls are bounded
+	callCtx, cancel := context.WithTimeout(ctx, c.ttl/3)
+	defer cancel()
+
+	if *joined {
+		err := c.backend.renew(callCtx)
+		if errors.Is(err, errLapsed) {
+			c.hc.event(slog.LevelWarn, "⚠️  Cluster: registration lapsed; joining again", "cluster lapsed", "via", c.via, "member", c.id)
+			*joined = false
+		} else if err != nil {
+			return err
+		}
+	}
+	if !*joined {
+		if err := c.backend.join(callCtx, c.id, c.ttl); err != nil {
+			return err
+		}
+		*joined = true
+	}
+
+	alive, err := c.backend.members(callCtx)
+	if err != nil {
+		return err
+	}
+	if !slices.Contains(alive, c.id) {
+		alive = append(alive, c.id) // registered, if not yet listed
+	}
+	slices.Sort(alive)
+
+	c.mu.Lock()
+	changed := !slices.Equal(alive, c.alive)
+	c.alive = alive
+	c.mu.Unlock()
+	if changed && ctx.Err() == nil {
+		owned, total := c.hc.rebalance(ctx)
+		clusterMembers.Set(float64(len(alive)))
+		c.hc.event(slog.LevelInfo, fmt.Sprintf("👥 Cluster: %d members (%s); checking %d of %d endpoints as %s", len(alive), strings.Join(alive, ", "), owned, total, c.id),
+			"cluster changed", "via", c.via, "member", c.id, "members", alive, "owned", owned, "endpoints", total)
+	}
+	return nil
+}
+
+// assign gives each endpoint the member that checks it, by name. It
+// returns nil without a cluster, where this instance checks them all,
+// and an empty map before the members are known, when it checks none.
+func (c *cluster) assign(endpoints []Endpoint) map[string]string {
+	if c == nil {
+		return nil
+	}
+	c.mu.Lock()
+	alive := c.alive
+	c.mu.Unlock()
+
+	owners := make(map[string]string, len(endpoints))
+	if alive == nil {
+		return owners
+	}
+	groups := endpointGroups(endpoints)
+	for _, ep := range endpoints {
+		var best uint64
+		for _, member := range alive {
+			if score := rendezvous.Score(member, groups[ep.Name]); owners[ep.Name] == "" || score > best {
+				owners[ep.Name], best = member, score
+			}
+		}
+	}
+	return owners
+}
+
+// endpointGroups maps each endpoint to the smallest name among those tied
+// to it by all, any or depends_on, which it's assigned by, so a composite,
+// its members and what depends on them all land on one instance and see
+// each other's statuses
+func endpointGroups(endpoints []Endpoint) map[string]string {
+	parent := make(map[string]string, len(endpoints))
+	for _, ep := range endpoints {
+		parent[ep.Name] = ep.Name
+	}
+	find := func(name string) string {
+		for parent[name] != name {
+			parent[name] = parent[parent[name]]
+			name = parent[name]
+		}
+		return name
+	}
+	for _, ep := range endpoints {
+		for _, other := range slices.Concat(ep.All, ep.Any, ep.DependsOn) {
+			if _, ok := parent[other]; !ok {
+				continue
+			}
+			a, b := find(ep.Name), find(other)
+			if b < a {
+				a, b = b, a
+			}
+			parent[b] = a
+		}
+	}
+
+	groups := make(map[string]string, len(parent))
+	for name := range parent {
+		groups[name] = find(name)
+	}
+	return groups
+}
+
+// checkedBy returns the member that checks an endpoint if that's another
+// instance, or "". Called with hc.mu held.
+func (hc *HealthChecker) checkedBy(name string) string {
+	if owner := hc.owners[name]; hc.cluster != nil && owner != hc.cluster.id {
+		return owner
+	}
+	return ""
+}
+
+// etcdMembers registers members under a prefix in etcd, each key held by
+// a lease that lapses with its TTL
+type etcdMembers struct {
+	addr   string
+	prefix string
+	client *http.Client
+	lease  string // ID, as the gateway gives 64-bit numbers
+}
+
+func newEtcdMembers(addr, prefix string) *etcdMembers {
+	return &etcdMembers{addr: strings.TrimSuffix(addr, "/"), prefix: prefix, client: &http.Client{}}
+}
+
+func (e *etcdMembers) join(ctx context.Context, id string, ttl time.Duration) error {
+	var grant struct {
+		ID string `json:"ID"`
+	}
+	if err := e.call(ctx, "/v3/lease/grant", map[string]any{"TTL": int64(ttl.Seconds())}, &grant); err != nil {
+		return err
+	}
+
+	// Only if no other member holds the ID
+	key := []byte(e.prefix + id)
+	var txn struct {
+		Succeeded bool `json:"succeeded"`
+	}
+	err := e.call(ctx, "/v3/kv/txn", map[string]any{
+		"compare": []any{map[string]any{"key": key, "target": "CREATE", "create_revision": "0"}},
+		"success": []any{map[string]any{"request_put": map[string]any{"key": key, "value": []byte(id), "lease": grant.ID}}},
+	}, &txn)
+	if err == nil && !txn.Succeeded {
+		err = fmt.Errorf("member %s is registered already: by another instance with this -cluster-id, or by this one before a restart until that lapses", id)
+	}
+	if err != nil {
+		e.call(ctx, "/v3/lease/revoke", map[string]any{"ID": grant.ID}, nil)
+		return err
+	}
+	e.lease = grant.ID
+	return nil
+}
+
+func (e *etcdMembers) renew(ctx context.Context) error {
+	// A keepalive is a stream; one request gets one response
+	var resp struct {
+		Result struct {
+			TTL int64 `json:"TTL,string"`
+		} `json:"result"`
+	}
+	if err := e.call(ctx, "/v3/lease/keepalive", map[string]any{"ID": e.lease}, &resp); err != nil {
+		return err
+	}
+	if resp.Result.TTL <= 0 {
+		return errLapsed
+	}
+	return nil
+}
+
+func (e *etcdMembers) members(ctx context.Context) ([]string, error) {
+	var resp struct {
+		KVs []etcdKV `json:"kvs"`
+	}
+	err := e.call(ctx, "/v3/kv/range", map[string]any{
+		"key":       []byte(e.prefix),
+		"range_end": prefixEnd(e.prefix),
+		"keys_only": true,
+	}, &resp)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]string, 0, len(resp.KVs))
+	for _, kv := range resp.KVs {
+		ids = append(ids, strings.TrimPrefix(string(kv.Key), e.prefix))
+	}
+	return ids, nil
+}
+
+func (e *etcdMembers) leave(ctx context.Context) error {
+	return e.call(ctx, "/v3/lease/revoke", map[string]any{"ID": e.lease}, nil)
+}
+
+// call POSTs body to the gateway and decodes the first JSON response
+// into v, if v isn't nil
+func (e *etcdMembers) call(ctx context.Context, path string, body, v any) error {
+	resp, err := etcdPost(ctx, e.client, e.addr, path, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if v == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("POST %s: %v", path, err)
+	}
+	return nil
+}
+
+// consulMembers registers members as keys under a prefix in Consul's KV
+// store, each locked by a session that lapses with its TTL and takes the
+// key with it
+type consulMembers struct {
+	addr    string
+	prefix  string
+	token   string
+	client  *http.Client
+	session string
+}
+
+func newConsulMembers(addr, prefix, token string) *consulMembers {
+	return &consulMembers{addr: strings.TrimSuffix(addr, "/"), prefix: strings.TrimPrefix(prefix, "/"), token: token, client: &http.Client{}}
+}
+
+func (c *consulMembers) join(ctx context.Context, id string, ttl time.Duration) error {
+	var session struct{ ID string }
+	err := c.call(ctx, http.MethodPut, "/v1/session/create", nil, map[string]any{
+		"Name":      "healthcheck " + id,
+		"TTL":       ttl.String(),
+		"Behavior":  "delete",
+		"LockDelay": "0s",
+	}, &session)
+	if err != nil {
+		return err
+	}
+
+	var acquired bool
+	err = c.call(ctx, http.MethodPut, "/v1/kv/"+c.prefix+url.PathEscape(id), url.Values{"acquire": {session.ID}}, id, &acquired)
+	if err == nil && !acquired {
+		err = fmt.Errorf("member %s is registered already: by another instance with this -cluster-id, or by this one before a restart until that lapses", id)
+	}
+	if err != nil {
+		c.call(ctx, http.MethodPut, "/v1/session/destroy/"+session.ID, nil, nil, nil)
+		return err
+	}
+	c.session = session.ID
+	return nil
+}
+
+func (c *consulMembers) renew(ctx context.Context) error {
+	err := c.call(ctx, http.MethodPut, "/v1/session/renew/"+c.session, nil, nil, nil)
+	if errors.Is(err, errConsulNotFound) {
+		return errLapsed
+	}
+	return err
+}
+
+func (c *consulMembers) members(ctx context.Context) ([]string, error) {
+	var keys []struct {
+		Key     string
+		Session string
+	}
+	err := c.call(ctx, http.MethodGet, "/v1/kv/"+c.prefix, url.Values{"recurse": {"true"}}, nil, &keys)
+	if err != nil && !errors.Is(err, errConsulNotFound) {
+		return nil, err
+	}
+	var ids []string
+	for _, k := range keys {
+		if k.Session != "" { // a key left over from a session without delete
+			ids = append(ids, strings.TrimPrefix(k.Key, c.prefix))
+		}
+	}
+	return ids, nil
+}
+
+func (c *consulMembers) leave(ctx context.Context) error {
+	return c.call(ctx, http.MethodPut, "/v1/session/destroy/"+c.session, nil, nil, nil)
+}
+
+// errConsulNotFound is a Consul API 404
+var errConsulNotFound = errors.New("not found")
+
+// call sends body, JSON encoded unless it's a string, and decodes the
+// response into v, if v isn't nil
+func (c *consulMembers) call(ctx context.Context, method, path string, query url.Values, body, v any) error {
+	var r io.Reader
+	switch body := body.(type) {
+	case nil:
+	case string:
+		r = strings.NewReader(body)
+	default:
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		r = bytes.NewReader(data)
+	}
+	u := c.addr + path
+	if query != nil {
+		u += "?" + query.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u, r)
+	if err != nil {
+		return err
+	}
+	if c.token != "" {
+		req.Header.Set("X-Consul-Token", c.token)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return fmt.Errorf("%s %s: %w", method, path, errConsulNotFound)
+	case resp.StatusCode != http.StatusOK:
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%s %s: %s: %s", method, path, resp.Status, strings.TrimSpace(string(msg)))
+	case v == nil:
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("%s %s: %v", method, path, err)
+	}
+	return nil
+}
+
+// validClusterTTL checks -cluster-ttl: members renew at a third of it,
+// and Consul sessions take whole seconds from 10s up
+func validClusterTTL(ttl time.Duration, via string) error {
+	switch {
+	case ttl < 3*time.Second:
+		return fmt.Errorf("-cluster-ttl must be at least 3s, got %v", ttl)
+	case via == "consul" && ttl < 10*time.Second:
+		return fmt.Errorf("-cluster-ttl must be at least 10s for Consul sessions, got %v", ttl)
+	case ttl%time.Second != 0:
+		return fmt.Errorf("-cluster-ttl must be whole seconds, got %v", ttl)
+	}
+	return nil
+}
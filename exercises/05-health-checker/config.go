@@ -0,0 +1,355 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+)
+
+// loadEndpoints reads endpoints from a JSON, YAML (.yaml, .yml) or TOML
+// (.toml) file. The file holds a list of endpoints, or a table whose
+// "endpoints" key does (the only form TOML allows: [[endpoints]]).
+// ${VAR} and ${VAR:-default} in any string are replaced from the
+// environment first; $${ stands for a literal ${.
+func loadEndpoints(filename string) ([]Endpoint, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	var doc any
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".yaml", ".yml":
+		doc, err = parseYAML(data)
+	case ".toml":
+		doc, err = parseTOML(data)
+	default:
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.UseNumber()
+		err = dec.Decode(&doc)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", filename, err)
+	}
+
+	root := ""
+	if _, ok := doc.([]any); ok {
+		root = "endpoints" // as the table form would name it
+	}
+	if doc, err = interpolate(doc, root); err != nil {
+		return nil, fmt.Errorf("%s: %v", filename, err)
+	}
+
+	endpoints, err := decodeEndpoints(doc)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", filename, err)
+	}
+	return endpoints, nil
+}
+
+// asJSON returns v, as decoded from YAML or TOML, the way encoding/json
+// would have decoded it: []any rather than []map[string]any, RFC 3339
+// strings for times, json.Number for numbers. interpolate and
+// decodeEndpoints then see the same shapes whatever the format.
+func asJSON(v any) (any, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var doc any
+	err = dec.Decode(&doc)
+	return doc, err
+}
+
+// decodeEndpoints turns a parsed config into validated endpoints. Going
+// through JSON gives every format the same field names, types and
+// errors.
+func decodeEndpoints(doc any) ([]Endpoint, error) {
+	if table, ok := doc.(map[string]any); ok {
+		for key := range table {
+			if key != "endpoints" {
+				return nil, fmt.Errorf("unknown top-level key %q (want endpoints)", key)
+			}
+		}
+		doc = table["endpoints"]
+	}
+	list, ok := doc.([]any)
+	if !ok {
+		return nil, errors.New("want a list of endpoints")
+	}
+
+	endpoints := make([]Endpoint, len(list))
+	seen := make(map[string]bool)
+	tokens := make(map[string]string) // heartbeat token to endpoint
+	for i, item := range list {
+		where := fmt.Sprintf("endpoint #%d", i+1)
+		if m, ok := item.(map[string]any); ok {
+			if name, ok := m["name"].(string); ok && name != "" {
+				where = fmt.Sprintf("endpoint %q", name)
+			}
+		}
+
+		data, err := json.Marshal(item)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", where, err)
+		}
+		ep := &endpoints[i]
+		if err := json.Unmarshal(data, ep); err != nil {
+			return nil, fmt.Errorf("%s: %v", where, fieldError(err))
+		}
+		if err := ep.validate(); err != nil {
+			return nil, fmt.Errorf("%s: %v", where, err)
+		}
+		if seen[ep.Name] {
+			return nil, fmt.Errorf("%s: name used twice", where)
+		}
+		seen[ep.Name] = true
+		if ep.Type == "heartbeat" {
+			if other, ok := tokens[ep.Token]; ok {
+				return nil, fmt.Errorf("%s: token already used by %q", where, other)
+			}
+			tokens[ep.Token] = ep.Name
+		}
+	}
+	if err := checkGraph(endpoints); err != nil {
+		return nil, err
+	}
+	return endpoints, nil
+}
+
+// UnmarshalJSON decodes an endpoint strictly, so a misspelt field is an
+// error rather than silently ignored, and accepts durations as strings
+// ("5s", "250ms") as well as integer nanoseconds
+func (ep *Endpoint) UnmarshalJSON(data []byte) error {
+	type plain Endpoint // without this method
+	aux := struct {
+		*plain
+		Interval        json.RawMessage `json:"interval"`
+		Timeout         json.RawMessage `json:"timeout"`
+		RetryBackoff    json.RawMessage `json:"retry_backoff"`
+		WarnLatency     json.RawMessage `json:"warn_latency"`
+		CriticalLatency json.RawMessage `json:"critical_latency"`
+		MaxLatency      json.RawMessage `json:"max_latency"`
+		Grace           json.RawMessage `json:"grace"`
+		IdleConnTimeout json.RawMessage `json:"idle_conn_timeout"`
+		ExpectedStatus  json.RawMessage `json:"expected_status"`
+	}{plain: (*plain)(ep)}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&aux); err != nil {
+		return err
+	}
+
+	durations := []struct {
+		name string
+		raw  json.RawMessage
+		dst  *time.Duration
+	}{
+		{"interval", aux.Interval, &ep.Interval},
+		{"timeout", aux.Timeout, &ep.Timeout},
+		{"retry_backoff", aux.RetryBackoff, &ep.RetryBackoff},
+		{"warn_latency", aux.WarnLatency, &ep.WarnLatency},
+		{"critical_latency", aux.CriticalLatency, &ep.CriticalLatency},
+		{"max_latency", aux.MaxLatency, &ep.MaxLatency},
+		{"grace", aux.Grace, &ep.Grace},
+		{"idle_conn_timeout", aux.IdleConnTimeout, &ep.IdleConnTimeout},
+	}
+	for _, d := range durations {
+		if d.raw == nil {
+			continue
+		}
+		v, err := parseDuration(d.raw)
+		if err != nil {
+			return fmt.Errorf("%s: %v", d.name, err)
+		}
+		*d.dst = v
+	}
+	if aux.ExpectedStatus != nil {
+		if err := json.Unmarshal(aux.ExpectedStatus, &ep.ExpectedStatus); err != nil {
+			return fmt.Errorf("expected_status: %v", err)
+		}
+	}
+	return nil
+}
+
+// parseDuration reads a JSON duration: a Go duration string or, as
+// configs written before strings were accepted have, nanoseconds
+func parseDuration(raw json.RawMessage) (time.Duration, error) {
+	var d time.Duration
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		v, err := time.ParseDuration(s)
+		if err != nil {
+			return 0, fmt.Errorf("bad duration %q (want e.g. \"5s\" or \"250ms\")", s)
+		}
+		d = v
+	} else if err := json.Unmarshal(raw, &d); err != nil {
+		return 0, fmt.Errorf("want a duration like \"5s\", got %s", raw)
+	}
+	if d < 0 {
+		return 0, errors.New("can't be negative")
+	}
+	return d, nil
+}
+
+// fieldError rewords encoding/json's errors to lead with the field
+func fieldError(err error) error {
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) && typeErr.Field != "" {
+		return fmt.Errorf("%s: want %s, got %s", typeErr.Field, typeErr.Type, typeErr.Value)
+	}
+	return errors.New(strings.TrimPrefix(err.Error(), "json: "))
+}
+
+// validate checks an endpoint makes sense for its type and fills in the
+// defaults
+func (ep *Endpoint) validate() error {
+	if ep.Name == "" {
+		return errors.New("name is required")
+	}
+
+	if ep.Type == "" {
+		ep.Type = "http"
+	}
+	t, ok := checkerTypes[ep.Type]
+	if !ok {
+		return fmt.Errorf("unknown type %q (want %s)", ep.Type, checkerNames())
+	}
+	if t.validate != nil {
+		if err := t.validate(ep); err != nil {
+			return err
+		}
+	}
+
+	if ep.SRV != "" && ep.Type != "http" && ep.Type != "tcp" && ep.Type != "grpc" {
+		return fmt.Errorf("%s checks can't use srv", ep.Type)
+	}
+
+	if len(ep.Interfaces) > 0 {
+		switch {
+		case ep.Type == "heartbeat" || ep.Type == "composite":
+			return fmt.Errorf("%s checks can't use interfaces", ep.Type)
+		case ep.Type == "dns" && ep.Resolver == "":
+			return errors.New("dns checks from interfaces need a resolver to ask")
+		}
+		seen := make(map[string]bool)
+		for _, source := range ep.Interfaces {
+			switch {
+			case source == "":
+				return errors.New("interfaces: empty entry")
+			case seen[source]:
+				return fmt.Errorf("interfaces: %s listed twice", source)
+			}
+			seen[source] = true
+		}
+	}
+
+	if ep.Proxy != "" {
+		if slices.Contains([]string{"dns", "heartbeat", "icmp", "composite"}, ep.Type) {
+			return fmt.Errorf("%s checks can't use a proxy", ep.Type)
+		}
+		u, err := parseProxy(ep.Proxy)
+		if err != nil {
+			return fmt.Errorf("proxy: %v", err)
+		}
+		ep.proxyURL = u
+	}
+	if err := ep.validateResolution(); err != nil {
+		return err
+	}
+
+	if err := ep.buildTLS(); err != nil {
+		return err
+	}
+	if ep.tlsConfig != nil {
+		if ep.InsecureSkipVerify {
+			log.Printf("⚠️  %s: insecure_skip_verify is set; its certificate won't be checked", ep.Name)
+		}
+		switch {
+		case ep.Type == "http" && !strings.HasPrefix(strings.ToLower(ep.URL), "https://"):
+			return errors.New("TLS options need an https url")
+		case ep.Type == "grpc" && !ep.TLS:
+			return errors.New("TLS options need tls: true")
+		case ep.Type != "http" && ep.Type != "grpc":
+			return fmt.Errorf("%s checks don't use TLS", ep.Type)
+		}
+	}
+
+	for i := range ep.Maintenance {
+		if err := ep.Maintenance[i].validate(); err != nil {
+			return fmt.Errorf("maintenance[%d]: %v", i, err)
+		}
+	}
+
+	if ep.Interval == 0 {
+		ep.Interval = 5 * time.Second
+	}
+	if ep.Timeout == 0 {
+		ep.Timeout = 3 * time.Second
+	}
+	if ep.FailureThreshold < 1 {
+		ep.FailureThreshold = 1
+	}
+	if ep.SuccessThreshold < 1 {
+		ep.SuccessThreshold = 1
+	}
+	if ep.Retries > 0 && ep.RetryBackoff == 0 {
+		ep.RetryBackoff = 500 * time.Millisecond
+	}
+	return nil
+}
+
+// envRef matches ${VAR}, ${VAR:-default} and the $${ escape
+var envRef = regexp.MustCompile(`\$\$\{|\$\{([A-Za-z_][A-Za-z0-9_]*)(?::-([^}]*))?\}`)
+
+// interpolate replaces environment references in every string in v.
+// path locates v in the config, for errors.
+func interpolate(v any, path string) (any, error) {
+	switch v := v.(type) {
+	case string:
+		var err error
+		s := envRef.ReplaceAllStringFunc(v, func(ref string) string {
+			if ref == "$${" {
+				return "${"
+			}
+			m := envRef.FindStringSubmatch(ref)
+			if value, ok := os.LookupEnv(m[1]); ok {
+				return value
+			}
+			if strings.Contains(ref, ":-") {
+				return m[2]
+			}
+			if err == nil {
+				err = fmt.Errorf("%s: environment variable %s is not set", strings.TrimPrefix(path, "."), m[1])
+			}
+			return ""
+		})
+		return s, err
+	case []any:
+		for i := range v {
+			var err error
+			if v[i], err = interpolate(v[i], fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return nil, err
+			}
+		}
+	case map[string]any:
+		for key := range v {
+			var err error
+			if v[key], err = interpolate(v[key], path+"."+key); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return v, nil
+}
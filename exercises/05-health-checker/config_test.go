@@ -0,0 +1,156 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// load writes config to a file with ext in a temp dir and loads it
+func load(t *testing.T, ext, config string) ([]Endpoint, error) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "endpoints"+ext)
+	if err := os.WriteFile(path, []byte(config), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return loadEndpoints(path)
+}
+
+func TestLoadEndpoints(t *testing.T) {
+	t.Setenv("API_HOST", "api.example.com")
+
+	tests := []struct {
+		name, ext, config string
+	}{
+		{"json", ".json", `[
+			{"name": "api", "url": "https://${API_HOST}/health", "interval": "30s", "expected_status": [200, 204]}
+		]`},
+		{"yaml list", ".yaml", `
+- name: api  # comment
+  url: https://${API_HOST}/health
+  interval: 30s
+  expected_status: [200, 204]
+`},
+		{"yaml table", ".yml", `
+endpoints:
+  - name: api
+    url: "https://${API_HOST}/health"
+    interval: 30s
+    expected_status:
+      - 200
+      - 204
+`},
+		{"toml", ".toml", `
+[[endpoints]]
+name = "api"
+url = "https://${API_HOST}/health"
+interval = "30s"
+expected_status = [200, 204]
+`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			endpoints, err := load(t, tt.ext, tt.config)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(endpoints) != 1 {
+				t.Fatalf("got %d endpoints, want 1", len(endpoints))
+			}
+			ep := endpoints[0]
+			if ep.Name != "api" || ep.URL != "https://api.example.com/health" || ep.Interval != 30*time.Second {
+				t.Errorf("got %q %q every %v", ep.Name, ep.URL, ep.Interval)
+			}
+			if !ep.ExpectedStatus.Contains(204) || ep.ExpectedStatus.Contains(500) {
+				t.Errorf("expected_status = %v, want 200 and 204", ep.ExpectedStatus)
+			}
+		})
+	}
+}
+
+func TestLoadEndpointsMaintenanceTimes(t *testing.T) {
+	want := time.Date(2026, 3, 1, 2, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name, ext, config string
+	}{
+		{"yaml timestamp", ".yaml", `
+- name: api
+  url: https://example.com
+  maintenance:
+    - start: 2026-03-01T02:00:00Z
+      end: 2026-03-01T04:00:00Z
+`},
+		{"toml offset datetime", ".toml", `
+[[endpoints]]
+name = "api"
+url = "https://example.com"
+maintenance = [{start = 2026-03-01T02:00:00Z, end = 2026-03-01T04:00:00Z}]
+`},
+		{"toml string", ".toml", `
+[[endpoints]]
+name = "api"
+url = "https://example.com"
+
+[[endpoints.maintenance]]
+start = "2026-03-01T02:00:00Z"
+end = "2026-03-01T04:00:00Z"
+`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			endpoints, err := load(t, tt.ext, tt.config)
+			if err != nil {
+				t.Fatal(err)
+			}
+			windows := endpoints[0].Maintenance
+			if len(windows) != 1 || !windows[0].Start.Equal(want) || !windows[0].End.Equal(want.Add(2*time.Hour)) {
+				t.Errorf("maintenance = %+v, want 02:00-04:00 UTC on 2026-03-01", windows)
+			}
+		})
+	}
+}
+
+func TestLoadEndpointsTOMLLocalDatetime(t *testing.T) {
+	endpoints, err := load(t, ".toml", `
+[[endpoints]]
+name = "api"
+url = "https://example.com"
+maintenance = [{start = 2026-03-01T02:00:00, end = 2026-03-01T04:00:00}]
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := time.Date(2026, 3, 1, 2, 0, 0, 0, time.Local)
+	if got := endpoints[0].Maintenance[0].Start; !got.Equal(want) {
+		t.Errorf("start = %v, want %v local time", got, want)
+	}
+}
+
+func TestLoadEndpointsErrors(t *testing.T) {
+	tests := []struct {
+		name, ext, config string
+		want              string // in the error
+	}{
+		{"yaml nested mapping value", ".yaml", "- name: api\n  url: value: other\n", "line 2"},
+		{"yaml tab indent", ".yaml", "- name: api\n\turl: https://example.com\n", "line 2"},
+		{"yaml duplicate key", ".yaml", "- name: api\n  url: https://example.com\n  url: https://example.org\n", "line 3"},
+		{"toml missing value", ".toml", "[[endpoints]]\nname = \"api\"\nurl =\n", "line 3"},
+		{"toml duplicate key", ".toml", "[[endpoints]]\nname = \"api\"\nname = \"b\"\n", "line 3"},
+		{"toml bad datetime", ".toml", "[[endpoints]]\nname = \"api\"\nx = 2026-13-01T00:00:00Z\n", "line 3"},
+		{"unknown field", ".yaml", "- name: api\n  url: https://example.com\n  intervall: 5s\n", `unknown field "intervall"`},
+		{"unknown top-level key", ".toml", "[[endpoint]]\nname = \"api\"\n", `unknown top-level key "endpoint"`},
+		{"unset variable", ".yaml", "- name: api\n  url: https://${HEALTH_TEST_UNSET}/\n", "endpoints[0].url: environment variable HEALTH_TEST_UNSET is not set"},
+		{"empty", ".yaml", "# nothing\n", "want a list of endpoints"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := load(t, tt.ext, tt.config)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("error = %v, want one mentioning %q", err, tt.want)
+			}
+		})
+	}
+}
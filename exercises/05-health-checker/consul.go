@@ -0,0 +1,191 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"maps"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// consulWait is how long a blocking query waits for the catalog to
+// change before returning anyway
+const consulWait = 5 * time.Minute
+
+// consulDiscovery keeps endpoints in step with the services in a Consul
+// catalog that carry a tag, one endpoint per instance. It watches with
+// blocking queries, which return as soon as the catalog changes.
+// Instance metadata says how to check it:
+//
+//	healthcheck-path     an http check of this path (default /)
+//	healthcheck-type     tcp or grpc instead
+//	healthcheck-scheme   http or https (default http)
+//	healthcheck-config   any other endpoint fields, as JSON: {"interval": "10s"}
+type consulDiscovery struct {
+	hc       *HealthChecker
+	addr     string // e.g. http://127.0.0.1:8500
+	tag      string
+	token    string // ACL token, if the catalog needs one
+	client   *http.Client
+	problems discoveryProblems
+}
+
+// consulInstance is the part of a catalog entry discovery reads
+type consulInstance struct {
+	Node           string
+	Address        string // the node's
+	ServiceID      string
+	ServiceName    string
+	ServiceAddress string // the instance's, if it differs from the node's
+	ServicePort    int
+	ServiceMeta    map[string]string
+}
+
+func newConsulDiscovery(hc *HealthChecker, addr, tag, token string) *consulDiscovery {
+	return &consulDiscovery{
+		hc:       hc,
+		addr:     strings.TrimSuffix(addr, "/"),
+		tag:      tag,
+		token:    token,
+		client:   &http.Client{Timeout: consulWait + 30*time.Second}, // Consul adds up to wait/16 of jitter
+		problems: make(discoveryProblems),
+	}
+}
+
+// run follows the catalog until ctx is cancelled
+func (c *consulDiscovery) run(ctx context.Context) {
+	index, failing := uint64(0), false
+	for {
+		endpoints, next, err := c.sync(ctx, index)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			if !failing {
+				c.hc.event(slog.LevelError, fmt.Sprintf("❌ Consul discovery: %v", err), "discovery failed", "source", "consul", "error", err)
+			}
+			failing, index = true, 0
+			if !sleepCtx(ctx, discoveryRetry) {
+				return
+			}
+			continue
+		}
+		if failing {
+			c.hc.event(slog.LevelInfo, "🧭 Consul discovery: reading the catalog again", "discovery recovered", "source", "consul")
+			failing = false
+		}
+
+		c.hc.update(ctx, "consul", endpoints)
+		// An index that goes backwards means Consul's state was reset
+		if next < index {
+			next = 0
+		}
+		index = next
+	}
+}
+
+// sync waits for the catalog's services to change from index, then reads
+// every tagged service's instances. It returns their endpoints and the
+// index to wait on next.
+func (c *consulDiscovery) sync(ctx context.Context, index uint64) ([]Endpoint, uint64, error) {
+	var services map[string][]string
+	next, err := c.get(ctx, "/v1/catalog/services", url.Values{
+		"index": {strconv.FormatUint(index, 10)},
+		"wait":  {consulWait.String()},
+	}, &services)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var endpoints []Endpoint
+	problems := make(map[string]string)
+	for _, service := range slices.Sorted(maps.Keys(services)) {
+		if !slices.Contains(services[service], c.tag) {
+			continue
+		}
+		var instances []consulInstance
+		if _, err := c.get(ctx, "/v1/catalog/service/"+url.PathEscape(service), url.Values{"tag": {c.tag}}, &instances); err != nil {
+			return nil, 0, err
+		}
+		for _, inst := range instances {
+			name := "consul/" + service + "/" + inst.Node
+			if inst.ServiceID != inst.ServiceName {
+				name += "/" + inst.ServiceID
+			}
+			ep, err := c.endpoint(name, inst)
+			if err != nil {
+				problems[name] = err.Error()
+				continue
+			}
+			endpoints = append(endpoints, ep)
+		}
+	}
+	c.problems.report(c.hc, "consul", problems)
+	return endpoints, next, nil
+}
+
+// endpoint builds the check for one instance from its metadata
+func (c *consulDiscovery) endpoint(name string, inst consulInstance) (Endpoint, error) {
+	host := inst.ServiceAddress
+	if host == "" {
+		host = inst.Address
+	}
+	if host == "" || inst.ServicePort == 0 {
+		return Endpoint{}, fmt.Errorf("no address and port registered")
+	}
+	addr := net.JoinHostPort(host, strconv.Itoa(inst.ServicePort))
+
+	meta := inst.ServiceMeta
+	fields := map[string]any{"name": name}
+	switch typ := meta["healthcheck-type"]; typ {
+	case "tcp", "grpc":
+		fields["type"], fields["address"] = typ, addr
+	case "", "http":
+		scheme, path := meta["healthcheck-scheme"], meta["healthcheck-path"]
+		if scheme == "" {
+			scheme = "http"
+		}
+		if path == "" {
+			path = "/"
+		}
+		if !strings.HasPrefix(path, "/") {
+			return Endpoint{}, fmt.Errorf("healthcheck-path %q must start with /", path)
+		}
+		fields["url"] = scheme + "://" + addr + path
+	default:
+		return Endpoint{}, fmt.Errorf("healthcheck-type %q: want http, tcp or grpc", typ)
+	}
+	return discoveredEndpoint(fields, meta["healthcheck-config"])
+}
+
+// get decodes a Consul API response into v and returns its index
+func (c *consulDiscovery) get(ctx context.Context, path string, query url.Values, v any) (uint64, error) {
+	u := c.addr + path + "?" + query.Encode()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return 0, err
+	}
+	if c.token != "" {
+		req.Header.Set("X-Consul-Token", c.token)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("GET %s: %s", path, resp.Status)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return 0, fmt.Errorf("GET %s: %v", path, err)
+	}
+	index, _ := strconv.ParseUint(resp.Header.Get("X-Consul-Index"), 10, 64)
+	return index, nil
+}
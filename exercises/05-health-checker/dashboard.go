@@ -0,0 +1,320 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+//go:embed static/index.html
+var dashboardHTML []byte
+
+// defaultHistory is how many checks /api/endpoints/{name} returns
+// without ?limit
+const defaultHistory = 100
+
+// endpointJSON is one endpoint as the status API reports it
+type endpointJSON struct {
+	Name      string       `json:"name"`
+	URL       string       `json:"url"`
+	State     string       `json:"state"` // "up", "degraded", "down", "skipped" while a dependency is down, "pending" before the first check, or "remote" if another cluster member checks it
+	LastOK    bool         `json:"last_ok"`
+	Streak    int          `json:"streak"` // checks in a row with the last one's result
+	Attempts  int          `json:"attempts,omitempty"`
+	LatencyMS float64      `json:"latency_ms"`
+	LastCheck *time.Time   `json:"last_check,omitempty"`
+	Error     string       `json:"error,omitempty"`
+	Protocol  string       `json:"protocol,omitempty"`   // what the last http response came over
+	Muted     string       `json:"muted,omitempty"`      // why failures aren't alerted on, during maintenance or a silence
+	Skipped   string       `json:"skipped,omitempty"`    // which dependency is down
+	CheckedBy string       `json:"checked_by,omitempty"` // the cluster member checking it, if another
+	Stats     *windowStats `json:"stats,omitempty"`      // over the last statsWindow
+	Phases    *phasesJSON  `json:"phases,omitempty"`     // http checks that got a response
+	Partial   string       `json:"partial,omitempty"`    // srv checks with only some targets down, or checks failing from only some interfaces
+	Targets   []targetJSON `json:"targets,omitempty"`    // srv checks: each target's last check
+	Sources   []sourceJSON `json:"sources,omitempty"`    // checks with interfaces: the last check from each
+}
+
+// sourceJSON is a sourceResult in milliseconds
+type sourceJSON struct {
+	Source    string  `json:"source"`
+	Address   string  `json:"address,omitempty"`
+	LatencyMS float64 `json:"latency_ms"`
+	Error     string  `json:"error,omitempty"`
+}
+
+// targetJSON is an srvTarget in milliseconds
+type targetJSON struct {
+	Address   string  `json:"address"`
+	LatencyMS float64 `json:"latency_ms"`
+	Error     string  `json:"error,omitempty"`
+}
+
+// phasesJSON is an httpPhases in milliseconds
+type phasesJSON struct {
+	DNSMS     float64 `json:"dns_ms"`
+	ConnectMS float64 `json:"connect_ms"`
+	TLSMS     float64 `json:"tls_ms"`
+	TTFBMS    float64 `json:"ttfb_ms"`
+	Reused    bool    `json:"reused"`
+}
+
+// snapshot returns every endpoint's status, in config order
+func (hc *HealthChecker) snapshot() []endpointJSON {
+	hc.mu.RLock()
+	defer hc.mu.RUnlock()
+
+	list := make([]endpointJSON, 0, len(hc.endpoints))
+	for i := range hc.endpoints {
+		list = append(list, hc.endpointStatus(&hc.endpoints[i]))
+	}
+	return list
+}
+
+// stateName is an endpoint's state after thresholds, as the status API
+// and structured logs name it
+func stateName(status *HealthStatus) string {
+	switch {
+	case status.Skipped != "":
+		return "skipped"
+	case status.Degraded:
+		return "degraded"
+	case status.Healthy:
+		return "up"
+	}
+	return "down"
+}
+
+// endpointStatus reports one endpoint. Called with hc.mu held.
+func (hc *HealthChecker) endpointStatus(ep *Endpoint) endpointJSON {
+	e := endpointJSON{Name: ep.Name, URL: ep.target(), State: "pending"}
+	if e.CheckedBy = hc.checkedBy(ep.Name); e.CheckedBy != "" {
+		e.State = "remote"
+		return e
+	}
+	status, ok := hc.statuses[ep.Name]
+	if !ok {
+		return e
+	}
+	e.State = stateName(status)
+	e.LastOK = status.LastOK
+	e.Streak = status.Streak
+	e.Attempts = status.Attempts
+	e.LatencyMS = float64(status.Latency.Microseconds()) / 1000
+	e.LastCheck = &status.LastCheck
+	e.Error = status.Error
+	e.Muted = status.Muted
+	e.Skipped = status.Skipped
+	e.Partial = status.Partial
+	for _, t := range status.Targets {
+		e.Targets = append(e.Targets, targetJSON{Address: t.Address, LatencyMS: float64(t.Latency.Microseconds()) / 1000, Error: t.Error})
+	}
+	for _, s := range status.Sources {
+		e.Sources = append(e.Sources, sourceJSON{Source: s.Source, Address: s.Address, LatencyMS: float64(s.Latency.Microseconds()) / 1000, Error: s.Error})
+	}
+	stats := hc.windows[ep.Name].stats()
+	e.Stats = &stats
+	if p := status.Phases; p != nil {
+		e.Protocol = p.Proto
+	}
+	if p := status.Phases; p != nil && p.TTFB > 0 {
+		e.Phases = &phasesJSON{
+			DNSMS:     float64(p.DNS.Microseconds()) / 1000,
+			ConnectMS: float64(p.Connect.Microseconds()) / 1000,
+			TLSMS:     float64(p.TLS.Microseconds()) / 1000,
+			TTFBMS:    float64(p.TTFB.Microseconds()) / 1000,
+			Reused:    p.Reused,
+		}
+	}
+	return e
+}
+
+// checkJSON is one check in an endpoint's history
+type checkJSON struct {
+	Time      time.Time `json:"time"`
+	OK        bool      `json:"ok"`
+	LatencyMS float64   `json:"latency_ms"`
+	Error     string    `json:"error,omitempty"`
+}
+
+// endpointDetailJSON is one endpoint with its recent checks
+type endpointDetailJSON struct {
+	endpointJSON
+	History []checkJSON   `json:"history"`          // newest first, from the last statsWindow
+	Uptime  *uptimeReport `json:"uptime,omitempty"` // with -db
+}
+
+// endpointDetail reports the named endpoint and up to limit of its
+// latest checks, or false if there's no such endpoint
+func (hc *HealthChecker) endpointDetail(name string, limit int) (endpointDetailJSON, bool) {
+	hc.mu.RLock()
+	defer hc.mu.RUnlock()
+
+	for i := range hc.endpoints {
+		ep := &hc.endpoints[i]
+		if ep.Name != name {
+			continue
+		}
+		d := endpointDetailJSON{endpointJSON: hc.endpointStatus(ep), History: []checkJSON{}}
+		if w := hc.windows[name]; w != nil {
+			for j := len(w.samples) - 1; j >= 0 && len(d.History) < limit; j-- {
+				s := w.samples[j]
+				d.History = append(d.History, checkJSON{
+					Time:      s.at,
+					OK:        s.ok,
+					LatencyMS: float64(s.latency.Microseconds()) / 1000,
+					Error:     s.err,
+				})
+			}
+		}
+		return d, true
+	}
+	return endpointDetailJSON{}, false
+}
+
+// admin guards a handler that changes state with token, if there is one
+func admin(token string, h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if token != "" && subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), []byte("Bearer "+token)) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		h(w, r)
+	}
+}
+
+// writeJSON sends v as an uncached JSON response
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("Status API: %v", err)
+	}
+}
+
+// serveUI runs the status page and its JSON API on addr until ctx is
+// cancelled. The page polls /api/status, so it stays live without a
+// reload; /api/endpoints/{name} adds one endpoint's recent checks. With
+// -db, /api/uptime reports uptime and outages too. /api/silences is the
+// admin API, guarded by adminToken if there is one.
+func serveUI(ctx context.Context, addr, adminToken string, hc *HealthChecker) error {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /{$}", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write(dashboardHTML)
+	})
+	mux.HandleFunc("GET /api/status", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, map[string]any{"endpoints": hc.snapshot()})
+	})
+	mux.HandleFunc("GET /api/endpoints/{name}", func(w http.ResponseWriter, r *http.Request) {
+		limit := defaultHistory
+		if s := r.URL.Query().Get("limit"); s != "" {
+			n, err := strconv.Atoi(s)
+			if err != nil || n < 0 {
+				http.Error(w, "limit must be a number of checks", http.StatusBadRequest)
+				return
+			}
+			limit = n
+		}
+
+		name := r.PathValue("name")
+		detail, ok := hc.endpointDetail(name, limit)
+		if !ok {
+			http.Error(w, fmt.Sprintf("no endpoint %q", name), http.StatusNotFound)
+			return
+		}
+		if hc.store != nil {
+			reports, err := hc.store.Report([]string{name}, time.Now())
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			detail.Uptime = &reports[0]
+		}
+		writeJSON(w, detail)
+	})
+	mux.HandleFunc("GET /api/silences", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, map[string]any{"silences": hc.listSilences()})
+	})
+	mux.HandleFunc("POST /api/silences", admin(adminToken, func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Endpoint string `json:"endpoint"`
+			Duration string `json:"duration"`
+			Reason   string `json:"reason"`
+		}
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64<<10)).Decode(&req); err != nil {
+			http.Error(w, "bad request: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		d, err := time.ParseDuration(req.Duration)
+		if err != nil || d <= 0 {
+			http.Error(w, `duration must be positive, e.g. "30m"`, http.StatusBadRequest)
+			return
+		}
+		s, err := hc.addSilence(req.Endpoint, d, req.Reason)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		log.Printf("🔇 Silenced %s until %s (%s)", s.Endpoint, s.Until.Local().Format(time.TimeOnly), r.RemoteAddr)
+		w.WriteHeader(http.StatusCreated)
+		writeJSON(w, s)
+	}))
+	mux.HandleFunc("DELETE /api/silences/{id}", admin(adminToken, func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.Atoi(r.PathValue("id"))
+		if err != nil || !hc.removeSilence(id) {
+			http.Error(w, "no such silence", http.StatusNotFound)
+			return
+		}
+		log.Printf("🔔 Silence %d lifted (%s)", id, r.RemoteAddr)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	if hc.store != nil {
+		mux.HandleFunc("GET /api/uptime", func(w http.ResponseWriter, r *http.Request) {
+			hc.mu.RLock()
+			names := make([]string, len(hc.endpoints))
+			for i, ep := range hc.endpoints {
+				names[i] = ep.Name
+			}
+			hc.mu.RUnlock()
+			reports, err := hc.store.Report(names, time.Now())
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			writeJSON(w, map[string]any{"endpoints": reports})
+		})
+	}
+
+	server := &http.Server{
+		Handler:           mux,
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(shutdownCtx)
+	}()
+
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("🌐 Status page on http://%s", ln.Addr())
+	if err := server.Serve(ln); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+func init() {
+	RegisterChecker("composite", func(hc *HealthChecker) Checker { return compositeChecker{hc} }, (*Endpoint).validateComposite)
+}
+
+// compositeChecker derives a composite endpoint's health from its
+// members' current states: up if all of them are (all), or if any is
+// (any). A member that is down, skipped or not yet checked counts as
+// not up.
+type compositeChecker struct{ hc *HealthChecker }
+
+func (c compositeChecker) Check(ctx context.Context, ep *Endpoint) Result {
+	return Result{Err: c.hc.checkComposite(ep)}
+}
+
+// Pending until every member has been checked, so the first verdict
+// isn't taken from members still pending
+func (c compositeChecker) Pending(ep *Endpoint) bool {
+	return !c.hc.membersChecked(ep)
+}
+
+func (hc *HealthChecker) checkComposite(ep *Endpoint) error {
+	hc.mu.RLock()
+	defer hc.mu.RUnlock()
+
+	members := ep.All
+	if len(ep.Any) > 0 {
+		members = ep.Any
+	}
+	var notUp []string
+	for _, name := range members {
+		if !hc.isUp(name) {
+			notUp = append(notUp, name)
+		}
+	}
+
+	switch {
+	case len(ep.All) > 0 && len(notUp) > 0:
+		return fmt.Errorf("not up: %s", strings.Join(notUp, ", "))
+	case len(ep.Any) > 0 && len(notUp) == len(members):
+		return fmt.Errorf("none up: %s", strings.Join(notUp, ", "))
+	}
+	return nil
+}
+
+// isUp reports whether the named endpoint was up when last checked.
+// Called with hc.mu held.
+func (hc *HealthChecker) isUp(name string) bool {
+	status, ok := hc.statuses[name]
+	return ok && status.Healthy && status.Skipped == ""
+}
+
+// downDependency returns the endpoint whose outage means ep shouldn't
+// be checked, or "" if it should: a dependency that is down (or the one
+// behind a dependency that is skipped in turn), or for a composite, the
+// one behind a member that is skipped. A dependency not checked yet
+// doesn't hold ep up.
+func (hc *HealthChecker) downDependency(ep *Endpoint) string {
+	hc.mu.RLock()
+	defer hc.mu.RUnlock()
+
+	for _, name := range ep.DependsOn {
+		if status, ok := hc.statuses[name]; ok && !hc.isUp(name) {
+			if status.Skipped != "" {
+				return status.Skipped
+			}
+			return name
+		}
+	}
+
+	// A composite over a skipped member would only repeat its
+	// dependency's outage; an any composite with a member up is fine
+	anyUp, skippedBy := false, ""
+	for _, name := range slices.Concat(ep.All, ep.Any) {
+		if status, ok := hc.statuses[name]; ok && status.Skipped != "" && skippedBy == "" {
+			skippedBy = status.Skipped
+		}
+		anyUp = anyUp || hc.isUp(name)
+	}
+	if skippedBy != "" && (len(ep.All) > 0 || !anyUp) {
+		return skippedBy
+	}
+	return ""
+}
+
+// membersChecked reports whether every member of a composite has been
+// checked, so its first verdict isn't taken from members still pending
+func (hc *HealthChecker) membersChecked(ep *Endpoint) bool {
+	hc.mu.RLock()
+	defer hc.mu.RUnlock()
+	for _, name := range slices.Concat(ep.All, ep.Any) {
+		if _, ok := hc.statuses[name]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// skip records that ep wasn't checked because dependency is down. Its
+// state, streak and history stay as they were, and nothing is alerted,
+// stored or counted: the dependency's own alert covers it.
+func (hc *HealthChecker) skip(ep *Endpoint, dependency string) {
+	hc.mu.Lock()
+	defer hc.mu.Unlock()
+
+	status := &HealthStatus{Endpoint: ep}
+	if prev := hc.statuses[ep.Name]; prev != nil {
+		*status = *prev
+	}
+	status.Skipped = dependency
+	status.LastCheck = time.Now()
+	hc.statuses[ep.Name] = status
+	hc.logSkip(ep, dependency)
+}
+
+// checkGraph checks that every endpoint named by depends_on, all and any
+// exists, and that following them never leads back where it started
+func checkGraph(endpoints []Endpoint) error {
+	byName := make(map[string]*Endpoint, len(endpoints))
+	for i := range endpoints {
+		byName[endpoints[i].Name] = &endpoints[i]
+	}
+	edges := func(ep *Endpoint) []string {
+		return append(append(append([]string(nil), ep.DependsOn...), ep.All...), ep.Any...)
+	}
+
+	for i := range endpoints {
+		ep := &endpoints[i]
+		for _, name := range edges(ep) {
+			if byName[name] == nil {
+				return fmt.Errorf("endpoint %q: no endpoint %q to depend on", ep.Name, name)
+			}
+		}
+	}
+
+	// Depth-first, with the path so far to name a cycle
+	const (
+		unvisited = iota
+		visiting
+		done
+	)
+	state := make(map[string]int, len(endpoints))
+	var path []string
+	var visit func(name string) error
+	visit = func(name string) error {
+		switch state[name] {
+		case visiting:
+			i := slices.Index(path, name)
+			return fmt.Errorf("endpoints depend on each other: %s -> %s", strings.Join(path[i:], " -> "), name)
+		case done:
+			return nil
+		}
+		state[name] = visiting
+		path = append(path, name)
+		for _, next := range edges(byName[name]) {
+			if err := visit(next); err != nil {
+				return err
+			}
+		}
+		path = path[:len(path)-1]
+		state[name] = done
+		return nil
+	}
+	for i := range endpoints {
+		if err := visit(endpoints[i].Name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateComposite checks a composite endpoint names its members one
+// way
+func (ep *Endpoint) validateComposite() error {
+	switch {
+	case len(ep.All) > 0 && len(ep.Any) > 0:
+		return errors.New("composite check takes all or any, not both")
+	case len(ep.All) == 0 && len(ep.Any) == 0:
+		return errors.New("composite check needs all or any")
+	}
+	return nil
+}
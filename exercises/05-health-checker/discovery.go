@@ -0,0 +1,75 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"maps"
+	"slices"
+	"time"
+)
+
+// discoveryRetry is how long discovery waits after failing to reach its
+// registry before trying again
+const discoveryRetry = 5 * time.Second
+
+// discoveredEndpoint builds an endpoint from fields as a config file
+// would give them, with extra, a JSON object of any other endpoint
+// fields from the registry (e.g. {"interval": "10s"}), laid over them.
+// It gets the same defaults and validation as a config's.
+func discoveredEndpoint(fields map[string]any, extra string) (Endpoint, error) {
+	if extra != "" {
+		var more map[string]any
+		dec := json.NewDecoder(bytes.NewReader([]byte(extra)))
+		dec.UseNumber()
+		if err := dec.Decode(&more); err != nil {
+			return Endpoint{}, fmt.Errorf("config: want a JSON object of endpoint fields: %v", err)
+		}
+		if _, ok := more["name"]; ok {
+			return Endpoint{}, fmt.Errorf("config: the name comes from discovery")
+		}
+		maps.Copy(fields, more)
+	}
+
+	data, err := json.Marshal(fields)
+	if err != nil {
+		return Endpoint{}, err
+	}
+	var ep Endpoint
+	if err := json.Unmarshal(data, &ep); err != nil {
+		return Endpoint{}, fieldError(err)
+	}
+	if ep.Type == "composite" || len(ep.DependsOn) > 0 {
+		return Endpoint{}, fmt.Errorf("composite checks and depends_on need a config file")
+	}
+	return ep, ep.validate()
+}
+
+// discoveryProblems logs why discovered objects couldn't be turned into
+// endpoints, each once until it changes, rather than at every refresh
+type discoveryProblems map[string]string
+
+// report logs problems new since the last call, by object, and forgets
+// those that went away
+func (p discoveryProblems) report(hc *HealthChecker, source string, now map[string]string) {
+	for _, key := range slices.Sorted(maps.Keys(now)) {
+		if p[key] != now[key] {
+			hc.event(slog.LevelWarn, fmt.Sprintf("⚠️  %s: skipping %s: %s", source, key, now[key]),
+				"discovery skipped", "source", source, "object", key, "error", now[key])
+		}
+	}
+	clear(p)
+	maps.Copy(p, now)
+}
+
+// sleepCtx waits d, or returns false if ctx is cancelled first
+func sleepCtx(ctx context.Context, d time.Duration) bool {
+	select {
+	case <-ctx.Done():
+		return false
+	case <-time.After(d):
+		return true
+	}
+}
@@ -0,0 +1,287 @@
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net"
+	"slices"
+	"strings"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// dnsTypes are the record types a dns check can ask for
+var dnsTypes = map[string]dnsmessage.Type{
+	"A":     dnsmessage.TypeA,
+	"AAAA":  dnsmessage.TypeAAAA,
+	"CNAME": dnsmessage.TypeCNAME,
+	"MX":    dnsmessage.TypeMX,
+	"NS":    dnsmessage.TypeNS,
+	"TXT":   dnsmessage.TypeTXT,
+}
+
+func init() {
+	RegisterChecker("dns", func(hc *HealthChecker) Checker { return dnsChecker{hc} }, (*Endpoint).validateDNS)
+}
+
+// dnsChecker looks up the endpoint's query and expects a non-empty
+// answer that contains every expected answer, within the latency budget.
+// With a resolver configured the question goes straight to that server,
+// so a broken DNS server shows up here rather than as a vague HTTP
+// failure; otherwise it is asked the way every other program on this
+// host asks, /etc/hosts included.
+type dnsChecker struct{ hc *HealthChecker }
+
+func (c dnsChecker) Check(ctx context.Context, ep *Endpoint) Result {
+	return Result{Err: c.check(ctx, ep)}
+}
+
+func (c dnsChecker) check(ctx context.Context, ep *Endpoint) error {
+	start := time.Now()
+	var answers []string
+	var err error
+	if ep.Resolver != "" {
+		answers, err = queryDNS(ctx, c.hc.dialerFor(ep), ep.Resolver, ep.Query, dnsTypes[ep.RecordType])
+	} else {
+		answers, err = lookupSystem(ctx, ep.Query, ep.RecordType)
+	}
+	elapsed := time.Since(start)
+	if err != nil {
+		return err
+	}
+
+	if len(answers) == 0 {
+		return fmt.Errorf("no %s records for %s", ep.RecordType, ep.Query)
+	}
+	var missing []string
+	for _, want := range ep.ExpectedAnswers {
+		if ep.RecordType != "TXT" {
+			want = normalizeAnswer(want)
+		}
+		if !slices.Contains(answers, want) {
+			missing = append(missing, want)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("%s answered %s, missing %s", ep.RecordType, strings.Join(answers, ", "), strings.Join(missing, ", "))
+	}
+	if ep.MaxLatency > 0 && elapsed > ep.MaxLatency {
+		return fmt.Errorf("answered in %v, over the %v budget", elapsed.Round(time.Millisecond), ep.MaxLatency)
+	}
+	return nil
+}
+
+// validateDNS checks a dns endpoint's query and fills in its defaults
+func (ep *Endpoint) validateDNS() error {
+	if ep.Query == "" {
+		return errors.New("dns check needs a query")
+	}
+	ep.RecordType = strings.ToUpper(ep.RecordType)
+	if ep.RecordType == "" {
+		ep.RecordType = "A"
+	}
+	if _, ok := dnsTypes[ep.RecordType]; !ok {
+		return fmt.Errorf("unsupported record_type %q", ep.RecordType)
+	}
+	return nil
+}
+
+// forNetwork returns dialer for dialing over network. Source dialers are
+// bound for TCP; over UDP, a copy leaves from the same address.
+func forNetwork(dialer *net.Dialer, network string) *net.Dialer {
+	a, ok := dialer.LocalAddr.(*net.TCPAddr)
+	if !ok || !strings.HasPrefix(network, "udp") {
+		return dialer
+	}
+	udp := *dialer
+	udp.LocalAddr = &net.UDPAddr{IP: a.IP, Zone: a.Zone}
+	return &udp
+}
+
+// lookupSystem asks the system resolver for name's records of rtype
+func lookupSystem(ctx context.Context, name, rtype string) ([]string, error) {
+	r := net.DefaultResolver
+	var answers []string
+	switch rtype {
+	case "A", "AAAA":
+		network := "ip4"
+		if rtype == "AAAA" {
+			network = "ip6"
+		}
+		ips, err := r.LookupIP(ctx, network, name)
+		if err != nil {
+			return nil, err
+		}
+		for _, ip := range ips {
+			answers = append(answers, ip.String())
+		}
+	case "CNAME":
+		cname, err := r.LookupCNAME(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		answers = append(answers, cname)
+	case "MX":
+		mxs, err := r.LookupMX(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		for _, mx := range mxs {
+			answers = append(answers, mx.Host)
+		}
+	case "NS":
+		nss, err := r.LookupNS(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		for _, ns := range nss {
+			answers = append(answers, ns.Host)
+		}
+	case "TXT":
+		txts, err := r.LookupTXT(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		return txts, nil
+	}
+	for i := range answers {
+		answers[i] = normalizeAnswer(answers[i])
+	}
+	return answers, nil
+}
+
+// queryDNS sends one recursive query for name to server (host:port) over
+// UDP, repeating it over TCP if the answer was truncated
+func queryDNS(ctx context.Context, dialer *net.Dialer, server, name string, qtype dnsmessage.Type) ([]string, error) {
+	qname, err := dnsmessage.NewName(strings.TrimSuffix(name, ".") + ".")
+	if err != nil {
+		return nil, err
+	}
+	query := dnsmessage.Message{
+		Header:    dnsmessage.Header{ID: uint16(rand.Uint32()), RecursionDesired: true},
+		Questions: []dnsmessage.Question{{Name: qname, Type: qtype, Class: dnsmessage.ClassINET}},
+	}
+
+	resp, err := exchangeDNS(ctx, dialer, "udp", server, query)
+	if err == nil && resp.Truncated {
+		resp, err = exchangeDNS(ctx, dialer, "tcp", server, query)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if resp.RCode != dnsmessage.RCodeSuccess {
+		return nil, fmt.Errorf("%s from %s for %s", rcodeName(resp.RCode), server, name)
+	}
+
+	// Only records of the type asked for; a CNAME chain leading to them
+	// comes along in the same answer
+	var answers []string
+	for _, rr := range resp.Answers {
+		if rr.Header.Type != qtype {
+			continue
+		}
+		switch body := rr.Body.(type) {
+		case *dnsmessage.AResource:
+			answers = append(answers, net.IP(body.A[:]).String())
+		case *dnsmessage.AAAAResource:
+			answers = append(answers, net.IP(body.AAAA[:]).String())
+		case *dnsmessage.CNAMEResource:
+			answers = append(answers, normalizeAnswer(body.CNAME.String()))
+		case *dnsmessage.MXResource:
+			answers = append(answers, normalizeAnswer(body.MX.String()))
+		case *dnsmessage.NSResource:
+			answers = append(answers, normalizeAnswer(body.NS.String()))
+		case *dnsmessage.TXTResource:
+			answers = append(answers, strings.Join(body.TXT, ""))
+		}
+	}
+	return answers, nil
+}
+
+// exchangeDNS sends query to server and returns its reply. Over TCP each
+// message is preceded by its length (RFC 1035 section 4.2.2).
+func exchangeDNS(ctx context.Context, dialer *net.Dialer, network, server string, query dnsmessage.Message) (*dnsmessage.Message, error) {
+	packed, err := query.Pack()
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := forNetwork(dialer, network).DialContext(ctx, network, server)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	if network == "tcp" {
+		prefixed := binary.BigEndian.AppendUint16(nil, uint16(len(packed)))
+		packed = append(prefixed, packed...)
+	}
+	if _, err := conn.Write(packed); err != nil {
+		return nil, err
+	}
+
+	buf := make([]byte, 65535)
+	for {
+		var n int
+		if network == "tcp" {
+			var size [2]byte
+			if _, err := io.ReadFull(conn, size[:]); err != nil {
+				return nil, err
+			}
+			n, err = io.ReadFull(conn, buf[:binary.BigEndian.Uint16(size[:])])
+		} else {
+			n, err = conn.Read(buf)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		var resp dnsmessage.Message
+		if err := resp.Unpack(buf[:n]); err != nil {
+			return nil, err
+		}
+		// A late reply to an earlier, timed-out query can still arrive
+		// on UDP; skip anything that isn't the answer to this one
+		if resp.Response && resp.ID == query.ID {
+			return &resp, nil
+		}
+		if network == "tcp" {
+			return nil, errors.New("mismatched DNS reply")
+		}
+	}
+}
+
+// rcodeName is the conventional (dig) name of a DNS response code
+func rcodeName(rcode dnsmessage.RCode) string {
+	switch rcode {
+	case dnsmessage.RCodeNameError:
+		return "NXDOMAIN"
+	case dnsmessage.RCodeServerFailure:
+		return "SERVFAIL"
+	case dnsmessage.RCodeRefused:
+		return "REFUSED"
+	case dnsmessage.RCodeFormatError:
+		return "FORMERR"
+	case dnsmessage.RCodeNotImplemented:
+		return "NOTIMP"
+	}
+	return rcode.String()
+}
+
+// normalizeAnswer puts an answer other than TXT in the form answers are
+// compared in: addresses canonical, names lower-case without the
+// trailing dot
+func normalizeAnswer(s string) string {
+	if ip := net.ParseIP(s); ip != nil {
+		return ip.String()
+	}
+	return strings.ToLower(strings.TrimSuffix(s, "."))
+}
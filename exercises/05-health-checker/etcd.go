@@ -0,0 +1,241 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// etcdDiscovery keeps endpoints in step with the keys under a prefix in
+// etcd, through its v3 JSON gateway: a range read, then a watch from the
+// revision after it. Each key holds an endpoint as a config file would
+// give it, in JSON, and names it: /healthcheck/web is etcd/web.
+type etcdDiscovery struct {
+	hc       *HealthChecker
+	addr     string // e.g. http://127.0.0.1:2379
+	prefix   string
+	client   *http.Client
+	problems discoveryProblems
+
+	mu     sync.Mutex
+	values map[string][]byte // by key
+}
+
+// etcdKV is a key and value as the gateway sends them, base64 encoded
+type etcdKV struct {
+	Key   []byte `json:"key"`
+	Value []byte `json:"value"`
+}
+
+func newEtcdDiscovery(hc *HealthChecker, addr, prefix string) *etcdDiscovery {
+	return &etcdDiscovery{
+		hc:       hc,
+		addr:     strings.TrimSuffix(addr, "/"),
+		prefix:   prefix,
+		client:   &http.Client{},
+		problems: make(discoveryProblems),
+		values:   make(map[string][]byte),
+	}
+}
+
+// run follows the prefix until ctx is cancelled
+func (e *etcdDiscovery) run(ctx context.Context) {
+	failing := false
+	for {
+		revision, err := e.list(ctx)
+		if err == nil && failing {
+			e.hc.event(slog.LevelInfo, "🗝️  etcd discovery: reading "+e.prefix+" again", "discovery recovered", "source", "etcd")
+			failing = false
+		}
+		for err == nil {
+			revision, err = e.watch(ctx, revision)
+		}
+		if ctx.Err() != nil {
+			return
+		}
+		if errors.Is(err, errWatchExpired) {
+			continue // compacted past where the watch was; read it all again
+		}
+		if !failing {
+			e.hc.event(slog.LevelError, fmt.Sprintf("❌ etcd discovery: %v", err), "discovery failed", "source", "etcd", "error", err)
+		}
+		failing = true
+		if !sleepCtx(ctx, discoveryRetry) {
+			return
+		}
+	}
+}
+
+// list reads every key under the prefix and returns the revision it
+// read at
+func (e *etcdDiscovery) list(ctx context.Context) (int64, error) {
+	listCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	resp, err := e.post(listCtx, "/v3/kv/range", map[string]any{
+		"key":       []byte(e.prefix),
+		"range_end": prefixEnd(e.prefix),
+	})
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		Header etcdHeader `json:"header"`
+		KVs    []etcdKV   `json:"kvs"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return 0, fmt.Errorf("decoding range: %v", err)
+	}
+
+	e.mu.Lock()
+	clear(e.values)
+	for _, kv := range result.KVs {
+		e.values[string(kv.Key)] = kv.Value
+	}
+	e.mu.Unlock()
+	e.publish(ctx)
+	return result.Header.Revision, nil
+}
+
+// watch follows changes after revision until the stream ends, returning
+// the revision to resume after
+func (e *etcdDiscovery) watch(ctx context.Context, revision int64) (int64, error) {
+	resp, err := e.post(ctx, "/v3/watch", map[string]any{"create_request": map[string]any{
+		"key":            []byte(e.prefix),
+		"range_end":      prefixEnd(e.prefix),
+		"start_revision": revision + 1,
+	}})
+	if err != nil {
+		return revision, err
+	}
+	defer resp.Body.Close()
+
+	// One JSON response per line
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(nil, 16<<20)
+	for scanner.Scan() {
+		var msg struct {
+			Result struct {
+				Header          etcdHeader `json:"header"`
+				Canceled        bool       `json:"canceled"`
+				CancelReason    string     `json:"cancel_reason"`
+				CompactRevision int64      `json:"compact_revision,string"`
+				Events          []struct {
+					Type string `json:"type"` // "DELETE", or left out for a put
+					KV   etcdKV `json:"kv"`
+				} `json:"events"`
+			} `json:"result"`
+			Error *struct {
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		if err := json.Unmarshal(scanner.Bytes(), &msg); err != nil {
+			return revision, fmt.Errorf("decoding watch response: %v", err)
+		}
+		r := msg.Result
+		switch {
+		case msg.Error != nil:
+			return revision, errors.New(msg.Error.Message)
+		case r.CompactRevision > 0:
+			return revision, errWatchExpired
+		case r.Canceled:
+			return revision, fmt.Errorf("watch canceled: %s", r.CancelReason)
+		case len(r.Events) == 0:
+			continue // the watch's creation, or progress
+		}
+
+		e.mu.Lock()
+		for _, ev := range r.Events {
+			if ev.Type == "DELETE" {
+				delete(e.values, string(ev.KV.Key))
+			} else {
+				e.values[string(ev.KV.Key)] = ev.KV.Value
+			}
+		}
+		e.mu.Unlock()
+		revision = r.Header.Revision
+		e.publish(ctx)
+	}
+	if err := scanner.Err(); err != nil && ctx.Err() == nil {
+		return revision, err
+	}
+	return revision, ctx.Err()
+}
+
+// etcdHeader is a response header; 64-bit numbers come as strings
+type etcdHeader struct {
+	Revision int64 `json:"revision,string"`
+}
+
+// publish turns the values into endpoints and applies them. The lock is
+// held throughout so changes apply in order.
+func (e *etcdDiscovery) publish(ctx context.Context) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	var endpoints []Endpoint
+	problems := make(map[string]string)
+	for _, key := range slices.Sorted(maps.Keys(e.values)) {
+		name := "etcd/" + strings.TrimPrefix(strings.TrimPrefix(key, e.prefix), "/")
+		ep, err := discoveredEndpoint(map[string]any{"name": name}, string(e.values[key]))
+		if err != nil {
+			problems[key] = err.Error()
+			continue
+		}
+		endpoints = append(endpoints, ep)
+	}
+	e.problems.report(e.hc, "etcd", problems)
+	e.hc.update(ctx, "etcd", endpoints)
+}
+
+func (e *etcdDiscovery) post(ctx context.Context, path string, body any) (*http.Response, error) {
+	return etcdPost(ctx, e.client, e.addr, path, body)
+}
+
+// etcdPost POSTs body as JSON to path on the gateway at addr and returns
+// the response if it's a 200
+func etcdPost(ctx context.Context, client *http.Client, addr, path string, body any) (*http.Response, error) {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, addr+path, bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("POST %s: %s", path, resp.Status)
+	}
+	return resp, nil
+}
+
+// prefixEnd is the end of the range of keys starting with prefix: the
+// prefix with its last byte below 0xff incremented, as etcd's own
+// clients do it
+func prefixEnd(prefix string) []byte {
+	end := []byte(prefix)
+	for i := len(end) - 1; i >= 0; i-- {
+		if end[i] < 0xff {
+			end[i]++
+			return end[:i+1]
+		}
+	}
+	return []byte{0} // every key
+}
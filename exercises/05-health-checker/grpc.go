@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+func init() {
+	RegisterChecker("grpc", func(hc *HealthChecker) Checker { return grpcChecker{hc} }, (*Endpoint).validateAddress)
+}
+
+// grpcChecker calls the standard health-checking protocol's
+// grpc.health.v1.Health/Check on the endpoint's address and expects
+// SERVING. An empty service asks about the server as a whole.
+type grpcChecker struct{ hc *HealthChecker }
+
+func (c grpcChecker) Check(ctx context.Context, ep *Endpoint) Result {
+	return Result{Err: c.check(ctx, ep)}
+}
+
+func (c grpcChecker) check(ctx context.Context, ep *Endpoint) error {
+	creds := insecure.NewCredentials()
+	if ep.TLS {
+		config := &tls.Config{}
+		if ep.tlsConfig != nil {
+			config = ep.tlsConfig.Clone()
+		}
+		creds = credentials.NewTLS(config)
+	}
+
+	// passthrough hands the address to the dialer as it is, leaving the
+	// lookup to the endpoint's own resolver, ip_version and ip
+	conn, err := grpc.NewClient("passthrough:///"+ep.Address,
+		grpc.WithTransportCredentials(creds),
+		grpc.WithContextDialer(func(ctx context.Context, addr string) (net.Conn, error) {
+			return c.hc.dial(ctx, ep, addr)
+		}),
+	)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	resp, err := healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{Service: ep.Service})
+	if err != nil {
+		switch status.Code(err) {
+		case codes.Unimplemented:
+			return errors.New("server doesn't implement grpc.health.v1.Health")
+		case codes.NotFound:
+			return fmt.Errorf("server doesn't know service %q", ep.Service)
+		}
+		return err
+	}
+	if resp.Status != healthpb.HealthCheckResponse_SERVING {
+		return fmt.Errorf("status %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"strings"
+	"syscall"
+)
+
+// errPrivate is why -block-private refused a connection
+var errPrivate = errors.New("private address, refused by -block-private")
+
+// addrGuard keeps checks away from private addresses, so a checker run
+// on configs from others can't be used to probe the network it sits in.
+// It vets each address as it's dialed, after any lookup, so a redirect
+// or a name that resolves inward is caught the same as a literal IP.
+type addrGuard struct {
+	allow []netip.Prefix // private ranges checks may reach all the same
+}
+
+// newAddrGuard returns a guard allowing the comma-separated CIDRs or IPs
+// in allow
+func newAddrGuard(allow string) (*addrGuard, error) {
+	g := &addrGuard{}
+	for _, s := range strings.Split(allow, ",") {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+		if !strings.Contains(s, "/") {
+			ip, err := netip.ParseAddr(s)
+			if err != nil {
+				return nil, fmt.Errorf("%q isn't a CIDR or IP", s)
+			}
+			s = netip.PrefixFrom(ip, ip.BitLen()).String()
+		}
+		prefix, err := netip.ParsePrefix(s)
+		if err != nil {
+			return nil, fmt.Errorf("%q isn't a CIDR or IP", s)
+		}
+		g.allow = append(g.allow, prefix.Masked())
+	}
+	return g, nil
+}
+
+// check returns errPrivate if ip is loopback, link-local, private (RFC
+// 1918, or an IPv6 unique local address) or unspecified, which reaches
+// the local host, and not allowed
+func (g *addrGuard) check(ip netip.Addr) error {
+	ip = ip.Unmap().WithZone("")
+	if !ip.IsLoopback() && !ip.IsLinkLocalUnicast() && !ip.IsLinkLocalMulticast() &&
+		!ip.IsPrivate() && !ip.IsUnspecified() {
+		return nil
+	}
+	for _, prefix := range g.allow {
+		if prefix.Contains(ip) {
+			return nil
+		}
+	}
+	return errPrivate
+}
+
+// control is a net.Dialer Control func that applies the guard to the
+// address about to be connected to
+func (g *addrGuard) control(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip, err := netip.ParseAddr(host)
+	if err != nil {
+		return err
+	}
+	return g.check(ip)
+}
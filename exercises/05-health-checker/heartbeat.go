@@ -0,0 +1,174 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"log/slog"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// maxFailureMessage is how much of a failure report's body is kept
+const maxFailureMessage = 1 << 10
+
+func init() {
+	RegisterChecker("heartbeat", func(hc *HealthChecker) Checker { return heartbeatChecker{hc} }, (*Endpoint).validateHeartbeat)
+}
+
+// heartbeatChecker passes if the endpoint's job sent a heartbeat within
+// its grace period and didn't report a failure with it. Nothing goes
+// over the network: the job calls in.
+type heartbeatChecker struct{ hc *HealthChecker }
+
+func (c heartbeatChecker) Check(ctx context.Context, ep *Endpoint) Result {
+	return Result{Err: c.hc.checkHeartbeat(ep)}
+}
+
+// Pending until the job calls in or is late
+func (c heartbeatChecker) Pending(ep *Endpoint) bool {
+	return c.hc.awaitingHeartbeat(ep)
+}
+
+// heartbeats is what's been heard from a heartbeat endpoint's job
+type heartbeats struct {
+	since  time.Time // when they were first expected: the endpoint's start
+	last   time.Time // the latest, zero before the first
+	failed string    // what went wrong, if the latest reported a failure
+}
+
+// expectHeartbeats starts the grace period of a heartbeat endpoint
+// that has none running. One restarted by a reload keeps what it heard.
+func (hc *HealthChecker) expectHeartbeats(name string) {
+	hc.mu.Lock()
+	defer hc.mu.Unlock()
+	if _, ok := hc.heartbeats[name]; !ok {
+		hc.heartbeats[name] = &heartbeats{since: time.Now()}
+	}
+}
+
+// awaitingHeartbeat reports whether a heartbeat endpoint has yet to hear
+// from its job but is still within its first grace period, so there's
+// nothing to say about it
+func (hc *HealthChecker) awaitingHeartbeat(ep *Endpoint) bool {
+	hc.mu.RLock()
+	defer hc.mu.RUnlock()
+	hb := hc.heartbeats[ep.Name]
+	return hb != nil && hb.last.IsZero() && time.Since(hb.since) < ep.Grace
+}
+
+// checkHeartbeat is the heartbeat check: an error if the job is late or
+// said it failed
+func (hc *HealthChecker) checkHeartbeat(ep *Endpoint) error {
+	hc.mu.RLock()
+	defer hc.mu.RUnlock()
+	hb := hc.heartbeats[ep.Name]
+	switch {
+	case hb == nil:
+		return errors.New("not expecting heartbeats")
+	case hb.last.IsZero():
+		return fmt.Errorf("no heartbeat since starting %v ago (grace %v)", time.Since(hb.since).Round(time.Second), ep.Grace)
+	case time.Since(hb.last) > ep.Grace:
+		return fmt.Errorf("last heartbeat %v ago (grace %v)", time.Since(hb.last).Round(time.Second), ep.Grace)
+	case hb.failed != "":
+		return fmt.Errorf("job reported failure: %s", hb.failed)
+	}
+	return nil
+}
+
+// validateHeartbeat checks a heartbeat endpoint has a token and a grace
+// period, and reads the token if it's a reference
+func (ep *Endpoint) validateHeartbeat() error {
+	if ep.Token == "" {
+		return errors.New("heartbeat check needs a token")
+	}
+	token, err := resolveSecret(ep.Token)
+	if err != nil {
+		return fmt.Errorf("token: %v", err)
+	}
+	ep.Token = token
+	if ep.Grace == 0 {
+		return errors.New("heartbeat check needs a grace period, e.g. \"25h\" for a daily job")
+	}
+	return nil
+}
+
+// heartbeat records a heartbeat with token, failed if the job reported
+// a failure, and returns the endpoint it's for, or "" if no endpoint has
+// that token
+func (hc *HealthChecker) heartbeat(token, failed string) string {
+	hc.mu.Lock()
+	defer hc.mu.Unlock()
+	for i := range hc.endpoints {
+		ep := &hc.endpoints[i]
+		if ep.Type != "heartbeat" || subtle.ConstantTimeCompare([]byte(ep.Token), []byte(token)) != 1 {
+			continue
+		}
+		hb := hc.heartbeats[ep.Name]
+		if hb == nil {
+			return "" // not started yet
+		}
+		hb.last, hb.failed = time.Now(), failed
+		return ep.Name
+	}
+	return ""
+}
+
+// serveHeartbeats receives heartbeats on addr until ctx is cancelled.
+// A job that ran POSTs /heartbeat/TOKEN, and one that failed POSTs
+// /heartbeat/TOKEN/fail with what went wrong as the body. Only POST
+// counts, so a link preview or a crawler that comes across the URL
+// can't keep a dead job looking alive.
+func serveHeartbeats(ctx context.Context, addr string, hc *HealthChecker) error {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /heartbeat/{token}", func(w http.ResponseWriter, r *http.Request) {
+		if hc.heartbeat(r.PathValue("token"), "") == "" {
+			http.Error(w, "unknown token", http.StatusNotFound)
+			return
+		}
+		fmt.Fprintln(w, "OK")
+	})
+	mux.HandleFunc("POST /heartbeat/{token}/fail", func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(io.LimitReader(r.Body, maxFailureMessage))
+		message := strings.TrimSpace(strings.ToValidUTF8(string(body), "?"))
+		if message == "" {
+			message = "no details given"
+		}
+		name := hc.heartbeat(r.PathValue("token"), message)
+		if name == "" {
+			http.Error(w, "unknown token", http.StatusNotFound)
+			return
+		}
+		hc.event(slog.LevelWarn, fmt.Sprintf("💔 %s: job reported failure: %s", name, message),
+			"heartbeat failure", "endpoint", name, "error", message)
+		fmt.Fprintln(w, "OK")
+	})
+
+	server := &http.Server{
+		Handler:           mux,
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(shutdownCtx)
+	}()
+
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("💓 Heartbeats on http://%s/heartbeat/TOKEN", ln.Addr())
+	if err := server.Serve(ln); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
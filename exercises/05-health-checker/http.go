@@ -0,0 +1,262 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/channyeintun/network-exercises/pkg/healthcheck"
+)
+
+func init() {
+	RegisterChecker("http", func(hc *HealthChecker) Checker { return httpChecker{} }, (*Endpoint).validateHTTP)
+}
+
+// httpChecker sends the endpoint's request and expects its status code
+// and any body assertions to hold, timing its phases
+type httpChecker struct{}
+
+func (httpChecker) Check(ctx context.Context, ep *Endpoint) Result {
+	phases := &httpPhases{}
+	return Result{Err: checkHTTP(ctx, ep, phases), Phases: phases}
+}
+
+// drainLimit is how much of a response body checkHTTP reads off before
+// closing it. A longer body is cut off, and its connection with it.
+const drainLimit = 4 << 20
+
+// checkHTTP checks ep with phases timed into phases
+func checkHTTP(ctx context.Context, ep *Endpoint, phases *httpPhases) error {
+	var body io.Reader
+	if ep.Body != "" {
+		body = strings.NewReader(ep.Body)
+	}
+	req, err := http.NewRequestWithContext(withPhaseTrace(ctx, phases), ep.Method, ep.URL, body)
+	if err != nil {
+		return err
+	}
+	for name, value := range ep.Headers {
+		// net/http sends req.Host, ignoring any Host in the headers
+		if http.CanonicalHeaderKey(name) == "Host" {
+			req.Host = value
+			continue
+		}
+		req.Header.Set(name, value)
+	}
+	if ep.Auth != nil {
+		ep.Auth.apply(req)
+	}
+
+	resp, err := ep.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		// A body closed unread costs the connection, so read off what's
+		// left for the next check to reuse it
+		io.Copy(io.Discard, io.LimitReader(resp.Body, drainLimit))
+		resp.Body.Close()
+	}()
+
+	phases.Proto = resp.Proto
+	if ep.FinalURL != "" && resp.Request.URL.String() != ep.FinalURL {
+		return fmt.Errorf("ended up at %s (expected %s)", resp.Request.URL, ep.FinalURL)
+	}
+	if want := protocolMajor[ep.Protocol]; want != 0 && resp.ProtoMajor != want {
+		return fmt.Errorf("got %s, not the %s asked for", resp.Proto, ep.Protocol)
+	}
+
+	if err := ep.ExpectedStatus.Check(resp.StatusCode); err != nil {
+		return err
+	}
+	if ep.ExpectedContentType != "" {
+		if err := checkContentType(ep.ExpectedContentType, resp.Header.Get("Content-Type")); err != nil {
+			return err
+		}
+	}
+	if ep.hasBodyAssertions() {
+		return checkBody(ep, resp.Body)
+	}
+	return nil
+}
+
+// validateHTTP checks an http endpoint's request and assertions, and
+// fills in their defaults
+func (ep *Endpoint) validateHTTP() error {
+	if ep.URL == "" {
+		return errors.New("http check needs a url")
+	}
+	if len(ep.ExpectedStatus) == 0 {
+		ep.ExpectedStatus = healthcheck.StatusSet{{Lo: 200, Hi: 200}}
+	}
+	ep.Method = strings.ToUpper(ep.Method)
+	if ep.Method == "" {
+		ep.Method = http.MethodGet
+	}
+	if ep.BodyRegexp != "" {
+		re, err := regexp.Compile(ep.BodyRegexp)
+		if err != nil {
+			return fmt.Errorf("body_regexp: %v", err)
+		}
+		ep.bodyRegexp = re
+	}
+	if ep.JSONPath != "" {
+		path, err := parseJSONPath(ep.JSONPath)
+		if err != nil {
+			return fmt.Errorf("json_path %q: %v", ep.JSONPath, err)
+		}
+		ep.jsonPath = path
+	} else if ep.JSONValue != "" {
+		return errors.New("json_value needs a json_path")
+	}
+	following := ep.FollowRedirects == nil || *ep.FollowRedirects
+	switch {
+	case !following && (ep.MaxRedirects != 0 || ep.FinalURL != ""):
+		return errors.New("max_redirects and final_url need follow_redirects")
+	case ep.MaxRedirects < 0:
+		return errors.New("max_redirects can't be negative")
+	case following && ep.MaxRedirects == 0:
+		ep.MaxRedirects = defaultMaxRedirects
+	}
+	switch ep.Protocol = strings.ToLower(ep.Protocol); ep.Protocol {
+	case "", "http/1.1", "h2":
+	case "h3":
+		switch {
+		case !strings.HasPrefix(strings.ToLower(ep.URL), "https://"):
+			return errors.New("h3 runs over QUIC, which is always encrypted; it needs an https:// url")
+		case ep.Proxy != "":
+			return errors.New("h3 can't go through a proxy; CONNECT and SOCKS5 only carry TCP")
+		case ep.FreshConnections || ep.MaxIdleConns != 0:
+			return errors.New("h3 keeps one QUIC connection per host; fresh_connections and max_idle_conns are for http/1.1 and h2")
+		}
+	default:
+		return fmt.Errorf("unknown protocol %q (want http/1.1, h2 or h3)", ep.Protocol)
+	}
+	switch {
+	case ep.MaxIdleConns < 0 || ep.IdleConnTimeout < 0:
+		return errors.New("max_idle_conns and idle_conn_timeout can't be negative")
+	case ep.FreshConnections && (ep.MaxIdleConns != 0 || ep.IdleConnTimeout != 0):
+		return errors.New("fresh_connections keeps no idle connections; drop max_idle_conns and idle_conn_timeout")
+	}
+	if ep.MaxBodyBytes <= 0 {
+		ep.MaxBodyBytes = defaultMaxBody
+	}
+	switch {
+	case ep.MinBytes < 0 || ep.MaxBytes < 0:
+		return errors.New("min_bytes and max_bytes can't be negative")
+	case ep.MaxBytes > 0 && ep.MinBytes > ep.MaxBytes:
+		return fmt.Errorf("min_bytes (%d) is over max_bytes (%d)", ep.MinBytes, ep.MaxBytes)
+	}
+	if ep.ExpectedContentType != "" {
+		mediaType, _, err := mime.ParseMediaType(ep.ExpectedContentType)
+		if err != nil {
+			return fmt.Errorf("expected_content_type %q: %v", ep.ExpectedContentType, err)
+		}
+		ep.ExpectedContentType = mediaType
+	}
+	if ep.Auth != nil {
+		if err := ep.Auth.resolve(); err != nil {
+			return fmt.Errorf("auth: %v", err)
+		}
+	}
+	return nil
+}
+
+// defaultMaxRedirects is how many redirects are followed unless
+// max_redirects says otherwise, as many as net/http's default
+const defaultMaxRedirects = 10
+
+// protocolMajor is the HTTP major version each protocol option must get
+var protocolMajor = map[string]int{"http/1.1": 1, "h2": 2, "h3": 3}
+
+// endpointClient returns the HTTP client ep's checks use: the shared one,
+// or one with the endpoint's redirect policy. An endpoint that needs its
+// own transport (a proxy, TLS options, a protocol) gets a copy of the
+// shared one with those applied, so it keeps the interface binding and
+// pool settings. An h3 endpoint gets a QUIC transport instead.
+func (hc *HealthChecker) endpointClient(ep *Endpoint) *http.Client {
+	srvTLS := ep.SRV != "" && strings.HasPrefix(strings.ToLower(ep.URL), "https://")
+	ownResolution := ep.Resolver != "" || ep.IPVersion != 0 || ep.IP != ""
+	ownPool := ep.FreshConnections || ep.MaxIdleConns != 0 || ep.IdleConnTimeout != 0
+	ownTransport := ep.proxyURL != nil || ep.tlsConfig != nil || ep.Protocol != "" || srvTLS || ownResolution || ownPool
+	ownRedirects := ep.MaxRedirects != defaultMaxRedirects
+	if !ownTransport && !ownRedirects {
+		return hc.client
+	}
+
+	client := &http.Client{
+		Transport:     hc.client.Transport,
+		Timeout:       hc.client.Timeout,
+		CheckRedirect: ep.checkRedirect,
+	}
+	if !ownTransport {
+		return client
+	}
+	if ep.Protocol == "h3" {
+		client.Transport = hc.h3Transport(ep)
+		return client
+	}
+
+	transport := hc.client.Transport.(*http.Transport).Clone()
+	if ep.proxyURL != nil {
+		transport.Proxy = http.ProxyURL(ep.proxyURL)
+	}
+	if ep.tlsConfig != nil {
+		transport.TLSClientConfig = ep.tlsConfig.Clone()
+	}
+	if ownResolution {
+		transport.DialContext = hc.dialFunc(ep)
+	}
+	transport.DisableKeepAlives = ep.FreshConnections
+	if ep.MaxIdleConns > 0 {
+		transport.MaxIdleConnsPerHost = ep.MaxIdleConns
+	}
+	if ep.IdleConnTimeout > 0 {
+		transport.IdleConnTimeout = ep.IdleConnTimeout
+	}
+	if srvTLS {
+		// SRV targets are dialed by their own names, but it's the url's
+		// host the certificate has to be for
+		if transport.TLSClientConfig == nil {
+			transport.TLSClientConfig = &tls.Config{}
+		}
+		if transport.TLSClientConfig.ServerName == "" {
+			u, _ := url.Parse(ep.URL)
+			transport.TLSClientConfig.ServerName = u.Hostname()
+		}
+	}
+	if ep.Protocol != "" {
+		transport.Protocols = new(http.Protocols)
+		switch ep.Protocol {
+		case "http/1.1":
+			transport.Protocols.SetHTTP1(true)
+		case "h2":
+			// Negotiated by ALPN over TLS; assumed (h2c with prior
+			// knowledge) for http:// URLs
+			transport.Protocols.SetHTTP2(true)
+			transport.Protocols.SetUnencryptedHTTP2(true)
+		}
+	}
+	client.Transport = transport
+	return client
+}
+
+// checkRedirect applies the endpoint's redirect policy. Not following
+// hands the 3xx itself back as the response, to be checked against
+// expected_status.
+func (ep *Endpoint) checkRedirect(req *http.Request, via []*http.Request) error {
+	if ep.FollowRedirects != nil && !*ep.FollowRedirects {
+		return http.ErrUseLastResponse
+	}
+	if len(via) > ep.MaxRedirects {
+		return fmt.Errorf("stopped after %d redirects", ep.MaxRedirects)
+	}
+	return nil
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http/httptrace"
+	"net/netip"
+	"net/url"
+
+	"github.com/quic-go/quic-go"
+	"github.com/quic-go/quic-go/http3"
+)
+
+// h3Transport returns the HTTP/3 transport for ep's checks. QUIC runs
+// over UDP, so it can't share the TCP transport's DialContext; dialQUIC
+// connects from the same source address, with the same resolution and
+// guard, instead.
+func (hc *HealthChecker) h3Transport(ep *Endpoint) *http3.Transport {
+	tlsConfig := &tls.Config{}
+	if ep.tlsConfig != nil {
+		tlsConfig = ep.tlsConfig.Clone()
+	}
+	if ep.SRV != "" && tlsConfig.ServerName == "" {
+		// As for h2: the certificate has to be for the url's host, not
+		// the SRV target dialed
+		u, _ := url.Parse(ep.URL)
+		tlsConfig.ServerName = u.Hostname()
+	}
+	quicConfig := &quic.Config{}
+	if ep.IdleConnTimeout > 0 {
+		quicConfig.MaxIdleTimeout = ep.IdleConnTimeout
+	}
+	return &http3.Transport{
+		TLSClientConfig: tlsConfig,
+		QUICConfig:      quicConfig,
+		Dial:            hc.dialQUIC(ep),
+	}
+}
+
+// dialQUIC returns how ep's h3 checks connect: from a UDP socket on the
+// dialer's local address, to the host looked up with the endpoint's
+// resolver and IP version, or its pinned IP. The handshake is QUIC's and
+// TLS's at once, so it's all timed as the connect phase.
+func (hc *HealthChecker) dialQUIC(ep *Endpoint) func(context.Context, string, *tls.Config, *quic.Config) (*quic.Conn, error) {
+	return func(ctx context.Context, addr string, tlsConfig *tls.Config, quicConfig *quic.Config) (*quic.Conn, error) {
+		trace := httptrace.ContextClientTrace(ctx)
+		raddr, err := hc.resolveQUIC(ctx, ep, addr, trace)
+		if err != nil {
+			return nil, err
+		}
+		if hc.guard != nil {
+			// The socket isn't dialed, so the dialer's guard doesn't see it
+			if err := hc.guard.check(raddr.AddrPort().Addr()); err != nil {
+				return nil, fmt.Errorf("dial udp %s: %w", raddr, err)
+			}
+		}
+
+		var local *net.UDPAddr
+		if tcp, ok := hc.dialerFor(ep).LocalAddr.(*net.TCPAddr); ok {
+			local = &net.UDPAddr{IP: tcp.IP}
+		}
+		pconn, err := net.ListenUDP("udp", local)
+		if err != nil {
+			return nil, err
+		}
+		if trace != nil && trace.ConnectStart != nil {
+			trace.ConnectStart("udp", raddr.String())
+		}
+		conn, err := quic.Dial(ctx, pconn, raddr, tlsConfig, quicConfig)
+		if trace != nil && trace.ConnectDone != nil {
+			trace.ConnectDone("udp", raddr.String(), err)
+		}
+		if err != nil {
+			pconn.Close()
+			return nil, err
+		}
+		// quic-go leaves a socket it was handed open, so close it along
+		// with the connection
+		go func() {
+			<-conn.Context().Done()
+			pconn.Close()
+		}()
+		return conn, nil
+	}
+}
+
+// resolveQUIC returns the UDP address an h3 check of ep connects to for
+// addr, a host and port
+func (hc *HealthChecker) resolveQUIC(ctx context.Context, ep *Endpoint, addr string, trace *httptrace.ClientTrace) (*net.UDPAddr, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	if ep.IP != "" {
+		host = ep.IP
+	}
+	if ip, err := netip.ParseAddr(host); err == nil {
+		return net.UDPAddrFromAddrPort(netip.AddrPortFrom(ip, portNumber(port))), nil
+	}
+
+	if trace != nil && trace.DNSStart != nil {
+		trace.DNSStart(httptrace.DNSStartInfo{Host: host})
+	}
+	ips, err := hc.resolverFor(ep).LookupNetIP(ctx, ep.ipNetwork(), host)
+	if trace != nil && trace.DNSDone != nil {
+		trace.DNSDone(httptrace.DNSDoneInfo{Err: err})
+	}
+	if err != nil {
+		return nil, err
+	}
+	return net.UDPAddrFromAddrPort(netip.AddrPortFrom(ips[0].Unmap(), portNumber(port))), nil
+}
+
+// portNumber parses a port from net.SplitHostPort, 0 if it isn't one
+func portNumber(port string) uint16 {
+	p, _ := net.LookupPort("udp", port)
+	return uint16(p)
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCheckHTTPReusesConnection(t *testing.T) {
+	// Bigger than the transport's read buffer, so closing it unread
+	// would cost the connection
+	page := strings.Repeat("x", 1<<20)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(page))
+	}))
+	defer srv.Close()
+
+	tests := []struct {
+		name string
+		ep   Endpoint
+	}{
+		{"unread body", Endpoint{URL: srv.URL}},
+		{"body assertion", Endpoint{URL: srv.URL, BodyContains: "xxx"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ep := tt.ep
+			if err := ep.validateHTTP(); err != nil {
+				t.Fatal(err)
+			}
+			ep.client = &http.Client{Transport: &http.Transport{}}
+			defer ep.client.CloseIdleConnections()
+
+			for i := range 2 {
+				var phases httpPhases
+				if err := checkHTTP(context.Background(), &ep, &phases); err != nil {
+					t.Fatal(err)
+				}
+				if want := i > 0; phases.Reused != want {
+					t.Errorf("check %d: reused = %v, want %v", i+1, phases.Reused, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"sync"
+	"time"
+
+	"github.com/channyeintun/network-exercises/pkg/ping"
+)
+
+// icmpPingInterval is the gap between an icmp check's pings
+const icmpPingInterval = 200 * time.Millisecond
+
+func init() {
+	RegisterChecker("icmp", func(hc *HealthChecker) Checker { return &icmpChecker{hc: hc} }, (*Endpoint).validateICMP)
+}
+
+// icmpChecker pings the endpoint's host, as exercise 04 does, and passes
+// if any of its pings is answered. Its latency is the average round trip.
+// Every icmp endpoint shares one ICMP socket per address family and
+// source address.
+type icmpChecker struct {
+	hc *HealthChecker
+
+	mu    sync.Mutex
+	conns map[string]*ping.Conn // by network, "ip4" or "ip6", and local address
+}
+
+func (c *icmpChecker) Check(ctx context.Context, ep *Endpoint) Result {
+	ips, err := c.hc.resolverFor(ep).LookupIP(ctx, ep.ipNetwork(), ep.Address)
+	if err != nil {
+		return Result{Err: err}
+	}
+	dst := &net.IPAddr{IP: ips[0]}
+	if c.hc.guard != nil {
+		// Pings aren't dialed, so the dialer's guard doesn't see them
+		ip, _ := netip.AddrFromSlice(dst.IP)
+		if err := c.hc.guard.check(ip); err != nil {
+			return Result{Err: fmt.Errorf("ping %s: %w", dst, err)}
+		}
+	}
+	conn, err := c.conn(dst, c.hc.dialerFor(ep))
+	if err != nil {
+		return Result{Err: fmt.Errorf("no ICMP socket: %v", err)}
+	}
+
+	var lastErr error
+	p := ping.New(dst, ping.Config{
+		Count:    ep.Pings,
+		Interval: icmpPingInterval,
+		Timeout:  ep.Timeout,
+		Prober:   conn,
+		OnRecv: func(pkt ping.Packet) {
+			if pkt.Err != nil {
+				lastErr = pkt.Err
+			}
+		},
+	})
+	if err := p.Run(ctx); err != nil {
+		return Result{Err: err}
+	}
+
+	stats := p.Statistics()
+	if stats.PacketsRecv == 0 {
+		if lastErr == nil || errors.Is(lastErr, ping.ErrTimeout) {
+			lastErr = fmt.Errorf("no reply to %d pings", stats.PacketsSent)
+		}
+		return Result{Err: lastErr}
+	}
+	return Result{Latency: stats.AvgRTT}
+}
+
+// conn returns the socket to ping dst from, opening it on first use,
+// bound to the dialer's address if that's the family's
+func (c *icmpChecker) conn(dst *net.IPAddr, dialer *net.Dialer) (*ping.Conn, error) {
+	network := "ip4"
+	if dst.IP.To4() == nil {
+		network = "ip6"
+	}
+	local := ""
+	if a, ok := dialer.LocalAddr.(*net.TCPAddr); ok && (a.IP.To4() != nil) == (network == "ip4") {
+		local = a.IP.String()
+	}
+	key := network + " " + local
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if conn := c.conns[key]; conn != nil {
+		return conn, nil
+	}
+	conn, err := ping.Listen(network, local)
+	if err != nil {
+		return nil, err
+	}
+	if c.conns == nil {
+		c.conns = make(map[string]*ping.Conn)
+	}
+	c.conns[key] = conn
+	return conn, nil
+}
+
+// validateICMP checks an icmp endpoint names a host and fills in how
+// many pings it sends
+func (ep *Endpoint) validateICMP() error {
+	if ep.Address == "" {
+		return errors.New("icmp check needs an address: a host name or IP")
+	}
+	if _, _, err := net.SplitHostPort(ep.Address); err == nil {
+		return errors.New("icmp check pings a host; leave the port out of its address")
+	}
+	if ep.Pings < 0 {
+		return errors.New("pings can't be negative")
+	}
+	if ep.Pings == 0 {
+		ep.Pings = 3
+	}
+	return nil
+}
@@ -0,0 +1,438 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Where a pod finds its service account's credentials
+const (
+	k8sTokenFile = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+	k8sCAFile    = "/var/run/secrets/kubernetes.io/serviceaccount/ca.crt"
+)
+
+// k8sWatchTimeout is how long one watch request lasts before it's
+// resumed from where it got to
+const k8sWatchTimeout = 5 * time.Minute
+
+// k8sKind is a kind of object discovery watches
+type k8sKind struct {
+	name     string // in endpoint names
+	group    string // API path prefix
+	resource string
+}
+
+var k8sKinds = []k8sKind{
+	{"svc", "/api/v1", "services"},
+	{"ingress", "/apis/networking.k8s.io/v1", "ingresses"},
+}
+
+// k8sObject is the part of a Service or Ingress discovery reads
+type k8sObject struct {
+	Metadata struct {
+		Name            string            `json:"name"`
+		Namespace       string            `json:"namespace"`
+		ResourceVersion string            `json:"resourceVersion"`
+		Annotations     map[string]string `json:"annotations"`
+	} `json:"metadata"`
+	Spec struct {
+		// Service
+		Type         string `json:"type"`
+		ExternalName string `json:"externalName"`
+		Ports        []struct {
+			Name string `json:"name"`
+			Port int    `json:"port"`
+		} `json:"ports"`
+
+		// Ingress
+		Rules []struct {
+			Host string `json:"host"`
+		} `json:"rules"`
+		TLS []struct {
+			Hosts []string `json:"hosts"`
+		} `json:"tls"`
+	} `json:"spec"`
+
+	// In a watch event, a Status for an ERROR
+	Code int `json:"code"`
+}
+
+// k8sDiscovery keeps endpoints in step with the Services and Ingresses
+// annotated for checking, through the Kubernetes API's list and watch.
+// With annotation prefix healthcheck.io:
+//
+//	healthcheck.io/path     an http check of this path (the opt-in for most)
+//	healthcheck.io/type     tcp or grpc instead, for a Service
+//	healthcheck.io/port     which Service port, by name or number; default the first
+//	healthcheck.io/scheme   http or https; default https for port 443 or one named https
+//	healthcheck.io/config   any other endpoint fields, as JSON: {"interval": "10s"}
+//
+// Services are checked at name.namespace.svc, so the checker must run in
+// the cluster; Ingresses at each of their hosts.
+type k8sDiscovery struct {
+	hc        *HealthChecker
+	api       string // e.g. https://10.96.0.1:443, or http://127.0.0.1:8001 for kubectl proxy
+	client    *http.Client
+	tokenFile string // "" to send no token
+	namespace string // "" for all of them
+	prefix    string
+
+	mu       sync.Mutex
+	objects  map[string]k8sObject // by kind/namespace/name
+	problems discoveryProblems
+}
+
+// newK8sDiscovery sets up discovery against api or, if that's "", the
+// API server of the cluster the checker runs in, with its pod's service
+// account
+func newK8sDiscovery(hc *HealthChecker, api, namespace, prefix string) (*k8sDiscovery, error) {
+	d := &k8sDiscovery{
+		hc:        hc,
+		api:       strings.TrimSuffix(api, "/"),
+		client:    &http.Client{},
+		namespace: namespace,
+		prefix:    strings.TrimSuffix(prefix, "/"),
+		objects:   make(map[string]k8sObject),
+		problems:  make(discoveryProblems),
+	}
+	if api != "" {
+		return d, nil
+	}
+
+	host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+	if host == "" || port == "" {
+		return nil, errors.New("not running in a cluster (no KUBERNETES_SERVICE_HOST); give -k8s-api, e.g. http://127.0.0.1:8001 with kubectl proxy")
+	}
+	pem, err := os.ReadFile(k8sCAFile)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("%s: no certificates", k8sCAFile)
+	}
+	d.api = "https://" + net.JoinHostPort(host, port)
+	d.client.Transport = &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool}}
+	d.tokenFile = k8sTokenFile
+	return d, nil
+}
+
+// run watches every kind until ctx is cancelled
+func (d *k8sDiscovery) run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, kind := range k8sKinds {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			d.watchKind(ctx, kind)
+		}()
+	}
+	wg.Wait()
+}
+
+// watchKind lists one kind, then follows changes to it, listing again
+// whenever the watch can't resume
+func (d *k8sDiscovery) watchKind(ctx context.Context, kind k8sKind) {
+	failing := false
+	for {
+		version, err := d.list(ctx, kind)
+		if err == nil && failing {
+			d.hc.event(slog.LevelInfo, "☸️  Kubernetes discovery: listing "+kind.resource+" again", "discovery recovered", "source", "kubernetes", "resource", kind.resource)
+			failing = false
+		}
+		for err == nil {
+			version, err = d.watch(ctx, kind, version)
+		}
+		if ctx.Err() != nil {
+			return
+		}
+		if errors.Is(err, errWatchExpired) {
+			continue // too far behind; start again from a fresh list
+		}
+		if !failing {
+			d.hc.event(slog.LevelError, fmt.Sprintf("❌ Kubernetes discovery: %s: %v", kind.resource, err),
+				"discovery failed", "source", "kubernetes", "resource", kind.resource, "error", err)
+		}
+		failing = true
+		if !sleepCtx(ctx, discoveryRetry) {
+			return
+		}
+	}
+}
+
+// errWatchExpired is a watch asked to resume from a version the API
+// server no longer has (410 Gone)
+var errWatchExpired = errors.New("watch expired")
+
+// list replaces what's known of a kind and returns the version to watch
+// from
+func (d *k8sDiscovery) list(ctx context.Context, kind k8sKind) (string, error) {
+	listCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	resp, err := d.get(listCtx, kind, nil)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var list struct {
+		Metadata struct {
+			ResourceVersion string `json:"resourceVersion"`
+		} `json:"metadata"`
+		Items []k8sObject `json:"items"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&list); err != nil {
+		return "", fmt.Errorf("decoding list: %v", err)
+	}
+
+	d.mu.Lock()
+	for key := range d.objects {
+		if strings.HasPrefix(key, kind.name+"/") {
+			delete(d.objects, key)
+		}
+	}
+	for _, obj := range list.Items {
+		d.objects[d.key(kind, obj)] = obj
+	}
+	d.mu.Unlock()
+	d.publish(ctx)
+	return list.Metadata.ResourceVersion, nil
+}
+
+// watch follows changes to a kind from version until the API server
+// ends the request, returning the version to resume from
+func (d *k8sDiscovery) watch(ctx context.Context, kind k8sKind, version string) (string, error) {
+	resp, err := d.get(ctx, kind, url.Values{
+		"watch":               {"1"},
+		"resourceVersion":     {version},
+		"allowWatchBookmarks": {"true"},
+		"timeoutSeconds":      {strconv.Itoa(int(k8sWatchTimeout.Seconds()))},
+	})
+	if err != nil {
+		return version, err
+	}
+	defer resp.Body.Close()
+
+	// One JSON event per line
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(nil, 16<<20)
+	for scanner.Scan() {
+		var event struct {
+			Type   string    `json:"type"`
+			Object k8sObject `json:"object"`
+		}
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			return version, fmt.Errorf("decoding watch event: %v", err)
+		}
+		obj := event.Object
+		switch event.Type {
+		case "ADDED", "MODIFIED":
+			d.mu.Lock()
+			d.objects[d.key(kind, obj)] = obj
+			d.mu.Unlock()
+		case "DELETED":
+			d.mu.Lock()
+			delete(d.objects, d.key(kind, obj))
+			d.mu.Unlock()
+		case "BOOKMARK":
+		case "ERROR":
+			if obj.Code == http.StatusGone {
+				return version, errWatchExpired
+			}
+			return version, fmt.Errorf("watch error %d", obj.Code)
+		default:
+			continue
+		}
+		version = obj.Metadata.ResourceVersion
+		if event.Type != "BOOKMARK" {
+			d.publish(ctx)
+		}
+	}
+	if err := scanner.Err(); err != nil && ctx.Err() == nil {
+		return version, err
+	}
+	return version, ctx.Err()
+}
+
+// get requests a kind's objects, in -k8s-namespace if there is one
+func (d *k8sDiscovery) get(ctx context.Context, kind k8sKind, query url.Values) (*http.Response, error) {
+	path := kind.group
+	if d.namespace != "" {
+		path += "/namespaces/" + url.PathEscape(d.namespace)
+	}
+	u := d.api + path + "/" + kind.resource
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	if d.tokenFile != "" {
+		// Read every time: projected tokens are rotated
+		token, err := os.ReadFile(d.tokenFile)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(token)))
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("GET %s: %s", u, resp.Status)
+	}
+	return resp, nil
+}
+
+func (d *k8sDiscovery) key(kind k8sKind, obj k8sObject) string {
+	return kind.name + "/" + obj.Metadata.Namespace + "/" + obj.Metadata.Name
+}
+
+// publish turns the annotated objects into endpoints and applies them.
+// The lock is held throughout so two kinds' changes apply in order.
+func (d *k8sDiscovery) publish(ctx context.Context) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	var endpoints []Endpoint
+	problems := make(map[string]string)
+	for _, key := range slices.Sorted(maps.Keys(d.objects)) {
+		eps, err := d.endpoints(key, d.objects[key])
+		if err != nil {
+			problems[key] = err.Error()
+			continue
+		}
+		endpoints = append(endpoints, eps...)
+	}
+	d.problems.report(d.hc, "kubernetes", problems)
+
+	d.hc.update(ctx, "kubernetes", endpoints)
+}
+
+// endpoints turns one object into its endpoints: none if it isn't
+// annotated, one for a Service, one per host for an Ingress
+func (d *k8sDiscovery) endpoints(key string, obj k8sObject) ([]Endpoint, error) {
+	ann := func(name string) string { return obj.Metadata.Annotations[d.prefix+"/"+name] }
+	path, typ, extra := ann("path"), ann("type"), ann("config")
+	if path == "" && typ == "" && extra == "" {
+		return nil, nil
+	}
+	if path == "" {
+		path = "/"
+	}
+	if !strings.HasPrefix(path, "/") {
+		return nil, fmt.Errorf("%s/path %q must start with /", d.prefix, path)
+	}
+
+	if strings.HasPrefix(key, "ingress/") {
+		if typ != "" && typ != "http" {
+			return nil, fmt.Errorf("an Ingress gets http checks, not %s", typ)
+		}
+		tlsHosts := map[string]bool{}
+		for _, t := range obj.Spec.TLS {
+			for _, h := range t.Hosts {
+				tlsHosts[h] = true
+			}
+		}
+		var hosts []string
+		for _, r := range obj.Spec.Rules {
+			if r.Host != "" && !strings.HasPrefix(r.Host, "*") && !slices.Contains(hosts, r.Host) {
+				hosts = append(hosts, r.Host)
+			}
+		}
+		if len(hosts) == 0 {
+			return nil, errors.New("no rule with a host to check")
+		}
+
+		var eps []Endpoint
+		for _, host := range hosts {
+			scheme := ann("scheme")
+			if scheme == "" {
+				scheme = "http"
+				if tlsHosts[host] {
+					scheme = "https"
+				}
+			}
+			name := key
+			if len(hosts) > 1 {
+				name += "/" + host
+			}
+			ep, err := discoveredEndpoint(map[string]any{"name": name, "url": scheme + "://" + host + path}, extra)
+			if err != nil {
+				return nil, err
+			}
+			eps = append(eps, ep)
+		}
+		return eps, nil
+	}
+
+	// A Service, by its cluster DNS name or, for an ExternalName, that
+	host := obj.Metadata.Name + "." + obj.Metadata.Namespace + ".svc"
+	if obj.Spec.Type == "ExternalName" {
+		host = obj.Spec.ExternalName
+	}
+	want := ann("port")
+	port, portName := 0, ""
+	for _, p := range obj.Spec.Ports {
+		if want == "" || want == p.Name || want == strconv.Itoa(p.Port) {
+			port, portName = p.Port, p.Name
+			break
+		}
+	}
+	switch {
+	case port == 0 && want != "" && obj.Spec.Type == "ExternalName":
+		n, err := strconv.Atoi(want)
+		if err != nil {
+			return nil, fmt.Errorf("%s/port %q: an ExternalName Service has no named ports", d.prefix, want)
+		}
+		port = n
+	case port == 0 && want != "":
+		return nil, fmt.Errorf("no port %q", want)
+	case port == 0:
+		return nil, fmt.Errorf("no ports; give %s/port", d.prefix)
+	}
+	addr := net.JoinHostPort(host, strconv.Itoa(port))
+
+	fields := map[string]any{"name": key}
+	switch typ {
+	case "tcp", "grpc":
+		fields["type"], fields["address"] = typ, addr
+	case "", "http":
+		scheme := ann("scheme")
+		if scheme == "" {
+			scheme = "http"
+			if port == 443 || portName == "https" {
+				scheme = "https"
+			}
+		}
+		fields["url"] = scheme + "://" + addr + path
+	default:
+		return nil, fmt.Errorf("%s/type %q: want http, tcp or grpc", d.prefix, typ)
+	}
+	ep, err := discoveredEndpoint(fields, extra)
+	if err != nil {
+		return nil, err
+	}
+	return []Endpoint{ep}, nil
+}
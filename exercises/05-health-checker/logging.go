@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"log/slog"
+)
+
+// newLogger returns the structured logger -log-format asks for, or nil
+// for "pretty": the refreshing status table and emoji log lines meant
+// for a person at a terminal. "text" is logfmt-style key=value lines and
+// "json" one JSON object per line, for log shippers.
+func newLogger(format string, w io.Writer) (*slog.Logger, error) {
+	switch format {
+	case "pretty":
+		return nil, nil
+	case "text":
+		return slog.New(slog.NewTextHandler(w, nil)), nil
+	case "json":
+		return slog.New(slog.NewJSONHandler(w, nil)), nil
+	}
+	return nil, fmt.Errorf("unknown -log-format %q (want pretty, text or json)", format)
+}
+
+// event logs something that happened: pretty as is, or msg and attrs as
+// a structured record
+func (hc *HealthChecker) event(level slog.Level, pretty, msg string, attrs ...any) {
+	if hc.log == nil {
+		log.Print(pretty)
+		return
+	}
+	hc.log.Log(context.Background(), level, msg, attrs...)
+}
+
+// logCheck logs one check's result and, if the endpoint went up or down
+// with it, the transition. The table shows results in pretty mode, so
+// this only logs structured. Called with hc.mu held.
+func (hc *HealthChecker) logCheck(status, prev *HealthStatus) {
+	if hc.log == nil {
+		return
+	}
+	ep := status.Endpoint
+
+	attrs := []any{
+		"endpoint", ep.Name,
+		"type", ep.Type,
+		"target", ep.target(),
+		"ok", status.LastOK,
+		"state", stateName(status),
+		"latency_ms", float64(status.Latency.Microseconds()) / 1000,
+		"attempts", status.Attempts,
+	}
+	if status.Error != "" {
+		attrs = append(attrs, "error", status.Error)
+	}
+	if status.Phases != nil {
+		attrs = append(attrs, "phases", status.Phases.String())
+	}
+	if status.Partial != "" {
+		attrs = append(attrs, "partial", status.Partial)
+	}
+	if status.Muted != "" {
+		attrs = append(attrs, "muted", status.Muted)
+	}
+	level := slog.LevelInfo
+	if !status.LastOK {
+		level = slog.LevelWarn
+	}
+	hc.log.Log(context.Background(), level, "check", attrs...)
+
+	if prev == nil || prev.Healthy == status.Healthy {
+		return
+	}
+	attrs = []any{"endpoint", ep.Name, "from", stateName(prev), "to", stateName(status)}
+	if status.Error != "" {
+		attrs = append(attrs, "error", status.Error)
+	}
+	if status.Muted != "" {
+		attrs = append(attrs, "muted", status.Muted)
+	}
+	level = slog.LevelInfo
+	if !status.Healthy {
+		level = slog.LevelError
+	}
+	hc.log.Log(context.Background(), level, "state change", attrs...)
+}
+
+// logSkip logs a check skipped because a dependency is down. Called with
+// hc.mu held.
+func (hc *HealthChecker) logSkip(ep *Endpoint, dependency string) {
+	if hc.log != nil {
+		hc.log.Info("check skipped", "endpoint", ep.Name, "dependency", dependency)
+	}
+}
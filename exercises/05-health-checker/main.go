@@ -1,5 +1,6 @@
-// Package main implements an HTTP health checker for multiple endpoints.
-// This exercise teaches HTTP client usage and concurrent health monitoring.
+// Package main implements a health checker for multiple HTTP, TCP, DNS, gRPC
+// and ICMP endpoints. This exercise teaches HTTP client usage and concurrent
+// health monitoring.
 //
 // Learning objectives:
 // - Configure HTTP clients with timeouts
@@ -8,37 +9,221 @@
 // - Parse and validate responses
 //
 // Run: go run main.go
-// Or:  go run main.go -config endpoints.json
+// Or:  go run main.go -config endpoints.json   (or .yaml, .toml; reloaded on change or SIGHUP)
+// Or:  go run main.go -k8s   (in a cluster: check Services and Ingresses annotated healthcheck.io/path)
+// Or:  go run main.go -consul http://127.0.0.1:8500   (check Consul services tagged healthcheck)
+// Or:  go run main.go -etcd http://127.0.0.1:2379 -etcd-prefix /healthcheck/   (endpoints as JSON under a prefix)
+// Or:  go run main.go -ui :8080   (live status page, /api/status and /api/endpoints/{name} JSON)
+// Or:  go run main.go -config jobs.json -heartbeat :8081   (cron jobs POST /heartbeat/TOKEN; late ones go down)
+// Or:  go run main.go -metrics :9100   (Prometheus /metrics)
+// Or:  go run main.go -alert-slack https://hooks.slack.com/services/... -alert-resend 1h
+// Or:  go run main.go -db health.db   (then -db health.db -report for uptime and outages)
+// Or:  go run main.go -tui   (in-place table, sortable, with a history sparkline per endpoint)
+// Or:  go run main.go -log-format json   (structured logs instead of the status table)
+// Or:  go run main.go -db health.db -status-dir public   (static status page and SVG badges, rewritten every minute)
+// Or:  go run main.go -otlp http://localhost:4318   (spans per check and metrics to an OpenTelemetry collector)
+// Or:  go run main.go -config endpoints.json -cluster-etcd http://127.0.0.1:2379   (on each instance: they split the endpoints)
+// Or:  go run main.go -config untrusted.json -block-private   (refuse loopback, link-local and RFC 1918 targets: no SSRF)
 package main
 
 import (
 	"context"
-	"encoding/json"
+	"crypto/tls"
 	"flag"
 	"fmt"
 	"log"
+	"log/slog"
+	"math/rand/v2"
 	"net"
 	"net/http"
+	"net/url"
 	"os"
 	"os/signal"
+	"regexp"
+	"strings"
 	"sync"
 	"syscall"
 	"time"
+
+	"github.com/channyeintun/network-exercises/pkg/healthcheck"
+	"github.com/channyeintun/network-exercises/pkg/netif"
 )
 
 // Endpoint represents a health check target
 type Endpoint struct {
-	Name           string        `json:"name"`
-	URL            string        `json:"url"`
-	Interval       time.Duration `json:"interval"`
-	Timeout        time.Duration `json:"timeout"`
-	ExpectedStatus int           `json:"expected_status"`
+	Name           string                `json:"name"`
+	Type           string                `json:"type"`    // "http" (default), "tcp", "dns", "grpc", "icmp", "heartbeat" or "composite"
+	URL            string                `json:"url"`     // for http
+	Address        string                `json:"address"` // host:port, for tcp and grpc; a host, for icmp
+	Interval       time.Duration         `json:"interval"`
+	Timeout        time.Duration         `json:"timeout"`
+	ExpectedStatus healthcheck.StatusSet `json:"expected_status"` // for http; default 200
+
+	// For http, tcp and grpc: an SRV name (_http._tcp.example.com),
+	// looked up every check, whose targets are each checked in place of
+	// the address, or of the url's host and port (the url's host is
+	// still what's asked for). The endpoint is down only if every target
+	// is, and degraded while some are.
+	SRV string `json:"srv"`
+
+	// How many failed checks in a row take a healthy endpoint down, and
+	// how many passed ones bring it back up (both default 1), so one
+	// blip doesn't flip the state
+	FailureThreshold int `json:"failure_threshold"`
+	SuccessThreshold int `json:"success_threshold"`
+
+	// How many times a failed attempt is retried within one check, so a
+	// connection reset doesn't count as a failure, and the wait before
+	// the first retry (default 500ms), doubling for each one after
+	Retries      int           `json:"retries"`
+	RetryBackoff time.Duration `json:"retry_backoff"`
+
+	// Latency SLOs: a passing check slower than warn_latency leaves the
+	// endpoint up but degraded, and one slower than critical_latency
+	// fails. Zero disables either.
+	WarnLatency     time.Duration `json:"warn_latency"`
+	CriticalLatency time.Duration `json:"critical_latency"`
+
+	// For http: the request to send, by default a bare GET. A "Host"
+	// header overrides the host the URL names.
+	Method  string            `json:"method"`
+	Headers map[string]string `json:"headers"`
+	Body    string            `json:"body"`
+	Auth    *Auth             `json:"auth"`
+
+	// For http: whether to follow redirects (by default yes, up to
+	// max_redirects, default 10; if not, the 3xx is what expected_status
+	// checks) and, if final_url is set, where they must end up
+	FollowRedirects *bool  `json:"follow_redirects"`
+	MaxRedirects    int    `json:"max_redirects"`
+	FinalURL        string `json:"final_url"`
+
+	// For http: the protocol to insist on, "http/1.1", "h2" or "h3"
+	// (QUIC, https only), and fail without; by default HTTP/1.1. Which one
+	// was used is shown either way.
+	Protocol string `json:"protocol"`
+
+	// For http: how connections are kept between checks. By default an
+	// idle one is kept for 90s and reused, so with a shorter interval
+	// only the first check pays for DNS, connect and TLS; a body over
+	// 4MB isn't read to the end, though, and closes its connection. With
+	// fresh_connections every check opens a new one, so regressions in
+	// those still show; max_idle_conns and idle_conn_timeout size and
+	// age the endpoint's pool instead.
+	FreshConnections bool          `json:"fresh_connections"`
+	MaxIdleConns     int           `json:"max_idle_conns"`
+	IdleConnTimeout  time.Duration `json:"idle_conn_timeout"`
+
+	// For http: what the response body must hold, within its first
+	// max_body_bytes (default 64 KiB). json_path must exist and, if
+	// json_value is set, have that value.
+	BodyContains string `json:"body_contains"`
+	BodyRegexp   string `json:"body_regexp"`
+	JSONPath     string `json:"json_path"`
+	JSONValue    string `json:"json_value"`
+	MaxBodyBytes int64  `json:"max_body_bytes"`
+
+	// For http: how big the body must be, to catch truncated or runaway
+	// responses (no more than max_bytes+1 of it is read), and the media
+	// type it must be served as, e.g. "application/json" or "image/*",
+	// whatever its charset
+	MinBytes            int64  `json:"min_bytes"`
+	MaxBytes            int64  `json:"max_bytes"`
+	ExpectedContentType string `json:"expected_content_type"`
+
+	bodyRegexp *regexp.Regexp
+	jsonPath   jsonPath
+
+	// For dns: the name to look up, its record type (default A), the
+	// server to ask (host[:port]; default the system resolver), answers
+	// that must be among those returned, and how long it may take
+	Query           string        `json:"query"`
+	RecordType      string        `json:"record_type"`
+	Resolver        string        `json:"resolver"`
+	ExpectedAnswers []string      `json:"expected_answers"`
+	MaxLatency      time.Duration `json:"max_latency"`
+
+	// For http, tcp, grpc and icmp, how the host is found, e.g. to check
+	// the inside of a split-horizon setup: resolver names the DNS server
+	// to look it up with rather than the system's, ip_version connects
+	// only over IPv4 or IPv6 (4 or 6), and for all but icmp, ip connects
+	// to that address without a lookup, like curl --resolve. Host and
+	// SNI still come from the url or address.
+	IPVersion int    `json:"ip_version"`
+	IP        string `json:"ip"`
+
+	// Where to connect through: an http(s):// proxy or a socks5:// one,
+	// e.g. a jump host's ssh -D, with any credentials in the URL. Used
+	// by http, tcp and grpc checks; dns checks always go direct.
+	Proxy    string `json:"proxy"`
+	proxyURL *url.URL
+
+	// For grpc: the service to ask about ("" for the whole server) and
+	// whether the server speaks TLS
+	Service string `json:"service"`
+	TLS     bool   `json:"tls"`
+
+	// For icmp: how many pings each check sends (default 3); it passes
+	// if any is answered
+	Pings int `json:"pings"`
+
+	// For https and grpc with tls: a client certificate and key (PEM
+	// files) for servers that require mutual TLS, read again whenever
+	// either file changes
+	ClientCert string `json:"client_cert"`
+	ClientKey  string `json:"client_key"`
+
+	// For https and grpc with tls: the CA certificates (a PEM file) to
+	// trust instead of the system's, for a private CA; the name to send
+	// in SNI and expect on the certificate, for an IP-only URL; and, as
+	// a last resort, not verifying the certificate at all
+	CAFile             string `json:"ca_file"`
+	ServerName         string `json:"server_name"`
+	InsecureSkipVerify bool   `json:"insecure_skip_verify"`
+
+	tlsConfig *tls.Config
+
+	// When failures are expected, and recorded but not alerted on
+	Maintenance []maintenanceWindow `json:"maintenance"`
+
+	// Endpoints this one needs: while any is down it isn't checked but
+	// shown as skipped, so an outage of a shared gateway alerts once
+	DependsOn []string `json:"depends_on"`
+
+	// For composite: up if all of these endpoints are, or if any is
+	All []string `json:"all"`
+	Any []string `json:"any"`
+
+	// Interface names or local IPs to check from, each at once, instead
+	// of -interface's: the results are shown side by side, to compare
+	// routes on a multi-homed host or through a VPN. Like srv targets, it
+	// fails only if every source does.
+	Interfaces []string `json:"interfaces"`
+
+	// For heartbeat: the token a job (a cron job, a batch run) POSTs its
+	// heartbeats to -heartbeat with, or env: or file: to read it from,
+	// and how long it may go without sending one before it's down
+	Token string        `json:"token"`
+	Grace time.Duration `json:"grace"`
+
+	client *http.Client // for http checks: shared, or the endpoint's own
+	dialer *net.Dialer  // for a check from one of its interfaces; nil for the shared one
 }
 
 // HealthStatus represents the current health of an endpoint
 type HealthStatus struct {
 	Endpoint  *Endpoint
-	Healthy   bool
+	Healthy   bool           // the state, which moves only once a threshold is met
+	LastOK    bool           // whether the latest check itself passed
+	Streak    int            // checks in a row with the latest's result
+	Attempts  int            // tries the latest check took, retries included
+	Degraded  bool           // up, but the latest check was slower than WarnLatency or failed at some SRV targets or from some interfaces
+	Partial   string         // for srv and interfaces: the targets or sources down while others are up
+	Targets   []srvTarget    // for srv: how each target's latest check went
+	Sources   []sourceResult // for interfaces: how the latest check went from each
+	Phases    *httpPhases    // where an http check's time went
+	Muted     string         // why failures aren't alerted on right now, if they aren't
+	Skipped   string         // the dependency whose outage stopped the latest check, if one did
 	Latency   time.Duration
 	LastCheck time.Time
 	Error     string
@@ -51,109 +236,429 @@ var defaultEndpoints = []Endpoint{
 		URL:            "https://www.google.com",
 		Interval:       5 * time.Second,
 		Timeout:        3 * time.Second,
-		ExpectedStatus: 200,
+		ExpectedStatus: healthcheck.StatusSet{{Lo: 200, Hi: 200}},
 	},
 	{
 		Name:           "Cloudflare",
 		URL:            "https://www.cloudflare.com",
 		Interval:       5 * time.Second,
 		Timeout:        3 * time.Second,
-		ExpectedStatus: 200,
+		ExpectedStatus: healthcheck.StatusSet{{Lo: 200, Hi: 200}},
 	},
 	{
 		Name:           "GitHub",
 		URL:            "https://api.github.com",
 		Interval:       5 * time.Second,
 		Timeout:        3 * time.Second,
-		ExpectedStatus: 200,
+		ExpectedStatus: healthcheck.StatusSet{{Lo: 200, Hi: 200}},
 	},
 	{
 		Name:           "Example (should work)",
 		URL:            "https://example.com",
 		Interval:       5 * time.Second,
 		Timeout:        3 * time.Second,
-		ExpectedStatus: 200,
+		ExpectedStatus: healthcheck.StatusSet{{Lo: 200, Hi: 200}},
 	},
 	{
 		Name:           "Bad Endpoint (should fail)",
 		URL:            "https://this-does-not-exist-12345.com",
 		Interval:       10 * time.Second,
 		Timeout:        2 * time.Second,
-		ExpectedStatus: 200,
+		ExpectedStatus: healthcheck.StatusSet{{Lo: 200, Hi: 200}},
 	},
 }
 
+// target is what the endpoint checks, for display
+func (ep *Endpoint) target() string {
+	switch ep.Type {
+	case "tcp":
+		if ep.SRV != "" {
+			return "tcp+srv://" + ep.SRV
+		}
+		return "tcp://" + ep.Address
+	case "grpc":
+		target := "grpc://" + ep.Address
+		if ep.SRV != "" {
+			target = "grpc+srv://" + ep.SRV
+		}
+		if ep.Service != "" {
+			return target + "/" + ep.Service
+		}
+		return target
+	case "icmp":
+		return "icmp://" + ep.Address
+	case "heartbeat":
+		return "heartbeat within " + ep.Grace.String()
+	case "composite":
+		if len(ep.Any) > 0 {
+			return "any(" + strings.Join(ep.Any, ", ") + ")"
+		}
+		return "all(" + strings.Join(ep.All, ", ") + ")"
+	case "dns":
+		// RFC 4501 DNS URIs
+		if ep.Resolver == "" {
+			return "dns:" + ep.Query + "?type=" + ep.RecordType
+		}
+		return "dns://" + ep.Resolver + "/" + ep.Query + "?type=" + ep.RecordType
+	}
+	if ep.SRV != "" {
+		return ep.URL + " (SRV " + ep.SRV + ")"
+	}
+	return ep.URL
+}
+
 // HealthChecker manages health checks for multiple endpoints
 type HealthChecker struct {
-	endpoints []Endpoint
-	client    *http.Client
-	statuses  map[string]*HealthStatus
-	mu        sync.RWMutex
+	endpoints   []Endpoint
+	dialer      *net.Dialer
+	client      *http.Client
+	guard       *addrGuard // nil without -block-private; applied by dialer
+	statuses    map[string]*HealthStatus
+	windows     map[string]*checkWindow // rolling statistics, by endpoint name
+	alerts      *alerter                // nil without -alert-webhook or -alert-slack
+	otel        *otlpExporter           // nil without -otlp
+	log         *slog.Logger            // nil for -log-format pretty
+	silences    map[int]silence         // by ID, from the admin API
+	heartbeats  map[string]*heartbeats  // by endpoint name, for heartbeat endpoints
+	nextSilence int
+	store       *Store // nil without -db
+	mu          sync.RWMutex
+
+	// Running check loops by endpoint name, touched only by main and
+	// then by update, and the endpoints each source (-config, discovery)
+	// last gave it
+	monitors map[string]*monitor
+	sources  map[string][]Endpoint
+	updateMu sync.Mutex
+	wg       sync.WaitGroup
+
+	stagger bool          // spread each loop's first check over its interval
+	slots   chan struct{} // one per check allowed in flight; nil for no limit
+
+	checkers map[string]Checker // by endpoint type
+
+	// With -cluster-etcd or -cluster-consul, the instances sharing the
+	// endpoints, and which of them checks each endpoint, by name; nil
+	// without
+	cluster *cluster
+	owners  map[string]string
 }
 
 func main() {
-	configFile := flag.String("config", "", "JSON config file with endpoints")
+	configFile := flag.String("config", "", "config file with endpoints (JSON, YAML or TOML)")
+	k8s := flag.Bool("k8s", false, "Discover endpoints from annotated Kubernetes Services and Ingresses, and keep them in step")
+	k8sAPI := flag.String("k8s-api", "", "Kubernetes API server for -k8s, e.g. http://127.0.0.1:8001 for kubectl proxy (default: the cluster this runs in)")
+	k8sNamespace := flag.String("k8s-namespace", "", "Only discover in this namespace (default all)")
+	k8sAnnotation := flag.String("k8s-annotation", "healthcheck.io", "Annotation prefix -k8s looks for: PREFIX/path, /type, /port, /scheme and /config")
+	consulAddr := flag.String("consul", "", "Discover endpoints from services in this Consul agent's catalog, e.g. http://127.0.0.1:8500")
+	consulTag := flag.String("consul-tag", "healthcheck", "Only discover Consul services with this tag")
+	consulToken := flag.String("consul-token", os.Getenv("CONSUL_HTTP_TOKEN"), "Consul ACL token (default $CONSUL_HTTP_TOKEN)")
+	etcdAddr := flag.String("etcd", "", "Discover endpoints from keys under -etcd-prefix in etcd, e.g. http://127.0.0.1:2379")
+	etcdPrefix := flag.String("etcd-prefix", "/healthcheck/", "Key prefix -etcd reads endpoints from, one JSON endpoint per key")
+	hostname, _ := os.Hostname()
+	clusterEtcd := flag.String("cluster-etcd", "", "Split the endpoints with the other instances registered in this etcd, e.g. http://127.0.0.1:2379")
+	clusterConsul := flag.String("cluster-consul", "", "Split the endpoints with the other instances registered in this Consul agent's KV store, e.g. http://127.0.0.1:8500")
+	clusterPrefix := flag.String("cluster-prefix", "/healthcheck-cluster/", "Key prefix cluster members register under")
+	clusterID := flag.String("cluster-id", hostname, "This instance's name in the cluster, unique among its members")
+	clusterTTL := flag.Duration("cluster-ttl", 15*time.Second, "How long after an instance stops renewing its registration the others take its endpoints over")
 	interfaceName := flag.String("interface", "", "Network interface to bind to (optional)")
+	blockPrivate := flag.Bool("block-private", false, "Refuse to check loopback, link-local and private addresses, for a checker that runs configs from others")
+	allowPrivate := flag.String("allow-private", "", "With -block-private, still allow these comma-separated CIDRs or IPs, e.g. 10.20.0.0/16")
+	uiAddr := flag.String("ui", "", "Serve a live status page on this address, e.g. :8080")
+	heartbeatAddr := flag.String("heartbeat", "", "Receive heartbeat endpoints' heartbeats (POST /heartbeat/TOKEN) on this address, e.g. :8081")
+	adminToken := flag.String("admin-token", "", "Bearer token the -ui admin API (silences) requires; default none")
+	metricsAddr := flag.String("metrics", "", "Expose Prometheus metrics on this address, e.g. :9100")
+	alertWebhook := flag.String("alert-webhook", "", "POST a JSON event here when an endpoint goes down or comes back up")
+	alertSlack := flag.String("alert-slack", "", "Post alerts to this Slack incoming webhook")
+	alertResend := flag.Duration("alert-resend", 0, "Repeat the alert this often while an endpoint stays down (0 for once)")
+	dbPath := flag.String("db", "", "Record every check in this SQLite database, for uptime reports that survive restarts")
+	report := flag.Bool("report", false, "Print uptime over 24h/7d/30d and the outage log from -db, then exit")
+	statusDir := flag.String("status-dir", "", "Write a static status page and SVG badges to this directory, from -db's history")
+	statusInterval := flag.Duration("status-interval", time.Minute, "How often to rewrite -status-dir")
+	statusTitle := flag.String("status-title", "Service Status", "Title of the -status-dir page")
+	otlpEndpoint := flag.String("otlp", "", "Export check spans and metrics over OTLP/HTTP to this collector, e.g. http://localhost:4318")
+	otlpInterval := flag.Duration("otlp-interval", 15*time.Second, "How often to export metrics with -otlp")
+	maxConcurrent := flag.Int("max-concurrent", 64, "Most checks in flight at once (0 for no limit)")
+	stagger := flag.Bool("stagger", true, "Start each endpoint's checks at a random point in its interval, not all at once")
+	logFormat := flag.String("log-format", "pretty", "pretty (a status table for terminals), text (key=value) or json logs")
+	tuiMode := flag.Bool("tui", false, "Show an interactive, in-place status table instead of a new one every 2 seconds")
 	flag.Parse()
 
-	// Load endpoints
+	if *statusDir != "" && *dbPath == "" {
+		log.Fatal("-status-dir needs -db for the uptime history and incidents it publishes")
+	}
+	if *statusInterval <= 0 {
+		log.Fatal("-status-interval must be positive")
+	}
+	for flagName, addr := range map[string]string{"-consul": *consulAddr, "-etcd": *etcdAddr, "-cluster-etcd": *clusterEtcd, "-cluster-consul": *clusterConsul} {
+		if u, err := url.Parse(addr); addr != "" && (err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "") {
+			log.Fatalf("%s wants an http:// or https:// URL, got %q", flagName, addr)
+		}
+	}
+	if *clusterEtcd != "" || *clusterConsul != "" {
+		via := "etcd"
+		switch {
+		case *clusterEtcd != "" && *clusterConsul != "":
+			log.Fatal("-cluster-etcd and -cluster-consul are two ways to do one thing; pick one")
+		case *clusterConsul != "":
+			via = "consul"
+		}
+		if err := validClusterTTL(*clusterTTL, via); err != nil {
+			log.Fatal(err)
+		}
+		if *clusterID == "" || strings.Contains(*clusterID, "/") {
+			log.Fatalf("-cluster-id wants a name without slashes, got %q", *clusterID)
+		}
+	}
+	var guard *addrGuard
+	switch {
+	case *blockPrivate:
+		var err error
+		if guard, err = newAddrGuard(*allowPrivate); err != nil {
+			log.Fatalf("-allow-private: %v", err)
+		}
+	case *allowPrivate != "":
+		log.Fatal("-allow-private makes exceptions to -block-private; add it")
+	}
+	if *tuiMode && *logFormat != "pretty" {
+		log.Fatal("-tui is a display mode of its own; drop -log-format")
+	}
+	if *tuiMode && !isTerminal(os.Stdout) {
+		log.Fatal("-tui needs a terminal")
+	}
+
+	logger, err := newLogger(*logFormat, os.Stderr)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if logger != nil {
+		slog.SetDefault(logger) // and so every log.Printf too
+	}
+
+	if *report {
+		if *dbPath == "" {
+			log.Fatal("-report requires -db")
+		}
+		if err := printStoredReport(*dbPath); err != nil {
+			log.Fatalf("Report failed: %v", err)
+		}
+		return
+	}
+
+	// Load endpoints; with discovery, only the config's are fixed
 	endpoints := defaultEndpoints
-	if *configFile != "" {
+	switch {
+	case *configFile != "":
 		loaded, err := loadEndpoints(*configFile)
 		if err != nil {
 			log.Fatalf("Failed to load config: %v", err)
 		}
 		endpoints = loaded
+	case *k8s, *consulAddr != "", *etcdAddr != "":
+		endpoints = nil
+	default:
+		// Filled in with the same defaults as a config's
+		for i := range endpoints {
+			if err := endpoints[i].validate(); err != nil {
+				log.Fatalf("Default endpoint %q: %v", endpoints[i].Name, err)
+			}
+		}
+	}
+
+	// Heartbeats would only ever be late with nothing to receive them
+	for _, ep := range endpoints {
+		if ep.Type == "heartbeat" && *heartbeatAddr == "" {
+			log.Fatalf("Endpoint %q is a heartbeat check: give -heartbeat an address to receive its heartbeats on", ep.Name)
+		}
 	}
 
-	// Create HTTP client
-	client := createClient(*interfaceName)
+	// Create the dialer TCP checks use and the HTTP client built on it
+	dialer := createDialer(*interfaceName, guard)
+	client := createClient(dialer)
 
 	// Initialize health checker
 	hc := &HealthChecker{
-		endpoints: endpoints,
-		client:    client,
-		statuses:  make(map[string]*HealthStatus),
+		endpoints:  endpoints,
+		dialer:     dialer,
+		client:     client,
+		guard:      guard,
+		statuses:   make(map[string]*HealthStatus),
+		windows:    make(map[string]*checkWindow),
+		silences:   make(map[int]silence),
+		heartbeats: make(map[string]*heartbeats),
+		monitors:   make(map[string]*monitor),
+		sources:    map[string][]Endpoint{configSource: endpoints},
+		stagger:    *stagger,
+		log:        logger,
+	}
+	hc.checkers = newCheckers(hc)
+	switch {
+	case *clusterEtcd != "":
+		hc.cluster = newCluster(hc, *clusterID, *clusterTTL, "etcd", newEtcdMembers(*clusterEtcd, *clusterPrefix))
+	case *clusterConsul != "":
+		hc.cluster = newCluster(hc, *clusterID, *clusterTTL, "consul", newConsulMembers(*clusterConsul, *clusterPrefix, *consulToken))
+	}
+	if *maxConcurrent > 0 {
+		hc.slots = make(chan struct{}, *maxConcurrent)
 	}
 
 	// Setup context for cancellation
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
+	// Alert on state changes if anyone is listening
+	var receivers []alertReceiver
+	if *alertWebhook != "" {
+		receivers = append(receivers, alertReceiver{url: *alertWebhook})
+	}
+	if *alertSlack != "" {
+		receivers = append(receivers, alertReceiver{url: *alertSlack, slack: true})
+	}
+	if len(receivers) > 0 {
+		hc.alerts = newAlerter(ctx, receivers, *alertResend)
+	}
+
+	if *otlpEndpoint != "" {
+		if u, err := url.Parse(*otlpEndpoint); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			log.Fatalf("-otlp wants a collector URL like http://localhost:4318, got %q", *otlpEndpoint)
+		}
+		if *otlpInterval <= 0 {
+			log.Fatalf("-otlp-interval must be positive")
+		}
+		hc.otel = newOTLPExporter(*otlpEndpoint, *otlpInterval)
+	}
+
+	if *dbPath != "" {
+		store, err := openStore(*dbPath)
+		if err != nil {
+			log.Fatalf("Failed to open database: %v", err)
+		}
+		hc.store = store
+	}
+
 	// Handle signals
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
 
 	go func() {
 		<-sigChan
-		fmt.Println("\n🛑 Shutting down...")
+		if logger == nil {
+			fmt.Println("\n🛑 Shutting down...")
+		} else {
+			logger.Info("shutting down")
+		}
 		cancel()
 	}()
 
-	fmt.Println("🏥 Health Checker Starting")
-	fmt.Println("─────────────────────────────────────────────────")
-	fmt.Printf("   Monitoring %d endpoints\n", len(endpoints))
-	fmt.Println("   Press Ctrl+C to stop")
-	fmt.Println("─────────────────────────────────────────────────")
+	switch {
+	case *tuiMode:
+	case logger == nil:
+		fmt.Println("🏥 Health Checker Starting")
+		fmt.Println("─────────────────────────────────────────────────")
+		fmt.Printf("   Monitoring %d endpoints\n", len(endpoints))
+		fmt.Println("   Press Ctrl+C to stop")
+		fmt.Println("─────────────────────────────────────────────────")
+	default:
+		logger.Info("starting", "endpoints", len(endpoints))
+	}
+
+	// Start health checks; in a cluster, this instance's share once it
+	// knows the members
+	if hc.cluster == nil {
+		for i := range endpoints {
+			hc.start(ctx, &endpoints[i])
+		}
+	} else {
+		hc.wg.Add(1)
+		go func() {
+			defer hc.wg.Done()
+			hc.cluster.run(ctx)
+		}()
+	}
+	if *configFile != "" {
+		go hc.watchConfig(ctx, *configFile)
+	}
+	if *k8s {
+		discovery, err := newK8sDiscovery(hc, *k8sAPI, *k8sNamespace, *k8sAnnotation)
+		if err != nil {
+			log.Fatalf("-k8s: %v", err)
+		}
+		go discovery.run(ctx)
+	}
+	if *consulAddr != "" {
+		go newConsulDiscovery(hc, *consulAddr, *consulTag, *consulToken).run(ctx)
+	}
+	if *etcdAddr != "" {
+		go newEtcdDiscovery(hc, *etcdAddr, *etcdPrefix).run(ctx)
+	}
 
-	// Start health checks
-	var wg sync.WaitGroup
-	for i := range endpoints {
-		wg.Add(1)
-		go func(ep *Endpoint) {
-			defer wg.Done()
-			hc.monitorEndpoint(ctx, ep)
-		}(&endpoints[i])
+	// Start status display; structured logs have a record per check
+	// instead
+	var tuiDone chan struct{}
+	switch {
+	case *tuiMode:
+		tuiDone = make(chan struct{})
+		go func() {
+			hc.runTUI(ctx, cancel)
+			close(tuiDone)
+		}()
+	case logger == nil:
+		go hc.displayStatus(ctx)
 	}
 
-	// Start status display
-	go hc.displayStatus(ctx)
+	if *uiAddr != "" {
+		go func() {
+			if err := serveUI(ctx, *uiAddr, *adminToken, hc); err != nil {
+				log.Fatalf("Status page failed: %v", err)
+			}
+		}()
+	}
+	if *heartbeatAddr != "" {
+		go func() {
+			if err := serveHeartbeats(ctx, *heartbeatAddr, hc); err != nil {
+				log.Fatalf("Heartbeat receiver failed: %v", err)
+			}
+		}()
+	}
+	if *statusDir != "" {
+		go hc.publishStatus(ctx, *statusDir, *statusTitle, *statusInterval)
+	}
+	if *metricsAddr != "" {
+		go func() {
+			if err := serveMetrics(ctx, *metricsAddr); err != nil {
+				log.Fatalf("Metrics server failed: %v", err)
+			}
+		}()
+	}
 
-	wg.Wait()
-	fmt.Println("✅ Health checker stopped")
+	<-ctx.Done()
+	if tuiDone != nil {
+		<-tuiDone // the terminal back to normal before anything more is printed
+	}
+	hc.wg.Wait()
+	hc.alerts.close()
+	hc.otel.close()
+	if err := hc.store.Close(); err != nil {
+		log.Printf("Closing database: %v", err)
+	}
+	if logger == nil {
+		fmt.Println("✅ Health checker stopped")
+	} else {
+		logger.Info("stopped")
+	}
 }
 
 func (hc *HealthChecker) monitorEndpoint(ctx context.Context, ep *Endpoint) {
+	// Endpoints sharing an interval would otherwise all be checked in
+	// the same instant, every interval
+	if hc.stagger {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(rand.N(ep.Interval)):
+		}
+	}
+
 	ticker := time.NewTicker(ep.Interval)
 	defer ticker.Stop()
 
@@ -170,46 +675,146 @@ func (hc *HealthChecker) monitorEndpoint(ctx context.Context, ep *Endpoint) {
 	}
 }
 
+// checkEndpoint runs one check cycle: an attempt and, while attempts
+// fail, up to ep.Retries more after a backoff that doubles each time.
+// Only the last attempt's latency and error are kept.
 func (hc *HealthChecker) checkEndpoint(ctx context.Context, ep *Endpoint) {
-	reqCtx, cancel := context.WithTimeout(ctx, ep.Timeout)
-	defer cancel()
-
-	req, err := http.NewRequestWithContext(reqCtx, "GET", ep.URL, nil)
-	if err != nil {
-		hc.updateStatus(ep, false, 0, err.Error())
+	if dependency := hc.downDependency(ep); dependency != "" {
+		hc.skip(ep, dependency)
 		return
 	}
+	if p, ok := hc.checkers[ep.Type].(Pender); ok && p.Pending(ep) {
+		return // until there's something to go on
+	}
 
 	start := time.Now()
-	resp, err := hc.client.Do(req)
-	latency := time.Since(start)
+	backoff := ep.RetryBackoff
+	attempts := 1
+	res := hc.attempt(ctx, ep)
+	for ; res.Err != nil && attempts <= ep.Retries; attempts++ {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+		retriesTotal.WithLabelValues(ep.Name).Inc()
+		res = hc.attempt(ctx, ep)
+	}
 
-	if err != nil {
-		hc.updateStatus(ep, false, latency, err.Error())
+	// A check cut short by shutdown or a reload says nothing about the
+	// endpoint
+	if ctx.Err() != nil {
 		return
 	}
-	defer resp.Body.Close()
+	res.Attempts = attempts
+	hc.updateStatus(ep, res)
+	hc.otel.check(ep, start, res)
+}
 
-	healthy := resp.StatusCode == ep.ExpectedStatus
-	errMsg := ""
-	if !healthy {
-		errMsg = fmt.Sprintf("status %d (expected %d)", resp.StatusCode, ep.ExpectedStatus)
+// attempt checks the endpoint once, within its timeout. It waits for a
+// slot first if -max-concurrent checks are already in flight; the wait
+// counts against neither the timeout nor the latency.
+func (hc *HealthChecker) attempt(ctx context.Context, ep *Endpoint) Result {
+	if hc.slots != nil {
+		select {
+		case hc.slots <- struct{}{}:
+			defer func() { <-hc.slots }()
+		case <-ctx.Done():
+			return Result{Err: ctx.Err()}
+		}
 	}
+	inflightChecks.Inc()
+	defer inflightChecks.Dec()
+
+	ctx, cancel := context.WithTimeout(ctx, ep.Timeout)
+	defer cancel()
 
-	hc.updateStatus(ep, healthy, latency, errMsg)
+	res := hc.check(ctx, ep)
+	if res.Err == nil && ep.CriticalLatency > 0 && res.Latency > ep.CriticalLatency {
+		res.Err = fmt.Errorf("took %v, over critical_latency %v", res.Latency.Round(time.Millisecond), ep.CriticalLatency)
+	}
+	return res
 }
 
-func (hc *HealthChecker) updateStatus(ep *Endpoint, healthy bool, latency time.Duration, errMsg string) {
+// check checks the endpoint once: from each of its interfaces, at each
+// of its SRV targets, or with its type's Checker
+func (hc *HealthChecker) check(ctx context.Context, ep *Endpoint) Result {
+	var res Result
+	start := time.Now()
+	switch {
+	case len(ep.Interfaces) > 0:
+		res = hc.checkSources(ctx, ep)
+	case ep.SRV != "":
+		res = hc.checkSRV(ctx, ep)
+	default:
+		res = hc.checkers[ep.Type].Check(ctx, ep)
+	}
+	if res.Latency == 0 {
+		res.Latency = time.Since(start)
+	}
+	return res
+}
+
+func (hc *HealthChecker) updateStatus(ep *Endpoint, res Result) {
 	hc.mu.Lock()
 	defer hc.mu.Unlock()
 
-	hc.statuses[ep.Name] = &HealthStatus{
+	healthy, latency := res.Err == nil, res.Latency
+	status := &HealthStatus{
 		Endpoint:  ep,
 		Healthy:   healthy,
+		LastOK:    healthy,
+		Streak:    1,
+		Attempts:  res.Attempts,
 		Latency:   latency,
+		Phases:    res.Phases,
+		Partial:   res.Partial,
+		Targets:   res.Targets,
+		Sources:   res.Sources,
 		LastCheck: time.Now(),
-		Error:     errMsg,
 	}
+	if res.Err != nil {
+		status.Error = res.Err.Error()
+	}
+
+	// The first check sets the state; after that it takes a streak
+	prev := hc.statuses[ep.Name]
+	if prev != nil && prev.Streak == 0 {
+		prev = nil // skipped before it was ever checked
+	}
+	if prev != nil {
+		if prev.LastOK == healthy {
+			status.Streak = prev.Streak + 1
+		}
+		status.Healthy = prev.Healthy
+		switch {
+		case healthy && !prev.Healthy && status.Streak >= ep.SuccessThreshold:
+			status.Healthy = true
+		case !healthy && prev.Healthy && status.Streak >= ep.FailureThreshold:
+			status.Healthy = false
+		}
+	}
+
+	slow := ep.WarnLatency > 0 && latency > ep.WarnLatency
+	status.Degraded = status.Healthy && healthy && (slow || status.Partial != "")
+
+	window := hc.windows[ep.Name]
+	if window == nil {
+		window = &checkWindow{}
+		hc.windows[ep.Name] = window
+	}
+	window.add(checkSample{at: status.LastCheck, latency: latency, ok: healthy, err: status.Error})
+
+	status.Muted = hc.mutedReason(ep, status.LastCheck)
+
+	observeCheck(status, prev)
+	hc.logCheck(status, prev)
+	if status.Muted == "" {
+		hc.alerts.observe(status)
+	}
+	hc.store.record(status)
+	hc.statuses[ep.Name] = status
 }
 
 func (hc *HealthChecker) displayStatus(ctx context.Context) {
@@ -232,6 +837,10 @@ func (hc *HealthChecker) printStatus() {
 
 	fmt.Println("\n📊 Health Status:")
 	for _, ep := range hc.endpoints {
+		if member := hc.checkedBy(ep.Name); member != "" {
+			fmt.Printf("   👥 %-25s checked by %s\n", ep.Name, member)
+			continue
+		}
 		status, ok := hc.statuses[ep.Name]
 		if !ok {
 			fmt.Printf("   ⏳ %-25s checking...\n", ep.Name)
@@ -239,38 +848,96 @@ func (hc *HealthChecker) printStatus() {
 		}
 
 		icon := "✅"
-		if !status.Healthy {
+		switch {
+		case !status.Healthy:
 			icon = "❌"
+		case status.Partial != "":
+			icon = "🟠"
+		case status.Degraded:
+			icon = "🐢"
+		}
+		if status.Muted != "" && !status.LastOK {
+			icon = "🔇"
+		}
+		if status.Skipped != "" {
+			fmt.Printf("   ⏭️  %-25s skipped (dependency %s down)\n", ep.Name, status.Skipped)
+			continue
 		}
 
 		latencyStr := fmt.Sprintf("%.0fms", float64(status.Latency.Microseconds())/1000)
-		if status.Error != "" {
+		if status.Attempts > 1 {
+			latencyStr += fmt.Sprintf(" after %d attempts", status.Attempts)
+		}
+		latencyStr += " [" + hc.windows[ep.Name].stats().String() + "]"
+		switch {
+		case status.Healthy && !status.LastOK:
+			fmt.Printf("   %s %-25s %s (failing %d/%d: %s)\n", icon, ep.Name, latencyStr, status.Streak, ep.FailureThreshold, status.Error)
+		case !status.Healthy && status.LastOK:
+			fmt.Printf("   %s %-25s %s (recovering %d/%d)\n", icon, ep.Name, latencyStr, status.Streak, ep.SuccessThreshold)
+		case status.Error != "":
 			fmt.Printf("   %s %-25s %s (error: %s)\n", icon, ep.Name, latencyStr, status.Error)
-		} else {
+		case status.Partial != "":
+			fmt.Printf("   %s %-25s %s (partial outage: %s)\n", icon, ep.Name, latencyStr, status.Partial)
+		case status.Degraded:
+			fmt.Printf("   %s %-25s %s (degraded: over warn_latency %v)\n", icon, ep.Name, latencyStr, ep.WarnLatency)
+		default:
 			fmt.Printf("   %s %-25s %s\n", icon, ep.Name, latencyStr)
 		}
+		if status.Phases != nil && status.Phases.TTFB > 0 {
+			fmt.Printf("      ↳ %s\n", status.Phases)
+		}
+		for _, t := range status.Targets {
+			if t.Error != "" {
+				fmt.Printf("      ↳ ❌ %s (%s)\n", t.Address, t.Error)
+			} else {
+				fmt.Printf("      ↳ ✅ %s %.0fms\n", t.Address, float64(t.Latency.Microseconds())/1000)
+			}
+		}
+		for _, s := range status.Sources {
+			from := s.Source
+			if s.Address != "" && s.Address != s.Source {
+				from += " (" + s.Address + ")"
+			}
+			if s.Error != "" {
+				fmt.Printf("      ↳ ❌ from %s: %s\n", from, s.Error)
+			} else {
+				fmt.Printf("      ↳ ✅ from %s %.0fms\n", from, float64(s.Latency.Microseconds())/1000)
+			}
+		}
+		if status.Muted != "" {
+			fmt.Printf("      🔇 muted (%s)\n", status.Muted)
+		}
 	}
 }
 
-func createClient(interfaceName string) *http.Client {
-	transport := &http.Transport{
-		MaxIdleConns:        100,
-		MaxIdleConnsPerHost: 10,
-		IdleConnTimeout:     90 * time.Second,
+// createDialer returns the dialer every check connects with, bound to
+// the interface if one is given and vetting addresses with the guard
+func createDialer(interfaceName string, guard *addrGuard) *net.Dialer {
+	dialer := &net.Dialer{
+		Timeout: 5 * time.Second,
+	}
+	if guard != nil {
+		dialer.Control = guard.control
 	}
 
 	// Bind to specific interface if provided
 	if interfaceName != "" {
 		localAddr := getInterfaceAddr(interfaceName)
 		if localAddr != nil {
-			dialer := &net.Dialer{
-				LocalAddr: localAddr,
-				Timeout:   5 * time.Second,
-			}
-			transport.DialContext = dialer.DialContext
+			dialer.LocalAddr = localAddr
 			log.Printf("Bound to interface: %s (%s)", interfaceName, localAddr)
 		}
 	}
+	return dialer
+}
+
+func createClient(dialer *net.Dialer) *http.Client {
+	transport := &http.Transport{
+		DialContext:         dialer.DialContext,
+		MaxIdleConns:        100,
+		MaxIdleConnsPerHost: 10,
+		IdleConnTimeout:     90 * time.Second,
+	}
 
 	return &http.Client{
 		Transport: transport,
@@ -279,50 +946,10 @@ func createClient(interfaceName string) *http.Client {
 }
 
 func getInterfaceAddr(name string) *net.TCPAddr {
-	iface, err := net.InterfaceByName(name)
+	addr, err := netif.Addr(name, false)
 	if err != nil {
-		log.Printf("Interface %s not found: %v", name, err)
+		log.Printf("Not binding to an interface: %v", err)
 		return nil
 	}
-
-	addrs, err := iface.Addrs()
-	if err != nil {
-		log.Printf("Failed to get addresses for %s: %v", name, err)
-		return nil
-	}
-
-	for _, addr := range addrs {
-		if ipnet, ok := addr.(*net.IPNet); ok && ipnet.IP.To4() != nil {
-			return &net.TCPAddr{IP: ipnet.IP}
-		}
-	}
-
-	return nil
-}
-
-func loadEndpoints(filename string) ([]Endpoint, error) {
-	data, err := os.ReadFile(filename)
-	if err != nil {
-		return nil, err
-	}
-
-	var endpoints []Endpoint
-	if err := json.Unmarshal(data, &endpoints); err != nil {
-		return nil, err
-	}
-
-	// Set defaults
-	for i := range endpoints {
-		if endpoints[i].Interval == 0 {
-			endpoints[i].Interval = 5 * time.Second
-		}
-		if endpoints[i].Timeout == 0 {
-			endpoints[i].Timeout = 3 * time.Second
-		}
-		if endpoints[i].ExpectedStatus == 0 {
-			endpoints[i].ExpectedStatus = 200
-		}
-	}
-
-	return endpoints, nil
+	return &net.TCPAddr{IP: addr.IP}
 }
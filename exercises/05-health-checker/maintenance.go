@@ -0,0 +1,265 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maintenanceWindow is a time an endpoint is expected to be down: either
+// once, from start to end, or every time a cron schedule fires, for
+// duration. Failures in a window are recorded but not alerted.
+type maintenanceWindow struct {
+	Start    time.Time     `json:"start"`
+	End      time.Time     `json:"end"`
+	Cron     string        `json:"cron"` // minute hour day-of-month month day-of-week, local time
+	Duration time.Duration `json:"duration"`
+	Reason   string        `json:"reason"`
+
+	schedule *cronSchedule
+}
+
+// UnmarshalJSON decodes a window as strictly as an endpoint, duration
+// string and all
+func (w *maintenanceWindow) UnmarshalJSON(data []byte) error {
+	type plain maintenanceWindow
+	aux := struct {
+		*plain
+		Duration json.RawMessage `json:"duration"`
+	}{plain: (*plain)(w)}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&aux); err != nil {
+		return err
+	}
+	if aux.Duration != nil {
+		d, err := parseDuration(aux.Duration)
+		if err != nil {
+			return fmt.Errorf("duration: %v", err)
+		}
+		w.Duration = d
+	}
+	return nil
+}
+
+func (w *maintenanceWindow) validate() error {
+	switch {
+	case w.Cron != "":
+		if !w.Start.IsZero() || !w.End.IsZero() {
+			return errors.New("give either cron and duration or start and end")
+		}
+		if w.Duration <= 0 {
+			return errors.New("cron needs a duration")
+		}
+		schedule, err := parseCron(w.Cron)
+		if err != nil {
+			return fmt.Errorf("cron %q: %v", w.Cron, err)
+		}
+		w.schedule = schedule
+	case w.Start.IsZero() || w.End.IsZero():
+		return errors.New("give either cron and duration or start and end")
+	case !w.End.After(w.Start):
+		return errors.New("end must be after start")
+	}
+	return nil
+}
+
+// active reports whether t falls in the window: for a schedule, whether
+// it fired within duration before t
+func (w *maintenanceWindow) active(t time.Time) bool {
+	if w.schedule == nil {
+		return !t.Before(w.Start) && t.Before(w.End)
+	}
+	minute := t.Truncate(time.Minute)
+	for fired := minute; t.Sub(fired) < w.Duration; fired = fired.Add(-time.Minute) {
+		if w.schedule.matches(fired) {
+			return true
+		}
+	}
+	return false
+}
+
+// cronSchedule is a parsed five-field cron expression, each field a
+// bitmask of the values it allows
+type cronSchedule struct {
+	minute, hour, dom, month, dow uint64
+	domAny, dowAny                bool // "*", which changes how dom and dow combine
+}
+
+// cronFields are each field's range, in order
+var cronFields = []struct {
+	name     string
+	min, max int
+}{
+	{"minute", 0, 59},
+	{"hour", 0, 23},
+	{"day of month", 1, 31},
+	{"month", 1, 12},
+	{"day of week", 0, 7}, // 0 and 7 are both Sunday
+}
+
+// parseCron parses "minute hour day-of-month month day-of-week", each
+// field *, a value, a range a-b, a list of those, with an optional /step
+func parseCron(spec string) (*cronSchedule, error) {
+	fields := strings.Fields(spec)
+	if len(fields) != len(cronFields) {
+		return nil, fmt.Errorf("want %d fields, got %d", len(cronFields), len(fields))
+	}
+
+	masks := make([]uint64, len(fields))
+	for i, field := range fields {
+		f := cronFields[i]
+		for _, part := range strings.Split(field, ",") {
+			expr, stepStr, hasStep := strings.Cut(part, "/")
+			step := 1
+			if hasStep {
+				n, err := strconv.Atoi(stepStr)
+				if err != nil || n < 1 {
+					return nil, fmt.Errorf("%s: bad step %q", f.name, stepStr)
+				}
+				step = n
+			}
+
+			lo, hi := f.min, f.max
+			if expr != "*" {
+				a, b, isRange := strings.Cut(expr, "-")
+				var err error
+				if lo, err = strconv.Atoi(a); err != nil {
+					return nil, fmt.Errorf("%s: bad value %q", f.name, a)
+				}
+				hi = lo
+				if isRange {
+					if hi, err = strconv.Atoi(b); err != nil {
+						return nil, fmt.Errorf("%s: bad value %q", f.name, b)
+					}
+				} else if hasStep {
+					hi = f.max // "5/15" is 5, 20, 35, 50
+				}
+				if lo < f.min || hi > f.max || lo > hi {
+					return nil, fmt.Errorf("%s: %q is outside %d-%d", f.name, expr, f.min, f.max)
+				}
+			}
+			for v := lo; v <= hi; v += step {
+				masks[i] |= 1 << v
+			}
+		}
+	}
+
+	s := &cronSchedule{
+		minute: masks[0],
+		hour:   masks[1],
+		dom:    masks[2],
+		month:  masks[3],
+		dow:    masks[4],
+		domAny: fields[2] == "*",
+		dowAny: fields[4] == "*",
+	}
+	if s.dow&(1<<7) != 0 {
+		s.dow |= 1 // Sunday as 7
+	}
+	return s, nil
+}
+
+// matches reports whether the schedule fires in t's minute, in t's
+// location. As in cron, when both day fields are restricted either one
+// matching is enough.
+func (s *cronSchedule) matches(t time.Time) bool {
+	t = t.Local()
+	if s.minute&(1<<t.Minute()) == 0 || s.hour&(1<<t.Hour()) == 0 || s.month&(1<<int(t.Month())) == 0 {
+		return false
+	}
+	domOK := s.dom&(1<<t.Day()) != 0
+	dowOK := s.dow&(1<<int(t.Weekday())) != 0
+	if s.domAny || s.dowAny {
+		return domOK && dowOK
+	}
+	return domOK || dowOK
+}
+
+// silence mutes one endpoint until a time, set at runtime through the
+// admin API, e.g. for an unplanned fix
+type silence struct {
+	ID       int       `json:"id"`
+	Endpoint string    `json:"endpoint"`
+	Until    time.Time `json:"until"`
+	Reason   string    `json:"reason,omitempty"`
+}
+
+// mutedReason says why ep's failures shouldn't alert at t, or "" if
+// they should. Called with hc.mu held.
+func (hc *HealthChecker) mutedReason(ep *Endpoint, t time.Time) string {
+	for _, s := range hc.silences {
+		if s.Endpoint == ep.Name && t.Before(s.Until) {
+			if s.Reason != "" {
+				return "silenced: " + s.Reason
+			}
+			return "silenced until " + s.Until.Local().Format(time.TimeOnly)
+		}
+	}
+	for i := range ep.Maintenance {
+		if w := &ep.Maintenance[i]; w.active(t) {
+			if w.Reason != "" {
+				return "maintenance: " + w.Reason
+			}
+			return "maintenance"
+		}
+	}
+	return ""
+}
+
+// addSilence mutes endpoint for d
+func (hc *HealthChecker) addSilence(endpoint string, d time.Duration, reason string) (silence, error) {
+	hc.mu.Lock()
+	defer hc.mu.Unlock()
+
+	known := false
+	for _, ep := range hc.endpoints {
+		known = known || ep.Name == endpoint
+	}
+	if !known {
+		return silence{}, fmt.Errorf("no endpoint %q", endpoint)
+	}
+
+	hc.pruneSilences()
+	hc.nextSilence++
+	s := silence{ID: hc.nextSilence, Endpoint: endpoint, Until: time.Now().Add(d), Reason: reason}
+	hc.silences[s.ID] = s
+	return s, nil
+}
+
+// removeSilence ends a silence early, reporting whether it existed
+func (hc *HealthChecker) removeSilence(id int) bool {
+	hc.mu.Lock()
+	defer hc.mu.Unlock()
+	_, ok := hc.silences[id]
+	delete(hc.silences, id)
+	return ok
+}
+
+// listSilences returns the silences still in force, oldest first
+func (hc *HealthChecker) listSilences() []silence {
+	hc.mu.Lock()
+	defer hc.mu.Unlock()
+	hc.pruneSilences()
+	list := make([]silence, 0, len(hc.silences))
+	for _, s := range hc.silences {
+		list = append(list, s)
+	}
+	slices.SortFunc(list, func(a, b silence) int { return a.ID - b.ID })
+	return list
+}
+
+// pruneSilences drops expired silences. Called with hc.mu held.
+func (hc *HealthChecker) pruneSilences() {
+	now := time.Now()
+	for id, s := range hc.silences {
+		if !now.Before(s.Until) {
+			delete(hc.silences, id)
+		}
+	}
+}
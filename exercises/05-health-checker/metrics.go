@@ -0,0 +1,216 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Prometheus metrics, labelled by endpoint name. They are always updated
+// (it's cheap) but only exposed when -metrics is given.
+var (
+	endpointUp = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "healthcheck_up",
+		Help: "Whether the endpoint is up (1) or down (0), after failure and success thresholds.",
+	}, []string{"endpoint"})
+
+	endpointDegraded = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "healthcheck_degraded",
+		Help: "Whether the endpoint is up but slower than its warn_latency, or with some SRV targets down (1), or not (0).",
+	}, []string{"endpoint"})
+
+	checkLatency = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "healthcheck_latency_seconds",
+		Help:    "Time each check took, failed ones included.",
+		Buckets: prometheus.ExponentialBuckets(0.005, 2, 12), // 5ms .. ~10s
+	}, []string{"endpoint"})
+
+	phaseLatency = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "healthcheck_http_phase_seconds",
+		Help:    "Time HTTP checks spent in each phase: dns, connect, tls and ttfb. Reused connections only have ttfb.",
+		Buckets: prometheus.ExponentialBuckets(0.001, 2, 14), // 1ms .. ~8s
+	}, []string{"endpoint", "phase"})
+
+	httpConnections = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "healthcheck_http_connections_total",
+		Help: "HTTP checks by the connection they got: a kept-alive one reused, or a new one.",
+	}, []string{"endpoint", "connection"})
+
+	checksTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "healthcheck_checks_total",
+		Help: "Checks run.",
+	}, []string{"endpoint"})
+
+	retriesTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "healthcheck_retries_total",
+		Help: "Attempts retried within a check after a failure.",
+	}, []string{"endpoint"})
+
+	failuresTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "healthcheck_failures_total",
+		Help: "Checks that failed, retries exhausted.",
+	}, []string{"endpoint"})
+
+	transitionsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "healthcheck_state_transitions_total",
+		Help: "Times the endpoint went up or down, by the state it went to.",
+	}, []string{"endpoint", "to"})
+
+	endpointMuted = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "healthcheck_muted",
+		Help: "Whether the endpoint is in maintenance or silenced (1), so its failures aren't alerted on.",
+	}, []string{"endpoint"})
+	inflightChecks = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "healthcheck_inflight_checks",
+		Help: "Checks running right now, at most -max-concurrent.",
+	})
+	lastCheckTime = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "healthcheck_last_check_timestamp_seconds",
+		Help: "Unix time of the endpoint's most recent check.",
+	}, []string{"endpoint"})
+	srvTargets = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "healthcheck_srv_targets",
+		Help: "SRV targets of the endpoint's most recent check, by whether they were up or down.",
+	}, []string{"endpoint", "state"})
+	clusterMembers = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "healthcheck_cluster_members",
+		Help: "Instances sharing the endpoints with -cluster-etcd or -cluster-consul, this one included.",
+	})
+	sourceUp = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "healthcheck_source_up",
+		Help: "Whether the endpoint's most recent check passed from this one of its interfaces (1) or not (0).",
+	}, []string{"endpoint", "source"})
+	sourceLatency = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "healthcheck_source_latency_seconds",
+		Help: "How long the endpoint's most recent check took from this one of its interfaces.",
+	}, []string{"endpoint", "source"})
+)
+
+// observeCheck records one check. prev is the previous status, nil for
+// the first check, which isn't a transition.
+func observeCheck(status, prev *HealthStatus) {
+	name := status.Endpoint.Name
+
+	checksTotal.WithLabelValues(name).Inc()
+	lastCheckTime.WithLabelValues(name).Set(float64(status.LastCheck.Unix()))
+	if status.Latency > 0 {
+		checkLatency.WithLabelValues(name).Observe(status.Latency.Seconds())
+	}
+
+	if p := status.Phases; p != nil && p.TTFB > 0 {
+		connection := "new"
+		if p.Reused {
+			connection = "reused"
+		}
+		httpConnections.WithLabelValues(name, connection).Inc()
+		if !p.Reused {
+			phaseLatency.WithLabelValues(name, "dns").Observe(p.DNS.Seconds())
+			phaseLatency.WithLabelValues(name, "connect").Observe(p.Connect.Seconds())
+			if p.TLS > 0 {
+				phaseLatency.WithLabelValues(name, "tls").Observe(p.TLS.Seconds())
+			}
+		}
+		phaseLatency.WithLabelValues(name, "ttfb").Observe(p.TTFB.Seconds())
+	}
+
+	if status.Healthy {
+		endpointUp.WithLabelValues(name).Set(1)
+	} else {
+		endpointUp.WithLabelValues(name).Set(0)
+	}
+	if status.Degraded {
+		endpointDegraded.WithLabelValues(name).Set(1)
+	} else {
+		endpointDegraded.WithLabelValues(name).Set(0)
+	}
+	if status.Muted != "" {
+		endpointMuted.WithLabelValues(name).Set(1)
+	} else {
+		endpointMuted.WithLabelValues(name).Set(0)
+	}
+	if !status.LastOK {
+		failuresTotal.WithLabelValues(name).Inc()
+	}
+	if status.Endpoint.SRV != "" {
+		down := 0
+		for _, t := range status.Targets {
+			if t.Error != "" {
+				down++
+			}
+		}
+		srvTargets.WithLabelValues(name, "up").Set(float64(len(status.Targets) - down))
+		srvTargets.WithLabelValues(name, "down").Set(float64(down))
+	}
+	for _, s := range status.Sources {
+		up := 0.0
+		if s.Error == "" {
+			up = 1
+		}
+		sourceUp.WithLabelValues(name, s.Source).Set(up)
+		sourceLatency.WithLabelValues(name, s.Source).Set(s.Latency.Seconds())
+	}
+
+	if prev != nil && prev.Healthy != status.Healthy {
+		to := "down"
+		if status.Healthy {
+			to = "up"
+		}
+		transitionsTotal.WithLabelValues(name, to).Inc()
+	}
+}
+
+// forgetMetrics deletes a removed endpoint's series, so it stops being
+// exported rather than reporting its last state forever
+func forgetMetrics(name string) {
+	labels := prometheus.Labels{"endpoint": name}
+	endpointUp.DeletePartialMatch(labels)
+	endpointDegraded.DeletePartialMatch(labels)
+	endpointMuted.DeletePartialMatch(labels)
+	checkLatency.DeletePartialMatch(labels)
+	phaseLatency.DeletePartialMatch(labels)
+	httpConnections.DeletePartialMatch(labels)
+	checksTotal.DeletePartialMatch(labels)
+	retriesTotal.DeletePartialMatch(labels)
+	failuresTotal.DeletePartialMatch(labels)
+	transitionsTotal.DeletePartialMatch(labels)
+	lastCheckTime.DeletePartialMatch(labels)
+	srvTargets.DeletePartialMatch(labels)
+	sourceUp.DeletePartialMatch(labels)
+	sourceLatency.DeletePartialMatch(labels)
+}
+
+// serveMetrics exposes /metrics on addr until ctx is cancelled
+func serveMetrics(ctx context.Context, addr string) error {
+	mux := http.NewServeMux()
+	mux.Handle("GET /metrics", promhttp.Handler())
+
+	server := &http.Server{
+		Handler:           mux,
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(shutdownCtx)
+	}()
+
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("📈 Metrics on http://%s/metrics", ln.Addr())
+	if err := server.Serve(ln); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
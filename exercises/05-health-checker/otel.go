@@ -0,0 +1,341 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// How spans are batched for export
+const (
+	otlpBatchSize     = 100
+	otlpFlushInterval = 5 * time.Second
+	otlpTimeout       = 10 * time.Second
+)
+
+// otlpExporter sends a span per check, with a child span per HTTP phase,
+// and the checker's Prometheus metrics to an OpenTelemetry collector,
+// over OTLP/HTTP with the JSON encoding, which needs no SDK. Spans are
+// batched; metrics are sent every interval. A nil *otlpExporter sends
+// nothing.
+type otlpExporter struct {
+	endpoint string // collector base URL, e.g. http://localhost:4318
+	interval time.Duration
+	resource otlpResource
+	client   *http.Client
+	spans    chan otlpSpan
+	start    time.Time // of the cumulative metrics
+	done     chan struct{}
+	failing  bool // the last export failed; logged once, not per batch
+}
+
+func newOTLPExporter(endpoint string, interval time.Duration) *otlpExporter {
+	host, _ := os.Hostname()
+	e := &otlpExporter{
+		endpoint: strings.TrimSuffix(endpoint, "/"),
+		interval: interval,
+		resource: otlpResource{Attributes: []otlpAttr{
+			attr("service.name", "health-checker"),
+			attr("host.name", host),
+		}},
+		client: &http.Client{Timeout: otlpTimeout},
+		spans:  make(chan otlpSpan, 1024),
+		start:  time.Now(),
+		done:   make(chan struct{}),
+	}
+	go e.run()
+	return e
+}
+
+// run batches spans and sends metrics until the span channel is closed,
+// then flushes what's left
+func (e *otlpExporter) run() {
+	defer close(e.done)
+	flush := time.NewTicker(otlpFlushInterval)
+	defer flush.Stop()
+	metrics := time.NewTicker(e.interval)
+	defer metrics.Stop()
+
+	var batch []otlpSpan
+	send := func() {
+		if len(batch) > 0 {
+			e.export("/v1/traces", e.traces(batch))
+			batch = nil
+		}
+	}
+	for {
+		select {
+		case span, ok := <-e.spans:
+			if !ok {
+				send()
+				e.export("/v1/metrics", e.metrics())
+				return
+			}
+			if batch = append(batch, span); len(batch) >= otlpBatchSize {
+				send()
+			}
+		case <-flush.C:
+			send()
+		case <-metrics.C:
+			e.export("/v1/metrics", e.metrics())
+		}
+	}
+}
+
+// check records one check as a span, from start to now, with the last
+// attempt's HTTP phases as children
+func (e *otlpExporter) check(ep *Endpoint, start time.Time, res Result) {
+	if e == nil {
+		return
+	}
+	end := time.Now()
+
+	span := otlpSpan{
+		TraceID: randomHex(16),
+		SpanID:  randomHex(8),
+		Name:    "healthcheck " + ep.Type,
+		Kind:    otlpSpanKindClient,
+		Start:   nanos(start),
+		End:     nanos(end),
+		Attributes: []otlpAttr{
+			attr("healthcheck.endpoint", ep.Name),
+			attr("healthcheck.type", ep.Type),
+			attr("healthcheck.target", ep.target()),
+			intAttr("healthcheck.attempts", res.Attempts),
+		},
+	}
+	if res.Err != nil {
+		span.Status = otlpStatus{Code: otlpStatusError, Message: res.Err.Error()}
+	}
+	if p := res.Phases; p != nil && p.Proto != "" {
+		span.Attributes = append(span.Attributes,
+			attr("network.protocol.version", strings.TrimPrefix(p.Proto, "HTTP/")),
+			boolAttr("healthcheck.connection.reused", p.Reused))
+	}
+	spans := []otlpSpan{span}
+
+	if p := res.Phases; p != nil && p.TTFB > 0 {
+		child := func(name string, at time.Time, d time.Duration) {
+			if at.IsZero() {
+				return
+			}
+			spans = append(spans, otlpSpan{
+				TraceID:  span.TraceID,
+				SpanID:   randomHex(8),
+				ParentID: span.SpanID,
+				Name:     name,
+				Kind:     otlpSpanKindInternal,
+				Start:    nanos(at),
+				End:      nanos(at.Add(d)),
+			})
+		}
+		if !p.Reused {
+			child("dns", p.dnsAt, p.DNS)
+			child("connect", p.connectAt, p.Connect)
+			child("tls", p.tlsAt, p.TLS)
+		}
+		child("ttfb", p.wroteAt, p.TTFB)
+	}
+
+	for _, s := range spans {
+		select {
+		case e.spans <- s:
+		default:
+			// The collector can't keep up; checks mustn't wait for it
+		}
+	}
+}
+
+// close flushes queued spans and the final metrics
+func (e *otlpExporter) close() {
+	if e == nil {
+		return
+	}
+	close(e.spans)
+	<-e.done
+}
+
+// export POSTs one OTLP/JSON request, logging the first failure of a run
+// of them and the recovery
+func (e *otlpExporter) export(path string, body any) {
+	data, err := json.Marshal(body)
+	if err == nil {
+		var resp *http.Response
+		resp, err = e.client.Post(e.endpoint+path, "application/json", bytes.NewReader(data))
+		if err == nil {
+			resp.Body.Close()
+			if resp.StatusCode/100 != 2 {
+				err = fmt.Errorf("%s", resp.Status)
+			}
+		}
+	}
+
+	switch {
+	case err != nil && !e.failing:
+		log.Printf("⚠️  OTLP export to %s failed: %v", e.endpoint+path, err)
+	case err == nil && e.failing:
+		log.Printf("📡 OTLP export to %s working again", e.endpoint)
+	}
+	e.failing = err != nil
+}
+
+func (e *otlpExporter) traces(spans []otlpSpan) any {
+	return map[string]any{"resourceSpans": []any{map[string]any{
+		"resource": e.resource,
+		"scopeSpans": []any{map[string]any{
+			"scope": otlpScope,
+			"spans": spans,
+		}},
+	}}}
+}
+
+// metrics converts the checker's own Prometheus metrics (healthcheck_*)
+// to OTLP: gauges stay gauges, counters become cumulative monotonic
+// sums, and histograms keep their buckets
+func (e *otlpExporter) metrics() any {
+	families, err := prometheus.DefaultGatherer.Gather()
+	if err != nil {
+		log.Printf("⚠️  Gathering metrics for OTLP: %v", err)
+	}
+
+	now, start := nanos(time.Now()), nanos(e.start)
+	var metrics []map[string]any
+	for _, mf := range families {
+		if !strings.HasPrefix(mf.GetName(), "healthcheck_") {
+			continue
+		}
+		m := map[string]any{"name": mf.GetName(), "description": mf.GetHelp()}
+		var points []map[string]any
+		for _, metric := range mf.GetMetric() {
+			point := map[string]any{"attributes": labelAttrs(metric.GetLabel()), "timeUnixNano": now}
+			switch mf.GetType() {
+			case dto.MetricType_GAUGE:
+				point["asDouble"] = metric.GetGauge().GetValue()
+			case dto.MetricType_COUNTER:
+				point["startTimeUnixNano"] = start
+				point["asDouble"] = metric.GetCounter().GetValue()
+			case dto.MetricType_HISTOGRAM:
+				h := metric.GetHistogram()
+				point["startTimeUnixNano"] = start
+				point["count"] = strconv.FormatUint(h.GetSampleCount(), 10)
+				point["sum"] = h.GetSampleSum()
+
+				// Prometheus buckets are cumulative; OTLP's aren't, and
+				// end with an overflow bucket
+				var bounds []float64
+				var counts []string
+				var below uint64
+				for _, b := range h.GetBucket() {
+					bounds = append(bounds, b.GetUpperBound())
+					counts = append(counts, strconv.FormatUint(b.GetCumulativeCount()-below, 10))
+					below = b.GetCumulativeCount()
+				}
+				point["explicitBounds"] = bounds
+				point["bucketCounts"] = append(counts, strconv.FormatUint(h.GetSampleCount()-below, 10))
+			default:
+				continue
+			}
+			points = append(points, point)
+		}
+
+		switch mf.GetType() {
+		case dto.MetricType_GAUGE:
+			m["gauge"] = map[string]any{"dataPoints": points}
+		case dto.MetricType_COUNTER:
+			m["sum"] = map[string]any{"dataPoints": points, "aggregationTemporality": otlpCumulative, "isMonotonic": true}
+		case dto.MetricType_HISTOGRAM:
+			m["histogram"] = map[string]any{"dataPoints": points, "aggregationTemporality": otlpCumulative}
+		default:
+			continue
+		}
+		metrics = append(metrics, m)
+	}
+
+	return map[string]any{"resourceMetrics": []any{map[string]any{
+		"resource": e.resource,
+		"scopeMetrics": []any{map[string]any{
+			"scope":   otlpScope,
+			"metrics": metrics,
+		}},
+	}}}
+}
+
+// OTLP/JSON: the protobuf messages in their JSON mapping, with IDs in
+// hex and 64-bit integers as strings
+type (
+	otlpResource struct {
+		Attributes []otlpAttr `json:"attributes"`
+	}
+
+	otlpSpan struct {
+		TraceID    string     `json:"traceId"`
+		SpanID     string     `json:"spanId"`
+		ParentID   string     `json:"parentSpanId,omitempty"`
+		Name       string     `json:"name"`
+		Kind       int        `json:"kind"`
+		Start      string     `json:"startTimeUnixNano"`
+		End        string     `json:"endTimeUnixNano"`
+		Attributes []otlpAttr `json:"attributes,omitempty"`
+		Status     otlpStatus `json:"status"`
+	}
+
+	otlpStatus struct {
+		Code    int    `json:"code,omitempty"`
+		Message string `json:"message,omitempty"`
+	}
+
+	otlpAttr struct {
+		Key   string         `json:"key"`
+		Value map[string]any `json:"value"`
+	}
+)
+
+const (
+	otlpSpanKindInternal = 1
+	otlpSpanKindClient   = 3
+	otlpStatusError      = 2
+	otlpCumulative       = 2
+)
+
+var otlpScope = map[string]string{"name": "github.com/channyeintun/network-exercises/05-health-checker"}
+
+func attr(key, value string) otlpAttr {
+	return otlpAttr{Key: key, Value: map[string]any{"stringValue": value}}
+}
+
+func intAttr(key string, value int) otlpAttr {
+	return otlpAttr{Key: key, Value: map[string]any{"intValue": strconv.Itoa(value)}}
+}
+
+func boolAttr(key string, value bool) otlpAttr {
+	return otlpAttr{Key: key, Value: map[string]any{"boolValue": value}}
+}
+
+func labelAttrs(labels []*dto.LabelPair) []otlpAttr {
+	attrs := make([]otlpAttr, 0, len(labels))
+	for _, l := range labels {
+		attrs = append(attrs, attr(l.GetName(), l.GetValue()))
+	}
+	return attrs
+}
+
+func nanos(t time.Time) string {
+	return strconv.FormatInt(t.UnixNano(), 10)
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+
+	"golang.org/x/net/proxy"
+)
+
+// parseProxy validates an endpoint's proxy: http:// or https:// for an
+// HTTP proxy (CONNECT for anything but plain http checks), socks5:// or
+// socks5h:// for SOCKS5. Credentials go in the URL's userinfo.
+func parseProxy(raw string) (*url.URL, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, err
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5", "socks5h":
+	default:
+		return nil, fmt.Errorf("unsupported scheme %q (want http, https, socks5 or socks5h)", u.Scheme)
+	}
+	if u.Hostname() == "" {
+		return nil, fmt.Errorf("%q has no host", raw)
+	}
+	if u.Port() == "" {
+		port := map[string]string{"http": "80", "https": "443", "socks5": "1080", "socks5h": "1080"}[u.Scheme]
+		u.Host = net.JoinHostPort(u.Hostname(), port)
+	}
+	return u, nil
+}
+
+// dial connects to addr for a tcp or grpc check, through the endpoint's
+// proxy if it has one
+func (hc *HealthChecker) dial(ctx context.Context, ep *Endpoint, addr string) (net.Conn, error) {
+	switch u := ep.proxyURL; {
+	case u == nil:
+		return hc.dialFunc(ep)(ctx, "tcp", addr)
+	case u.Scheme == "socks5" || u.Scheme == "socks5h":
+		var auth *proxy.Auth
+		if u.User != nil {
+			password, _ := u.User.Password()
+			auth = &proxy.Auth{User: u.User.Username(), Password: password}
+		}
+		socks, err := proxy.SOCKS5("tcp", u.Host, auth, hc.dialerFor(ep))
+		if err != nil {
+			return nil, err
+		}
+		conn, err := socks.(proxy.ContextDialer).DialContext(ctx, "tcp", addr)
+		if err != nil {
+			return nil, fmt.Errorf("via proxy %s: %w", u.Redacted(), err)
+		}
+		return conn, nil
+	default:
+		conn, err := hc.connect(ctx, hc.dialerFor(ep), u, addr)
+		if err != nil {
+			return nil, fmt.Errorf("via proxy %s: %w", u.Redacted(), err)
+		}
+		return conn, nil
+	}
+}
+
+// connect opens a tunnel to addr through an HTTP proxy with CONNECT
+func (hc *HealthChecker) connect(ctx context.Context, dialer *net.Dialer, u *url.URL, addr string) (net.Conn, error) {
+	conn, err := dialer.DialContext(ctx, "tcp", u.Host)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme == "https" {
+		tlsConn := tls.Client(conn, &tls.Config{ServerName: u.Hostname()})
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			conn.Close()
+			return nil, err
+		}
+		conn = tlsConn
+	}
+
+	// Don't let a silent proxy outlast the check
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+
+	req := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Opaque: addr},
+		Host:   addr,
+		Header: make(http.Header),
+	}
+	if u.User != nil {
+		password, _ := u.User.Password()
+		credentials := base64.StdEncoding.EncodeToString([]byte(u.User.Username() + ":" + password))
+		req.Header.Set("Proxy-Authorization", "Basic "+credentials)
+	}
+	if err := req.Write(conn); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, req)
+	if !stop() {
+		return nil, ctx.Err() // conn is closed
+	}
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		conn.Close()
+		return nil, fmt.Errorf("CONNECT %s: %s", addr, resp.Status)
+	}
+	return &bufferedConn{Conn: conn, r: br}, nil
+}
+
+// bufferedConn is a tunnel whose first bytes may already be buffered
+// behind the proxy's response, as when a server speaks first
+type bufferedConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+func (c *bufferedConn) Read(p []byte) (int, error) { return c.r.Read(p) }
@@ -0,0 +1,246 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"maps"
+	"os"
+	"os/signal"
+	"reflect"
+	"slices"
+	"syscall"
+	"time"
+)
+
+// configPollInterval is how often -config is checked for changes. A
+// poll rather than inotify keeps this dependency-free and works across
+// editors that save by renaming a new file into place.
+const configPollInterval = 2 * time.Second
+
+// monitor is one endpoint's running check loop
+type monitor struct {
+	ep     *Endpoint
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// start runs ep's check loop until ctx is cancelled or the endpoint is
+// stopped by a reload
+func (hc *HealthChecker) start(ctx context.Context, ep *Endpoint) {
+	ep.client = hc.endpointClient(ep)
+	if ep.Type == "heartbeat" {
+		hc.expectHeartbeats(ep.Name)
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	m := &monitor{ep: ep, cancel: cancel, done: make(chan struct{})}
+	hc.monitors[ep.Name] = m
+
+	hc.wg.Add(1)
+	go func() {
+		defer hc.wg.Done()
+		defer close(m.done)
+		hc.monitorEndpoint(ctx, ep)
+	}()
+}
+
+// stop cancels an endpoint's check loop and waits for it to exit, so no
+// check lands after it's gone
+func (hc *HealthChecker) stop(name string) {
+	m := hc.monitors[name]
+	m.cancel()
+	<-m.done
+	if m.ep.client.Transport != hc.client.Transport {
+		m.ep.client.CloseIdleConnections()
+	}
+	delete(hc.monitors, name)
+}
+
+// watchConfig reloads path when it changes or on SIGHUP, until ctx is
+// cancelled
+func (hc *HealthChecker) watchConfig(ctx context.Context, path string) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	ticker := time.NewTicker(configPollInterval)
+	defer ticker.Stop()
+
+	last, _ := os.Stat(path)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			hc.event(slog.LevelInfo, "🔄 SIGHUP: reloading "+path, "reloading config", "path", path, "trigger", "SIGHUP")
+		case <-ticker.C:
+			fi, err := os.Stat(path)
+			if err != nil || last != nil && fi.ModTime().Equal(last.ModTime()) && fi.Size() == last.Size() {
+				continue // mid-save, or unchanged
+			}
+			last = fi
+			hc.event(slog.LevelInfo, "🔄 "+path+" changed: reloading", "reloading config", "path", path, "trigger", "changed")
+		}
+		hc.reload(ctx, path)
+	}
+}
+
+// reload applies a new config. A config that fails to load changes
+// nothing.
+func (hc *HealthChecker) reload(ctx context.Context, path string) {
+	endpoints, err := loadEndpoints(path)
+	if err != nil {
+		hc.event(slog.LevelError, fmt.Sprintf("❌ Reload failed, keeping the current config: %v", err), "reload failed", "path", path, "error", err)
+		return
+	}
+
+	total, added, updated, removed := hc.update(ctx, configSource, endpoints)
+	hc.event(slog.LevelInfo, fmt.Sprintf("🔄 Reloaded: %d endpoints (%d added, %d updated, %d removed)", total, added, updated, removed),
+		"config reloaded", "path", path, "endpoints", total, "added", added, "updated", updated, "removed", removed)
+}
+
+// configSource is the -config file's (or the defaults') name among the
+// sources of endpoints; discovery adds others
+const configSource = "config"
+
+// update replaces the endpoints from one source and applies the lot:
+// endpoints no source names any more are stopped and forgotten, new ones
+// are started, and changed ones are restarted with their history kept,
+// since the name says it's the same service. Unchanged endpoints carry
+// on untouched. Where two sources name the same endpoint the config
+// wins, then the source first in order. It returns how many endpoints
+// there are now and what changed.
+func (hc *HealthChecker) update(ctx context.Context, source string, endpoints []Endpoint) (total, added, updated, removed int) {
+	hc.updateMu.Lock()
+	defer hc.updateMu.Unlock()
+
+	hc.sources[source] = endpoints
+	return hc.apply(ctx)
+}
+
+// rebalance applies the endpoints again after the cluster's members
+// changed: endpoints another member has now are stopped and forgotten
+// here, and those this one has now are started. It returns how many
+// endpoints this instance checks, of how many.
+func (hc *HealthChecker) rebalance(ctx context.Context) (owned, total int) {
+	hc.updateMu.Lock()
+	defer hc.updateMu.Unlock()
+
+	total, _, _, _ = hc.apply(ctx)
+	return len(hc.monitors), total
+}
+
+// apply merges the sources' endpoints and brings the check loops in line
+// with them. In a cluster only the endpoints assigned to this instance
+// run here. Called with updateMu held.
+func (hc *HealthChecker) apply(ctx context.Context) (total, added, updated, removed int) {
+	names := slices.Sorted(maps.Keys(hc.sources))
+	if i := slices.Index(names, configSource); i > 0 {
+		names = slices.Concat([]string{configSource}, slices.Delete(names, i, i+1))
+	}
+	var all []Endpoint
+	from := make(map[string]string)
+	for _, src := range names {
+		for _, ep := range hc.sources[src] {
+			if other, dup := from[ep.Name]; dup {
+				hc.event(slog.LevelWarn, fmt.Sprintf("⚠️  %s names %s too; keeping %s's", src, ep.Name, other),
+					"duplicate endpoint", "endpoint", ep.Name, "source", src, "kept", other)
+				continue
+			}
+			from[ep.Name] = src
+			all = append(all, ep)
+		}
+	}
+
+	prev := make(map[string]*Endpoint, len(hc.endpoints))
+	for i := range hc.endpoints {
+		prev[hc.endpoints[i].Name] = &hc.endpoints[i]
+	}
+	owners := hc.cluster.assign(all)
+	mine := func(name string) bool { return owners == nil || owners[name] == hc.cluster.id }
+
+	for name := range prev {
+		if from[name] == "" {
+			if _, ok := hc.monitors[name]; ok {
+				hc.stop(name)
+			}
+			hc.forget(name)
+			hc.event(slog.LevelInfo, "➖ Stopped monitoring "+name, "endpoint removed", "endpoint", name)
+			removed++
+		}
+	}
+	for name := range hc.monitors {
+		if !mine(name) {
+			hc.stop(name)
+			hc.forget(name)
+			hc.event(slog.LevelInfo, fmt.Sprintf("👋 Handed %s over to %s", name, owners[name]), "endpoint handed over", "endpoint", name, "member", owners[name])
+		}
+	}
+
+	// Stopped before the endpoint list changes, started after, so the
+	// status page never shows an endpoint without its loop
+	var start []*Endpoint
+	for i := range all {
+		ep := &all[i]
+		old, known := prev[ep.Name]
+		switch {
+		case !known:
+			hc.event(slog.LevelInfo, fmt.Sprintf("➕ Monitoring %s (%s)", ep.Name, ep.target()), "endpoint added", "endpoint", ep.Name, "target", ep.target(), "source", from[ep.Name])
+			added++
+		case !sameEndpoint(old, ep):
+			hc.event(slog.LevelInfo, fmt.Sprintf("✏️  Updated %s (%s)", ep.Name, ep.target()), "endpoint updated", "endpoint", ep.Name, "target", ep.target(), "source", from[ep.Name])
+			updated++
+		}
+		if !mine(ep.Name) {
+			continue
+		}
+		m, running := hc.monitors[ep.Name]
+		switch {
+		case !running:
+			// Not the first split, where every instance starts its share
+			if was := hc.owners[ep.Name]; known && was != "" && was != hc.cluster.id {
+				hc.event(slog.LevelInfo, fmt.Sprintf("🤝 Took %s over from %s", ep.Name, was), "endpoint taken over", "endpoint", ep.Name, "member", was)
+			}
+			start = append(start, ep)
+		case !sameEndpoint(m.ep, ep):
+			hc.stop(ep.Name)
+			start = append(start, ep)
+		}
+	}
+
+	hc.mu.Lock()
+	hc.endpoints = all
+	hc.owners = owners
+	hc.mu.Unlock()
+
+	for _, ep := range start {
+		hc.start(ctx, ep)
+	}
+	return len(all), added, updated, removed
+}
+
+// forget drops everything kept about a removed endpoint, so it leaves
+// the status page, the metrics and the alert state
+func (hc *HealthChecker) forget(name string) {
+	hc.mu.Lock()
+	defer hc.mu.Unlock()
+	delete(hc.statuses, name)
+	delete(hc.windows, name)
+	delete(hc.heartbeats, name)
+	hc.alerts.forget(name)
+	forgetMetrics(name)
+}
+
+// sameEndpoint reports whether two loaded endpoints check the same thing
+// the same way. What's derived from the config (compiled body_regexp and
+// json_path, the parsed proxy and TLS config, the client built on them)
+// is left out.
+func sameEndpoint(a, b *Endpoint) bool {
+	x, y := *a, *b
+	x.bodyRegexp, y.bodyRegexp = nil, nil
+	x.jsonPath, y.jsonPath = nil, nil
+	x.proxyURL, y.proxyURL = nil, nil
+	x.tlsConfig, y.tlsConfig = nil, nil
+	x.client, y.client = nil, nil
+	return reflect.DeepEqual(x, y)
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"slices"
+	"strconv"
+)
+
+// validateResolution checks how an endpoint finds its host's address:
+// the resolver to ask, the IP version to connect over, or an IP to
+// connect to without asking at all
+func (ep *Endpoint) validateResolution() error {
+	if ep.Resolver == "" && ep.IPVersion == 0 && ep.IP == "" {
+		return nil
+	}
+	switch {
+	case slices.Contains([]string{"heartbeat", "composite"}, ep.Type):
+		return fmt.Errorf("%s checks don't connect anywhere; drop resolver, ip_version and ip", ep.Type)
+	case ep.Type == "dns" && (ep.IPVersion != 0 || ep.IP != ""):
+		return errors.New("dns checks ask their resolver directly; ip_version and ip are for other types")
+	case ep.Type == "icmp" && ep.IP != "":
+		return errors.New("icmp checks ping their address; give the IP as that")
+	case ep.Proxy != "":
+		return errors.New("the proxy resolves the host itself; resolver, ip_version and ip can't be used with one")
+	}
+
+	if ep.Resolver != "" {
+		if _, _, err := net.SplitHostPort(ep.Resolver); err != nil {
+			ep.Resolver = net.JoinHostPort(ep.Resolver, "53")
+		}
+	}
+	if ep.IPVersion != 0 && ep.IPVersion != 4 && ep.IPVersion != 6 {
+		return fmt.Errorf("ip_version must be 4 or 6, got %d", ep.IPVersion)
+	}
+	if ep.IP == "" {
+		return nil
+	}
+
+	ip := net.ParseIP(ep.IP)
+	switch {
+	case ip == nil:
+		return fmt.Errorf("ip: %q isn't an IP address", ep.IP)
+	case ep.SRV != "":
+		return errors.New("ip pins one address and srv finds several; use one or the other")
+	case ep.Resolver != "":
+		return errors.New("with ip there's nothing to resolve; drop resolver")
+	case ep.IPVersion == 4 && ip.To4() == nil, ep.IPVersion == 6 && ip.To4() != nil:
+		return fmt.Errorf("ip %s isn't IPv%d", ep.IP, ep.IPVersion)
+	}
+	return nil
+}
+
+// dialFunc returns how ep's checks connect: with its source's dialer or
+// the shared one, resolving the host with the endpoint's resolver, over
+// its IP version, or straight to its pinned IP. Only the connection
+// changes, so an https check still sends the url's host as Host and in
+// SNI, and checks the certificate against it.
+func (hc *HealthChecker) dialFunc(ep *Endpoint) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	dialer := hc.dialerFor(ep)
+	if ep.Resolver != "" {
+		withResolver := *dialer
+		withResolver.Resolver = hc.resolverFor(ep)
+		dialer = &withResolver
+	}
+	if ep.IPVersion == 0 && ep.IP == "" {
+		return dialer.DialContext
+	}
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		if ep.IP != "" {
+			_, port, err := net.SplitHostPort(addr)
+			if err != nil {
+				return nil, err
+			}
+			addr = net.JoinHostPort(ep.IP, port)
+		}
+		if ep.IPVersion != 0 {
+			network += strconv.Itoa(ep.IPVersion) // tcp4 or tcp6
+		}
+		return dialer.DialContext(ctx, network, addr)
+	}
+}
+
+// resolverFor returns the resolver for ep's host names: one that asks
+// the endpoint's resolver, from the same address its checks connect
+// from, or the system's. Both read /etc/hosts first.
+func (hc *HealthChecker) resolverFor(ep *Endpoint) *net.Resolver {
+	if ep.Resolver == "" {
+		return net.DefaultResolver
+	}
+	dialer := hc.dialerFor(ep)
+	return &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			return forNetwork(dialer, network).DialContext(ctx, network, ep.Resolver)
+		},
+	}
+}
+
+// ipNetwork is the network to look up an endpoint's host in: "ip", or
+// "ip4" or "ip6" for its IP version
+func (ep *Endpoint) ipNetwork() string {
+	if ep.IPVersion == 0 {
+		return "ip"
+	}
+	return "ip" + strconv.Itoa(ep.IPVersion)
+}
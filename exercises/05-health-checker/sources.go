@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/channyeintun/network-exercises/pkg/netif"
+)
+
+// sourceResult is how an endpoint's check went from one of its
+// interfaces
+type sourceResult struct {
+	Source  string // as configured: an interface name or a local IP
+	Address string // the local IP checked from; "" if there was none
+	Latency time.Duration
+	Error   string
+}
+
+// checkSources checks the endpoint from each of its interfaces at once,
+// each as the endpoint bound to the interface's address, so the paths
+// can be compared side by side. Like an SRV check it fails only if every
+// source does; while some do, Partial names them. Its latency is the
+// slowest passing source's, so warn_latency holds for every path.
+func (hc *HealthChecker) checkSources(ctx context.Context, ep *Endpoint) Result {
+	sources := make([]sourceResult, len(ep.Interfaces))
+	var wg sync.WaitGroup
+	for i, source := range ep.Interfaces {
+		sources[i].Source = source
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ip, err := sourceIP(source)
+			if err != nil {
+				sources[i].Error = err.Error()
+				return
+			}
+			sources[i].Address = ip.String()
+			start := time.Now()
+			res := hc.check(ctx, hc.fromSource(ep, ip))
+			sources[i].Latency = res.Latency
+			if sources[i].Latency == 0 {
+				sources[i].Latency = time.Since(start)
+			}
+			if res.Err != nil {
+				sources[i].Error = res.Err.Error()
+			}
+		}()
+	}
+	wg.Wait()
+
+	res := Result{Sources: sources}
+	var down []string
+	for _, s := range sources {
+		if s.Error != "" {
+			down = append(down, s.Source+" ("+s.Error+")")
+		} else {
+			res.Latency = max(res.Latency, s.Latency)
+		}
+	}
+	switch {
+	case len(down) == len(sources):
+		res.Err = fmt.Errorf("failing from all %d interfaces: %s", len(sources), strings.Join(down, ", "))
+	case len(down) > 0:
+		res.Partial = fmt.Sprintf("failing from %d of %d interfaces: %s", len(down), len(sources), strings.Join(down, ", "))
+	}
+	return res
+}
+
+// sourceIP is the address to check from for an interfaces entry: the
+// entry itself if it's an IP, or else the named interface's IPv4
+// address, looked up each check so a VPN that comes and goes is seen to
+func sourceIP(source string) (net.IP, error) {
+	if ip := net.ParseIP(source); ip != nil {
+		return ip, nil
+	}
+	addr, err := netif.Addr(source, false)
+	if err != nil {
+		return nil, err
+	}
+	return addr.IP, nil
+}
+
+// fromSource returns a copy of ep that connects from ip. An http check
+// gets a client of its own that opens a fresh connection each time, so
+// every check is routed anew rather than reusing one from before a
+// route changed.
+func (hc *HealthChecker) fromSource(ep *Endpoint, ip net.IP) *Endpoint {
+	source := *ep
+	source.Interfaces = nil
+	dialer := *hc.dialer
+	dialer.LocalAddr = &net.TCPAddr{IP: ip}
+	source.dialer = &dialer
+	if ep.client != nil {
+		client := *ep.client
+		if ep.Protocol == "h3" {
+			// h3 reuses its QUIC connection, which stays bound to the
+			// source's socket; it isn't rerouted until it idles out
+			client.Transport = hc.h3Transport(&source)
+		} else {
+			transport := ep.client.Transport.(*http.Transport).Clone()
+			transport.DialContext = hc.dialFunc(&source)
+			transport.DisableKeepAlives = true
+			client.Transport = transport
+		}
+		source.client = &client
+	}
+	return &source
+}
+
+// dialerFor returns the dialer ep's checks connect with: its source's,
+// if it's being checked from one of its interfaces, or the shared one
+func (hc *HealthChecker) dialerFor(ep *Endpoint) *net.Dialer {
+	if ep.dialer != nil {
+		return ep.dialer
+	}
+	return hc.dialer
+}
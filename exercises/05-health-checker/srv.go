@@ -0,0 +1,103 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"maps"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// srvTarget is one target of an SRV lookup and how its check went
+type srvTarget struct {
+	Address string // host:port
+	Latency time.Duration
+	Error   string
+}
+
+// checkSRV looks up the endpoint's SRV name and checks every target it
+// returns at once, each with its type's Checker as the endpoint with the
+// target in place of its address. It fails only if every target does;
+// while some do, Partial names them.
+func (hc *HealthChecker) checkSRV(ctx context.Context, ep *Endpoint) Result {
+	_, records, err := hc.resolverFor(ep).LookupSRV(ctx, "", "", ep.SRV)
+	if err != nil {
+		return Result{Err: fmt.Errorf("SRV lookup: %v", err)}
+	}
+	// A lone "." target is RFC 2782's way of saying there's no such
+	// service here
+	if len(records) == 0 || len(records) == 1 && records[0].Target == "." {
+		return Result{Err: fmt.Errorf("no SRV targets for %s", ep.SRV)}
+	}
+
+	targets := make([]srvTarget, len(records))
+	var wg sync.WaitGroup
+	for i, rec := range records {
+		targets[i].Address = net.JoinHostPort(strings.TrimSuffix(rec.Target, "."), strconv.Itoa(int(rec.Port)))
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			start := time.Now()
+			if err := hc.checkTarget(ctx, ep, targets[i].Address); err != nil {
+				targets[i].Error = err.Error()
+			}
+			targets[i].Latency = time.Since(start)
+		}()
+	}
+	wg.Wait()
+	// Lookups shuffle targets by weight; a stable order reads better
+	slices.SortFunc(targets, func(a, b srvTarget) int { return cmp.Compare(a.Address, b.Address) })
+
+	var down []string
+	for _, t := range targets {
+		if t.Error != "" {
+			down = append(down, t.Address+" ("+t.Error+")")
+		}
+	}
+	res := Result{Targets: targets}
+	switch {
+	case len(down) == len(targets):
+		res.Err = fmt.Errorf("all %d targets down: %s", len(targets), strings.Join(down, ", "))
+	case len(down) > 0:
+		res.Partial = fmt.Sprintf("%d of %d targets down: %s", len(down), len(targets), strings.Join(down, ", "))
+	}
+	return res
+}
+
+// checkTarget checks one SRV target: at its host and port for tcp and
+// grpc, and for http, at the url with the target's host and port, still
+// asking for the url's host
+func (hc *HealthChecker) checkTarget(ctx context.Context, ep *Endpoint, addr string) error {
+	target := *ep
+	target.SRV = ""
+	if ep.Type != "http" {
+		target.Address = addr
+		return hc.checkers[ep.Type].Check(ctx, &target).Err
+	}
+
+	u, err := url.Parse(ep.URL)
+	if err != nil {
+		return err
+	}
+	if !slices.ContainsFunc(slices.Collect(maps.Keys(ep.Headers)), isHostHeader) {
+		target.Headers = maps.Clone(ep.Headers)
+		if target.Headers == nil {
+			target.Headers = make(map[string]string)
+		}
+		target.Headers["Host"] = u.Host
+	}
+	u.Host = addr
+	target.URL = u.String()
+	return hc.checkers["http"].Check(ctx, &target).Err
+}
+
+func isHostHeader(name string) bool {
+	return http.CanonicalHeaderKey(name) == "Host"
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"time"
+)
+
+// statsWindow is how far back the rolling statistics look
+const statsWindow = time.Hour
+
+// checkSample is one check as the rolling statistics remember it
+type checkSample struct {
+	at      time.Time
+	latency time.Duration
+	ok      bool
+	err     string // why it failed, for the endpoint's history
+}
+
+// checkWindow holds an endpoint's checks from the last statsWindow
+type checkWindow struct {
+	samples []checkSample // oldest first
+}
+
+func (w *checkWindow) add(s checkSample) {
+	cutoff := s.at.Add(-statsWindow)
+	drop := 0
+	for drop < len(w.samples) && w.samples[drop].at.Before(cutoff) {
+		drop++
+	}
+	w.samples = append(w.samples[drop:], s)
+}
+
+// windowStats summarises a window: how many checks passed and the
+// latency percentiles of those that did, since a timeout's latency says
+// more about the timeout than the endpoint
+type windowStats struct {
+	Checks      int     `json:"checks"`
+	SuccessRate float64 `json:"success_rate"` // 0..1
+	P50MS       float64 `json:"p50_ms"`
+	P95MS       float64 `json:"p95_ms"`
+	P99MS       float64 `json:"p99_ms"`
+}
+
+func (w *checkWindow) stats() windowStats {
+	st := windowStats{Checks: len(w.samples)}
+	var latencies []time.Duration
+	for _, s := range w.samples {
+		if s.ok {
+			latencies = append(latencies, s.latency)
+		}
+	}
+	if st.Checks > 0 {
+		st.SuccessRate = float64(len(latencies)) / float64(st.Checks)
+	}
+	if len(latencies) > 0 {
+		slices.Sort(latencies)
+		st.P50MS = percentileMS(latencies, 0.50)
+		st.P95MS = percentileMS(latencies, 0.95)
+		st.P99MS = percentileMS(latencies, 0.99)
+	}
+	return st
+}
+
+// percentileMS is the nearest-rank percentile p of sorted, in ms
+func percentileMS(sorted []time.Duration, p float64) float64 {
+	i := int(math.Ceil(p*float64(len(sorted)))) - 1
+	return float64(sorted[max(i, 0)].Microseconds()) / 1000
+}
+
+// String is the summary for the console. The window is statsWindow.
+func (st windowStats) String() string {
+	if st.SuccessRate == 0 {
+		return fmt.Sprintf("0%% ok of %d in 1h", st.Checks)
+	}
+	return fmt.Sprintf("p50/p95/p99 %.0f/%.0f/%.0fms, %.1f%% ok of %d in 1h",
+		st.P50MS, st.P95MS, st.P99MS, st.SuccessRate*100, st.Checks)
+}
@@ -0,0 +1,282 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	_ "embed"
+	"fmt"
+	"html"
+	"html/template"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+//go:embed static/statuspage.html
+var statusPageHTML string
+
+var statusPageTemplate = template.Must(template.New("status").Parse(statusPageHTML))
+
+// statusPageDays is how many daily uptime bars the page shows, as many
+// as -db keeps checks for
+var statusPageDays = int(reportWindows[len(reportWindows)-1].d / (24 * time.Hour))
+
+// statusPage is what the static status page shows. Targets and error
+// messages are left out: the page is meant to be published.
+type statusPage struct {
+	Title        string
+	Overall      string
+	OverallClass string
+	Endpoints    []statusPageEndpoint
+	Incidents    []statusPageIncident
+	Days         int
+	Generated    time.Time
+}
+
+type statusPageEndpoint struct {
+	Name   string
+	State  string
+	Uptime string // over Days
+	Bars   []uptimeBar
+}
+
+// uptimeBar is one day's bar: up, minor (some downtime), major (over 1%
+// downtime) or none (no checks)
+type uptimeBar struct {
+	Class string
+	Title string
+}
+
+type statusPageIncident struct {
+	Endpoint string
+	outage
+}
+
+// publishStatus writes a status page and badges to dir every interval,
+// until ctx is cancelled
+func (hc *HealthChecker) publishStatus(ctx context.Context, dir, title string, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	failing := false
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		err := hc.writeStatusPage(dir, title, time.Now())
+		switch {
+		case err != nil && !failing:
+			log.Printf("❌ -status-dir: %v", err)
+		case err == nil && failing:
+			log.Printf("📄 -status-dir: writing %s again", dir)
+		}
+		failing = err != nil
+	}
+}
+
+// writeStatusPage writes dir/index.html, the status page, and for each
+// endpoint dir/badges/NAME.svg, its state, and dir/badges/NAME-uptime.svg,
+// its uptime over the page's days. Each file is replaced whole, so a sync
+// to object storage mid-write never uploads half a page.
+func (hc *HealthChecker) writeStatusPage(dir, title string, now time.Time) error {
+	page := statusPage{Title: title, Days: statusPageDays, Generated: now}
+
+	hc.mu.RLock()
+	var names []string
+	states := map[string]string{}
+	for i := range hc.endpoints {
+		ep := &hc.endpoints[i]
+		names = append(names, ep.Name)
+		states[ep.Name] = "pending"
+		if status, ok := hc.statuses[ep.Name]; ok {
+			states[ep.Name] = stateName(status)
+			if !status.Healthy && status.Muted != "" {
+				states[ep.Name] = "maintenance"
+			}
+		}
+	}
+	hc.mu.RUnlock()
+
+	reports, err := hc.store.Report(names, now)
+	if err != nil {
+		return err
+	}
+	badges := filepath.Join(dir, "badges")
+	if err := os.MkdirAll(badges, 0o755); err != nil {
+		return err
+	}
+
+	counts := map[string]int{}
+	for i, r := range reports {
+		name, state := names[i], states[names[i]]
+		counts[state]++
+
+		days, err := hc.store.dailyUptime(name, statusPageDays, now)
+		if err != nil {
+			return err
+		}
+		e := statusPageEndpoint{Name: name, State: state, Uptime: "-"}
+		for _, d := range days {
+			bar := uptimeBar{Class: "none", Title: d.Day.Format(time.DateOnly) + ": no data"}
+			if d.Checks > 0 {
+				bar.Title = fmt.Sprintf("%s: %.2f%% up", d.Day.Format(time.DateOnly), d.UptimePct)
+				switch {
+				case d.UptimePct == 100:
+					bar.Class = "up"
+				case d.UptimePct >= 99:
+					bar.Class = "minor"
+				default:
+					bar.Class = "major"
+				}
+			}
+			e.Bars = append(e.Bars, bar)
+		}
+		uptime := r.Uptime[len(r.Uptime)-1]
+		if uptime.Checks > 0 {
+			e.Uptime = formatUptime(uptime.UptimePct)
+		}
+		page.Endpoints = append(page.Endpoints, e)
+
+		for _, o := range r.Outages {
+			page.Incidents = append(page.Incidents, statusPageIncident{Endpoint: name, outage: o})
+		}
+
+		slug := badgeSlug(name)
+		if err := writeFileAtomic(filepath.Join(badges, slug+".svg"), stateBadge(name, state)); err != nil {
+			return err
+		}
+		if err := writeFileAtomic(filepath.Join(badges, slug+"-uptime.svg"), uptimeBadge(uptime)); err != nil {
+			return err
+		}
+	}
+	slices.SortFunc(page.Incidents, func(a, b statusPageIncident) int { return b.Start.Compare(a.Start) })
+
+	switch {
+	case counts["down"] > 0 && counts["down"] == len(names):
+		page.Overall, page.OverallClass = "Major outage", "down"
+	case counts["down"] > 0:
+		page.Overall, page.OverallClass = "Partial outage", "down"
+	case counts["degraded"] > 0:
+		page.Overall, page.OverallClass = "Degraded performance", "degraded"
+	case counts["maintenance"] > 0:
+		page.Overall, page.OverallClass = "Under maintenance", "maintenance"
+	default:
+		page.Overall, page.OverallClass = "All systems operational", "up"
+	}
+
+	var b bytes.Buffer
+	if err := statusPageTemplate.Execute(&b, page); err != nil {
+		return err
+	}
+	return writeFileAtomic(filepath.Join(dir, "index.html"), b.Bytes())
+}
+
+// Badge colors, as shields.io uses them
+const (
+	badgeGreen       = "#4c1"
+	badgeYellowGreen = "#a4a61d"
+	badgeYellow      = "#dfb317"
+	badgeRed         = "#e05d44"
+	badgeGrey        = "#9f9f9f"
+)
+
+func stateBadge(name, state string) []byte {
+	color := map[string]string{
+		"up":          badgeGreen,
+		"degraded":    badgeYellow,
+		"maintenance": badgeYellow,
+		"down":        badgeRed,
+	}[state]
+	if color == "" {
+		color = badgeGrey
+	}
+	return badge(name, state, color)
+}
+
+func uptimeBadge(u uptimeWindow) []byte {
+	if u.Checks == 0 {
+		return badge("uptime "+u.Window, "no data", badgeGrey)
+	}
+	color := badgeRed
+	switch {
+	case u.UptimePct >= 99.9:
+		color = badgeGreen
+	case u.UptimePct >= 99:
+		color = badgeYellowGreen
+	case u.UptimePct >= 95:
+		color = badgeYellow
+	}
+	return badge("uptime "+u.Window, formatUptime(u.UptimePct), color)
+}
+
+// badge draws a flat, shields.io-style SVG badge. Text widths are
+// estimated, as there's no font to measure with.
+func badge(label, value, color string) []byte {
+	width := func(s string) int { return 7*utf8.RuneCountInString(s) + 10 }
+	lw, vw := width(label), width(value)
+	label, value = html.EscapeString(label), html.EscapeString(value)
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="20" role="img" aria-label="%s: %s">`, lw+vw, label, value)
+	fmt.Fprintf(&b, `<title>%s: %s</title>`, label, value)
+	b.WriteString(`<linearGradient id="s" x2="0" y2="100%"><stop offset="0" stop-color="#bbb" stop-opacity=".1"/><stop offset="1" stop-opacity=".1"/></linearGradient>`)
+	fmt.Fprintf(&b, `<clipPath id="r"><rect width="%d" height="20" rx="3" fill="#fff"/></clipPath>`, lw+vw)
+	fmt.Fprintf(&b, `<g clip-path="url(#r)"><rect width="%d" height="20" fill="#555"/><rect x="%d" width="%d" height="20" fill="%s"/><rect width="%d" height="20" fill="url(#s)"/></g>`,
+		lw, lw, vw, color, lw+vw)
+	b.WriteString(`<g fill="#fff" text-anchor="middle" font-family="Verdana,Geneva,DejaVu Sans,sans-serif" font-size="11">`)
+	fmt.Fprintf(&b, `<text x="%d" y="14">%s</text><text x="%d" y="14">%s</text></g></svg>`, lw/2, label, lw+vw/2, value)
+	b.WriteString("\n")
+	return b.Bytes()
+}
+
+// formatUptime shows as many decimals as it takes to tell 99.95% from
+// 100%, without claiming more precision than that
+func formatUptime(pct float64) string {
+	if pct >= 99.99 && pct < 100 {
+		return "99.99%"
+	}
+	s := fmt.Sprintf("%.2f", pct)
+	s = strings.TrimRight(strings.TrimRight(s, "0"), ".")
+	return s + "%"
+}
+
+// badgeSlug makes an endpoint name safe as a file name and in a URL
+func badgeSlug(name string) string {
+	slug := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9', r == '-', r == '_':
+			return r
+		case r >= 'A' && r <= 'Z':
+			return r - 'A' + 'a'
+		}
+		return '-'
+	}, name)
+	return strings.Trim(slug, "-")
+}
+
+// writeFileAtomic replaces path with data through a temporary file and a
+// rename, so readers see the old file or the new one, never part of it
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(0o644); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,360 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"time"
+
+	_ "modernc.org/sqlite" // pure-Go SQLite driver, no cgo required
+)
+
+// Times are stored as Unix milliseconds so windows are cheap range scans
+const schema = `
+CREATE TABLE IF NOT EXISTS checks (
+	endpoint    TEXT    NOT NULL,
+	checked_at  INTEGER NOT NULL,
+	healthy     INTEGER NOT NULL, -- the endpoint's state after thresholds
+	ok          INTEGER NOT NULL, -- whether this check itself passed
+	latency_us  INTEGER NOT NULL,
+	error       TEXT    NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_checks_endpoint ON checks(endpoint, checked_at);
+
+CREATE TABLE IF NOT EXISTS outages (
+	endpoint    TEXT    NOT NULL,
+	started_at  INTEGER NOT NULL,
+	ended_at    INTEGER,          -- NULL while it lasts
+	error       TEXT    NOT NULL  -- the error that took the endpoint down
+);
+
+CREATE INDEX IF NOT EXISTS idx_outages_endpoint ON outages(endpoint, started_at);
+`
+
+// Uptime is reported over these windows. Checks older than the longest
+// are pruned; outages are kept.
+var reportWindows = []struct {
+	name string
+	d    time.Duration
+}{
+	{"24h", 24 * time.Hour},
+	{"7d", 7 * 24 * time.Hour},
+	{"30d", 30 * 24 * time.Hour},
+}
+
+const pruneInterval = time.Hour
+
+// Store persists every check in a SQLite database (-db), so uptime can be
+// reported over days and survives restarts of the checker. Writes go
+// through a queue so a slow disk holds up history, not checks. A nil
+// *Store stores nothing.
+type Store struct {
+	db     *sql.DB
+	writes chan *HealthStatus
+	done   chan struct{}
+}
+
+func openStore(path string) (*Store, error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, err
+	}
+
+	// The writer and the status API share the database; one connection
+	// keeps SQLite from returning "database is locked" between them
+	db.SetMaxOpenConns(1)
+
+	if _, err := db.Exec(schema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("create schema: %w", err)
+	}
+
+	s := &Store{
+		db:     db,
+		writes: make(chan *HealthStatus, 1024),
+		done:   make(chan struct{}),
+	}
+	go s.writer()
+	return s, nil
+}
+
+// Close writes what is queued and closes the database
+func (s *Store) Close() error {
+	if s == nil {
+		return nil
+	}
+	close(s.writes)
+	<-s.done
+	return s.db.Close()
+}
+
+// record queues a check for writing. Called with the checker's lock
+// held, in check order.
+func (s *Store) record(status *HealthStatus) {
+	if s == nil {
+		return
+	}
+	s.writes <- status
+}
+
+func (s *Store) writer() {
+	defer close(s.done)
+	s.prune()
+	lastPrune := time.Now()
+	for status := range s.writes {
+		if err := s.write(status); err != nil {
+			log.Printf("❌ -db: recording %s: %v", status.Endpoint.Name, err)
+		}
+		if time.Since(lastPrune) >= pruneInterval {
+			s.prune()
+			lastPrune = time.Now()
+		}
+	}
+}
+
+// write stores one check, opening an outage when the endpoint goes down
+// and closing it when the endpoint comes back. An outage left open by
+// the last run carries on if the endpoint is still down.
+func (s *Store) write(status *HealthStatus) error {
+	at := status.LastCheck.UnixMilli()
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(
+		`INSERT INTO checks (endpoint, checked_at, healthy, ok, latency_us, error) VALUES (?, ?, ?, ?, ?, ?)`,
+		status.Endpoint.Name, at, status.Healthy, status.LastOK, status.Latency.Microseconds(), status.Error,
+	)
+	if err != nil {
+		return fmt.Errorf("insert check: %w", err)
+	}
+
+	if status.Healthy {
+		_, err = tx.Exec(`UPDATE outages SET ended_at = ? WHERE endpoint = ? AND ended_at IS NULL`, at, status.Endpoint.Name)
+	} else {
+		_, err = tx.Exec(
+			`INSERT INTO outages (endpoint, started_at, error)
+			 SELECT ?, ?, ? WHERE NOT EXISTS (SELECT 1 FROM outages WHERE endpoint = ? AND ended_at IS NULL)`,
+			status.Endpoint.Name, at, status.Error, status.Endpoint.Name,
+		)
+	}
+	if err != nil {
+		return fmt.Errorf("update outages: %w", err)
+	}
+	return tx.Commit()
+}
+
+func (s *Store) prune() {
+	cutoff := time.Now().Add(-reportWindows[len(reportWindows)-1].d).UnixMilli()
+	if _, err := s.db.Exec(`DELETE FROM checks WHERE checked_at < ?`, cutoff); err != nil {
+		log.Printf("❌ -db: pruning old checks: %v", err)
+	}
+}
+
+// uptimeWindow is an endpoint's uptime over one report window
+type uptimeWindow struct {
+	Window    string  `json:"window"`
+	Checks    int     `json:"checks"`
+	UptimePct float64 `json:"uptime_pct"` // share of checks that found it up
+}
+
+// outage is one stretch of an endpoint being down
+type outage struct {
+	Start    time.Time  `json:"start"`
+	End      *time.Time `json:"end,omitempty"` // nil while it lasts
+	Duration string     `json:"duration"`
+	Error    string     `json:"error"`
+}
+
+// uptimeReport is an endpoint's uptime per window and its outages in
+// the longest one
+type uptimeReport struct {
+	Endpoint string         `json:"endpoint"`
+	Uptime   []uptimeWindow `json:"uptime"`
+	Outages  []outage       `json:"outages"`
+}
+
+// Endpoints returns every endpoint with stored checks
+func (s *Store) Endpoints() ([]string, error) {
+	rows, err := s.db.Query(`SELECT DISTINCT endpoint FROM checks ORDER BY endpoint`)
+	if err != nil {
+		return nil, fmt.Errorf("list endpoints: %w", err)
+	}
+	defer rows.Close()
+
+	var names []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+	}
+	return names, rows.Err()
+}
+
+// Report computes uptime and outages for the named endpoints as of now
+func (s *Store) Report(names []string, now time.Time) ([]uptimeReport, error) {
+	reports := make([]uptimeReport, 0, len(names))
+	for _, name := range names {
+		r := uptimeReport{Endpoint: name, Uptime: []uptimeWindow{}, Outages: []outage{}}
+		for _, w := range reportWindows {
+			uw := uptimeWindow{Window: w.name}
+			var up sql.NullInt64
+			err := s.db.QueryRow(
+				`SELECT COUNT(*), SUM(healthy) FROM checks WHERE endpoint = ? AND checked_at >= ?`,
+				name, now.Add(-w.d).UnixMilli(),
+			).Scan(&uw.Checks, &up)
+			if err != nil {
+				return nil, fmt.Errorf("uptime of %s: %w", name, err)
+			}
+			if uw.Checks > 0 {
+				uw.UptimePct = 100 * float64(up.Int64) / float64(uw.Checks)
+			}
+			r.Uptime = append(r.Uptime, uw)
+		}
+
+		outages, err := s.outages(name, now.Add(-reportWindows[len(reportWindows)-1].d), now)
+		if err != nil {
+			return nil, err
+		}
+		r.Outages = outages
+		reports = append(reports, r)
+	}
+	return reports, nil
+}
+
+// outages returns name's outages that overlap [since, now], newest first
+func (s *Store) outages(name string, since, now time.Time) ([]outage, error) {
+	rows, err := s.db.Query(
+		`SELECT started_at, ended_at, error FROM outages
+		 WHERE endpoint = ? AND (ended_at IS NULL OR ended_at >= ?)
+		 ORDER BY started_at DESC`,
+		name, since.UnixMilli(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("outages of %s: %w", name, err)
+	}
+	defer rows.Close()
+
+	outages := []outage{}
+	for rows.Next() {
+		var started int64
+		var ended sql.NullInt64
+		var o outage
+		if err := rows.Scan(&started, &ended, &o.Error); err != nil {
+			return nil, err
+		}
+		o.Start = time.UnixMilli(started)
+		end := now
+		if ended.Valid {
+			end = time.UnixMilli(ended.Int64)
+			o.End = &end
+		}
+		o.Duration = end.Sub(o.Start).Round(time.Second).String()
+		outages = append(outages, o)
+	}
+	return outages, rows.Err()
+}
+
+// dayUptime is an endpoint's uptime over one UTC day
+type dayUptime struct {
+	Day       time.Time
+	Checks    int
+	UptimePct float64
+}
+
+// dailyUptime returns name's uptime for each of the last days UTC days,
+// today last. Days without checks have Checks 0.
+func (s *Store) dailyUptime(name string, days int, now time.Time) ([]dayUptime, error) {
+	const day = 24 * time.Hour
+	first := now.UTC().Truncate(day).Add(-time.Duration(days-1) * day)
+	out := make([]dayUptime, days)
+	for i := range out {
+		out[i].Day = first.Add(time.Duration(i) * day)
+	}
+
+	rows, err := s.db.Query(
+		`SELECT checked_at / ?, COUNT(*), SUM(healthy) FROM checks
+		 WHERE endpoint = ? AND checked_at >= ? GROUP BY 1`,
+		day.Milliseconds(), name, first.UnixMilli(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("daily uptime of %s: %w", name, err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var n int64
+		var checks, up int
+		if err := rows.Scan(&n, &checks, &up); err != nil {
+			return nil, err
+		}
+		if i := int(time.UnixMilli(n*day.Milliseconds()).Sub(first) / day); i >= 0 && i < days {
+			out[i].Checks = checks
+			out[i].UptimePct = 100 * float64(up) / float64(checks)
+		}
+	}
+	return out, rows.Err()
+}
+
+// printReport prints reports for -report
+func printReport(reports []uptimeReport) {
+	fmt.Println("📈 Uptime")
+	fmt.Printf("   %-25s", "")
+	for _, w := range reportWindows {
+		fmt.Printf(" %9s", w.name)
+	}
+	fmt.Println()
+	for _, r := range reports {
+		fmt.Printf("   %-25s", r.Endpoint)
+		for _, w := range r.Uptime {
+			if w.Checks == 0 {
+				fmt.Printf(" %9s", "-")
+				continue
+			}
+			fmt.Printf(" %8.3f%%", w.UptimePct)
+		}
+		fmt.Println()
+	}
+
+	for _, r := range reports {
+		if len(r.Outages) == 0 {
+			continue
+		}
+		fmt.Printf("\n🔴 Outages of %s (last %s)\n", r.Endpoint, reportWindows[len(reportWindows)-1].name)
+		for _, o := range r.Outages {
+			end := "ongoing"
+			if o.End != nil {
+				end = o.End.Format(time.DateTime)
+			}
+			fmt.Printf("   %s → %-19s %10s  %s\n", o.Start.Format(time.DateTime), end, o.Duration, o.Error)
+		}
+	}
+}
+
+// printStoredReport prints the report for every endpoint in the database
+// at path
+func printStoredReport(path string) error {
+	store, err := openStore(path)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	names, err := store.Endpoints()
+	if err != nil {
+		return err
+	}
+	if len(names) == 0 {
+		return fmt.Errorf("no checks recorded in %s yet", path)
+	}
+	reports, err := store.Report(names, time.Now())
+	if err != nil {
+		return err
+	}
+	printReport(reports)
+	return nil
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+
+	"github.com/channyeintun/network-exercises/pkg/healthcheck"
+)
+
+func init() {
+	RegisterChecker("tcp", func(hc *HealthChecker) Checker { return tcpChecker{hc} }, (*Endpoint).validateAddress)
+}
+
+// tcpChecker connects to the endpoint's address and hangs up, proving
+// something accepts connections there (a database, an SMTP server)
+// without speaking its protocol
+type tcpChecker struct{ hc *HealthChecker }
+
+func (c tcpChecker) Check(ctx context.Context, ep *Endpoint) Result {
+	dial := func(ctx context.Context, _, addr string) (net.Conn, error) { return c.hc.dial(ctx, ep, addr) }
+	return Result{Err: healthcheck.TCP(ctx, dial, ep.Address)}
+}
+
+// validateAddress checks a tcp or grpc endpoint has an address of
+// host:port to connect to, or an SRV name to find them by
+func (ep *Endpoint) validateAddress() error {
+	if ep.SRV != "" {
+		if ep.Address != "" {
+			return fmt.Errorf("%s check takes an address or an srv name, not both", ep.Type)
+		}
+		return nil
+	}
+	if _, _, err := net.SplitHostPort(ep.Address); err != nil {
+		return fmt.Errorf("%s check needs an address of host:port: %v", ep.Type, err)
+	}
+	return nil
+}
@@ -0,0 +1,18 @@
+//go:build !(linux || darwin || dragonfly || freebsd || netbsd || openbsd)
+
+package main
+
+import (
+	"errors"
+	"os"
+)
+
+// terminalSize assumes the classic 80x24 where there's no TIOCGWINSZ
+func terminalSize(f *os.File) (int, int) {
+	return 80, 24
+}
+
+// cbreak isn't supported here; keys take effect after Enter
+func cbreak(f *os.File) (func(), error) {
+	return nil, errors.New("no termios on this platform")
+}
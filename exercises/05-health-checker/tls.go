@@ -0,0 +1,117 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// clientCert is a client certificate and key for mutual TLS, read from
+// files and read again when either changes, so a certificate rotated on
+// disk (by cert-manager, say, or a mesh's sidecar) is presented from the
+// next handshake without a restart
+type clientCert struct {
+	certFile, keyFile string
+
+	mu       sync.Mutex
+	cert     *tls.Certificate
+	modTimes [2]time.Time // of certFile and keyFile when cert was loaded
+}
+
+// loadClientCert reads the pair, failing if it isn't usable now
+func loadClientCert(certFile, keyFile string) (*clientCert, error) {
+	c := &clientCert{certFile: certFile, keyFile: keyFile}
+	if err := c.load(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *clientCert) load() error {
+	modTimes, err := c.stat()
+	if err != nil {
+		return err
+	}
+	cert, err := tls.LoadX509KeyPair(c.certFile, c.keyFile)
+	if err != nil {
+		return err
+	}
+	c.cert, c.modTimes = &cert, modTimes
+	return nil
+}
+
+func (c *clientCert) stat() ([2]time.Time, error) {
+	var modTimes [2]time.Time
+	for i, name := range []string{c.certFile, c.keyFile} {
+		fi, err := os.Stat(name)
+		if err != nil {
+			return modTimes, err
+		}
+		modTimes[i] = fi.ModTime()
+	}
+	return modTimes, nil
+}
+
+// get is a tls.Config GetClientCertificate. A pair that fails to load
+// (mid-rotation, with one file written and not the other) is logged and
+// the last good one used, so a failed reload doesn't fail the check.
+func (c *clientCert) get(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if modTimes, err := c.stat(); err == nil && modTimes != c.modTimes {
+		if err := c.load(); err != nil {
+			log.Printf("⚠️  Reloading client certificate %s: %v; still using the previous one", c.certFile, err)
+			c.modTimes = modTimes // don't retry until the files change again
+		} else {
+			log.Printf("🔑 Reloaded client certificate %s", c.certFile)
+		}
+	}
+	return c.cert, nil
+}
+
+// buildTLS turns an endpoint's TLS options into the config its checks
+// use, or leaves it nil if the defaults will do
+func (ep *Endpoint) buildTLS() error {
+	if ep.ClientCert == "" && ep.ClientKey == "" && ep.CAFile == "" && ep.ServerName == "" && !ep.InsecureSkipVerify {
+		return nil
+	}
+	config := &tls.Config{
+		ServerName:         ep.ServerName,
+		InsecureSkipVerify: ep.InsecureSkipVerify,
+	}
+
+	if ep.ClientCert != "" || ep.ClientKey != "" {
+		if ep.ClientCert == "" || ep.ClientKey == "" {
+			return errors.New("client_cert and client_key go together")
+		}
+		cert, err := loadClientCert(ep.ClientCert, ep.ClientKey)
+		if err != nil {
+			return fmt.Errorf("client certificate: %v", err)
+		}
+		config.GetClientCertificate = cert.get
+	}
+
+	if ep.CAFile != "" {
+		if ep.InsecureSkipVerify {
+			return errors.New("ca_file is pointless with insecure_skip_verify")
+		}
+		pem, err := os.ReadFile(ep.CAFile)
+		if err != nil {
+			return fmt.Errorf("ca_file: %v", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("ca_file: no PEM certificates in %s", ep.CAFile)
+		}
+		config.RootCAs = pool
+	}
+
+	ep.tlsConfig = config
+	return nil
+}
@@ -0,0 +1,16 @@
+package main
+
+import (
+	"github.com/BurntSushi/toml"
+)
+
+// parseTOML parses a TOML config into the same values encoding/json
+// produces. Dates and times become RFC 3339 strings; one without an
+// offset is taken as local time. Errors carry the line they're on.
+func parseTOML(data []byte) (any, error) {
+	var doc map[string]any
+	if _, err := toml.Decode(string(data), &doc); err != nil {
+		return nil, err
+	}
+	return asJSON(doc)
+}
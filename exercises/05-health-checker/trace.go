@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net/http/httptrace"
+	"sync"
+	"time"
+)
+
+// httpPhases breaks an HTTP check's latency down, to tell a slow network
+// from a slow server. A reused keep-alive connection skips DNS, connect
+// and TLS, leaving them zero.
+type httpPhases struct {
+	DNS     time.Duration
+	Connect time.Duration
+	TLS     time.Duration
+	TTFB    time.Duration // request written to first response byte: the server's think time
+	Reused  bool
+	Proto   string // what the response came over, e.g. "HTTP/2.0"
+
+	// When each phase started, for -otlp's child spans
+	dnsAt, connectAt, tlsAt, wroteAt time.Time
+}
+
+func (p *httpPhases) String() string {
+	ms := func(d time.Duration) string { return fmt.Sprintf("%.0fms", float64(d.Microseconds())/1000) }
+	if p.Reused {
+		return p.Proto + ": reused connection, ttfb " + ms(p.TTFB)
+	}
+	s := p.Proto + ": dns " + ms(p.DNS) + ", connect " + ms(p.Connect)
+	if p.TLS > 0 {
+		s += ", tls " + ms(p.TLS)
+	}
+	return s + ", ttfb " + ms(p.TTFB)
+}
+
+// withPhaseTrace returns ctx with an httptrace.ClientTrace filling in p.
+// Hooks can run on the transport's goroutines (a dual-stack dial races
+// two connects), hence the lock.
+func withPhaseTrace(ctx context.Context, p *httpPhases) context.Context {
+	var mu sync.Mutex
+	trace := &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) {
+			mu.Lock()
+			defer mu.Unlock()
+			p.dnsAt = time.Now()
+		},
+		DNSDone: func(httptrace.DNSDoneInfo) {
+			mu.Lock()
+			defer mu.Unlock()
+			p.DNS = time.Since(p.dnsAt)
+		},
+		ConnectStart: func(string, string) {
+			mu.Lock()
+			defer mu.Unlock()
+			if p.connectAt.IsZero() {
+				p.connectAt = time.Now()
+			}
+		},
+		ConnectDone: func(_, _ string, err error) {
+			mu.Lock()
+			defer mu.Unlock()
+			if err == nil {
+				p.Connect = time.Since(p.connectAt)
+			}
+		},
+		TLSHandshakeStart: func() {
+			mu.Lock()
+			defer mu.Unlock()
+			p.tlsAt = time.Now()
+		},
+		TLSHandshakeDone: func(_ tls.ConnectionState, _ error) {
+			mu.Lock()
+			defer mu.Unlock()
+			p.TLS = time.Since(p.tlsAt)
+		},
+		GotConn: func(info httptrace.GotConnInfo) {
+			mu.Lock()
+			defer mu.Unlock()
+			p.Reused = info.Reused
+		},
+		WroteRequest: func(httptrace.WroteRequestInfo) {
+			mu.Lock()
+			defer mu.Unlock()
+			p.wroteAt = time.Now()
+		},
+		GotFirstResponseByte: func() {
+			mu.Lock()
+			defer mu.Unlock()
+			p.TTFB = time.Since(p.wroteAt)
+		},
+	}
+	return httptrace.WithClientTrace(ctx, trace)
+}
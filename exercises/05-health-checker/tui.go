@@ -0,0 +1,458 @@
+package main
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// How often the TUI redraws, and how many log lines it keeps to show
+// under the table
+const (
+	tuiRefresh = 500 * time.Millisecond
+	tuiLogKeep = 5
+)
+
+// ANSI escape sequences the TUI draws with
+const (
+	ansiAltScreen  = "\033[?1049h\033[?25l" // switch to the alternate screen, hide the cursor
+	ansiMainScreen = "\033[?25h\033[?1049l"
+	ansiHome       = "\033[H"
+	ansiClearLine  = "\033[K" // to the end of the line
+	ansiClearBelow = "\033[J"
+	ansiBold       = "\033[1m"
+	ansiDim        = "\033[2m"
+	ansiReverse    = "\033[7m"
+	ansiRed        = "\033[31m"
+	ansiGreen      = "\033[32m"
+	ansiYellow     = "\033[33m"
+	ansiCyan       = "\033[36m"
+	ansiReset      = "\033[0m"
+)
+
+// tuiSort is the column the table is sorted by
+type tuiSort int
+
+const (
+	sortName    tuiSort = iota // A to Z
+	sortState                  // worst first
+	sortLatency                // slowest first
+)
+
+func (s tuiSort) String() string {
+	return [...]string{"name", "state", "latency"}[s]
+}
+
+// tui is the -tui dashboard: a table of endpoints redrawn in place, and
+// under it a detail pane for the selected one
+type tui struct {
+	hc       *HealthChecker
+	sortBy   tuiSort
+	reverse  bool
+	selected string // endpoint name; the first row if it's gone
+	logs     *tuiLog
+}
+
+// tuiRow is one endpoint as the table shows it, copied out under the
+// checker's lock
+type tuiRow struct {
+	ep      *Endpoint
+	status  *HealthStatus // nil until the first check
+	state   string
+	stats   windowStats
+	history []checkSample
+
+	checkedBy string // the cluster member checking it, if another
+}
+
+// tuiLog keeps the latest log lines, which would otherwise scribble over
+// the table
+type tuiLog struct {
+	mu    sync.Mutex
+	lines []string
+}
+
+func (l *tuiLog) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, line := range strings.Split(strings.TrimRight(string(p), "\n"), "\n") {
+		l.lines = append(l.lines, line)
+	}
+	if n := len(l.lines); n > tuiLogKeep {
+		l.lines = slices.Clone(l.lines[n-tuiLogKeep:])
+	}
+	return len(p), nil
+}
+
+func (l *tuiLog) last() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return slices.Clone(l.lines)
+}
+
+// runTUI draws the dashboard on the terminal's alternate screen until
+// ctx is cancelled or q is pressed, then puts the terminal back. Keys:
+// ↑/↓ (or k/j) select an endpoint, n, s and l sort by name, state and
+// latency, again to reverse, and q quits.
+func (hc *HealthChecker) runTUI(ctx context.Context, quit context.CancelFunc) {
+	t := &tui{hc: hc, sortBy: sortName, logs: &tuiLog{}}
+	log.SetOutput(t.logs)
+	defer log.SetOutput(os.Stderr)
+
+	// Without cbreak mode keys still work, a line at a time
+	if restore, err := cbreak(os.Stdin); err == nil {
+		defer restore()
+	}
+	keys := make(chan string)
+	go readKeys(os.Stdin, keys)
+
+	fmt.Print(ansiAltScreen)
+	defer fmt.Print(ansiMainScreen)
+	ticker := time.NewTicker(tuiRefresh)
+	defer ticker.Stop()
+	for {
+		width, height := terminalSize(os.Stdout)
+		t.draw(os.Stdout, width, height)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case key := <-keys:
+			if key == "q" {
+				quit()
+				return
+			}
+			t.key(key)
+		}
+	}
+}
+
+// readKeys sends each key pressed on r, with the arrow keys' escape
+// sequences as "up" and "down"
+func readKeys(r io.Reader, keys chan<- string) {
+	buf := make([]byte, 64)
+	for {
+		n, err := r.Read(buf)
+		if err != nil {
+			return
+		}
+		in := string(buf[:n])
+		for in != "" {
+			key := in[:1]
+			switch {
+			case strings.HasPrefix(in, "\033[A"):
+				key = "up"
+				in = in[3:]
+			case strings.HasPrefix(in, "\033[B"):
+				key = "down"
+				in = in[3:]
+			default:
+				in = in[1:]
+			}
+			keys <- key
+		}
+	}
+}
+
+// key acts on a key other than q
+func (t *tui) key(key string) {
+	switch key {
+	case "n", "s", "l":
+		sortBy := map[string]tuiSort{"n": sortName, "s": sortState, "l": sortLatency}[key]
+		t.reverse = sortBy == t.sortBy && !t.reverse
+		t.sortBy = sortBy
+	case "up", "k", "down", "j":
+		rows := t.rows()
+		if len(rows) == 0 {
+			return
+		}
+		i := slices.IndexFunc(rows, func(r tuiRow) bool { return r.ep.Name == t.selected })
+		if key == "up" || key == "k" {
+			i = max(i-1, 0)
+		} else {
+			i = min(i+1, len(rows)-1)
+		}
+		t.selected = rows[i].ep.Name
+	}
+}
+
+// stateRank orders states worst first
+var stateRank = map[string]int{"down": 0, "degraded": 1, "skipped": 2, "pending": 3, "up": 4, "remote": 5}
+
+// rows snapshots every endpoint, sorted
+func (t *tui) rows() []tuiRow {
+	hc := t.hc
+	hc.mu.RLock()
+	rows := make([]tuiRow, 0, len(hc.endpoints))
+	for i := range hc.endpoints {
+		ep := &hc.endpoints[i]
+		row := tuiRow{ep: ep, state: "pending"}
+		if row.checkedBy = hc.checkedBy(ep.Name); row.checkedBy != "" {
+			row.state = "remote"
+		} else if status, ok := hc.statuses[ep.Name]; ok {
+			row.status = status
+			row.state = stateName(status)
+		}
+		if w := hc.windows[ep.Name]; w != nil {
+			row.stats = w.stats()
+			row.history = slices.Clone(w.samples)
+		}
+		rows = append(rows, row)
+	}
+	hc.mu.RUnlock()
+
+	latency := func(r tuiRow) time.Duration {
+		if r.status == nil {
+			return -1
+		}
+		return r.status.Latency
+	}
+	slices.SortStableFunc(rows, func(a, b tuiRow) int {
+		c := 0
+		switch t.sortBy {
+		case sortState:
+			c = cmp.Compare(stateRank[a.state], stateRank[b.state])
+		case sortLatency:
+			c = cmp.Compare(latency(b), latency(a))
+		}
+		if c == 0 {
+			c = strings.Compare(a.ep.Name, b.ep.Name)
+		}
+		if t.reverse {
+			c = -c
+		}
+		return c
+	})
+	return rows
+}
+
+// draw draws one frame: a title line, the table, the selected endpoint's
+// details and the latest log lines. The frame is built in memory and
+// written at once, each line overwriting the last frame's, so it doesn't
+// flicker.
+func (t *tui) draw(w io.Writer, width, height int) {
+	rows := t.rows()
+	if len(rows) > 0 && !slices.ContainsFunc(rows, func(r tuiRow) bool { return r.ep.Name == t.selected }) {
+		t.selected = rows[0].ep.Name
+	}
+
+	var b bytes.Buffer
+	line := func(s string) {
+		b.WriteString(s + ansiReset + ansiClearLine + "\n")
+	}
+	b.WriteString(ansiHome)
+
+	counts := map[string]int{}
+	for _, r := range rows {
+		counts[r.state]++
+	}
+	order := ""
+	if t.reverse {
+		order = ", reversed"
+	}
+	line(fmt.Sprintf("%s🏥 Health Checker%s  %d endpoints  %s%d up%s  %s%d degraded%s  %s%d down%s  %ssorted by %s%s%s",
+		ansiBold, ansiReset, len(rows),
+		ansiGreen, counts["up"], ansiReset, ansiYellow, counts["degraded"], ansiReset, ansiRed, counts["down"], ansiReset,
+		ansiDim, t.sortBy, order, ansiReset))
+	line("")
+	line(fmt.Sprintf("%s  %-25s %-9s %9s %9s %7s  %s", ansiBold, "NAME", "STATE", "LATENCY", "P95", "OK 1H", "LAST CHECK"))
+
+	// Leave room for the detail pane and the log under the table
+	const below = 11 + tuiLogKeep
+	shown := max(height-3-below, 3)
+	first := 0
+	if i := slices.IndexFunc(rows, func(r tuiRow) bool { return r.ep.Name == t.selected }); i >= shown {
+		first = i - shown + 1
+	}
+	var selected *tuiRow
+	for i := range rows {
+		r := &rows[i]
+		if r.ep.Name == t.selected {
+			selected = r
+		}
+		if i < first || i >= first+shown {
+			continue
+		}
+
+		latency, p95, ok, age := "-", "-", "-", "-"
+		if r.status != nil {
+			latency = formatMS(r.status.Latency)
+			age = time.Since(r.status.LastCheck).Round(time.Second).String() + " ago"
+		}
+		if r.stats.Checks > 0 {
+			ok = fmt.Sprintf("%.1f%%", r.stats.SuccessRate*100)
+		}
+		if r.stats.SuccessRate > 0 {
+			p95 = fmt.Sprintf("%.0fms", r.stats.P95MS)
+		}
+		marker, style := "  ", ""
+		if r.ep.Name == t.selected {
+			marker, style = "▶ ", ansiReverse
+		}
+		line(fmt.Sprintf("%s%s%-25s %s%-9s%s%s %9s %9s %7s  %s",
+			marker, style, clip(r.ep.Name, 25), stateColor(r.state), r.state, ansiReset, style, latency, p95, ok, age))
+	}
+	if n := len(rows) - first - shown; n > 0 {
+		line(fmt.Sprintf("%s  … %d more", ansiDim, n))
+	}
+	line("")
+
+	if selected != nil {
+		t.drawDetail(line, selected, width)
+	}
+	line("")
+	for _, l := range t.logs.last() {
+		line(ansiDim + clip(l, width))
+	}
+	line(ansiDim + "↑/↓ select · n/s/l sort by name/state/latency (again to reverse) · q quit")
+	b.WriteString(ansiClearBelow)
+	w.Write(b.Bytes())
+}
+
+// drawDetail draws the detail pane for one endpoint
+func (t *tui) drawDetail(line func(string), r *tuiRow, width int) {
+	field := func(name, value string) {
+		line(fmt.Sprintf("  %s%-8s%s %s", ansiDim, name, ansiReset, clip(value, width-12)))
+	}
+	line(fmt.Sprintf("%s── %s %s", ansiBold, r.ep.Name, strings.Repeat("─", max(width-len(r.ep.Name)-5, 0))))
+	field("target", r.ep.target())
+
+	s := r.status
+	if r.checkedBy != "" {
+		field("state", "checked by "+r.checkedBy)
+		return
+	}
+	if s == nil {
+		field("state", "checking...")
+		return
+	}
+	state := r.state
+	switch {
+	case s.Skipped != "":
+		state += " (dependency " + s.Skipped + " down)"
+	case s.Healthy && !s.LastOK:
+		state += fmt.Sprintf(" (failing %d/%d)", s.Streak, r.ep.FailureThreshold)
+	case !s.Healthy && s.LastOK:
+		state += fmt.Sprintf(" (recovering %d/%d)", s.Streak, r.ep.SuccessThreshold)
+	case s.Partial != "":
+		state += " (partial outage)"
+	case s.Degraded:
+		state += fmt.Sprintf(" (over warn_latency %v)", r.ep.WarnLatency)
+	}
+	field("state", state)
+
+	latency := formatMS(s.Latency)
+	if s.Attempts > 1 {
+		latency += fmt.Sprintf(" after %d attempts", s.Attempts)
+	}
+	field("latency", latency+" ["+r.stats.String()+"]")
+	if s.Phases != nil && s.Phases.TTFB > 0 {
+		field("phases", s.Phases.String())
+	}
+	if s.Error != "" {
+		field("error", s.Error)
+	}
+	for i, t := range s.Targets {
+		name := ""
+		if i == 0 {
+			name = "targets"
+		}
+		if t.Error != "" {
+			field(name, ansiRed+"× "+ansiReset+t.Address+" ("+t.Error+")")
+		} else {
+			field(name, ansiGreen+"✓ "+ansiReset+t.Address+" "+formatMS(t.Latency))
+		}
+	}
+	for i, src := range s.Sources {
+		name := ""
+		if i == 0 {
+			name = "from"
+		}
+		from := src.Source
+		if src.Address != "" && src.Address != src.Source {
+			from += " (" + src.Address + ")"
+		}
+		if src.Error != "" {
+			field(name, ansiRed+"× "+ansiReset+from+": "+src.Error)
+		} else {
+			field(name, ansiGreen+"✓ "+ansiReset+from+" "+formatMS(src.Latency))
+		}
+	}
+	if s.Muted != "" {
+		field("muted", s.Muted)
+	}
+
+	history := r.history[max(len(r.history)-(width-12), 0):]
+	spark, top := sparkline(history)
+	line(fmt.Sprintf("  %s%-8s%s %s", ansiDim, "history", ansiReset, spark))
+	switch {
+	case top > 0:
+		line(fmt.Sprintf("  %9s%slast %d checks, 0 to %s; × failed", "", ansiDim, len(history), formatMS(top)))
+	case len(history) > 0:
+		line(fmt.Sprintf("  %9s%slast %d checks, all failed", "", ansiDim, len(history)))
+	}
+}
+
+// sparkline draws passed checks' latencies as one row of block
+// characters, scaled to the slowest, and failed ones as a red ×. It
+// returns the line and the latency of a full block.
+func sparkline(samples []checkSample) (string, time.Duration) {
+	levels := []rune("▁▂▃▄▅▆▇█")
+	var top time.Duration
+	for _, s := range samples {
+		if s.ok {
+			top = max(top, s.latency)
+		}
+	}
+
+	var b strings.Builder
+	b.WriteString(ansiCyan)
+	for _, s := range samples {
+		if !s.ok {
+			b.WriteString(ansiRed + "×" + ansiCyan)
+			continue
+		}
+		i := int(int64(s.latency) * int64(len(levels)-1) / int64(max(top, 1)))
+		b.WriteRune(levels[i])
+	}
+	b.WriteString(ansiReset)
+	return b.String(), top
+}
+
+func stateColor(state string) string {
+	switch state {
+	case "up":
+		return ansiGreen
+	case "degraded":
+		return ansiYellow
+	case "down":
+		return ansiRed
+	}
+	return ansiDim
+}
+
+func formatMS(d time.Duration) string {
+	return fmt.Sprintf("%.0fms", float64(d.Microseconds())/1000)
+}
+
+// clip shortens s to at most n runes, marking the cut with …
+func clip(s string, n int) string {
+	r := []rune(s)
+	if n < 1 || len(r) <= n {
+		return s
+	}
+	return string(r[:n-1]) + "…"
+}
+
+func isTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
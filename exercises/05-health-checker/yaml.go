@@ -0,0 +1,15 @@
+package main
+
+import (
+	"gopkg.in/yaml.v3"
+)
+
+// parseYAML parses a YAML config into the same values encoding/json
+// produces. Errors carry the line they're on.
+func parseYAML(data []byte) (any, error) {
+	var doc any
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	return asJSON(doc)
+}
@@ -0,0 +1,184 @@
+// Package main implements a dig-like DNS client.
+// This exercise teaches binary protocol encoding with DNS over UDP and TCP.
+//
+// Learning objectives:
+// - Encode and decode DNS messages by hand (header, questions, records, name compression)
+// - Query over UDP, and fall back to TCP when a reply comes back truncated
+// - Advertise a larger UDP buffer, and more, with EDNS(0)
+// - Match replies to queries by ID and question, and time them
+//
+// Run: go run . example.com
+// Or:  go run . example.com MX @1.1.1.1   (dig-style: type and @server anywhere)
+// Or:  go run . -type AAAA -server 8.8.8.8 example.com
+// Or:  go run . -short example.com TXT   (just the answers' data)
+// Or:  go run . -x 8.8.8.8   (reverse lookup: PTR for 8.8.8.8.in-addr.arpa.)
+// Or:  go run . -tcp example.com   (TCP from the start, as zone transfers and big replies use)
+// Or:  go run . -bufsize 512 example.com DNSKEY   (small UDP buffer: truncated, then TCP)
+// Or:  go run . -bufsize 0 example.com   (no EDNS: a plain RFC 1035 query, 512-byte replies)
+// Or:  go run . -dnssec -nsid example.com @1.1.1.1   (DO bit for DNSSEC records; which instance answered)
+// Or:  go run . -norecurse example.com @a.iana-servers.net   (ask an authoritative server directly)
+// Or:  go run . -class CH version.bind TXT @127.0.0.1   (ask a server what it runs)
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"math/rand/v2"
+	"net"
+	"net/netip"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"github.com/channyeintun/network-exercises/pkg/dnswire"
+)
+
+func main() {
+	server := flag.String("server", "", "DNS server to ask, host[:port] (default the first nameserver in /etc/resolv.conf); or @server as an argument")
+	qtype := flag.String("type", "A", "Record type to ask for: A, AAAA, MX, TXT, CNAME, NS, SOA, PTR, SRV, ANY or TYPEn; or as an argument")
+	qclass := flag.String("class", "IN", "Class to ask in: IN, or CH for server information")
+	reverse := flag.Bool("x", false, "Reverse lookup: the name is an IP address, asked for its PTR record")
+	useTCP := flag.Bool("tcp", false, "Query over TCP instead of UDP")
+	ignoreTC := flag.Bool("ignore-tc", false, "Take a truncated UDP reply as it is instead of asking again over TCP")
+	bufSize := flag.Int("bufsize", 1232, "EDNS UDP buffer size to advertise; 0 sends no EDNS, limiting UDP replies to 512 bytes")
+	dnssec := flag.Bool("dnssec", false, "Set the EDNS DO bit, asking for DNSSEC records")
+	nsid := flag.Bool("nsid", false, "Ask the server to identify itself (EDNS NSID)")
+	norecurse := flag.Bool("norecurse", false, "Clear RD: ask for what the server knows itself, not a full resolution")
+	timeout := flag.Duration("timeout", 2*time.Second, "How long to wait for each reply")
+	tries := flag.Int("tries", 3, "UDP sends before giving up")
+	short := flag.Bool("short", false, "Print only the answers' data, one per line")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s [flags] [@server] name [type]\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+
+	// dig-style arguments: @server, a type and a name, in any order and
+	// among the flags, which flag.Parse alone would stop at
+	var positional []string
+	for args := os.Args[1:]; ; {
+		flag.CommandLine.Parse(args)
+		if args = flag.Args(); len(args) == 0 {
+			break
+		}
+		positional, args = append(positional, args[0]), args[1:]
+	}
+	var name string
+	for _, arg := range positional {
+		if s, ok := strings.CutPrefix(arg, "@"); ok {
+			*server = s
+			continue
+		}
+		if !strings.Contains(arg, ".") {
+			if _, err := dnswire.ParseType(arg); err == nil {
+				*qtype = arg
+				continue
+			}
+		}
+		if name != "" {
+			fatalf("one name at a time: got %s and %s", name, arg)
+		}
+		name = arg
+	}
+	if name == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	t, err := dnswire.ParseType(*qtype)
+	if err != nil {
+		fatalf("%v", err)
+	}
+	class, err := dnswire.ParseClass(*qclass)
+	if err != nil {
+		fatalf("%v", err)
+	}
+	if *reverse {
+		ip, err := netip.ParseAddr(name)
+		if err != nil {
+			fatalf("-x wants an IP address, got %q", name)
+		}
+		name, t = dnswire.ReverseName(ip), dnswire.TypePTR
+	}
+	if *bufSize < 0 || *bufSize > 65535 {
+		fatalf("-bufsize must be 0 to 65535")
+	}
+	if *bufSize == 0 && (*dnssec || *nsid) {
+		fatalf("-dnssec and -nsid are EDNS options; they need -bufsize")
+	}
+	if *tries < 1 {
+		fatalf("-tries must be at least 1")
+	}
+
+	if *server == "" {
+		*server = systemServer()
+	}
+	addr := *server
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		addr = net.JoinHostPort(strings.Trim(addr, "[]"), "53")
+	}
+
+	query := &dnswire.Message{
+		Header: dnswire.Header{
+			ID:               uint16(rand.UintN(1 << 16)), // random, so an off-path spoofer has to guess it
+			Opcode:           dnswire.OpcodeQuery,
+			RecursionDesired: !*norecurse,
+		},
+		Questions: []dnswire.Question{{Name: dnswire.Fqdn(name), Type: t, Class: class}},
+	}
+	if *bufSize > 0 {
+		edns := dnswire.EDNS{UDPSize: uint16(*bufSize), DO: *dnssec}
+		if *nsid {
+			edns.Options = append(edns.Options, dnswire.Option{Code: dnswire.OptionNSID})
+		}
+		query.SetEDNS(edns)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	c := &dnswire.Client{
+		Timeout:  *timeout,
+		Tries:    *tries,
+		TCP:      *useTCP,
+		IgnoreTC: *ignoreTC,
+		Note: func(reason string) {
+			if !*short {
+				fmt.Println(";; " + reason)
+			}
+		},
+	}
+	start := time.Now()
+	r, err := c.Exchange(ctx, addr, query)
+	if err != nil {
+		fatalf("%v", err)
+	}
+
+	if *short {
+		printShort(r.Msg)
+		return
+	}
+	printReply(os.Args[1:], r, addr, start)
+}
+
+// systemServer returns the first nameserver in /etc/resolv.conf, or
+// localhost's, as dig falls back to, without one
+func systemServer() string {
+	data, err := os.ReadFile("/etc/resolv.conf")
+	if err != nil {
+		return "127.0.0.1"
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) >= 2 && fields[0] == "nameserver" {
+			return fields[1]
+		}
+	}
+	return "127.0.0.1"
+}
+
+func fatalf(format string, args ...any) {
+	fmt.Fprintf(os.Stderr, ";; "+format+"\n", args...)
+	os.Exit(1)
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/channyeintun/network-exercises/pkg/dnswire"
+)
+
+// printReply prints the reply as dig does: the header, EDNS, each
+// section as zone file lines, then how long it took and where from
+func printReply(args []string, r *dnswire.Reply, server string, when time.Time) {
+	m := r.Msg
+	fmt.Printf("\n; <<>> 06-dns-client <<>> %s\n", strings.Join(args, " "))
+	fmt.Println(";; Got answer:")
+	fmt.Printf(";; ->>HEADER<<- opcode: %s, status: %s, id: %d\n", m.Opcode, m.RCode, m.ID)
+	fmt.Printf(";; flags: %s; QUERY: %d, ANSWER: %d, AUTHORITY: %d, ADDITIONAL: %d\n",
+		m.FlagString(), len(m.Questions), len(m.Answers), len(m.Authority), len(m.Additional))
+
+	if edns, ok := m.EDNS(); ok {
+		flags := ""
+		if edns.DO {
+			flags = " do"
+		}
+		fmt.Println("\n;; OPT PSEUDOSECTION:")
+		fmt.Printf("; EDNS: version: %d, flags:%s; udp: %d\n", edns.Version, flags, edns.UDPSize)
+		for _, o := range edns.Options {
+			if o.Code == dnswire.OptionNSID {
+				fmt.Printf("; NSID: % x (%q)\n", o.Data, o.Data)
+				continue
+			}
+			fmt.Printf("; %s\n", o)
+		}
+	}
+
+	fmt.Println("\n;; QUESTION SECTION:")
+	for _, q := range m.Questions {
+		fmt.Printf(";%s\t\t%s\t%s\n", q.Name, q.Class, q.Type)
+	}
+	printSection("ANSWER", m.Answers)
+	printSection("AUTHORITY", m.Authority)
+	var additional []dnswire.RR
+	for _, rr := range m.Additional {
+		if rr.Type != dnswire.TypeOPT {
+			additional = append(additional, rr)
+		}
+	}
+	printSection("ADDITIONAL", additional)
+
+	fmt.Printf("\n;; Query time: %d msec\n", r.RTT.Milliseconds())
+	fmt.Printf(";; SERVER: %s (%s)\n", server, strings.ToUpper(r.Network))
+	fmt.Printf(";; WHEN: %s\n", when.Format("Mon Jan 02 15:04:05 MST 2006"))
+	fmt.Printf(";; MSG SIZE  rcvd: %d\n\n", r.Size)
+}
+
+func printSection(name string, rrs []dnswire.RR) {
+	if len(rrs) == 0 {
+		return
+	}
+	fmt.Printf("\n;; %s SECTION:\n", name)
+	for _, rr := range rrs {
+		fmt.Println(rr)
+	}
+}
+
+// printShort prints just the answers' data, as dig +short does
+func printShort(m *dnswire.Message) {
+	for _, rr := range m.Answers {
+		if rr.Data != nil {
+			fmt.Println(rr.Data)
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"github.com/channyeintun/network-exercises/pkg/dnswire"
+)
+
+// maxChain bounds how many CNAMEs an answer follows inside a zone, so a
+// loop of them ends
+const maxChain = 8
+
+// findZone returns the zone with the longest origin that name is in,
+// nil if none is
+func findZone(zones []*zone, name string) *zone {
+	var best *zone
+	for _, z := range zones {
+		if dnswire.IsSubdomain(name, z.origin) && (best == nil || len(z.origin) > len(best.origin)) {
+			best = z
+		}
+	}
+	return best
+}
+
+// answer fills in reply to q from the zone's own records:
+//   - the records asked for, following CNAMEs within the zone
+//   - a referral (the child's NS records, not authoritative) for names
+//     delegated to other servers
+//   - NXDOMAIN for a name that doesn't exist, or NOERROR with no answers
+//     (NODATA) for a type it hasn't, with the SOA so resolvers know how
+//     long to cache that (RFC 2308)
+func (z *zone) answer(q dnswire.Question, reply *dnswire.Message) {
+	name := dnswire.CanonicalName(q.Name)
+	if cut := z.delegation(name); cut != "" {
+		z.refer(cut, reply)
+		return
+	}
+	reply.Authoritative = true
+
+	for range maxChain {
+		rrsets, ok := z.rrsets[name]
+		if !ok {
+			if !z.names[name] {
+				reply.RCode = dnswire.RCodeNameError
+			}
+			z.negative(reply)
+			return
+		}
+		if q.Type == dnswire.TypeANY {
+			for _, t := range types(rrsets) {
+				reply.Answers = append(reply.Answers, rrsets[t]...)
+			}
+			z.additional(reply)
+			return
+		}
+		if rrs := rrsets[q.Type]; len(rrs) > 0 {
+			reply.Answers = append(reply.Answers, rrs...)
+			z.additional(reply)
+			return
+		}
+		cname := rrsets[dnswire.TypeCNAME]
+		if len(cname) == 0 {
+			z.negative(reply)
+			return
+		}
+		// The alias, then what it points at if that's here too; if it's
+		// elsewhere the resolver asking follows it
+		reply.Answers = append(reply.Answers, cname...)
+		target := dnswire.CanonicalName(cname[0].Data.(*dnswire.CNAME).Target)
+		if !dnswire.IsSubdomain(target, z.origin) || z.delegation(target) != "" {
+			return
+		}
+		name = target
+	}
+}
+
+// delegation returns the highest name between name and the apex with
+// NS records, where the zone hands that part of the tree to other
+// servers, or "" if name isn't delegated
+func (z *zone) delegation(name string) string {
+	cut := ""
+	for n := name; n != z.origin; n, _ = dnswire.Parent(n) {
+		if len(z.rrsets[n][dnswire.TypeNS]) > 0 {
+			cut = n
+		}
+	}
+	return cut
+}
+
+// refer points the asker at the servers a name is delegated to, with
+// their addresses (glue) when they're inside the delegated zone and so
+// couldn't be looked up without them
+func (z *zone) refer(cut string, reply *dnswire.Message) {
+	reply.Authority = append(reply.Authority, z.rrsets[cut][dnswire.TypeNS]...)
+	for _, rr := range z.rrsets[cut][dnswire.TypeNS] {
+		z.addAddresses(reply, rr.Data.(*dnswire.NS).Host)
+	}
+}
+
+// negative adds the SOA to a reply with no answer, its TTL capped at
+// the SOA's minimum: how long the absence may be cached
+func (z *zone) negative(reply *dnswire.Message) {
+	soa := z.soa
+	soa.TTL = min(soa.TTL, soa.Data.(*dnswire.SOA).MinTTL)
+	reply.Authority = append(reply.Authority, soa)
+}
+
+// additional adds the addresses of the hosts the answers name (mail
+// exchangers, SRV targets, name servers) when the zone has them,
+// sparing the asker a lookup for each
+func (z *zone) additional(reply *dnswire.Message) {
+	for _, rr := range reply.Answers {
+		switch d := rr.Data.(type) {
+		case *dnswire.MX:
+			z.addAddresses(reply, d.Host)
+		case *dnswire.SRV:
+			z.addAddresses(reply, d.Target)
+		case *dnswire.NS:
+			z.addAddresses(reply, d.Host)
+		}
+	}
+}
+
+// addAddresses adds host's A and AAAA records to the additional section
+// once, if host is in the zone
+func (z *zone) addAddresses(reply *dnswire.Message, host string) {
+	host = dnswire.CanonicalName(host)
+	for _, rr := range reply.Additional {
+		if dnswire.CanonicalName(rr.Name) == host {
+			return
+		}
+	}
+	rrsets := z.rrsets[host]
+	reply.Additional = append(reply.Additional, rrsets[dnswire.TypeA]...)
+	reply.Additional = append(reply.Additional, rrsets[dnswire.TypeAAAA]...)
+}
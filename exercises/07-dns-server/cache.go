@@ -0,0 +1,134 @@
+package main
+
+import (
+	"container/list"
+	"sync"
+	"time"
+
+	"github.com/channyeintun/network-exercises/pkg/dnswire"
+)
+
+// cacheKey is what an upstream answer is cached under. DO is part of it
+// because an answer with DNSSEC records isn't the same answer.
+type cacheKey struct {
+	name  string // canonical
+	qtype dnswire.Type
+	class dnswire.Class
+	do    bool
+}
+
+type cacheEntry struct {
+	key     cacheKey
+	msg     *dnswire.Message
+	stored  time.Time
+	expires time.Time
+	elem    *list.Element
+}
+
+// cache keeps upstream answers until their TTLs run out, handing them
+// out with the TTLs counted down by the time they've been held, and
+// drops the least recently used when it's full
+type cache struct {
+	mu      sync.Mutex
+	size    int
+	maxTTL  time.Duration
+	entries map[cacheKey]*cacheEntry
+	lru     *list.List // front: most recently used
+}
+
+func newCache(size int, maxTTL time.Duration) *cache {
+	return &cache{size: size, maxTTL: maxTTL, entries: make(map[cacheKey]*cacheEntry), lru: list.New()}
+}
+
+// get returns a copy of the cached answer for key, its TTLs reduced by
+// its age, or nil if there's none or it has expired
+func (c *cache) get(key cacheKey, now time.Time) *dnswire.Message {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[key]
+	if !ok {
+		return nil
+	}
+	if !now.Before(e.expires) {
+		c.remove(e)
+		return nil
+	}
+	c.lru.MoveToFront(e.elem)
+
+	age := uint32(now.Sub(e.stored) / time.Second)
+	m := &dnswire.Message{Header: e.msg.Header}
+	m.Answers = aged(e.msg.Answers, age)
+	m.Authority = aged(e.msg.Authority, age)
+	m.Additional = aged(e.msg.Additional, age)
+	return m
+}
+
+// put caches msg under key for as long as its records allow, if at all
+func (c *cache) put(key cacheKey, msg *dnswire.Message, now time.Time) {
+	ttl := cacheTTL(msg)
+	if ttl == 0 || c.size <= 0 {
+		return
+	}
+	d := min(time.Duration(ttl)*time.Second, c.maxTTL)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.entries[key]; ok {
+		c.remove(e)
+	}
+	for c.lru.Len() >= c.size {
+		c.remove(c.lru.Back().Value.(*cacheEntry))
+	}
+	e := &cacheEntry{key: key, msg: msg, stored: now, expires: now.Add(d)}
+	e.elem = c.lru.PushFront(e)
+	c.entries[key] = e
+}
+
+func (c *cache) remove(e *cacheEntry) {
+	c.lru.Remove(e.elem)
+	delete(c.entries, e.key)
+}
+
+// len returns how many answers are cached, expired ones included
+func (c *cache) len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.entries)
+}
+
+// cacheTTL returns how many seconds an answer may be cached: its
+// records' lowest TTL, or for a negative answer (NXDOMAIN, or no
+// records of the type) the SOA's TTL capped at its minimum field (RFC
+// 2308). Failures, and negative answers without an SOA, aren't cached.
+func cacheTTL(msg *dnswire.Message) uint32 {
+	switch {
+	case msg.RCode == dnswire.RCodeSuccess && len(msg.Answers) > 0:
+		ttl := ^uint32(0)
+		for _, section := range [][]dnswire.RR{msg.Answers, msg.Authority, msg.Additional} {
+			for _, rr := range section {
+				ttl = min(ttl, rr.TTL)
+			}
+		}
+		return ttl
+	case msg.RCode == dnswire.RCodeSuccess, msg.RCode == dnswire.RCodeNameError:
+		for _, rr := range msg.Authority {
+			if soa, ok := rr.Data.(*dnswire.SOA); ok {
+				return min(rr.TTL, soa.MinTTL)
+			}
+		}
+	}
+	return 0
+}
+
+// aged copies rrs with age taken off their TTLs
+func aged(rrs []dnswire.RR, age uint32) []dnswire.RR {
+	if len(rrs) == 0 {
+		return nil
+	}
+	out := make([]dnswire.RR, len(rrs))
+	for i, rr := range rrs {
+		rr.TTL -= min(rr.TTL, age)
+		out[i] = rr
+	}
+	return out
+}
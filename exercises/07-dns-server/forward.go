@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"github.com/channyeintun/network-exercises/pkg/dnswire"
+)
+
+// forwarder answers the names no zone here has by asking upstream
+// resolvers, caching what they say
+type forwarder struct {
+	upstreams []string // host:port, tried in order
+	client    *dnswire.Client
+	cache     *cache
+
+	mu sync.Mutex
+	// inflight holds the upstream queries on their way, so clients
+	// asking the same thing at once share one rather than each sending
+	// their own
+	inflight map[cacheKey]*call
+}
+
+// call is one upstream query that several clients may be waiting on
+type call struct {
+	done chan struct{}
+	msg  *dnswire.Message
+	err  error
+}
+
+func newForwarder(upstreams []string, timeout time.Duration, c *cache) *forwarder {
+	return &forwarder{
+		upstreams: upstreams,
+		client:    &dnswire.Client{Timeout: timeout, Tries: 2},
+		cache:     c,
+		inflight:  make(map[cacheKey]*call),
+	}
+}
+
+// resolve returns the answer to q, from the cache if it's there (and
+// says so), otherwise from upstream
+func (f *forwarder) resolve(ctx context.Context, q dnswire.Question, do bool) (*dnswire.Message, bool, error) {
+	key := cacheKey{name: dnswire.CanonicalName(q.Name), qtype: q.Type, class: q.Class, do: do}
+	if msg := f.cache.get(key, time.Now()); msg != nil {
+		return msg, true, nil
+	}
+
+	f.mu.Lock()
+	if c, ok := f.inflight[key]; ok {
+		f.mu.Unlock()
+		select {
+		case <-c.done:
+			return c.msg, false, c.err
+		case <-ctx.Done():
+			return nil, false, ctx.Err()
+		}
+	}
+	c := &call{done: make(chan struct{})}
+	f.inflight[key] = c
+	f.mu.Unlock()
+
+	c.msg, c.err = f.ask(ctx, q, do)
+	if c.err == nil {
+		f.cache.put(key, c.msg, time.Now())
+	}
+	f.mu.Lock()
+	delete(f.inflight, key)
+	f.mu.Unlock()
+	close(c.done)
+	return c.msg, false, c.err
+}
+
+// ask sends q to each upstream in turn until one answers with
+// something other than a failure
+func (f *forwarder) ask(ctx context.Context, q dnswire.Question, do bool) (*dnswire.Message, error) {
+	query := &dnswire.Message{
+		Header: dnswire.Header{
+			ID:               uint16(rand.UintN(1 << 16)),
+			Opcode:           dnswire.OpcodeQuery,
+			RecursionDesired: true,
+		},
+		Questions: []dnswire.Question{q},
+	}
+	query.SetEDNS(dnswire.EDNS{UDPSize: 1232, DO: do})
+
+	var lastErr error
+	for _, upstream := range f.upstreams {
+		r, err := f.client.Exchange(ctx, upstream, query)
+		if err != nil {
+			lastErr = fmt.Errorf("%s: %w", upstream, err)
+			continue
+		}
+		msg := r.Msg
+		if msg.RCode == dnswire.RCodeServerFailure || msg.RCode == dnswire.RCodeRefused {
+			lastErr = fmt.Errorf("%s: %s", upstream, msg.RCode)
+			continue
+		}
+		// The OPT record was for this hop; the client gets our own
+		additional := msg.Additional[:0:0]
+		for _, rr := range msg.Additional {
+			if rr.Type != dnswire.TypeOPT {
+				additional = append(additional, rr)
+			}
+		}
+		msg.Additional = additional
+		return msg, nil
+	}
+	if lastErr == nil {
+		lastErr = errors.New("no upstreams")
+	}
+	return nil, lastErr
+}
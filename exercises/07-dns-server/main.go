@@ -0,0 +1,188 @@
+// Package main implements an authoritative and forwarding DNS server.
+// This exercise teaches serving a binary protocol over UDP and TCP at once.
+//
+// Learning objectives:
+// - Load a zone from a master file and answer for it with authority
+// - Answer NXDOMAIN and NODATA with the SOA, follow CNAMEs, refer delegations
+// - Forward other names upstream, caching answers until their TTLs run out
+// - Serve UDP and TCP on one port, with concurrent queries and truncation
+//
+// Run: go run . -zone lab.zone
+// Test: go run ../06-dns-client www.lab.test @127.0.0.1:5353
+// Or:  go run . -zone lab.zone -upstream ""   (authoritative only: REFUSED for other names)
+// Or:  go run . -zone lab.zone -upstream 9.9.9.9,1.1.1.1 -cache-size 50000 -log-queries
+// Or:  go run . -upstream 1.1.1.1   (a caching forwarder for the whole network, no zones)
+// Or:  go run . -zone lab.zone -nsid ns1   (name this instance for NSID and id.server)
+// Or:  go run . -zone lab.zone,corp.zone -listen :53   (several zones; port 53 needs root)
+// Edit a zone and kill -HUP the server to reload it without a restart.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/channyeintun/network-exercises/pkg/dnswire"
+)
+
+func main() {
+	listen := flag.String("listen", ":5353", "Address to serve on, over both UDP and TCP")
+	zoneFiles := flag.String("zone", "", "Comma-separated zone (master) files to answer for with authority")
+	upstream := flag.String("upstream", "1.1.1.1,8.8.8.8", "Comma-separated resolvers to forward other names to, host[:port], tried in order; empty to refuse them")
+	timeout := flag.Duration("timeout", 2*time.Second, "How long to wait for each upstream reply")
+	cacheSize := flag.Int("cache-size", 10000, "Forwarded answers to cache; 0 disables the cache")
+	cacheMaxTTL := flag.Duration("cache-max-ttl", time.Hour, "Longest to cache an answer, whatever its TTL")
+	udpSize := flag.Int("udp-size", 1232, "Largest UDP reply to send to EDNS clients (512 to those without)")
+	nsid := flag.String("nsid", "", "Identifier to answer EDNS NSID and id.server queries with")
+	maxConcurrent := flag.Int("max-concurrent", 1000, "Queries to answer at once; more wait")
+	idle := flag.Duration("idle-timeout", 10*time.Second, "Close TCP connections idle this long")
+	logQueries := flag.Bool("log-queries", false, "Log every query and how it was answered")
+	statsEvery := flag.Duration("stats", time.Minute, "Log query counts this often; 0 to only log them at shutdown")
+	flag.Parse()
+
+	if *udpSize < 512 || *udpSize > 65535 {
+		log.Fatal("-udp-size must be 512 to 65535")
+	}
+	if *maxConcurrent < 1 {
+		log.Fatal("-max-concurrent must be at least 1")
+	}
+	paths := splitList(*zoneFiles)
+	if len(paths) == 0 && *upstream == "" {
+		log.Fatal("Nothing to answer with: give -zone, -upstream or both")
+	}
+
+	s := &server{
+		udpSize:    uint16(*udpSize),
+		nsid:       *nsid,
+		idle:       *idle,
+		logQueries: *logQueries,
+		sem:        make(chan struct{}, *maxConcurrent),
+	}
+	zones, err := loadZones(paths)
+	if err != nil {
+		log.Fatalf("Failed to load zones: %v", err)
+	}
+	s.zones.Store(&zones)
+	if upstreams := splitList(*upstream); len(upstreams) > 0 {
+		for i, u := range upstreams {
+			if _, _, err := net.SplitHostPort(u); err != nil {
+				upstreams[i] = net.JoinHostPort(strings.Trim(u, "[]"), "53")
+			}
+		}
+		s.fwd = newForwarder(upstreams, *timeout, newCache(*cacheSize, *cacheMaxTTL))
+		log.Printf("↗️  Forwarding other names to %s", strings.Join(upstreams, ", "))
+	}
+
+	pc, err := net.ListenPacket("udp", *listen)
+	if err != nil {
+		log.Fatalf("Failed to listen on UDP %s: %v", *listen, err)
+	}
+	// The same port over TCP, for truncated replies and big ones
+	ln, err := net.Listen("tcp", pc.LocalAddr().String())
+	if err != nil {
+		log.Fatalf("Failed to listen on TCP %s: %v", *listen, err)
+	}
+	log.Printf("🚀 DNS server listening on %s (UDP and TCP)", pc.LocalAddr())
+	log.Printf("   Test with: go run ./06-dns-client www.lab.test @127.0.0.1:%d", pc.LocalAddr().(*net.UDPAddr).Port)
+	log.Println("   Press Ctrl+C to shutdown")
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	go reloadOnHUP(ctx, s, paths)
+	if *statsEvery > 0 {
+		go func() {
+			ticker := time.NewTicker(*statsEvery)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ticker.C:
+					log.Printf("📊 Stats: %s", s.stats.String())
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+
+	// The serve loops count themselves in, so the queries they start are
+	// counted before Wait can see zero
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		s.serveUDP(ctx, pc, &wg)
+	}()
+	go func() {
+		defer wg.Done()
+		s.serveTCP(ctx, ln, &wg)
+	}()
+
+	<-ctx.Done()
+	log.Println("🛑 Shutting down, finishing the queries in hand...")
+	pc.Close()
+	ln.Close()
+	wg.Wait()
+	log.Printf("📊 Final Stats: %s", s.stats.String())
+	if s.fwd != nil {
+		log.Printf("   %d answers cached", s.fwd.cache.len())
+	}
+}
+
+// loadZones loads every zone file, refusing two for the same origin
+func loadZones(paths []string) ([]*zone, error) {
+	var zones []*zone
+	seen := make(map[string]string)
+	for _, path := range paths {
+		z, err := loadZone(path)
+		if err != nil {
+			return nil, err
+		}
+		if other, ok := seen[z.origin]; ok {
+			return nil, fmt.Errorf("%s: zone %s is already loaded from %s", path, z.origin, other)
+		}
+		seen[z.origin] = path
+		log.Printf("📄 Loaded zone %s from %s: %d records, serial %d", z.origin, path, z.count, z.soa.Data.(*dnswire.SOA).Serial)
+		zones = append(zones, z)
+	}
+	return zones, nil
+}
+
+// reloadOnHUP loads the zone files again on SIGHUP, keeping the zones
+// it has if any of them fails to load
+func reloadOnHUP(ctx context.Context, s *server, paths []string) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+	for {
+		select {
+		case <-hup:
+			log.Println("🔄 SIGHUP: reloading zones")
+			zones, err := loadZones(paths)
+			if err != nil {
+				log.Printf("❌ Reload failed, still serving the old zones: %v", err)
+				continue
+			}
+			s.zones.Store(&zones)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func splitList(s string) []string {
+	var out []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			out = append(out, item)
+		}
+	}
+	return out
+}
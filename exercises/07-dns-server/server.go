@@ -0,0 +1,300 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/channyeintun/network-exercises/pkg/dnswire"
+)
+
+// server answers queries from its zones, or forwards them
+type server struct {
+	zones      atomic.Pointer[[]*zone] // swapped whole on reload
+	fwd        *forwarder              // nil: answer only for the zones
+	udpSize    uint16                  // the largest UDP reply we send with EDNS
+	nsid       string
+	idle       time.Duration // how long a TCP connection may sit between queries
+	logQueries bool
+	sem        chan struct{} // one slot per query being answered
+	stats      stats
+}
+
+// stats counts queries by how they came and how they were answered
+type stats struct {
+	udp, tcp      atomic.Uint64
+	authoritative atomic.Uint64
+	forwarded     atomic.Uint64
+	cached        atomic.Uint64
+	refused       atomic.Uint64
+	failed        atomic.Uint64 // SERVFAIL
+	malformed     atomic.Uint64 // FORMERR, NOTIMP and BADVERS
+	truncated     atomic.Uint64
+}
+
+func (s *stats) String() string {
+	return fmt.Sprintf("%d queries (%d UDP, %d TCP): %d authoritative, %d forwarded (%d from cache), %d refused, %d failed, %d malformed, %d truncated",
+		s.udp.Load()+s.tcp.Load(), s.udp.Load(), s.tcp.Load(), s.authoritative.Load(), s.forwarded.Load(),
+		s.cached.Load(), s.refused.Load(), s.failed.Load(), s.malformed.Load(), s.truncated.Load())
+}
+
+// handle answers one query, returning the packed reply, or nil when
+// there's nothing to send back
+func (s *server) handle(ctx context.Context, packet []byte, network string, from net.Addr) []byte {
+	start := time.Now()
+	query, err := dnswire.Parse(packet)
+	if err != nil {
+		if len(packet) < dnswire.HeaderLen || packet[2]&0x80 != 0 {
+			return nil // too short to answer, or a reply, which we never answer
+		}
+		// Tell the sender, by the ID they used, that we couldn't read it
+		s.stats.malformed.Add(1)
+		reply := &dnswire.Message{Header: dnswire.Header{
+			ID: uint16(packet[0])<<8 | uint16(packet[1]), Response: true, RCode: dnswire.RCodeFormatError,
+		}}
+		b, _ := reply.Pack()
+		return b
+	}
+	if query.Response {
+		return nil
+	}
+
+	reply := &dnswire.Message{
+		Header: dnswire.Header{
+			ID:                 query.ID,
+			Response:           true,
+			Opcode:             query.Opcode,
+			RecursionDesired:   query.RecursionDesired,
+			RecursionAvailable: s.fwd != nil,
+			CheckingDisabled:   query.CheckingDisabled,
+		},
+		Questions: query.Questions,
+	}
+	edns, hasEDNS := query.EDNS()
+	how := s.answer(ctx, query, edns, hasEDNS, reply)
+
+	if hasEDNS {
+		opts := []dnswire.Option(nil)
+		for _, o := range edns.Options {
+			if o.Code == dnswire.OptionNSID && s.nsid != "" {
+				opts = append(opts, dnswire.Option{Code: dnswire.OptionNSID, Data: []byte(s.nsid)})
+			}
+		}
+		reply.SetEDNS(dnswire.EDNS{UDPSize: s.udpSize, DO: edns.DO, Options: opts})
+	}
+	b, err := reply.Pack()
+	if err != nil {
+		log.Printf("❌ Packing the reply to %s: %v", from, err)
+		s.stats.failed.Add(1)
+		reply.Answers, reply.Authority, reply.Additional = nil, nil, nil
+		reply.RCode = dnswire.RCodeServerFailure
+		if b, err = reply.Pack(); err != nil {
+			return nil
+		}
+	}
+
+	// Over UDP a reply has to fit what the client said it takes: 512
+	// bytes without EDNS. If it doesn't, send the header and question
+	// with TC set, and the client asks again over TCP.
+	if network == "udp" {
+		limit := 512
+		if hasEDNS {
+			limit = max(512, int(min(edns.UDPSize, s.udpSize)))
+		}
+		if len(b) > limit {
+			s.stats.truncated.Add(1)
+			reply.Truncated = true
+			reply.Answers, reply.Authority = nil, nil
+			reply.Additional = slices.DeleteFunc(reply.Additional, func(rr dnswire.RR) bool { return rr.Type != dnswire.TypeOPT })
+			b, _ = reply.Pack()
+		}
+	}
+
+	if s.logQueries {
+		q := "(no question)"
+		if len(query.Questions) > 0 {
+			q = fmt.Sprintf("%s %s", query.Questions[0].Name, query.Questions[0].Type)
+		}
+		tc := ""
+		if reply.Truncated {
+			tc = ", truncated"
+		}
+		log.Printf("🔎 %s %s %s → %s, %d answers (%s%s) in %v",
+			from, network, q, reply.RCode, len(reply.Answers), how, tc, time.Since(start).Round(time.Microsecond))
+	}
+	return b
+}
+
+// answer fills in reply to query and says where the answer came from
+func (s *server) answer(ctx context.Context, query *dnswire.Message, edns dnswire.EDNS, hasEDNS bool, reply *dnswire.Message) string {
+	switch {
+	case query.Opcode != dnswire.OpcodeQuery:
+		s.stats.malformed.Add(1)
+		reply.RCode = dnswire.RCodeNotImplemented
+		return "not a query"
+	case len(query.Questions) != 1:
+		s.stats.malformed.Add(1)
+		reply.RCode = dnswire.RCodeFormatError
+		return "not one question"
+	case hasEDNS && edns.Version > 0:
+		// We speak EDNS version 0 only; the client may try again with it
+		s.stats.malformed.Add(1)
+		reply.RCode = dnswire.RCodeBadVersion
+		return "EDNS version"
+	}
+
+	q := query.Questions[0]
+	if q.Class == dnswire.ClassCHAOS {
+		return s.chaos(q, reply)
+	}
+	if q.Class == dnswire.ClassINET || q.Class == dnswire.ClassANY {
+		if z := findZone(*s.zones.Load(), q.Name); z != nil {
+			s.stats.authoritative.Add(1)
+			z.answer(q, reply)
+			return "zone " + z.origin
+		}
+	}
+	if s.fwd == nil || !query.RecursionDesired {
+		s.stats.refused.Add(1)
+		reply.RCode = dnswire.RCodeRefused
+		return "not ours"
+	}
+
+	msg, cached, err := s.fwd.resolve(ctx, q, hasEDNS && edns.DO)
+	if err != nil {
+		log.Printf("⚠️  Forwarding %s %s: %v", q.Name, q.Type, err)
+		s.stats.failed.Add(1)
+		reply.RCode = dnswire.RCodeServerFailure
+		return "upstream failed"
+	}
+	s.stats.forwarded.Add(1)
+	// The message may be shared with other clients and the cache, so
+	// copy what's taken from it rather than appending to its slices
+	reply.RCode = msg.RCode
+	reply.AuthenticData = msg.AuthenticData && hasEDNS && edns.DO
+	reply.Answers = slices.Clone(msg.Answers)
+	reply.Authority = slices.Clone(msg.Authority)
+	reply.Additional = slices.Clone(msg.Additional)
+	if cached {
+		s.stats.cached.Add(1)
+		return "cache"
+	}
+	return "upstream"
+}
+
+// chaos answers the CH TXT names servers identify themselves under:
+// version.bind, and hostname.bind or id.server
+func (s *server) chaos(q dnswire.Question, reply *dnswire.Message) string {
+	var text string
+	switch dnswire.CanonicalName(q.Name) {
+	case "version.bind.", "version.server.":
+		text = "07-dns-server"
+	case "hostname.bind.", "id.server.":
+		text = s.nsid
+		if text == "" {
+			text, _ = os.Hostname()
+		}
+	}
+	if text == "" || (q.Type != dnswire.TypeTXT && q.Type != dnswire.TypeANY) {
+		s.stats.refused.Add(1)
+		reply.RCode = dnswire.RCodeRefused
+		return "chaos"
+	}
+	reply.Authoritative = true
+	reply.Answers = []dnswire.RR{{Name: q.Name, Type: dnswire.TypeTXT, Class: dnswire.ClassCHAOS, Data: &dnswire.TXT{Text: []string{text}}}}
+	return "chaos"
+}
+
+// serveUDP answers datagrams until pc is closed, each in its own
+// goroutine so a slow upstream doesn't hold up the queries behind it
+func (s *server) serveUDP(ctx context.Context, pc net.PacketConn, wg *sync.WaitGroup) {
+	buf := make([]byte, 65535)
+	for {
+		n, addr, err := pc.ReadFrom(buf)
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			log.Printf("UDP read error: %v", err)
+			continue
+		}
+		s.stats.udp.Add(1)
+		packet := slices.Clone(buf[:n])
+		s.sem <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-s.sem }()
+			if reply := s.handle(ctx, packet, "udp", addr); reply != nil {
+				pc.WriteTo(reply, addr)
+			}
+		}()
+	}
+}
+
+// serveTCP accepts connections until ln is closed
+func (s *server) serveTCP(ctx context.Context, ln net.Listener, wg *sync.WaitGroup) {
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			log.Printf("TCP accept error: %v", err)
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.serveConn(ctx, conn)
+		}()
+	}
+}
+
+// serveConn answers the queries on one TCP connection. A client may
+// send several without waiting (RFC 7766), so each is answered in its
+// own goroutine and the replies go back in whatever order they're
+// ready, matched up by ID.
+func (s *server) serveConn(ctx context.Context, conn net.Conn) {
+	defer conn.Close()
+	var (
+		writeMu sync.Mutex
+		queries sync.WaitGroup
+	)
+	defer queries.Wait()
+	stop := context.AfterFunc(ctx, func() { conn.SetReadDeadline(time.Now()) })
+	defer stop()
+
+	for {
+		conn.SetReadDeadline(time.Now().Add(s.idle))
+		if ctx.Err() != nil {
+			return
+		}
+		packet, err := dnswire.ReadTCP(conn)
+		if err != nil {
+			return // closed, idle too long, or cut off mid-message
+		}
+		s.stats.tcp.Add(1)
+		s.sem <- struct{}{}
+		queries.Add(1)
+		go func() {
+			defer queries.Done()
+			defer func() { <-s.sem }()
+			reply := s.handle(ctx, packet, "tcp", conn.RemoteAddr())
+			if reply == nil {
+				return
+			}
+			writeMu.Lock()
+			defer writeMu.Unlock()
+			conn.SetWriteDeadline(time.Now().Add(s.idle))
+			dnswire.WriteTCP(conn, reply)
+		}()
+	}
+}
@@ -0,0 +1,526 @@
+package main
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/netip"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/channyeintun/network-exercises/pkg/dnswire"
+)
+
+// zone is one zone's records, loaded from a master file (RFC 1035
+// section 5), that the server answers for with authority
+type zone struct {
+	origin string // the apex, canonical: "lab.test."
+	soa    dnswire.RR
+	// rrsets holds the records by canonical owner name, then type
+	rrsets map[string]map[dnswire.Type][]dnswire.RR
+	// names holds every name that exists: the owners, and the names
+	// between them and the apex with nothing of their own (empty
+	// non-terminals), which answer NODATA rather than NXDOMAIN
+	names map[string]bool
+	count int // records, for the log
+}
+
+// field is one word of a master file line, or one "quoted string"
+type field struct {
+	text   string
+	quoted bool
+}
+
+// entry is one logical line of a master file: parentheses let an entry
+// run over several lines
+type entry struct {
+	line int
+	// blankOwner is set when the line starts with white space: the
+	// entry belongs to the previous entry's owner
+	blankOwner bool
+	fields     []field
+}
+
+// loadZone reads and checks a master file
+func loadZone(path string) (*zone, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	z, err := parseZone(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return z, nil
+}
+
+// parseZone parses a master file's records. It knows $ORIGIN and $TTL,
+// relative names and @, owners, TTLs and classes left out to mean the
+// previous ones, TTLs with units (1h30m), and the record types dnswire
+// decodes, plus any other type in RFC 3597's \# form.
+func parseZone(data string) (*zone, error) {
+	entries, err := splitEntries(data)
+	if err != nil {
+		return nil, err
+	}
+
+	z := &zone{rrsets: make(map[string]map[dnswire.Type][]dnswire.RR), names: make(map[string]bool)}
+	var (
+		origin     string // "" until $ORIGIN or an absolute SOA owner
+		defaultTTL int64  = -1
+		lastTTL    int64  = -1
+		lastOwner  string
+		records    []dnswire.RR
+	)
+	for _, e := range entries {
+		fail := func(format string, args ...any) error {
+			return fmt.Errorf("line %d: %s", e.line, fmt.Sprintf(format, args...))
+		}
+		f := e.fields
+
+		if d := f[0].text; strings.HasPrefix(d, "$") && !f[0].quoted {
+			switch strings.ToUpper(d) {
+			case "$ORIGIN":
+				if len(f) != 2 {
+					return nil, fail("$ORIGIN wants one name")
+				}
+				if origin, err = absolute(f[1].text, origin); err != nil {
+					return nil, fail("%v", err)
+				}
+			case "$TTL":
+				if len(f) != 2 {
+					return nil, fail("$TTL wants one TTL")
+				}
+				ttl, err := parseTTL(f[1].text)
+				if err != nil {
+					return nil, fail("%v", err)
+				}
+				defaultTTL = int64(ttl)
+			default:
+				return nil, fail("%s isn't supported", d)
+			}
+			continue
+		}
+
+		rr := dnswire.RR{Class: dnswire.ClassINET}
+		if e.blankOwner {
+			if lastOwner == "" {
+				return nil, fail("no owner name, and no record before this one to take it from")
+			}
+			rr.Name = lastOwner
+		} else {
+			if rr.Name, err = absolute(f[0].text, origin); err != nil {
+				return nil, fail("%v", err)
+			}
+			f = f[1:]
+		}
+		lastOwner = rr.Name
+
+		// [TTL] [class] type, the first two either way round
+		ttl := int64(-1)
+		for range 2 {
+			if len(f) == 0 {
+				break
+			}
+			if c, err := dnswire.ParseClass(f[0].text); err == nil {
+				if c != dnswire.ClassINET {
+					return nil, fail("only class IN is served, not %s", f[0].text)
+				}
+				f = f[1:]
+			} else if t, err := parseTTL(f[0].text); err == nil {
+				ttl = int64(t)
+				f = f[1:]
+			} else {
+				break
+			}
+		}
+		if len(f) == 0 {
+			return nil, fail("no record type")
+		}
+		if rr.Type, err = dnswire.ParseType(f[0].text); err != nil {
+			return nil, fail("%v", err)
+		}
+		switch {
+		case ttl >= 0:
+		case defaultTTL >= 0:
+			ttl = defaultTTL
+		case lastTTL >= 0:
+			ttl = lastTTL
+		default:
+			return nil, fail("no TTL, and no $TTL or record before this one to take it from")
+		}
+		rr.TTL, lastTTL = uint32(ttl), ttl
+
+		if rr.Data, err = parseRData(rr.Type, f[1:], origin); err != nil {
+			return nil, fail("%s %s: %v", rr.Name, rr.Type, err)
+		}
+		if rr.Type == dnswire.TypeSOA && origin == "" {
+			origin = rr.Name
+		}
+		records = append(records, rr)
+	}
+	return z, z.add(records)
+}
+
+// add indexes the records, checking the zone makes sense: one SOA at
+// the apex, everything under it, and nothing beside a CNAME
+func (z *zone) add(records []dnswire.RR) error {
+	for _, rr := range records {
+		if rr.Type != dnswire.TypeSOA {
+			continue
+		}
+		if z.origin != "" {
+			return fmt.Errorf("a second SOA, at %s", rr.Name)
+		}
+		z.origin, z.soa = dnswire.CanonicalName(rr.Name), rr
+	}
+	if z.origin == "" {
+		return errors.New("no SOA record: a zone starts with one at its apex")
+	}
+
+	for _, rr := range records {
+		name := dnswire.CanonicalName(rr.Name)
+		if !dnswire.IsSubdomain(name, z.origin) {
+			return fmt.Errorf("%s is outside the zone %s", rr.Name, z.origin)
+		}
+		if z.rrsets[name] == nil {
+			z.rrsets[name] = make(map[dnswire.Type][]dnswire.RR)
+		}
+		z.rrsets[name][rr.Type] = append(z.rrsets[name][rr.Type], rr)
+		z.count++
+		for n := name; !z.names[n]; n, _ = dnswire.Parent(n) {
+			z.names[n] = true
+			if n == z.origin {
+				break
+			}
+		}
+	}
+	for name, rrsets := range z.rrsets {
+		if cnames := len(rrsets[dnswire.TypeCNAME]); cnames > 1 || cnames == 1 && len(rrsets) > 1 {
+			return fmt.Errorf("%s has a CNAME and other records; a CNAME must be alone", name)
+		}
+	}
+	return nil
+}
+
+// splitEntries cuts a master file into entries of fields, dropping
+// comments and joining lines inside parentheses
+func splitEntries(data string) ([]entry, error) {
+	var (
+		entries   []entry
+		cur       entry
+		word      strings.Builder
+		inWord    bool
+		depth     int
+		line      = 1
+		lineStart = true
+	)
+	flush := func() {
+		if inWord {
+			if len(cur.fields) == 0 {
+				cur.line = line
+			}
+			cur.fields = append(cur.fields, field{text: word.String()})
+			word.Reset()
+			inWord = false
+		}
+	}
+	for i := 0; i < len(data); i++ {
+		c := data[i]
+		startOfLine := lineStart
+		lineStart = false
+		switch c {
+		case '\n':
+			flush()
+			if depth == 0 {
+				if len(cur.fields) > 0 {
+					entries = append(entries, cur)
+				}
+				cur = entry{}
+			}
+			line++
+			lineStart = true
+		case ';':
+			flush()
+			for i+1 < len(data) && data[i+1] != '\n' {
+				i++
+			}
+		case ' ', '\t', '\r':
+			flush()
+			if startOfLine && depth == 0 && c != '\r' {
+				cur.blankOwner = true
+			}
+		case '(':
+			flush()
+			depth++
+		case ')':
+			flush()
+			if depth == 0 {
+				return nil, fmt.Errorf("line %d: ) without (", line)
+			}
+			depth--
+		case '"':
+			flush()
+			s, n, err := readQuoted(data[i+1:])
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", line, err)
+			}
+			if len(cur.fields) == 0 {
+				cur.line = line
+			}
+			cur.fields = append(cur.fields, field{text: s, quoted: true})
+			i += n
+		default:
+			word.WriteByte(c)
+			inWord = true
+			if c == '\\' && i+1 < len(data) {
+				// Keep the escape for the name parser, but don't let
+				// it end the word or open a comment
+				i++
+				word.WriteByte(data[i])
+			}
+		}
+	}
+	flush()
+	if depth != 0 {
+		return nil, errors.New("( without ) at the end of the file")
+	}
+	if len(cur.fields) > 0 {
+		entries = append(entries, cur)
+	}
+	return entries, nil
+}
+
+// readQuoted reads a quoted string's contents up to its closing quote,
+// resolving \X and \DDD escapes, and returns how many bytes it used
+func readQuoted(s string) (string, int, error) {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '"':
+			return b.String(), i + 1, nil
+		case '\n':
+			return "", 0, errors.New("a quoted string runs past the end of its line")
+		case '\\':
+			if i+3 < len(s) && isDigits(s[i+1:i+4]) {
+				n, _ := strconv.Atoi(s[i+1 : i+4])
+				if n > 255 {
+					return "", 0, fmt.Errorf("escape \\%s is more than a byte", s[i+1:i+4])
+				}
+				b.WriteByte(byte(n))
+				i += 3
+			} else if i+1 < len(s) {
+				b.WriteByte(s[i+1])
+				i++
+			}
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return "", 0, errors.New("a quoted string has no closing quote")
+}
+
+func isDigits(s string) bool {
+	for i := range len(s) {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return s != ""
+}
+
+// absolute makes a master file name fully qualified: @ is the origin,
+// and a name without a trailing dot is relative to it
+func absolute(name, origin string) (string, error) {
+	if name == "@" {
+		if origin == "" {
+			return "", errors.New("@ used before $ORIGIN")
+		}
+		return origin, nil
+	}
+	if dnswire.Fqdn(name) == name {
+		return name, nil
+	}
+	if origin == "" {
+		return "", fmt.Errorf("relative name %s used before $ORIGIN", name)
+	}
+	if origin == "." {
+		return name + ".", nil
+	}
+	return name + "." + origin, nil
+}
+
+// parseTTL reads a TTL in seconds, or in units as BIND writes them:
+// 1w, 2d, 1h30m, 90s
+func parseTTL(s string) (uint32, error) {
+	if isDigits(s) {
+		n, err := strconv.ParseUint(s, 10, 32)
+		return uint32(n), err
+	}
+	var total, n uint64
+	digits := false
+	for i := range len(s) {
+		c := s[i]
+		if '0' <= c && c <= '9' {
+			n, digits = n*10+uint64(c-'0'), true
+			continue
+		}
+		unit, ok := map[byte]uint64{'s': 1, 'm': 60, 'h': 3600, 'd': 86400, 'w': 604800}[c|0x20]
+		if !ok || !digits {
+			return 0, fmt.Errorf("bad TTL %q", s)
+		}
+		total, n, digits = total+n*unit, 0, false
+	}
+	if digits || total > 1<<31-1 {
+		return 0, fmt.Errorf("bad TTL %q", s)
+	}
+	return uint32(total), nil
+}
+
+// parseRData reads a record's data from its presentation form
+func parseRData(t dnswire.Type, f []field, origin string) (dnswire.RData, error) {
+	text := make([]string, len(f))
+	for i := range f {
+		text[i] = f[i].text
+	}
+	if len(f) > 0 && f[0].text == `\#` && !f[0].quoted {
+		return parseUnknown(text[1:])
+	}
+	want := func(n int) error {
+		if len(f) != n {
+			return fmt.Errorf("want %d fields, got %d", n, len(f))
+		}
+		return nil
+	}
+	name := func(i int) (string, error) { return absolute(text[i], origin) }
+	num := func(i int) (uint16, error) {
+		n, err := strconv.ParseUint(text[i], 10, 16)
+		return uint16(n), err
+	}
+
+	switch t {
+	case dnswire.TypeA, dnswire.TypeAAAA:
+		if err := want(1); err != nil {
+			return nil, err
+		}
+		ip, err := netip.ParseAddr(text[0])
+		if err != nil {
+			return nil, err
+		}
+		if t == dnswire.TypeA {
+			if !ip.Is4() {
+				return nil, fmt.Errorf("%s isn't an IPv4 address", ip)
+			}
+			return &dnswire.A{Addr: ip}, nil
+		}
+		if !ip.Is6() || ip.Is4In6() {
+			return nil, fmt.Errorf("%s isn't an IPv6 address", ip)
+		}
+		return &dnswire.AAAA{Addr: ip}, nil
+	case dnswire.TypeNS, dnswire.TypeCNAME, dnswire.TypePTR:
+		if err := want(1); err != nil {
+			return nil, err
+		}
+		host, err := name(0)
+		if err != nil {
+			return nil, err
+		}
+		switch t {
+		case dnswire.TypeNS:
+			return &dnswire.NS{Host: host}, nil
+		case dnswire.TypeCNAME:
+			return &dnswire.CNAME{Target: host}, nil
+		}
+		return &dnswire.PTR{Host: host}, nil
+	case dnswire.TypeMX:
+		if err := want(2); err != nil {
+			return nil, err
+		}
+		pref, err := num(0)
+		if err != nil {
+			return nil, err
+		}
+		host, err := name(1)
+		return &dnswire.MX{Preference: pref, Host: host}, err
+	case dnswire.TypeTXT:
+		if len(f) == 0 {
+			return nil, errors.New("want at least one string")
+		}
+		for _, s := range text {
+			if len(s) > 255 {
+				return nil, errors.New("a TXT string is over 255 bytes; split it into several")
+			}
+		}
+		return &dnswire.TXT{Text: text}, nil
+	case dnswire.TypeSRV:
+		if err := want(4); err != nil {
+			return nil, err
+		}
+		var nums [3]uint16
+		for i := range nums {
+			n, err := num(i)
+			if err != nil {
+				return nil, err
+			}
+			nums[i] = n
+		}
+		target, err := name(3)
+		return &dnswire.SRV{Priority: nums[0], Weight: nums[1], Port: nums[2], Target: target}, err
+	case dnswire.TypeSOA:
+		if err := want(7); err != nil {
+			return nil, err
+		}
+		soa := &dnswire.SOA{}
+		var err error
+		if soa.MName, err = name(0); err != nil {
+			return nil, err
+		}
+		if soa.RName, err = name(1); err != nil {
+			return nil, err
+		}
+		serial, err := strconv.ParseUint(text[2], 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("bad serial: %w", err)
+		}
+		soa.Serial = uint32(serial)
+		for i, p := range []*uint32{&soa.Refresh, &soa.Retry, &soa.Expire, &soa.MinTTL} {
+			if *p, err = parseTTL(text[3+i]); err != nil {
+				return nil, err
+			}
+		}
+		return soa, nil
+	}
+	return nil, fmt.Errorf("%s records aren't known; write them as \\# length hex", t)
+}
+
+// parseUnknown reads RFC 3597's generic form: \# 4 c0000201
+func parseUnknown(text []string) (dnswire.RData, error) {
+	if len(text) == 0 {
+		return nil, errors.New(`\# wants a length`)
+	}
+	n, err := strconv.Atoi(text[0])
+	if err != nil {
+		return nil, fmt.Errorf(`bad \# length: %w`, err)
+	}
+	data, err := hex.DecodeString(strings.Join(text[1:], ""))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) != n {
+		return nil, fmt.Errorf(`\# says %d bytes but has %d`, n, len(data))
+	}
+	return &dnswire.Unknown{Data: data}, nil
+}
+
+// types returns a name's record types in order, for answers that list
+// them all
+func types(rrsets map[dnswire.Type][]dnswire.RR) []dnswire.Type {
+	ts := make([]dnswire.Type, 0, len(rrsets))
+	for t := range rrsets {
+		ts = append(ts, t)
+	}
+	sort.Slice(ts, func(i, j int) bool { return ts[i] < ts[j] })
+	return ts
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/channyeintun/network-exercises/pkg/healthcheck"
+)
+
+// checker checks backends with exercise 05's tcp and http checks (from
+// pkg/healthcheck): connect and hang up, or GET a path and want a status
+// in expect
+type checker struct {
+	kind     string // "tcp", "http" or "none"
+	path     string // for http
+	expect   healthcheck.StatusSet
+	interval time.Duration
+	timeout  time.Duration
+	th       thresholds
+	client   *http.Client
+}
+
+// watch checks b now and every interval until ctx is cancelled. With
+// checks off a backend is up from the start.
+func (c *checker) watch(ctx context.Context, b *backend) {
+	if c.kind == "none" {
+		b.observe(true, nil, c.th)
+		return
+	}
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+	for {
+		err := c.check(ctx, b.addr)
+		if ctx.Err() != nil {
+			return // stopped mid-check: the result says nothing
+		}
+		b.observe(err == nil, err, c.th)
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// check checks addr once
+func (c *checker) check(ctx context.Context, addr string) error {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+	if c.kind == "tcp" {
+		var d net.Dialer
+		return healthcheck.TCP(ctx, d.DialContext, addr)
+	}
+	if err := healthcheck.HTTP(ctx, c.client, "http://"+addr+c.path, c.expect); err != nil {
+		return fmt.Errorf("GET %s: %w", c.path, err)
+	}
+	return nil
+}
+
+// healthAPI takes backends' states from a running exercise 05 health
+// checker instead of checking them itself: its -ui status API lists
+// every endpoint's state after its failure and success thresholds
+type healthAPI struct {
+	url      string // the checker's -ui address, e.g. http://127.0.0.1:8080
+	interval time.Duration
+	client   *http.Client
+}
+
+// apiEndpoint is the part of an endpoint in /api/status used here
+type apiEndpoint struct {
+	Name  string `json:"name"`
+	URL   string `json:"url"`
+	State string `json:"state"`
+}
+
+// follow polls the status API every interval until ctx is cancelled,
+// matching each backend to the endpoint named after its address or
+// checking it (tcp://host:port, or an http URL on host:port). While the
+// API can't be reached backends keep the states they have.
+func (h *healthAPI) follow(ctx context.Context, p *pool) {
+	ticker := time.NewTicker(h.interval)
+	defer ticker.Stop()
+	warned := make(map[string]bool)
+	unreachable := false
+	for {
+		endpoints, err := h.status(ctx)
+		switch {
+		case err != nil && !unreachable && ctx.Err() == nil:
+			log.Printf("⚠️  Health checker at %s: %v; keeping the backends' states until it answers", h.url, err)
+			unreachable = true
+		case err == nil && unreachable:
+			log.Printf("✅ Health checker at %s answers again", h.url)
+			unreachable = false
+		}
+		if err == nil {
+			h.apply(endpoints, p.list(), warned)
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// apply sets each backend's state from the endpoint it matches. One
+// that doesn't match any is up, as nothing says otherwise; warned
+// remembers which have been logged.
+func (h *healthAPI) apply(endpoints []apiEndpoint, backends []*backend, warned map[string]bool) {
+	for _, b := range backends {
+		ep, ok := match(endpoints, b.addr)
+		switch {
+		case !ok:
+			if !warned[b.addr] {
+				log.Printf("⚠️  %s isn't among the health checker's endpoints; treating it as up", b.addr)
+				warned[b.addr] = true
+			}
+			b.set(true, "not checked by "+h.url)
+		case ep.State == "up" || ep.State == "degraded":
+			b.set(true, fmt.Sprintf("%q is %s", ep.Name, ep.State))
+		case ep.State == "down":
+			b.set(false, fmt.Sprintf("%q is down", ep.Name))
+		}
+		// pending, skipped and remote say nothing either way
+	}
+}
+
+func (h *healthAPI) status(ctx context.Context) ([]apiEndpoint, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(h.url, "/")+"/api/status", nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("/api/status: %s", resp.Status)
+	}
+	var body struct {
+		Endpoints []apiEndpoint `json:"endpoints"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("/api/status: %w", err)
+	}
+	return body.Endpoints, nil
+}
+
+// match finds the endpoint for a backend: by name first, then by the
+// host:port its target has
+func match(endpoints []apiEndpoint, addr string) (apiEndpoint, bool) {
+	for _, ep := range endpoints {
+		if ep.Name == addr {
+			return ep, true
+		}
+	}
+	for _, ep := range endpoints {
+		target := ep.URL
+		if _, rest, ok := strings.Cut(target, "://"); ok {
+			target = rest
+		}
+		if host, _, _ := strings.Cut(target, "/"); host == addr {
+			return ep, true
+		}
+	}
+	return apiEndpoint{}, false
+}
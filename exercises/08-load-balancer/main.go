@@ -0,0 +1,313 @@
+// Package main implements a layer-4 (TCP) load balancer.
+// This exercise ties exercise 01's TCP servers to exercise 05's health checks.
+//
+// Learning objectives:
+// - Proxy TCP connections to a pool of backends, copying both ways with half-closes
+// - Pick backends round robin, by fewest connections, or by client IP hash
+// - Take unhealthy backends out with active and passive health checks
+// - Drain a removed backend: no new connections, and time for the open ones to finish
+//
+// Run: go run . -backends 127.0.0.1:9001,127.0.0.1:9002
+// Test: go run ../01-tcp-echo -addr :9001 & go run ../01-tcp-echo -addr :9002 &  nc localhost 7000
+// Or:  go run . -backends-file backends.txt   (one host:port per line; kill -HUP after editing drains removed ones)
+// Or:  go run . -backends 10.0.0.5:80,10.0.0.6:80 -check http -check-path /healthz -policy least-conn
+// Or:  go run . -backends 127.0.0.1:9001,127.0.0.1:9002 -policy source-hash   (a client IP sticks to one backend)
+// Or:  go run . -backends 127.0.0.1:9001,127.0.0.1:9002 -health-api http://127.0.0.1:8080   (follow exercise 05's -ui)
+// Or:  go run . -backends 127.0.0.1:9001 -admin :7001   (GET /backends; PUT or DELETE /backends/{addr})
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/channyeintun/network-exercises/pkg/healthcheck"
+)
+
+func main() {
+	listen := flag.String("listen", ":7000", "Address to accept connections on")
+	backendList := flag.String("backends", "", "Comma-separated backends, host:port")
+	backendsFile := flag.String("backends-file", "", "File of backends, one host:port per line (# comments); reloaded on SIGHUP")
+	policy := flag.String("policy", "round-robin", "How to pick a backend: round-robin, least-conn or source-hash")
+	checkKind := flag.String("check", "tcp", "Health check: tcp (connect), http (GET -check-path, want -check-status) or none")
+	checkPath := flag.String("check-path", "/", "Path for -check http")
+	checkStatus := flag.String("check-status", "2xx,3xx", "Statuses -check http accepts, as exercise 05's expected_status: codes, ranges (200-299) or classes (2xx)")
+	interval := flag.Duration("interval", 5*time.Second, "How often to check each backend, or poll -health-api")
+	checkTimeout := flag.Duration("check-timeout", 2*time.Second, "How long a check may take")
+	failureThreshold := flag.Int("failure-threshold", 3, "Failed checks in a row that take a backend out")
+	successThreshold := flag.Int("success-threshold", 2, "Good checks in a row that put it back")
+	healthAPIURL := flag.String("health-api", "", "Take backends' states from a running 05-health-checker's -ui, e.g. http://127.0.0.1:8080, instead of -check")
+	dialTimeout := flag.Duration("dial-timeout", 3*time.Second, "How long to wait for a backend to accept before trying another")
+	drainTimeout := flag.Duration("drain-timeout", 30*time.Second, "How long a removed backend's connections may stay open, and how long shutdown waits")
+	adminAddr := flag.String("admin", "", "Serve the admin API on this address, e.g. :7001")
+	statsEvery := flag.Duration("stats", time.Minute, "Log each backend's connections this often; 0 to only log them at shutdown")
+	verbose := flag.Bool("v", false, "Log every connection")
+	flag.Parse()
+
+	switch *policy {
+	case "round-robin", "least-conn", "source-hash":
+	default:
+		log.Fatalf("Unknown -policy %q: want round-robin, least-conn or source-hash", *policy)
+	}
+	switch *checkKind {
+	case "tcp", "http", "none":
+	default:
+		log.Fatalf("Unknown -check %q: want tcp, http or none", *checkKind)
+	}
+	expect, err := healthcheck.ParseStatus(*checkStatus)
+	if err != nil {
+		log.Fatalf("Invalid -check-status: %v", err)
+	}
+	if *failureThreshold < 1 || *successThreshold < 1 {
+		log.Fatal("-failure-threshold and -success-threshold must be at least 1")
+	}
+	if (*backendList == "") == (*backendsFile == "") {
+		log.Fatal("Give the backends with -backends or -backends-file")
+	}
+	load := func() ([]string, error) {
+		if *backendsFile != "" {
+			return readBackends(*backendsFile)
+		}
+		return parseBackends(strings.Split(*backendList, ","))
+	}
+	addrs, err := load()
+	if err != nil {
+		log.Fatalf("Failed to load backends: %v", err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	th := thresholds{failure: *failureThreshold, success: *successThreshold}
+	lb := &balancer{
+		pool:        &pool{policy: *policy},
+		dialTimeout: *dialTimeout,
+		th:          th,
+		verbose:     *verbose,
+	}
+	c := &checker{
+		kind: *checkKind, path: *checkPath, expect: expect, interval: *interval, timeout: *checkTimeout, th: th,
+		client: &http.Client{Timeout: *checkTimeout, CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }},
+	}
+	if *healthAPIURL != "" {
+		h := &healthAPI{url: *healthAPIURL, interval: *interval, client: &http.Client{Timeout: *checkTimeout}}
+		go h.follow(ctx, lb.pool)
+		log.Printf("🩺 Following backends' states from the health checker at %s", *healthAPIURL)
+	} else {
+		log.Printf("🩺 Checking backends (%s) every %v: out after %d failures, back after %d passes", *checkKind, *interval, th.failure, th.success)
+	}
+
+	// apply puts addrs in service: checking new backends (unless
+	// -health-api does), and draining removed ones in the background
+	var draining sync.WaitGroup
+	apply := func(addrs []string) {
+		added, removed := lb.pool.update(ctx, addrs)
+		for _, b := range added {
+			log.Printf("➕ %s added", b.addr)
+			if *healthAPIURL == "" {
+				go c.watch(b.ctx, b)
+			}
+		}
+		for _, b := range removed {
+			draining.Add(1)
+			go func() {
+				defer draining.Done()
+				b.drain(*drainTimeout)
+			}()
+		}
+	}
+	apply(addrs)
+
+	ln, err := net.Listen("tcp", *listen)
+	if err != nil {
+		log.Fatalf("Failed to listen on %s: %v", *listen, err)
+	}
+	log.Printf("🚀 Load balancer listening on %s, %s across %d backends", *listen, *policy, len(addrs))
+	log.Println("   Press Ctrl+C to shutdown")
+	go lb.serve(ctx, ln)
+
+	if *backendsFile != "" {
+		go func() {
+			hup := make(chan os.Signal, 1)
+			signal.Notify(hup, syscall.SIGHUP)
+			defer signal.Stop(hup)
+			for {
+				select {
+				case <-hup:
+					log.Printf("🔄 SIGHUP: reloading %s", *backendsFile)
+					addrs, err := load()
+					if err != nil {
+						log.Printf("❌ Reload failed, keeping the backends: %v", err)
+						continue
+					}
+					apply(addrs)
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+	if *adminAddr != "" {
+		go serveAdmin(ctx, *adminAddr, lb.pool, apply)
+	}
+	if *statsEvery > 0 {
+		go func() {
+			ticker := time.NewTicker(*statsEvery)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ticker.C:
+					logStats(lb.pool)
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+
+	<-ctx.Done()
+	log.Printf("🛑 Shutting down: draining connections for up to %v...", *drainTimeout)
+	ln.Close()
+	logStats(lb.pool)
+	apply(nil)
+	draining.Wait()
+	lb.conns.Wait()
+	log.Println("✅ Load balancer shutdown complete")
+}
+
+// readBackends reads a backends file: one host:port per line, with
+// blank lines and # comments skipped
+func readBackends(path string) ([]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var lines []string
+	for _, line := range strings.Split(string(data), "\n") {
+		line, _, _ = strings.Cut(line, "#")
+		lines = append(lines, line)
+	}
+	addrs, err := parseBackends(lines)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return addrs, nil
+}
+
+// parseBackends checks each backend is host:port, dropping blanks and
+// repeats
+func parseBackends(list []string) ([]string, error) {
+	var addrs []string
+	seen := make(map[string]bool)
+	for _, addr := range list {
+		addr = strings.TrimSpace(addr)
+		if addr == "" || seen[addr] {
+			continue
+		}
+		if _, _, err := net.SplitHostPort(addr); err != nil {
+			return nil, fmt.Errorf("backend %q: %v", addr, err)
+		}
+		seen[addr] = true
+		addrs = append(addrs, addr)
+	}
+	if len(addrs) == 0 {
+		return nil, fmt.Errorf("no backends")
+	}
+	return addrs, nil
+}
+
+func logStats(p *pool) {
+	log.Println("📊 Stats:")
+	for _, b := range p.list() {
+		log.Printf("   %-21s %-8s %d open, %d total, %d failed, %d bytes in, %d out",
+			b.addr, b.state(), b.active(), b.total.Load(), b.failed.Load(), b.bytesIn.Load(), b.bytesOut.Load())
+	}
+}
+
+// backendJSON is one backend as the admin API reports it
+type backendJSON struct {
+	Address   string `json:"address"`
+	State     string `json:"state"` // "up", "down", "pending" before its first check, or "draining"
+	Active    int    `json:"active"`
+	Total     uint64 `json:"total"`
+	Failed    uint64 `json:"failed"`
+	BytesIn   uint64 `json:"bytes_in"`
+	BytesOut  uint64 `json:"bytes_out"`
+	LastError string `json:"last_error,omitempty"`
+}
+
+// serveAdmin serves the admin API until ctx is cancelled: GET /backends
+// lists them, PUT /backends/{addr} adds one and DELETE drains one out
+func serveAdmin(ctx context.Context, addr string, p *pool, apply func([]string)) {
+	var mu sync.Mutex // one change at a time
+	addrs := func() []string {
+		var list []string
+		for _, b := range p.list() {
+			list = append(list, b.addr)
+		}
+		return list
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /backends", func(w http.ResponseWriter, r *http.Request) {
+		list := []backendJSON{}
+		for _, b := range p.list() {
+			b.mu.Lock()
+			lastErr := b.lastErr
+			b.mu.Unlock()
+			list = append(list, backendJSON{
+				Address: b.addr, State: b.state(), Active: b.active(), Total: b.total.Load(), Failed: b.failed.Load(),
+				BytesIn: b.bytesIn.Load(), BytesOut: b.bytesOut.Load(), LastError: lastErr,
+			})
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{"backends": list})
+	})
+	mux.HandleFunc("PUT /backends/{addr}", func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		list, err := parseBackends(append(addrs(), r.PathValue("addr")))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		apply(list)
+		w.WriteHeader(http.StatusNoContent)
+	})
+	mux.HandleFunc("DELETE /backends/{addr}", func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		var list []string
+		for _, a := range addrs() {
+			if a != r.PathValue("addr") {
+				list = append(list, a)
+			}
+		}
+		if len(list) == len(addrs()) {
+			http.Error(w, "no such backend", http.StatusNotFound)
+			return
+		}
+		apply(list) // drains it in the background
+		w.WriteHeader(http.StatusAccepted)
+	})
+
+	srv := &http.Server{Addr: addr, Handler: mux}
+	go func() {
+		<-ctx.Done()
+		srv.Close()
+	}()
+	log.Printf("🛠️  Admin API on %s: GET /backends, PUT or DELETE /backends/{addr}", addr)
+	if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		log.Printf("❌ Admin API: %v", err)
+	}
+}
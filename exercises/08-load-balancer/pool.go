@@ -0,0 +1,291 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/channyeintun/network-exercises/pkg/rendezvous"
+)
+
+// backend is one server connections are balanced across
+type backend struct {
+	addr string
+	ctx  context.Context // cancelled once it's removed, stopping its checks
+	stop context.CancelFunc
+
+	mu       sync.Mutex
+	checked  bool // has a state yet; until then it gets no connections
+	healthy  bool // the state, which moves only once a threshold is met
+	streak   int  // passes (above 0) or failures (below 0) in a row
+	lastErr  string
+	draining bool                  // removed: no new connections, the open ones finish
+	conns    map[net.Conn]net.Conn // connections to it being proxied, to their clients
+	drained  chan struct{}         // closed once draining with no connections left
+
+	total    atomic.Uint64 // connections proxied
+	failed   atomic.Uint64 // connections it refused or didn't answer
+	bytesIn  atomic.Uint64 // from clients to it
+	bytesOut atomic.Uint64 // from it to clients
+}
+
+func newBackend(ctx context.Context, addr string) *backend {
+	b := &backend{addr: addr, conns: make(map[net.Conn]net.Conn), drained: make(chan struct{})}
+	b.ctx, b.stop = context.WithCancel(ctx)
+	return b
+}
+
+// state names the backend's state for logs and the admin API
+func (b *backend) state() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch {
+	case b.draining:
+		return "draining"
+	case !b.checked:
+		return "pending"
+	case b.healthy:
+		return "up"
+	}
+	return "down"
+}
+
+// available reports whether new connections may go to the backend
+func (b *backend) available() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.checked && b.healthy && !b.draining
+}
+
+// active returns how many connections to it are open
+func (b *backend) active() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.conns)
+}
+
+// track counts a new connection to the backend for client, and reports
+// false if it started draining since it was picked
+func (b *backend) track(conn, client net.Conn) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.draining {
+		return false
+	}
+	b.conns[conn] = client
+	return true
+}
+
+// release forgets a finished connection
+func (b *backend) release(conn net.Conn) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.conns, conn)
+	if b.draining && len(b.conns) == 0 {
+		close(b.drained)
+	}
+}
+
+// drain stops new connections to the backend and waits for the open
+// ones to finish, closing any still open after timeout
+func (b *backend) drain(timeout time.Duration) {
+	b.stop()
+	b.mu.Lock()
+	if b.draining {
+		b.mu.Unlock()
+		<-b.drained
+		return
+	}
+	b.draining = true
+	open := len(b.conns)
+	if open == 0 {
+		close(b.drained)
+	}
+	b.mu.Unlock()
+	if open == 0 {
+		log.Printf("➖ %s removed", b.addr)
+		return
+	}
+
+	log.Printf("⏳ %s draining: %d connections open, waiting up to %v", b.addr, open, timeout)
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-b.drained:
+		log.Printf("➖ %s drained and removed", b.addr)
+	case <-timer.C:
+		b.mu.Lock()
+		left := len(b.conns)
+		for conn, client := range b.conns {
+			conn.Close() // its proxy goroutine releases it
+			client.Close()
+		}
+		b.mu.Unlock()
+		<-b.drained
+		log.Printf("➖ %s removed after %v, cutting %d connections", b.addr, timeout, left)
+	}
+}
+
+// observe records a health check's result, or a failed connection's,
+// moving the state once failures or successes in a row reach their
+// threshold. A backend's first result sets its state straight away.
+func (b *backend) observe(ok bool, err error, th thresholds) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.draining {
+		return
+	}
+	if err != nil {
+		b.lastErr = err.Error()
+	}
+	if !b.checked {
+		b.checked, b.healthy, b.streak = true, ok, 1
+		if ok {
+			log.Printf("🟢 %s is up", b.addr)
+		} else {
+			b.streak = -1
+			log.Printf("🔴 %s is down: %s", b.addr, b.lastErr)
+		}
+		return
+	}
+
+	switch {
+	case ok && b.streak > 0:
+		b.streak++
+	case ok:
+		b.streak = 1
+	case b.streak < 0:
+		b.streak--
+	default:
+		b.streak = -1
+	}
+	switch {
+	case ok && !b.healthy && b.streak >= th.success:
+		b.healthy = true
+		log.Printf("🟢 %s is back up after %d good checks", b.addr, b.streak)
+	case !ok && b.healthy && -b.streak >= th.failure:
+		b.healthy = false
+		log.Printf("🔴 %s is down after %d failures, taking it out: %s", b.addr, -b.streak, b.lastErr)
+	}
+}
+
+// set makes the state what another health checker says, as -health-api
+// does
+func (b *backend) set(healthy bool, reason string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.draining || b.checked && b.healthy == healthy {
+		return
+	}
+	b.checked, b.healthy, b.lastErr = true, healthy, reason
+	if healthy {
+		log.Printf("🟢 %s is up (%s)", b.addr, reason)
+	} else {
+		log.Printf("🔴 %s is down, taking it out (%s)", b.addr, reason)
+	}
+}
+
+// thresholds are how many checks in a row move a backend down or up
+type thresholds struct{ failure, success int }
+
+// pool is the backends in service and how one is picked for a
+// connection
+type pool struct {
+	policy string // "round-robin", "least-conn" or "source-hash"
+	next   atomic.Uint64
+
+	mu       sync.RWMutex
+	backends []*backend
+}
+
+// list returns the backends in service
+func (p *pool) list() []*backend {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return slices.Clone(p.backends)
+}
+
+// pick chooses an available backend for a connection from client,
+// leaving out those in skip (tried already), or returns nil if none is
+// available
+func (p *pool) pick(client net.Addr, skip []*backend) *backend {
+	var candidates []*backend
+	for _, b := range p.list() {
+		if b.available() && !slices.Contains(skip, b) {
+			candidates = append(candidates, b)
+		}
+	}
+	if len(candidates) == 0 {
+		return nil
+	}
+
+	switch p.policy {
+	case "least-conn":
+		// Fewest open connections; ties go round robin, so an idle pool
+		// still spreads
+		start := int(p.next.Add(1) % uint64(len(candidates)))
+		best := candidates[start]
+		for i := range candidates {
+			b := candidates[(start+i)%len(candidates)]
+			if b.active() < best.active() {
+				best = b
+			}
+		}
+		return best
+	case "source-hash":
+		// The same client IP goes to the same backend while it's
+		// available, and only the clients of a backend that goes move
+		ip := client.String()
+		if host, _, err := net.SplitHostPort(ip); err == nil {
+			ip = host
+		}
+		var best *backend
+		var bestScore uint64
+		for _, b := range candidates {
+			if score := rendezvous.Score(b.addr, ip); best == nil || score > bestScore {
+				best, bestScore = b, score
+			}
+		}
+		return best
+	}
+	return candidates[p.next.Add(1)%uint64(len(candidates))]
+}
+
+// update makes addrs the backends in service. Those already in it keep
+// their state and connections; new ones, their contexts made from ctx,
+// are returned to be checked, and removed ones to be drained. An address
+// listed twice is one backend.
+func (p *pool) update(ctx context.Context, addrs []string) (added, removed []*backend) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	current := make(map[string]*backend, len(p.backends))
+	for _, b := range p.backends {
+		current[b.addr] = b
+	}
+	next := make([]*backend, 0, len(addrs))
+	listed := make(map[string]bool, len(addrs))
+	for _, addr := range addrs {
+		if listed[addr] {
+			continue
+		}
+		listed[addr] = true
+		b, ok := current[addr]
+		if !ok {
+			b = newBackend(ctx, addr)
+			added = append(added, b)
+		}
+		delete(current, addr)
+		next = append(next, b)
+	}
+	for _, b := range p.backends {
+		if _, gone := current[b.addr]; gone {
+			removed = append(removed, b)
+		}
+	}
+	p.backends = next
+	return added, removed
+}
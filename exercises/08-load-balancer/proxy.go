@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/channyeintun/network-exercises/pkg/relay"
+)
+
+// balancer accepts connections and proxies each to a backend
+type balancer struct {
+	pool        *pool
+	dialTimeout time.Duration
+	th          thresholds // for failed connections, counted as failed checks
+	verbose     bool
+	conns       sync.WaitGroup
+}
+
+// serve accepts connections until ln is closed
+func (lb *balancer) serve(ctx context.Context, ln net.Listener) {
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			log.Printf("Accept error: %v", err)
+			continue
+		}
+		lb.conns.Add(1)
+		go func() {
+			defer lb.conns.Done()
+			lb.handle(ctx, conn)
+		}()
+	}
+}
+
+// handle connects client to a backend, trying the next one if a
+// backend refuses or doesn't answer, and copies bytes both ways until
+// both sides are done
+func (lb *balancer) handle(ctx context.Context, client net.Conn) {
+	defer client.Close()
+	var tried []*backend
+	for {
+		b := lb.pool.pick(client.RemoteAddr(), tried)
+		if b == nil {
+			if len(tried) == 0 {
+				log.Printf("🚫 No backend available for %s", client.RemoteAddr())
+			} else {
+				log.Printf("🚫 No backend answered %s, tried %d", client.RemoteAddr(), len(tried))
+			}
+			return
+		}
+		tried = append(tried, b)
+
+		d := net.Dialer{Timeout: lb.dialTimeout}
+		server, err := d.DialContext(ctx, "tcp", b.addr)
+		if err != nil {
+			// A failed connection is a failed check too (passive
+			// checking), so a dead backend goes before its next check
+			b.failed.Add(1)
+			b.observe(false, err, lb.th)
+			log.Printf("⚠️  %s → %s: %v; trying another backend", client.RemoteAddr(), b.addr, err)
+			continue
+		}
+		if !b.track(server, client) {
+			server.Close() // started draining since we picked it
+			continue
+		}
+		b.total.Add(1)
+		lb.proxy(client, server, b)
+		b.release(server)
+		return
+	}
+}
+
+// proxy copies between client and server until both sides are done
+func (lb *balancer) proxy(client, server net.Conn, b *backend) {
+	defer server.Close()
+	start := time.Now()
+	if lb.verbose {
+		log.Printf("🔀 %s → %s", client.RemoteAddr(), b.addr)
+	}
+
+	in, out := relay.Copy(client, server)
+	b.bytesIn.Add(uint64(in))
+	b.bytesOut.Add(uint64(out))
+
+	if lb.verbose {
+		log.Printf("✅ %s → %s closed after %v", client.RemoteAddr(), b.addr, time.Since(start).Round(time.Millisecond))
+	}
+}
@@ -0,0 +1,206 @@
+// Package main implements a SOCKS5 proxy server (RFC 1928).
+// This exercise teaches a binary handshake protocol from the server's side.
+//
+// Learning objectives:
+// - Negotiate an authentication method, then check a username and password (RFC 1929)
+// - Parse CONNECT requests with IPv4, IPv6 and domain name destinations
+// - Relay UDP datagrams for a client with UDP ASSOCIATE
+// - Allow and deny destinations by name, IP range and port, after the lookup
+//
+// Run: go run .
+// Test: curl --socks5-hostname 127.0.0.1:1080 https://example.com
+// Or:  go run . -user alice:secret   (then curl --socks5-hostname alice:secret@127.0.0.1:1080 ...)
+// Or:  go run . -users users.txt -rules rules.txt   (kill -HUP after editing either reloads both)
+// Or:  go run . -allow '*.example.com:80,443 93.184.0.0/16'   (refuse everything else)
+// Or:  go run . -udp   (UDP ASSOCIATE too, for DNS and other UDP through the proxy)
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"maps"
+	"net"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/channyeintun/network-exercises/pkg/acl"
+)
+
+func main() {
+	listen := flag.String("listen", "127.0.0.1:1080", "Address to accept clients on; an open proxy on a public address gets abused, so add -user or -rules before widening it")
+	user := flag.String("user", "", "Require this name:password from clients")
+	usersFile := flag.String("users", "", "File of name:password lines clients may authenticate with (# comments); reloaded on SIGHUP")
+	rulesFile := flag.String("rules", "", "File of \"allow\" or \"deny\" destination rules, the first match deciding; reloaded on SIGHUP")
+	allow := flag.String("allow", "", "Space-separated destinations to allow after -rules, e.g. '*.example.com:443 10.0.0.0/8:22,80'; anything no rule allows is refused")
+	udp := flag.Bool("udp", false, "Support UDP ASSOCIATE")
+	dialTimeout := flag.Duration("dial-timeout", 10*time.Second, "How long to wait for a destination's name to resolve, and then for it to accept")
+	handshakeTimeout := flag.Duration("handshake-timeout", 10*time.Second, "How long a client may take to authenticate and send its request")
+	shutdownTimeout := flag.Duration("shutdown-timeout", 10*time.Second, "How long shutdown waits for open connections before cutting them")
+	statsEvery := flag.Duration("stats", time.Minute, "Log request counts this often; 0 to only log them at shutdown")
+	verbose := flag.Bool("v", false, "Log every connection and UDP association")
+	flag.Parse()
+
+	load := func() (*config, error) {
+		return loadConfig(*user, *usersFile, *rulesFile, *allow)
+	}
+	cfg, err := load()
+	if err != nil {
+		log.Fatalf("Failed to load the configuration: %v", err)
+	}
+	s := &server{
+		resolver:         net.DefaultResolver,
+		dialTimeout:      *dialTimeout,
+		handshakeTimeout: *handshakeTimeout,
+		udp:              *udp,
+		verbose:          *verbose,
+		open:             make(map[net.Conn]struct{}),
+	}
+	s.cfg.Store(cfg)
+
+	ln, err := net.Listen("tcp", *listen)
+	if err != nil {
+		log.Fatalf("Failed to listen on %s: %v", *listen, err)
+	}
+	log.Printf("🚀 SOCKS5 proxy listening on %s", ln.Addr())
+	logConfig(cfg)
+	if ip := ln.Addr().(*net.TCPAddr).IP; !ip.IsLoopback() && len(cfg.users) == 0 && len(cfg.rules) == 0 {
+		log.Printf("⚠️  Anyone who can reach %s may use this proxy to reach anything: consider -user or -rules", ln.Addr())
+	}
+	log.Printf("   Test with: curl --socks5-hostname %s https://example.com", ln.Addr())
+	log.Println("   Press Ctrl+C to shutdown")
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	if *usersFile != "" || *rulesFile != "" {
+		go func() {
+			hup := make(chan os.Signal, 1)
+			signal.Notify(hup, syscall.SIGHUP)
+			defer signal.Stop(hup)
+			for {
+				select {
+				case <-hup:
+					log.Println("🔄 SIGHUP: reloading users and rules")
+					cfg, err := load()
+					if err != nil {
+						log.Printf("❌ Reload failed, keeping the old ones: %v", err)
+						continue
+					}
+					s.cfg.Store(cfg)
+					logConfig(cfg)
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+	if *statsEvery > 0 {
+		go func() {
+			ticker := time.NewTicker(*statsEvery)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ticker.C:
+					log.Printf("📊 Stats: %s", s.stats.String())
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+	go s.serve(ctx, ln)
+
+	<-ctx.Done()
+	log.Printf("🛑 Shutting down, waiting up to %v for open connections...", *shutdownTimeout)
+	ln.Close()
+	done := make(chan struct{})
+	go func() {
+		s.conns.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(*shutdownTimeout):
+		log.Printf("⚠️  Cutting %d connections still open", s.closeAll())
+		<-done
+	}
+	log.Printf("📊 Final Stats: %s", s.stats.String())
+	log.Println("✅ SOCKS5 proxy shutdown complete")
+}
+
+// loadConfig builds the users from -user and -users, and the rules from
+// -rules and then -allow
+func loadConfig(user, usersFile, rulesFile, allow string) (*config, error) {
+	cfg := &config{users: make(map[string]string)}
+	if user != "" {
+		name, password, ok := strings.Cut(user, ":")
+		if !ok || name == "" {
+			return nil, fmt.Errorf("-user: want name:password")
+		}
+		cfg.users[name] = password
+	}
+	if usersFile != "" {
+		users, err := loadUsers(usersFile)
+		if err != nil {
+			return nil, err
+		}
+		maps.Copy(cfg.users, users)
+	}
+	for name, password := range cfg.users {
+		if len(name) > 255 || len(password) > 255 {
+			return nil, fmt.Errorf("user %q: names and passwords are at most 255 bytes in SOCKS5", name)
+		}
+	}
+
+	if rulesFile != "" {
+		rules, err := acl.Load(rulesFile)
+		if err != nil {
+			return nil, err
+		}
+		cfg.rules = rules
+	}
+	allowed, err := acl.ParseAllow(allow)
+	if err != nil {
+		return nil, fmt.Errorf("-allow: %w", err)
+	}
+	cfg.rules = append(cfg.rules, allowed...)
+	return cfg, nil
+}
+
+// loadUsers reads name:password lines, skipping blank lines and #
+// comments
+func loadUsers(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	users := make(map[string]string)
+	for i, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue // a # elsewhere may be part of a password
+		}
+		name, password, ok := strings.Cut(line, ":")
+		if !ok || name == "" {
+			return nil, fmt.Errorf("%s:%d: want name:password", path, i+1)
+		}
+		users[name] = password
+	}
+	return users, nil
+}
+
+func logConfig(cfg *config) {
+	auth := "no authentication"
+	if len(cfg.users) > 0 {
+		auth = fmt.Sprintf("username/password, %d users", len(cfg.users))
+	}
+	rules := "any destination"
+	if n := len(cfg.rules); n > 0 {
+		rules = fmt.Sprintf("%d destination rules", n)
+	}
+	log.Printf("🔐 %s; %s", auth, rules)
+}
@@ -0,0 +1,278 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/netip"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/channyeintun/network-exercises/pkg/acl"
+	"github.com/channyeintun/network-exercises/pkg/relay"
+)
+
+// config is what SIGHUP reloads
+type config struct {
+	users map[string]string // username to password; empty: no authentication
+	rules acl.List
+}
+
+// server speaks SOCKS5 to each client it accepts
+type server struct {
+	cfg              atomic.Pointer[config]
+	resolver         *net.Resolver
+	dialTimeout      time.Duration
+	handshakeTimeout time.Duration
+	udp              bool // UDP ASSOCIATE allowed
+	verbose          bool
+
+	conns sync.WaitGroup
+	mu    sync.Mutex
+	open  map[net.Conn]struct{} // client connections, closed if shutdown runs out of time
+	stats stats
+}
+
+// stats counts requests by how they ended, and the bytes relayed
+type stats struct {
+	clients      atomic.Uint64
+	connects     atomic.Uint64
+	associations atomic.Uint64
+	denied       atomic.Uint64
+	authFailed   atomic.Uint64
+	failed       atomic.Uint64 // destinations that couldn't be reached
+	bytesUp      atomic.Uint64 // from clients
+	bytesDown    atomic.Uint64 // to clients
+	datagrams    atomic.Uint64 // relayed over UDP, both ways
+}
+
+func (s *stats) String() string {
+	return fmt.Sprintf("%d clients: %d connects, %d UDP associations, %d denied, %d failed authentication, %d unreachable; %d bytes up, %d down, %d datagrams",
+		s.clients.Load(), s.connects.Load(), s.associations.Load(), s.denied.Load(), s.authFailed.Load(), s.failed.Load(),
+		s.bytesUp.Load(), s.bytesDown.Load(), s.datagrams.Load())
+}
+
+// serve accepts clients until ln is closed
+func (s *server) serve(ctx context.Context, ln net.Listener) {
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			log.Printf("Accept error: %v", err)
+			continue
+		}
+		s.stats.clients.Add(1)
+		s.mu.Lock()
+		s.open[conn] = struct{}{}
+		s.mu.Unlock()
+		s.conns.Add(1)
+		go func() {
+			defer s.conns.Done()
+			defer func() {
+				s.mu.Lock()
+				delete(s.open, conn)
+				s.mu.Unlock()
+				conn.Close()
+			}()
+			s.handle(ctx, conn)
+		}()
+	}
+}
+
+// closeAll cuts every client still connected
+func (s *server) closeAll() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for conn := range s.open {
+		conn.Close()
+	}
+	return len(s.open)
+}
+
+// handle runs one client's handshake, authentication and request
+func (s *server) handle(ctx context.Context, conn net.Conn) {
+	who := conn.RemoteAddr().String()
+	conn.SetDeadline(time.Now().Add(s.handshakeTimeout))
+
+	// The client offers authentication methods and we pick one: with
+	// users configured that must be username/password, since no-auth
+	// would let anyone in
+	methods, err := readGreeting(conn)
+	if err != nil {
+		if s.verbose {
+			log.Printf("⚠️  %s: greeting: %v", who, err)
+		}
+		return
+	}
+	cfg := s.cfg.Load()
+	want := byte(methodNoAuth)
+	if len(cfg.users) > 0 {
+		want = methodUserPass
+	}
+	if !slices.Contains(methods, want) {
+		conn.Write([]byte{socksVersion, methodNoAcceptable})
+		s.stats.authFailed.Add(1)
+		log.Printf("🚫 %s offers methods %v, not %d", who, methods, want)
+		return
+	}
+	if _, err := conn.Write([]byte{socksVersion, want}); err != nil {
+		return
+	}
+	if want == methodUserPass {
+		user, password, err := readUserPass(conn)
+		if err != nil {
+			log.Printf("⚠️  %s: authentication: %v", who, err)
+			return
+		}
+		if !checkPassword(cfg.users, user, password) {
+			conn.Write([]byte{userPassVersion, 1})
+			s.stats.authFailed.Add(1)
+			log.Printf("🚫 %s: wrong username or password for %q", who, user)
+			return
+		}
+		if _, err := conn.Write([]byte{userPassVersion, 0}); err != nil {
+			return
+		}
+		who = fmt.Sprintf("%s (%s)", who, user)
+	}
+
+	cmd, dst, err := readRequest(conn)
+	if err != nil {
+		var re *replyError
+		if errors.As(err, &re) {
+			writeReply(conn, re.code, addr{})
+		}
+		log.Printf("⚠️  %s: request: %v", who, err)
+		return
+	}
+	switch {
+	case cmd == cmdConnect:
+		s.connect(ctx, conn, who, dst, cfg)
+	case cmd == cmdUDPAssociate && s.udp:
+		s.associate(ctx, conn, who, dst, cfg)
+	default:
+		// BIND, for protocols like active FTP where the server connects
+		// back, isn't supported; nor is UDP ASSOCIATE without -udp
+		writeReply(conn, repCommandNotSupported, addr{})
+		log.Printf("🚫 %s: command %d not supported", who, cmd)
+	}
+}
+
+// connect opens a connection to dst for the client, replies with the
+// address it's bound to, and relays until both sides are done
+func (s *server) connect(ctx context.Context, conn net.Conn, who string, dst addr, cfg *config) {
+	start := time.Now()
+	upstream, code, err := s.dial(ctx, dst, cfg)
+	if err != nil {
+		writeReply(conn, code, addr{})
+		log.Printf("🚫 %s → %s: %v", who, dst, err)
+		return
+	}
+	defer upstream.Close()
+	if err := writeReply(conn, repSucceeded, addrFrom(upstream.LocalAddr())); err != nil {
+		return
+	}
+	conn.SetDeadline(time.Time{})
+	s.stats.connects.Add(1)
+	if s.verbose {
+		log.Printf("🔀 %s → %s (%s)", who, dst, upstream.RemoteAddr())
+	}
+
+	up, down := relay.Copy(conn, upstream)
+	s.stats.bytesUp.Add(uint64(up))
+	s.stats.bytesDown.Add(uint64(down))
+	if s.verbose {
+		log.Printf("✅ %s → %s closed after %v: %d bytes up, %d down",
+			who, dst, time.Since(start).Round(time.Millisecond), up, down)
+	}
+}
+
+// dial connects to the first of dst's addresses the rules allow that
+// answers
+func (s *server) dial(ctx context.Context, dst addr, cfg *config) (net.Conn, replyCode, error) {
+	ips, code, err := s.allowedIPs(ctx, dst, cfg)
+	if err != nil {
+		return nil, code, err
+	}
+	d := net.Dialer{Timeout: s.dialTimeout}
+	for _, ip := range ips {
+		var conn net.Conn
+		conn, err = d.DialContext(ctx, "tcp", netip.AddrPortFrom(ip, dst.port).String())
+		if err == nil {
+			return conn, repSucceeded, nil
+		}
+	}
+	s.stats.failed.Add(1)
+	return nil, replyFor(err), err
+}
+
+// allowedIPs looks dst up and returns the addresses the rules allow.
+// The rules see the addresses we actually send to, so a name can't
+// resolve its way past a CIDR rule.
+func (s *server) allowedIPs(ctx context.Context, dst addr, cfg *config) ([]netip.Addr, replyCode, error) {
+	ips, err := s.lookup(ctx, dst)
+	if err != nil {
+		s.stats.failed.Add(1)
+		return nil, repHostUnreachable, err
+	}
+	return s.filter(dst, ips, cfg)
+}
+
+// filter returns those of ips, dst's addresses, that the rules allow
+func (s *server) filter(dst addr, ips []netip.Addr, cfg *config) ([]netip.Addr, replyCode, error) {
+	allowed, why := cfg.rules.Filter(dst.name, ips, dst.port)
+	if len(allowed) == 0 {
+		s.stats.denied.Add(1)
+		return nil, repNotAllowed, fmt.Errorf("denied (%s)", why)
+	}
+	return allowed, repSucceeded, nil
+}
+
+// lookup returns dst's IP, or the addresses its name has
+func (s *server) lookup(ctx context.Context, dst addr) ([]netip.Addr, error) {
+	if dst.name == "" {
+		return []netip.Addr{dst.ip}, nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, s.dialTimeout)
+	defer cancel()
+	ips, err := s.resolver.LookupNetIP(ctx, "ip", dst.name)
+	if err != nil {
+		return nil, err
+	}
+	for i, ip := range ips {
+		ips[i] = ip.Unmap()
+	}
+	return ips, nil
+}
+
+// replyFor turns a dial error into the reply code that says the most
+func replyFor(err error) replyCode {
+	var ne net.Error
+	switch {
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return repConnectionRefused
+	case errors.Is(err, syscall.ENETUNREACH):
+		return repNetworkUnreachable
+	case errors.Is(err, syscall.EHOSTUNREACH), errors.As(err, &ne) && ne.Timeout():
+		return repHostUnreachable
+	}
+	return repGeneralFailure
+}
+
+// checkPassword compares in constant time, so how long it takes doesn't
+// say how much of a password was right
+func checkPassword(users map[string]string, user, password string) bool {
+	want, ok := users[user]
+	if !ok {
+		want = password + "x" // still compare, so unknown users take as long
+	}
+	return subtle.ConstantTimeCompare([]byte(want), []byte(password)) == 1 && ok
+}
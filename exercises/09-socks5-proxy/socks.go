@@ -0,0 +1,262 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/netip"
+	"strconv"
+)
+
+// The SOCKS5 wire format (RFC 1928), and username/password
+// authentication (RFC 1929)
+const (
+	socksVersion = 0x05
+
+	methodNoAuth       = 0x00
+	methodUserPass     = 0x02
+	methodNoAcceptable = 0xff
+
+	userPassVersion = 0x01
+
+	cmdConnect      = 0x01
+	cmdBind         = 0x02
+	cmdUDPAssociate = 0x03
+
+	atypIPv4   = 0x01
+	atypDomain = 0x03
+	atypIPv6   = 0x04
+)
+
+// replyCode is the REP field of a reply
+type replyCode byte
+
+const (
+	repSucceeded           replyCode = 0x00
+	repGeneralFailure      replyCode = 0x01
+	repNotAllowed          replyCode = 0x02 // by the ruleset
+	repNetworkUnreachable  replyCode = 0x03
+	repHostUnreachable     replyCode = 0x04
+	repConnectionRefused   replyCode = 0x05
+	repTTLExpired          replyCode = 0x06
+	repCommandNotSupported replyCode = 0x07
+	repAddressNotSupported replyCode = 0x08
+)
+
+func (r replyCode) String() string {
+	switch r {
+	case repSucceeded:
+		return "succeeded"
+	case repGeneralFailure:
+		return "general failure"
+	case repNotAllowed:
+		return "not allowed by ruleset"
+	case repNetworkUnreachable:
+		return "network unreachable"
+	case repHostUnreachable:
+		return "host unreachable"
+	case repConnectionRefused:
+		return "connection refused"
+	case repTTLExpired:
+		return "TTL expired"
+	case repCommandNotSupported:
+		return "command not supported"
+	case repAddressNotSupported:
+		return "address type not supported"
+	}
+	return fmt.Sprintf("reply 0x%02x", byte(r))
+}
+
+// replyError is a request that failed with a reply code to send back
+type replyError struct {
+	code replyCode
+	err  error
+}
+
+func (e *replyError) Error() string { return fmt.Sprintf("%s: %v", e.code, e.err) }
+func (e *replyError) Unwrap() error { return e.err }
+
+// addr is a request's destination: a domain name or an IP, and a port
+type addr struct {
+	name string     // set for ATYP domain
+	ip   netip.Addr // set for ATYP IPv4 and IPv6
+	port uint16
+}
+
+func (a addr) String() string {
+	if a.name != "" {
+		return net.JoinHostPort(a.name, strconv.Itoa(int(a.port)))
+	}
+	return netip.AddrPortFrom(a.ip, a.port).String()
+}
+
+// addrFrom makes an addr of a net.Addr, for BND.ADDR and the source of
+// a UDP datagram
+func addrFrom(a net.Addr) addr {
+	var ap netip.AddrPort
+	switch a := a.(type) {
+	case *net.TCPAddr:
+		ap = a.AddrPort()
+	case *net.UDPAddr:
+		ap = a.AddrPort()
+	}
+	return addr{ip: ap.Addr().Unmap(), port: ap.Port()}
+}
+
+// readAddr reads ATYP, DST.ADDR and DST.PORT
+func readAddr(r io.Reader) (addr, error) {
+	var atyp [1]byte
+	if _, err := io.ReadFull(r, atyp[:]); err != nil {
+		return addr{}, err
+	}
+	var a addr
+	switch atyp[0] {
+	case atypIPv4:
+		var b [4]byte
+		if _, err := io.ReadFull(r, b[:]); err != nil {
+			return addr{}, err
+		}
+		a.ip = netip.AddrFrom4(b)
+	case atypIPv6:
+		var b [16]byte
+		if _, err := io.ReadFull(r, b[:]); err != nil {
+			return addr{}, err
+		}
+		a.ip = netip.AddrFrom16(b)
+	case atypDomain:
+		var n [1]byte
+		if _, err := io.ReadFull(r, n[:]); err != nil {
+			return addr{}, err
+		}
+		name := make([]byte, n[0])
+		if _, err := io.ReadFull(r, name); err != nil {
+			return addr{}, err
+		}
+		if n[0] == 0 {
+			return addr{}, &replyError{repAddressNotSupported, errors.New("empty domain name")}
+		}
+		// Some clients send an IP as a name
+		if ip, err := netip.ParseAddr(string(name)); err == nil {
+			a.ip = ip.Unmap()
+		} else {
+			a.name = string(name)
+		}
+	default:
+		return addr{}, &replyError{repAddressNotSupported, fmt.Errorf("address type 0x%02x", atyp[0])}
+	}
+	var port [2]byte
+	if _, err := io.ReadFull(r, port[:]); err != nil {
+		return addr{}, err
+	}
+	a.port = binary.BigEndian.Uint16(port[:])
+	return a, nil
+}
+
+// appendAddr appends a's ATYP, ADDR and PORT to b
+func appendAddr(b []byte, a addr) []byte {
+	switch {
+	case a.name != "":
+		b = append(b, atypDomain, byte(len(a.name)))
+		b = append(b, a.name...)
+	case a.ip.Is4():
+		b = append(b, atypIPv4)
+		b = append(b, a.ip.AsSlice()...)
+	case a.ip.Is6():
+		b = append(b, atypIPv6)
+		b = append(b, a.ip.AsSlice()...)
+	default:
+		b = append(b, atypIPv4, 0, 0, 0, 0) // no address: 0.0.0.0
+	}
+	return binary.BigEndian.AppendUint16(b, a.port)
+}
+
+// readGreeting reads the client's version and the authentication
+// methods it offers
+func readGreeting(r io.Reader) ([]byte, error) {
+	var head [2]byte
+	if _, err := io.ReadFull(r, head[:]); err != nil {
+		return nil, err
+	}
+	if head[0] != socksVersion {
+		return nil, fmt.Errorf("version %d, not SOCKS5", head[0])
+	}
+	methods := make([]byte, head[1])
+	if _, err := io.ReadFull(r, methods); err != nil {
+		return nil, err
+	}
+	return methods, nil
+}
+
+// readUserPass reads an RFC 1929 username and password
+func readUserPass(r io.Reader) (user, password string, err error) {
+	var ver [1]byte
+	if _, err := io.ReadFull(r, ver[:]); err != nil {
+		return "", "", err
+	}
+	if ver[0] != userPassVersion {
+		return "", "", fmt.Errorf("username/password version %d", ver[0])
+	}
+	field := func() (string, error) {
+		var n [1]byte
+		if _, err := io.ReadFull(r, n[:]); err != nil {
+			return "", err
+		}
+		b := make([]byte, n[0])
+		_, err := io.ReadFull(r, b)
+		return string(b), err
+	}
+	if user, err = field(); err != nil {
+		return "", "", err
+	}
+	if password, err = field(); err != nil {
+		return "", "", err
+	}
+	return user, password, nil
+}
+
+// readRequest reads VER, CMD, RSV and the destination
+func readRequest(r io.Reader) (cmd byte, dst addr, err error) {
+	var head [3]byte
+	if _, err := io.ReadFull(r, head[:]); err != nil {
+		return 0, addr{}, err
+	}
+	if head[0] != socksVersion {
+		return 0, addr{}, fmt.Errorf("request version %d", head[0])
+	}
+	dst, err = readAddr(r)
+	return head[1], dst, err
+}
+
+// writeReply sends a reply with the bound address, which is the zero
+// addr for a failure
+func writeReply(w io.Writer, code replyCode, bound addr) error {
+	_, err := w.Write(appendAddr([]byte{socksVersion, byte(code), 0}, bound))
+	return err
+}
+
+// parseDatagram splits a UDP ASSOCIATE datagram into its destination and
+// payload. Fragments (FRAG other than 0) aren't supported and are
+// dropped, as RFC 1928 allows.
+func parseDatagram(b []byte) (addr, []byte, error) {
+	if len(b) < 4 {
+		return addr{}, nil, errors.New("short datagram")
+	}
+	if b[2] != 0 {
+		return addr{}, nil, fmt.Errorf("fragment %d", b[2])
+	}
+	r := bytes.NewReader(b[3:])
+	dst, err := readAddr(r)
+	if err != nil {
+		return addr{}, nil, err
+	}
+	return dst, b[len(b)-r.Len():], nil
+}
+
+// appendDatagram appends the header for a datagram from src, then data
+func appendDatagram(b []byte, src addr, data []byte) []byte {
+	b = appendAddr(append(b, 0, 0, 0), src)
+	return append(b, data...)
+}
@@ -0,0 +1,273 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log"
+	"net"
+	"net/netip"
+	"sync"
+	"time"
+)
+
+// maxPeers caps how many destinations one association may send to, and
+// so how many it takes replies from
+const maxPeers = 1024
+
+// Names in datagrams are looked up off the read loop, once per nameTTL,
+// so a slow lookup holds up only the datagrams waiting on it. maxHeld
+// caps how many datagrams an association keeps waiting.
+const (
+	nameTTL = time.Minute
+	maxHeld = 64
+)
+
+// association relays one client's UDP datagrams. The client sends each
+// to the relay socket with a header naming its destination; we send the
+// payload on from a second socket, and wrap replies in a header naming
+// where they came from. It lasts as long as the client's TCP connection.
+type association struct {
+	s     *server
+	who   string
+	cfg   *config
+	relay *net.UDPConn // faces the client
+	out   *net.UDPConn // faces destinations
+
+	mu     sync.Mutex
+	client netip.AddrPort          // where the client sends from, once known
+	peers  map[netip.AddrPort]bool // destinations sent to: only they may reply
+	names  map[string]*nameLookup  // lookups of the names datagrams were sent to
+	held   int                     // datagrams waiting on a lookup
+}
+
+// nameLookup is one lookup of a name datagrams are addressed to. Until
+// it's done, datagrams for the name wait in held.
+type nameLookup struct {
+	done bool
+	at   time.Time
+	ips  []netip.Addr
+	err  error
+	held []heldDatagram
+}
+
+type heldDatagram struct {
+	dst     addr
+	payload []byte
+}
+
+// associate sets up a UDP relay for the client on the control
+// connection conn. hint is where the client says it'll send from; a
+// zero address or port means it doesn't know yet.
+func (s *server) associate(ctx context.Context, conn net.Conn, who string, hint addr, cfg *config) {
+	// Listen on the address the client reached us on: it can get there
+	local := conn.LocalAddr().(*net.TCPAddr)
+	relaySock, err := net.ListenUDP("udp", &net.UDPAddr{IP: local.IP, Zone: local.Zone})
+	if err != nil {
+		writeReply(conn, repGeneralFailure, addr{})
+		log.Printf("❌ %s: UDP ASSOCIATE: %v", who, err)
+		return
+	}
+	defer relaySock.Close()
+	outSock, err := net.ListenUDP("udp", nil)
+	if err != nil {
+		writeReply(conn, repGeneralFailure, addr{})
+		log.Printf("❌ %s: UDP ASSOCIATE: %v", who, err)
+		return
+	}
+	defer outSock.Close()
+
+	a := &association{s: s, who: who, cfg: cfg, relay: relaySock, out: outSock,
+		peers: make(map[netip.AddrPort]bool), names: make(map[string]*nameLookup)}
+	clientIP := addrFrom(conn.RemoteAddr()).ip
+	if hint.ip.IsValid() && !hint.ip.IsUnspecified() {
+		clientIP = hint.ip
+	}
+	if hint.port != 0 {
+		a.client = netip.AddrPortFrom(clientIP, hint.port)
+	}
+
+	bound := addrFrom(relaySock.LocalAddr())
+	if err := writeReply(conn, repSucceeded, bound); err != nil {
+		return
+	}
+	conn.SetDeadline(time.Time{})
+	s.stats.associations.Add(1)
+	log.Printf("📦 %s: UDP relay on %s", who, bound)
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		a.fromClient(ctx, clientIP)
+	}()
+	go func() {
+		defer wg.Done()
+		a.toClient()
+	}()
+
+	// The association ends when the client closes its TCP connection,
+	// or we do at shutdown; nothing more is read from it
+	io.Copy(io.Discard, conn)
+	relaySock.Close()
+	outSock.Close()
+	wg.Wait()
+	if s.verbose {
+		log.Printf("✅ %s: UDP relay on %s closed", who, bound)
+	}
+}
+
+// fromClient reads the client's datagrams and sends each on, if the
+// rules allow its destination. Datagrams from anyone but the client are
+// dropped: the first from clientIP fixes its port if the request didn't.
+func (a *association) fromClient(ctx context.Context, clientIP netip.Addr) {
+	buf := make([]byte, 65535)
+	for {
+		n, from, err := a.relay.ReadFromUDPAddrPort(buf)
+		if err != nil {
+			return // closed
+		}
+		from = netip.AddrPortFrom(from.Addr().Unmap(), from.Port())
+		a.mu.Lock()
+		if !a.client.IsValid() && from.Addr() == clientIP {
+			a.client = from
+		}
+		ours := from == a.client
+		a.mu.Unlock()
+		if !ours {
+			continue
+		}
+
+		dst, payload, err := parseDatagram(buf[:n])
+		if err != nil {
+			if a.s.verbose {
+				log.Printf("⚠️  %s: dropping datagram: %v", a.who, err)
+			}
+			continue
+		}
+		if dst.name == "" {
+			a.forward(dst, []netip.Addr{dst.ip}, nil, payload)
+			continue
+		}
+		if l := a.lookup(ctx, dst, payload); l != nil {
+			a.forward(dst, l.ips, l.err, payload)
+		}
+	}
+}
+
+// lookup returns the done lookup of dst's name. If there isn't one, it
+// holds a copy of payload for when it is, and returns nil.
+func (a *association) lookup(ctx context.Context, dst addr, payload []byte) *nameLookup {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	l := a.names[dst.name]
+	if l != nil && l.done && time.Since(l.at) < nameTTL {
+		return l
+	}
+	if l == nil || l.done {
+		if l == nil && len(a.names) >= maxPeers {
+			a.dropStaleNames()
+			if len(a.names) >= maxPeers {
+				return nil
+			}
+		}
+		l = &nameLookup{}
+		a.names[dst.name] = l
+		go a.resolve(ctx, dst.name, l)
+	}
+	if a.held >= maxHeld {
+		if a.s.verbose {
+			log.Printf("⚠️  %s: dropping datagram for %s: too many waiting on lookups", a.who, dst)
+		}
+		return nil
+	}
+	a.held++
+	l.held = append(l.held, heldDatagram{dst, bytes.Clone(payload)})
+	return nil
+}
+
+// dropStaleNames forgets the lookups that are past nameTTL. a.mu must
+// be held.
+func (a *association) dropStaleNames() {
+	for name, l := range a.names {
+		if l.done && time.Since(l.at) >= nameTTL {
+			delete(a.names, name)
+		}
+	}
+}
+
+// resolve looks name up for l and sends on the datagrams that waited
+func (a *association) resolve(ctx context.Context, name string, l *nameLookup) {
+	ips, err := a.s.lookup(ctx, addr{name: name})
+	if err != nil {
+		a.s.stats.failed.Add(1)
+	}
+	a.mu.Lock()
+	l.at, l.ips, l.err = time.Now(), ips, err
+	// Datagrams keep arriving while the held ones go out, so l is only
+	// done once none are left, and they all go in the order they came
+	for len(l.held) > 0 {
+		held := l.held
+		l.held = nil
+		a.held -= len(held)
+		a.mu.Unlock()
+		for _, d := range held {
+			a.forward(d.dst, ips, err, d.payload)
+		}
+		a.mu.Lock()
+	}
+	l.done = true
+	a.mu.Unlock()
+}
+
+// forward sends payload to the first of ips, dst's addresses, that the
+// rules allow. err is why dst's name didn't resolve, if it didn't.
+func (a *association) forward(dst addr, ips []netip.Addr, err error, payload []byte) {
+	if err == nil {
+		ips, _, err = a.s.filter(dst, ips, a.cfg)
+	}
+	if err != nil {
+		if a.s.verbose {
+			log.Printf("🚫 %s → %s (UDP): %v", a.who, dst, err)
+		}
+		return
+	}
+
+	to := netip.AddrPortFrom(ips[0], dst.port)
+	a.mu.Lock()
+	if !a.peers[to] && len(a.peers) >= maxPeers {
+		a.mu.Unlock()
+		return
+	}
+	a.peers[to] = true
+	a.mu.Unlock()
+	if _, err := a.out.WriteToUDPAddrPort(payload, to); err == nil {
+		a.s.stats.datagrams.Add(1)
+		a.s.stats.bytesUp.Add(uint64(len(payload)))
+	}
+}
+
+// toClient wraps replies from destinations the client sent to and
+// passes them back
+func (a *association) toClient() {
+	buf := make([]byte, 65535)
+	packet := make([]byte, 0, 65535+22)
+	for {
+		n, from, err := a.out.ReadFromUDPAddrPort(buf)
+		if err != nil {
+			return // closed
+		}
+		from = netip.AddrPortFrom(from.Addr().Unmap(), from.Port())
+		a.mu.Lock()
+		client, known := a.client, a.peers[from]
+		a.mu.Unlock()
+		if !known || !client.IsValid() {
+			continue
+		}
+		packet = appendDatagram(packet[:0], addr{ip: from.Addr(), port: from.Port()}, buf[:n])
+		if _, err := a.relay.WriteToUDPAddrPort(packet, client); err == nil {
+			a.s.stats.datagrams.Add(1)
+			a.s.stats.bytesDown.Add(uint64(n))
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// accessLog writes a line per request in the Common Log Format, as web
+// servers and proxies do, plus how long it took and where it went:
+//
+//	127.0.0.1 - alice [16/Oct/2026:04:34:51 +0000] "CONNECT example.com:443 HTTP/1.1" 200 5123 532ms direct
+type accessLog struct {
+	mu    sync.Mutex
+	w     io.Writer // nil: no access log
+	route string    // "direct", or "via" and the upstream
+}
+
+func (a *accessLog) log(r *http.Request, user string, status int, bytes int64, took time.Duration) {
+	if a.w == nil {
+		return
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	target := r.RequestURI
+	if r.Method == http.MethodConnect {
+		target = r.Host
+	}
+	size := "-"
+	if bytes > 0 {
+		size = fmt.Sprint(bytes)
+	}
+	if status == 0 {
+		status = http.StatusOK // the handler wrote nothing at all
+	}
+	line := fmt.Sprintf("%s - %s [%s] %q %d %s %v %s\n",
+		host, user, time.Now().Format("02/Jan/2006:15:04:05 -0700"),
+		r.Method+" "+target+" "+r.Proto, status, size, took.Round(time.Millisecond), a.route)
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	io.WriteString(a.w, line)
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strconv"
+	"time"
+
+	"golang.org/x/net/proxy"
+)
+
+// deniedError is a destination the rules don't allow
+type deniedError struct {
+	addr string
+	why  string
+}
+
+func (e *deniedError) Error() string { return fmt.Sprintf("%s denied (%s)", e.addr, e.why) }
+
+// parseUpstream validates -upstream: http:// for another HTTP proxy,
+// socks5:// or socks5h:// for a SOCKS5 one. Credentials go in the URL.
+func parseUpstream(raw string) (*url.URL, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, err
+	}
+	switch u.Scheme {
+	case "http", "socks5", "socks5h":
+	default:
+		return nil, fmt.Errorf("unsupported scheme %q (want http, socks5 or socks5h)", u.Scheme)
+	}
+	if u.Hostname() == "" {
+		return nil, fmt.Errorf("%q has no host", raw)
+	}
+	if u.Port() == "" {
+		port := map[string]string{"http": "3128", "socks5": "1080", "socks5h": "1080"}[u.Scheme]
+		u.Host = net.JoinHostPort(u.Hostname(), port)
+	}
+	return u, nil
+}
+
+// check applies the rules to a destination the upstream proxy will
+// reach for us. It looks the name up, so we can't see where it goes:
+// CIDR rules only catch IP literals.
+func (s *server) check(addr string) error {
+	host, port, err := splitHostPort(addr)
+	if err != nil {
+		return err
+	}
+	name, ip := host, netip.Addr{}
+	if parsed, err := netip.ParseAddr(host); err == nil {
+		name, ip = "", parsed
+	}
+	if ok, why := s.rules.Load().Allowed(name, ip, port); !ok {
+		return &deniedError{addr, why}
+	}
+	return nil
+}
+
+// dial connects to addr for a tunnel, or for a plain request the
+// transport sends itself. Without -upstream it looks the name up and
+// dials only the addresses the rules allow, so a name can't resolve its
+// way past a CIDR rule; with one, the upstream connects for us.
+func (s *server) dial(ctx context.Context, network, addr string) (net.Conn, error) {
+	if s.upstream != nil {
+		if err := s.check(addr); err != nil {
+			return nil, err
+		}
+		if s.upstream.Scheme == "http" {
+			return s.connect(ctx, addr)
+		}
+		return s.socks.DialContext(ctx, network, addr)
+	}
+
+	host, port, err := splitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	ips := []netip.Addr{}
+	if ip, err := netip.ParseAddr(host); err == nil {
+		ips = append(ips, ip.Unmap())
+		host = ""
+	} else {
+		lookupCtx, cancel := context.WithTimeout(ctx, s.dialTimeout)
+		found, err := net.DefaultResolver.LookupNetIP(lookupCtx, "ip", host)
+		cancel()
+		if err != nil {
+			return nil, err
+		}
+		for _, ip := range found {
+			ips = append(ips, ip.Unmap())
+		}
+	}
+	allowed, why := s.rules.Load().Filter(host, ips, port)
+	if len(allowed) == 0 {
+		return nil, &deniedError{addr, why}
+	}
+	d := net.Dialer{Timeout: s.dialTimeout}
+	for _, ip := range allowed {
+		var conn net.Conn
+		conn, err = d.DialContext(ctx, network, netip.AddrPortFrom(ip, port).String())
+		if err == nil {
+			return conn, nil
+		}
+	}
+	return nil, err
+}
+
+// connect opens a tunnel to addr through the upstream HTTP proxy with
+// CONNECT
+func (s *server) connect(ctx context.Context, addr string) (net.Conn, error) {
+	d := net.Dialer{Timeout: s.dialTimeout}
+	conn, err := d.DialContext(ctx, "tcp", s.upstream.Host)
+	if err != nil {
+		return nil, fmt.Errorf("upstream %s: %w", s.upstream.Host, err)
+	}
+	conn.SetDeadline(time.Now().Add(s.dialTimeout))
+
+	req := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Opaque: addr},
+		Host:   addr,
+		Header: make(http.Header),
+	}
+	if u := s.upstream.User; u != nil {
+		password, _ := u.Password()
+		credentials := base64.StdEncoding.EncodeToString([]byte(u.Username() + ":" + password))
+		req.Header.Set("Proxy-Authorization", "Basic "+credentials)
+	}
+	if err := req.Write(conn); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("upstream %s: %w", s.upstream.Host, err)
+	}
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("upstream %s: %w", s.upstream.Host, err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		conn.Close()
+		return nil, &upstreamError{resp.StatusCode, fmt.Errorf("upstream %s: CONNECT %s: %s", s.upstream.Host, addr, resp.Status)}
+	}
+	conn.SetDeadline(time.Time{})
+	return &bufferedConn{Conn: conn, r: br}, nil
+}
+
+// upstreamError is a status the upstream proxy refused a tunnel with,
+// passed on to our client
+type upstreamError struct {
+	status int
+	err    error
+}
+
+func (e *upstreamError) Error() string { return e.err.Error() }
+func (e *upstreamError) Unwrap() error { return e.err }
+
+// newSOCKS returns a dialer through a SOCKS5 upstream. It passes names
+// on for the upstream to look up, for socks5:// as for socks5h://.
+func newSOCKS(u *url.URL, timeout time.Duration) (proxy.ContextDialer, error) {
+	d, err := proxy.FromURL(u, &net.Dialer{Timeout: timeout})
+	if err != nil {
+		return nil, err
+	}
+	cd, ok := d.(proxy.ContextDialer)
+	if !ok {
+		return nil, errors.New("SOCKS dialer does not support contexts")
+	}
+	return cd, nil
+}
+
+// splitHostPort splits addr, which must have a numeric port
+func splitHostPort(addr string) (string, uint16, error) {
+	host, portStr, err := net.SplitHostPort(addr)
+	if err != nil {
+		return "", 0, err
+	}
+	port, err := strconv.ParseUint(portStr, 10, 16)
+	if err != nil {
+		return "", 0, fmt.Errorf("bad port in %q", addr)
+	}
+	return host, uint16(port), nil
+}
+
+// bufferedConn is a tunnel whose first bytes may already be buffered,
+// behind the upstream's response or our client's CONNECT request
+type bufferedConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+func (c *bufferedConn) Read(p []byte) (int, error) { return c.r.Read(p) }
+
+// CloseWrite half-closes the connection underneath, so relay.Copy can
+func (c *bufferedConn) CloseWrite() error {
+	if cw, ok := c.Conn.(interface{ CloseWrite() error }); ok {
+		return cw.CloseWrite()
+	}
+	return c.Conn.Close()
+}
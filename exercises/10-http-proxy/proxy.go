@@ -0,0 +1,280 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/channyeintun/network-exercises/pkg/acl"
+	"github.com/channyeintun/network-exercises/pkg/relay"
+	"golang.org/x/net/proxy"
+)
+
+// server is a forward proxy: clients send it absolute-URL requests for
+// plain HTTP, and CONNECT to open a tunnel for anything else, like HTTPS
+type server struct {
+	rules       atomic.Pointer[acl.List]
+	credentials string                 // base64 of name:password clients must send; "" for none
+	upstream    *url.URL               // another proxy to send everything through; nil goes direct
+	socks       proxy.ContextDialer    // for a socks5 upstream
+	forward     *httputil.ReverseProxy // sends plain requests on
+	dialTimeout time.Duration
+	via         string // our Via entry, which also tells us a request looped back
+	access      *accessLog
+	verbose     bool
+
+	tunnels sync.WaitGroup
+	mu      sync.Mutex
+	open    map[net.Conn]struct{} // tunnels' client connections, closed if shutdown runs out of time
+	stats   stats
+}
+
+// stats counts requests by how they ended, and the bytes sent back
+type stats struct {
+	requests   atomic.Uint64 // plain HTTP
+	tunnels    atomic.Uint64 // CONNECT
+	denied     atomic.Uint64
+	authFailed atomic.Uint64
+	failed     atomic.Uint64 // destinations or the upstream couldn't be reached
+	bytesUp    atomic.Uint64 // through tunnels, from clients
+	bytesDown  atomic.Uint64 // to clients
+}
+
+func (s *stats) String() string {
+	return fmt.Sprintf("%d requests, %d tunnels: %d denied, %d failed authentication, %d failed; %d bytes up (tunnels), %d down",
+		s.requests.Load(), s.tunnels.Load(), s.denied.Load(), s.authFailed.Load(), s.failed.Load(), s.bytesUp.Load(), s.bytesDown.Load())
+}
+
+// newForward returns the reverse proxy that sends plain requests on. It
+// drops the hop-by-hop headers (Connection and the ones it names,
+// Proxy-Authorization, Keep-Alive...) that were for us, not the server.
+func (s *server) newForward(idle time.Duration) *httputil.ReverseProxy {
+	transport := &http.Transport{
+		DialContext:         s.dial,
+		MaxIdleConns:        100,
+		IdleConnTimeout:     idle,
+		TLSHandshakeTimeout: s.dialTimeout,
+	}
+	if s.upstream != nil && s.upstream.Scheme == "http" {
+		// An HTTP upstream takes plain requests as they are, with their
+		// absolute URLs; the transport adds its Proxy-Authorization
+		d := &net.Dialer{Timeout: s.dialTimeout}
+		transport.DialContext = d.DialContext
+		transport.Proxy = func(r *http.Request) (*url.URL, error) {
+			if err := s.check(hostPort(r.URL)); err != nil {
+				return nil, err
+			}
+			return s.upstream, nil
+		}
+	}
+	return &httputil.ReverseProxy{
+		Rewrite: func(pr *httputil.ProxyRequest) {
+			// The request already has the URL to send it to. Rewrite
+			// drops X-Forwarded-For and friends, and we don't add them:
+			// a forward proxy doesn't tell servers who its clients are.
+			pr.Out.Header.Add("Via", s.via)
+		},
+		ModifyResponse: func(resp *http.Response) error {
+			resp.Header.Add("Via", s.via)
+			return nil
+		},
+		Transport:    transport,
+		ErrorHandler: s.failed,
+	}
+}
+
+// ServeHTTP handles one request from a client
+func (s *server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	rec := &recorder{ResponseWriter: w}
+	user := "-"
+	defer func() { s.access.log(r, user, rec.status, rec.bytes, time.Since(start)) }()
+
+	if s.credentials != "" {
+		name, ok := s.authenticate(r)
+		if !ok {
+			s.stats.authFailed.Add(1)
+			rec.Header().Set("Proxy-Authenticate", `Basic realm="10-http-proxy"`)
+			http.Error(rec, "Proxy authentication required", http.StatusProxyAuthRequired)
+			return
+		}
+		user = name
+	}
+	if strings.Contains(strings.Join(r.Header.Values("Via"), ", "), s.via) {
+		// The request came back to us: someone pointed it at this proxy
+		http.Error(rec, "Loop detected: the request came through this proxy already", http.StatusLoopDetected)
+		return
+	}
+
+	switch {
+	case r.Method == http.MethodConnect:
+		s.tunnel(rec, r)
+	case r.URL.IsAbs() && (r.URL.Scheme == "http" || r.URL.Scheme == "https"):
+		s.stats.requests.Add(1)
+		s.forward.ServeHTTP(rec, r)
+		s.stats.bytesDown.Add(uint64(rec.bytes))
+	default:
+		// An origin-form request (GET /) is for a server, not a proxy
+		http.Error(rec, "This is a proxy: set it as your client's HTTP proxy, and request absolute http:// URLs or CONNECT host:port", http.StatusBadRequest)
+	}
+}
+
+// authenticate checks the request's Proxy-Authorization, returning the
+// name it gave
+func (s *server) authenticate(r *http.Request) (string, bool) {
+	scheme, credentials, _ := strings.Cut(r.Header.Get("Proxy-Authorization"), " ")
+	if !strings.EqualFold(scheme, "Basic") {
+		return "", false
+	}
+	ok := subtle.ConstantTimeCompare([]byte(credentials), []byte(s.credentials)) == 1
+	if !ok {
+		return "", false
+	}
+	decoded, _ := base64.StdEncoding.DecodeString(credentials)
+	name, _, _ := strings.Cut(string(decoded), ":")
+	return name, true
+}
+
+// tunnel connects to the CONNECT request's host:port, answers 200, and
+// from then on relays bytes both ways without looking at them, so TLS
+// runs end to end between the client and the server
+func (s *server) tunnel(w *recorder, r *http.Request) {
+	addr := r.Host
+	if _, _, err := splitHostPort(addr); err != nil {
+		http.Error(w, "CONNECT needs host:port", http.StatusBadRequest)
+		return
+	}
+	upstream, err := s.dial(r.Context(), "tcp", addr)
+	if err != nil {
+		s.failed(w, r, err)
+		return
+	}
+	defer upstream.Close()
+
+	client, brw, err := http.NewResponseController(w).Hijack()
+	if err != nil {
+		http.Error(w, "Can't tunnel on this connection", http.StatusInternalServerError)
+		return
+	}
+	defer client.Close()
+	s.tunnels.Add(1)
+	defer s.tunnels.Done()
+	s.mu.Lock()
+	s.open[client] = struct{}{}
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.open, client)
+		s.mu.Unlock()
+	}()
+
+	w.status = http.StatusOK
+	client.SetDeadline(time.Time{})
+	if _, err := client.Write([]byte("HTTP/1.1 200 Connection established\r\n\r\n")); err != nil {
+		return
+	}
+	s.stats.tunnels.Add(1)
+	var conn net.Conn = client
+	if brw.Reader.Buffered() > 0 {
+		// The client didn't wait for our answer: its first bytes are
+		// already read into the server's buffer
+		conn = &bufferedConn{Conn: client, r: brw.Reader}
+	}
+	if s.verbose {
+		log.Printf("🔀 %s → %s", r.RemoteAddr, addr)
+	}
+
+	up, down := relay.Copy(conn, upstream)
+	w.bytes = down
+	s.stats.bytesUp.Add(uint64(up))
+	s.stats.bytesDown.Add(uint64(down))
+	if s.verbose {
+		log.Printf("✅ %s → %s closed: %d bytes up, %d down", r.RemoteAddr, addr, up, down)
+	}
+}
+
+// failed answers a request we couldn't send on: 403 for the rules, the
+// upstream's own status for a tunnel it refused, 504 for a timeout and
+// 502 for anything else
+func (s *server) failed(w http.ResponseWriter, r *http.Request, err error) {
+	var denied *deniedError
+	var refused *upstreamError
+	var ne net.Error
+	status := http.StatusBadGateway
+	switch {
+	case errors.As(err, &denied):
+		s.stats.denied.Add(1)
+		log.Printf("🚫 %s: %s", r.RemoteAddr, denied)
+		http.Error(w, "Forbidden by the proxy's rules", http.StatusForbidden)
+		return
+	case errors.As(err, &refused) && refused.status != http.StatusProxyAuthRequired:
+		// A 407 would ask our client for credentials we need, so it
+		// stays a 502
+		status = refused.status
+	case errors.As(err, &ne) && ne.Timeout():
+		status = http.StatusGatewayTimeout
+	}
+	s.stats.failed.Add(1)
+	log.Printf("⚠️  %s %s %s: %v", r.RemoteAddr, r.Method, r.Host, err)
+	http.Error(w, fmt.Sprintf("%s: %v", http.StatusText(status), err), status)
+}
+
+// closeAll cuts every tunnel still open
+func (s *server) closeAll() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for conn := range s.open {
+		conn.Close()
+	}
+	return len(s.open)
+}
+
+// hostPort returns u's host:port, with the scheme's default port if it
+// has none
+func hostPort(u *url.URL) string {
+	if u.Port() != "" {
+		return u.Host
+	}
+	port := "80"
+	if u.Scheme == "https" {
+		port = "443"
+	}
+	return net.JoinHostPort(u.Hostname(), port)
+}
+
+// recorder remembers the status and how many body bytes went to the
+// client, for the access log
+type recorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+func (r *recorder) WriteHeader(code int) {
+	if r.status == 0 && code >= 200 {
+		r.status = code
+	}
+	r.ResponseWriter.WriteHeader(code)
+}
+
+func (r *recorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	n, err := r.ResponseWriter.Write(b)
+	r.bytes += int64(n)
+	return n, err
+}
+
+// Unwrap lets http.ResponseController reach Flush and Hijack
+func (r *recorder) Unwrap() http.ResponseWriter { return r.ResponseWriter }
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/channyeintun/network-exercises/pkg/relay"
+)
+
+// forwarder pipes every connection it accepts to the target
+type forwarder struct {
+	target      string
+	dialTimeout time.Duration
+	idle        time.Duration // 0: sessions may sit idle forever
+	slots       chan struct{} // one per session; nil for no limit
+
+	nextID   atomic.Uint64
+	sessions sync.WaitGroup
+	mu       sync.Mutex
+	active   map[uint64]*session
+	stats    stats
+}
+
+// session is one client's connection and the one we opened for it
+type session struct {
+	id     uint64
+	client net.Conn
+	server net.Conn // nil until the target accepts
+	start  time.Time
+}
+
+// stats counts sessions by how they ended, and the bytes they carried
+type stats struct {
+	sessions  atomic.Uint64
+	rejected  atomic.Uint64 // over -max-sessions
+	failed    atomic.Uint64 // the target didn't accept
+	idled     atomic.Uint64 // closed by -idle-timeout
+	bytesUp   atomic.Uint64 // client to target, in finished sessions
+	bytesDown atomic.Uint64 // target to client
+}
+
+func (f *forwarder) String() string {
+	f.mu.Lock()
+	active := len(f.active)
+	f.mu.Unlock()
+	s := &f.stats
+	return fmt.Sprintf("%d sessions (%d active): %d rejected, %d failed to connect, %d idle timeouts; %d bytes up, %d down",
+		s.sessions.Load(), active, s.rejected.Load(), s.failed.Load(), s.idled.Load(), s.bytesUp.Load(), s.bytesDown.Load())
+}
+
+// serve accepts connections until ln is closed
+func (f *forwarder) serve(ctx context.Context, ln net.Listener) {
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			log.Printf("Accept error: %v", err)
+			continue
+		}
+		if f.slots != nil {
+			select {
+			case f.slots <- struct{}{}:
+			default:
+				// Full: hanging up at once beats keeping the client
+				// waiting on a connection nobody serves
+				f.stats.rejected.Add(1)
+				log.Printf("🚫 %s: %d sessions open already, refusing", conn.RemoteAddr(), cap(f.slots))
+				conn.Close()
+				continue
+			}
+		}
+		s := &session{id: f.nextID.Add(1), client: conn, start: time.Now()}
+		f.mu.Lock()
+		f.active[s.id] = s
+		f.mu.Unlock()
+		f.sessions.Add(1)
+		go func() {
+			defer f.sessions.Done()
+			defer func() {
+				f.mu.Lock()
+				delete(f.active, s.id)
+				f.mu.Unlock()
+				if f.slots != nil {
+					<-f.slots
+				}
+			}()
+			f.handle(ctx, s)
+		}()
+	}
+}
+
+// handle connects to the target for s and copies bytes both ways until
+// both sides are done, or neither has sent anything for the idle timeout
+func (f *forwarder) handle(ctx context.Context, s *session) {
+	defer s.client.Close()
+	from := s.client.RemoteAddr()
+	d := net.Dialer{Timeout: f.dialTimeout}
+	server, err := d.DialContext(ctx, "tcp", f.target)
+	if err != nil {
+		f.stats.failed.Add(1)
+		log.Printf("❌ #%d %s: can't reach %s: %v", s.id, from, f.target, err)
+		return
+	}
+	defer server.Close()
+	f.mu.Lock()
+	s.server = server
+	f.mu.Unlock()
+	f.stats.sessions.Add(1)
+	log.Printf("🔗 #%d %s → %s (via %s)", s.id, from, f.target, server.LocalAddr())
+
+	up, down, err := relay.CopyIdle(s.client, server, f.idle)
+	f.stats.bytesUp.Add(uint64(up))
+	f.stats.bytesDown.Add(uint64(down))
+	took := time.Since(s.start).Round(time.Millisecond)
+	if errors.Is(err, relay.ErrIdle) {
+		f.stats.idled.Add(1)
+		log.Printf("⏱️  #%d %s idle for %v, closed after %v: %d bytes up, %d down", s.id, from, f.idle, took, up, down)
+		return
+	}
+	log.Printf("✅ #%d %s closed after %v: %d bytes up, %d down", s.id, from, took, up, down)
+}
+
+// closeAll cuts every session still open
+func (f *forwarder) closeAll() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, s := range f.active {
+		s.client.Close()
+		if s.server != nil {
+			s.server.Close()
+		}
+	}
+	return len(f.active)
+}
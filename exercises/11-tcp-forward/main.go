@@ -0,0 +1,105 @@
+// Package main implements a TCP port forwarder.
+// This exercise teaches the building block under tunnels and sidecars.
+//
+// Learning objectives:
+// - Pipe bytes both ways with io.Copy, half-closing each side when the other finishes
+// - Run many sessions at once, capping how many
+// - Close sessions that go idle without cutting one-way transfers
+// - Account for the bytes each session carries
+//
+// Run: go run . -listen :9000 -target 127.0.0.1:8080
+// Test: go run ../01-tcp-echo &  go run . -listen :9000 -target 127.0.0.1:8080 &  nc localhost 9000
+// Or:  go run . -listen 127.0.0.1:5432 -target db.internal:5432   (reach a database as if it were local)
+// Or:  go run . -listen :9000 -target example.com:80 -idle-timeout 30s -max-sessions 10
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"net"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+func main() {
+	listen := flag.String("listen", ":9000", "Address to accept connections on")
+	target := flag.String("target", "", "host:port to forward each connection to")
+	idle := flag.Duration("idle-timeout", 5*time.Minute, "Close a session once neither side has sent anything for this long; 0 never")
+	dialTimeout := flag.Duration("dial-timeout", 5*time.Second, "How long to wait for the target to accept")
+	maxSessions := flag.Int("max-sessions", 100, "Sessions to allow at once, refusing more; 0 for no limit")
+	shutdownTimeout := flag.Duration("shutdown-timeout", 10*time.Second, "How long shutdown waits for open sessions before cutting them")
+	statsEvery := flag.Duration("stats", time.Minute, "Log session counts and bytes this often; 0 to only log them at shutdown")
+	flag.Parse()
+
+	if *target == "" {
+		log.Fatal("Give the address to forward to with -target host:port")
+	}
+	if _, _, err := net.SplitHostPort(*target); err != nil {
+		log.Fatalf("Invalid -target %q: %v", *target, err)
+	}
+	if *maxSessions < 0 || *idle < 0 {
+		log.Fatal("-max-sessions and -idle-timeout can't be negative")
+	}
+
+	f := &forwarder{
+		target:      *target,
+		dialTimeout: *dialTimeout,
+		idle:        *idle,
+		active:      make(map[uint64]*session),
+	}
+	if *maxSessions > 0 {
+		f.slots = make(chan struct{}, *maxSessions)
+	}
+
+	ln, err := net.Listen("tcp", *listen)
+	if err != nil {
+		log.Fatalf("Failed to listen on %s: %v", *listen, err)
+	}
+	log.Printf("🚀 Forwarding %s → %s", ln.Addr(), *target)
+	log.Printf("   Idle timeout %v, at most %d sessions (0: no limit)", *idle, *maxSessions)
+	log.Println("   Press Ctrl+C to shutdown")
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	if *statsEvery > 0 {
+		go func() {
+			ticker := time.NewTicker(*statsEvery)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ticker.C:
+					log.Printf("📊 Stats: %s", f)
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+	served := make(chan struct{})
+	go func() {
+		defer close(served)
+		f.serve(ctx, ln)
+	}()
+
+	<-ctx.Done()
+	log.Printf("🛑 Shutting down, waiting up to %v for open sessions...", *shutdownTimeout)
+	ln.Close()
+	done := make(chan struct{})
+	go func() {
+		// serve adds each session it accepts, so it has to be done
+		// accepting before the count can be waited on
+		<-served
+		f.sessions.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(*shutdownTimeout):
+		log.Printf("⚠️  Cutting %d sessions still open", f.closeAll())
+		<-done
+	}
+	log.Printf("📊 Final Stats: %s", f)
+	log.Println("✅ Forwarder shutdown complete")
+}
@@ -0,0 +1,211 @@
+// Package acl allows or denies network destinations by name, IP range
+// and port, for proxies that shouldn't take their clients anywhere:
+//
+//	list, err := acl.Load("rules.txt")
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	ips, why := list.Filter("example.com", resolved, 443)
+//	if len(ips) == 0 {
+//		return fmt.Errorf("denied (%s)", why)
+//	}
+//
+// Check the addresses a name resolved to, and dial only those, so a name
+// can't resolve its way past a CIDR rule.
+package acl
+
+import (
+	"fmt"
+	"net/netip"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Rule allows or denies destinations: a host pattern and, optionally,
+// ports. The host is * (anything), a name (example.com), every name
+// under one (*.example.com), an IP or a CIDR; IPv6 goes in brackets
+// when ports follow ([2001:db8::/32]:443).
+type Rule struct {
+	allow  bool
+	text   string       // as written, for logs
+	any    bool         // *
+	name   string       // lower case, no trailing dot
+	suffix bool         // *.name: names under name, not name itself
+	prefix netip.Prefix // an IP or CIDR
+	ports  []portRange  // nil: any port
+}
+
+type portRange struct{ lo, hi uint16 }
+
+// String returns the rule as written, after "deny " if it denies
+func (r Rule) String() string {
+	if r.allow {
+		return r.text
+	}
+	return "deny " + r.text
+}
+
+// List is a list of rules; the first to match a destination decides. A
+// destination no rule matches is denied, unless the list is empty.
+type List []Rule
+
+// Allowed reports whether port on ip may be reached, a destination that
+// was asked for by name (or "" for an IP, and ip may be the zero Addr
+// for a name that wasn't looked up), and the rule that decided.
+func (l List) Allowed(name string, ip netip.Addr, port uint16) (bool, string) {
+	if len(l) == 0 {
+		return true, ""
+	}
+	name = strings.ToLower(strings.TrimSuffix(name, "."))
+	ip = ip.Unmap().WithZone("")
+	for _, r := range l {
+		if r.matches(name, ip, port) {
+			return r.allow, r.String()
+		}
+	}
+	return false, "no rule allows it"
+}
+
+// Filter returns the addresses name resolved to that may be reached on
+// port, and if it leaves out any, the rule that denied the first
+func (l List) Filter(name string, ips []netip.Addr, port uint16) ([]netip.Addr, string) {
+	var allowed []netip.Addr
+	var why string
+	for _, ip := range ips {
+		if ok, rule := l.Allowed(name, ip, port); ok {
+			allowed = append(allowed, ip)
+		} else if why == "" {
+			why = rule
+		}
+	}
+	return allowed, why
+}
+
+func (r *Rule) matches(name string, ip netip.Addr, port uint16) bool {
+	if r.ports != nil {
+		in := false
+		for _, pr := range r.ports {
+			if port >= pr.lo && port <= pr.hi {
+				in = true
+				break
+			}
+		}
+		if !in {
+			return false
+		}
+	}
+	switch {
+	case r.any:
+		return true
+	case r.prefix.IsValid():
+		return ip.IsValid() && r.prefix.Contains(ip)
+	case r.suffix:
+		return strings.HasSuffix(name, "."+r.name)
+	}
+	return name != "" && name == r.name
+}
+
+// Load reads a rules file: one "allow" or "deny" and a destination per
+// line, with blank lines and # comments skipped
+func Load(path string) (List, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var list List
+	for i, line := range strings.Split(string(data), "\n") {
+		line, _, _ = strings.Cut(line, "#")
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) != 2 || (fields[0] != "allow" && fields[0] != "deny") {
+			return nil, fmt.Errorf("%s:%d: want \"allow\" or \"deny\" and a destination", path, i+1)
+		}
+		r, err := ParseRule(fields[0] == "allow", fields[1])
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, i+1, err)
+		}
+		list = append(list, r)
+	}
+	return list, nil
+}
+
+// ParseAllow parses space-separated destinations, each allowed, as an
+// -allow flag gives them
+func ParseAllow(s string) (List, error) {
+	var list List
+	for _, dst := range strings.Fields(s) {
+		r, err := ParseRule(true, dst)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, r)
+	}
+	return list, nil
+}
+
+// ParseRule parses a destination: host, host:ports or [IPv6]:ports,
+// where ports is *, a port, a range (8000-8080) or a comma-separated
+// list of them
+func ParseRule(allow bool, s string) (Rule, error) {
+	r := Rule{allow: allow, text: s}
+	host, ports := s, ""
+	switch {
+	case strings.HasPrefix(s, "["):
+		end := strings.Index(s, "]")
+		if end < 0 {
+			return Rule{}, fmt.Errorf("%q: missing ]", s)
+		}
+		host, ports = s[1:end], s[end+1:]
+		if ports != "" {
+			var ok bool
+			if ports, ok = strings.CutPrefix(ports, ":"); !ok {
+				return Rule{}, fmt.Errorf("%q: want ]:ports", s)
+			}
+		}
+	case strings.Count(s, ":") == 1:
+		host, ports, _ = strings.Cut(s, ":")
+	}
+
+	switch {
+	case host == "*":
+		r.any = true
+	case strings.Contains(host, "/"):
+		prefix, err := netip.ParsePrefix(host)
+		if err != nil {
+			return Rule{}, fmt.Errorf("%q isn't a CIDR", host)
+		}
+		r.prefix = prefix.Masked()
+	default:
+		if ip, err := netip.ParseAddr(host); err == nil {
+			ip = ip.Unmap().WithZone("")
+			r.prefix = netip.PrefixFrom(ip, ip.BitLen())
+			break
+		}
+		name := strings.ToLower(strings.TrimSuffix(host, "."))
+		name, r.suffix = strings.CutPrefix(name, "*.")
+		if name == "" || strings.ContainsAny(name, "*/ :[]") {
+			return Rule{}, fmt.Errorf("%q isn't *, a name, *.name, an IP or a CIDR", host)
+		}
+		r.name = name
+	}
+
+	if ports == "" || ports == "*" {
+		return r, nil
+	}
+	for _, p := range strings.Split(ports, ",") {
+		lo, hi, isRange := strings.Cut(p, "-")
+		if !isRange {
+			hi = lo
+		}
+		l, err1 := strconv.ParseUint(lo, 10, 16)
+		h, err2 := strconv.ParseUint(hi, 10, 16)
+		if err1 != nil || err2 != nil || l > h {
+			return Rule{}, fmt.Errorf("%q isn't a port or range", p)
+		}
+		r.ports = append(r.ports, portRange{uint16(l), uint16(h)})
+	}
+	return r, nil
+}
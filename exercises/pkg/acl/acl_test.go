@@ -0,0 +1,109 @@
+package acl
+
+import (
+	"net/netip"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func mustList(t *testing.T, rules ...string) List {
+	t.Helper()
+	var list List
+	for i := 0; i < len(rules); i += 2 {
+		r, err := ParseRule(rules[i] == "allow", rules[i+1])
+		if err != nil {
+			t.Fatalf("ParseRule(%q): %v", rules[i+1], err)
+		}
+		list = append(list, r)
+	}
+	return list
+}
+
+func TestAllowed(t *testing.T) {
+	list := mustList(t,
+		"deny", "10.0.0.0/8",
+		"deny", "[fc00::/7]",
+		"allow", "*.example.com:80,443",
+		"allow", "example.org",
+		"allow", "192.0.2.1:8000-8080",
+		"allow", "[2001:db8::1]:22",
+	)
+	tests := []struct {
+		name string
+		ip   string
+		port uint16
+		want bool
+	}{
+		{"www.example.com", "93.184.216.34", 443, true},
+		{"WWW.Example.COM.", "93.184.216.34", 80, true},
+		{"www.example.com", "93.184.216.34", 22, false}, // port not listed
+		{"example.com", "93.184.216.34", 443, false},    // *.name is only the names under it
+		{"www.example.com", "10.1.2.3", 443, false},     // resolves inward: the deny comes first
+		{"example.org", "203.0.113.9", 12345, true},     // any port
+		{"", "192.0.2.1", 8080, true},                   // range
+		{"", "192.0.2.1", 8081, false},                  // past it
+		{"", "::ffff:192.0.2.1", 8000, true},            // IPv4-mapped IPv6 is the IPv4 address
+		{"", "2001:db8::1", 22, true},                   // bracketed IPv6 with a port
+		{"", "fd00::1", 443, false},                     // denied IPv6 range
+		{"example.org", "", 443, true},                  // a name not looked up
+		{"", "203.0.113.9", 443, false},                 // nothing matches
+	}
+	for _, tt := range tests {
+		var ip netip.Addr
+		if tt.ip != "" {
+			ip = netip.MustParseAddr(tt.ip)
+		}
+		if got, why := list.Allowed(tt.name, ip, tt.port); got != tt.want {
+			t.Errorf("Allowed(%q, %s, %d) = %v (%s), want %v", tt.name, tt.ip, tt.port, got, why, tt.want)
+		}
+	}
+	if ok, _ := List(nil).Allowed("anything", netip.Addr{}, 1); !ok {
+		t.Error("an empty list denied a destination")
+	}
+}
+
+func TestFilter(t *testing.T) {
+	list := mustList(t, "deny", "127.0.0.0/8", "allow", "*")
+	ips := []netip.Addr{netip.MustParseAddr("127.0.0.1"), netip.MustParseAddr("93.184.216.34")}
+	got, why := list.Filter("rebind.example", ips, 80)
+	if !slices.Equal(got, ips[1:]) || why != "deny 127.0.0.0/8" {
+		t.Errorf("Filter = %v, %q", got, why)
+	}
+}
+
+func TestParseRuleErrors(t *testing.T) {
+	for _, s := range []string{"", "*.", "a*b.com", "bad:port:x", "10.0.0.0/33", "[::1", "[::1]443", "host:0-", "host:90-80", "host:65536"} {
+		if _, err := ParseRule(true, s); err == nil {
+			t.Errorf("ParseRule(%q) succeeded", s)
+		}
+	}
+}
+
+func TestLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rules.txt")
+	os.WriteFile(path, []byte("# comment\n\ndeny 10.0.0.0/8  # private\nallow *:443\n"), 0o644)
+	list, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 2 || list[0].String() != "deny 10.0.0.0/8" || list[1].String() != "*:443" {
+		t.Errorf("Load = %v", list)
+	}
+
+	os.WriteFile(path, []byte("allow *:443\npermit *\n"), 0o644)
+	if _, err := Load(path); err == nil || err.Error() != path+`:2: want "allow" or "deny" and a destination` {
+		t.Errorf("Load of a bad line: %v", err)
+	}
+}
+
+func TestParseAllow(t *testing.T) {
+	list, err := ParseAllow(" *.example.com:80,443  10.0.0.0/8:22 ")
+	if err != nil || len(list) != 2 {
+		t.Fatalf("ParseAllow = %v, %v", list, err)
+	}
+	if ok, _ := list.Allowed("", netip.MustParseAddr("10.9.9.9"), 22); !ok {
+		t.Error("10.9.9.9:22 denied")
+	}
+}
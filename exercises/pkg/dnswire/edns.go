@@ -0,0 +1,70 @@
+package dnswire
+
+// EDNS is a message's EDNS(0) OPT pseudo-record as it's used (RFC
+// 6891): the record's class holds the largest UDP reply the sender
+// takes, and its TTL the extended RCODE, EDNS version and flags
+//
+//	extended RCODE (8) | version (8) | DO | Z (15)
+type EDNS struct {
+	// UDPSize is the largest UDP payload the sender can take; 1232
+	// avoids IP fragmentation on nearly every path (DNS Flag Day 2020)
+	UDPSize uint16
+	Version uint8
+	DO      bool // DNSSEC OK: include DNSSEC records
+	Options []Option
+}
+
+// Common EDNS option codes
+const (
+	OptionNSID         uint16 = 3  // the server's identifier; ask by sending it empty
+	OptionClientSubnet uint16 = 8  // the client's subnet, for geo-aware answers
+	OptionCookie       uint16 = 10 // a client/server cookie against spoofing
+	OptionPadding      uint16 = 12 // zero bytes, to hide message sizes when encrypted
+)
+
+var optionNames = map[uint16]string{
+	OptionNSID: "NSID", OptionClientSubnet: "CLIENT-SUBNET", OptionCookie: "COOKIE", OptionPadding: "PADDING",
+}
+
+// EDNS returns the message's OPT record, decoded, and whether it has one
+func (m *Message) EDNS() (EDNS, bool) {
+	rr := m.opt()
+	if rr == nil {
+		return EDNS{}, false
+	}
+	e := EDNS{
+		UDPSize: uint16(rr.Class),
+		Version: uint8(rr.TTL >> 16),
+		DO:      rr.TTL&(1<<15) != 0,
+	}
+	if opt, ok := rr.Data.(*OPT); ok {
+		e.Options = opt.Options
+	}
+	return e, true
+}
+
+// SetEDNS gives the message an OPT record, replacing any it has. Pack
+// fills in the extended RCODE from the header's.
+func (m *Message) SetEDNS(e EDNS) {
+	var ttl uint32
+	ttl |= uint32(e.Version) << 16
+	if e.DO {
+		ttl |= 1 << 15
+	}
+	rr := RR{Name: ".", Type: TypeOPT, Class: Class(e.UDPSize), TTL: ttl, Data: &OPT{Options: e.Options}}
+	if existing := m.opt(); existing != nil {
+		*existing = rr
+		return
+	}
+	m.Additional = append(m.Additional, rr)
+}
+
+// opt returns the message's OPT record, nil without one
+func (m *Message) opt() *RR {
+	for i := range m.Additional {
+		if m.Additional[i].Type == TypeOPT {
+			return &m.Additional[i]
+		}
+	}
+	return nil
+}
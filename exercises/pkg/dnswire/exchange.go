@@ -0,0 +1,204 @@
+package dnswire
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"time"
+)
+
+// Reply is a server's answer and how it came
+type Reply struct {
+	Msg     *Message
+	Size    int           // bytes, as received
+	Network string        // "udp" or "tcp"
+	RTT     time.Duration // query sent to reply read
+}
+
+// Client sends queries and waits for their replies: exercise 06 asks
+// with it and exercise 07 forwards with it
+type Client struct {
+	Timeout time.Duration // per try; default 2s
+	Tries   int           // UDP sends before giving up; default 1
+	TCP     bool          // skip UDP
+	// IgnoreTC takes a truncated UDP reply as it is rather than asking
+	// again over TCP
+	IgnoreTC bool
+	// Note, if set, is told when a query is sent again or a reply skipped
+	Note func(reason string)
+}
+
+// Exchange sends query to server (host:port) and returns the reply:
+// over UDP, sent again after each timeout, then over TCP if the reply
+// was truncated, or straight over TCP with TCP set
+func (c *Client) Exchange(ctx context.Context, server string, query *Message) (*Reply, error) {
+	packed, err := query.Pack()
+	if err != nil {
+		return nil, err
+	}
+	if c.TCP {
+		return c.exchangeTCP(ctx, server, query, packed)
+	}
+
+	tries := max(c.Tries, 1)
+	var lastErr error
+	for try := 1; try <= tries; try++ {
+		r, err := c.exchangeUDP(ctx, server, query, packed)
+		if err == nil {
+			if r.Msg.Truncated && !c.IgnoreTC {
+				c.note("Truncated, retrying in TCP mode.")
+				return c.exchangeTCP(ctx, server, query, packed)
+			}
+			return r, nil
+		}
+		var netErr net.Error
+		if !errors.As(err, &netErr) || !netErr.Timeout() || ctx.Err() != nil {
+			return nil, err
+		}
+		lastErr = err
+		if try < tries {
+			c.note(fmt.Sprintf("no reply from %s after %v, trying again", server, c.timeout()))
+		}
+	}
+	return nil, fmt.Errorf("no servers could be reached: %w", lastErr)
+}
+
+func (c *Client) timeout() time.Duration {
+	if c.Timeout > 0 {
+		return c.Timeout
+	}
+	return 2 * time.Second
+}
+
+func (c *Client) note(reason string) {
+	if c.Note != nil {
+		c.Note(reason)
+	}
+}
+
+// exchangeUDP sends the query in one datagram and waits for the reply.
+// The socket is connected, so the kernel drops datagrams from anyone but
+// the server; a reply must still carry the query's ID and question, or
+// it's someone guessing at them (a spoofed answer) and is skipped.
+func (c *Client) exchangeUDP(ctx context.Context, server string, query *Message, packed []byte) (*Reply, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout())
+	defer cancel()
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "udp", server)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	deadline, _ := ctx.Deadline()
+	conn.SetDeadline(deadline)
+
+	start := time.Now()
+	if _, err := conn.Write(packed); err != nil {
+		return nil, err
+	}
+	buf := make([]byte, 65535)
+	for {
+		n, err := conn.Read(buf)
+		if err != nil {
+			return nil, err
+		}
+		rtt := time.Since(start)
+		msg, err := Parse(buf[:n])
+		if err != nil {
+			c.note(fmt.Sprintf("Warning: ignoring a reply that doesn't parse: %v", err))
+			continue
+		}
+		if reason := mismatch(query, msg); reason != "" {
+			c.note("Warning: " + reason + "; ignoring it")
+			continue
+		}
+		return &Reply{Msg: msg, Size: n, Network: "udp", RTT: rtt}, nil
+	}
+}
+
+// exchangeTCP sends the query over a new TCP connection
+func (c *Client) exchangeTCP(ctx context.Context, server string, query *Message, packed []byte) (*Reply, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout())
+	defer cancel()
+	var d net.Dialer
+	start := time.Now()
+	conn, err := d.DialContext(ctx, "tcp", server)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	deadline, _ := ctx.Deadline()
+	conn.SetDeadline(deadline)
+
+	if err := WriteTCP(conn, packed); err != nil {
+		return nil, err
+	}
+	buf, err := ReadTCP(conn)
+	if err != nil {
+		return nil, fmt.Errorf("reading reply: %w", err)
+	}
+	rtt := time.Since(start)
+	msg, err := Parse(buf)
+	if err != nil {
+		return nil, err
+	}
+	if reason := mismatch(query, msg); reason != "" {
+		return nil, errors.New(reason)
+	}
+	return &Reply{Msg: msg, Size: len(buf), Network: "tcp", RTT: rtt}, nil
+}
+
+// Over TCP each message is preceded by its length in two bytes (RFC
+// 1035 section 4.2.2), so one can be up to 64 KiB and several can
+// follow each other on a connection.
+
+// WriteTCP writes one packed message to a TCP connection
+func WriteTCP(w io.Writer, msg []byte) error {
+	if len(msg) > 0xffff {
+		return fmt.Errorf("dnswire: %d-byte message is too long for TCP", len(msg))
+	}
+	_, err := w.Write(append(binary.BigEndian.AppendUint16(nil, uint16(len(msg))), msg...))
+	return err
+}
+
+// ReadTCP reads one message from a TCP connection; io.EOF means the
+// peer closed it cleanly, between messages
+func ReadTCP(r io.Reader) ([]byte, error) {
+	var length [2]byte
+	if _, err := io.ReadFull(r, length[:]); err != nil {
+		return nil, err
+	}
+	buf := make([]byte, binary.BigEndian.Uint16(length[:]))
+	if _, err := io.ReadFull(r, buf); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	return buf, nil
+}
+
+// mismatch says why msg isn't the reply to query, or "" if it is
+func mismatch(query, msg *Message) string {
+	switch {
+	case !msg.Response:
+		return "got a query, not a reply"
+	case msg.ID != query.ID:
+		return fmt.Sprintf("reply ID %d doesn't match query ID %d", msg.ID, query.ID)
+	case len(msg.Questions) == 0 && msg.RCode != RCodeSuccess:
+		// Some servers drop the question from errors, FORMERR especially
+		return ""
+	case len(msg.Questions) != len(query.Questions):
+		return fmt.Sprintf("reply has %d questions", len(msg.Questions))
+	}
+	for i, q := range msg.Questions {
+		want := query.Questions[i]
+		if CanonicalName(q.Name) != CanonicalName(want.Name) || q.Type != want.Type || q.Class != want.Class {
+			return fmt.Sprintf("reply is to %s, not %s", q, want)
+		}
+	}
+	return ""
+}
@@ -0,0 +1,142 @@
+package dnswire
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"net/netip"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeServer answers A queries on a local UDP socket and a TCP listener
+// sharing its port: over UDP it first sends a reply with the wrong ID,
+// then a truncated one, and over TCP the whole answer
+func fakeServer(t *testing.T) string {
+	t.Helper()
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ln, err := net.Listen("tcp", pc.LocalAddr().String())
+	if err != nil {
+		pc.Close()
+		t.Skipf("TCP port %s is taken: %v", pc.LocalAddr(), err)
+	}
+	t.Cleanup(func() { pc.Close(); ln.Close() })
+
+	answer := func(query *Message) *Message {
+		reply := &Message{Header: query.Header, Questions: query.Questions}
+		reply.Response = true
+		reply.Answers = []RR{{Name: query.Questions[0].Name, Type: TypeA, Class: ClassINET, TTL: 60, Data: &A{netip.MustParseAddr("192.0.2.1")}}}
+		return reply
+	}
+	go func() {
+		buf := make([]byte, 512)
+		for {
+			n, addr, err := pc.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			query, err := Parse(buf[:n])
+			if err != nil {
+				continue
+			}
+			spoofed := answer(query)
+			spoofed.ID++
+			truncated := answer(query)
+			truncated.Answers, truncated.Truncated = nil, true
+			for _, m := range []*Message{spoofed, truncated} {
+				b, _ := m.Pack()
+				pc.WriteTo(b, addr)
+			}
+		}
+	}()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				b, err := ReadTCP(conn)
+				if err != nil {
+					return
+				}
+				query, err := Parse(b)
+				if err != nil {
+					return
+				}
+				b, _ = answer(query).Pack()
+				WriteTCP(conn, b)
+			}()
+		}
+	}()
+	return pc.LocalAddr().String()
+}
+
+func TestExchange(t *testing.T) {
+	server := fakeServer(t)
+	query := &Message{
+		Header:    Header{ID: 4242, RecursionDesired: true},
+		Questions: []Question{{Name: "example.com.", Type: TypeA, Class: ClassINET}},
+	}
+	var notes []string
+	c := &Client{Timeout: time.Second, Note: func(reason string) { notes = append(notes, reason) }}
+
+	r, err := c.Exchange(context.Background(), server, query)
+	if err != nil {
+		t.Fatalf("Exchange: %v", err)
+	}
+	if r.Network != "tcp" || len(r.Msg.Answers) != 1 {
+		t.Errorf("got %d answers over %s, want the whole answer over TCP", len(r.Msg.Answers), r.Network)
+	}
+	if len(notes) != 2 || !strings.Contains(notes[0], "doesn't match") || !strings.Contains(notes[1], "Truncated") {
+		t.Errorf("notes = %q, want a skipped spoof then a TCP retry", notes)
+	}
+
+	c.IgnoreTC = true
+	r, err = c.Exchange(context.Background(), server, query)
+	if err != nil {
+		t.Fatalf("Exchange with IgnoreTC: %v", err)
+	}
+	if r.Network != "udp" || !r.Msg.Truncated {
+		t.Errorf("with IgnoreTC got a reply over %s, truncated %v", r.Network, r.Msg.Truncated)
+	}
+}
+
+func TestExchangeTimeout(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pc.Close()
+	query := &Message{Questions: []Question{{Name: "example.com.", Type: TypeA, Class: ClassINET}}}
+	tries := 0
+	c := &Client{Timeout: 20 * time.Millisecond, Tries: 3, Note: func(string) { tries++ }}
+	if _, err := c.Exchange(context.Background(), pc.LocalAddr().String(), query); err == nil {
+		t.Fatal("Exchange with a silent server succeeded")
+	}
+	if tries != 2 {
+		t.Errorf("noted %d retries, want 2", tries)
+	}
+}
+
+func TestTCPFraming(t *testing.T) {
+	var buf bytes.Buffer
+	WriteTCP(&buf, []byte("abc"))
+	WriteTCP(&buf, nil)
+	if got := buf.Bytes(); !bytes.Equal(got, []byte{0, 3, 'a', 'b', 'c', 0, 0}) {
+		t.Errorf("framed = % x", got)
+	}
+	for _, want := range []string{"abc", ""} {
+		if got, err := ReadTCP(&buf); err != nil || string(got) != want {
+			t.Errorf("ReadTCP = %q, %v, want %q", got, err, want)
+		}
+	}
+	if _, err := ReadTCP(bytes.NewReader([]byte{0, 5, 'a'})); err == nil {
+		t.Error("ReadTCP of a cut-off message succeeded")
+	}
+}
@@ -0,0 +1,313 @@
+// Package dnswire encodes and decodes DNS messages (RFC 1035) by hand:
+// the 12-byte header, questions, resource records with name compression,
+// and the EDNS(0) OPT pseudo-record (RFC 6891).
+//
+// It is the wire format behind exercise 06's dig-like client and exercise
+// 07's server, written out rather than imported so the layout of every
+// field can be read:
+//
+//	query := &dnswire.Message{
+//		Header:    dnswire.Header{ID: 0xbeef, RecursionDesired: true},
+//		Questions: []dnswire.Question{{Name: "example.com.", Type: dnswire.TypeA, Class: dnswire.ClassINET}},
+//	}
+//	query.SetEDNS(dnswire.EDNS{UDPSize: 1232})
+//	packet, _ := query.Pack()
+//	// ... send packet over UDP, read the reply ...
+//	reply, err := dnswire.Parse(buf[:n])
+//	for _, rr := range reply.Answers {
+//		fmt.Println(rr)
+//	}
+//
+// Client does the sending and reading, falling back to TCP for a
+// truncated reply:
+//
+//	r, err := (&dnswire.Client{Timeout: time.Second}).Exchange(ctx, "1.1.1.1:53", query)
+//	for _, rr := range r.Msg.Answers {
+//		fmt.Println(rr)
+//	}
+package dnswire
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// HeaderLen is the size of a message's fixed header
+const HeaderLen = 12
+
+// ErrShort reports a message that ends in the middle of something
+var ErrShort = errors.New("message too short")
+
+// Header is a message's fixed header, less the section counts, which
+// Pack fills in from the sections themselves
+type Header struct {
+	ID                 uint16
+	Response           bool // QR: a reply, not a query
+	Opcode             Opcode
+	Authoritative      bool // AA: from a server authoritative for the name
+	Truncated          bool // TC: cut to fit a UDP datagram; ask again over TCP
+	RecursionDesired   bool // RD: resolve it fully rather than refer me on
+	RecursionAvailable bool // RA: the server would have
+	AuthenticData      bool // AD: the resolver validated it with DNSSEC
+	CheckingDisabled   bool // CD: return it without DNSSEC validation
+	// RCode is the response code. Codes over 15 (e.g. BADVERS) carry
+	// their upper 8 bits in the OPT record, so need one.
+	RCode RCode
+}
+
+// flags packs the header's second 16 bits:
+//
+//	QR | Opcode (4) | AA | TC | RD | RA | Z | AD | CD | RCODE (4)
+func (h *Header) flags() uint16 {
+	f := uint16(h.Opcode&0xf)<<11 | uint16(h.RCode&0xf)
+	for _, bit := range []struct {
+		set  bool
+		mask uint16
+	}{
+		{h.Response, 1 << 15},
+		{h.Authoritative, 1 << 10},
+		{h.Truncated, 1 << 9},
+		{h.RecursionDesired, 1 << 8},
+		{h.RecursionAvailable, 1 << 7},
+		{h.AuthenticData, 1 << 5},
+		{h.CheckingDisabled, 1 << 4},
+	} {
+		if bit.set {
+			f |= bit.mask
+		}
+	}
+	return f
+}
+
+// setFlags unpacks the header's second 16 bits
+func (h *Header) setFlags(f uint16) {
+	h.Response = f&(1<<15) != 0
+	h.Opcode = Opcode(f >> 11 & 0xf)
+	h.Authoritative = f&(1<<10) != 0
+	h.Truncated = f&(1<<9) != 0
+	h.RecursionDesired = f&(1<<8) != 0
+	h.RecursionAvailable = f&(1<<7) != 0
+	h.AuthenticData = f&(1<<5) != 0
+	h.CheckingDisabled = f&(1<<4) != 0
+	h.RCode = RCode(f & 0xf)
+}
+
+// FlagString lists the header's set flags as dig does, e.g. "qr rd ra"
+func (h *Header) FlagString() string {
+	var flags []string
+	for _, f := range []struct {
+		set  bool
+		name string
+	}{
+		{h.Response, "qr"},
+		{h.Authoritative, "aa"},
+		{h.Truncated, "tc"},
+		{h.RecursionDesired, "rd"},
+		{h.RecursionAvailable, "ra"},
+		{h.AuthenticData, "ad"},
+		{h.CheckingDisabled, "cd"},
+	} {
+		if f.set {
+			flags = append(flags, f.name)
+		}
+	}
+	return strings.Join(flags, " ")
+}
+
+// Question is what a query asks for
+type Question struct {
+	Name  string
+	Type  Type
+	Class Class
+}
+
+func (q Question) String() string {
+	return q.Name + "\t" + q.Class.String() + "\t" + q.Type.String()
+}
+
+// RR is a resource record. Data's type has to match Type, except that
+// any type can carry *Unknown data.
+type RR struct {
+	Name  string
+	Type  Type
+	Class Class // for OPT, the sender's UDP payload size
+	TTL   uint32
+	Data  RData // nil for none
+}
+
+// String formats the record as a zone file line, as dig prints it
+func (rr RR) String() string {
+	data := ""
+	if rr.Data != nil {
+		data = rr.Data.String()
+	}
+	return fmt.Sprintf("%s\t%d\t%s\t%s\t%s", rr.Name, rr.TTL, rr.Class, rr.Type, data)
+}
+
+// Message is a whole DNS message, query or reply
+type Message struct {
+	Header
+	Questions  []Question
+	Answers    []RR
+	Authority  []RR
+	Additional []RR
+}
+
+// Pack encodes the message, compressing repeated names (RFC 1035
+// section 4.1.4)
+func (m *Message) Pack() ([]byte, error) {
+	b, err := m.pack()
+	if err != nil {
+		return nil, fmt.Errorf("dnswire: %w", err)
+	}
+	return b, nil
+}
+
+func (m *Message) pack() ([]byte, error) {
+	if m.RCode > 0xf && m.opt() == nil {
+		return nil, fmt.Errorf("rcode %s needs an OPT record", m.RCode)
+	}
+
+	b := make([]byte, HeaderLen, 512)
+	binary.BigEndian.PutUint16(b[0:], m.ID)
+	binary.BigEndian.PutUint16(b[2:], m.flags())
+	for i, n := range []int{len(m.Questions), len(m.Answers), len(m.Authority), len(m.Additional)} {
+		if n > 0xffff {
+			return nil, errors.New("more than 65535 entries in a section")
+		}
+		binary.BigEndian.PutUint16(b[4+2*i:], uint16(n))
+	}
+
+	comp := make(map[string]int)
+	var err error
+	for _, q := range m.Questions {
+		if b, err = appendName(b, q.Name, comp); err != nil {
+			return nil, err
+		}
+		b = binary.BigEndian.AppendUint16(b, uint16(q.Type))
+		b = binary.BigEndian.AppendUint16(b, uint16(q.Class))
+	}
+	for _, section := range [][]RR{m.Answers, m.Authority, m.Additional} {
+		for _, rr := range section {
+			if rr.Type == TypeOPT {
+				// The extended RCODE's upper 8 bits go in the TTL's top byte
+				rr.TTL = rr.TTL&0x00ffffff | uint32(m.RCode>>4)<<24
+			}
+			if b, err = rr.pack(b, comp); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return b, nil
+}
+
+// pack appends the record to b
+func (rr *RR) pack(b []byte, comp map[string]int) ([]byte, error) {
+	b, err := appendName(b, rr.Name, comp)
+	if err != nil {
+		return nil, fmt.Errorf("%s %s: %w", rr.Name, rr.Type, err)
+	}
+	b = binary.BigEndian.AppendUint16(b, uint16(rr.Type))
+	b = binary.BigEndian.AppendUint16(b, uint16(rr.Class))
+	b = binary.BigEndian.AppendUint32(b, rr.TTL)
+
+	lenAt := len(b)
+	b = append(b, 0, 0) // RDLENGTH, filled in below
+	if rr.Data != nil {
+		if b, err = rr.Data.pack(b, comp); err != nil {
+			return nil, fmt.Errorf("%s %s: %w", rr.Name, rr.Type, err)
+		}
+	}
+	n := len(b) - lenAt - 2
+	if n > 0xffff {
+		return nil, fmt.Errorf("%s %s: data over 65535 bytes", rr.Name, rr.Type)
+	}
+	binary.BigEndian.PutUint16(b[lenAt:], uint16(n))
+	return b, nil
+}
+
+// Parse decodes a message. Names come back fully qualified, with their
+// trailing dot.
+func Parse(msg []byte) (*Message, error) {
+	m, err := parse(msg)
+	if err != nil {
+		return nil, fmt.Errorf("dnswire: %w", err)
+	}
+	return m, nil
+}
+
+func parse(msg []byte) (*Message, error) {
+	if len(msg) < HeaderLen {
+		return nil, ErrShort
+	}
+	m := &Message{}
+	m.ID = binary.BigEndian.Uint16(msg[0:])
+	m.setFlags(binary.BigEndian.Uint16(msg[2:]))
+	var counts [4]int
+	for i := range counts {
+		counts[i] = int(binary.BigEndian.Uint16(msg[4+2*i:]))
+	}
+
+	off := HeaderLen
+	for range counts[0] {
+		name, next, err := readName(msg, off)
+		if err != nil {
+			return nil, fmt.Errorf("%w (in the question section)", err)
+		}
+		if next+4 > len(msg) {
+			return nil, fmt.Errorf("%w (in the question section)", ErrShort)
+		}
+		m.Questions = append(m.Questions, Question{
+			Name:  name,
+			Type:  Type(binary.BigEndian.Uint16(msg[next:])),
+			Class: Class(binary.BigEndian.Uint16(msg[next+2:])),
+		})
+		off = next + 4
+	}
+
+	sections := []*[]RR{&m.Answers, &m.Authority, &m.Additional}
+	names := []string{"answer", "authority", "additional"}
+	for i, section := range sections {
+		for range counts[i+1] {
+			rr, next, err := readRR(msg, off)
+			if err != nil {
+				return nil, fmt.Errorf("%w (in the %s section)", err, names[i])
+			}
+			*section = append(*section, rr)
+			off = next
+		}
+	}
+
+	if opt := m.opt(); opt != nil {
+		m.RCode |= RCode(opt.TTL>>24) << 4
+	}
+	return m, nil
+}
+
+// readRR reads the record at off, returning it and the offset after it
+func readRR(msg []byte, off int) (RR, int, error) {
+	name, off, err := readName(msg, off)
+	if err != nil {
+		return RR{}, 0, err
+	}
+	if off+10 > len(msg) {
+		return RR{}, 0, ErrShort
+	}
+	rr := RR{
+		Name:  name,
+		Type:  Type(binary.BigEndian.Uint16(msg[off:])),
+		Class: Class(binary.BigEndian.Uint16(msg[off+2:])),
+		TTL:   binary.BigEndian.Uint32(msg[off+4:]),
+	}
+	length := int(binary.BigEndian.Uint16(msg[off+8:]))
+	off += 10
+	if off+length > len(msg) {
+		return RR{}, 0, ErrShort
+	}
+	if rr.Data, err = parseRData(msg, off, length, rr.Type); err != nil {
+		return RR{}, 0, fmt.Errorf("%s %s: %w", rr.Name, rr.Type, err)
+	}
+	return rr, off + length, nil
+}
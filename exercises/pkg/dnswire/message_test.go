@@ -0,0 +1,179 @@
+package dnswire
+
+import (
+	"bytes"
+	"errors"
+	"net/netip"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestPackQuery(t *testing.T) {
+	query := &Message{
+		Header:    Header{ID: 0xbeef, RecursionDesired: true},
+		Questions: []Question{{Name: "example.com", Type: TypeA, Class: ClassINET}},
+	}
+	got, err := query.Pack()
+	if err != nil {
+		t.Fatalf("Pack: %v", err)
+	}
+	want := []byte{
+		0xbe, 0xef, // ID
+		0x01, 0x00, // flags: RD
+		0, 1, 0, 0, 0, 0, 0, 0, // one question, no records
+		7, 'e', 'x', 'a', 'm', 'p', 'l', 'e', 3, 'c', 'o', 'm', 0,
+		0, 1, // A
+		0, 1, // IN
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("Pack =\n% x\nwant\n% x", got, want)
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	msg := &Message{
+		Header: Header{
+			ID: 1234, Response: true, Authoritative: true, RecursionDesired: true,
+			RecursionAvailable: true, AuthenticData: true, RCode: RCodeNameError,
+		},
+		Questions: []Question{{Name: "example.com.", Type: TypeANY, Class: ClassINET}},
+		Answers: []RR{
+			{Name: "example.com.", Type: TypeA, Class: ClassINET, TTL: 300, Data: &A{netip.MustParseAddr("192.0.2.1")}},
+			{Name: "example.com.", Type: TypeAAAA, Class: ClassINET, TTL: 300, Data: &AAAA{netip.MustParseAddr("2001:db8::1")}},
+			{Name: "www.example.com.", Type: TypeCNAME, Class: ClassINET, TTL: 60, Data: &CNAME{"example.com."}},
+			{Name: "example.com.", Type: TypeMX, Class: ClassINET, TTL: 3600, Data: &MX{10, "mail.example.com."}},
+			{Name: "example.com.", Type: TypeTXT, Class: ClassINET, TTL: 3600, Data: &TXT{[]string{"v=spf1 -all", `say "hi"`, ""}}},
+			{Name: "_sip._tcp.example.com.", Type: TypeSRV, Class: ClassINET, TTL: 60, Data: &SRV{10, 60, 5060, "sip.example.com."}},
+			{Name: "1.2.0.192.in-addr.arpa.", Type: TypePTR, Class: ClassINET, TTL: 60, Data: &PTR{"example.com."}},
+			{Name: "example.com.", Type: 257, Class: ClassINET, TTL: 60, Data: &Unknown{[]byte{0, 5, 'i', 's', 's', 'u', 'e'}}},
+		},
+		Authority: []RR{
+			{Name: "example.com.", Type: TypeNS, Class: ClassINET, TTL: 86400, Data: &NS{"ns1.example.com."}},
+			{Name: "example.com.", Type: TypeSOA, Class: ClassINET, TTL: 3600, Data: &SOA{"ns1.example.com.", "hostmaster.example.com.", 2024010101, 7200, 3600, 1209600, 300}},
+		},
+	}
+	msg.SetEDNS(EDNS{UDPSize: 1232, DO: true, Options: []Option{{Code: OptionNSID, Data: []byte("ns1")}}})
+
+	packed, err := msg.Pack()
+	if err != nil {
+		t.Fatalf("Pack: %v", err)
+	}
+	got, err := Parse(packed)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if !reflect.DeepEqual(got, msg) {
+		t.Errorf("round trip changed the message:\n got %+v\nwant %+v", got, msg)
+	}
+	e, ok := got.EDNS()
+	if !ok || e.UDPSize != 1232 || !e.DO || e.Version != 0 || string(e.Options[0].Data) != "ns1" {
+		t.Errorf("EDNS = %+v, %v", e, ok)
+	}
+}
+
+func TestCompression(t *testing.T) {
+	msg := &Message{
+		Questions: []Question{{Name: "Example.COM.", Type: TypeMX, Class: ClassINET}},
+		Answers: []RR{
+			{Name: "example.com.", Type: TypeMX, Class: ClassINET, Data: &MX{10, "mail.example.com."}},
+		},
+	}
+	packed, err := msg.Pack()
+	if err != nil {
+		t.Fatalf("Pack: %v", err)
+	}
+	// The answer's owner is all pointer (to the question's name, at 12,
+	// whatever its case), and its MX host is "mail" then a pointer
+	if !bytes.Contains(packed, []byte{0xc0, 12, 0, 15}) {
+		t.Errorf("answer's name isn't compressed: % x", packed)
+	}
+	if !bytes.HasSuffix(packed, []byte{4, 'm', 'a', 'i', 'l', 0xc0, 12}) {
+		t.Errorf("MX host isn't compressed: % x", packed)
+	}
+	got, err := Parse(packed)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if host := got.Answers[0].Data.(*MX).Host; host != "mail.Example.COM." {
+		t.Errorf("MX host = %q, want mail.Example.COM. (the pointer's target's case)", host)
+	}
+}
+
+func TestSRVTargetNotCompressed(t *testing.T) {
+	msg := &Message{
+		Questions: []Question{{Name: "example.com.", Type: TypeSRV, Class: ClassINET}},
+		Answers:   []RR{{Name: "example.com.", Type: TypeSRV, Class: ClassINET, Data: &SRV{Target: "example.com."}}},
+	}
+	packed, err := msg.Pack()
+	if err != nil {
+		t.Fatalf("Pack: %v", err)
+	}
+	if !bytes.HasSuffix(packed, []byte{7, 'e', 'x', 'a', 'm', 'p', 'l', 'e', 3, 'c', 'o', 'm', 0}) {
+		t.Errorf("SRV target was compressed: % x", packed)
+	}
+}
+
+func TestExtendedRCode(t *testing.T) {
+	msg := &Message{Header: Header{Response: true, RCode: RCodeBadVersion}}
+	if _, err := msg.Pack(); err == nil {
+		t.Error("BADVERS packed without an OPT record")
+	}
+	msg.SetEDNS(EDNS{UDPSize: 512})
+	packed, err := msg.Pack()
+	if err != nil {
+		t.Fatalf("Pack: %v", err)
+	}
+	got, err := Parse(packed)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if got.RCode != RCodeBadVersion {
+		t.Errorf("RCode = %s, want BADVERS", got.RCode)
+	}
+}
+
+func TestParseTruncated(t *testing.T) {
+	msg := &Message{
+		Questions: []Question{{Name: "example.com.", Type: TypeSOA, Class: ClassINET}},
+		Answers: []RR{
+			{Name: "example.com.", Type: TypeSOA, Class: ClassINET, Data: &SOA{"ns.example.com.", "admin.example.com.", 1, 2, 3, 4, 5}},
+			{Name: "example.com.", Type: TypeTXT, Class: ClassINET, Data: &TXT{[]string{"hello"}}},
+		},
+	}
+	packed, err := msg.Pack()
+	if err != nil {
+		t.Fatalf("Pack: %v", err)
+	}
+	for n := range len(packed) {
+		if _, err := Parse(packed[:n]); !errors.Is(err, ErrShort) {
+			t.Errorf("Parse of the first %d of %d bytes: err = %v, want ErrShort", n, len(packed), err)
+		}
+	}
+}
+
+func TestParseRejectsPointerLoops(t *testing.T) {
+	header := []byte{0, 0, 0, 0, 0, 1, 0, 0, 0, 0, 0, 0}
+	for name, question := range map[string][]byte{
+		"to itself": {0xc0, 12, 0, 1, 0, 1},
+		"forward":   {0xc0, 14, 0, 1, 0, 1, 0},
+		// "a" then a pointer back to the "a": each pass reads the label
+		// and lands on the same pointer
+		"around a label": {1, 'a', 0xc0, 12, 0, 1, 0, 1},
+	} {
+		_, err := Parse(append(header[:len(header):len(header)], question...))
+		if err == nil || !strings.Contains(err.Error(), "point back") {
+			t.Errorf("%s: err = %v, want a pointer error", name, err)
+		}
+	}
+}
+
+func TestParseBadRData(t *testing.T) {
+	msg := []byte{
+		0, 0, 0x80, 0, 0, 0, 0, 1, 0, 0, 0, 0, // one answer
+		0, 0, 1, 0, 1, 0, 0, 0, 0, 0, 3, 1, 2, 3, // ". A IN" with 3 bytes of data
+	}
+	if _, err := Parse(msg); err == nil || !strings.Contains(err.Error(), "want 4") {
+		t.Errorf("err = %v, want one about A data's length", err)
+	}
+}
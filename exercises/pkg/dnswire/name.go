@@ -0,0 +1,268 @@
+package dnswire
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net/netip"
+	"strconv"
+	"strings"
+)
+
+// Names are given and returned in presentation form, "www.example.com."
+// (the trailing dot is optional going in). On the wire each label is a
+// length byte and that many bytes, ending with the root's empty label:
+//
+//	3 w w w 7 e x a m p l e 3 c o m 0
+//
+// A label's own dots and backslashes, and bytes that aren't printable
+// ASCII, are escaped as \. \\ and \DDD (decimal).
+
+// Limits on names (RFC 1035 section 2.3.4)
+const (
+	MaxLabelLen = 63
+	MaxNameLen  = 255 // on the wire, length bytes and root included
+)
+
+// Fqdn returns name with a trailing dot, fully qualified
+func Fqdn(name string) string {
+	if strings.HasSuffix(name, ".") && !strings.HasSuffix(name, `\.`) {
+		return name
+	}
+	return name + "."
+}
+
+// CanonicalName returns name fully qualified and in lower case, to
+// compare or key names by: DNS ignores ASCII case (RFC 4343)
+func CanonicalName(name string) string {
+	b := []byte(Fqdn(name))
+	for i, c := range b {
+		if 'A' <= c && c <= 'Z' {
+			b[i] = c + 'a' - 'A'
+		}
+	}
+	return string(b)
+}
+
+// Parent returns name without its first label, "example.com." for
+// "www.example.com.", and false for the root, which has no parent
+func Parent(name string) (string, bool) {
+	name = Fqdn(name)
+	if name == "." {
+		return "", false
+	}
+	for i := 0; i < len(name); i++ {
+		switch name[i] {
+		case '\\':
+			i++ // an escaped byte, or the first digit of \DDD, isn't a dot
+		case '.':
+			if i+1 == len(name) {
+				return ".", true
+			}
+			return name[i+1:], true
+		}
+	}
+	return ".", true
+}
+
+// IsSubdomain reports whether child is parent or a name under it,
+// comparing whole labels and ignoring case
+func IsSubdomain(child, parent string) bool {
+	child, parent = CanonicalName(child), CanonicalName(parent)
+	for {
+		if child == parent {
+			return true
+		}
+		var ok bool
+		if child, ok = Parent(child); !ok {
+			return false
+		}
+	}
+}
+
+// ReverseName returns the name an address's PTR record is under:
+// 4.3.2.1.in-addr.arpa. for 1.2.3.4, or the address's 32 nibbles
+// backwards under ip6.arpa. for IPv6
+func ReverseName(ip netip.Addr) string {
+	var b strings.Builder
+	if ip.Is4() || ip.Is4In6() {
+		a := ip.Unmap().As4()
+		for i := 3; i >= 0; i-- {
+			b.WriteString(strconv.Itoa(int(a[i])))
+			b.WriteByte('.')
+		}
+		b.WriteString("in-addr.arpa.")
+		return b.String()
+	}
+	a := ip.As16()
+	const hex = "0123456789abcdef"
+	for i := 15; i >= 0; i-- {
+		b.WriteByte(hex[a[i]&0xf])
+		b.WriteByte('.')
+		b.WriteByte(hex[a[i]>>4])
+		b.WriteByte('.')
+	}
+	b.WriteString("ip6.arpa.")
+	return b.String()
+}
+
+// splitName returns name's labels, unescaped, checking their lengths
+func splitName(name string) ([]string, error) {
+	if name == "." || name == "" {
+		return nil, nil
+	}
+	var labels []string
+	var label []byte
+	wireLen := 1 // the root label
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		switch {
+		case c == '\\':
+			if i+3 < len(name) && isDigit(name[i+1]) && isDigit(name[i+2]) && isDigit(name[i+3]) {
+				v, _ := strconv.Atoi(name[i+1 : i+4])
+				if v > 255 {
+					return nil, fmt.Errorf("name %q: \\%s isn't a byte", name, name[i+1:i+4])
+				}
+				label = append(label, byte(v))
+				i += 3
+			} else if i+1 < len(name) {
+				label = append(label, name[i+1])
+				i++
+			} else {
+				return nil, fmt.Errorf("name %q ends in a lone backslash", name)
+			}
+		case c == '.':
+			if len(label) == 0 {
+				return nil, fmt.Errorf("name %q has an empty label", name)
+			}
+			labels = append(labels, string(label))
+			wireLen += 1 + len(label)
+			label = label[:0]
+		default:
+			label = append(label, c)
+		}
+		if len(label) > MaxLabelLen {
+			return nil, fmt.Errorf("name %q has a label over %d bytes", name, MaxLabelLen)
+		}
+	}
+	if len(label) > 0 {
+		labels = append(labels, string(label))
+		wireLen += 1 + len(label)
+	}
+	if wireLen > MaxNameLen {
+		return nil, fmt.Errorf("name %q is over %d bytes", name, MaxNameLen)
+	}
+	return labels, nil
+}
+
+func isDigit(c byte) bool { return '0' <= c && c <= '9' }
+
+// appendName appends name to the message b. With comp non-nil, a suffix
+// already in the message is replaced by a pointer to it (two bytes, the
+// top two bits set, then its 14-bit offset), and the suffixes written
+// are noted for the names after. Matching ignores ASCII case, as names
+// do.
+func appendName(b []byte, name string, comp map[string]int) ([]byte, error) {
+	labels, err := splitName(name)
+	if err != nil {
+		return nil, err
+	}
+	for i, label := range labels {
+		if comp != nil {
+			key := suffixKey(labels[i:])
+			if off, ok := comp[key]; ok {
+				return binary.BigEndian.AppendUint16(b, 0xc000|uint16(off)), nil
+			}
+			if len(b) <= 0x3fff {
+				comp[key] = len(b)
+			}
+		}
+		b = append(b, byte(len(label)))
+		b = append(b, label...)
+	}
+	return append(b, 0), nil
+}
+
+// suffixKey is labels as a compression map key: their wire form,
+// lowercased
+func suffixKey(labels []string) string {
+	var b []byte
+	for _, label := range labels {
+		b = append(b, byte(len(label)))
+		for i := 0; i < len(label); i++ {
+			c := label[i]
+			if 'A' <= c && c <= 'Z' {
+				c += 'a' - 'A'
+			}
+			b = append(b, c)
+		}
+	}
+	return string(b)
+}
+
+// readName reads the name at off in msg, following compression
+// pointers, and returns it with the offset after it (after the first
+// pointer, if any). Each pointer has to point before everything read
+// of the name so far, so a crafted one can't loop.
+func readName(msg []byte, off int) (string, int, error) {
+	var b strings.Builder
+	next := -1
+	wireLen := 1
+	lowest := off // where reading the name has been, at the lowest
+	for {
+		if off >= len(msg) {
+			return "", 0, ErrShort
+		}
+		c := int(msg[off])
+		switch c & 0xc0 {
+		case 0x00:
+			if c == 0 {
+				if next < 0 {
+					next = off + 1
+				}
+				if b.Len() == 0 {
+					return ".", next, nil
+				}
+				return b.String(), next, nil
+			}
+			if off+1+c > len(msg) {
+				return "", 0, ErrShort
+			}
+			if wireLen += 1 + c; wireLen > MaxNameLen {
+				return "", 0, fmt.Errorf("name over %d bytes", MaxNameLen)
+			}
+			writeLabel(&b, msg[off+1:off+1+c])
+			b.WriteByte('.')
+			off += 1 + c
+		case 0xc0:
+			if off+2 > len(msg) {
+				return "", 0, ErrShort
+			}
+			if next < 0 {
+				next = off + 2
+			}
+			ptr := int(binary.BigEndian.Uint16(msg[off:]) & 0x3fff)
+			if ptr >= lowest {
+				return "", 0, errors.New("compression pointer doesn't point back")
+			}
+			off, lowest = ptr, ptr
+		default:
+			return "", 0, fmt.Errorf("reserved label type %#x", c&0xc0)
+		}
+	}
+}
+
+// writeLabel writes a label in presentation form, escaped
+func writeLabel(b *strings.Builder, label []byte) {
+	for _, c := range label {
+		switch {
+		case c == '.' || c == '\\':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		case c <= ' ' || c >= 0x7f:
+			fmt.Fprintf(b, "\\%03d", c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+}
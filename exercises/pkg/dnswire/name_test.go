@@ -0,0 +1,136 @@
+package dnswire
+
+import (
+	"net/netip"
+	"strings"
+	"testing"
+)
+
+func TestNameEscapes(t *testing.T) {
+	for _, name := range []string{
+		".",
+		"example.com.",
+		`dot\.in.label.`,
+		`back\\slash.`,
+		`\000\255nonprint.`,
+		`sp\032ace.`,
+	} {
+		b, err := appendName(nil, name, nil)
+		if err != nil {
+			t.Errorf("appendName(%q): %v", name, err)
+			continue
+		}
+		got, next, err := readName(b, 0)
+		if err != nil || next != len(b) {
+			t.Errorf("readName of %q = %q, %d, %v", name, got, next, err)
+			continue
+		}
+		if got != name {
+			t.Errorf("round trip of %q = %q", name, got)
+		}
+	}
+
+	b, _ := appendName(nil, `a\.b.c`, nil)
+	if want := "\x03a.b\x01c\x00"; string(b) != want {
+		t.Errorf(`a\.b.c packs as %q, want %q`, b, want)
+	}
+}
+
+func TestNameLimits(t *testing.T) {
+	label63 := strings.Repeat("a", 63)
+	long := strings.Repeat(label63+".", 4) // 4*64+1 = 257 bytes on the wire
+	for _, tc := range []struct {
+		name string
+		ok   bool
+	}{
+		{label63 + ".com", true},
+		{label63 + "a.com", false},
+		{strings.Repeat(label63+".", 3) + strings.Repeat("a", 61), true}, // 255 exactly
+		{long, false},
+		{"a..b", false},
+		{`a\`, false},
+		{`a\256`, false},
+	} {
+		_, err := splitName(tc.name)
+		if (err == nil) != tc.ok {
+			t.Errorf("splitName(%.20q...): err = %v, want ok %v", tc.name, err, tc.ok)
+		}
+	}
+}
+
+func TestFqdn(t *testing.T) {
+	for in, want := range map[string]string{
+		"example.com":  "example.com.",
+		"example.com.": "example.com.",
+		"":             ".",
+		`a\.`:          `a\..`,
+	} {
+		if got := Fqdn(in); got != want {
+			t.Errorf("Fqdn(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestParent(t *testing.T) {
+	for _, name := range []string{"www.example.com.", `a\.b.example.com`, `a\\.example.com.`} {
+		if got, _ := Parent(name); got != "example.com." && got != `b.example.com.` {
+			t.Errorf("Parent(%q) = %q", name, got)
+		}
+	}
+	if got, ok := Parent("com."); got != "." || !ok {
+		t.Errorf("Parent(com.) = %q, %v", got, ok)
+	}
+	if _, ok := Parent("."); ok {
+		t.Error("the root has a parent")
+	}
+}
+
+func TestIsSubdomain(t *testing.T) {
+	for _, tc := range []struct {
+		child, parent string
+		want          bool
+	}{
+		{"www.Example.com.", "example.COM", true},
+		{"example.com.", "example.com.", true},
+		{"anything.", ".", true},
+		{"badexample.com.", "example.com.", false},
+		{`a\.example.com.`, "example.com.", false}, // one label, "a.example", under com.
+		{"com.", "example.com.", false},
+	} {
+		if got := IsSubdomain(tc.child, tc.parent); got != tc.want {
+			t.Errorf("IsSubdomain(%q, %q) = %v, want %v", tc.child, tc.parent, got, tc.want)
+		}
+	}
+	if got := CanonicalName("WWW.Example.Com"); got != "www.example.com." {
+		t.Errorf("CanonicalName = %q", got)
+	}
+}
+
+func TestReverseName(t *testing.T) {
+	for ip, want := range map[string]string{
+		"192.0.2.1":          "1.2.0.192.in-addr.arpa.",
+		"::ffff:192.0.2.1":   "1.2.0.192.in-addr.arpa.",
+		"2001:db8::567:89ab": "b.a.9.8.7.6.5.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.8.b.d.0.1.0.0.2.ip6.arpa.",
+	} {
+		if got := ReverseName(netip.MustParseAddr(ip)); got != want {
+			t.Errorf("ReverseName(%s) = %s, want %s", ip, got, want)
+		}
+	}
+}
+
+func TestParseType(t *testing.T) {
+	for in, want := range map[string]Type{"a": TypeA, "AAAA": TypeAAAA, "mx": TypeMX, "TYPE65": 65, "https": 65} {
+		if got, err := ParseType(in); err != nil || got != want {
+			t.Errorf("ParseType(%q) = %v, %v, want %v", in, got, err, want)
+		}
+	}
+	if _, err := ParseType("BOGUS"); err == nil {
+		t.Error("ParseType(BOGUS) succeeded")
+	}
+	if got := Type(65).String(); got != "HTTPS" {
+		t.Errorf("Type(65) = %s", got)
+	}
+	if got := Type(65280).String(); got != "TYPE65280" {
+		t.Errorf("Type(65280) = %s", got)
+	}
+}
@@ -0,0 +1,341 @@
+package dnswire
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/netip"
+	"strconv"
+	"strings"
+)
+
+// RData is a record's data. The types here are the ones this package
+// knows the layout of; any other type's data is *Unknown.
+type RData interface {
+	// String returns the data as written in a zone file
+	String() string
+	pack(b []byte, comp map[string]int) ([]byte, error)
+}
+
+// A is an IPv4 address
+type A struct{ Addr netip.Addr }
+
+// AAAA is an IPv6 address
+type AAAA struct{ Addr netip.Addr }
+
+// NS names an authoritative server for the zone
+type NS struct{ Host string }
+
+// CNAME says the name is an alias for Target
+type CNAME struct{ Target string }
+
+// PTR maps a reverse name back to a host
+type PTR struct{ Host string }
+
+// MX names a mail server; lower preferences are tried first
+type MX struct {
+	Preference uint16
+	Host       string
+}
+
+// TXT is text, as one or more strings of up to 255 bytes each
+type TXT struct{ Text []string }
+
+// SOA describes a zone: its primary server, its admin's mailbox (the
+// first label being the local part), and timers for its secondaries.
+// MinTTL is how long a name's absence may be cached (RFC 2308).
+type SOA struct {
+	MName, RName                           string
+	Serial, Refresh, Retry, Expire, MinTTL uint32
+}
+
+// SRV locates a service: Target:Port, with lower priorities tried first
+// and weights sharing load within one (RFC 2782)
+type SRV struct {
+	Priority, Weight, Port uint16
+	Target                 string
+}
+
+// OPT is the EDNS(0) pseudo-record's data: its options. The rest of it
+// lives in the record's class and TTL; see EDNS.
+type OPT struct{ Options []Option }
+
+// Option is one EDNS option, e.g. NSID or a client subnet
+type Option struct {
+	Code uint16
+	Data []byte
+}
+
+// Unknown is data of a type without a layout here, kept as it came
+type Unknown struct{ Data []byte }
+
+func (d *A) String() string     { return d.Addr.String() }
+func (d *AAAA) String() string  { return d.Addr.String() }
+func (d *NS) String() string    { return d.Host }
+func (d *CNAME) String() string { return d.Target }
+func (d *PTR) String() string   { return d.Host }
+func (d *MX) String() string    { return strconv.Itoa(int(d.Preference)) + " " + d.Host }
+
+func (d *TXT) String() string {
+	quoted := make([]string, len(d.Text))
+	for i, s := range d.Text {
+		quoted[i] = quoteString(s)
+	}
+	return strings.Join(quoted, " ")
+}
+
+func (d *SOA) String() string {
+	return fmt.Sprintf("%s %s %d %d %d %d %d", d.MName, d.RName, d.Serial, d.Refresh, d.Retry, d.Expire, d.MinTTL)
+}
+
+func (d *SRV) String() string {
+	return fmt.Sprintf("%d %d %d %s", d.Priority, d.Weight, d.Port, d.Target)
+}
+
+func (d *OPT) String() string {
+	options := make([]string, len(d.Options))
+	for i, o := range d.Options {
+		options[i] = o.String()
+	}
+	return strings.Join(options, " ")
+}
+
+// String names the option if it's a common one, with its data in hex
+func (o Option) String() string {
+	name, ok := optionNames[o.Code]
+	if !ok {
+		name = "OPT" + strconv.Itoa(int(o.Code))
+	}
+	return name + ": " + hex.EncodeToString(o.Data)
+}
+
+// String is RFC 3597's form for data of unknown types: \# length hex
+func (d *Unknown) String() string {
+	return `\# ` + strconv.Itoa(len(d.Data)) + " " + hex.EncodeToString(d.Data)
+}
+
+func (d *A) pack(b []byte, _ map[string]int) ([]byte, error) {
+	if !d.Addr.Is4() {
+		return nil, fmt.Errorf("%v isn't an IPv4 address", d.Addr)
+	}
+	a := d.Addr.As4()
+	return append(b, a[:]...), nil
+}
+
+func (d *AAAA) pack(b []byte, _ map[string]int) ([]byte, error) {
+	if !d.Addr.Is6() {
+		return nil, fmt.Errorf("%v isn't an IPv6 address", d.Addr)
+	}
+	a := d.Addr.As16()
+	return append(b, a[:]...), nil
+}
+
+// The names in NS, CNAME, PTR, MX and SOA data may be compressed. Types
+// defined since RFC 1035 don't compress theirs (RFC 3597), SRV's
+// included (RFC 2782), as servers that don't know them couldn't follow.
+
+func (d *NS) pack(b []byte, comp map[string]int) ([]byte, error) {
+	return appendName(b, d.Host, comp)
+}
+
+func (d *CNAME) pack(b []byte, comp map[string]int) ([]byte, error) {
+	return appendName(b, d.Target, comp)
+}
+
+func (d *PTR) pack(b []byte, comp map[string]int) ([]byte, error) {
+	return appendName(b, d.Host, comp)
+}
+
+func (d *MX) pack(b []byte, comp map[string]int) ([]byte, error) {
+	b = binary.BigEndian.AppendUint16(b, d.Preference)
+	return appendName(b, d.Host, comp)
+}
+
+func (d *TXT) pack(b []byte, _ map[string]int) ([]byte, error) {
+	if len(d.Text) == 0 {
+		return append(b, 0), nil // one empty string: TXT data can't be empty
+	}
+	for _, s := range d.Text {
+		if len(s) > 255 {
+			return nil, errors.New("TXT string over 255 bytes; split it")
+		}
+		b = append(b, byte(len(s)))
+		b = append(b, s...)
+	}
+	return b, nil
+}
+
+func (d *SOA) pack(b []byte, comp map[string]int) ([]byte, error) {
+	b, err := appendName(b, d.MName, comp)
+	if err != nil {
+		return nil, err
+	}
+	if b, err = appendName(b, d.RName, comp); err != nil {
+		return nil, err
+	}
+	for _, v := range []uint32{d.Serial, d.Refresh, d.Retry, d.Expire, d.MinTTL} {
+		b = binary.BigEndian.AppendUint32(b, v)
+	}
+	return b, nil
+}
+
+func (d *SRV) pack(b []byte, _ map[string]int) ([]byte, error) {
+	b = binary.BigEndian.AppendUint16(b, d.Priority)
+	b = binary.BigEndian.AppendUint16(b, d.Weight)
+	b = binary.BigEndian.AppendUint16(b, d.Port)
+	return appendName(b, d.Target, nil)
+}
+
+func (d *OPT) pack(b []byte, _ map[string]int) ([]byte, error) {
+	for _, o := range d.Options {
+		if len(o.Data) > 0xffff {
+			return nil, errors.New("EDNS option over 65535 bytes")
+		}
+		b = binary.BigEndian.AppendUint16(b, o.Code)
+		b = binary.BigEndian.AppendUint16(b, uint16(len(o.Data)))
+		b = append(b, o.Data...)
+	}
+	return b, nil
+}
+
+func (d *Unknown) pack(b []byte, _ map[string]int) ([]byte, error) {
+	return append(b, d.Data...), nil
+}
+
+// parseRData decodes the length bytes of t's data at off. Names in it
+// may point anywhere earlier in msg, but must end within it.
+func parseRData(msg []byte, off, length int, t Type) (RData, error) {
+	data := msg[off : off+length]
+	end := off + length
+
+	// name reads a name at off that must end within the data
+	name := func(at int) (string, int, error) {
+		n, next, err := readName(msg[:end], at)
+		if errors.Is(err, ErrShort) {
+			err = errors.New("name runs past the record's data")
+		}
+		return n, next, err
+	}
+	// only reads data made of just a name
+	only := func() (string, error) {
+		n, next, err := name(off)
+		if err == nil && next != end {
+			err = fmt.Errorf("%d bytes after the name", end-next)
+		}
+		return n, err
+	}
+
+	switch t {
+	case TypeA:
+		if length != 4 {
+			return nil, fmt.Errorf("%d bytes of A data (want 4)", length)
+		}
+		return &A{netip.AddrFrom4([4]byte(data))}, nil
+	case TypeAAAA:
+		if length != 16 {
+			return nil, fmt.Errorf("%d bytes of AAAA data (want 16)", length)
+		}
+		return &AAAA{netip.AddrFrom16([16]byte(data))}, nil
+	case TypeNS:
+		host, err := only()
+		return &NS{host}, err
+	case TypeCNAME:
+		target, err := only()
+		return &CNAME{target}, err
+	case TypePTR:
+		host, err := only()
+		return &PTR{host}, err
+	case TypeMX:
+		if length < 3 {
+			return nil, fmt.Errorf("%d bytes is too short for %s data", length, t)
+		}
+		host, next, err := name(off + 2)
+		if err == nil && next != end {
+			err = fmt.Errorf("%d bytes after the name", end-next)
+		}
+		return &MX{binary.BigEndian.Uint16(data), host}, err
+	case TypeTXT:
+		txt := &TXT{}
+		for i := 0; i < len(data); {
+			n := int(data[i])
+			if i+1+n > len(data) {
+				return nil, errors.New("TXT string runs past the record's data")
+			}
+			txt.Text = append(txt.Text, string(data[i+1:i+1+n]))
+			i += 1 + n
+		}
+		return txt, nil
+	case TypeSOA:
+		mname, next, err := name(off)
+		if err != nil {
+			return nil, err
+		}
+		rname, next, err := name(next)
+		if err != nil {
+			return nil, err
+		}
+		if end-next != 20 {
+			return nil, fmt.Errorf("%d bytes of SOA timers (want 20)", end-next)
+		}
+		timers := msg[next:end]
+		return &SOA{
+			MName: mname, RName: rname,
+			Serial:  binary.BigEndian.Uint32(timers[0:]),
+			Refresh: binary.BigEndian.Uint32(timers[4:]),
+			Retry:   binary.BigEndian.Uint32(timers[8:]),
+			Expire:  binary.BigEndian.Uint32(timers[12:]),
+			MinTTL:  binary.BigEndian.Uint32(timers[16:]),
+		}, nil
+	case TypeSRV:
+		if length < 7 {
+			return nil, fmt.Errorf("%d bytes is too short for %s data", length, t)
+		}
+		target, next, err := name(off + 6)
+		if err == nil && next != end {
+			err = fmt.Errorf("%d bytes after the name", end-next)
+		}
+		return &SRV{
+			Priority: binary.BigEndian.Uint16(data[0:]),
+			Weight:   binary.BigEndian.Uint16(data[2:]),
+			Port:     binary.BigEndian.Uint16(data[4:]),
+			Target:   target,
+		}, err
+	case TypeOPT:
+		opt := &OPT{}
+		for i := 0; i < len(data); {
+			if i+4 > len(data) {
+				return nil, errors.New("EDNS option header runs past the record's data")
+			}
+			code := binary.BigEndian.Uint16(data[i:])
+			n := int(binary.BigEndian.Uint16(data[i+2:]))
+			if i+4+n > len(data) {
+				return nil, errors.New("EDNS option runs past the record's data")
+			}
+			opt.Options = append(opt.Options, Option{Code: code, Data: append([]byte(nil), data[i+4:i+4+n]...)})
+			i += 4 + n
+		}
+		return opt, nil
+	}
+	return &Unknown{append([]byte(nil), data...)}, nil
+}
+
+// quoteString quotes a TXT string as a zone file would, escaping quotes,
+// backslashes and bytes that aren't printable ASCII
+func quoteString(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '"' || c == '\\':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		case c < ' ' || c >= 0x7f:
+			fmt.Fprintf(&b, "\\%03d", c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
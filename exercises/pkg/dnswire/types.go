@@ -0,0 +1,140 @@
+package dnswire
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Type is a record type, or in a question, the type asked for
+type Type uint16
+
+// Record types this package decodes into typed data, plus OPT and ANY
+const (
+	TypeA     Type = 1
+	TypeNS    Type = 2
+	TypeCNAME Type = 5
+	TypeSOA   Type = 6
+	TypePTR   Type = 12
+	TypeMX    Type = 15
+	TypeTXT   Type = 16
+	TypeAAAA  Type = 28
+	TypeSRV   Type = 33
+	TypeOPT   Type = 41
+	TypeANY   Type = 255
+)
+
+var typeNames = map[Type]string{
+	TypeA: "A", TypeNS: "NS", TypeCNAME: "CNAME", TypeSOA: "SOA", TypePTR: "PTR",
+	TypeMX: "MX", TypeTXT: "TXT", TypeAAAA: "AAAA", TypeSRV: "SRV", TypeOPT: "OPT",
+	TypeANY: "ANY",
+	// Common types with no typed data here, named for display
+	43: "DS", 46: "RRSIG", 47: "NSEC", 48: "DNSKEY", 50: "NSEC3", 52: "TLSA",
+	64: "SVCB", 65: "HTTPS", 99: "SPF", 252: "AXFR", 257: "CAA",
+}
+
+// String returns the type's mnemonic, or TYPEn for one without (RFC 3597)
+func (t Type) String() string {
+	if name, ok := typeNames[t]; ok {
+		return name
+	}
+	return "TYPE" + strconv.Itoa(int(t))
+}
+
+// ParseType reads a type's mnemonic, in any case, or its TYPEn form
+func ParseType(s string) (Type, error) {
+	s = strings.ToUpper(s)
+	for t, name := range typeNames {
+		if name == s {
+			return t, nil
+		}
+	}
+	if n, ok := strings.CutPrefix(s, "TYPE"); ok {
+		if v, err := strconv.ParseUint(n, 10, 16); err == nil {
+			return Type(v), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown record type %q", s)
+}
+
+// Class is a record's class; in practice always IN
+type Class uint16
+
+const (
+	ClassINET   Class = 1
+	ClassCHAOS  Class = 3 // for asking servers about themselves: version.bind. CH TXT
+	ClassHESIOD Class = 4
+	ClassANY    Class = 255
+)
+
+var classNames = map[Class]string{ClassINET: "IN", ClassCHAOS: "CH", ClassHESIOD: "HS", ClassANY: "ANY"}
+
+func (c Class) String() string {
+	if name, ok := classNames[c]; ok {
+		return name
+	}
+	return "CLASS" + strconv.Itoa(int(c))
+}
+
+// ParseClass reads a class's mnemonic, in any case, or its CLASSn form
+func ParseClass(s string) (Class, error) {
+	s = strings.ToUpper(s)
+	for c, name := range classNames {
+		if name == s {
+			return c, nil
+		}
+	}
+	if n, ok := strings.CutPrefix(s, "CLASS"); ok {
+		if v, err := strconv.ParseUint(n, 10, 16); err == nil {
+			return Class(v), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown class %q", s)
+}
+
+// Opcode is the kind of query
+type Opcode uint8
+
+const (
+	OpcodeQuery  Opcode = 0
+	OpcodeStatus Opcode = 2
+	OpcodeNotify Opcode = 4
+	OpcodeUpdate Opcode = 5
+)
+
+var opcodeNames = map[Opcode]string{OpcodeQuery: "QUERY", OpcodeStatus: "STATUS", OpcodeNotify: "NOTIFY", OpcodeUpdate: "UPDATE"}
+
+func (o Opcode) String() string {
+	if name, ok := opcodeNames[o]; ok {
+		return name
+	}
+	return "OPCODE" + strconv.Itoa(int(o))
+}
+
+// RCode is a reply's response code: 4 bits in the header, extended to 12
+// by the OPT record
+type RCode uint16
+
+const (
+	RCodeSuccess        RCode = 0 // NOERROR
+	RCodeFormatError    RCode = 1
+	RCodeServerFailure  RCode = 2
+	RCodeNameError      RCode = 3 // NXDOMAIN: the name doesn't exist
+	RCodeNotImplemented RCode = 4
+	RCodeRefused        RCode = 5
+	RCodeBadVersion     RCode = 16 // BADVERS: an EDNS version the server doesn't speak
+)
+
+var rcodeNames = map[RCode]string{
+	RCodeSuccess: "NOERROR", RCodeFormatError: "FORMERR", RCodeServerFailure: "SERVFAIL",
+	RCodeNameError: "NXDOMAIN", RCodeNotImplemented: "NOTIMP", RCodeRefused: "REFUSED",
+	6: "YXDOMAIN", 7: "YXRRSET", 8: "NXRRSET", 9: "NOTAUTH", 10: "NOTZONE",
+	RCodeBadVersion: "BADVERS",
+}
+
+func (r RCode) String() string {
+	if name, ok := rcodeNames[r]; ok {
+		return name
+	}
+	return "RCODE" + strconv.Itoa(int(r))
+}
@@ -0,0 +1,53 @@
+// Package healthcheck holds the checks exercise 05 runs that others
+// reuse: connecting to a TCP port, and judging an HTTP response by its
+// status against a set of accepted codes written like expected_status.
+//
+//	ok, _ := healthcheck.ParseStatus("2xx, 301")
+//	if err := healthcheck.HTTP(ctx, client, "http://10.0.0.5/healthz", ok); err != nil {
+//		log.Printf("down: %v", err)
+//	}
+package healthcheck
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+)
+
+// DialFunc opens a connection, as net.Dialer.DialContext does
+type DialFunc func(ctx context.Context, network, addr string) (net.Conn, error)
+
+// TCP connects to addr with dial and hangs up, proving something accepts
+// connections there (a database, an SMTP server) without speaking its
+// protocol
+func TCP(ctx context.Context, dial DialFunc, addr string) error {
+	conn, err := dial(ctx, "tcp", addr)
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
+
+// HTTP GETs url with client and wants a status in expect. The body isn't
+// read; a client that follows redirects judges where they end up.
+func HTTP(ctx context.Context, client *http.Client, url string, expect StatusSet) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return expect.Check(resp.StatusCode)
+}
+
+// Check returns an error naming code and the set if code isn't in it
+func (s StatusSet) Check(code int) error {
+	if !s.Contains(code) {
+		return fmt.Errorf("status %d (expected %s)", code, s)
+	}
+	return nil
+}
@@ -0,0 +1,54 @@
+package healthcheck
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestTCP(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	var d net.Dialer
+	if err := TCP(context.Background(), d.DialContext, addr); err != nil {
+		t.Errorf("listening port: %v", err)
+	}
+	ln.Close()
+	if err := TCP(context.Background(), d.DialContext, addr); err == nil {
+		t.Error("closed port checked fine")
+	}
+}
+
+func TestHTTP(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/moved":
+			http.Redirect(w, r, "/", http.StatusFound)
+		case "/broken":
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer srv.Close()
+	noRedirects := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	ok, _ := ParseStatus("2xx,3xx")
+
+	tests := []struct {
+		path, wantErr string
+	}{
+		{"/", ""},
+		{"/moved", ""},
+		{"/broken", "status 500 (expected 2xx, 3xx)"},
+	}
+	for _, tt := range tests {
+		err := HTTP(context.Background(), noRedirects, srv.URL+tt.path, ok)
+		if tt.wantErr == "" && err != nil || tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+			t.Errorf("%s: %v, want %q", tt.path, err, tt.wantErr)
+		}
+	}
+}
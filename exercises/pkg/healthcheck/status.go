@@ -0,0 +1,103 @@
+package healthcheck
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// StatusRange is an inclusive range of HTTP status codes
+type StatusRange struct{ Lo, Hi int }
+
+// StatusSet is the status codes a check accepts. In JSON it is a
+// code (200), a string of comma-separated codes and ranges ("200-299",
+// "2xx, 301"), or a list of either ([200, 301], ["2xx", 404]).
+type StatusSet []StatusRange
+
+// Contains reports whether code is in the set
+func (s StatusSet) Contains(code int) bool {
+	for _, r := range s {
+		if code >= r.Lo && code <= r.Hi {
+			return true
+		}
+	}
+	return false
+}
+
+// String writes the set back the way ParseStatus reads it
+func (s StatusSet) String() string {
+	parts := make([]string, len(s))
+	for i, r := range s {
+		switch {
+		case r.Lo == r.Hi:
+			parts[i] = strconv.Itoa(r.Lo)
+		case r.Lo%100 == 0 && r.Hi == r.Lo+99:
+			parts[i] = fmt.Sprintf("%dxx", r.Lo/100)
+		default:
+			parts[i] = fmt.Sprintf("%d-%d", r.Lo, r.Hi)
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// UnmarshalJSON reads a code, a ParseStatus string or a list of either
+func (s *StatusSet) UnmarshalJSON(data []byte) error {
+	var list []json.RawMessage
+	if err := json.Unmarshal(data, &list); err != nil {
+		list = []json.RawMessage{data}
+	}
+
+	var set StatusSet
+	for _, item := range list {
+		var code int
+		if err := json.Unmarshal(item, &code); err == nil {
+			ranges, err := ParseStatus(strconv.Itoa(code))
+			if err != nil {
+				return err
+			}
+			set = append(set, ranges...)
+			continue
+		}
+		var expr string
+		if err := json.Unmarshal(item, &expr); err != nil {
+			return fmt.Errorf("want a code, a range string or a list, got %s", item)
+		}
+		ranges, err := ParseStatus(expr)
+		if err != nil {
+			return err
+		}
+		set = append(set, ranges...)
+	}
+	*s = set
+	return nil
+}
+
+// ParseStatus parses comma-separated codes ("204"), ranges ("200-299")
+// and classes ("2xx")
+func ParseStatus(expr string) (StatusSet, error) {
+	var set StatusSet
+	for _, part := range strings.Split(expr, ",") {
+		part = strings.TrimSpace(part)
+		var r StatusRange
+		var err error
+		if class, ok := strings.CutSuffix(strings.ToLower(part), "xx"); ok && len(class) == 1 {
+			r.Lo, err = strconv.Atoi(class)
+			r.Lo *= 100
+			r.Hi = r.Lo + 99
+		} else if lo, hi, ok := strings.Cut(part, "-"); ok {
+			r.Lo, err = strconv.Atoi(strings.TrimSpace(lo))
+			if err == nil {
+				r.Hi, err = strconv.Atoi(strings.TrimSpace(hi))
+			}
+		} else {
+			r.Lo, err = strconv.Atoi(part)
+			r.Hi = r.Lo
+		}
+		if err != nil || r.Lo < 100 || r.Hi > 599 || r.Lo > r.Hi {
+			return nil, fmt.Errorf("bad status %q", part)
+		}
+		set = append(set, r)
+	}
+	return set, nil
+}
@@ -0,0 +1,70 @@
+package healthcheck
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestParseStatus(t *testing.T) {
+	tests := []struct {
+		expr, want string
+		in, out    []int
+	}{
+		{"200", "200", []int{200}, []int{201, 404}},
+		{"2xx", "2xx", []int{200, 299}, []int{199, 300}},
+		{"2XX, 301", "2xx, 301", []int{204, 301}, []int{302}},
+		{"200-204", "200-204", []int{200, 204}, []int{205}},
+		{"2xx,3xx", "2xx, 3xx", []int{200, 399}, []int{400}},
+	}
+	for _, tt := range tests {
+		set, err := ParseStatus(tt.expr)
+		if err != nil {
+			t.Errorf("ParseStatus(%q): %v", tt.expr, err)
+			continue
+		}
+		if got := set.String(); got != tt.want {
+			t.Errorf("ParseStatus(%q) = %s, want %s", tt.expr, got, tt.want)
+		}
+		for _, code := range tt.in {
+			if err := set.Check(code); err != nil {
+				t.Errorf("%s: %v", tt.expr, err)
+			}
+		}
+		for _, code := range tt.out {
+			if set.Contains(code) {
+				t.Errorf("%s contains %d", tt.expr, code)
+			}
+		}
+	}
+}
+
+func TestParseStatusRejects(t *testing.T) {
+	for _, expr := range []string{"", "abc", "99", "600", "204-200", "2x", "1xxx"} {
+		if set, err := ParseStatus(expr); err == nil {
+			t.Errorf("ParseStatus(%q) = %s, want an error", expr, set)
+		}
+	}
+}
+
+func TestStatusSetJSON(t *testing.T) {
+	tests := []struct{ json, want string }{
+		{`200`, "200"},
+		{`"2xx, 301"`, "2xx, 301"},
+		{`[200, "3xx"]`, "200, 3xx"},
+	}
+	for _, tt := range tests {
+		var set StatusSet
+		if err := json.Unmarshal([]byte(tt.json), &set); err != nil {
+			t.Errorf("%s: %v", tt.json, err)
+			continue
+		}
+		if got := set.String(); got != tt.want {
+			t.Errorf("%s = %s, want %s", tt.json, got, tt.want)
+		}
+	}
+
+	var set StatusSet
+	if err := json.Unmarshal([]byte(`true`), &set); err == nil {
+		t.Errorf("true = %s, want an error", set)
+	}
+}
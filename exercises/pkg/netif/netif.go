@@ -0,0 +1,59 @@
+// Package netif looks up the addresses of network interfaces, so that
+// exercises can bind their sockets to one interface on a multi-homed
+// machine:
+//
+//	addr, err := netif.Addr("eth0", false)
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	dialer := &net.Dialer{LocalAddr: &net.TCPAddr{IP: addr.IP, Zone: addr.Zone}}
+package netif
+
+import (
+	"fmt"
+	"net"
+)
+
+// Addr returns the first IPv4 (or, if ipv6 is set, IPv6) address of the
+// named interface. Global IPv6 addresses win over link-local ones, which
+// only work together with a zone, so a link-local result has its Zone
+// set to the interface name.
+func Addr(name string, ipv6 bool) (*net.IPAddr, error) {
+	iface, err := net.InterfaceByName(name)
+	if err != nil {
+		return nil, fmt.Errorf("interface %s not found: %w", name, err)
+	}
+
+	addrs, err := iface.Addrs()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get addresses for %s: %w", name, err)
+	}
+	return pick(addrs, name, ipv6)
+}
+
+// pick chooses Addr's result from an interface's addresses
+func pick(addrs []net.Addr, name string, ipv6 bool) (*net.IPAddr, error) {
+	var linkLocal *net.IPAddr
+	for _, addr := range addrs {
+		ipnet, ok := addr.(*net.IPNet)
+		if !ok || (ipnet.IP.To4() == nil) != ipv6 {
+			continue
+		}
+		if ipv6 && ipnet.IP.IsLinkLocalUnicast() {
+			if linkLocal == nil {
+				linkLocal = &net.IPAddr{IP: ipnet.IP, Zone: name}
+			}
+			continue
+		}
+		return &net.IPAddr{IP: ipnet.IP}, nil
+	}
+	if linkLocal != nil {
+		return linkLocal, nil
+	}
+
+	family := "IPv4"
+	if ipv6 {
+		family = "IPv6"
+	}
+	return nil, fmt.Errorf("interface %s has no %s address", name, family)
+}
@@ -0,0 +1,66 @@
+package netif
+
+import (
+	"net"
+	"strings"
+	"testing"
+)
+
+func ipNet(cidr string) *net.IPNet {
+	ip, ipnet, err := net.ParseCIDR(cidr)
+	if err != nil {
+		panic(err)
+	}
+	ipnet.IP = ip
+	return ipnet
+}
+
+func TestPick(t *testing.T) {
+	addrs := []net.Addr{
+		ipNet("fe80::1/64"),
+		ipNet("192.0.2.2/24"),
+		ipNet("2001:db8::2/64"),
+		ipNet("198.51.100.7/24"),
+	}
+
+	tests := []struct {
+		ipv6 bool
+		want string
+	}{
+		{false, "192.0.2.2"},
+		{true, "2001:db8::2"},
+	}
+	for _, tt := range tests {
+		got, err := pick(addrs, "eth0", tt.ipv6)
+		if err != nil {
+			t.Errorf("pick(ipv6=%v): %v", tt.ipv6, err)
+			continue
+		}
+		if got.String() != tt.want {
+			t.Errorf("pick(ipv6=%v) = %s, want %s", tt.ipv6, got, tt.want)
+		}
+	}
+}
+
+func TestPickLinkLocal(t *testing.T) {
+	got, err := pick([]net.Addr{ipNet("192.0.2.2/24"), ipNet("fe80::1/64")}, "eth0", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.String() != "fe80::1%eth0" {
+		t.Errorf("pick = %s, want fe80::1%%eth0", got)
+	}
+}
+
+func TestPickMissingFamily(t *testing.T) {
+	_, err := pick([]net.Addr{ipNet("fe80::1/64")}, "eth0", false)
+	if err == nil || !strings.Contains(err.Error(), "no IPv4 address") {
+		t.Errorf("pick = %v, want a missing IPv4 address error", err)
+	}
+}
+
+func TestAddrUnknownInterface(t *testing.T) {
+	if _, err := Addr("no-such-interface0", false); err == nil {
+		t.Error("Addr succeeded for a missing interface")
+	}
+}
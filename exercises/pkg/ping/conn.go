@@ -0,0 +1,503 @@
+package ping
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net"
+	"os"
+	"runtime"
+	"slices"
+	"sync"
+	"time"
+
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+)
+
+// IANA protocol numbers, which icmp.ParseMessage needs to tell the
+// two ICMP versions apart
+const (
+	protocolICMP   = 1
+	protocolICMPv6 = 58
+)
+
+// icmpFamily holds everything that differs between ICMPv4 and ICMPv6
+type icmpFamily struct {
+	raw       string // raw socket network, needs root
+	datagram  string // unprivileged datagram socket network
+	wildcard  string // listen address
+	protocol  int
+	echo      icmp.Type
+	echoReply icmp.Type
+}
+
+var (
+	familyV4 = icmpFamily{"ip4:icmp", "udp4", "0.0.0.0", protocolICMP,
+		ipv4.ICMPTypeEcho, ipv4.ICMPTypeEchoReply}
+	familyV6 = icmpFamily{"ip6:ipv6-icmp", "udp6", "::", protocolICMPv6,
+		ipv6.ICMPTypeEchoRequest, ipv6.ICMPTypeEchoReply}
+)
+
+// ErrTimeout is returned when no reply arrives in time
+var ErrTimeout = errors.New("timeout")
+
+// A reply that came back but didn't echo the request intact is
+// reported with one of these, wrapped with details. Statistics counts
+// them apart from losses: something answered, but the data was mangled
+// on the way, which points at a different fault.
+var (
+	ErrCorrupted = errors.New("corrupted reply")
+	ErrTruncated = errors.New("truncated reply")
+)
+
+// stampLen is the size of the stamp Ping writes at the start of every
+// payload with room for it: a random nonce, then the send time in
+// nanoseconds since the Unix epoch, both big-endian
+const stampLen = 16
+
+// stampPayload returns a copy of payload starting with a stamp of nonce
+// and at, so each request's data is unique and a reply to an older one
+// or a mangled copy can't pass for it. Payloads too short for a stamp
+// are sent as they are.
+func stampPayload(payload []byte, nonce uint64, at time.Time) []byte {
+	stamped := slices.Clone(payload)
+	if len(stamped) >= stampLen {
+		binary.BigEndian.PutUint64(stamped[0:8], nonce)
+		binary.BigEndian.PutUint64(stamped[8:16], uint64(at.UnixNano()))
+	}
+	return stamped
+}
+
+// checkEcho compares a reply's data with the payload sent
+func checkEcho(sent, got []byte) error {
+	if len(got) < len(sent) {
+		return fmt.Errorf("%w: %d of %d bytes came back", ErrTruncated, len(got), len(sent))
+	}
+	if len(sent) >= stampLen && !bytes.Equal(got[:stampLen], sent[:stampLen]) {
+		return fmt.Errorf("%w: nonce or timestamp differs", ErrCorrupted)
+	}
+	if !bytes.Equal(got, sent) {
+		return fmt.Errorf("%w: payload differs (%d bytes sent, %d received)", ErrCorrupted, len(sent), len(got))
+	}
+	return nil
+}
+
+// ICMPError is returned when a router, or the target, answers an echo
+// request with an ICMP error such as Destination Unreachable. From is
+// who sent it, which for a router is not the address pinged.
+type ICMPError struct {
+	Type icmp.Type
+	From net.IP
+}
+
+func (e *ICMPError) Error() string {
+	return fmt.Sprintf("%v from %s", e.Type, e.From)
+}
+
+// DefaultPayload fills echo requests unless the caller picks another
+var DefaultPayload = []byte("PING from Go exercise!")
+
+// MakePayload repeats pattern to fill size bytes
+func MakePayload(size int, pattern []byte) []byte {
+	payload := make([]byte, size)
+	for i := range payload {
+		payload[i] = pattern[i%len(pattern)]
+	}
+	return payload
+}
+
+// echoKey identifies an outstanding echo request. Targets sharing a
+// Conn can ping the same address with the same sequence number, so more
+// than one request may be waiting on a key.
+type echoKey struct {
+	ip      string
+	id, seq int
+}
+
+// waiter is a request waiting on its reply
+type waiter struct {
+	sent []byte // payload, whose stamp tells its reply from others'
+	ch   chan Reply
+}
+
+// Reply is what came back about an echo request: the echo reply from
+// the target, or an ICMP error about the request from a router
+type Reply struct {
+	Type icmp.Type
+	From net.IP
+	TTL  int    // TTL (hop limit) the reply arrived with, 0 if unknown
+	Data []byte // echoed payload
+	At   time.Time
+}
+
+// EchoReply reports whether the target itself answered
+func (r Reply) EchoReply() bool {
+	return r.Type == ipv4.ICMPTypeEchoReply || r.Type == ipv6.ICMPTypeEchoReply
+}
+
+// Unreachable reports whether a router or the target said the target
+// can't be reached
+func (r Reply) Unreachable() bool {
+	return r.Type == ipv4.ICMPTypeDestinationUnreachable || r.Type == ipv6.ICMPTypeDestinationUnreachable
+}
+
+// Conn owns one ICMP socket, which any number of targets of its IP
+// version can share. A background reader hands each echo reply to the
+// request waiting for it, so pings can overlap without opening a socket
+// per packet.
+type Conn struct {
+	conn       *icmp.PacketConn
+	family     icmpFamily
+	privileged bool
+	id         int // ID we put in requests
+	replyID    int // ID replies carry back
+
+	mu      sync.Mutex
+	pending map[echoKey][]*waiter
+}
+
+// Listen opens an ICMP socket for network "ip4" or "ip6", bound to the
+// local address if one is given so requests leave from it. Root gets a
+// raw socket; everyone else, or root without CAP_NET_RAW, falls back to
+// an ICMP datagram socket.
+func Listen(network, local string) (*Conn, error) {
+	var family icmpFamily
+	switch network {
+	case "ip4":
+		family = familyV4
+	case "ip6":
+		family = familyV6
+	default:
+		return nil, fmt.Errorf("ping: unknown network %q (want ip4 or ip6)", network)
+	}
+	if local == "" {
+		local = family.wildcard
+	}
+
+	if os.Geteuid() == 0 {
+		if conn, err := icmp.ListenPacket(family.raw, local); err == nil {
+			return newConn(conn, family, true), nil
+		}
+	}
+
+	conn, err := icmp.ListenPacket(family.datagram, local)
+	if err != nil {
+		return nil, err
+	}
+	return newConn(conn, family, false), nil
+}
+
+func newConn(conn *icmp.PacketConn, family icmpFamily, privileged bool) *Conn {
+	c := &Conn{
+		conn:       conn,
+		family:     family,
+		privileged: privileged,
+		id:         os.Getpid() & 0xffff,
+		pending:    make(map[echoKey][]*waiter),
+	}
+
+	// Linux rewrites the ID of datagram pings to the socket's local
+	// port, so that is what a genuine reply carries
+	c.replyID = c.id
+	if udp, ok := conn.LocalAddr().(*net.UDPAddr); ok && !privileged && runtime.GOOS == "linux" {
+		c.replyID = udp.Port
+	}
+
+	// Ask for each packet's TTL; not every platform supports it, and
+	// replies then just don't show one
+	if family.protocol == protocolICMP {
+		conn.IPv4PacketConn().SetControlMessage(ipv4.FlagTTL, true)
+	} else {
+		conn.IPv6PacketConn().SetControlMessage(ipv6.FlagHopLimit, true)
+	}
+
+	go c.readLoop()
+	return c
+}
+
+// Close closes the socket, failing any pings still waiting
+func (c *Conn) Close() error {
+	return c.conn.Close()
+}
+
+// Privileged reports whether this is a raw socket. Only raw sockets see
+// ICMP errors on Linux, which traceroute needs.
+func (c *Conn) Privileged() bool {
+	return c.privileged
+}
+
+// Network returns the socket's network, e.g. "ip4:icmp" or "udp6"
+func (c *Conn) Network() string {
+	if c.privileged {
+		return c.family.raw
+	}
+	return c.family.datagram
+}
+
+// SetTTL sets the TTL (hop limit) of every packet sent from now on
+func (c *Conn) SetTTL(ttl int) error {
+	if c.family.protocol == protocolICMP {
+		return c.conn.IPv4PacketConn().SetTTL(ttl)
+	}
+	return c.conn.IPv6PacketConn().SetHopLimit(ttl)
+}
+
+// SetTOS sets the TOS byte (IPv6 traffic class) of every packet sent
+// from now on. Routers that do QoS queue packets by its top six bits,
+// the DSCP.
+func (c *Conn) SetTOS(tos int) error {
+	if c.family.protocol == protocolICMP {
+		return c.conn.IPv4PacketConn().SetTOS(tos)
+	}
+	return c.conn.IPv6PacketConn().SetTrafficClass(tos)
+}
+
+// read reads one ICMP message along with the TTL it arrived with
+func (c *Conn) read(buf []byte) (n, ttl int, peer net.Addr, err error) {
+	if c.family.protocol == protocolICMP {
+		var cm *ipv4.ControlMessage
+		n, cm, peer, err = c.conn.IPv4PacketConn().ReadFrom(buf)
+		if cm != nil {
+			ttl = cm.TTL
+		}
+		return n, ttl, peer, err
+	}
+
+	var cm *ipv6.ControlMessage
+	n, cm, peer, err = c.conn.IPv6PacketConn().ReadFrom(buf)
+	if cm != nil {
+		ttl = cm.HopLimit
+	}
+	return n, ttl, peer, err
+}
+
+// addrIP extracts the IP from the address types ICMP sockets use
+func addrIP(addr net.Addr) net.IP {
+	switch a := addr.(type) {
+	case *net.IPAddr:
+		return a.IP
+	case *net.UDPAddr:
+		return a.IP
+	}
+	return nil
+}
+
+// readLoop dispatches replies until the socket is closed. A raw socket
+// sees every ICMP packet on the host, so an echo reply only counts if it
+// comes from a target with an outstanding request of the same ID and
+// sequence number. Replies to other ping processes, late replies and
+// duplicates are dropped.
+//
+// Time Exceeded and Destination Unreachable come from whichever router
+// gave up on the packet. They quote the start of our request, which
+// says what it was for.
+func (c *Conn) readLoop() {
+	buf := make([]byte, 65536) // room for the largest payload
+	for {
+		n, ttl, peer, err := c.read(buf)
+		if err != nil {
+			return
+		}
+		received := time.Now()
+
+		rm, err := icmp.ParseMessage(c.family.protocol, buf[:n])
+		if err != nil {
+			continue
+		}
+
+		var key echoKey
+		var data []byte
+		var ok bool
+		switch body := rm.Body.(type) {
+		case *icmp.Echo:
+			if rm.Type != c.family.echoReply || body.ID != c.replyID {
+				continue
+			}
+			key = echoKey{ip: addrIP(peer).String(), id: body.ID, seq: body.Seq}
+			data = slices.Clone(body.Data)
+		case *icmp.TimeExceeded:
+			if key, ok = quotedEcho(c.family.protocol, body.Data); !ok {
+				continue
+			}
+		case *icmp.DstUnreach:
+			if key, ok = quotedEcho(c.family.protocol, body.Data); !ok {
+				continue
+			}
+		default:
+			continue
+		}
+
+		c.deliver(key, Reply{Type: rm.Type, From: slices.Clone(addrIP(peer)), TTL: ttl, Data: data, At: received})
+	}
+}
+
+// deliver hands r to a request waiting on key. An echo reply goes to
+// the request whose stamp it carries, or failing that, as a mangled
+// reply, to the first still waiting. An ICMP error quotes no more than
+// the key, so every request it could be about gets it.
+func (c *Conn) deliver(key echoKey, r Reply) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	waiters := c.pending[key]
+	if r.Type != c.family.echoReply {
+		for _, w := range waiters {
+			select {
+			case w.ch <- r:
+			default:
+			}
+		}
+		return
+	}
+
+	ordered := make([]*waiter, 0, len(waiters))
+	for _, w := range waiters {
+		if len(w.sent) >= stampLen && bytes.HasPrefix(r.Data, w.sent[:stampLen]) {
+			ordered = append([]*waiter{w}, ordered...)
+		} else {
+			ordered = append(ordered, w)
+		}
+	}
+	for _, w := range ordered {
+		select {
+		case w.ch <- r:
+			return
+		default: // has its reply; this may be a duplicate
+		}
+	}
+}
+
+// wait registers a request for payload as waiting on key
+func (c *Conn) wait(key echoKey, payload []byte) *waiter {
+	w := &waiter{sent: payload, ch: make(chan Reply, 1)}
+	c.mu.Lock()
+	c.pending[key] = append(c.pending[key], w)
+	c.mu.Unlock()
+	return w
+}
+
+// done stops w waiting on key
+func (c *Conn) done(key echoKey, w *waiter) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	waiters := slices.DeleteFunc(c.pending[key], func(x *waiter) bool { return x == w })
+	if len(waiters) == 0 {
+		delete(c.pending, key)
+	} else {
+		c.pending[key] = waiters
+	}
+}
+
+// quotedEcho finds which of our requests an ICMP error is about. The
+// error quotes the request's IP header and at least the first 8 bytes
+// after it, which is our echo header with its ID and sequence number.
+func quotedEcho(protocol int, data []byte) (echoKey, bool) {
+	var dst net.IP
+	var headerLen int
+	if protocol == protocolICMP {
+		if len(data) < ipv4.HeaderLen {
+			return echoKey{}, false
+		}
+		dst = net.IP(data[16:20])
+		headerLen = int(data[0]&0x0f) * 4
+	} else {
+		if len(data) < ipv6.HeaderLen {
+			return echoKey{}, false
+		}
+		dst = net.IP(data[24:40])
+		headerLen = ipv6.HeaderLen
+	}
+	if len(data) < headerLen+8 {
+		return echoKey{}, false
+	}
+
+	echo := data[headerLen:]
+	return echoKey{
+		ip:  dst.String(),
+		id:  int(binary.BigEndian.Uint16(echo[4:6])),
+		seq: int(binary.BigEndian.Uint16(echo[6:8])),
+	}, true
+}
+
+// Ping sends one echo request carrying payload to dst and waits for its
+// reply, returning the RTT and the reply's TTL. An ICMP error about the
+// request ends the wait early. A payload of 16 bytes or more starts with
+// a per-request nonce and the send time in place of its first bytes,
+// and the reply must echo all of it unchanged.
+func (c *Conn) Ping(ctx context.Context, dst *net.IPAddr, seq int, payload []byte, timeout time.Duration) (time.Duration, int, error) {
+	payload = stampPayload(payload, rand.Uint64(), time.Now())
+	r, rtt, err := c.exchange(ctx, dst, seq, payload, timeout)
+	if err != nil {
+		return 0, 0, err
+	}
+	if r.Type != c.family.echoReply {
+		return 0, 0, &ICMPError{Type: r.Type, From: r.From}
+	}
+	if err := checkEcho(payload, r.Data); err != nil {
+		return 0, 0, err
+	}
+	return rtt, r.TTL, nil
+}
+
+// Probe is Ping with the TTL (hop limit) set, for traceroute, and
+// returns whatever came back. The whole socket gets the TTL, so probes
+// must not overlap with pings.
+func (c *Conn) Probe(ctx context.Context, dst *net.IPAddr, seq, ttl int, timeout time.Duration) (Reply, time.Duration, error) {
+	if err := c.SetTTL(ttl); err != nil {
+		return Reply{}, 0, fmt.Errorf("set TTL: %w", err)
+	}
+	return c.exchange(ctx, dst, seq, DefaultPayload, timeout)
+}
+
+// exchange sends one echo request and waits for the first reply about it
+func (c *Conn) exchange(ctx context.Context, dst *net.IPAddr, seq int, payload []byte, timeout time.Duration) (Reply, time.Duration, error) {
+	key := echoKey{ip: dst.IP.String(), id: c.replyID, seq: seq}
+	w := c.wait(key, payload)
+	defer c.done(key, w)
+
+	// Build ICMP echo request
+	msg := icmp.Message{
+		Type: c.family.echo,
+		Code: 0,
+		Body: &icmp.Echo{
+			ID:   c.id,
+			Seq:  seq,
+			Data: payload,
+		},
+	}
+
+	// The kernel fills in the ICMPv6 checksum, which covers a pseudo
+	// header we don't have here
+	msgBytes, err := msg.Marshal(nil)
+	if err != nil {
+		return Reply{}, 0, fmt.Errorf("marshal error: %w", err)
+	}
+
+	// Datagram sockets are addressed like UDP, raw sockets by IP alone
+	var to net.Addr = dst
+	if !c.privileged {
+		to = &net.UDPAddr{IP: dst.IP, Zone: dst.Zone}
+	}
+
+	// Send
+	start := time.Now()
+	if _, err := c.conn.WriteTo(msgBytes, to); err != nil {
+		return Reply{}, 0, fmt.Errorf("write error: %w", err)
+	}
+
+	// Receive reply
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case r := <-w.ch:
+		return r, r.At.Sub(start), nil
+	case <-timer.C:
+		return Reply{}, 0, ErrTimeout
+	case <-ctx.Done():
+		return Reply{}, 0, ctx.Err()
+	}
+}
@@ -0,0 +1,153 @@
+package ping
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+)
+
+// quotedRequest is what a router quotes back about an echo request to
+// 192.0.2.1: its IPv4 header and the echo header
+func quotedRequest(t *testing.T, id, seq int) []byte {
+	t.Helper()
+
+	msg := icmp.Message{Type: ipv4.ICMPTypeEcho, Body: &icmp.Echo{ID: id, Seq: seq, Data: DefaultPayload}}
+	echo, err := msg.Marshal(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	header := make([]byte, ipv4.HeaderLen)
+	header[0] = 0x45 // version 4, 20 byte header
+	copy(header[16:20], []byte{192, 0, 2, 1})
+	return append(header, echo[:8]...)
+}
+
+func TestQuotedEcho(t *testing.T) {
+	data := quotedRequest(t, 0x1234, 7)
+
+	key, ok := quotedEcho(protocolICMP, data)
+	if !ok {
+		t.Fatal("quotedEcho didn't find the request")
+	}
+	want := echoKey{ip: "192.0.2.1", id: 0x1234, seq: 7}
+	if key != want {
+		t.Errorf("quotedEcho = %+v, want %+v", key, want)
+	}
+
+	if _, ok := quotedEcho(protocolICMP, data[:ipv4.HeaderLen+4]); ok {
+		t.Error("quotedEcho accepted a truncated echo header")
+	}
+	if _, ok := quotedEcho(protocolICMPv6, data); ok {
+		t.Error("quotedEcho read an IPv4 quote as IPv6")
+	}
+}
+
+func TestListenUnknownNetwork(t *testing.T) {
+	if _, err := Listen("udp", ""); err == nil {
+		t.Error("Listen accepted network udp")
+	}
+}
+
+func TestICMPError(t *testing.T) {
+	var err error = &ICMPError{Type: ipv4.ICMPTypeTimeExceeded, From: net.IPv4(192, 0, 2, 254)}
+	if got, want := err.Error(), "time exceeded from 192.0.2.254"; got != want {
+		t.Errorf("Error() = %q, want %q", got, want)
+	}
+
+	var ie *ICMPError
+	if !errors.As(fmt.Errorf("seq 1: %w", err), &ie) || !ie.From.Equal(net.IPv4(192, 0, 2, 254)) {
+		t.Error("errors.As didn't recover the ICMPError")
+	}
+}
+
+func TestStampPayload(t *testing.T) {
+	payload := MakePayload(32, []byte{0xab})
+	at := time.Unix(1700000000, 123)
+	stamped := stampPayload(payload, 0x0102030405060708, at)
+
+	if payload[0] != 0xab {
+		t.Fatal("stampPayload modified the caller's payload")
+	}
+	if got := binary.BigEndian.Uint64(stamped[0:8]); got != 0x0102030405060708 {
+		t.Errorf("nonce = %#x", got)
+	}
+	if got := int64(binary.BigEndian.Uint64(stamped[8:16])); got != at.UnixNano() {
+		t.Errorf("timestamp = %d, want %d", got, at.UnixNano())
+	}
+	if !bytes.Equal(stamped[stampLen:], payload[stampLen:]) {
+		t.Error("stampPayload changed the bytes after the stamp")
+	}
+
+	short := []byte("tiny")
+	if got := stampPayload(short, 1, at); !bytes.Equal(got, short) {
+		t.Errorf("short payload stamped: %q", got)
+	}
+}
+
+func TestCheckEcho(t *testing.T) {
+	sent := stampPayload(MakePayload(32, []byte{0xab}), 42, time.Now())
+	flip := func(i int) []byte {
+		got := bytes.Clone(sent)
+		got[i] ^= 0xff
+		return got
+	}
+
+	tests := []struct {
+		name string
+		got  []byte
+		want error
+	}{
+		{"intact", bytes.Clone(sent), nil},
+		{"truncated", sent[:20], ErrTruncated},
+		{"nonce", flip(3), ErrCorrupted},
+		{"timestamp", flip(12), ErrCorrupted},
+		{"body", flip(30), ErrCorrupted},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkEcho(sent, tt.got)
+			if !errors.Is(err, tt.want) || (tt.want == nil && err != nil) {
+				t.Errorf("checkEcho = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestDeliverSharedKey(t *testing.T) {
+	// Two targets pinging the same address with the same sequence
+	// number wait on one key; each reply must reach its own request
+	c := &Conn{family: familyV4, pending: make(map[echoKey][]*waiter)}
+	key := echoKey{ip: "192.0.2.1", id: 1, seq: 7}
+	a := stampPayload(DefaultPayload, 1, time.Now())
+	b := stampPayload(DefaultPayload, 2, time.Now())
+	wa, wb := c.wait(key, a), c.wait(key, b)
+
+	c.deliver(key, Reply{Type: ipv4.ICMPTypeEchoReply, Data: b})
+	c.deliver(key, Reply{Type: ipv4.ICMPTypeEchoReply, Data: a})
+	if r := <-wa.ch; !bytes.Equal(r.Data, a) {
+		t.Error("first request got the second's reply")
+	}
+	if r := <-wb.ch; !bytes.Equal(r.Data, b) {
+		t.Error("second request got the first's reply")
+	}
+
+	c.done(key, wa)
+	if got := len(c.pending[key]); got != 1 {
+		t.Errorf("%d requests waiting after one is done, want 1", got)
+	}
+	c.deliver(key, Reply{Type: ipv4.ICMPTypeDestinationUnreachable})
+	if r := <-wb.ch; !r.Unreachable() {
+		t.Errorf("got %v, want the unreachable error", r.Type)
+	}
+	c.done(key, wb)
+	if _, ok := c.pending[key]; ok {
+		t.Error("key still pending with no requests waiting")
+	}
+}
@@ -0,0 +1,253 @@
+// Package ping implements ICMP echo (ping) over raw or unprivileged
+// datagram sockets, for IPv4 and IPv6.
+//
+// It is the engine behind exercise 04 and can be embedded by other
+// exercises that need to check whether a host answers:
+//
+//	dst, _ := net.ResolveIPAddr("ip", "127.0.0.1")
+//	p := ping.New(dst, ping.Config{
+//		Count: 3,
+//		OnRecv: func(pkt ping.Packet) {
+//			fmt.Println(pkt.Seq, pkt.RTT, pkt.Err)
+//		},
+//	})
+//	if err := p.Run(ctx); err != nil {
+//		log.Fatal(err) // no ICMP socket
+//	}
+//	fmt.Printf("%.1f%% loss\n", p.Statistics().Loss())
+package ping
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net"
+	"sync"
+	"time"
+)
+
+// Defaults applied to zero Config fields
+const (
+	DefaultInterval = time.Second
+	DefaultTimeout  = 2 * time.Second
+)
+
+// Prober sends one probe to dst and times it, returning the RTT and the
+// reply's TTL (0 if unknown). *Conn is the ICMP echo prober; callers can
+// plug in others, e.g. timing TCP connects where ICMP is filtered.
+type Prober interface {
+	Ping(ctx context.Context, dst *net.IPAddr, seq int, payload []byte, timeout time.Duration) (time.Duration, int, error)
+}
+
+// Packet is the outcome of one ping
+type Packet struct {
+	Seq    int // counts from 1 and, unlike the ICMP field, never wraps
+	Size   int // payload bytes
+	SentAt time.Time
+	RTT    time.Duration
+	TTL    int   // reply TTL, 0 if unknown
+	Err    error // ErrTimeout, an ICMP error, ...; nil for a reply
+}
+
+// Config describes how a Pinger pings
+type Config struct {
+	// Count is the number of pings to send; 0 pings until ctx is done
+	Count int
+	// Interval is the time between pings. Pings don't wait for the
+	// previous reply, so a slow one just overlaps the next request.
+	Interval time.Duration
+	// Adaptive makes each ping wait for the previous one's reply (or
+	// timeout) too, like ping -A: at most one ping is outstanding, and
+	// Interval is only the minimum. On a fast network that is a ping
+	// per Interval; on a slow one, a ping per RTT.
+	Adaptive bool
+	// Timeout bounds the wait for each reply
+	Timeout time.Duration
+	// Payloads are sent in turn, one per ping. Defaults to DefaultPayload.
+	Payloads [][]byte
+	// Prober sends the pings. Defaults to an ICMP Conn that Run opens
+	// for this Pinger alone; share one Conn between Pingers instead to
+	// ping many hosts from one socket.
+	Prober Prober
+
+	// OnSend, if set, is called as each request goes out
+	OnSend func(seq int)
+	// OnRecv, if set, is called for each reply or failed ping. Calls
+	// don't overlap, and Statistics already counts the packet.
+	OnRecv func(Packet)
+	// OnFinish, if set, is called with the final statistics when Run
+	// is done
+	OnFinish func(Statistics)
+}
+
+// Pinger pings one host and keeps running statistics
+type Pinger struct {
+	dst *net.IPAddr
+	cfg Config
+
+	mu     sync.Mutex
+	stats  Statistics
+	recvMu sync.Mutex // serializes OnRecv
+}
+
+// New returns a Pinger for dst; nothing is sent until Run
+func New(dst *net.IPAddr, cfg Config) *Pinger {
+	if cfg.Interval <= 0 {
+		cfg.Interval = DefaultInterval
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = DefaultTimeout
+	}
+	if len(cfg.Payloads) == 0 {
+		cfg.Payloads = [][]byte{DefaultPayload}
+	}
+	return &Pinger{dst: dst, cfg: cfg}
+}
+
+// Statistics returns a copy of the statistics so far
+func (p *Pinger) Statistics() Statistics {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.stats
+}
+
+// Run sends Count pings, one every Interval, and returns once the last
+// has been answered or timed out, or ctx is done. Pings still waiting
+// when ctx is cancelled are abandoned without being reported. The ICMP
+// sequence field is 16 bits, so it wraps in long sessions while the
+// reported Seq keeps going.
+//
+// Run only fails when it has to open its own ICMP socket and can't.
+func (p *Pinger) Run(ctx context.Context) error {
+	prober := p.cfg.Prober
+	if prober == nil {
+		network := "ip4"
+		if p.dst.IP.To4() == nil {
+			network = "ip6"
+		}
+		conn, err := Listen(network, "")
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+		prober = conn
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; p.cfg.Count == 0 || i < p.cfg.Count; i++ {
+		seq := i + 1
+		p.mu.Lock()
+		p.stats.PacketsSent++
+		p.mu.Unlock()
+		if p.cfg.OnSend != nil {
+			p.cfg.OnSend(seq)
+		}
+
+		answered := make(chan struct{})
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer close(answered)
+			payload := p.cfg.Payloads[i%len(p.cfg.Payloads)]
+			sentAt := time.Now()
+			rtt, ttl, err := prober.Ping(ctx, p.dst, seq&0xffff, payload, p.cfg.Timeout)
+			if ctx.Err() != nil {
+				return
+			}
+
+			if err == nil {
+				p.mu.Lock()
+				p.stats.record(rtt)
+				p.mu.Unlock()
+			} else if errors.Is(err, ErrCorrupted) || errors.Is(err, ErrTruncated) {
+				p.mu.Lock()
+				p.stats.PacketsCorrupted++
+				p.mu.Unlock()
+			}
+			if p.cfg.OnRecv != nil {
+				p.recvMu.Lock()
+				defer p.recvMu.Unlock()
+				p.cfg.OnRecv(Packet{Seq: seq, Size: len(payload), SentAt: sentAt, RTT: rtt, TTL: ttl, Err: err})
+			}
+		}()
+
+		// Wait between pings (except for last one)
+		if i == p.cfg.Count-1 {
+			break
+		}
+		next := time.After(p.cfg.Interval)
+		if p.cfg.Adaptive {
+			select {
+			case <-ctx.Done():
+			case <-answered:
+			}
+		}
+		select {
+		case <-ctx.Done():
+		case <-next:
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	wg.Wait()
+
+	if p.cfg.OnFinish != nil {
+		p.cfg.OnFinish(p.Statistics())
+	}
+	return nil
+}
+
+// Statistics summarizes a ping session the way system ping does
+type Statistics struct {
+	PacketsSent      int
+	PacketsRecv      int // intact replies
+	PacketsCorrupted int // replies that came back mangled or truncated
+	MinRTT           time.Duration
+	MaxRTT           time.Duration
+	AvgRTT           time.Duration
+	TotalRTT         time.Duration
+	LastRTT          time.Duration
+	MDev             time.Duration // standard deviation, as iputils ping reports
+	Jitter           time.Duration // mean difference between consecutive RTTs
+
+	sumSquares float64       // of RTTs in nanoseconds, for MDev
+	sumDiffs   time.Duration // of |RTT - previous RTT|, for Jitter
+}
+
+// record adds a reply's RTT to the statistics
+func (s *Statistics) record(rtt time.Duration) {
+	if s.PacketsRecv == 0 {
+		s.MinRTT, s.MaxRTT = rtt, rtt
+	} else {
+		diff := rtt - s.LastRTT
+		if diff < 0 {
+			diff = -diff
+		}
+		s.sumDiffs += diff
+		s.Jitter = s.sumDiffs / time.Duration(s.PacketsRecv)
+	}
+
+	s.PacketsRecv++
+	s.TotalRTT += rtt
+	s.LastRTT = rtt
+	s.AvgRTT = s.TotalRTT / time.Duration(s.PacketsRecv)
+
+	// Variance is the mean of the squares minus the square of the mean
+	s.sumSquares += float64(rtt) * float64(rtt)
+	mean := float64(s.TotalRTT) / float64(s.PacketsRecv)
+	variance := s.sumSquares/float64(s.PacketsRecv) - mean*mean
+	s.MDev = time.Duration(math.Sqrt(max(variance, 0)))
+
+	s.MinRTT = min(s.MinRTT, rtt)
+	s.MaxRTT = max(s.MaxRTT, rtt)
+}
+
+// Loss returns the percentage of pings that went unanswered. Corrupted
+// replies are answers, so they don't count as lost.
+func (s Statistics) Loss() float64 {
+	if s.PacketsSent == 0 {
+		return 0
+	}
+	return float64(s.PacketsSent-s.PacketsRecv-s.PacketsCorrupted) / float64(s.PacketsSent) * 100
+}
@@ -0,0 +1,235 @@
+package ping
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeProber answers from a script of RTTs; a negative RTT fails that
+// ping with ErrTimeout
+type fakeProber struct {
+	mu       sync.Mutex
+	rtts     []time.Duration
+	payloads [][]byte
+}
+
+func (f *fakeProber) Ping(ctx context.Context, dst *net.IPAddr, seq int, payload []byte, timeout time.Duration) (time.Duration, int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.payloads = append(f.payloads, payload)
+	rtt := f.rtts[(seq-1)%len(f.rtts)]
+	if rtt < 0 {
+		return 0, 0, ErrTimeout
+	}
+	return rtt, 64, nil
+}
+
+var localhost = &net.IPAddr{IP: net.IPv4(127, 0, 0, 1)}
+
+func TestRunStatistics(t *testing.T) {
+	ms := time.Millisecond
+	fake := &fakeProber{rtts: []time.Duration{10 * ms, 20 * ms, -1, 30 * ms}}
+
+	var sent []int
+	var packets []Packet
+	var final Statistics
+	p := New(localhost, Config{
+		Count:    4,
+		Interval: time.Millisecond,
+		Prober:   fake,
+		OnSend:   func(seq int) { sent = append(sent, seq) },
+		OnRecv:   func(pkt Packet) { packets = append(packets, pkt) },
+		OnFinish: func(s Statistics) { final = s },
+	})
+	if err := p.Run(context.Background()); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	if len(sent) != 4 || len(packets) != 4 {
+		t.Fatalf("got %d sends and %d packets, want 4 of each", len(sent), len(packets))
+	}
+	lost := 0
+	for _, pkt := range packets {
+		if errors.Is(pkt.Err, ErrTimeout) {
+			lost++
+			if pkt.Seq != 3 {
+				t.Errorf("seq %d timed out, want seq 3", pkt.Seq)
+			}
+		} else if pkt.TTL != 64 {
+			t.Errorf("seq %d: TTL %d, want 64", pkt.Seq, pkt.TTL)
+		}
+	}
+	if lost != 1 {
+		t.Errorf("%d packets lost, want 1", lost)
+	}
+
+	s := p.Statistics()
+	if s != final {
+		t.Errorf("OnFinish got %+v, Statistics says %+v", final, s)
+	}
+	if s.PacketsSent != 4 || s.PacketsRecv != 3 || s.Loss() != 25 {
+		t.Errorf("sent %d, received %d, loss %.1f%%; want 4, 3, 25%%", s.PacketsSent, s.PacketsRecv, s.Loss())
+	}
+	if s.MinRTT != 10*ms || s.MaxRTT != 30*ms || s.AvgRTT != 20*ms {
+		t.Errorf("min/avg/max = %v/%v/%v, want 10ms/20ms/30ms", s.MinRTT, s.AvgRTT, s.MaxRTT)
+	}
+}
+
+// errProber fails pings with a script of errors; nil answers in 1ms
+type errProber struct {
+	errs []error
+}
+
+func (e *errProber) Ping(ctx context.Context, dst *net.IPAddr, seq int, payload []byte, timeout time.Duration) (time.Duration, int, error) {
+	if err := e.errs[(seq-1)%len(e.errs)]; err != nil {
+		return 0, 0, err
+	}
+	return time.Millisecond, 64, nil
+}
+
+func TestRunCorrupted(t *testing.T) {
+	p := New(localhost, Config{
+		Count:    4,
+		Interval: time.Millisecond,
+		Prober:   &errProber{errs: []error{nil, fmt.Errorf("%w: bit flip", ErrCorrupted), ErrTimeout, ErrTruncated}},
+	})
+	if err := p.Run(context.Background()); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	s := p.Statistics()
+	if s.PacketsRecv != 1 || s.PacketsCorrupted != 2 || s.Loss() != 25 {
+		t.Errorf("received %d, corrupted %d, loss %.1f%%; want 1, 2, 25%%", s.PacketsRecv, s.PacketsCorrupted, s.Loss())
+	}
+}
+
+func TestStatisticsRecord(t *testing.T) {
+	ms := time.Millisecond
+	var s Statistics
+	for _, rtt := range []time.Duration{10 * ms, 30 * ms, 10 * ms, 30 * ms} {
+		s.PacketsSent++
+		s.record(rtt)
+	}
+
+	// Every RTT is 10ms from the mean, and 20ms from the one before
+	if s.MDev != 10*ms {
+		t.Errorf("MDev = %v, want 10ms", s.MDev)
+	}
+	if s.Jitter != 20*ms {
+		t.Errorf("Jitter = %v, want 20ms", s.Jitter)
+	}
+	if s.Loss() != 0 {
+		t.Errorf("Loss = %.1f%%, want 0", s.Loss())
+	}
+}
+
+func TestRunPayloads(t *testing.T) {
+	fake := &fakeProber{rtts: []time.Duration{time.Millisecond}}
+	payloads := [][]byte{MakePayload(4, []byte{0xff}), MakePayload(8, []byte{0xff, 0x00})}
+
+	var sizes []int
+	p := New(localhost, Config{
+		Count:    3,
+		Interval: time.Millisecond,
+		Payloads: payloads,
+		Prober:   fake,
+		OnRecv:   func(pkt Packet) { sizes = append(sizes, pkt.Size) },
+	})
+	p.Run(context.Background())
+
+	total := 0
+	for _, n := range sizes {
+		total += n
+	}
+	if total != 4+8+4 {
+		t.Errorf("payload sizes %v, want 4, 8 and 4 in some order", sizes)
+	}
+	if got := string(payloads[1]); got != "\xff\x00\xff\x00\xff\x00\xff\x00" {
+		t.Errorf("MakePayload = %q", got)
+	}
+}
+
+func TestRunCancel(t *testing.T) {
+	fake := &fakeProber{rtts: []time.Duration{time.Millisecond}}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	p := New(localhost, Config{Interval: 10 * time.Millisecond, Prober: fake})
+	done := make(chan struct{})
+	go func() {
+		p.Run(ctx)
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Run with Count 0 didn't return once ctx was done")
+	}
+	if sent := p.Statistics().PacketsSent; sent < 2 {
+		t.Errorf("sent %d pings in 50ms at a 10ms interval", sent)
+	}
+}
+
+// slowProber takes delay to answer and tracks how many pings overlap
+type slowProber struct {
+	delay time.Duration
+
+	mu                      sync.Mutex
+	outstanding, maxOverlap int
+}
+
+func (sp *slowProber) Ping(ctx context.Context, dst *net.IPAddr, seq int, payload []byte, timeout time.Duration) (time.Duration, int, error) {
+	sp.mu.Lock()
+	sp.outstanding++
+	sp.maxOverlap = max(sp.maxOverlap, sp.outstanding)
+	sp.mu.Unlock()
+
+	time.Sleep(sp.delay)
+
+	sp.mu.Lock()
+	sp.outstanding--
+	sp.mu.Unlock()
+	return sp.delay, 0, nil
+}
+
+func TestRunAdaptive(t *testing.T) {
+	for _, adaptive := range []bool{false, true} {
+		sp := &slowProber{delay: 20 * time.Millisecond}
+		p := New(localhost, Config{Count: 5, Interval: time.Millisecond, Adaptive: adaptive, Prober: sp})
+
+		start := time.Now()
+		p.Run(context.Background())
+		elapsed := time.Since(start)
+
+		if adaptive && (sp.maxOverlap != 1 || elapsed < 5*sp.delay) {
+			t.Errorf("adaptive: %d pings overlapped, 5 took %v; want 1 at a time", sp.maxOverlap, elapsed)
+		}
+		if !adaptive && sp.maxOverlap < 2 {
+			t.Errorf("fixed interval: pings didn't overlap, so they waited for replies")
+		}
+	}
+}
+
+// TestPingLocalhost sends real echo requests, which needs root or, on
+// Linux, a ping_group_range that includes us
+func TestPingLocalhost(t *testing.T) {
+	conn, err := Listen("ip4", "")
+	if err != nil {
+		t.Skipf("no ICMP socket: %v", err)
+	}
+	defer conn.Close()
+
+	p := New(localhost, Config{Count: 3, Interval: 10 * time.Millisecond, Timeout: time.Second, Prober: conn})
+	if err := p.Run(context.Background()); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if s := p.Statistics(); s.PacketsRecv != 3 {
+		t.Errorf("%d of %d pings to 127.0.0.1 answered", s.PacketsRecv, s.PacketsSent)
+	}
+}
@@ -0,0 +1,103 @@
+// Package relay copies bytes both ways between two connections, as a
+// proxy does between its client and the server it connected to:
+//
+//	sent, received := relay.Copy(client, server)
+//
+// When one side finishes sending, the other is told with a half close
+// (FIN) rather than a full one, so replies still in flight get through.
+// CopyIdle also gives up on a session neither side has sent anything on
+// for a while.
+package relay
+
+import (
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrIdle is returned by CopyIdle when it closed both connections
+// because neither side sent anything for the idle timeout
+var ErrIdle = errors.New("idle timeout")
+
+// Copy copies from a to b and from b to a until both directions are
+// done, returning how many bytes went each way. Neither connection is
+// closed fully; that's left to the caller once Copy returns.
+func Copy(a, b net.Conn) (aToB, bToA int64) {
+	aToB, bToA, _ = CopyIdle(a, b, 0)
+	return aToB, bToA
+}
+
+// CopyIdle is Copy, except that once idle passes with no bytes either
+// way it closes both connections and returns ErrIdle. It's the session
+// that must be idle, not one direction: a download the client sends
+// nothing during keeps going. An idle of 0 never times out.
+func CopyIdle(a, b net.Conn, idle time.Duration) (aToB, bToA int64, err error) {
+	var ra, rb io.Reader = a, b
+	var timedOut atomic.Bool
+	if idle > 0 {
+		var last atomic.Int64 // UnixNano of the last read either way
+		last.Store(time.Now().UnixNano())
+		ra = &activity{r: a, last: &last}
+		rb = &activity{r: b, last: &last}
+
+		// Rather than a deadline per read, which would cut a direction
+		// that's quiet while the other is busy, one timer checks when
+		// anything last moved. It starts an hour out and is only brought
+		// in once timer is set, so the callback can't read it before that.
+		var timer *time.Timer
+		timer = time.AfterFunc(time.Hour, func() {
+			quiet := time.Since(time.Unix(0, last.Load()))
+			if quiet < idle {
+				timer.Reset(idle - quiet)
+				return
+			}
+			timedOut.Store(true)
+			a.Close()
+			b.Close()
+		})
+		timer.Reset(idle)
+		defer timer.Stop()
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		aToB, _ = io.Copy(b, ra)
+		CloseWrite(b)
+	}()
+	bToA, _ = io.Copy(a, rb)
+	CloseWrite(a)
+	wg.Wait()
+	if timedOut.Load() {
+		return aToB, bToA, ErrIdle
+	}
+	return aToB, bToA, nil
+}
+
+// activity notes the time of every read that gets bytes
+type activity struct {
+	r    io.Reader
+	last *atomic.Int64
+}
+
+func (a *activity) Read(p []byte) (int, error) {
+	n, err := a.r.Read(p)
+	if n > 0 {
+		a.last.Store(time.Now().UnixNano())
+	}
+	return n, err
+}
+
+// CloseWrite half-closes conn if it can (TCP, TLS and Unix connections
+// can), or closes it
+func CloseWrite(conn net.Conn) {
+	if cw, ok := conn.(interface{ CloseWrite() error }); ok {
+		cw.CloseWrite()
+		return
+	}
+	conn.Close()
+}
@@ -0,0 +1,142 @@
+package relay
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+// pair returns both ends of a TCP connection on localhost
+func pair(t *testing.T) (net.Conn, net.Conn) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		conn, _ := ln.Accept()
+		accepted <- conn
+	}()
+	dialed, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn := <-accepted
+	if conn == nil {
+		t.Fatal("accept failed")
+	}
+	t.Cleanup(func() { dialed.Close(); conn.Close() })
+	return dialed, conn
+}
+
+func TestCopyHalfClose(t *testing.T) {
+	client, proxyFront := pair(t)
+	proxyBack, server := pair(t)
+
+	type result struct{ up, down int64 }
+	done := make(chan result, 1)
+	go func() {
+		up, down := Copy(proxyFront, proxyBack)
+		done <- result{up, down}
+	}()
+
+	// The client sends its request and half-closes, as a client that
+	// has said everything does; the server only answers once it has
+	// seen the end of the request, so a full close would lose the reply
+	client.Write([]byte("request"))
+	client.(*net.TCPConn).CloseWrite()
+	server.SetDeadline(time.Now().Add(5 * time.Second))
+	request, err := io.ReadAll(server)
+	if err != nil || string(request) != "request" {
+		t.Fatalf("server read %q, %v", request, err)
+	}
+	server.Write([]byte("a longer reply"))
+	server.Close()
+
+	client.SetDeadline(time.Now().Add(5 * time.Second))
+	reply, err := io.ReadAll(client)
+	if err != nil || string(reply) != "a longer reply" {
+		t.Fatalf("client read %q, %v", reply, err)
+	}
+	select {
+	case r := <-done:
+		if r.up != 7 || r.down != 14 {
+			t.Errorf("Copy = %d, %d; want 7, 14", r.up, r.down)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Copy didn't return once both sides were done")
+	}
+}
+
+func TestCloseWriteWithoutHalfClose(t *testing.T) {
+	a, b := net.Pipe()
+	defer b.Close()
+	CloseWrite(a) // a pipe can't half-close, so it's closed
+	if _, err := a.Write([]byte("x")); err == nil {
+		t.Error("write after CloseWrite on a pipe succeeded")
+	}
+}
+
+func TestCopyIdleTimesOut(t *testing.T) {
+	_, proxyFront := pair(t) // nobody sends anything
+	proxyBack, _ := pair(t)
+
+	start := time.Now()
+	_, _, err := CopyIdle(proxyFront, proxyBack, 100*time.Millisecond)
+	if !errors.Is(err, ErrIdle) {
+		t.Fatalf("CopyIdle = %v, want ErrIdle", err)
+	}
+	if took := time.Since(start); took < 100*time.Millisecond || took > 2*time.Second {
+		t.Errorf("timed out after %v, want about 100ms", took)
+	}
+}
+
+// A timeout shorter than it takes to start the timer must still work,
+// and not race on it (go test -race)
+func TestCopyIdleTinyTimeout(t *testing.T) {
+	for range 100 {
+		_, proxyFront := pair(t)
+		proxyBack, _ := pair(t)
+		if _, _, err := CopyIdle(proxyFront, proxyBack, time.Nanosecond); !errors.Is(err, ErrIdle) {
+			t.Fatalf("CopyIdle = %v, want ErrIdle", err)
+		}
+	}
+}
+
+func TestCopyIdleOneWayTrafficKeepsItOpen(t *testing.T) {
+	client, proxyFront := pair(t)
+	proxyBack, server := pair(t)
+
+	done := make(chan error, 1)
+	go func() {
+		_, down, err := CopyIdle(proxyFront, proxyBack, 150*time.Millisecond)
+		if err == nil && down != 10 {
+			err = fmt.Errorf("%d bytes down, want 10", down)
+		}
+		done <- err
+	}()
+
+	// The server trickles a byte every 50ms for half a second, three
+	// idle timeouts in all, while the client says nothing
+	go func() {
+		for range 10 {
+			server.Write([]byte("x"))
+			time.Sleep(50 * time.Millisecond)
+		}
+		server.Close()
+	}()
+	client.SetDeadline(time.Now().Add(5 * time.Second))
+	got, _ := io.ReadAll(client)
+	if len(got) != 10 {
+		t.Errorf("client read %d bytes, want 10", len(got))
+	}
+	client.Close()
+	if err := <-done; err != nil {
+		t.Errorf("CopyIdle: %v", err)
+	}
+}
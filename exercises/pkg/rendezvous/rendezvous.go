@@ -0,0 +1,35 @@
+// Package rendezvous spreads keys over a set of members with rendezvous
+// (highest random weight) hashing: every member scores every key and the
+// highest score wins it. When a member leaves, only the keys it won move,
+// and everyone who knows the same members agrees without talking.
+//
+//	owner := rendezvous.Pick([]string{"a", "b", "c"}, "some-key")
+package rendezvous
+
+import "hash/fnv"
+
+// Score scores member for key; the highest scoring member wins it
+func Score(member, key string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(member))
+	h.Write([]byte{0})
+	h.Write([]byte(key))
+	// FNV's bits are poorly mixed for short inputs; finish them as
+	// splitmix64 does
+	x := h.Sum64()
+	x = (x ^ x>>30) * 0xbf58476d1ce4e5b9
+	x = (x ^ x>>27) * 0x94d049bb133111eb
+	return x ^ x>>31
+}
+
+// Pick returns the member that wins key, or "" if there are none
+func Pick(members []string, key string) string {
+	var best string
+	var bestScore uint64
+	for i, m := range members {
+		if score := Score(m, key); i == 0 || score > bestScore {
+			best, bestScore = m, score
+		}
+	}
+	return best
+}
@@ -0,0 +1,48 @@
+package rendezvous
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+)
+
+func TestPickIsStableAndSpreads(t *testing.T) {
+	members := []string{"10.0.0.1:80", "10.0.0.2:80", "10.0.0.3:80"}
+	reversed := slices.Clone(members)
+	slices.Reverse(reversed)
+	counts := make(map[string]int)
+	for i := range 3000 {
+		key := fmt.Sprintf("192.168.%d.%d", i/256, i%256)
+		owner := Pick(members, key)
+		if again := Pick(reversed, key); again != owner {
+			t.Fatalf("%s: %s, then %s with the members reversed", key, owner, again)
+		}
+		counts[owner]++
+	}
+	for _, m := range members {
+		if counts[m] < 800 || counts[m] > 1200 {
+			t.Errorf("%s won %d of 3000 keys, want about 1000", m, counts[m])
+		}
+	}
+}
+
+func TestPickMovesOnlyTheLeaversKeys(t *testing.T) {
+	members := []string{"a", "b", "c", "d"}
+	remaining := []string{"a", "b", "d"}
+	for i := range 1000 {
+		key := fmt.Sprint(i)
+		before, after := Pick(members, key), Pick(remaining, key)
+		if before != "c" && before != after {
+			t.Fatalf("key %s moved from %s to %s though %s stayed", key, before, after, before)
+		}
+		if after == "c" {
+			t.Fatalf("key %s went to a member that left", key)
+		}
+	}
+}
+
+func TestPickNone(t *testing.T) {
+	if got := Pick(nil, "key"); got != "" {
+		t.Errorf("Pick(nil) = %q, want \"\"", got)
+	}
+}
@@ -0,0 +1,154 @@
+package scanner
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"golang.org/x/net/proxy"
+)
+
+// Dialer is what Probe uses to open connections. Swapping it out lets
+// the same scan run directly or through a jump host.
+type Dialer interface {
+	DialContext(ctx context.Context, network, address string) (net.Conn, error)
+}
+
+// ProxyError wraps a failure reported by a proxy rather than the target.
+// The proxy only tells us what it saw, so closed and filtered ports can
+// look the same from our side.
+type ProxyError struct {
+	Err error
+}
+
+func (e *ProxyError) Error() string { return "proxy: " + e.Err.Error() }
+func (e *ProxyError) Unwrap() error { return e.Err }
+
+// NewDialer returns a direct dialer, or one that tunnels through the
+// proxy in proxyURL (socks5://, socks5h:// or http://)
+func NewDialer(proxyURL string) (Dialer, error) {
+	if proxyURL == "" {
+		return &net.Dialer{}, nil
+	}
+
+	u, err := url.Parse(proxyURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy URL: %w", err)
+	}
+
+	switch u.Scheme {
+	case "socks5", "socks5h":
+		d, err := proxy.FromURL(u, &net.Dialer{})
+		if err != nil {
+			return nil, err
+		}
+		cd, ok := d.(proxy.ContextDialer)
+		if !ok {
+			return nil, fmt.Errorf("SOCKS dialer does not support contexts")
+		}
+		return &socksDialer{cd}, nil
+	case "http":
+		return newHTTPConnectDialer(u), nil
+	default:
+		return nil, fmt.Errorf("unsupported proxy scheme %q (want socks5 or http)", u.Scheme)
+	}
+}
+
+// ProxyAccuracyNote explains what a proxy can and cannot tell us
+func ProxyAccuracyNote(proxyURL string) string {
+	if strings.HasPrefix(proxyURL, "http") {
+		return "HTTP proxies answer 502/503 for both closed and filtered ports; non-open ports are reported as filtered"
+	}
+	return "closed vs filtered comes from the SOCKS reply code, which some proxies report inaccurately"
+}
+
+// socksDialer tags SOCKS failures as ProxyErrors
+type socksDialer struct {
+	d proxy.ContextDialer
+}
+
+func (s *socksDialer) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	conn, err := s.d.DialContext(ctx, network, address)
+	if err != nil {
+		return nil, &ProxyError{Err: err}
+	}
+	return conn, nil
+}
+
+// httpConnectDialer tunnels TCP through an HTTP proxy using CONNECT
+type httpConnectDialer struct {
+	proxyAddr string
+	auth      string // Proxy-Authorization value, if credentials were given
+}
+
+func newHTTPConnectDialer(u *url.URL) *httpConnectDialer {
+	d := &httpConnectDialer{proxyAddr: u.Host}
+	if u.Port() == "" {
+		d.proxyAddr = net.JoinHostPort(u.Hostname(), "8080")
+	}
+	if u.User != nil {
+		pass, _ := u.User.Password()
+		creds := u.User.Username() + ":" + pass
+		d.auth = "Basic " + base64.StdEncoding.EncodeToString([]byte(creds))
+	}
+	return d
+}
+
+func (h *httpConnectDialer) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", h.proxyAddr)
+	if err != nil {
+		return nil, err // the proxy itself is unreachable
+	}
+
+	// Bound the CONNECT exchange by the caller's deadline
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	req := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Opaque: address},
+		Host:   address,
+		Header: make(http.Header),
+	}
+	if h.auth != "" {
+		req.Header.Set("Proxy-Authorization", h.auth)
+	}
+	if err := req.Write(conn); err != nil {
+		conn.Close()
+		return nil, &ProxyError{Err: err}
+	}
+
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		conn.Close()
+		return nil, &ProxyError{Err: err}
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		conn.Close()
+		return nil, &ProxyError{Err: fmt.Errorf("CONNECT %s: %s", address, resp.Status)}
+	}
+
+	conn.SetDeadline(time.Time{})
+	return &bufferedConn{Conn: conn, r: br}, nil
+}
+
+// bufferedConn keeps any bytes the response reader already pulled in
+type bufferedConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+func (c *bufferedConn) Read(p []byte) (int, error) {
+	return c.r.Read(p)
+}
@@ -0,0 +1,185 @@
+package scanner
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+)
+
+// ExcludeList holds hosts and networks that must never be probed
+type ExcludeList struct {
+	entries []string
+	nets    []*net.IPNet
+	names   map[string]bool
+}
+
+// NewExcludeList parses IPs, CIDR blocks and hostnames. A nil or empty
+// list excludes nothing.
+func NewExcludeList(entries []string) (*ExcludeList, error) {
+	ex := &ExcludeList{names: make(map[string]bool)}
+
+	for _, e := range entries {
+		e = strings.TrimSpace(e)
+		if e == "" {
+			continue
+		}
+
+		switch {
+		case strings.Contains(e, "/"):
+			_, ipnet, err := net.ParseCIDR(e)
+			if err != nil {
+				return nil, fmt.Errorf("invalid exclusion %q: %w", e, err)
+			}
+			ex.nets = append(ex.nets, ipnet)
+		case net.ParseIP(strings.Trim(e, "[]")) != nil:
+			ip := net.ParseIP(strings.Trim(e, "[]"))
+			bits := 8 * len(ip)
+			if ip4 := ip.To4(); ip4 != nil {
+				ip, bits = ip4, 32
+			}
+			ex.nets = append(ex.nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+		default:
+			ex.names[strings.ToLower(e)] = true
+		}
+		ex.entries = append(ex.entries, e)
+	}
+
+	return ex, nil
+}
+
+// ReadExcludeFile reads exclusions from a file, one or more per line
+// separated by commas. Blank lines and # comments are ignored.
+func ReadExcludeFile(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var entries []string
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		line, _, _ := strings.Cut(sc.Text(), "#")
+		for _, e := range strings.Split(line, ",") {
+			if e = strings.TrimSpace(e); e != "" {
+				entries = append(entries, e)
+			}
+		}
+	}
+	return entries, sc.Err()
+}
+
+// Entries returns the exclusions as given, e.g. for saving in a checkpoint
+func (ex *ExcludeList) Entries() []string {
+	if ex == nil {
+		return nil
+	}
+	return ex.entries
+}
+
+// Len reports how many exclusions the list holds
+func (ex *ExcludeList) Len() int {
+	if ex == nil {
+		return 0
+	}
+	return len(ex.entries)
+}
+
+// Contains reports whether host is excluded. Hostnames match by name,
+// and also by address if they resolve into an excluded network.
+func (ex *ExcludeList) Contains(host string) bool {
+	excluded, _, _ := ex.vet(context.Background(), host, "tcp")
+	return excluded
+}
+
+// vet reports whether host is excluded and, for a hostname that had to
+// be resolved to tell, the address to dial in its place: the first of
+// network's family. Every address the name has is checked, so a name
+// with any foot in an excluded range is dropped.
+func (ex *ExcludeList) vet(ctx context.Context, host, network string) (excluded bool, addr string, err error) {
+	if ex.Len() == 0 {
+		return false, "", nil
+	}
+	if ex.names[strings.ToLower(host)] {
+		return true, "", nil
+	}
+
+	if ip := net.ParseIP(host); ip != nil {
+		return ex.containsIP(ip), "", nil
+	}
+	if len(ex.nets) == 0 {
+		return false, "", nil
+	}
+
+	// Never touch a name that points into an excluded range
+	addrs, err := net.DefaultResolver.LookupIP(ctx, lookupNetwork(network), host)
+	if err != nil {
+		return false, "", err
+	}
+	for _, ip := range addrs {
+		if ex.containsIP(ip) {
+			return true, "", nil
+		}
+	}
+	return false, addrs[0].String(), nil
+}
+
+func (ex *ExcludeList) containsIP(ip net.IP) bool {
+	for _, n := range ex.nets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// lookupNetwork maps a dial network to the family names resolve in
+func lookupNetwork(network string) string {
+	switch network {
+	case "tcp4", "udp4", "ip4":
+		return "ip4"
+	case "tcp6", "udp6", "ip6":
+		return "ip6"
+	}
+	return "ip"
+}
+
+// ExpandTargetsExcluding is ExpandTargets with ex applied as hosts are
+// expanded, so excluded hosts never reach the scanner. It also returns
+// the hosts that were dropped.
+//
+// A hostname that has to be resolved to be checked against excluded
+// networks is replaced by the address it was vetted with, looked up in
+// network's family. Dialing the name again could resolve somewhere else
+// the second time.
+func ExpandTargetsExcluding(spec string, ex *ExcludeList, network string) (hosts, excluded []string, err error) {
+	all, err := ExpandTargets(spec)
+	if err != nil {
+		return nil, nil, err
+	}
+	if ex.Len() == 0 {
+		return all, nil, nil
+	}
+
+	for _, h := range all {
+		drop, addr, err := ex.vet(context.Background(), h, network)
+		switch {
+		case err != nil:
+			return nil, nil, fmt.Errorf("checking %s against exclusions: %w", h, err)
+		case drop:
+			excluded = append(excluded, h)
+		case addr != "":
+			hosts = append(hosts, addr)
+		default:
+			hosts = append(hosts, h)
+		}
+	}
+
+	if len(hosts) == 0 {
+		return nil, excluded, fmt.Errorf("all %d targets in %q are excluded", len(all), spec)
+	}
+	return hosts, excluded, nil
+}
@@ -0,0 +1,9 @@
+//go:build !unix
+
+package scanner
+
+// fdLimit is unknown on platforms without RLIMIT_NOFILE (e.g. Windows);
+// the adaptive throttle still backs off if sockets run out
+func fdLimit() (uint64, bool) {
+	return 0, false
+}
@@ -0,0 +1,15 @@
+//go:build unix
+
+package scanner
+
+import "syscall"
+
+// fdLimit returns the soft RLIMIT_NOFILE, the number of file
+// descriptors (and therefore sockets) this process may hold open
+func fdLimit() (uint64, bool) {
+	var rl syscall.Rlimit
+	if err := syscall.Getrlimit(syscall.RLIMIT_NOFILE, &rl); err != nil {
+		return 0, false
+	}
+	return uint64(rl.Cur), true
+}
@@ -0,0 +1,316 @@
+// Package scanner implements a concurrent TCP connect scanner.
+//
+// It is the engine behind exercise 03 and can be embedded by other
+// exercises that need to probe ports:
+//
+//	results, err := scanner.Scan(ctx, scanner.Config{
+//		Hosts:     []string{"127.0.0.1"},
+//		StartPort: 1,
+//		EndPort:   1024,
+//	})
+//	for r := range results {
+//		if r.State == scanner.StateOpen {
+//			fmt.Println(r.Host, r.Port)
+//		}
+//	}
+package scanner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+// Port states reported for each scanned port
+const (
+	StateOpen     = "open"
+	StateClosed   = "closed"
+	StateFiltered = "filtered"
+	// StateError means the probe kept failing for local reasons (e.g.
+	// out of file descriptors), so nothing is known about the port
+	StateError = "error"
+)
+
+// Defaults applied to zero Config fields
+const (
+	DefaultTimeout       = 500 * time.Millisecond
+	DefaultBannerTimeout = 500 * time.Millisecond
+	DefaultWorkers       = 100
+)
+
+// maxBanner is how much of a greeting is kept
+const maxBanner = 256
+
+// Result holds the result of scanning a port
+type Result struct {
+	Host      string
+	Hostname  string // PTR name, filled in by callers that resolve it
+	Port      int
+	State     string
+	Service   string
+	Banner    string
+	Latency   time.Duration
+	Timestamp time.Time
+}
+
+// Config describes what to scan and how
+type Config struct {
+	Hosts     []string
+	StartPort int
+	EndPort   int
+	// Ports, if set, is scanned instead of StartPort-EndPort, e.g. the
+	// list from ServiceDB.TopPorts
+	Ports []int
+
+	// Network is "tcp", "tcp4" or "tcp6". Defaults to "tcp".
+	Network string
+	// Timeout bounds each connection attempt
+	Timeout time.Duration
+	// BannerTimeout is how long an open port gets to send a greeting
+	// (SSH, SMTP, FTP...) before it's closed. Negative skips banners.
+	BannerTimeout time.Duration
+	// Workers is the number of concurrent probes
+	Workers int
+	// Dialer opens connections. Defaults to a direct net.Dialer.
+	Dialer Dialer
+	// Services names the ports in results. Defaults to DefaultServices.
+	Services *ServiceDB
+	// Skip, if set, is consulted before each probe; returning true
+	// leaves that host/port out (e.g. already done in a resumed scan)
+	Skip func(host string, port int) bool
+	// Logf, if set, receives notes about concurrency adjustments
+	Logf func(format string, args ...any)
+}
+
+func (c *Config) validate() error {
+	if len(c.Hosts) == 0 {
+		return errors.New("scanner: no hosts to scan")
+	}
+	if len(c.Ports) > 0 {
+		for _, p := range c.Ports {
+			if p < 1 || p > 65535 {
+				return fmt.Errorf("scanner: invalid port %d", p)
+			}
+		}
+	} else if c.StartPort < 1 || c.EndPort > 65535 || c.StartPort > c.EndPort {
+		return fmt.Errorf("scanner: invalid port range %d-%d", c.StartPort, c.EndPort)
+	}
+
+	if c.Network == "" {
+		c.Network = "tcp"
+	}
+	if c.Timeout <= 0 {
+		c.Timeout = DefaultTimeout
+	}
+	if c.BannerTimeout == 0 {
+		c.BannerTimeout = DefaultBannerTimeout
+	}
+	if c.Workers <= 0 {
+		c.Workers = DefaultWorkers
+	}
+	if c.Dialer == nil {
+		c.Dialer = &net.Dialer{}
+	}
+	if c.Logf == nil {
+		c.Logf = func(string, ...any) {}
+	}
+
+	// Each in-flight probe holds a socket, so more workers than file
+	// descriptors would only produce "too many open files" errors
+	workers, limit, capped := capWorkers(c.Workers)
+	if capped {
+		c.Logf("⚠️  RLIMIT_NOFILE is %d; capping workers at %d (raise with ulimit -n)", limit, workers)
+	}
+	c.Workers = workers
+	return nil
+}
+
+// target is a single host/port pair handed to a worker
+type target struct {
+	host string
+	port int
+}
+
+// Scan probes every host/port pair in cfg using a pool of workers and
+// streams one Result per probe. The channel is closed when the scan
+// finishes or ctx is cancelled; callers must drain it.
+func Scan(ctx context.Context, cfg Config) (<-chan Result, error) {
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+
+	// Channel for work to scan
+	targets := make(chan target, 100)
+
+	// Channel for results
+	results := make(chan Result, 100)
+
+	// WaitGroup for workers
+	var wg sync.WaitGroup
+
+	// The throttle lowers concurrency below cfg.Workers if we start
+	// running out of sockets, and raises it again once things recover
+	thr := newThrottle(cfg.Workers, cfg.Logf)
+	cfg.Logf("   Effective concurrency: %d workers", cfg.Workers)
+
+	// Start worker pool
+	for i := 0; i < cfg.Workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for t := range targets {
+				r := probeWithRetry(ctx, thr, cfg, t)
+				if ctx.Err() != nil {
+					// A probe cut short by cancellation says nothing
+					// about the port, so don't report it
+					continue
+				}
+				results <- r
+			}
+		}()
+	}
+
+	ports := cfg.Ports
+	if len(ports) == 0 {
+		for port := cfg.StartPort; port <= cfg.EndPort; port++ {
+			ports = append(ports, port)
+		}
+	}
+
+	// Send work to workers
+	go func() {
+		defer close(targets)
+		for _, host := range cfg.Hosts {
+			for _, port := range ports {
+				if cfg.Skip != nil && cfg.Skip(host, port) {
+					continue
+				}
+				select {
+				case targets <- target{host: host, port: port}:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+
+	// Wait for workers and close results
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	return results, nil
+}
+
+// probeWithRetry probes t, retrying with less concurrency while the
+// failure is a local resource error rather than an answer from the target
+func probeWithRetry(ctx context.Context, thr *throttle, cfg Config, t target) Result {
+	var r Result
+	for attempt := 1; attempt <= maxAttempts; attempt++ {
+		thr.Acquire()
+		var err error
+		r, err = probe(ctx, cfg.Dialer, cfg.Network, t.host, t.port, cfg.Timeout, cfg.BannerTimeout)
+		r.Service = cfg.Services.Name(t.port, "tcp")
+		thr.Release(err == nil || !isResourceError(err))
+
+		if err == nil || !isResourceError(err) || ctx.Err() != nil {
+			return r
+		}
+		thr.Backoff(err)
+
+		// Give the kernel a moment to reclaim sockets before retrying
+		select {
+		case <-time.After(time.Duration(attempt) * 50 * time.Millisecond):
+		case <-ctx.Done():
+			return r
+		}
+	}
+
+	r.State = StateError
+	return r
+}
+
+// Probe makes a single TCP connect attempt to host:port, without waiting
+// for a banner
+func Probe(ctx context.Context, dialer Dialer, network, host string, port int, timeout time.Duration) Result {
+	r, _ := probe(ctx, dialer, network, host, port, timeout, -1)
+	return r
+}
+
+// probe is Probe, reading a banner for up to bannerTimeout if that's
+// positive, and also returns the dial error for the retry logic
+func probe(ctx context.Context, dialer Dialer, network, host string, port int, timeout, bannerTimeout time.Duration) (Result, error) {
+	// JoinHostPort adds the brackets IPv6 literals need: [::1]:80
+	address := net.JoinHostPort(host, strconv.Itoa(port))
+
+	result := Result{
+		Host:      host,
+		Port:      port,
+		Service:   ServiceName(port),
+		Timestamp: time.Now(),
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	conn, err := dialer.DialContext(ctx, network, address)
+	result.Latency = time.Since(result.Timestamp)
+	if err != nil {
+		result.State = classifyDialError(err)
+		return result, err
+	}
+	defer conn.Close()
+
+	result.State = StateOpen
+	if bannerTimeout > 0 {
+		result.Banner = readBanner(conn, bannerTimeout)
+	}
+	return result, nil
+}
+
+// readBanner returns what conn sends unprompted within timeout, up to
+// maxBanner bytes, as one line of printable text. It's empty for
+// services that wait for the client to speak first, like HTTP.
+func readBanner(conn net.Conn, timeout time.Duration) string {
+	if err := conn.SetReadDeadline(time.Now().Add(timeout)); err != nil {
+		return ""
+	}
+	buf := make([]byte, maxBanner)
+	n, _ := conn.Read(buf)
+	banner := strings.ToValidUTF8(string(buf[:n]), "")
+	banner = strings.Map(func(r rune) rune {
+		if !unicode.IsPrint(r) && !unicode.IsSpace(r) {
+			return '.'
+		}
+		return r
+	}, banner)
+	return strings.Join(strings.Fields(banner), " ")
+}
+
+// classifyDialError maps a failed dial to a port state.
+// A timeout usually means a firewall dropped the SYN, while a
+// refused or reset connection means the host answered with a RST.
+func classifyDialError(err error) string {
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return StateFiltered
+	}
+
+	// Through a proxy we only know what the proxy tells us. SOCKS has a
+	// "connection refused" reply; anything else could be either state.
+	var proxyErr *ProxyError
+	if errors.As(err, &proxyErr) {
+		if strings.Contains(proxyErr.Error(), "connection refused") {
+			return StateClosed
+		}
+		return StateFiltered
+	}
+	return StateClosed
+}
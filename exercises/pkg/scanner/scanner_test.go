@@ -0,0 +1,317 @@
+package scanner
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// listen opens a local TCP listener that accepts and closes connections
+func listen(t *testing.T) (net.Listener, int) {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+
+	return ln, ln.Addr().(*net.TCPAddr).Port
+}
+
+// closedPort returns a port that nothing is listening on
+func closedPort(t *testing.T) int {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	port := ln.Addr().(*net.TCPAddr).Port
+	ln.Close()
+	return port
+}
+
+func collect(t *testing.T, ctx context.Context, cfg Config) map[int]Result {
+	t.Helper()
+
+	results, err := Scan(ctx, cfg)
+	if err != nil {
+		t.Fatalf("Scan: %v", err)
+	}
+
+	byPort := make(map[int]Result)
+	for r := range results {
+		byPort[r.Port] = r
+	}
+	return byPort
+}
+
+func TestScanFindsOpenPort(t *testing.T) {
+	_, port := listen(t)
+
+	got := collect(t, context.Background(), Config{
+		Hosts:     []string{"127.0.0.1"},
+		StartPort: port,
+		EndPort:   port,
+	})
+
+	r, ok := got[port]
+	if !ok {
+		t.Fatalf("no result for port %d", port)
+	}
+	if r.State != StateOpen {
+		t.Errorf("state = %q, want %q", r.State, StateOpen)
+	}
+	if r.Host != "127.0.0.1" {
+		t.Errorf("host = %q, want 127.0.0.1", r.Host)
+	}
+	if r.Timestamp.IsZero() {
+		t.Error("timestamp not set")
+	}
+}
+
+func TestScanReportsClosedPort(t *testing.T) {
+	port := closedPort(t)
+
+	got := collect(t, context.Background(), Config{
+		Hosts:     []string{"127.0.0.1"},
+		StartPort: port,
+		EndPort:   port,
+	})
+
+	if r := got[port]; r.State != StateClosed {
+		t.Errorf("state = %q, want %q", r.State, StateClosed)
+	}
+}
+
+func TestScanEmitsOneResultPerProbe(t *testing.T) {
+	_, open := listen(t)
+	start := open - 5
+	if start < 1 {
+		start = 1
+	}
+	end := start + 10
+
+	got := collect(t, context.Background(), Config{
+		Hosts:     []string{"127.0.0.1"},
+		StartPort: start,
+		EndPort:   end,
+		Workers:   3,
+	})
+
+	if len(got) != end-start+1 {
+		t.Errorf("got %d results, want %d", len(got), end-start+1)
+	}
+	if got[open].State != StateOpen {
+		t.Errorf("port %d state = %q, want open", open, got[open].State)
+	}
+}
+
+func TestScanSkip(t *testing.T) {
+	_, port := listen(t)
+
+	got := collect(t, context.Background(), Config{
+		Hosts:     []string{"127.0.0.1"},
+		StartPort: port,
+		EndPort:   port + 1,
+		Skip:      func(host string, p int) bool { return p == port },
+	})
+
+	if _, ok := got[port]; ok {
+		t.Errorf("port %d was scanned despite Skip", port)
+	}
+	if _, ok := got[port+1]; !ok {
+		t.Errorf("port %d was not scanned", port+1)
+	}
+}
+
+func TestScanPortList(t *testing.T) {
+	_, open := listen(t)
+	closed := closedPort(t)
+
+	db, err := ParseServices(strings.NewReader(fmt.Sprintf("test-svc\t%d/tcp\t0.5\n", open)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := collect(t, context.Background(), Config{
+		Hosts:    []string{"127.0.0.1"},
+		Ports:    []int{open, closed},
+		Services: db,
+	})
+
+	if len(got) != 2 {
+		t.Fatalf("got %d results, want 2 (one per listed port)", len(got))
+	}
+	if r := got[open]; r.State != StateOpen || r.Service != "test-svc" {
+		t.Errorf("port %d: state %s service %q, want open test-svc", open, r.State, r.Service)
+	}
+	if r := got[closed]; r.Service != "unknown" {
+		t.Errorf("port %d: service %q, want unknown", closed, r.Service)
+	}
+}
+
+// greeter opens a local TCP listener that writes greeting to each
+// connection and then waits for the client to hang up
+func greeter(t *testing.T, greeting string) int {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				conn.Write([]byte(greeting))
+				conn.Read(make([]byte, 1))
+			}()
+		}
+	}()
+	return ln.Addr().(*net.TCPAddr).Port
+}
+
+func TestScanReadsBanner(t *testing.T) {
+	tests := []struct {
+		name, greeting, want string
+		bannerTimeout        time.Duration
+	}{
+		{"ssh", "SSH-2.0-OpenSSH_9.6\r\n", "SSH-2.0-OpenSSH_9.6", 0},
+		{"multi-line", "220-mail.example.com\r\n220 ready\r\n", "220-mail.example.com 220 ready", 0},
+		{"control bytes", "hi\x00\x01there\n", "hi..there", 0},
+		{"silent", "", "", 50 * time.Millisecond},
+		{"skipped", "SSH-2.0-OpenSSH_9.6\r\n", "", -1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			port := greeter(t, tt.greeting)
+			got := collect(t, context.Background(), Config{
+				Hosts:         []string{"127.0.0.1"},
+				Ports:         []int{port},
+				BannerTimeout: tt.bannerTimeout,
+			})
+			if r := got[port]; r.State != StateOpen || r.Banner != tt.want {
+				t.Errorf("got %s with banner %q, want open with %q", r.State, r.Banner, tt.want)
+			}
+		})
+	}
+}
+
+func TestScanBannerIsCapped(t *testing.T) {
+	port := greeter(t, strings.Repeat("x", 2*maxBanner))
+	got := collect(t, context.Background(), Config{
+		Hosts: []string{"127.0.0.1"},
+		Ports: []int{port},
+	})
+	if n := len(got[port].Banner); n != maxBanner {
+		t.Errorf("banner is %d bytes, want %d", n, maxBanner)
+	}
+}
+
+func TestScanStopsOnCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	results, err := Scan(ctx, Config{
+		Hosts:     []string{"127.0.0.1"},
+		StartPort: 1,
+		EndPort:   65535,
+	})
+	if err != nil {
+		t.Fatalf("Scan: %v", err)
+	}
+
+	done := make(chan int)
+	go func() {
+		n := 0
+		for range results {
+			n++
+		}
+		done <- n
+	}()
+
+	select {
+	case n := <-done:
+		if n == 65535 {
+			t.Error("cancelled scan still probed every port")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("results channel not closed after cancel")
+	}
+}
+
+func TestScanRejectsBadConfig(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  Config
+	}{
+		{"no hosts", Config{StartPort: 1, EndPort: 10}},
+		{"reversed range", Config{Hosts: []string{"127.0.0.1"}, StartPort: 10, EndPort: 1}},
+		{"port zero", Config{Hosts: []string{"127.0.0.1"}, StartPort: 0, EndPort: 10}},
+		{"port too high", Config{Hosts: []string{"127.0.0.1"}, StartPort: 1, EndPort: 70000}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Scan(context.Background(), tt.cfg); err == nil {
+				t.Error("expected error")
+			}
+		})
+	}
+}
+
+func TestProbeUsesDialer(t *testing.T) {
+	d := &recordingDialer{}
+	Probe(context.Background(), d, "tcp6", "::1", 8080, time.Second)
+
+	if d.network != "tcp6" {
+		t.Errorf("network = %q, want tcp6", d.network)
+	}
+	if d.address != "[::1]:8080" {
+		t.Errorf("address = %q, want [::1]:8080", d.address)
+	}
+}
+
+type recordingDialer struct {
+	network, address string
+}
+
+func (d *recordingDialer) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	d.network, d.address = network, address
+	return nil, &net.OpError{Op: "dial", Err: errRefused}
+}
+
+var errRefused = &net.AddrError{Err: "connection refused"}
+
+func TestClassifyProxyErrors(t *testing.T) {
+	refused := &ProxyError{Err: errRefused}
+	if got := classifyDialError(refused); got != StateClosed {
+		t.Errorf("refused via proxy = %q, want closed", got)
+	}
+
+	unreachable := &ProxyError{Err: &net.AddrError{Err: "host unreachable"}}
+	if got := classifyDialError(unreachable); got != StateFiltered {
+		t.Errorf("unreachable via proxy = %q, want filtered", got)
+	}
+}
@@ -0,0 +1,178 @@
+package scanner
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Service is one entry of a services database
+type Service struct {
+	Name      string
+	Port      int
+	Protocol  string  // "tcp", "udp" or "sctp"
+	Frequency float64 // how often the port is found open, 0 if unknown
+}
+
+// ServiceDB maps ports to service names and ranks ports by how often
+// they are open. Load nmap's nmap-services with LoadServices, or use
+// DefaultServices for a small built-in table.
+type ServiceDB struct {
+	byPort map[serviceKey]Service
+	ranked map[string][]Service // per protocol, most frequent first
+}
+
+type serviceKey struct {
+	port     int
+	protocol string
+}
+
+// DefaultServices covers common ports. Frequencies are rounded from
+// nmap-services so TopPorts gives a sensible order without a file.
+var DefaultServices = newServiceDB([]Service{
+	{"HTTP", 80, "tcp", 0.484},
+	{"Telnet", 23, "tcp", 0.221},
+	{"HTTPS", 443, "tcp", 0.209},
+	{"FTP", 21, "tcp", 0.198},
+	{"SSH", 22, "tcp", 0.182},
+	{"SMTP", 25, "tcp", 0.131},
+	{"RDP", 3389, "tcp", 0.084},
+	{"POP3", 110, "tcp", 0.077},
+	{"SMB", 445, "tcp", 0.057},
+	{"IMAP", 143, "tcp", 0.050},
+	{"DNS", 53, "tcp", 0.048},
+	{"MySQL", 3306, "tcp", 0.045},
+	{"HTTP-Alt", 8080, "tcp", 0.042},
+	{"POP3S", 995, "tcp", 0.030},
+	{"IMAPS", 993, "tcp", 0.027},
+	{"HTTPS-Alt", 8443, "tcp", 0.010},
+	{"PostgreSQL", 5432, "tcp", 0.004},
+	{"Redis", 6379, "tcp", 0.001},
+	{"MongoDB", 27017, "tcp", 0.001},
+})
+
+func newServiceDB(services []Service) *ServiceDB {
+	db := &ServiceDB{
+		byPort: make(map[serviceKey]Service),
+		ranked: make(map[string][]Service),
+	}
+	for _, s := range services {
+		key := serviceKey{port: s.Port, protocol: s.Protocol}
+		// Keep the first entry for a port, as /etc/services readers do
+		if _, dup := db.byPort[key]; dup {
+			continue
+		}
+		db.byPort[key] = s
+		db.ranked[s.Protocol] = append(db.ranked[s.Protocol], s)
+	}
+
+	for _, list := range db.ranked {
+		sort.SliceStable(list, func(i, j int) bool {
+			if list[i].Frequency != list[j].Frequency {
+				return list[i].Frequency > list[j].Frequency
+			}
+			return list[i].Port < list[j].Port
+		})
+	}
+	return db
+}
+
+// LoadServices reads an nmap-services style file. /etc/services works
+// too; its entries simply have no frequency.
+func LoadServices(path string) (*ServiceDB, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	db, err := ParseServices(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return db, nil
+}
+
+// ParseServices parses lines of the form
+//
+//	http	80/tcp	0.484143	# World Wide Web HTTP
+//
+// where the frequency column is optional
+func ParseServices(r io.Reader) (*ServiceDB, error) {
+	var services []Service
+
+	sc := bufio.NewScanner(r)
+	lineNo := 0
+	for sc.Scan() {
+		lineNo++
+		line, _, _ := strings.Cut(sc.Text(), "#")
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+
+		portStr, proto, ok := strings.Cut(fields[1], "/")
+		port, err := strconv.Atoi(portStr)
+		if !ok || err != nil || port < 0 || port > 65535 {
+			return nil, fmt.Errorf("line %d: invalid port/protocol %q", lineNo, fields[1])
+		}
+
+		s := Service{Name: fields[0], Port: port, Protocol: strings.ToLower(proto)}
+		if len(fields) > 2 {
+			// /etc/services puts aliases here instead; those aren't numbers
+			if freq, err := strconv.ParseFloat(fields[2], 64); err == nil {
+				s.Frequency = freq
+			}
+		}
+		services = append(services, s)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	if len(services) == 0 {
+		return nil, fmt.Errorf("no services found")
+	}
+
+	return newServiceDB(services), nil
+}
+
+// Name returns the service name for port/protocol, or "unknown". A nil
+// db uses DefaultServices.
+func (db *ServiceDB) Name(port int, protocol string) string {
+	if db == nil {
+		db = DefaultServices
+	}
+	if s, ok := db.byPort[serviceKey{port: port, protocol: protocol}]; ok {
+		return s.Name
+	}
+	return "unknown"
+}
+
+// TopPorts returns up to n ports for protocol, most frequently open
+// first. It returns fewer if the database doesn't know n ports.
+func (db *ServiceDB) TopPorts(n int, protocol string) []int {
+	if db == nil {
+		db = DefaultServices
+	}
+	ranked := db.ranked[protocol]
+	if n > len(ranked) {
+		n = len(ranked)
+	}
+
+	ports := make([]int, 0, n)
+	for _, s := range ranked[:n] {
+		if s.Port > 0 {
+			ports = append(ports, s.Port)
+		}
+	}
+	return ports
+}
+
+// ServiceName returns the DefaultServices name for a TCP port
+func ServiceName(port int) string {
+	return DefaultServices.Name(port, "tcp")
+}
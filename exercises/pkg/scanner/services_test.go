@@ -0,0 +1,71 @@
+package scanner
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const sampleServices = `# Fields in this file are: Service name, portnum/protocol, open-frequency, optional comments
+#
+tcpmux	1/tcp	0.001995	# TCP Port Service Multiplexer [rfc-1078]
+ftp	21/tcp	0.197667	# File Transfer [Control]
+ssh	22/tcp	0.182286	# Secure Shell Login
+domain	53/udp	0.213496	# Domain Name Server
+http	80/tcp	0.484143	# World Wide Web HTTP
+unknown	1000/tcp	0.000000
+https	443/tcp	0.208669	# secure http (SSL)
+`
+
+func TestParseServices(t *testing.T) {
+	db, err := ParseServices(strings.NewReader(sampleServices))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := db.Name(80, "tcp"); got != "http" {
+		t.Errorf("Name(80/tcp) = %q, want http", got)
+	}
+	if got := db.Name(53, "udp"); got != "domain" {
+		t.Errorf("Name(53/udp) = %q, want domain", got)
+	}
+	if got := db.Name(53, "tcp"); got != "unknown" {
+		t.Errorf("Name(53/tcp) = %q, want unknown (only udp is listed)", got)
+	}
+
+	if got, want := db.TopPorts(3, "tcp"), []int{80, 443, 21}; !reflect.DeepEqual(got, want) {
+		t.Errorf("TopPorts(3) = %v, want %v", got, want)
+	}
+	if got := db.TopPorts(100, "tcp"); len(got) != 6 {
+		t.Errorf("TopPorts(100) returned %d ports, want all 6 tcp entries", len(got))
+	}
+}
+
+func TestParseServicesEtcFormat(t *testing.T) {
+	// /etc/services has aliases where nmap-services has frequencies
+	db, err := ParseServices(strings.NewReader("ssh\t\t22/tcp\t\t\t\t# SSH Remote Login Protocol\nsmtp\t\t25/tcp\t\tmail\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := db.Name(25, "tcp"); got != "smtp" {
+		t.Errorf("Name(25/tcp) = %q, want smtp", got)
+	}
+}
+
+func TestParseServicesErrors(t *testing.T) {
+	if _, err := ParseServices(strings.NewReader("http\teighty/tcp\n")); err == nil {
+		t.Error("expected an error for a non-numeric port")
+	}
+	if _, err := ParseServices(strings.NewReader("# only comments\n")); err == nil {
+		t.Error("expected an error for a file with no services")
+	}
+}
+
+func TestDefaultServices(t *testing.T) {
+	if got := ServiceName(22); got != "SSH" {
+		t.Errorf("ServiceName(22) = %q, want SSH", got)
+	}
+	if got := DefaultServices.TopPorts(3, "tcp"); !reflect.DeepEqual(got, []int{80, 23, 443}) {
+		t.Errorf("DefaultServices.TopPorts(3) = %v", got)
+	}
+}
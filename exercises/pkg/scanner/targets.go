@@ -0,0 +1,106 @@
+package scanner
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// MaxTargets caps CIDR expansion so a typo like /8 doesn't queue
+// millions of hosts by accident
+const MaxTargets = 65536
+
+// ExpandTargets turns a comma-separated list of hosts and CIDR blocks
+// into the individual hosts to scan
+func ExpandTargets(spec string) ([]string, error) {
+	var hosts []string
+
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		if !strings.Contains(part, "/") {
+			// Accept bracketed IPv6 literals like [::1] as well
+			hosts = append(hosts, strings.Trim(part, "[]"))
+			continue
+		}
+
+		expanded, err := expandCIDR(part)
+		if err != nil {
+			return nil, err
+		}
+		hosts = append(hosts, expanded...)
+
+		if len(hosts) > MaxTargets {
+			return nil, fmt.Errorf("target list exceeds %d hosts", MaxTargets)
+		}
+	}
+
+	if len(hosts) == 0 {
+		return nil, fmt.Errorf("no targets in %q", spec)
+	}
+	return hosts, nil
+}
+
+func expandCIDR(cidr string) ([]string, error) {
+	ip, ipnet, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid CIDR %q: %w", cidr, err)
+	}
+
+	ones, bits := ipnet.Mask.Size()
+	if bits-ones > 16 {
+		if bits == 128 {
+			// A single /64 holds 2^64 addresses; sweeping it would take
+			// longer than the age of the universe, so refuse politely
+			return nil, fmt.Errorf("IPv6 range %s has 2^%d addresses and cannot be swept; "+
+				"scan known addresses or a /112 or smaller instead", cidr, bits-ones)
+		}
+		return nil, fmt.Errorf("CIDR %s is too large (max /%d)", cidr, bits-16)
+	}
+
+	var hosts []string
+	for cur := ip.Mask(ipnet.Mask); ipnet.Contains(cur); cur = nextIP(cur) {
+		hosts = append(hosts, cur.String())
+	}
+
+	// Drop the network and broadcast addresses of IPv4 subnets
+	if bits == 32 && ones < 31 && len(hosts) > 2 {
+		hosts = hosts[1 : len(hosts)-1]
+	}
+	return hosts, nil
+}
+
+// nextIP returns a copy of ip incremented by one
+func nextIP(ip net.IP) net.IP {
+	next := make(net.IP, len(ip))
+	copy(next, ip)
+	for i := len(next) - 1; i >= 0; i-- {
+		next[i]++
+		if next[i] != 0 {
+			break
+		}
+	}
+	return next
+}
+
+// CheckFamily rejects IP literals that don't match network ("tcp4" or
+// "tcp6"). Hostnames are left alone; the dialer resolves A or AAAA as needed.
+func CheckFamily(hosts []string, network string) error {
+	for _, host := range hosts {
+		ip := net.ParseIP(host)
+		if ip == nil {
+			continue
+		}
+		isV4 := ip.To4() != nil
+		if network == "tcp4" && !isV4 {
+			return fmt.Errorf("%s is an IPv6 address but -4 was given", host)
+		}
+		if network == "tcp6" && isV4 {
+			return fmt.Errorf("%s is an IPv4 address but -6 was given", host)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,114 @@
+package scanner
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestExpandTargets(t *testing.T) {
+	tests := []struct {
+		spec string
+		want []string
+	}{
+		{"localhost", []string{"localhost"}},
+		{"10.0.0.1, 10.0.0.2", []string{"10.0.0.1", "10.0.0.2"}},
+		{"[::1]", []string{"::1"}},
+		{"192.168.1.0/30", []string{"192.168.1.1", "192.168.1.2"}},
+		{"192.168.1.5/32", []string{"192.168.1.5"}},
+		{"10.0.0.0/31", []string{"10.0.0.0", "10.0.0.1"}},
+		{"2001:db8::/126", []string{"2001:db8::", "2001:db8::1", "2001:db8::2", "2001:db8::3"}},
+	}
+
+	for _, tt := range tests {
+		got, err := ExpandTargets(tt.spec)
+		if err != nil {
+			t.Errorf("ExpandTargets(%q): %v", tt.spec, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ExpandTargets(%q) = %v, want %v", tt.spec, got, tt.want)
+		}
+	}
+}
+
+func TestExpandTargetsErrors(t *testing.T) {
+	tests := []struct {
+		spec    string
+		wantErr string
+	}{
+		{"", "no targets"},
+		{"10.0.0.0/33", "invalid CIDR"},
+		{"10.0.0.0/8", "too large"},
+		{"2001:db8::/64", "cannot be swept"},
+	}
+
+	for _, tt := range tests {
+		_, err := ExpandTargets(tt.spec)
+		if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("ExpandTargets(%q) error = %v, want %q", tt.spec, err, tt.wantErr)
+		}
+	}
+}
+
+func TestCheckFamily(t *testing.T) {
+	if err := CheckFamily([]string{"10.0.0.1", "example.com"}, "tcp4"); err != nil {
+		t.Errorf("IPv4 with tcp4: %v", err)
+	}
+	if err := CheckFamily([]string{"::1"}, "tcp4"); err == nil {
+		t.Error("IPv6 with tcp4: expected error")
+	}
+	if err := CheckFamily([]string{"10.0.0.1"}, "tcp6"); err == nil {
+		t.Error("IPv4 with tcp6: expected error")
+	}
+	if err := CheckFamily([]string{"10.0.0.1", "::1"}, "tcp"); err != nil {
+		t.Errorf("mixed with tcp: %v", err)
+	}
+}
+
+func TestExpandTargetsExcluding(t *testing.T) {
+	ex, err := NewExcludeList([]string{"192.168.1.2", "192.168.1.8/30", "Printer.LAN"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hosts, excluded, err := ExpandTargetsExcluding("192.168.1.0/28,printer.lan,localhost", ex, "tcp4")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wantHosts := []string{
+		"192.168.1.1", "192.168.1.3", "192.168.1.4", "192.168.1.5", "192.168.1.6",
+		"192.168.1.7", "192.168.1.12", "192.168.1.13", "192.168.1.14", "127.0.0.1",
+	}
+	wantExcluded := []string{"192.168.1.2", "192.168.1.8", "192.168.1.9", "192.168.1.10", "192.168.1.11", "printer.lan"}
+	if !reflect.DeepEqual(hosts, wantHosts) {
+		t.Errorf("hosts = %v, want %v", hosts, wantHosts)
+	}
+	if !reflect.DeepEqual(excluded, wantExcluded) {
+		t.Errorf("excluded = %v, want %v", excluded, wantExcluded)
+	}
+
+	if _, _, err := ExpandTargetsExcluding("192.168.1.2", ex, "tcp"); err == nil {
+		t.Error("expected an error when every target is excluded")
+	}
+	if _, _, err := ExpandTargetsExcluding("192.168.1.1,nowhere.invalid", ex, "tcp"); err == nil {
+		t.Error("expected an error for a name that can't be checked")
+	}
+}
+
+func TestExcludeListResolvesNames(t *testing.T) {
+	ex, err := NewExcludeList([]string{"127.0.0.0/8", "::1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ex.Contains("localhost") {
+		t.Error("localhost resolves into an excluded range and should be excluded")
+	}
+	if !ex.Contains("::1") {
+		t.Error("::1 should be excluded")
+	}
+	if _, err := NewExcludeList([]string{"10.0.0.0/40"}); err == nil {
+		t.Error("expected an error for an invalid CIDR")
+	}
+}
@@ -0,0 +1,111 @@
+package scanner
+
+import (
+	"errors"
+	"sync"
+	"syscall"
+)
+
+// fdReserve is how many descriptors we leave for stdio, DNS, log files
+// and anything else the process has open besides scan sockets
+const fdReserve = 64
+
+// maxAttempts bounds how often a probe is retried after local
+// resource errors before it is reported as StateError
+const maxAttempts = 8
+
+// isResourceError reports whether err came from running out of local
+// resources rather than from the target. Such failures say nothing
+// about the port, so reporting them as "closed" would be a lie. A reset
+// is not one of them: it was sent by the target or something on the way.
+func isResourceError(err error) bool {
+	return errors.Is(err, syscall.EMFILE) || // per-process fd limit
+		errors.Is(err, syscall.ENFILE) || // system-wide fd limit
+		errors.Is(err, syscall.ENOBUFS) || // kernel socket buffers exhausted
+		errors.Is(err, syscall.EADDRNOTAVAIL) // out of ephemeral ports
+}
+
+// capWorkers limits the worker count to what RLIMIT_NOFILE allows
+func capWorkers(workers int) (int, uint64, bool) {
+	limit, ok := fdLimit()
+	if !ok {
+		return workers, 0, false
+	}
+
+	max := int(limit) - fdReserve
+	if max < 1 {
+		max = 1
+	}
+	if workers > max {
+		return max, limit, true
+	}
+	return workers, limit, false
+}
+
+// throttle is a semaphore whose size adapts to errors using AIMD, the
+// same idea TCP congestion control uses: halve the limit when resources
+// run out, then grow it back by one after a full window of successes.
+type throttle struct {
+	mu       sync.Mutex
+	cond     *sync.Cond
+	limit    int
+	max      int
+	inFlight int
+	streak   int // successes since the last change
+	logf     func(format string, args ...any)
+}
+
+func newThrottle(max int, logf func(format string, args ...any)) *throttle {
+	t := &throttle{limit: max, max: max, logf: logf}
+	t.cond = sync.NewCond(&t.mu)
+	return t
+}
+
+// Acquire blocks until a probe slot is free
+func (t *throttle) Acquire() {
+	t.mu.Lock()
+	for t.inFlight >= t.limit {
+		t.cond.Wait()
+	}
+	t.inFlight++
+	t.mu.Unlock()
+}
+
+// Release frees a slot, growing the limit after a run of successes
+func (t *throttle) Release(ok bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.inFlight--
+	if ok {
+		t.streak++
+		if t.streak >= t.limit && t.limit < t.max {
+			t.limit++
+			t.streak = 0
+		}
+	}
+	t.cond.Broadcast()
+}
+
+// Backoff halves the concurrency limit after a resource error
+func (t *throttle) Backoff(err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.streak = 0
+	if t.limit == 1 {
+		return
+	}
+	t.limit /= 2
+	if t.limit < 1 {
+		t.limit = 1
+	}
+	t.logf("⚠️  %v: reducing concurrency to %d", err, t.limit)
+}
+
+// Limit returns the current concurrency limit
+func (t *throttle) Limit() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.limit
+}
@@ -0,0 +1,50 @@
+package scanner
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"syscall"
+	"testing"
+)
+
+func TestThrottleBacksOffAndRecovers(t *testing.T) {
+	thr := newThrottle(8, func(string, ...any) {})
+
+	thr.Backoff(syscall.EMFILE)
+	if got := thr.Limit(); got != 4 {
+		t.Fatalf("limit after one backoff = %d, want 4", got)
+	}
+
+	thr.Backoff(syscall.EMFILE)
+	thr.Backoff(syscall.EMFILE)
+	thr.Backoff(syscall.EMFILE)
+	if got := thr.Limit(); got != 1 {
+		t.Fatalf("limit never drops below 1, got %d", got)
+	}
+
+	// Additive increase: one step per full window of successes
+	for i := 0; i < 100; i++ {
+		thr.Acquire()
+		thr.Release(true)
+	}
+	if got := thr.Limit(); got != 8 {
+		t.Errorf("limit after recovery = %d, want 8", got)
+	}
+}
+
+func TestIsResourceError(t *testing.T) {
+	if !isResourceError(fmt.Errorf("dial: %w", syscall.EMFILE)) {
+		t.Error("EMFILE should be a resource error")
+	}
+	if isResourceError(fmt.Errorf("dial: %w", syscall.ECONNREFUSED)) {
+		t.Error("ECONNREFUSED is an answer from the target, not a resource error")
+	}
+	reset := &net.OpError{Op: "dial", Err: os.NewSyscallError("connect", syscall.ECONNRESET)}
+	if isResourceError(reset) {
+		t.Error("ECONNRESET is an answer from the target, not a resource error")
+	}
+	if got := classifyDialError(reset); got != StateClosed {
+		t.Errorf("reset during connect = %q, want closed", got)
+	}
+}
@@ -0,0 +1,715 @@
+// Remote-control API for the port scanner in pkg/scanner.
+//
+// Regenerate the Go code from the exercises/ directory with:
+//
+//   protoc --go_out=. --go_opt=paths=source_relative \
+//          --go-grpc_out=. --go-grpc_opt=paths=source_relative \
+//          pkg/scanrpc/scannerpb/scanner.proto
+
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.34.2
+// 	protoc        v5.28.3
+// source: pkg/scanrpc/scannerpb/scanner.proto
+
+package scannerpb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	durationpb "google.golang.org/protobuf/types/known/durationpb"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type ScanState int32
+
+const (
+	ScanState_SCAN_STATE_UNSPECIFIED ScanState = 0
+	ScanState_SCAN_STATE_RUNNING     ScanState = 1
+	ScanState_SCAN_STATE_DONE        ScanState = 2
+	ScanState_SCAN_STATE_CANCELLED   ScanState = 3
+)
+
+// Enum value maps for ScanState.
+var (
+	ScanState_name = map[int32]string{
+		0: "SCAN_STATE_UNSPECIFIED",
+		1: "SCAN_STATE_RUNNING",
+		2: "SCAN_STATE_DONE",
+		3: "SCAN_STATE_CANCELLED",
+	}
+	ScanState_value = map[string]int32{
+		"SCAN_STATE_UNSPECIFIED": 0,
+		"SCAN_STATE_RUNNING":     1,
+		"SCAN_STATE_DONE":        2,
+		"SCAN_STATE_CANCELLED":   3,
+	}
+)
+
+func (x ScanState) Enum() *ScanState {
+	p := new(ScanState)
+	*p = x
+	return p
+}
+
+func (x ScanState) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (ScanState) Descriptor() protoreflect.EnumDescriptor {
+	return file_pkg_scanrpc_scannerpb_scanner_proto_enumTypes[0].Descriptor()
+}
+
+func (ScanState) Type() protoreflect.EnumType {
+	return &file_pkg_scanrpc_scannerpb_scanner_proto_enumTypes[0]
+}
+
+func (x ScanState) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use ScanState.Descriptor instead.
+func (ScanState) EnumDescriptor() ([]byte, []int) {
+	return file_pkg_scanrpc_scannerpb_scanner_proto_rawDescGZIP(), []int{0}
+}
+
+type StartScanRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// Comma-separated hosts or CIDRs, e.g. "10.0.0.0/24,example.com"
+	Targets   string `protobuf:"bytes,1,opt,name=targets,proto3" json:"targets,omitempty"`
+	StartPort int32  `protobuf:"varint,2,opt,name=start_port,json=startPort,proto3" json:"start_port,omitempty"`
+	EndPort   int32  `protobuf:"varint,3,opt,name=end_port,json=endPort,proto3" json:"end_port,omitempty"`
+	// Per-probe timeout; defaults to the scanner's default if unset
+	Timeout *durationpb.Duration `protobuf:"bytes,4,opt,name=timeout,proto3" json:"timeout,omitempty"`
+	// Concurrent probes; defaults to the scanner's default if zero
+	Workers int32 `protobuf:"varint,5,opt,name=workers,proto3" json:"workers,omitempty"`
+	// "tcp", "tcp4" or "tcp6"; defaults to "tcp"
+	Network string `protobuf:"bytes,6,opt,name=network,proto3" json:"network,omitempty"`
+}
+
+func (x *StartScanRequest) Reset() {
+	*x = StartScanRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_pkg_scanrpc_scannerpb_scanner_proto_msgTypes[0]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *StartScanRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StartScanRequest) ProtoMessage() {}
+
+func (x *StartScanRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_scanrpc_scannerpb_scanner_proto_msgTypes[0]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StartScanRequest.ProtoReflect.Descriptor instead.
+func (*StartScanRequest) Descriptor() ([]byte, []int) {
+	return file_pkg_scanrpc_scannerpb_scanner_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *StartScanRequest) GetTargets() string {
+	if x != nil {
+		return x.Targets
+	}
+	return ""
+}
+
+func (x *StartScanRequest) GetStartPort() int32 {
+	if x != nil {
+		return x.StartPort
+	}
+	return 0
+}
+
+func (x *StartScanRequest) GetEndPort() int32 {
+	if x != nil {
+		return x.EndPort
+	}
+	return 0
+}
+
+func (x *StartScanRequest) GetTimeout() *durationpb.Duration {
+	if x != nil {
+		return x.Timeout
+	}
+	return nil
+}
+
+func (x *StartScanRequest) GetWorkers() int32 {
+	if x != nil {
+		return x.Workers
+	}
+	return 0
+}
+
+func (x *StartScanRequest) GetNetwork() string {
+	if x != nil {
+		return x.Network
+	}
+	return ""
+}
+
+type StartScanResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	ScanId      string `protobuf:"bytes,1,opt,name=scan_id,json=scanId,proto3" json:"scan_id,omitempty"`
+	Hosts       int32  `protobuf:"varint,2,opt,name=hosts,proto3" json:"hosts,omitempty"`
+	TotalProbes int32  `protobuf:"varint,3,opt,name=total_probes,json=totalProbes,proto3" json:"total_probes,omitempty"`
+}
+
+func (x *StartScanResponse) Reset() {
+	*x = StartScanResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_pkg_scanrpc_scannerpb_scanner_proto_msgTypes[1]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *StartScanResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StartScanResponse) ProtoMessage() {}
+
+func (x *StartScanResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_scanrpc_scannerpb_scanner_proto_msgTypes[1]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StartScanResponse.ProtoReflect.Descriptor instead.
+func (*StartScanResponse) Descriptor() ([]byte, []int) {
+	return file_pkg_scanrpc_scannerpb_scanner_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *StartScanResponse) GetScanId() string {
+	if x != nil {
+		return x.ScanId
+	}
+	return ""
+}
+
+func (x *StartScanResponse) GetHosts() int32 {
+	if x != nil {
+		return x.Hosts
+	}
+	return 0
+}
+
+func (x *StartScanResponse) GetTotalProbes() int32 {
+	if x != nil {
+		return x.TotalProbes
+	}
+	return 0
+}
+
+type ScanRef struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	ScanId string `protobuf:"bytes,1,opt,name=scan_id,json=scanId,proto3" json:"scan_id,omitempty"`
+}
+
+func (x *ScanRef) Reset() {
+	*x = ScanRef{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_pkg_scanrpc_scannerpb_scanner_proto_msgTypes[2]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ScanRef) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ScanRef) ProtoMessage() {}
+
+func (x *ScanRef) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_scanrpc_scannerpb_scanner_proto_msgTypes[2]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ScanRef.ProtoReflect.Descriptor instead.
+func (*ScanRef) Descriptor() ([]byte, []int) {
+	return file_pkg_scanrpc_scannerpb_scanner_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *ScanRef) GetScanId() string {
+	if x != nil {
+		return x.ScanId
+	}
+	return ""
+}
+
+type ScanStatus struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	ScanId      string                 `protobuf:"bytes,1,opt,name=scan_id,json=scanId,proto3" json:"scan_id,omitempty"`
+	State       ScanState              `protobuf:"varint,2,opt,name=state,proto3,enum=scanner.v1.ScanState" json:"state,omitempty"`
+	Targets     string                 `protobuf:"bytes,3,opt,name=targets,proto3" json:"targets,omitempty"`
+	ProbesDone  int32                  `protobuf:"varint,4,opt,name=probes_done,json=probesDone,proto3" json:"probes_done,omitempty"`
+	ProbesTotal int32                  `protobuf:"varint,5,opt,name=probes_total,json=probesTotal,proto3" json:"probes_total,omitempty"`
+	OpenPorts   int32                  `protobuf:"varint,6,opt,name=open_ports,json=openPorts,proto3" json:"open_ports,omitempty"`
+	StartedAt   *timestamppb.Timestamp `protobuf:"bytes,7,opt,name=started_at,json=startedAt,proto3" json:"started_at,omitempty"`
+	// Unset while the scan is running
+	FinishedAt *timestamppb.Timestamp `protobuf:"bytes,8,opt,name=finished_at,json=finishedAt,proto3" json:"finished_at,omitempty"`
+}
+
+func (x *ScanStatus) Reset() {
+	*x = ScanStatus{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_pkg_scanrpc_scannerpb_scanner_proto_msgTypes[3]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ScanStatus) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ScanStatus) ProtoMessage() {}
+
+func (x *ScanStatus) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_scanrpc_scannerpb_scanner_proto_msgTypes[3]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ScanStatus.ProtoReflect.Descriptor instead.
+func (*ScanStatus) Descriptor() ([]byte, []int) {
+	return file_pkg_scanrpc_scannerpb_scanner_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *ScanStatus) GetScanId() string {
+	if x != nil {
+		return x.ScanId
+	}
+	return ""
+}
+
+func (x *ScanStatus) GetState() ScanState {
+	if x != nil {
+		return x.State
+	}
+	return ScanState_SCAN_STATE_UNSPECIFIED
+}
+
+func (x *ScanStatus) GetTargets() string {
+	if x != nil {
+		return x.Targets
+	}
+	return ""
+}
+
+func (x *ScanStatus) GetProbesDone() int32 {
+	if x != nil {
+		return x.ProbesDone
+	}
+	return 0
+}
+
+func (x *ScanStatus) GetProbesTotal() int32 {
+	if x != nil {
+		return x.ProbesTotal
+	}
+	return 0
+}
+
+func (x *ScanStatus) GetOpenPorts() int32 {
+	if x != nil {
+		return x.OpenPorts
+	}
+	return 0
+}
+
+func (x *ScanStatus) GetStartedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.StartedAt
+	}
+	return nil
+}
+
+func (x *ScanStatus) GetFinishedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.FinishedAt
+	}
+	return nil
+}
+
+type PortResult struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Host      string                 `protobuf:"bytes,1,opt,name=host,proto3" json:"host,omitempty"`
+	Port      int32                  `protobuf:"varint,2,opt,name=port,proto3" json:"port,omitempty"`
+	State     string                 `protobuf:"bytes,3,opt,name=state,proto3" json:"state,omitempty"`
+	Service   string                 `protobuf:"bytes,4,opt,name=service,proto3" json:"service,omitempty"`
+	Banner    string                 `protobuf:"bytes,5,opt,name=banner,proto3" json:"banner,omitempty"`
+	Latency   *durationpb.Duration   `protobuf:"bytes,6,opt,name=latency,proto3" json:"latency,omitempty"`
+	Timestamp *timestamppb.Timestamp `protobuf:"bytes,7,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
+}
+
+func (x *PortResult) Reset() {
+	*x = PortResult{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_pkg_scanrpc_scannerpb_scanner_proto_msgTypes[4]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *PortResult) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PortResult) ProtoMessage() {}
+
+func (x *PortResult) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_scanrpc_scannerpb_scanner_proto_msgTypes[4]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PortResult.ProtoReflect.Descriptor instead.
+func (*PortResult) Descriptor() ([]byte, []int) {
+	return file_pkg_scanrpc_scannerpb_scanner_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *PortResult) GetHost() string {
+	if x != nil {
+		return x.Host
+	}
+	return ""
+}
+
+func (x *PortResult) GetPort() int32 {
+	if x != nil {
+		return x.Port
+	}
+	return 0
+}
+
+func (x *PortResult) GetState() string {
+	if x != nil {
+		return x.State
+	}
+	return ""
+}
+
+func (x *PortResult) GetService() string {
+	if x != nil {
+		return x.Service
+	}
+	return ""
+}
+
+func (x *PortResult) GetBanner() string {
+	if x != nil {
+		return x.Banner
+	}
+	return ""
+}
+
+func (x *PortResult) GetLatency() *durationpb.Duration {
+	if x != nil {
+		return x.Latency
+	}
+	return nil
+}
+
+func (x *PortResult) GetTimestamp() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Timestamp
+	}
+	return nil
+}
+
+var File_pkg_scanrpc_scannerpb_scanner_proto protoreflect.FileDescriptor
+
+var file_pkg_scanrpc_scannerpb_scanner_proto_rawDesc = []byte{
+	0x0a, 0x23, 0x70, 0x6b, 0x67, 0x2f, 0x73, 0x63, 0x61, 0x6e, 0x72, 0x70, 0x63, 0x2f, 0x73, 0x63,
+	0x61, 0x6e, 0x6e, 0x65, 0x72, 0x70, 0x62, 0x2f, 0x73, 0x63, 0x61, 0x6e, 0x6e, 0x65, 0x72, 0x2e,
+	0x70, 0x72, 0x6f, 0x74, 0x6f, 0x12, 0x0a, 0x73, 0x63, 0x61, 0x6e, 0x6e, 0x65, 0x72, 0x2e, 0x76,
+	0x31, 0x1a, 0x1e, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2f, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62,
+	0x75, 0x66, 0x2f, 0x64, 0x75, 0x72, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x2e, 0x70, 0x72, 0x6f, 0x74,
+	0x6f, 0x1a, 0x1f, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2f, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62,
+	0x75, 0x66, 0x2f, 0x74, 0x69, 0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x2e, 0x70, 0x72, 0x6f,
+	0x74, 0x6f, 0x22, 0xcf, 0x01, 0x0a, 0x10, 0x53, 0x74, 0x61, 0x72, 0x74, 0x53, 0x63, 0x61, 0x6e,
+	0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x18, 0x0a, 0x07, 0x74, 0x61, 0x72, 0x67, 0x65,
+	0x74, 0x73, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07, 0x74, 0x61, 0x72, 0x67, 0x65, 0x74,
+	0x73, 0x12, 0x1d, 0x0a, 0x0a, 0x73, 0x74, 0x61, 0x72, 0x74, 0x5f, 0x70, 0x6f, 0x72, 0x74, 0x18,
+	0x02, 0x20, 0x01, 0x28, 0x05, 0x52, 0x09, 0x73, 0x74, 0x61, 0x72, 0x74, 0x50, 0x6f, 0x72, 0x74,
+	0x12, 0x19, 0x0a, 0x08, 0x65, 0x6e, 0x64, 0x5f, 0x70, 0x6f, 0x72, 0x74, 0x18, 0x03, 0x20, 0x01,
+	0x28, 0x05, 0x52, 0x07, 0x65, 0x6e, 0x64, 0x50, 0x6f, 0x72, 0x74, 0x12, 0x33, 0x0a, 0x07, 0x74,
+	0x69, 0x6d, 0x65, 0x6f, 0x75, 0x74, 0x18, 0x04, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x19, 0x2e, 0x67,
+	0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x44,
+	0x75, 0x72, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x52, 0x07, 0x74, 0x69, 0x6d, 0x65, 0x6f, 0x75, 0x74,
+	0x12, 0x18, 0x0a, 0x07, 0x77, 0x6f, 0x72, 0x6b, 0x65, 0x72, 0x73, 0x18, 0x05, 0x20, 0x01, 0x28,
+	0x05, 0x52, 0x07, 0x77, 0x6f, 0x72, 0x6b, 0x65, 0x72, 0x73, 0x12, 0x18, 0x0a, 0x07, 0x6e, 0x65,
+	0x74, 0x77, 0x6f, 0x72, 0x6b, 0x18, 0x06, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07, 0x6e, 0x65, 0x74,
+	0x77, 0x6f, 0x72, 0x6b, 0x22, 0x65, 0x0a, 0x11, 0x53, 0x74, 0x61, 0x72, 0x74, 0x53, 0x63, 0x61,
+	0x6e, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x17, 0x0a, 0x07, 0x73, 0x63, 0x61,
+	0x6e, 0x5f, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x73, 0x63, 0x61, 0x6e,
+	0x49, 0x64, 0x12, 0x14, 0x0a, 0x05, 0x68, 0x6f, 0x73, 0x74, 0x73, 0x18, 0x02, 0x20, 0x01, 0x28,
+	0x05, 0x52, 0x05, 0x68, 0x6f, 0x73, 0x74, 0x73, 0x12, 0x21, 0x0a, 0x0c, 0x74, 0x6f, 0x74, 0x61,
+	0x6c, 0x5f, 0x70, 0x72, 0x6f, 0x62, 0x65, 0x73, 0x18, 0x03, 0x20, 0x01, 0x28, 0x05, 0x52, 0x0b,
+	0x74, 0x6f, 0x74, 0x61, 0x6c, 0x50, 0x72, 0x6f, 0x62, 0x65, 0x73, 0x22, 0x22, 0x0a, 0x07, 0x53,
+	0x63, 0x61, 0x6e, 0x52, 0x65, 0x66, 0x12, 0x17, 0x0a, 0x07, 0x73, 0x63, 0x61, 0x6e, 0x5f, 0x69,
+	0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x73, 0x63, 0x61, 0x6e, 0x49, 0x64, 0x22,
+	0xc7, 0x02, 0x0a, 0x0a, 0x53, 0x63, 0x61, 0x6e, 0x53, 0x74, 0x61, 0x74, 0x75, 0x73, 0x12, 0x17,
+	0x0a, 0x07, 0x73, 0x63, 0x61, 0x6e, 0x5f, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52,
+	0x06, 0x73, 0x63, 0x61, 0x6e, 0x49, 0x64, 0x12, 0x2b, 0x0a, 0x05, 0x73, 0x74, 0x61, 0x74, 0x65,
+	0x18, 0x02, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x15, 0x2e, 0x73, 0x63, 0x61, 0x6e, 0x6e, 0x65, 0x72,
+	0x2e, 0x76, 0x31, 0x2e, 0x53, 0x63, 0x61, 0x6e, 0x53, 0x74, 0x61, 0x74, 0x65, 0x52, 0x05, 0x73,
+	0x74, 0x61, 0x74, 0x65, 0x12, 0x18, 0x0a, 0x07, 0x74, 0x61, 0x72, 0x67, 0x65, 0x74, 0x73, 0x18,
+	0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07, 0x74, 0x61, 0x72, 0x67, 0x65, 0x74, 0x73, 0x12, 0x1f,
+	0x0a, 0x0b, 0x70, 0x72, 0x6f, 0x62, 0x65, 0x73, 0x5f, 0x64, 0x6f, 0x6e, 0x65, 0x18, 0x04, 0x20,
+	0x01, 0x28, 0x05, 0x52, 0x0a, 0x70, 0x72, 0x6f, 0x62, 0x65, 0x73, 0x44, 0x6f, 0x6e, 0x65, 0x12,
+	0x21, 0x0a, 0x0c, 0x70, 0x72, 0x6f, 0x62, 0x65, 0x73, 0x5f, 0x74, 0x6f, 0x74, 0x61, 0x6c, 0x18,
+	0x05, 0x20, 0x01, 0x28, 0x05, 0x52, 0x0b, 0x70, 0x72, 0x6f, 0x62, 0x65, 0x73, 0x54, 0x6f, 0x74,
+	0x61, 0x6c, 0x12, 0x1d, 0x0a, 0x0a, 0x6f, 0x70, 0x65, 0x6e, 0x5f, 0x70, 0x6f, 0x72, 0x74, 0x73,
+	0x18, 0x06, 0x20, 0x01, 0x28, 0x05, 0x52, 0x09, 0x6f, 0x70, 0x65, 0x6e, 0x50, 0x6f, 0x72, 0x74,
+	0x73, 0x12, 0x39, 0x0a, 0x0a, 0x73, 0x74, 0x61, 0x72, 0x74, 0x65, 0x64, 0x5f, 0x61, 0x74, 0x18,
+	0x07, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1a, 0x2e, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70,
+	0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x54, 0x69, 0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d,
+	0x70, 0x52, 0x09, 0x73, 0x74, 0x61, 0x72, 0x74, 0x65, 0x64, 0x41, 0x74, 0x12, 0x3b, 0x0a, 0x0b,
+	0x66, 0x69, 0x6e, 0x69, 0x73, 0x68, 0x65, 0x64, 0x5f, 0x61, 0x74, 0x18, 0x08, 0x20, 0x01, 0x28,
+	0x0b, 0x32, 0x1a, 0x2e, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f,
+	0x62, 0x75, 0x66, 0x2e, 0x54, 0x69, 0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x52, 0x0a, 0x66,
+	0x69, 0x6e, 0x69, 0x73, 0x68, 0x65, 0x64, 0x41, 0x74, 0x22, 0xeb, 0x01, 0x0a, 0x0a, 0x50, 0x6f,
+	0x72, 0x74, 0x52, 0x65, 0x73, 0x75, 0x6c, 0x74, 0x12, 0x12, 0x0a, 0x04, 0x68, 0x6f, 0x73, 0x74,
+	0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x68, 0x6f, 0x73, 0x74, 0x12, 0x12, 0x0a, 0x04,
+	0x70, 0x6f, 0x72, 0x74, 0x18, 0x02, 0x20, 0x01, 0x28, 0x05, 0x52, 0x04, 0x70, 0x6f, 0x72, 0x74,
+	0x12, 0x14, 0x0a, 0x05, 0x73, 0x74, 0x61, 0x74, 0x65, 0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x52,
+	0x05, 0x73, 0x74, 0x61, 0x74, 0x65, 0x12, 0x18, 0x0a, 0x07, 0x73, 0x65, 0x72, 0x76, 0x69, 0x63,
+	0x65, 0x18, 0x04, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07, 0x73, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65,
+	0x12, 0x16, 0x0a, 0x06, 0x62, 0x61, 0x6e, 0x6e, 0x65, 0x72, 0x18, 0x05, 0x20, 0x01, 0x28, 0x09,
+	0x52, 0x06, 0x62, 0x61, 0x6e, 0x6e, 0x65, 0x72, 0x12, 0x33, 0x0a, 0x07, 0x6c, 0x61, 0x74, 0x65,
+	0x6e, 0x63, 0x79, 0x18, 0x06, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x19, 0x2e, 0x67, 0x6f, 0x6f, 0x67,
+	0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x44, 0x75, 0x72, 0x61,
+	0x74, 0x69, 0x6f, 0x6e, 0x52, 0x07, 0x6c, 0x61, 0x74, 0x65, 0x6e, 0x63, 0x79, 0x12, 0x38, 0x0a,
+	0x09, 0x74, 0x69, 0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x18, 0x07, 0x20, 0x01, 0x28, 0x0b,
+	0x32, 0x1a, 0x2e, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62,
+	0x75, 0x66, 0x2e, 0x54, 0x69, 0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x52, 0x09, 0x74, 0x69,
+	0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x2a, 0x6e, 0x0a, 0x09, 0x53, 0x63, 0x61, 0x6e, 0x53,
+	0x74, 0x61, 0x74, 0x65, 0x12, 0x1a, 0x0a, 0x16, 0x53, 0x43, 0x41, 0x4e, 0x5f, 0x53, 0x54, 0x41,
+	0x54, 0x45, 0x5f, 0x55, 0x4e, 0x53, 0x50, 0x45, 0x43, 0x49, 0x46, 0x49, 0x45, 0x44, 0x10, 0x00,
+	0x12, 0x16, 0x0a, 0x12, 0x53, 0x43, 0x41, 0x4e, 0x5f, 0x53, 0x54, 0x41, 0x54, 0x45, 0x5f, 0x52,
+	0x55, 0x4e, 0x4e, 0x49, 0x4e, 0x47, 0x10, 0x01, 0x12, 0x13, 0x0a, 0x0f, 0x53, 0x43, 0x41, 0x4e,
+	0x5f, 0x53, 0x54, 0x41, 0x54, 0x45, 0x5f, 0x44, 0x4f, 0x4e, 0x45, 0x10, 0x02, 0x12, 0x18, 0x0a,
+	0x14, 0x53, 0x43, 0x41, 0x4e, 0x5f, 0x53, 0x54, 0x41, 0x54, 0x45, 0x5f, 0x43, 0x41, 0x4e, 0x43,
+	0x45, 0x4c, 0x4c, 0x45, 0x44, 0x10, 0x03, 0x32, 0x84, 0x02, 0x0a, 0x07, 0x53, 0x63, 0x61, 0x6e,
+	0x6e, 0x65, 0x72, 0x12, 0x48, 0x0a, 0x09, 0x53, 0x74, 0x61, 0x72, 0x74, 0x53, 0x63, 0x61, 0x6e,
+	0x12, 0x1c, 0x2e, 0x73, 0x63, 0x61, 0x6e, 0x6e, 0x65, 0x72, 0x2e, 0x76, 0x31, 0x2e, 0x53, 0x74,
+	0x61, 0x72, 0x74, 0x53, 0x63, 0x61, 0x6e, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x1d,
+	0x2e, 0x73, 0x63, 0x61, 0x6e, 0x6e, 0x65, 0x72, 0x2e, 0x76, 0x31, 0x2e, 0x53, 0x74, 0x61, 0x72,
+	0x74, 0x53, 0x63, 0x61, 0x6e, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x38, 0x0a,
+	0x09, 0x47, 0x65, 0x74, 0x53, 0x74, 0x61, 0x74, 0x75, 0x73, 0x12, 0x13, 0x2e, 0x73, 0x63, 0x61,
+	0x6e, 0x6e, 0x65, 0x72, 0x2e, 0x76, 0x31, 0x2e, 0x53, 0x63, 0x61, 0x6e, 0x52, 0x65, 0x66, 0x1a,
+	0x16, 0x2e, 0x73, 0x63, 0x61, 0x6e, 0x6e, 0x65, 0x72, 0x2e, 0x76, 0x31, 0x2e, 0x53, 0x63, 0x61,
+	0x6e, 0x53, 0x74, 0x61, 0x74, 0x75, 0x73, 0x12, 0x3e, 0x0a, 0x0d, 0x53, 0x74, 0x72, 0x65, 0x61,
+	0x6d, 0x52, 0x65, 0x73, 0x75, 0x6c, 0x74, 0x73, 0x12, 0x13, 0x2e, 0x73, 0x63, 0x61, 0x6e, 0x6e,
+	0x65, 0x72, 0x2e, 0x76, 0x31, 0x2e, 0x53, 0x63, 0x61, 0x6e, 0x52, 0x65, 0x66, 0x1a, 0x16, 0x2e,
+	0x73, 0x63, 0x61, 0x6e, 0x6e, 0x65, 0x72, 0x2e, 0x76, 0x31, 0x2e, 0x50, 0x6f, 0x72, 0x74, 0x52,
+	0x65, 0x73, 0x75, 0x6c, 0x74, 0x30, 0x01, 0x12, 0x35, 0x0a, 0x06, 0x43, 0x61, 0x6e, 0x63, 0x65,
+	0x6c, 0x12, 0x13, 0x2e, 0x73, 0x63, 0x61, 0x6e, 0x6e, 0x65, 0x72, 0x2e, 0x76, 0x31, 0x2e, 0x53,
+	0x63, 0x61, 0x6e, 0x52, 0x65, 0x66, 0x1a, 0x16, 0x2e, 0x73, 0x63, 0x61, 0x6e, 0x6e, 0x65, 0x72,
+	0x2e, 0x76, 0x31, 0x2e, 0x53, 0x63, 0x61, 0x6e, 0x53, 0x74, 0x61, 0x74, 0x75, 0x73, 0x42, 0x41,
+	0x5a, 0x3f, 0x67, 0x69, 0x74, 0x68, 0x75, 0x62, 0x2e, 0x63, 0x6f, 0x6d, 0x2f, 0x63, 0x68, 0x61,
+	0x6e, 0x6e, 0x79, 0x65, 0x69, 0x6e, 0x74, 0x75, 0x6e, 0x2f, 0x6e, 0x65, 0x74, 0x77, 0x6f, 0x72,
+	0x6b, 0x2d, 0x65, 0x78, 0x65, 0x72, 0x63, 0x69, 0x73, 0x65, 0x73, 0x2f, 0x70, 0x6b, 0x67, 0x2f,
+	0x73, 0x63, 0x61, 0x6e, 0x72, 0x70, 0x63, 0x2f, 0x73, 0x63, 0x61, 0x6e, 0x6e, 0x65, 0x72, 0x70,
+	0x62, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
+}
+
+var (
+	file_pkg_scanrpc_scannerpb_scanner_proto_rawDescOnce sync.Once
+	file_pkg_scanrpc_scannerpb_scanner_proto_rawDescData = file_pkg_scanrpc_scannerpb_scanner_proto_rawDesc
+)
+
+func file_pkg_scanrpc_scannerpb_scanner_proto_rawDescGZIP() []byte {
+	file_pkg_scanrpc_scannerpb_scanner_proto_rawDescOnce.Do(func() {
+		file_pkg_scanrpc_scannerpb_scanner_proto_rawDescData = protoimpl.X.CompressGZIP(file_pkg_scanrpc_scannerpb_scanner_proto_rawDescData)
+	})
+	return file_pkg_scanrpc_scannerpb_scanner_proto_rawDescData
+}
+
+var file_pkg_scanrpc_scannerpb_scanner_proto_enumTypes = make([]protoimpl.EnumInfo, 1)
+var file_pkg_scanrpc_scannerpb_scanner_proto_msgTypes = make([]protoimpl.MessageInfo, 5)
+var file_pkg_scanrpc_scannerpb_scanner_proto_goTypes = []any{
+	(ScanState)(0),                // 0: scanner.v1.ScanState
+	(*StartScanRequest)(nil),      // 1: scanner.v1.StartScanRequest
+	(*StartScanResponse)(nil),     // 2: scanner.v1.StartScanResponse
+	(*ScanRef)(nil),               // 3: scanner.v1.ScanRef
+	(*ScanStatus)(nil),            // 4: scanner.v1.ScanStatus
+	(*PortResult)(nil),            // 5: scanner.v1.PortResult
+	(*durationpb.Duration)(nil),   // 6: google.protobuf.Duration
+	(*timestamppb.Timestamp)(nil), // 7: google.protobuf.Timestamp
+}
+var file_pkg_scanrpc_scannerpb_scanner_proto_depIdxs = []int32{
+	6,  // 0: scanner.v1.StartScanRequest.timeout:type_name -> google.protobuf.Duration
+	0,  // 1: scanner.v1.ScanStatus.state:type_name -> scanner.v1.ScanState
+	7,  // 2: scanner.v1.ScanStatus.started_at:type_name -> google.protobuf.Timestamp
+	7,  // 3: scanner.v1.ScanStatus.finished_at:type_name -> google.protobuf.Timestamp
+	6,  // 4: scanner.v1.PortResult.latency:type_name -> google.protobuf.Duration
+	7,  // 5: scanner.v1.PortResult.timestamp:type_name -> google.protobuf.Timestamp
+	1,  // 6: scanner.v1.Scanner.StartScan:input_type -> scanner.v1.StartScanRequest
+	3,  // 7: scanner.v1.Scanner.GetStatus:input_type -> scanner.v1.ScanRef
+	3,  // 8: scanner.v1.Scanner.StreamResults:input_type -> scanner.v1.ScanRef
+	3,  // 9: scanner.v1.Scanner.Cancel:input_type -> scanner.v1.ScanRef
+	2,  // 10: scanner.v1.Scanner.StartScan:output_type -> scanner.v1.StartScanResponse
+	4,  // 11: scanner.v1.Scanner.GetStatus:output_type -> scanner.v1.ScanStatus
+	5,  // 12: scanner.v1.Scanner.StreamResults:output_type -> scanner.v1.PortResult
+	4,  // 13: scanner.v1.Scanner.Cancel:output_type -> scanner.v1.ScanStatus
+	10, // [10:14] is the sub-list for method output_type
+	6,  // [6:10] is the sub-list for method input_type
+	6,  // [6:6] is the sub-list for extension type_name
+	6,  // [6:6] is the sub-list for extension extendee
+	0,  // [0:6] is the sub-list for field type_name
+}
+
+func init() { file_pkg_scanrpc_scannerpb_scanner_proto_init() }
+func file_pkg_scanrpc_scannerpb_scanner_proto_init() {
+	if File_pkg_scanrpc_scannerpb_scanner_proto != nil {
+		return
+	}
+	if !protoimpl.UnsafeEnabled {
+		file_pkg_scanrpc_scannerpb_scanner_proto_msgTypes[0].Exporter = func(v any, i int) any {
+			switch v := v.(*StartScanRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_pkg_scanrpc_scannerpb_scanner_proto_msgTypes[1].Exporter = func(v any, i int) any {
+			switch v := v.(*StartScanResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_pkg_scanrpc_scannerpb_scanner_proto_msgTypes[2].Exporter = func(v any, i int) any {
+			switch v := v.(*ScanRef); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_pkg_scanrpc_scannerpb_scanner_proto_msgTypes[3].Exporter = func(v any, i int) any {
+			switch v := v.(*ScanStatus); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_pkg_scanrpc_scannerpb_scanner_proto_msgTypes[4].Exporter = func(v any, i int) any {
+			switch v := v.(*PortResult); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: file_pkg_scanrpc_scannerpb_scanner_proto_rawDesc,
+			NumEnums:      1,
+			NumMessages:   5,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_pkg_scanrpc_scannerpb_scanner_proto_goTypes,
+		DependencyIndexes: file_pkg_scanrpc_scannerpb_scanner_proto_depIdxs,
+		EnumInfos:         file_pkg_scanrpc_scannerpb_scanner_proto_enumTypes,
+		MessageInfos:      file_pkg_scanrpc_scannerpb_scanner_proto_msgTypes,
+	}.Build()
+	File_pkg_scanrpc_scannerpb_scanner_proto = out.File
+	file_pkg_scanrpc_scannerpb_scanner_proto_rawDesc = nil
+	file_pkg_scanrpc_scannerpb_scanner_proto_goTypes = nil
+	file_pkg_scanrpc_scannerpb_scanner_proto_depIdxs = nil
+}
@@ -0,0 +1,257 @@
+// Remote-control API for the port scanner in pkg/scanner.
+//
+// Regenerate the Go code from the exercises/ directory with:
+//
+//   protoc --go_out=. --go_opt=paths=source_relative \
+//          --go-grpc_out=. --go-grpc_opt=paths=source_relative \
+//          pkg/scanrpc/scannerpb/scanner.proto
+
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             v5.28.3
+// source: pkg/scanrpc/scannerpb/scanner.proto
+
+package scannerpb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	Scanner_StartScan_FullMethodName     = "/scanner.v1.Scanner/StartScan"
+	Scanner_GetStatus_FullMethodName     = "/scanner.v1.Scanner/GetStatus"
+	Scanner_StreamResults_FullMethodName = "/scanner.v1.Scanner/StreamResults"
+	Scanner_Cancel_FullMethodName        = "/scanner.v1.Scanner/Cancel"
+)
+
+// ScannerClient is the client API for Scanner service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type ScannerClient interface {
+	// StartScan launches a scan in the background and returns its ID
+	StartScan(ctx context.Context, in *StartScanRequest, opts ...grpc.CallOption) (*StartScanResponse, error)
+	// GetStatus reports the progress of a scan
+	GetStatus(ctx context.Context, in *ScanRef, opts ...grpc.CallOption) (*ScanStatus, error)
+	// StreamResults sends every open port found so far, then new ones as
+	// they turn up, and ends when the scan finishes
+	StreamResults(ctx context.Context, in *ScanRef, opts ...grpc.CallOption) (grpc.ServerStreamingClient[PortResult], error)
+	// Cancel stops a running scan; results found so far are kept
+	Cancel(ctx context.Context, in *ScanRef, opts ...grpc.CallOption) (*ScanStatus, error)
+}
+
+type scannerClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewScannerClient(cc grpc.ClientConnInterface) ScannerClient {
+	return &scannerClient{cc}
+}
+
+func (c *scannerClient) StartScan(ctx context.Context, in *StartScanRequest, opts ...grpc.CallOption) (*StartScanResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(StartScanResponse)
+	err := c.cc.Invoke(ctx, Scanner_StartScan_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *scannerClient) GetStatus(ctx context.Context, in *ScanRef, opts ...grpc.CallOption) (*ScanStatus, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ScanStatus)
+	err := c.cc.Invoke(ctx, Scanner_GetStatus_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *scannerClient) StreamResults(ctx context.Context, in *ScanRef, opts ...grpc.CallOption) (grpc.ServerStreamingClient[PortResult], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Scanner_ServiceDesc.Streams[0], Scanner_StreamResults_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[ScanRef, PortResult]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Scanner_StreamResultsClient = grpc.ServerStreamingClient[PortResult]
+
+func (c *scannerClient) Cancel(ctx context.Context, in *ScanRef, opts ...grpc.CallOption) (*ScanStatus, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ScanStatus)
+	err := c.cc.Invoke(ctx, Scanner_Cancel_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// ScannerServer is the server API for Scanner service.
+// All implementations must embed UnimplementedScannerServer
+// for forward compatibility.
+type ScannerServer interface {
+	// StartScan launches a scan in the background and returns its ID
+	StartScan(context.Context, *StartScanRequest) (*StartScanResponse, error)
+	// GetStatus reports the progress of a scan
+	GetStatus(context.Context, *ScanRef) (*ScanStatus, error)
+	// StreamResults sends every open port found so far, then new ones as
+	// they turn up, and ends when the scan finishes
+	StreamResults(*ScanRef, grpc.ServerStreamingServer[PortResult]) error
+	// Cancel stops a running scan; results found so far are kept
+	Cancel(context.Context, *ScanRef) (*ScanStatus, error)
+	mustEmbedUnimplementedScannerServer()
+}
+
+// UnimplementedScannerServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedScannerServer struct{}
+
+func (UnimplementedScannerServer) StartScan(context.Context, *StartScanRequest) (*StartScanResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method StartScan not implemented")
+}
+func (UnimplementedScannerServer) GetStatus(context.Context, *ScanRef) (*ScanStatus, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetStatus not implemented")
+}
+func (UnimplementedScannerServer) StreamResults(*ScanRef, grpc.ServerStreamingServer[PortResult]) error {
+	return status.Errorf(codes.Unimplemented, "method StreamResults not implemented")
+}
+func (UnimplementedScannerServer) Cancel(context.Context, *ScanRef) (*ScanStatus, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Cancel not implemented")
+}
+func (UnimplementedScannerServer) mustEmbedUnimplementedScannerServer() {}
+func (UnimplementedScannerServer) testEmbeddedByValue()                 {}
+
+// UnsafeScannerServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to ScannerServer will
+// result in compilation errors.
+type UnsafeScannerServer interface {
+	mustEmbedUnimplementedScannerServer()
+}
+
+func RegisterScannerServer(s grpc.ServiceRegistrar, srv ScannerServer) {
+	// If the following call pancis, it indicates UnimplementedScannerServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&Scanner_ServiceDesc, srv)
+}
+
+func _Scanner_StartScan_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(StartScanRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ScannerServer).StartScan(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Scanner_StartScan_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ScannerServer).StartScan(ctx, req.(*StartScanRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Scanner_GetStatus_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ScanRef)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ScannerServer).GetStatus(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Scanner_GetStatus_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ScannerServer).GetStatus(ctx, req.(*ScanRef))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Scanner_StreamResults_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(ScanRef)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(ScannerServer).StreamResults(m, &grpc.GenericServerStream[ScanRef, PortResult]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Scanner_StreamResultsServer = grpc.ServerStreamingServer[PortResult]
+
+func _Scanner_Cancel_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ScanRef)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ScannerServer).Cancel(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Scanner_Cancel_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ScannerServer).Cancel(ctx, req.(*ScanRef))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// Scanner_ServiceDesc is the grpc.ServiceDesc for Scanner service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Scanner_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "scanner.v1.Scanner",
+	HandlerType: (*ScannerServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "StartScan",
+			Handler:    _Scanner_StartScan_Handler,
+		},
+		{
+			MethodName: "GetStatus",
+			Handler:    _Scanner_GetStatus_Handler,
+		},
+		{
+			MethodName: "Cancel",
+			Handler:    _Scanner_Cancel_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "StreamResults",
+			Handler:       _Scanner_StreamResults_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "pkg/scanrpc/scannerpb/scanner.proto",
+}
@@ -0,0 +1,248 @@
+// Package scanrpc exposes the pkg/scanner engine over gRPC so scans can
+// be launched and watched from another machine or a UI.
+//
+//	grpcServer := grpc.NewServer()
+//	scannerpb.RegisterScannerServer(grpcServer, scanrpc.NewServer(nil))
+//	grpcServer.Serve(listener)
+//
+// The service definition lives in scannerpb/scanner.proto.
+package scanrpc
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/channyeintun/network-exercises/pkg/scanner"
+	"github.com/channyeintun/network-exercises/pkg/scanrpc/scannerpb"
+)
+
+// Server implements scannerpb.ScannerServer. Each StartScan runs in its
+// own goroutine; finished scans stay queryable until the server exits.
+type Server struct {
+	scannerpb.UnimplementedScannerServer
+
+	dialer scanner.Dialer
+
+	mu     sync.Mutex
+	nextID int
+	jobs   map[string]*job
+}
+
+// NewServer returns a Server that dials through dialer, or directly if
+// dialer is nil
+func NewServer(dialer scanner.Dialer) *Server {
+	return &Server{
+		dialer: dialer,
+		jobs:   make(map[string]*job),
+	}
+}
+
+// job tracks one scan launched through the API
+type job struct {
+	id      string
+	targets string
+	total   int
+	started time.Time
+	cancel  context.CancelFunc
+	stopped chan struct{} // closed once the scan goroutine exits
+
+	mu       sync.Mutex
+	state    scannerpb.ScanState
+	done     int
+	open     []scanner.Result
+	finished time.Time
+	changed  chan struct{} // closed and replaced when open or state changes
+}
+
+func (s *Server) StartScan(ctx context.Context, req *scannerpb.StartScanRequest) (*scannerpb.StartScanResponse, error) {
+	if req.GetTargets() == "" {
+		return nil, status.Error(codes.InvalidArgument, "targets is required")
+	}
+
+	hosts, err := scanner.ExpandTargets(req.GetTargets())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	if err := scanner.CheckFamily(hosts, req.GetNetwork()); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	// The scan outlives this RPC, so it gets its own context
+	scanCtx, cancel := context.WithCancel(context.Background())
+
+	results, err := scanner.Scan(scanCtx, scanner.Config{
+		Hosts:     hosts,
+		StartPort: int(req.GetStartPort()),
+		EndPort:   int(req.GetEndPort()),
+		Network:   req.GetNetwork(),
+		Timeout:   req.GetTimeout().AsDuration(),
+		Workers:   int(req.GetWorkers()),
+		Dialer:    s.dialer,
+	})
+	if err != nil {
+		cancel()
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	s.mu.Lock()
+	s.nextID++
+	j := &job{
+		id:      fmt.Sprintf("scan-%d", s.nextID),
+		targets: req.GetTargets(),
+		total:   len(hosts) * int(req.GetEndPort()-req.GetStartPort()+1),
+		started: time.Now(),
+		cancel:  cancel,
+		stopped: make(chan struct{}),
+		state:   scannerpb.ScanState_SCAN_STATE_RUNNING,
+		changed: make(chan struct{}),
+	}
+	s.jobs[j.id] = j
+	s.mu.Unlock()
+
+	go j.run(scanCtx, results)
+
+	return &scannerpb.StartScanResponse{
+		ScanId:      j.id,
+		Hosts:       int32(len(hosts)),
+		TotalProbes: int32(j.total),
+	}, nil
+}
+
+func (s *Server) GetStatus(ctx context.Context, ref *scannerpb.ScanRef) (*scannerpb.ScanStatus, error) {
+	j, err := s.lookup(ref)
+	if err != nil {
+		return nil, err
+	}
+	return j.status(), nil
+}
+
+func (s *Server) StreamResults(ref *scannerpb.ScanRef, stream scannerpb.Scanner_StreamResultsServer) error {
+	j, err := s.lookup(ref)
+	if err != nil {
+		return err
+	}
+
+	sent := 0
+	for {
+		j.mu.Lock()
+		batch := append([]scanner.Result(nil), j.open[sent:]...)
+		running := j.state == scannerpb.ScanState_SCAN_STATE_RUNNING
+		changed := j.changed
+		j.mu.Unlock()
+
+		for _, r := range batch {
+			if err := stream.Send(toProto(r)); err != nil {
+				return err
+			}
+		}
+		sent += len(batch)
+
+		if !running {
+			return nil
+		}
+
+		select {
+		case <-changed:
+		case <-stream.Context().Done():
+			return stream.Context().Err()
+		}
+	}
+}
+
+func (s *Server) Cancel(ctx context.Context, ref *scannerpb.ScanRef) (*scannerpb.ScanStatus, error) {
+	j, err := s.lookup(ref)
+	if err != nil {
+		return nil, err
+	}
+
+	j.cancel()
+
+	// Workers finish their in-flight probes first, which takes at most
+	// one probe timeout
+	select {
+	case <-j.stopped:
+	case <-ctx.Done():
+		return nil, status.FromContextError(ctx.Err()).Err()
+	}
+	return j.status(), nil
+}
+
+func (s *Server) lookup(ref *scannerpb.ScanRef) (*job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	j, ok := s.jobs[ref.GetScanId()]
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "no scan with id %q", ref.GetScanId())
+	}
+	return j, nil
+}
+
+// run drains the scan's results and records the outcome
+func (j *job) run(ctx context.Context, results <-chan scanner.Result) {
+	defer close(j.stopped)
+
+	for r := range results {
+		j.mu.Lock()
+		j.done++
+		if r.State == scanner.StateOpen {
+			j.open = append(j.open, r)
+			j.notify()
+		}
+		j.mu.Unlock()
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	j.finished = time.Now()
+	j.state = scannerpb.ScanState_SCAN_STATE_DONE
+	if ctx.Err() != nil {
+		j.state = scannerpb.ScanState_SCAN_STATE_CANCELLED
+	}
+	j.notify()
+}
+
+// notify wakes StreamResults callers; j.mu must be held
+func (j *job) notify() {
+	close(j.changed)
+	j.changed = make(chan struct{})
+}
+
+func (j *job) status() *scannerpb.ScanStatus {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	st := &scannerpb.ScanStatus{
+		ScanId:      j.id,
+		State:       j.state,
+		Targets:     j.targets,
+		ProbesDone:  int32(j.done),
+		ProbesTotal: int32(j.total),
+		OpenPorts:   int32(len(j.open)),
+		StartedAt:   timestamppb.New(j.started),
+	}
+	if !j.finished.IsZero() {
+		st.FinishedAt = timestamppb.New(j.finished)
+	}
+	return st
+}
+
+func toProto(r scanner.Result) *scannerpb.PortResult {
+	return &scannerpb.PortResult{
+		Host:      r.Host,
+		Port:      int32(r.Port),
+		State:     r.State,
+		Service:   r.Service,
+		Banner:    r.Banner,
+		Latency:   durationpb.New(r.Latency),
+		Timestamp: timestamppb.New(r.Timestamp),
+	}
+}
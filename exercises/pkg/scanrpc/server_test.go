@@ -0,0 +1,162 @@
+package scanrpc
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/durationpb"
+
+	"github.com/channyeintun/network-exercises/pkg/scanner"
+	"github.com/channyeintun/network-exercises/pkg/scanrpc/scannerpb"
+)
+
+// newClient serves a Server over an in-memory bufconn listener and
+// returns a client connected to it
+func newClient(t *testing.T, dialer scanner.Dialer) scannerpb.ScannerClient {
+	t.Helper()
+
+	lis := bufconn.Listen(1 << 20)
+	srv := grpc.NewServer()
+	scannerpb.RegisterScannerServer(srv, NewServer(dialer))
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	return scannerpb.NewScannerClient(conn)
+}
+
+func TestScanStreamsOpenPorts(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	port := int32(ln.Addr().(*net.TCPAddr).Port)
+
+	client := newClient(t, nil)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	started, err := client.StartScan(ctx, &scannerpb.StartScanRequest{
+		Targets:   "127.0.0.1",
+		StartPort: port,
+		EndPort:   port,
+		Timeout:   durationpb.New(time.Second),
+	})
+	if err != nil {
+		t.Fatalf("StartScan: %v", err)
+	}
+	if started.TotalProbes != 1 {
+		t.Errorf("TotalProbes = %d, want 1", started.TotalProbes)
+	}
+
+	stream, err := client.StreamResults(ctx, &scannerpb.ScanRef{ScanId: started.ScanId})
+	if err != nil {
+		t.Fatalf("StreamResults: %v", err)
+	}
+
+	var got []*scannerpb.PortResult
+	for {
+		r, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Recv: %v", err)
+		}
+		got = append(got, r)
+	}
+	if len(got) != 1 || got[0].Port != port || got[0].State != scanner.StateOpen {
+		t.Fatalf("streamed %v, want one open result for port %d", got, port)
+	}
+
+	// The stream only ends once the scan is done
+	st, err := client.GetStatus(ctx, &scannerpb.ScanRef{ScanId: started.ScanId})
+	if err != nil {
+		t.Fatalf("GetStatus: %v", err)
+	}
+	if st.State != scannerpb.ScanState_SCAN_STATE_DONE || st.ProbesDone != 1 || st.OpenPorts != 1 {
+		t.Errorf("status = %v, want done with 1 probe and 1 open port", st)
+	}
+	if st.FinishedAt == nil {
+		t.Error("FinishedAt not set on a finished scan")
+	}
+}
+
+// hangingDialer never connects; it blocks until the probe is abandoned
+type hangingDialer struct{}
+
+func (hangingDialer) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func TestCancelStopsScan(t *testing.T) {
+	client := newClient(t, hangingDialer{})
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	started, err := client.StartScan(ctx, &scannerpb.StartScanRequest{
+		Targets:   "127.0.0.1",
+		StartPort: 1,
+		EndPort:   1000,
+		Timeout:   durationpb.New(time.Minute),
+		Workers:   10,
+	})
+	if err != nil {
+		t.Fatalf("StartScan: %v", err)
+	}
+
+	st, err := client.Cancel(ctx, &scannerpb.ScanRef{ScanId: started.ScanId})
+	if err != nil {
+		t.Fatalf("Cancel: %v", err)
+	}
+	if st.State != scannerpb.ScanState_SCAN_STATE_CANCELLED {
+		t.Errorf("state after Cancel = %v, want cancelled", st.State)
+	}
+	if st.ProbesDone >= st.ProbesTotal {
+		t.Errorf("cancelled scan reports %d/%d probes done", st.ProbesDone, st.ProbesTotal)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	client := newClient(t, nil)
+	ctx := context.Background()
+
+	_, err := client.GetStatus(ctx, &scannerpb.ScanRef{ScanId: "scan-404"})
+	if status.Code(err) != codes.NotFound {
+		t.Errorf("GetStatus of unknown scan: %v, want NotFound", err)
+	}
+
+	bad := []*scannerpb.StartScanRequest{
+		{},
+		{Targets: "127.0.0.1", StartPort: 100, EndPort: 1},
+		{Targets: "127.0.0.1", StartPort: 1, EndPort: 70000},
+		{Targets: "10.0.0.0/33", StartPort: 1, EndPort: 10},
+		{Targets: "::1", StartPort: 1, EndPort: 10, Network: "tcp4"},
+	}
+	for _, req := range bad {
+		if _, err := client.StartScan(ctx, req); status.Code(err) != codes.InvalidArgument {
+			t.Errorf("StartScan(%v): %v, want InvalidArgument", req, err)
+		}
+	}
+}